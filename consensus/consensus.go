@@ -35,6 +35,18 @@ type Consenter interface {
 	ExecutionConsumer
 }
 
+// Reconfigurer is implemented by consensus plugins which can change the set
+// of validating peers without a network restart
+type Reconfigurer interface {
+	Reconfigure(validators []*pb.PeerID) error // Orders the replacement of the validator set
+}
+
+// StatusReporter is implemented by consensus plugins which expose their state
+// and metrics for introspection
+type StatusReporter interface {
+	GetConsensusStatus() (*pb.ConsensusStatus, error) // Returns the state and metrics of the plugin
+}
+
 // Inquirer is used to retrieve info about the validating network
 type Inquirer interface {
 	GetNetworkInfo() (self *pb.PeerEndpoint, network []*pb.PeerEndpoint, err error)
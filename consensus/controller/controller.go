@@ -17,6 +17,7 @@ limitations under the License.
 package controller
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/op/go-logging"
@@ -25,6 +26,7 @@ import (
 	"github.com/hyperledger/fabric/consensus"
 	"github.com/hyperledger/fabric/consensus/noops"
 	"github.com/hyperledger/fabric/consensus/pbft"
+	"github.com/hyperledger/fabric/consensus/raft"
 )
 
 var logger *logging.Logger // package-level logger
@@ -35,14 +37,23 @@ func init() {
 }
 
 // NewConsenter constructs a Consenter object if not already present
-func NewConsenter(stack consensus.Stack) consensus.Consenter {
+func NewConsenter(stack consensus.Stack) (consensus.Consenter, error) {
 
 	plugin := strings.ToLower(viper.GetString("peer.validator.consensus.plugin"))
 	if plugin == "pbft" {
+		// PBFT messages are signed with the enrollment keys of the validating peers, without
+		// them any peer could send messages in the name of the others
+		if !viper.GetBool("security.enabled") {
+			return nil, fmt.Errorf("The %s consensus plugin requires security.enabled to authenticate its messages", plugin)
+		}
 		logger.Infof("Creating consensus plugin %s", plugin)
-		return pbft.GetPlugin(stack)
+		return pbft.GetPlugin(stack), nil
+	}
+	if plugin == "raft" {
+		logger.Infof("Creating consensus plugin %s", plugin)
+		return raft.GetPlugin(stack), nil
 	}
 	logger.Info("Creating default consensus plugin (noops)")
-	return noops.GetNoops(stack)
+	return noops.GetNoops(stack), nil
 
 }
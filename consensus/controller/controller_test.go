@@ -0,0 +1,33 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestNewConsenterPBFTSecurityDisabled(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("peer.validator.consensus.plugin", "pbft")
+	viper.Set("security.enabled", false)
+
+	if consenter, err := NewConsenter(nil); err == nil {
+		t.Fatalf("Expected PBFT to be refused with security disabled, got %v", consenter)
+	}
+}
@@ -90,6 +90,24 @@ func (eng *EngineImpl) ProcessTransactionMsg(msg *pb.Message, tx *pb.Transaction
 	return response
 }
 
+// Reconfigure asks the consenter to replace the validator set of the network
+func (eng *EngineImpl) Reconfigure(validators []*pb.PeerID) error {
+	reconfigurer, ok := eng.consenter.(consensus.Reconfigurer)
+	if !ok {
+		return fmt.Errorf("Consensus plugin %T does not support changing the validator set", eng.consenter)
+	}
+	return reconfigurer.Reconfigure(validators)
+}
+
+// GetConsensusStatus asks the consenter for its state and metrics
+func (eng *EngineImpl) GetConsensusStatus() (*pb.ConsensusStatus, error) {
+	reporter, ok := eng.consenter.(consensus.StatusReporter)
+	if !ok {
+		return nil, fmt.Errorf("Consensus plugin %T does not report its status", eng.consenter)
+	}
+	return reporter.GetConsensusStatus()
+}
+
 func (eng *EngineImpl) setConsenter(consenter consensus.Consenter) *EngineImpl {
 	eng.consenter = consenter
 	return eng
@@ -108,13 +126,34 @@ func getEngineImpl() *EngineImpl {
 	return engine
 }
 
+// GetReconfigurer returns the initialized engine as a consensus.Reconfigurer,
+// or nil if the engine was not initialized
+func GetReconfigurer() consensus.Reconfigurer {
+	if engine == nil {
+		return nil
+	}
+	return engine
+}
+
+// GetStatusReporter returns the initialized engine as a
+// consensus.StatusReporter, or nil if the engine was not initialized
+func GetStatusReporter() consensus.StatusReporter {
+	if engine == nil {
+		return nil
+	}
+	return engine
+}
+
 // GetEngine returns initialized peer.Engine
 func GetEngine(coord peer.MessageHandlerCoordinator) (peer.Engine, error) {
 	var err error
 	engineOnce.Do(func() {
 		engine = new(EngineImpl)
 		engine.helper = NewHelper(coord)
-		engine.consenter = controller.NewConsenter(engine.helper)
+		if engine.consenter, err = controller.NewConsenter(engine.helper); err != nil {
+			engine = nil
+			return
+		}
 		engine.helper.setConsenter(engine.consenter)
 		engine.peerEndpoint, err = coord.GetPeerEndpoint()
 		engine.consensusFan = util.NewMessageFan()
@@ -128,5 +167,8 @@ func GetEngine(coord peer.MessageHandlerCoordinator) (peer.Engine, error) {
 			}
 		}()
 	})
+	if engine == nil {
+		return nil, err
+	}
 	return engine, err
 }
@@ -120,21 +120,21 @@ func (h *Helper) Unicast(msg *pb.Message, receiverHandle *pb.PeerID) error {
 }
 
 // Sign a message with this validator's signing key
+// If security is disabled, there is no signing key and Sign fails
 func (h *Helper) Sign(msg []byte) ([]byte, error) {
-	if h.secOn {
-		return h.secHelper.Sign(msg)
+	if !h.secOn {
+		return nil, fmt.Errorf("Cannot sign messages with security disabled")
 	}
-	logger.Debug("Security is disabled")
-	return msg, nil
+	return h.secHelper.Sign(msg)
 }
 
 // Verify that the given signature is valid under the given replicaID's verification key
 // If replicaID is nil, use this validator's verification key
 // If the signature is valid, the function should return nil
+// If security is disabled, no message can be authenticated and Verify fails
 func (h *Helper) Verify(replicaID *pb.PeerID, signature []byte, message []byte) error {
 	if !h.secOn {
-		logger.Debug("Security is disabled")
-		return nil
+		return fmt.Errorf("Cannot verify messages with security disabled")
 	}
 
 	logger.Debugf("Verify message from: %v", replicaID.Name)
@@ -180,22 +180,10 @@ func (h *Helper) ExecTxs(id interface{}, txs []*pb.Transaction) ([]byte, error)
 	// cxt := context.WithValue(context.Background(), "security", h.coordinator.GetSecHelper())
 	// TODO return directly once underlying implementation no longer returns []error
 
-	succeededTxs, res, ccevents, txerrs, err := chaincode.ExecuteTransactions(context.Background(), chaincode.DefaultChain, txs)
+	succeededTxs, res, txresults, err := chaincode.ExecuteTransactions(context.Background(), chaincode.DefaultChain, txs)
 
 	h.curBatch = append(h.curBatch, succeededTxs...) // TODO, remove after issue 579
 
-	//copy errs to result
-	txresults := make([]*pb.TransactionResult, len(txerrs))
-
-	//process errors for each transaction
-	for i, e := range txerrs {
-		//NOTE- it'll be nice if we can have error values. For now success == 0, error == 1
-		if txerrs[i] != nil {
-			txresults[i] = &pb.TransactionResult{Uuid: txs[i].Uuid, Error: e.Error(), ErrorCode: 1, ChaincodeEvent: ccevents[i]}
-		} else {
-			txresults[i] = &pb.TransactionResult{Uuid: txs[i].Uuid, ChaincodeEvent: ccevents[i]}
-		}
-	}
 	h.curBatchErrs = append(h.curBatchErrs, txresults...) // TODO, remove after issue 579
 
 	return res, err
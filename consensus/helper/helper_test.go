@@ -16,8 +16,22 @@ limitations under the License.
 
 package helper
 
-import "testing"
+import (
+	"testing"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
 
 func TestHelper(t *testing.T) {
 	t.Skip("Helper functions already tested in other consensus components")
 }
+
+func TestSignVerifySecurityDisabled(t *testing.T) {
+	h := &Helper{secOn: false}
+	if sig, err := h.Sign([]byte("msg")); err == nil {
+		t.Fatalf("Expected signing to fail with security disabled, got signature %x", sig)
+	}
+	if err := h.Verify(&pb.PeerID{Name: "vp1"}, nil, []byte("msg")); err == nil {
+		t.Fatal("Expected any message to fail verification with security disabled")
+	}
+}
@@ -51,10 +51,10 @@ func (h *Helper) ReadStateSet(prefix string) (map[string][]byte, error) {
 	it := db.GetIterator(db.PersistCF)
 	defer it.Close()
 	for it.Seek(prefixRaw); it.ValidForPrefix(prefixRaw); it.Next() {
-		key := string(it.Key().Data())
+		key := string(it.Key())
 		key = key[len("consensus."):]
 		// copy data from the slice!
-		ret[key] = append([]byte(nil), it.Value().Data()...)
+		ret[key] = append([]byte(nil), it.Value()...)
 	}
 	return ret, nil
 }
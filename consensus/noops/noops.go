@@ -25,6 +25,7 @@ import (
 	"github.com/op/go-logging"
 
 	"github.com/hyperledger/fabric/consensus"
+	consensusutil "github.com/hyperledger/fabric/consensus/util"
 	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/util"
@@ -33,17 +34,27 @@ import (
 
 var logger *logging.Logger // package-level logger
 
+// statusTimeout bounds the wait for the status of the plugin
+const statusTimeout = 5 * time.Second
+
 func init() {
 	logger = logging.MustGetLogger("consensus/noops")
 }
 
 // Noops is a plugin object implementing the consensus.Consenter interface.
 type Noops struct {
-	stack    consensus.Stack
-	txQ      *txq
-	timer    *time.Timer
-	duration time.Duration
-	channel  chan *pb.Transaction
+	stack      consensus.Stack
+	txQ        *txq
+	timer      *time.Timer
+	duration   time.Duration
+	channel    chan *pb.Transaction
+	statusChan chan chan *pb.ConsensusStatus
+
+	// metrics, only accessed from handleChannels
+	batches        uint64
+	requests       uint64
+	batchLatency   *consensusutil.Histogram
+	requestLatency *consensusutil.Histogram
 }
 
 // Setting up a singleton NOOPS consenter
@@ -83,6 +94,9 @@ func newNoops(c consensus.Stack) consensus.Consenter {
 	i.txQ = newTXQ(blockSize)
 
 	i.channel = make(chan *pb.Transaction, 100)
+	i.statusChan = make(chan chan *pb.ConsensusStatus)
+	i.batchLatency = consensusutil.NewHistogram(consensusutil.LatencyBounds)
+	i.requestLatency = consensusutil.NewHistogram(consensusutil.LatencyBounds)
 	i.timer = time.NewTimer(i.duration) // start timer now so we can just reset it
 	i.timer.Stop()
 	go i.handleChannels()
@@ -173,6 +187,8 @@ func (i *Noops) handleChannels() {
 			if err := i.processBlock(); nil != err {
 				logger.Error(err.Error())
 			}
+		case result := <-i.statusChan:
+			result <- i.status()
 		}
 	}
 }
@@ -210,6 +226,7 @@ func (i *Noops) processTransactions() error {
 	}
 
 	// Grab all transactions from the FIFO queue and run them in order
+	start := time.Now()
 	txarr := i.txQ.getTXs()
 	if logger.IsEnabledFor(logging.DEBUG) {
 		logger.Debugf("Executing batch of %d transactions with timestamp %v", len(txarr), timestamp)
@@ -231,9 +248,54 @@ func (i *Noops) processTransactions() error {
 		i.stack.RollbackTxBatch(timestamp)
 		return err
 	}
+	i.recordBatch(start, txarr)
 	return nil
 }
 
+// recordBatch observes the latencies of the committed batch and its transactions
+func (i *Noops) recordBatch(start time.Time, txs []*pb.Transaction) {
+	i.batches++
+	i.batchLatency.Observe(time.Since(start))
+	for _, tx := range txs {
+		i.requests++
+		if tx.Timestamp != nil {
+			created := time.Unix(tx.Timestamp.Seconds, int64(tx.Timestamp.Nanos))
+			i.requestLatency.Observe(time.Since(created))
+		}
+	}
+}
+
+func (i *Noops) status() *pb.ConsensusStatus {
+	return &pb.ConsensusStatus{
+		Plugin:              "noops",
+		OutstandingRequests: uint64(i.txQ.size()),
+		Metrics: &pb.ConsensusMetrics{
+			Batches:        i.batches,
+			Requests:       i.requests,
+			BatchLatency:   i.batchLatency.ToProto(),
+			RequestLatency: i.requestLatency.ToProto(),
+		},
+	}
+}
+
+// GetConsensusStatus returns the transactions waiting for a block and the
+// metrics of the blocks committed so far
+func (i *Noops) GetConsensusStatus() (*pb.ConsensusStatus, error) {
+	result := make(chan *pb.ConsensusStatus, 1)
+	timeout := time.After(statusTimeout)
+	select {
+	case i.statusChan <- result:
+	case <-timeout:
+		return nil, fmt.Errorf("Timed out requesting the consensus status")
+	}
+	select {
+	case status := <-result:
+		return status, nil
+	case <-timeout:
+		return nil, fmt.Errorf("Timed out waiting for the consensus status")
+	}
+}
+
 func (i *Noops) getTxFromMsg(msg *pb.Message) (*pb.Transaction, error) {
 	txs := &pb.TransactionBlock{}
 	if err := proto.Unmarshal(msg.Payload, txs); err != nil {
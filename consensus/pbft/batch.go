@@ -84,7 +84,7 @@ func newObcBatch(id uint64, config *viper.Viper, stack consensus.Stack) *obcBatc
 	op.pbft = newPbftCore(id, config, op, etf)
 	op.manager.Start()
 	op.externalEventReceiver.manager = op.manager
-	op.broadcaster = newBroadcaster(id, op.pbft.N, op.pbft.f, stack)
+	op.broadcaster = newBroadcaster(id, op.pbft.replicas, op.pbft.f, stack)
 
 	op.batchSize = config.GetInt("general.batchsize")
 	op.batchStore = nil
@@ -139,21 +139,46 @@ func (op *obcBatch) submitToLeader(req *Request) events.Event {
 
 func (op *obcBatch) broadcastMsg(msg *BatchMessage) {
 	msgPayload, _ := proto.Marshal(msg)
-	ocMsg := &pb.Message{
-		Type:    pb.Message_CONSENSUS,
-		Payload: msgPayload,
-	}
-	op.broadcaster.Broadcast(ocMsg)
+	op.broadcaster.Broadcast(op.newConsensusMessage(msgPayload))
 }
 
 // send a message to a specific replica
 func (op *obcBatch) unicastMsg(msg *BatchMessage, receiverID uint64) {
 	msgPayload, _ := proto.Marshal(msg)
-	ocMsg := &pb.Message{
-		Type:    pb.Message_CONSENSUS,
-		Payload: msgPayload,
+	op.broadcaster.Unicast(op.newConsensusMessage(msgPayload), receiverID)
+}
+
+// Wraps a packed batch message into a Fabric message, signed by this
+// replica so that every receiver can authenticate it
+func (op *obcBatch) newConsensusMessage(msgPayload []byte) *pb.Message {
+	signature, err := op.sign(msgPayload)
+	if err != nil {
+		logger.Errorf("Replica %d could not sign message: %s", op.pbft.id, err)
+	}
+	return &pb.Message{
+		Type:      pb.Message_CONSENSUS,
+		Payload:   msgPayload,
+		Signature: signature,
 	}
-	op.broadcaster.Unicast(ocMsg, receiverID)
+}
+
+// verifyRequests checks the signature of every request carried by a pbft
+// message, requests are relayed and must be authenticated by their origin
+func (op *obcBatch) verifyRequests(msg *Message) error {
+	var reqBatch *RequestBatch
+	if preprep := msg.GetPrePrepare(); preprep != nil {
+		reqBatch = preprep.GetRequestBatch()
+	} else if rb := msg.GetRequestBatch(); rb != nil {
+		reqBatch = rb
+	} else if rb := msg.GetReturnRequestBatch(); rb != nil {
+		reqBatch = rb
+	}
+	for _, req := range reqBatch.GetBatch() {
+		if err := op.pbft.verify(req); err != nil {
+			return fmt.Errorf("request from replica %d has an invalid signature: %s", req.ReplicaId, err)
+		}
+	}
+	return nil
 }
 
 // =============================================================================
@@ -187,6 +212,14 @@ func (op *obcBatch) verify(senderID uint64, signature []byte, message []byte) er
 func (op *obcBatch) execute(seqNo uint64, reqBatch *RequestBatch) {
 	var txs []*pb.Transaction
 	for _, req := range reqBatch.GetBatch() {
+		if reconfiguration := req.GetReconfiguration(); reconfiguration != nil {
+			logger.Debugf("Batch replica %d executing reconfiguration request from replica %d, seqNo=%d", op.pbft.id, req.ReplicaId, seqNo)
+			op.reqStore.remove(req)
+			op.deduplicator.Execute(req)
+			op.pbft.voteReconfiguration(seqNo, req.ReplicaId, reconfiguration.Replicas)
+			op.pbft.metrics.recordRequest(req)
+			continue
+		}
 		tx := &pb.Transaction{}
 		if err := proto.Unmarshal(req.Payload, tx); err != nil {
 			logger.Warningf("Batch replica %d could not unmarshal transaction %s", op.pbft.id, err)
@@ -198,12 +231,27 @@ func (op *obcBatch) execute(seqNo uint64, reqBatch *RequestBatch) {
 		}
 		txs = append(txs, tx)
 		op.deduplicator.Execute(req)
+		op.pbft.metrics.recordRequest(req)
 	}
-	meta, _ := proto.Marshal(&Metadata{seqNo})
+	meta, _ := proto.Marshal(&Metadata{
+		SeqNo:                seqNo,
+		Reconfiguration:      op.pbft.reconfiguration,
+		ReconfigurationVotes: op.pbft.getReconfigurationVotes(),
+	})
 	logger.Debugf("Batch replica %d received exec for seqNo %d containing %d transactions", op.pbft.id, seqNo, len(txs))
 	op.stack.Execute(meta, txs) // This executes in the background, we will receive an executedEvent once it completes
 }
 
+// change the replicas messages are sent to
+func (op *obcBatch) reconfigure(replicas []uint64) {
+	if op.broadcaster == nil {
+		// Restored while starting up, the broadcaster is created for the restored replica set
+		return
+	}
+	op.broadcaster.Close()
+	op.broadcaster = newBroadcaster(op.pbft.id, replicas, op.pbft.f, op.stack)
+}
+
 // =============================================================================
 // functions specific to batch mode
 // =============================================================================
@@ -249,10 +297,23 @@ func (op *obcBatch) txToReq(tx []byte) *Request {
 		Payload:   tx,
 		ReplicaId: op.pbft.id,
 	}
-	// XXX sign req
+	op.signRequest(req)
 	return req
 }
 
+func (op *obcBatch) reconfigurationToReq(replicas []uint64) *Request {
+	req := op.txToReq(nil)
+	req.Reconfiguration = &Reconfiguration{Replicas: replicas}
+	op.signRequest(req)
+	return req
+}
+
+func (op *obcBatch) signRequest(req *Request) {
+	if err := op.pbft.sign(req); err != nil {
+		logger.Errorf("Replica %d could not sign request: %s", op.pbft.id, err)
+	}
+}
+
 func (op *obcBatch) processMessage(ocMsg *pb.Message, senderHandle *pb.PeerID) events.Event {
 	if ocMsg.Type == pb.Message_CHAIN_TRANSACTION {
 		req := op.txToReq(ocMsg.Payload)
@@ -264,14 +325,28 @@ func (op *obcBatch) processMessage(ocMsg *pb.Message, senderHandle *pb.PeerID) e
 		return nil
 	}
 
+	senderID, err := getValidatorID(senderHandle) // who sent this?
+	if err != nil {
+		logger.Warningf("Replica %d received a message from %v which is not a replica: %s", op.pbft.id, senderHandle, err)
+		return nil
+	}
+	if err = op.verify(senderID, ocMsg.Signature, ocMsg.Payload); err != nil {
+		logger.Warningf("Replica %d rejecting message from replica %d with an invalid signature: %s", op.pbft.id, senderID, err)
+		return nil
+	}
+
 	batchMsg := &BatchMessage{}
-	err := proto.Unmarshal(ocMsg.Payload, batchMsg)
+	err = proto.Unmarshal(ocMsg.Payload, batchMsg)
 	if err != nil {
 		logger.Errorf("Error unmarshaling message: %s", err)
 		return nil
 	}
 
 	if req := batchMsg.GetRequest(); req != nil {
+		if err = op.pbft.verify(req); err != nil {
+			logger.Warningf("Replica %d rejecting request from replica %d with an invalid signature: %s", op.pbft.id, req.ReplicaId, err)
+			return nil
+		}
 		if !op.deduplicator.IsNew(req) {
 			logger.Warningf("Replica %d ignoring request as it is too old", op.pbft.id)
 			return nil
@@ -285,16 +360,16 @@ func (op *obcBatch) processMessage(ocMsg *pb.Message, senderHandle *pb.PeerID) e
 		op.startTimerIfOutstandingRequests()
 		return nil
 	} else if pbftMsg := batchMsg.GetPbftMessage(); pbftMsg != nil {
-		senderID, err := getValidatorID(senderHandle) // who sent this?
-		if err != nil {
-			panic("Cannot map sender's PeerID to a valid replica ID")
-		}
 		msg := &Message{}
 		err = proto.Unmarshal(pbftMsg, msg)
 		if err != nil {
 			logger.Errorf("Error unpacking payload from message: %s", err)
 			return nil
 		}
+		if err = op.verifyRequests(msg); err != nil {
+			logger.Warningf("Replica %d rejecting message from replica %d: %s", op.pbft.id, senderID, err)
+			return nil
+		}
 		return pbftMessageEvent{
 			msg:    msg,
 			sender: senderID,
@@ -360,6 +435,11 @@ func (op *obcBatch) ProcessEvent(event events.Event) events.Event {
 			return res
 		}
 		return op.resubmitOutstandingReqs()
+	case statusEvent:
+		et.result <- op.status()
+	case reconfigurationEvent:
+		logger.Infof("Replica %d submitting reconfiguration to replica set %v", op.pbft.id, et.replicas)
+		return op.submitToLeader(op.reconfigurationToReq(et.replicas))
 	case batchTimerEvent:
 		logger.Infof("Replica %d batch timer expired", op.pbft.id)
 		if op.pbft.activeView && (len(op.batchStore) > 0) {
@@ -436,15 +516,11 @@ func (op *obcBatch) stopBatchTimer() {
 }
 
 // Wraps a payload into a batch message, packs it and wraps it into
-// a Fabric message. Called by broadcast before transmission.
+// a signed Fabric message. Called by broadcast before transmission.
 func (op *obcBatch) wrapMessage(msgPayload []byte) *pb.Message {
 	batchMsg := &BatchMessage{Payload: &BatchMessage_PbftMessage{PbftMessage: msgPayload}}
 	packedBatchMsg, _ := proto.Marshal(batchMsg)
-	ocMsg := &pb.Message{
-		Type:    pb.Message_CONSENSUS,
-		Payload: packedBatchMsg,
-	}
-	return ocMsg
+	return op.newConsensusMessage(packedBatchMsg)
 }
 
 // Retrieve the idle channel, only used for testing
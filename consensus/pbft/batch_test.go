@@ -17,6 +17,7 @@ limitations under the License.
 package pbft
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -98,8 +99,11 @@ func TestClearOustandingReqsOnStateRecovery(t *testing.T) {
 func TestOutstandingReqsIngestion(t *testing.T) {
 	bs := [3]*obcBatch{}
 	for i := range bs {
+		sec := &authSecurity{&pb.PeerID{Name: fmt.Sprintf("vp%d", i)}}
 		omni := &omniProto{
 			UnicastImpl: func(ocMsg *pb.Message, peer *pb.PeerID) error { return nil },
+			SignImpl:    sec.Sign,
+			VerifyImpl:  sec.Verify,
 		}
 		bs[i] = newObcBatch(uint64(i), loadConfig(), omni)
 		defer bs[i].Close()
@@ -137,7 +141,11 @@ func TestOutstandingReqsIngestion(t *testing.T) {
 }
 
 func TestOutstandingReqsResubmission(t *testing.T) {
-	omni := &omniProto{}
+	sec := &authSecurity{&pb.PeerID{Name: "vp0"}}
+	omni := &omniProto{
+		SignImpl:   sec.Sign,
+		VerifyImpl: sec.Verify,
+	}
 	config := loadConfig()
 	config.Set("general.batchsize", 2)
 	b := newObcBatch(0, config, omni)
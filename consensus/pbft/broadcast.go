@@ -44,7 +44,7 @@ type sendRequest struct {
 	done chan bool
 }
 
-func newBroadcaster(self uint64, N int, f int, c communicator) *broadcaster {
+func newBroadcaster(self uint64, replicas []uint64, f int, c communicator) *broadcaster {
 	queueSize := 10 // XXX increase after testing
 
 	chans := make(map[uint64]chan *sendRequest)
@@ -54,16 +54,16 @@ func newBroadcaster(self uint64, N int, f int, c communicator) *broadcaster {
 		msgChans: chans,
 		closedCh: make(chan struct{}),
 	}
-	for i := 0; i < N; i++ {
-		if uint64(i) == self {
+	for _, replica := range replicas {
+		if replica == self {
 			continue
 		}
-		chans[uint64(i)] = make(chan *sendRequest, queueSize)
+		chans[replica] = make(chan *sendRequest, queueSize)
 	}
 
 	// We do not start the go routines in the above loop to avoid concurrent map read/writes
-	for i := 0; i < N; i++ {
-		go b.drainer(uint64(i))
+	for _, replica := range replicas {
+		go b.drainer(replica)
 	}
 
 	return b
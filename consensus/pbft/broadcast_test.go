@@ -69,7 +69,7 @@ func TestBroadcast(t *testing.T) {
 		}
 	}()
 
-	b := newBroadcaster(1, 4, 1, m)
+	b := newBroadcaster(1, newReplicaSet(4), 1, m)
 
 	msg := &pb.Message{Payload: []byte("hi")}
 	b.Broadcast(msg)
@@ -123,7 +123,7 @@ func TestBroadcastStuck(t *testing.T) {
 		}
 	}()
 
-	b := newBroadcaster(1, 4, 1, m)
+	b := newBroadcaster(1, newReplicaSet(4), 1, m)
 
 	maxc := 20
 	for c := 0; c < maxc; c++ {
@@ -168,7 +168,7 @@ func TestBroadcastUnicast(t *testing.T) {
 		}
 	}()
 
-	b := newBroadcaster(1, 4, 1, m)
+	b := newBroadcaster(1, newReplicaSet(4), 1, m)
 
 	msg := &pb.Message{Payload: []byte("hi")}
 	b.Unicast(msg, 0)
@@ -206,7 +206,7 @@ func TestBroadcastAllFail(t *testing.T) {
 		done: make(chan struct{}),
 	}
 
-	b := newBroadcaster(1, 4, 1, m)
+	b := newBroadcaster(1, newReplicaSet(4), 1, m)
 
 	maxc := 20
 	for c := 0; c < maxc; c++ {
@@ -250,7 +250,7 @@ func TestBroadcastIndefinitelyStuck(t *testing.T) {
 		done: make(chan struct{}),
 	}
 
-	b := newBroadcaster(1, 4, 1, m)
+	b := newBroadcaster(1, newReplicaSet(4), 1, m)
 
 	broadcastDone := make(chan struct{})
 
@@ -0,0 +1,164 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// signedBatchMessage packs a batch message signed by the given replica
+func signedBatchMessage(signer uint64, batchMsg *BatchMessage) (payload []byte, signature []byte) {
+	payload, _ = proto.Marshal(batchMsg)
+	handle, _ := getValidatorHandle(signer)
+	signature, _ = (&authSecurity{handle}).Sign(payload)
+	return
+}
+
+// signedRequest returns a request originating from the given replica, signed by signer
+func signedRequest(tag int64, origin uint64, signer uint64) *Request {
+	req := createPbftReq(tag, origin)
+	raw, _ := proto.Marshal(req)
+	handle, _ := getValidatorHandle(signer)
+	req.Signature, _ = (&authSecurity{handle}).Sign(raw)
+	return req
+}
+
+// committedPayloads lists the payloads of the transactions on the replica's chain
+func committedPayloads(ml *MockLedger) map[string]bool {
+	payloads := make(map[string]bool)
+	for i := uint64(1); i < ml.GetBlockchainSize(); i++ {
+		block, err := ml.GetBlock(i)
+		if err != nil {
+			continue
+		}
+		for _, tx := range block.Transactions {
+			payloads[string(tx.Payload)] = true
+		}
+	}
+	return payloads
+}
+
+func TestByzantineImpersonationRejected(t *testing.T) {
+	validatorCount := 4
+	net := makeConsumerNetwork(validatorCount, obcBatchSizeOneHelper)
+	defer net.stop()
+
+	// Replica 3 poses as primary 0, sending a pre-prepare for a batch of its choosing
+	forgedBatch := &RequestBatch{Batch: []*Request{signedRequest(666, 3, 3)}}
+	forgedDigest := hash(forgedBatch)
+	prePrepare, _ := proto.Marshal(&Message{Payload: &Message_PrePrepare{PrePrepare: &PrePrepare{
+		View:           0,
+		SequenceNumber: 2,
+		BatchDigest:    forgedDigest,
+		RequestBatch:   forgedBatch,
+		ReplicaId:      0,
+	}}})
+	forgedPayload, forgedSignature := signedBatchMessage(3, &BatchMessage{Payload: &BatchMessage_PbftMessage{PbftMessage: prePrepare}})
+	net.forgeFn = func(tm taggedMsg) []taggedMsg {
+		if tm.src != 3 {
+			return []taggedMsg{tm}
+		}
+		return []taggedMsg{tm, {src: 0, dst: tm.dst, msg: forgedPayload, signature: forgedSignature}}
+	}
+
+	net.endpoints[1].(*consumerEndpoint).consumer.RecvMsg(createTxMsg(1), net.endpoints[1].getHandle())
+	net.process()
+
+	for _, ep := range net.endpoints {
+		ce := ep.(*consumerEndpoint)
+		for idx, cert := range ce.consumer.getPBFTCore().certStore {
+			if cert.prePrepare != nil && cert.prePrepare.BatchDigest == forgedDigest {
+				t.Errorf("Replica %d accepted the forged pre-prepare for view=%d/seqNo=%d", ce.id, idx.v, idx.n)
+			}
+		}
+		if payloads := committedPayloads(net.mockLedgers[ce.id]); !payloads["1"] || payloads["666"] {
+			t.Errorf("Replica %d expected to have committed only the genuine request, has %v", ce.id, payloads)
+		}
+	}
+}
+
+func TestByzantineTamperedMessageRejected(t *testing.T) {
+	validatorCount := 4
+	net := makeConsumerNetwork(validatorCount, obcBatchSizeOneHelper)
+	defer net.stop()
+
+	// Prepares from replica 2 are altered in transit, keeping replica 2's signature
+	net.forgeFn = func(tm taggedMsg) []taggedMsg {
+		if tm.src != 2 {
+			return []taggedMsg{tm}
+		}
+		batchMsg := &BatchMessage{}
+		proto.Unmarshal(tm.msg, batchMsg)
+		msg := &Message{}
+		proto.Unmarshal(batchMsg.GetPbftMessage(), msg)
+		if prep := msg.GetPrepare(); prep != nil {
+			prep.BatchDigest = "forged"
+			raw, _ := proto.Marshal(msg)
+			tm.msg, _ = proto.Marshal(&BatchMessage{Payload: &BatchMessage_PbftMessage{PbftMessage: raw}})
+		}
+		return []taggedMsg{tm}
+	}
+
+	net.endpoints[1].(*consumerEndpoint).consumer.RecvMsg(createTxMsg(1), net.endpoints[1].getHandle())
+	net.process()
+
+	for _, ep := range net.endpoints {
+		ce := ep.(*consumerEndpoint)
+		for idx, cert := range ce.consumer.getPBFTCore().certStore {
+			for _, prep := range cert.prepare {
+				if prep.BatchDigest == "forged" {
+					t.Errorf("Replica %d accepted a tampered prepare from replica %d for view=%d/seqNo=%d", ce.id, prep.ReplicaId, idx.v, idx.n)
+				}
+			}
+		}
+		if payloads := committedPayloads(net.mockLedgers[ce.id]); !payloads["1"] {
+			t.Errorf("Replica %d expected to have committed the request despite the tampered prepares, has %v", ce.id, payloads)
+		}
+	}
+}
+
+func TestByzantineForgedRequestRejected(t *testing.T) {
+	validatorCount := 4
+	net := makeConsumerNetwork(validatorCount, obcBatchSizeOneHelper)
+	defer net.stop()
+
+	// Replica 3 relays a genuine request of replica 1, and one it claims replica 1 sent
+	forgedReq := signedRequest(666, 1, 3)
+	for _, req := range []*Request{signedRequest(1, 1, 1), forgedReq} {
+		payload, signature := signedBatchMessage(3, &BatchMessage{Payload: &BatchMessage_Request{Request: req}})
+		for _, ep := range net.endpoints[:3] {
+			ep.deliver(payload, signature, net.endpoints[3].getHandle())
+		}
+	}
+	net.process()
+
+	for _, ep := range net.endpoints {
+		ce := ep.(*consumerEndpoint)
+		payloads := committedPayloads(net.mockLedgers[ce.id])
+		if payloads["666"] {
+			t.Errorf("Replica %d committed the forged request", ce.id)
+		}
+		if ce.id != 3 && !payloads["1"] {
+			t.Errorf("Replica %d expected to have committed the relayed genuine request, has %v", ce.id, payloads)
+		}
+		if ce.consumer.(*obcBatch).reqStore.outstandingRequests.has(hash(forgedReq)) {
+			t.Errorf("Replica %d kept the forged request outstanding", ce.id)
+		}
+	}
+}
@@ -78,6 +78,20 @@ func (eer *externalEventReceiver) RolledBack(tag interface{}) {
 	eer.manager.Queue() <- rolledBackEvent{}
 }
 
+// Reconfigure orders the replacement of the replica set by the given validators,
+// the new replica set takes effect at the checkpoint following the reconfiguration
+func (eer *externalEventReceiver) Reconfigure(validators []*pb.PeerID) error {
+	replicas, err := getValidatorIDs(validators)
+	if err != nil {
+		return err
+	}
+	if err = checkReplicaSet(replicas); err != nil {
+		return err
+	}
+	eer.manager.Queue() <- reconfigurationEvent{replicas}
+	return nil
+}
+
 // StateUpdated is a signal from the stack that it has fast-forwarded its state
 func (eer *externalEventReceiver) StateUpdated(tag interface{}, target *pb.BlockchainInfo) {
 	eer.manager.Queue() <- stateUpdatedEvent{
@@ -11,6 +11,7 @@ It is generated from these files:
 It has these top-level messages:
 	Message
 	Request
+	Reconfiguration
 	PrePrepare
 	Prepare
 	Commit
@@ -23,6 +24,7 @@ It has these top-level messages:
 	RequestBatch
 	BatchMessage
 	Metadata
+	ReconfigurationVote
 */
 package pbft
 
@@ -318,10 +320,11 @@ func _Message_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer
 }
 
 type Request struct {
-	Timestamp *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=timestamp" json:"timestamp,omitempty"`
-	Payload   []byte                     `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
-	ReplicaId uint64                     `protobuf:"varint,3,opt,name=replica_id" json:"replica_id,omitempty"`
-	Signature []byte                     `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	Timestamp       *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=timestamp" json:"timestamp,omitempty"`
+	Payload         []byte                     `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	ReplicaId       uint64                     `protobuf:"varint,3,opt,name=replica_id" json:"replica_id,omitempty"`
+	Signature       []byte                     `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	Reconfiguration *Reconfiguration           `protobuf:"bytes,5,opt,name=reconfiguration" json:"reconfiguration,omitempty"`
 }
 
 func (m *Request) Reset()         { *m = Request{} }
@@ -335,6 +338,24 @@ func (m *Request) GetTimestamp() *google_protobuf.Timestamp {
 	return nil
 }
 
+func (m *Request) GetReconfiguration() *Reconfiguration {
+	if m != nil {
+		return m.Reconfiguration
+	}
+	return nil
+}
+
+// reconfiguration replaces the replica set; it is ordered like any other
+// request and takes effect once the checkpoint at sequence_number is executed
+type Reconfiguration struct {
+	Replicas       []uint64 `protobuf:"varint,1,rep,name=replicas" json:"replicas,omitempty"`
+	SequenceNumber uint64   `protobuf:"varint,2,opt,name=sequence_number" json:"sequence_number,omitempty"`
+}
+
+func (m *Reconfiguration) Reset()         { *m = Reconfiguration{} }
+func (m *Reconfiguration) String() string { return proto.CompactTextString(m) }
+func (*Reconfiguration) ProtoMessage()    {}
+
 type PrePrepare struct {
 	View           uint64        `protobuf:"varint,1,opt,name=view" json:"view,omitempty"`
 	SequenceNumber uint64        `protobuf:"varint,2,opt,name=sequence_number" json:"sequence_number,omitempty"`
@@ -663,9 +684,37 @@ func _BatchMessage_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.B
 }
 
 type Metadata struct {
-	SeqNo uint64 `protobuf:"varint,1,opt,name=seqNo" json:"seqNo,omitempty"`
+	SeqNo                uint64                 `protobuf:"varint,1,opt,name=seqNo" json:"seqNo,omitempty"`
+	Reconfiguration      *Reconfiguration       `protobuf:"bytes,2,opt,name=reconfiguration" json:"reconfiguration,omitempty"`
+	ReconfigurationVotes []*ReconfigurationVote `protobuf:"bytes,3,rep,name=reconfiguration_votes" json:"reconfiguration_votes,omitempty"`
 }
 
 func (m *Metadata) Reset()         { *m = Metadata{} }
 func (m *Metadata) String() string { return proto.CompactTextString(m) }
 func (*Metadata) ProtoMessage()    {}
+
+func (m *Metadata) GetReconfiguration() *Reconfiguration {
+	if m != nil {
+		return m.Reconfiguration
+	}
+	return nil
+}
+
+func (m *Metadata) GetReconfigurationVotes() []*ReconfigurationVote {
+	if m != nil {
+		return m.ReconfigurationVotes
+	}
+	return nil
+}
+
+// reconfiguration_vote is the replica set requested by a replica, a
+// reconfiguration is only ordered once a quorum of the replica set
+// requested the same replica set
+type ReconfigurationVote struct {
+	ReplicaId uint64   `protobuf:"varint,1,opt,name=replica_id" json:"replica_id,omitempty"`
+	Replicas  []uint64 `protobuf:"varint,2,rep,name=replicas" json:"replicas,omitempty"`
+}
+
+func (m *ReconfigurationVote) Reset()         { *m = ReconfigurationVote{} }
+func (m *ReconfigurationVote) String() string { return proto.CompactTextString(m) }
+func (*ReconfigurationVote) ProtoMessage()    {}
@@ -62,13 +62,13 @@ func (ce *consumerEndpoint) isBusy() bool {
 	return false
 }
 
-func (ce *consumerEndpoint) deliver(msg []byte, senderHandle *pb.PeerID) {
-	ce.consumer.RecvMsg(&pb.Message{Type: pb.Message_CONSENSUS, Payload: msg}, senderHandle)
+func (ce *consumerEndpoint) deliver(msg []byte, signature []byte, senderHandle *pb.PeerID) {
+	ce.consumer.RecvMsg(&pb.Message{Type: pb.Message_CONSENSUS, Payload: msg, Signature: signature}, senderHandle)
 }
 
 type completeStack struct {
 	*consumerEndpoint
-	*noopSecurity
+	*authSecurity
 	*MockLedger
 	mockPersist
 	skipTarget chan struct{}
@@ -134,7 +134,7 @@ func makeConsumerNetwork(N int, makeConsumer func(id uint64, config *viper.Viper
 
 		cs := &completeStack{
 			consumerEndpoint: ce,
-			noopSecurity:     &noopSecurity{},
+			authSecurity:     &authSecurity{tep.getHandle()},
 			MockLedger:       ml,
 			skipTarget:       make(chan struct{}, 1),
 		}
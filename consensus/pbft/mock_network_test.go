@@ -26,16 +26,17 @@ import (
 
 type endpoint interface {
 	stop()
-	deliver([]byte, *pb.PeerID)
+	deliver([]byte, []byte, *pb.PeerID)
 	getHandle() *pb.PeerID
 	getID() uint64
 	isBusy() bool
 }
 
 type taggedMsg struct {
-	src int
-	dst int
-	msg []byte
+	src       int
+	dst       int
+	msg       []byte
+	signature []byte
 }
 
 type testnet struct {
@@ -45,6 +46,10 @@ type testnet struct {
 	endpoints []endpoint
 	msgs      chan taggedMsg
 	filterFn  func(int, int, []byte) []byte
+
+	// forgeFn makes the network byzantine: every message sent is replaced
+	// by the messages it returns, which may claim to come from any replica
+	forgeFn func(taggedMsg) []taggedMsg
 }
 
 type testEndpoint struct {
@@ -105,7 +110,7 @@ func (ep *testEndpoint) GetNetworkHandles() (self *pb.PeerID, network []*pb.Peer
 // this behavior, because it exposes subtle bugs in the
 // implementation.
 func (ep *testEndpoint) Broadcast(msg *pb.Message, peerType pb.PeerEndpoint_Type) error {
-	ep.net.broadcastFilter(ep, msg.Payload)
+	ep.net.broadcastFilter(ep, msg.Payload, msg.Signature)
 	return nil
 }
 
@@ -114,10 +119,22 @@ func (ep *testEndpoint) Unicast(msg *pb.Message, receiverHandle *pb.PeerID) erro
 	if err != nil {
 		return fmt.Errorf("Couldn't unicast message to %s: %v", receiverHandle.Name, err)
 	}
-	internalQueueMessage(ep.net.msgs, taggedMsg{int(ep.id), int(receiverID), msg.Payload})
+	ep.net.queueMessage(taggedMsg{int(ep.id), int(receiverID), msg.Payload, msg.Signature})
 	return nil
 }
 
+// queueMessage queues a message for delivery, or the messages forged from it
+func (net *testnet) queueMessage(tm taggedMsg) {
+	if net.forgeFn == nil {
+		internalQueueMessage(net.msgs, tm)
+		return
+	}
+	for _, forged := range net.forgeFn(tm) {
+		net.debugMsg("TEST: queueing forged message from %d to %d\n", forged.src, forged.dst)
+		internalQueueMessage(net.msgs, forged)
+	}
+}
+
 func internalQueueMessage(queue chan<- taggedMsg, tm taggedMsg) {
 	select {
 	case queue <- tm:
@@ -133,7 +150,7 @@ func (net *testnet) debugMsg(msg string, args ...interface{}) {
 	}
 }
 
-func (net *testnet) broadcastFilter(ep *testEndpoint, payload []byte) {
+func (net *testnet) broadcastFilter(ep *testEndpoint, payload []byte, signature []byte) {
 	select {
 	case <-net.closed:
 		fmt.Println("WARNING! Attempted to send a request to a closed network, ignoring")
@@ -146,7 +163,7 @@ func (net *testnet) broadcastFilter(ep *testEndpoint, payload []byte) {
 	}
 	if payload != nil {
 		net.debugMsg("TEST: attempting to queue message %p\n", payload)
-		internalQueueMessage(net.msgs, taggedMsg{int(ep.id), -1, payload})
+		net.queueMessage(taggedMsg{int(ep.id), -1, payload, signature})
 		net.debugMsg("TEST: message queued successfully %p\n", payload)
 	} else {
 		net.debugMsg("TEST: suppressing message with payload %p\n", payload)
@@ -181,7 +198,7 @@ func (net *testnet) deliverFilter(msg taggedMsg) {
 				net.debugMsg("TEST: Delivering %d\n", lid)
 				if payload != nil {
 					net.debugMsg("TEST: Sending message %d\n", lid)
-					lep.deliver(payload, senderHandle)
+					lep.deliver(payload, msg.signature, senderHandle)
 					net.debugMsg("TEST: Sent message %d\n", lid)
 				} else {
 					net.debugMsg("TEST: Message to %d was skipped\n", lid)
@@ -197,7 +214,7 @@ func (net *testnet) deliverFilter(msg taggedMsg) {
 		}
 		if payload != nil {
 			net.debugMsg("TEST: Sending unicast\n")
-			net.endpoints[msg.dst].deliver(msg.msg, senderHandle)
+			net.endpoints[msg.dst].deliver(payload, msg.signature, senderHandle)
 		}
 	}
 }
@@ -17,6 +17,8 @@ limitations under the License.
 package pbft
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"math/rand"
 	"time"
@@ -44,16 +46,30 @@ func (it *inertTimerFactory) CreateTimer() events.Timer {
 	return &inertTimer{}
 }
 
-type noopSecurity struct{}
+// authSecurity signs with a digest keyed by the signer's handle, so a
+// replica cannot produce a signature which verifies for another replica
+type authSecurity struct {
+	handle *pb.PeerID
+}
 
-func (ns *noopSecurity) Sign(msg []byte) ([]byte, error) {
-	return nil, nil
+func (as *authSecurity) Sign(msg []byte) ([]byte, error) {
+	return authSignature(as.handle, msg), nil
 }
 
-func (ns *noopSecurity) Verify(peerID *pb.PeerID, signature []byte, message []byte) error {
+func (as *authSecurity) Verify(peerID *pb.PeerID, signature []byte, message []byte) error {
+	if !bytes.Equal(signature, authSignature(peerID, message)) {
+		return fmt.Errorf("Signature does not match the message from %s", peerID.Name)
+	}
 	return nil
 }
 
+func authSignature(handle *pb.PeerID, msg []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(handle.Name))
+	h.Write(msg)
+	return h.Sum(nil)
+}
+
 type mockPersist struct {
 	store map[string][]byte
 }
@@ -211,6 +227,9 @@ type omniProto struct {
 	validateStateImpl   func()
 	invalidateStateImpl func()
 
+	getLastReconfigurationImpl func() (*Reconfiguration, []*ReconfigurationVote, error)
+	reconfigureImpl            func(replicas []uint64)
+
 	// Closable Consenter methods
 	RecvMsgImpl func(ocMsg *pb.Message, senderHandle *pb.PeerID) error
 	CloseImpl   func()
@@ -481,6 +500,22 @@ func (op *omniProto) getLastSeqNo() (uint64, error) {
 	return 0, fmt.Errorf("getLastSeqNo is not implemented")
 }
 
+func (op *omniProto) getLastReconfiguration() (*Reconfiguration, []*ReconfigurationVote, error) {
+	if op.getLastReconfigurationImpl != nil {
+		return op.getLastReconfigurationImpl()
+	}
+
+	return nil, nil, fmt.Errorf("getLastReconfiguration is not implemented")
+}
+
+func (op *omniProto) reconfigure(replicas []uint64) {
+	if nil != op.reconfigureImpl {
+		op.reconfigureImpl(replicas)
+		return
+	}
+
+	panic("Unimplemented")
+}
 func (op *omniProto) Close() {
 	if nil != op.CloseImpl {
 		op.CloseImpl()
@@ -508,7 +543,7 @@ func (op *omniProto) SkipTo(seqNo uint64, meta []byte, id []*pb.PeerID) {
 	panic("Unimplemented")
 }
 
-func (op *omniProto) deliver(msg []byte, target *pb.PeerID) {
+func (op *omniProto) deliver(msg []byte, signature []byte, target *pb.PeerID) {
 	if nil != op.deliverImpl {
 		op.deliverImpl(msg, target)
 	}
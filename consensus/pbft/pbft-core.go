@@ -86,7 +86,9 @@ type innerStack interface {
 	execute(seqNo uint64, reqBatch *RequestBatch) // This is invoked on a separate thread
 	getState() []byte
 	getLastSeqNo() (uint64, error)
+	getLastReconfiguration() (*Reconfiguration, []*ReconfigurationVote, error)
 	skipTo(seqNo uint64, snapshotID []byte, peers []uint64)
+	reconfigure(replicas []uint64)
 
 	sign(msg []byte) ([]byte, error)
 	verify(senderID uint64, signature []byte, message []byte) error
@@ -135,6 +137,7 @@ type pbftCore struct {
 	L             uint64            // log size
 	lastExec      uint64            // last request we executed
 	replicaCount  int               // number of replicas; PBFT `|R|`
+	replicas      []uint64          // sorted replica IDs; PBFT `R`
 	seqNo         uint64            // PBFT "n", strictly monotonic increasing sequence number
 	view          uint64            // current view
 	chkpts        map[uint64]string // state checkpoints; map lastExec to global hash
@@ -162,8 +165,14 @@ type pbftCore struct {
 	viewChangePeriod   uint64        // period between automatic view changes
 	viewChangeSeqNo    uint64        // next seqNo to perform view change
 
+	reconfiguration      *Reconfiguration    // last reconfiguration ordered, takes effect after its checkpoint
+	reconfigurationView  uint64              // view in which the last reconfiguration was ordered
+	reconfigurationVotes map[uint64][]uint64 // replica set requested by each replica, until a quorum agrees
+
 	missingReqBatches map[string]bool // for all the assigned, non-checkpointed request batches we might be missing during view-change
 
+	metrics *pbftMetrics // counters and latencies, for introspection
+
 	// implementation of PBFT `in`
 	reqBatchStore   map[string]*RequestBatch // track request batches
 	certStore       map[msgID]*msgCert       // track quorum certificates for requests
@@ -258,6 +267,7 @@ func newPbftCore(id uint64, config *viper.Viper, consumer innerStack, etf events
 
 	instance.activeView = true
 	instance.replicaCount = instance.N
+	instance.replicas = newReplicaSet(instance.N)
 
 	logger.Infof("PBFT type = %T", instance.consumer)
 	logger.Infof("PBFT Max number of validating peers (N) = %v", instance.N)
@@ -291,12 +301,14 @@ func newPbftCore(id uint64, config *viper.Viper, consumer innerStack, etf events
 
 	// initialize state transfer
 	instance.hChkpts = make(map[uint64]uint64)
+	instance.reconfigurationVotes = make(map[uint64][]uint64)
 
 	instance.chkpts[0] = "XXX GENESIS"
 
 	instance.lastNewViewTimeout = instance.newViewTimeout
 	instance.outstandingReqBatches = make(map[string]*RequestBatch)
 	instance.missingReqBatches = make(map[string]bool)
+	instance.metrics = newPbftMetrics()
 
 	instance.restoreState()
 
@@ -330,6 +342,7 @@ func (instance *pbftCore) ProcessEvent(e events.Event) events.Event {
 		if err != nil {
 			break
 		}
+		instance.metrics.lastSeen[msg.sender] = time.Now()
 		return next
 	case *RequestBatch:
 		err = instance.recvRequestBatch(et)
@@ -370,8 +383,10 @@ func (instance *pbftCore) ProcessEvent(e events.Event) events.Event {
 			return nil
 		}
 		logger.Infof("Replica %d application caught up via state transfer, lastExec now %d", instance.id, update.seqNo)
+		instance.metrics.stateTransfers++
 		// XXX create checkpoint
 		instance.lastExec = update.seqNo
+		instance.restoreReconfiguration()
 		instance.moveWatermarks(instance.lastExec) // The watermark movement handles moving this to a checkpoint boundary
 		instance.skipInProgress = false
 		instance.consumer.validateState()
@@ -420,7 +435,7 @@ func (instance *pbftCore) ProcessEvent(e events.Event) events.Event {
 
 // Given a certain view n, what is the expected primary?
 func (instance *pbftCore) primary(n uint64) uint64 {
-	return n % uint64(instance.replicaCount)
+	return instance.replicas[n%uint64(len(instance.replicas))]
 }
 
 // Is the sequence number between watermarks?
@@ -558,6 +573,10 @@ func (instance *pbftCore) nullRequestHandler() {
 }
 
 func (instance *pbftCore) recvMsg(msg *Message, senderID uint64) (interface{}, error) {
+	if !instance.isReplica(senderID) {
+		return nil, fmt.Errorf("Sender %d is not part of the replica set %v", senderID, instance.replicas)
+	}
+
 	if reqBatch := msg.GetRequestBatch(); reqBatch != nil {
 		return reqBatch, nil
 	} else if preprep := msg.GetPrePrepare(); preprep != nil {
@@ -644,6 +663,11 @@ func (instance *pbftCore) sendPrePrepare(reqBatch *RequestBatch, digest string)
 		return
 	}
 
+	if instance.reconfigurationPending() && n > instance.reconfiguration.SequenceNumber {
+		logger.Infof("Primary %d about to switch to a new replica set, not sending pre-prepare with seqno=%d", instance.id, n)
+		return
+	}
+
 	logger.Debugf("Primary %d broadcasting pre-prepare for view=%d/seqNo=%d and digest %s", instance.id, instance.view, n, digest)
 	instance.seqNo = n
 	preprep := &PrePrepare{
@@ -656,6 +680,7 @@ func (instance *pbftCore) sendPrePrepare(reqBatch *RequestBatch, digest string)
 	cert := instance.getCert(instance.view, n)
 	cert.prePrepare = preprep
 	cert.digest = digest
+	instance.metrics.recordOrdered(n)
 	instance.persistQSet()
 	instance.innerBroadcast(&Message{Payload: &Message_PrePrepare{PrePrepare: preprep}})
 	instance.maybeSendCommit(digest, instance.view, n)
@@ -731,6 +756,7 @@ func (instance *pbftCore) recvPrePrepare(preprep *PrePrepare) error {
 
 	cert.prePrepare = preprep
 	cert.digest = preprep.BatchDigest
+	instance.metrics.recordOrdered(preprep.SequenceNumber)
 
 	// Store the request batch if, for whatever reason, we haven't received it from an earlier broadcast
 	if _, ok := instance.reqBatchStore[preprep.BatchDigest]; !ok && preprep.BatchDigest != "" {
@@ -949,10 +975,13 @@ func (instance *pbftCore) executeOne(idx msgID) bool {
 	if digest == "" {
 		logger.Infof("Replica %d executing/committing null request for view=%d/seqNo=%d",
 			instance.id, idx.v, idx.n)
+		instance.metrics.nullRequests++
+		delete(instance.metrics.ordered, idx.n)
 		instance.execDoneSync()
 	} else {
 		logger.Infof("Replica %d executing/committing request batch for view=%d/seqNo=%d and digest %s",
 			instance.id, idx.v, idx.n, digest)
+		instance.metrics.batches++
 		// synchronously execute, it is the other side's responsibility to execute in the background if needed
 		instance.consumer.execute(idx.n, reqBatch)
 	}
@@ -986,8 +1015,10 @@ func (instance *pbftCore) execDoneSync() {
 	if instance.currentExec != nil {
 		logger.Infof("Replica %d finished execution %d, trying next", instance.id, *instance.currentExec)
 		instance.lastExec = *instance.currentExec
+		instance.metrics.recordExecuted(instance.lastExec)
 		if instance.lastExec%instance.K == 0 {
 			instance.Checkpoint(instance.lastExec, instance.consumer.getState())
+			instance.reconfigureAtCheckpoint()
 		}
 
 	} else {
@@ -1042,6 +1073,7 @@ func (instance *pbftCore) moveWatermarks(n uint64) {
 	}
 
 	instance.h = h
+	instance.metrics.moveWatermarks(h)
 
 	logger.Debugf("Replica %d updated low watermark to %d",
 		instance.id, instance.h)
@@ -1278,12 +1310,12 @@ func (instance *pbftCore) innerBroadcast(msg *Message) error {
 	// testing byzantine fault.
 	if doByzantine {
 		rand2 := rand.New(rand.NewSource(time.Now().UnixNano()))
-		ignoreidx := rand2.Intn(instance.N)
-		for i := 0; i < instance.N; i++ {
-			if i != ignoreidx && uint64(i) != instance.id { //Pick a random replica and do not send message
-				instance.consumer.unicast(msgRaw, uint64(i))
+		ignoreidx := rand2.Intn(len(instance.replicas))
+		for i, replica := range instance.replicas {
+			if i != ignoreidx && replica != instance.id { //Pick a random replica and do not send message
+				instance.consumer.unicast(msgRaw, replica)
 			} else {
-				logger.Debugf("PBFT byzantine: not broadcasting to replica %v", i)
+				logger.Debugf("PBFT byzantine: not broadcasting to replica %v", replica)
 			}
 		}
 	} else {
@@ -33,7 +33,7 @@ type pbftEndpoint struct {
 	manager events.Manager
 }
 
-func (pe *pbftEndpoint) deliver(msgPayload []byte, senderHandle *pb.PeerID) {
+func (pe *pbftEndpoint) deliver(msgPayload []byte, signature []byte, senderHandle *pb.PeerID) {
 	senderID, _ := getValidatorID(senderHandle)
 	msg := &Message{}
 	err := proto.Unmarshal(msgPayload, msg)
@@ -148,6 +148,13 @@ func (sc *simpleConsumer) getLastSeqNo() (uint64, error) {
 	return sc.lastSeqNo, nil
 }
 
+func (sc *simpleConsumer) getLastReconfiguration() (*Reconfiguration, []*ReconfigurationVote, error) {
+	return nil, nil, nil
+}
+
+func (sc *simpleConsumer) reconfigure(replicas []uint64) {
+}
+
 func makePBFTNetwork(N int, config *viper.Viper) *pbftNetwork {
 	if config == nil {
 		config = loadConfig()
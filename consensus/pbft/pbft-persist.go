@@ -161,6 +161,7 @@ func (instance *pbftCore) restoreState() {
 	}
 
 	instance.restoreLastSeqNo()
+	instance.restoreReconfiguration()
 
 	logger.Infof("Replica %d restored state: view: %d, seqNo: %d, pset: %d, qset: %d, reqBatches: %d, chkpts: %d",
 		instance.id, instance.view, instance.seqNo, len(instance.pset), len(instance.qset), len(instance.reqBatchStore), len(instance.chkpts))
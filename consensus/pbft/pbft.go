@@ -121,6 +121,17 @@ func getValidatorHandles(ids []uint64) (handles []*pb.PeerID) {
 	return
 }
 
+// Returns the replica ids corresponding to a list of peer handles
+func getValidatorIDs(handles []*pb.PeerID) (ids []uint64, err error) {
+	ids = make([]uint64, len(handles))
+	for i, handle := range handles {
+		if ids[i], err = getValidatorID(handle); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
 type obcGeneric struct {
 	stack consensus.Stack
 	pbft  *pbftCore
@@ -157,3 +168,13 @@ func (op *obcGeneric) getLastSeqNo() (uint64, error) {
 	proto.Unmarshal(raw, meta)
 	return meta.SeqNo, nil
 }
+
+func (op *obcGeneric) getLastReconfiguration() (*Reconfiguration, []*ReconfigurationVote, error) {
+	raw, err := op.stack.GetBlockHeadMetadata()
+	if err != nil {
+		return nil, nil, err
+	}
+	meta := &Metadata{}
+	proto.Unmarshal(raw, meta)
+	return meta.Reconfiguration, meta.ReconfigurationVotes, nil
+}
@@ -0,0 +1,229 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"fmt"
+	"sort"
+)
+
+// --------------------------------------------------------------
+//
+// A reconfiguration replaces the replica set of the network. It is
+// submitted as a request and ordered like any other, so every replica
+// executes it at the same sequence number. A single replica cannot
+// change the replica set: each reconfiguration request is a vote,
+// signed by the replica which submitted it, and the reconfiguration is
+// only ordered once a quorum (2f+1) of the current replica set voted
+// for the same replica set. It takes effect once the
+// checkpoint closing that checkpoint interval has been executed:
+// N and f are recomputed from the new replica set and a view change
+// lets the new replica set elect its primary.
+//
+// The last reconfiguration ordered and the pending votes are recorded
+// in the metadata of every block, so a replica which restarts, or which
+// catches up through state transfer, recovers them from the ledger.
+//
+// --------------------------------------------------------------
+
+// reconfigurationEvent is sent when the replica set should be changed
+type reconfigurationEvent struct {
+	replicas []uint64
+}
+
+// newReplicaSet returns the replica set of a network of N statically
+// configured replicas, vp0 to vpN-1
+func newReplicaSet(N int) []uint64 {
+	replicas := make([]uint64, N)
+	for i := range replicas {
+		replicas[i] = uint64(i)
+	}
+	return replicas
+}
+
+// maxFaults returns the number of byzantine faults tolerated by N replicas
+func maxFaults(N int) int {
+	return (N - 1) / 3
+}
+
+// checkReplicaSet sorts the replica set and ensures it is usable
+func checkReplicaSet(replicas []uint64) error {
+	if len(replicas) == 0 {
+		return fmt.Errorf("The replica set must not be empty")
+	}
+	sort.Sort(sortableUint64Slice(replicas))
+	for i := 1; i < len(replicas); i++ {
+		if replicas[i] == replicas[i-1] {
+			return fmt.Errorf("Replica %d appears more than once in the replica set", replicas[i])
+		}
+	}
+	return nil
+}
+
+// Is the replica part of the current replica set?
+func (instance *pbftCore) isReplica(id uint64) bool {
+	i := sort.Search(len(instance.replicas), func(i int) bool { return instance.replicas[i] >= id })
+	return i < len(instance.replicas) && instance.replicas[i] == id
+}
+
+// Has a reconfiguration been ordered which did not take effect yet?
+func (instance *pbftCore) reconfigurationPending() bool {
+	return instance.reconfiguration != nil && instance.lastExec < instance.reconfiguration.SequenceNumber
+}
+
+// voteReconfiguration is invoked by the consumer when it executes a
+// reconfiguration request of the given replica in the given sequence
+// number, the reconfiguration is ordered once a quorum voted for it
+func (instance *pbftCore) voteReconfiguration(seqNo uint64, replicaID uint64, replicas []uint64) {
+	if !instance.isReplica(replicaID) {
+		logger.Warningf("Replica %d ignoring reconfiguration requested by replica %d which is not part of the replica set", instance.id, replicaID)
+		return
+	}
+	replicas = append([]uint64(nil), replicas...) // the request is kept in the log, do not sort it in place
+	if err := checkReplicaSet(replicas); err != nil {
+		logger.Warningf("Replica %d ignoring reconfiguration requested by replica %d in seqNo %d: %s", instance.id, replicaID, seqNo, err)
+		return
+	}
+
+	// A replica only has one vote, a later request replaces the earlier one
+	instance.reconfigurationVotes[replicaID] = replicas
+	votes := 0
+	for id, vote := range instance.reconfigurationVotes {
+		if instance.isReplica(id) && equalReplicaSets(vote, replicas) {
+			votes++
+		}
+	}
+	if votes < instance.intersectionQuorum() {
+		logger.Infof("Replica %d recorded vote of replica %d for replica set %v in seqNo %d, %d of %d votes",
+			instance.id, replicaID, replicas, seqNo, votes, instance.intersectionQuorum())
+		return
+	}
+
+	instance.reconfigurationVotes = make(map[uint64][]uint64)
+	instance.orderReconfiguration(seqNo, replicas)
+}
+
+// getReconfigurationVotes returns the pending votes, sorted by replica,
+// for them to be recorded in the metadata of the block
+func (instance *pbftCore) getReconfigurationVotes() []*ReconfigurationVote {
+	var ids []uint64
+	for id := range instance.reconfigurationVotes {
+		ids = append(ids, id)
+	}
+	sort.Sort(sortableUint64Slice(ids))
+	votes := make([]*ReconfigurationVote, len(ids))
+	for i, id := range ids {
+		votes[i] = &ReconfigurationVote{ReplicaId: id, Replicas: instance.reconfigurationVotes[id]}
+	}
+	return votes
+}
+
+// orderReconfiguration orders the replacement of the replica set in the
+// given sequence number
+func (instance *pbftCore) orderReconfiguration(seqNo uint64, replicas []uint64) {
+	// Round up to the checkpoint closing the interval the request was ordered in
+	chkptSeqNo := (seqNo + instance.K - 1) / instance.K * instance.K
+	logger.Infof("Replica %d ordered reconfiguration in seqNo %d, replica set will be %v after checkpoint %d",
+		instance.id, seqNo, replicas, chkptSeqNo)
+	instance.reconfiguration = &Reconfiguration{
+		Replicas:       replicas,
+		SequenceNumber: chkptSeqNo,
+	}
+	instance.reconfigurationView = instance.view
+}
+
+// applyReconfiguration switches to the replica set of the last
+// reconfiguration ordered
+func (instance *pbftCore) applyReconfiguration() {
+	replicas := instance.reconfiguration.Replicas
+
+	instance.replicas = replicas
+	instance.N = len(replicas)
+	instance.f = maxFaults(instance.N)
+	instance.replicaCount = instance.N
+
+	for id := range instance.hChkpts {
+		if !instance.isReplica(id) {
+			delete(instance.hChkpts, id)
+		}
+	}
+	for id := range instance.reconfigurationVotes {
+		if !instance.isReplica(id) {
+			delete(instance.reconfigurationVotes, id)
+		}
+	}
+
+	logger.Infof("Replica %d switched to replica set %v at seqNo %d, N = %d, f = %d",
+		instance.id, replicas, instance.reconfiguration.SequenceNumber, instance.N, instance.f)
+	if !instance.isReplica(instance.id) {
+		logger.Warningf("Replica %d is no longer part of the replica set", instance.id)
+	}
+
+	instance.consumer.reconfigure(replicas)
+}
+
+// reconfigureAtCheckpoint applies the pending reconfiguration once its
+// checkpoint has been executed
+func (instance *pbftCore) reconfigureAtCheckpoint() {
+	if instance.reconfiguration == nil || instance.reconfiguration.SequenceNumber != instance.lastExec {
+		return
+	}
+
+	instance.applyReconfiguration()
+
+	// A replica which moved to a new view since the reconfiguration was
+	// ordered already takes part in the view change of the new replica set
+	if instance.isReplica(instance.id) && instance.activeView && instance.view == instance.reconfigurationView {
+		logger.Infof("Replica %d changing view for the new replica set", instance.id)
+		instance.sendViewChange()
+	}
+}
+
+// restoreReconfiguration recovers the last reconfiguration ordered and
+// the pending votes from the ledger, and applies the reconfiguration if
+// it already took effect
+func (instance *pbftCore) restoreReconfiguration() {
+	reconfiguration, votes, err := instance.consumer.getLastReconfiguration()
+	if err != nil {
+		logger.Debugf("Replica %d could not restore reconfiguration: %s", instance.id, err)
+		return
+	}
+	instance.reconfigurationVotes = make(map[uint64][]uint64)
+	for _, vote := range votes {
+		instance.reconfigurationVotes[vote.ReplicaId] = vote.Replicas
+	}
+	if reconfiguration == nil {
+		return
+	}
+
+	instance.reconfiguration = reconfiguration
+	if instance.lastExec >= reconfiguration.SequenceNumber && !equalReplicaSets(instance.replicas, reconfiguration.Replicas) {
+		instance.applyReconfiguration()
+	}
+}
+
+func equalReplicaSets(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,220 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestReconfigurationAtCheckpoint(t *testing.T) {
+	var reconfigured []uint64
+	viewChangeSent := false
+	mock := &omniProto{
+		broadcastImpl: func(msgPayload []byte) {
+			msg := &Message{}
+			proto.Unmarshal(msgPayload, msg)
+			if msg.GetViewChange() != nil {
+				viewChangeSent = true
+			}
+		},
+		reconfigureImpl: func(replicas []uint64) {
+			reconfigured = replicas
+		},
+		signImpl:   func(msg []byte) ([]byte, error) { return msg, nil },
+		verifyImpl: func(senderID uint64, signature []byte, message []byte) error { return nil },
+	}
+	instance := newPbftCore(1, loadConfig(), mock, &inertTimerFactory{})
+	defer instance.close()
+	instance.K = 10
+
+	// Retire replica 0, which is the primary of view 0, this needs the
+	// votes of 3 replicas
+	instance.voteReconfiguration(1, 0, []uint64{3, 2, 1})
+	instance.voteReconfiguration(2, 7, []uint64{3, 2, 1})
+	instance.voteReconfiguration(2, 2, []uint64{0, 1, 2})
+	instance.voteReconfiguration(2, 1, []uint64{3, 2, 1})
+	if instance.reconfigurationPending() {
+		t.Fatalf("Expected reconfiguration not to be ordered without a quorum of votes")
+	}
+	if votes := instance.getReconfigurationVotes(); len(votes) != 3 {
+		t.Fatalf("Expected 3 votes to be recorded, got %v", votes)
+	}
+	instance.voteReconfiguration(3, 2, []uint64{3, 2, 1})
+	if !instance.reconfigurationPending() || instance.reconfiguration.SequenceNumber != 10 {
+		t.Fatalf("Expected reconfiguration to be pending until checkpoint 10, got %+v", instance.reconfiguration)
+	}
+	if votes := instance.getReconfigurationVotes(); len(votes) != 0 {
+		t.Fatalf("Expected votes to be cleared once the reconfiguration is ordered, got %v", votes)
+	}
+
+	instance.lastExec = 9
+	instance.reconfigureAtCheckpoint()
+	if instance.N != 4 || reconfigured != nil {
+		t.Fatalf("Replica set should not change before the checkpoint is executed")
+	}
+
+	instance.lastExec = 10
+	instance.reconfigureAtCheckpoint()
+	if instance.N != 3 || instance.f != 0 {
+		t.Errorf("Expected N=3 and f=0 after reconfiguration, got N=%d and f=%d", instance.N, instance.f)
+	}
+	if !reflect.DeepEqual(reconfigured, []uint64{1, 2, 3}) {
+		t.Errorf("Expected consumer to be reconfigured to replicas [1 2 3], got %v", reconfigured)
+	}
+	if !viewChangeSent || instance.view != 1 {
+		t.Errorf("Expected a view change to view 1 for the new replica set, in view %d", instance.view)
+	}
+	if p := instance.primary(instance.view); p != 2 {
+		t.Errorf("Expected replica 2 to be the primary of view 1, got %d", p)
+	}
+
+	_, err := instance.recvMsg(&Message{Payload: &Message_Checkpoint{Checkpoint: &Checkpoint{ReplicaId: 0}}}, 0)
+	if err == nil {
+		t.Errorf("Expected message from retired replica 0 to be rejected")
+	}
+}
+
+func TestReconfigurationRestored(t *testing.T) {
+	var reconfigured []uint64
+	mock := &omniProto{
+		getLastSeqNoImpl: func() (uint64, error) {
+			return 12, nil
+		},
+		getLastReconfigurationImpl: func() (*Reconfiguration, []*ReconfigurationVote, error) {
+			votes := []*ReconfigurationVote{{ReplicaId: 4, Replicas: []uint64{1, 2, 3, 4}}}
+			return &Reconfiguration{Replicas: []uint64{0, 1, 2, 3, 4}, SequenceNumber: 10}, votes, nil
+		},
+		reconfigureImpl: func(replicas []uint64) {
+			reconfigured = replicas
+		},
+	}
+	instance := newPbftCore(1, loadConfig(), mock, &inertTimerFactory{})
+	defer instance.close()
+
+	if instance.N != 5 || instance.f != 1 || !instance.isReplica(4) {
+		t.Errorf("Expected the replica set to be restored from the ledger, got %v", instance.replicas)
+	}
+	if instance.reconfigurationPending() || reconfigured == nil {
+		t.Errorf("Expected the restored reconfiguration to have taken effect")
+	}
+	if !reflect.DeepEqual(instance.reconfigurationVotes[4], []uint64{1, 2, 3, 4}) {
+		t.Errorf("Expected the pending vote of replica 4 to be restored, got %v", instance.reconfigurationVotes)
+	}
+}
+
+func TestNetworkReconfigurationAddReplica(t *testing.T) {
+	validatorCount := 5
+	net := makeConsumerNetwork(validatorCount, obcBatchSizeOneHelper, func(ce *consumerEndpoint) {
+		// vp4 is not part of the replica set the network starts with
+		ce.consumer.(*obcBatch).pbft.N = 4
+		ce.consumer.(*obcBatch).pbft.f = 1
+		ce.consumer.(*obcBatch).pbft.K = 2
+		ce.consumer.(*obcBatch).pbft.L = 4
+	})
+	defer net.stop()
+
+	handles := make([]*pb.PeerID, validatorCount)
+	for i := range handles {
+		handles[i], _ = getValidatorHandle(uint64(i))
+	}
+	// A quorum of the 4 replicas has to request the reconfiguration
+	for i := 0; i < 3; i++ {
+		err := net.endpoints[i].(*consumerEndpoint).consumer.(*obcBatch).Reconfigure(handles)
+		if err != nil {
+			t.Fatalf("Reconfiguration was not accepted: %s", err)
+		}
+		net.process()
+	}
+
+	// The reconfiguration takes effect at checkpoint 2, then the network moves
+	// on far enough for vp4 to catch up through state transfer
+	broadcaster := net.endpoints[generateBroadcaster(4)].getHandle()
+	for n := 1; n <= 9; n++ {
+		net.endpoints[1].(*consumerEndpoint).consumer.RecvMsg(createTxMsg(int64(n)), broadcaster)
+		net.process()
+	}
+
+	for _, ep := range net.endpoints {
+		ce := ep.(*consumerEndpoint)
+		obc := ce.consumer.(*obcBatch)
+		if obc.pbft.N != 5 || obc.pbft.f != 1 || !reflect.DeepEqual(obc.pbft.replicas, []uint64{0, 1, 2, 3, 4}) {
+			t.Errorf("Replica %d expected to have switched to 5 replicas, has N=%d, f=%d and replicas %v", ce.id, obc.pbft.N, obc.pbft.f, obc.pbft.replicas)
+		}
+		if ce.id == 4 {
+			continue
+		}
+		if !obc.pbft.activeView || obc.pbft.view != 1 {
+			t.Errorf("Replica %d expected to be active in view 1, is %v %d", ce.id, obc.pbft.activeView, obc.pbft.view)
+		}
+	}
+
+	// The network makes progress with vp4 as a replica
+	size := net.mockLedgers[0].GetBlockchainSize()
+	net.endpoints[1].(*consumerEndpoint).consumer.RecvMsg(createTxMsg(10), broadcaster)
+	net.process()
+	for i, ml := range net.mockLedgers {
+		if ml.GetBlockchainSize() != size+1 {
+			t.Errorf("Replica %d expected to have %d blocks, has %d", i, size+1, ml.GetBlockchainSize())
+		}
+	}
+}
+
+func TestNetworkReconfigurationNeedsQuorum(t *testing.T) {
+	validatorCount := 5
+	net := makeConsumerNetwork(validatorCount, obcBatchSizeOneHelper, func(ce *consumerEndpoint) {
+		ce.consumer.(*obcBatch).pbft.N = 4
+		ce.consumer.(*obcBatch).pbft.f = 1
+		ce.consumer.(*obcBatch).pbft.K = 2
+		ce.consumer.(*obcBatch).pbft.L = 4
+	})
+	defer net.stop()
+
+	handles := make([]*pb.PeerID, validatorCount)
+	for i := range handles {
+		handles[i], _ = getValidatorHandle(uint64(i))
+	}
+	// Two replicas, and vp4 which is not a replica, are not a quorum
+	for _, i := range []int{0, 1, 4} {
+		err := net.endpoints[i].(*consumerEndpoint).consumer.(*obcBatch).Reconfigure(handles)
+		if err != nil {
+			t.Fatalf("Reconfiguration was not accepted: %s", err)
+		}
+		net.process()
+	}
+
+	broadcaster := net.endpoints[generateBroadcaster(4)].getHandle()
+	for n := 1; n <= 4; n++ {
+		net.endpoints[1].(*consumerEndpoint).consumer.RecvMsg(createTxMsg(int64(n)), broadcaster)
+		net.process()
+	}
+
+	for _, ep := range net.endpoints[:4] {
+		ce := ep.(*consumerEndpoint)
+		obc := ce.consumer.(*obcBatch)
+		if obc.pbft.N != 4 || obc.pbft.reconfiguration != nil {
+			t.Errorf("Replica %d expected to keep 4 replicas without a quorum of votes, has N=%d and reconfiguration %v", ce.id, obc.pbft.N, obc.pbft.reconfiguration)
+		}
+		if len(obc.pbft.reconfigurationVotes) != 2 {
+			t.Errorf("Replica %d expected to have recorded the votes of replicas 0 and 1, has %v", ce.id, obc.pbft.reconfigurationVotes)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"google/protobuf"
+
+	"github.com/hyperledger/fabric/consensus/util"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// statusTimeout bounds the wait for the main thread to report the status
+const statusTimeout = 5 * time.Second
+
+// statusEvent is sent to retrieve the status of the replica from the main thread
+type statusEvent struct {
+	result chan *pb.ConsensusStatus
+}
+
+// pbftMetrics counts the work done by a replica, it is only accessed from the
+// main thread
+type pbftMetrics struct {
+	viewChanges    uint64
+	batches        uint64
+	nullRequests   uint64
+	requests       uint64
+	stateTransfers uint64
+	batchLatency   *util.Histogram
+	requestLatency *util.Histogram
+
+	ordered  map[uint64]time.Time // when each sequence number was pre-prepared
+	lastSeen map[uint64]time.Time // when a message was last received from each replica
+}
+
+func newPbftMetrics() *pbftMetrics {
+	return &pbftMetrics{
+		batchLatency:   util.NewHistogram(util.LatencyBounds),
+		requestLatency: util.NewHistogram(util.LatencyBounds),
+		ordered:        make(map[uint64]time.Time),
+		lastSeen:       make(map[uint64]time.Time),
+	}
+}
+
+// recordOrdered records when the batch with the sequence number was first pre-prepared
+func (metrics *pbftMetrics) recordOrdered(n uint64) {
+	if _, ok := metrics.ordered[n]; !ok {
+		metrics.ordered[n] = time.Now()
+	}
+}
+
+// recordExecuted observes the latency of the batch with the sequence number
+func (metrics *pbftMetrics) recordExecuted(n uint64) {
+	if ordered, ok := metrics.ordered[n]; ok {
+		metrics.batchLatency.Observe(time.Since(ordered))
+		delete(metrics.ordered, n)
+	}
+}
+
+// recordRequest observes the latency of the request, from its creation
+func (metrics *pbftMetrics) recordRequest(req *Request) {
+	metrics.requests++
+	if req.Timestamp != nil {
+		created := time.Unix(req.Timestamp.Seconds, int64(req.Timestamp.Nanos))
+		metrics.requestLatency.Observe(time.Since(created))
+	}
+}
+
+// moveWatermarks forgets the sequence numbers at or below the low watermark
+func (metrics *pbftMetrics) moveWatermarks(h uint64) {
+	for n := range metrics.ordered {
+		if n <= h {
+			delete(metrics.ordered, n)
+		}
+	}
+}
+
+func (metrics *pbftMetrics) toProto() *pb.ConsensusMetrics {
+	return &pb.ConsensusMetrics{
+		ViewChanges:    metrics.viewChanges,
+		Batches:        metrics.batches,
+		NullRequests:   metrics.nullRequests,
+		Requests:       metrics.requests,
+		StateTransfers: metrics.stateTransfers,
+		BatchLatency:   metrics.batchLatency.ToProto(),
+		RequestLatency: metrics.requestLatency.ToProto(),
+	}
+}
+
+// GetConsensusStatus returns the state and metrics of the replica, as
+// reported by the main thread
+func (eer *externalEventReceiver) GetConsensusStatus() (*pb.ConsensusStatus, error) {
+	event := statusEvent{make(chan *pb.ConsensusStatus, 1)}
+	timeout := time.After(statusTimeout)
+	select {
+	case eer.manager.Queue() <- event:
+	case <-timeout:
+		return nil, fmt.Errorf("Timed out queueing the consensus status request")
+	}
+	select {
+	case status := <-event.result:
+		return status, nil
+	case <-timeout:
+		return nil, fmt.Errorf("Timed out waiting for the consensus status")
+	}
+}
+
+// status returns the state and metrics of the replica
+func (instance *pbftCore) status() *pb.ConsensusStatus {
+	status := &pb.ConsensusStatus{
+		Plugin:                  "pbft",
+		ReplicaId:               instance.id,
+		F:                       uint32(instance.f),
+		View:                    instance.view,
+		ViewChangeInProgress:    !instance.activeView,
+		LowWatermark:            instance.h,
+		HighWatermark:           instance.h + instance.L,
+		LastExec:                instance.lastExec,
+		StateTransferInProgress: instance.skipInProgress || instance.stateTransferring,
+		Metrics:                 instance.metrics.toProto(),
+	}
+
+	var seqNos []uint64
+	for n := range instance.chkpts {
+		seqNos = append(seqNos, n)
+	}
+	sort.Sort(sortableUint64Slice(seqNos))
+	for _, n := range seqNos {
+		chkpt := &pb.ConsensusCheckpoint{SequenceNumber: n, Id: instance.chkpts[n], Stable: n == instance.h}
+		for testChkpt := range instance.checkpointStore {
+			if testChkpt.SequenceNumber == n && testChkpt.Id == chkpt.Id {
+				chkpt.Votes++
+			}
+		}
+		status.Checkpoints = append(status.Checkpoints, chkpt)
+	}
+
+	lastCheckpoints := make(map[uint64]uint64)
+	for chkpt := range instance.checkpointStore {
+		if chkpt.SequenceNumber > lastCheckpoints[chkpt.ReplicaId] {
+			lastCheckpoints[chkpt.ReplicaId] = chkpt.SequenceNumber
+		}
+	}
+	for _, id := range instance.replicas {
+		if id == instance.id {
+			continue
+		}
+		replica := &pb.ReplicaStatus{ReplicaId: id, LastCheckpoint: lastCheckpoints[id]}
+		if lastSeen, ok := instance.metrics.lastSeen[id]; ok {
+			replica.LastSeen = &google_protobuf.Timestamp{Seconds: lastSeen.Unix(), Nanos: int32(lastSeen.Nanosecond())}
+		}
+		status.Replicas = append(status.Replicas, replica)
+	}
+
+	return status
+}
+
+// status returns the state and metrics of the replica, including its requests
+func (op *obcBatch) status() *pb.ConsensusStatus {
+	status := op.pbft.status()
+	status.OutstandingRequests = uint64(op.reqStore.outstandingRequests.Len())
+	status.PendingRequests = uint64(op.reqStore.pendingRequests.Len())
+	return status
+}
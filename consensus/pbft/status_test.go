@@ -0,0 +1,68 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"testing"
+)
+
+func TestConsensusStatus(t *testing.T) {
+	validatorCount := 4
+	net := makeConsumerNetwork(validatorCount, obcBatchSizeOneHelper)
+	defer net.stop()
+
+	broadcaster := net.endpoints[generateBroadcaster(validatorCount)].getHandle()
+	net.endpoints[1].(*consumerEndpoint).consumer.RecvMsg(createTxMsg(1), broadcaster)
+	net.process()
+
+	status, err := net.endpoints[1].(*consumerEndpoint).consumer.(*obcBatch).GetConsensusStatus()
+	if err != nil {
+		t.Fatalf("Could not retrieve the consensus status: %s", err)
+	}
+
+	if status.Plugin != "pbft" || status.ReplicaId != 1 || status.View != 0 || status.ViewChangeInProgress {
+		t.Errorf("Expected replica 1 to report an active view 0, got %v", status)
+	}
+	if status.F != 1 {
+		t.Errorf("Expected %d replicas to tolerate 1 fault, got %d", validatorCount, status.F)
+	}
+	if status.LastExec != 1 || status.LowWatermark != 0 || status.HighWatermark == 0 {
+		t.Errorf("Expected replica 1 to have executed seqNo 1 within its watermarks, got %v", status)
+	}
+	if status.OutstandingRequests != 0 || status.PendingRequests != 0 {
+		t.Errorf("Expected no request left after execution, got %d outstanding and %d pending", status.OutstandingRequests, status.PendingRequests)
+	}
+	if len(status.Checkpoints) != 1 || !status.Checkpoints[0].Stable {
+		t.Errorf("Expected the genesis checkpoint to be the only, stable, checkpoint, got %v", status.Checkpoints)
+	}
+	if len(status.Replicas) != validatorCount-1 {
+		t.Fatalf("Expected the status of the %d other replicas, got %v", validatorCount-1, status.Replicas)
+	}
+	for _, replica := range status.Replicas {
+		if replica.LastSeen == nil {
+			t.Errorf("Expected replica %d to have been seen", replica.ReplicaId)
+		}
+	}
+
+	metrics := status.Metrics
+	if metrics.Batches != 1 || metrics.Requests != 1 || metrics.ViewChanges != 0 {
+		t.Errorf("Expected one batch with one request and no view change, got %v", metrics)
+	}
+	if metrics.BatchLatency.Count != 1 || metrics.RequestLatency.Count != 1 {
+		t.Errorf("Expected the latency of the batch and of the request to be observed, got %v and %v", metrics.BatchLatency, metrics.RequestLatency)
+	}
+}
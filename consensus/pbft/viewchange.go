@@ -517,6 +517,7 @@ func (instance *pbftCore) processNewView2(nv *NewView) events.Event {
 	instance.startTimerIfOutstandingRequests()
 
 	logger.Debugf("Replica %d done cleaning view change artifacts, calling into consumer", instance.id)
+	instance.metrics.viewChanges++
 
 	return viewChangedEvent{}
 }
@@ -0,0 +1,357 @@
+// Code generated by protoc-gen-go.
+// source: messages.proto
+// DO NOT EDIT!
+
+/*
+Package raft is a generated protocol buffer package.
+
+It is generated from these files:
+	messages.proto
+
+It has these top-level messages:
+	Message
+	Request
+	Entry
+	AppendEntries
+	AppendEntriesResponse
+	RequestVote
+	RequestVoteResponse
+	Snapshot
+	InstallSnapshot
+	HardState
+	Metadata
+*/
+package raft
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+type Message struct {
+	// Types that are valid to be assigned to Payload:
+	//	*Message_Request
+	//	*Message_AppendEntries
+	//	*Message_AppendEntriesResponse
+	//	*Message_RequestVote
+	//	*Message_RequestVoteResponse
+	//	*Message_InstallSnapshot
+	Payload isMessage_Payload `protobuf_oneof:"payload"`
+}
+
+func (m *Message) Reset()         { *m = Message{} }
+func (m *Message) String() string { return proto.CompactTextString(m) }
+func (*Message) ProtoMessage()    {}
+
+type isMessage_Payload interface{ isMessage_Payload() }
+
+type Message_Request struct {
+	Request *Request `protobuf:"bytes,1,opt,name=request,oneof"`
+}
+type Message_AppendEntries struct {
+	AppendEntries *AppendEntries `protobuf:"bytes,2,opt,name=append_entries,oneof"`
+}
+type Message_AppendEntriesResponse struct {
+	AppendEntriesResponse *AppendEntriesResponse `protobuf:"bytes,3,opt,name=append_entries_response,oneof"`
+}
+type Message_RequestVote struct {
+	RequestVote *RequestVote `protobuf:"bytes,4,opt,name=request_vote,oneof"`
+}
+type Message_RequestVoteResponse struct {
+	RequestVoteResponse *RequestVoteResponse `protobuf:"bytes,5,opt,name=request_vote_response,oneof"`
+}
+type Message_InstallSnapshot struct {
+	InstallSnapshot *InstallSnapshot `protobuf:"bytes,6,opt,name=install_snapshot,oneof"`
+}
+
+func (*Message_Request) isMessage_Payload()               {}
+func (*Message_AppendEntries) isMessage_Payload()         {}
+func (*Message_AppendEntriesResponse) isMessage_Payload() {}
+func (*Message_RequestVote) isMessage_Payload()           {}
+func (*Message_RequestVoteResponse) isMessage_Payload()   {}
+func (*Message_InstallSnapshot) isMessage_Payload()       {}
+
+func (m *Message) GetPayload() isMessage_Payload {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+func (m *Message) GetRequest() *Request {
+	if x, ok := m.GetPayload().(*Message_Request); ok {
+		return x.Request
+	}
+	return nil
+}
+
+func (m *Message) GetAppendEntries() *AppendEntries {
+	if x, ok := m.GetPayload().(*Message_AppendEntries); ok {
+		return x.AppendEntries
+	}
+	return nil
+}
+
+func (m *Message) GetAppendEntriesResponse() *AppendEntriesResponse {
+	if x, ok := m.GetPayload().(*Message_AppendEntriesResponse); ok {
+		return x.AppendEntriesResponse
+	}
+	return nil
+}
+
+func (m *Message) GetRequestVote() *RequestVote {
+	if x, ok := m.GetPayload().(*Message_RequestVote); ok {
+		return x.RequestVote
+	}
+	return nil
+}
+
+func (m *Message) GetRequestVoteResponse() *RequestVoteResponse {
+	if x, ok := m.GetPayload().(*Message_RequestVoteResponse); ok {
+		return x.RequestVoteResponse
+	}
+	return nil
+}
+
+func (m *Message) GetInstallSnapshot() *InstallSnapshot {
+	if x, ok := m.GetPayload().(*Message_InstallSnapshot); ok {
+		return x.InstallSnapshot
+	}
+	return nil
+}
+
+// XXX_OneofFuncs is for the internal use of the proto package.
+func (*Message) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), []interface{}) {
+	return _Message_OneofMarshaler, _Message_OneofUnmarshaler, []interface{}{
+		(*Message_Request)(nil),
+		(*Message_AppendEntries)(nil),
+		(*Message_AppendEntriesResponse)(nil),
+		(*Message_RequestVote)(nil),
+		(*Message_RequestVoteResponse)(nil),
+		(*Message_InstallSnapshot)(nil),
+	}
+}
+
+func _Message_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
+	m := msg.(*Message)
+	// payload
+	switch x := m.Payload.(type) {
+	case *Message_Request:
+		b.EncodeVarint(1<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Request); err != nil {
+			return err
+		}
+	case *Message_AppendEntries:
+		b.EncodeVarint(2<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.AppendEntries); err != nil {
+			return err
+		}
+	case *Message_AppendEntriesResponse:
+		b.EncodeVarint(3<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.AppendEntriesResponse); err != nil {
+			return err
+		}
+	case *Message_RequestVote:
+		b.EncodeVarint(4<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.RequestVote); err != nil {
+			return err
+		}
+	case *Message_RequestVoteResponse:
+		b.EncodeVarint(5<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.RequestVoteResponse); err != nil {
+			return err
+		}
+	case *Message_InstallSnapshot:
+		b.EncodeVarint(6<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.InstallSnapshot); err != nil {
+			return err
+		}
+	case nil:
+	default:
+		return fmt.Errorf("Message.Payload has unexpected type %T", x)
+	}
+	return nil
+}
+
+func _Message_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error) {
+	m := msg.(*Message)
+	switch tag {
+	case 1: // payload.request
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Request)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Message_Request{msg}
+		return true, err
+	case 2: // payload.append_entries
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(AppendEntries)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Message_AppendEntries{msg}
+		return true, err
+	case 3: // payload.append_entries_response
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(AppendEntriesResponse)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Message_AppendEntriesResponse{msg}
+		return true, err
+	case 4: // payload.request_vote
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(RequestVote)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Message_RequestVote{msg}
+		return true, err
+	case 5: // payload.request_vote_response
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(RequestVoteResponse)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Message_RequestVoteResponse{msg}
+		return true, err
+	case 6: // payload.install_snapshot
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(InstallSnapshot)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Message_InstallSnapshot{msg}
+		return true, err
+	default:
+		return false, nil
+	}
+}
+
+// A transaction forwarded to the leader
+type Request struct {
+	Payload   []byte `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`
+	ReplicaId uint64 `protobuf:"varint,2,opt,name=replica_id" json:"replica_id,omitempty"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+// One entry of the replicated log, each entry is committed as one block
+type Entry struct {
+	Term         uint64   `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
+	Index        uint64   `protobuf:"varint,2,opt,name=index" json:"index,omitempty"`
+	Transactions [][]byte `protobuf:"bytes,3,rep,name=transactions,proto3" json:"transactions,omitempty"`
+}
+
+func (m *Entry) Reset()         { *m = Entry{} }
+func (m *Entry) String() string { return proto.CompactTextString(m) }
+func (*Entry) ProtoMessage()    {}
+
+type AppendEntries struct {
+	Term         uint64   `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
+	LeaderId     uint64   `protobuf:"varint,2,opt,name=leader_id" json:"leader_id,omitempty"`
+	PrevLogIndex uint64   `protobuf:"varint,3,opt,name=prev_log_index" json:"prev_log_index,omitempty"`
+	PrevLogTerm  uint64   `protobuf:"varint,4,opt,name=prev_log_term" json:"prev_log_term,omitempty"`
+	Entries      []*Entry `protobuf:"bytes,5,rep,name=entries" json:"entries,omitempty"`
+	LeaderCommit uint64   `protobuf:"varint,6,opt,name=leader_commit" json:"leader_commit,omitempty"`
+}
+
+func (m *AppendEntries) Reset()         { *m = AppendEntries{} }
+func (m *AppendEntries) String() string { return proto.CompactTextString(m) }
+func (*AppendEntries) ProtoMessage()    {}
+
+func (m *AppendEntries) GetEntries() []*Entry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+type AppendEntriesResponse struct {
+	Term       uint64 `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
+	ReplicaId  uint64 `protobuf:"varint,2,opt,name=replica_id" json:"replica_id,omitempty"`
+	Success    bool   `protobuf:"varint,3,opt,name=success" json:"success,omitempty"`
+	MatchIndex uint64 `protobuf:"varint,4,opt,name=match_index" json:"match_index,omitempty"`
+}
+
+func (m *AppendEntriesResponse) Reset()         { *m = AppendEntriesResponse{} }
+func (m *AppendEntriesResponse) String() string { return proto.CompactTextString(m) }
+func (*AppendEntriesResponse) ProtoMessage()    {}
+
+type RequestVote struct {
+	Term         uint64 `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
+	CandidateId  uint64 `protobuf:"varint,2,opt,name=candidate_id" json:"candidate_id,omitempty"`
+	LastLogIndex uint64 `protobuf:"varint,3,opt,name=last_log_index" json:"last_log_index,omitempty"`
+	LastLogTerm  uint64 `protobuf:"varint,4,opt,name=last_log_term" json:"last_log_term,omitempty"`
+}
+
+func (m *RequestVote) Reset()         { *m = RequestVote{} }
+func (m *RequestVote) String() string { return proto.CompactTextString(m) }
+func (*RequestVote) ProtoMessage()    {}
+
+type RequestVoteResponse struct {
+	Term        uint64 `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
+	ReplicaId   uint64 `protobuf:"varint,2,opt,name=replica_id" json:"replica_id,omitempty"`
+	VoteGranted bool   `protobuf:"varint,3,opt,name=vote_granted" json:"vote_granted,omitempty"`
+}
+
+func (m *RequestVoteResponse) Reset()         { *m = RequestVoteResponse{} }
+func (m *RequestVoteResponse) String() string { return proto.CompactTextString(m) }
+func (*RequestVoteResponse) ProtoMessage()    {}
+
+// The state of the ledger once all entries up to and including index
+// have been applied, the log before it is discarded
+type Snapshot struct {
+	Index          uint64 `protobuf:"varint,1,opt,name=index" json:"index,omitempty"`
+	Term           uint64 `protobuf:"varint,2,opt,name=term" json:"term,omitempty"`
+	BlockchainInfo []byte `protobuf:"bytes,3,opt,name=blockchain_info,proto3" json:"blockchain_info,omitempty"`
+}
+
+func (m *Snapshot) Reset()         { *m = Snapshot{} }
+func (m *Snapshot) String() string { return proto.CompactTextString(m) }
+func (*Snapshot) ProtoMessage()    {}
+
+type InstallSnapshot struct {
+	Term     uint64    `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
+	LeaderId uint64    `protobuf:"varint,2,opt,name=leader_id" json:"leader_id,omitempty"`
+	Snapshot *Snapshot `protobuf:"bytes,3,opt,name=snapshot" json:"snapshot,omitempty"`
+}
+
+func (m *InstallSnapshot) Reset()         { *m = InstallSnapshot{} }
+func (m *InstallSnapshot) String() string { return proto.CompactTextString(m) }
+func (*InstallSnapshot) ProtoMessage()    {}
+
+func (m *InstallSnapshot) GetSnapshot() *Snapshot {
+	if m != nil {
+		return m.Snapshot
+	}
+	return nil
+}
+
+// Persisted so a restarted replica never votes twice in a term
+type HardState struct {
+	Term     uint64 `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
+	VotedFor uint64 `protobuf:"varint,2,opt,name=voted_for" json:"voted_for,omitempty"`
+	Voted    bool   `protobuf:"varint,3,opt,name=voted" json:"voted,omitempty"`
+}
+
+func (m *HardState) Reset()         { *m = HardState{} }
+func (m *HardState) String() string { return proto.CompactTextString(m) }
+func (*HardState) ProtoMessage()    {}
+
+// Stored in every block, the last log entry applied to the ledger
+type Metadata struct {
+	Index uint64 `protobuf:"varint,1,opt,name=index" json:"index,omitempty"`
+	Term  uint64 `protobuf:"varint,2,opt,name=term" json:"term,omitempty"`
+}
+
+func (m *Metadata) Reset()         { *m = Metadata{} }
+func (m *Metadata) String() string { return proto.CompactTextString(m) }
+func (*Metadata) ProtoMessage()    {}
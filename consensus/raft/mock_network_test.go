@@ -0,0 +1,232 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package raft
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/consensus"
+	"github.com/hyperledger/fabric/consensus/util/events"
+	pb "github.com/hyperledger/fabric/protos"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+)
+
+type inertTimer struct{}
+
+func (it *inertTimer) Halt()                                                {}
+func (it *inertTimer) Reset(duration time.Duration, event events.Event)     {}
+func (it *inertTimer) SoftReset(duration time.Duration, event events.Event) {}
+func (it *inertTimer) Stop()                                                {}
+
+type inertTimerFactory struct{}
+
+func (it *inertTimerFactory) CreateTimer() events.Timer {
+	return &inertTimer{}
+}
+
+// testReplica is the stack of one replica of the test network, the
+// executions, commits and state transfers it is asked to perform complete
+// synchronously and report back through the network
+type testReplica struct {
+	consensus.Stack // unused methods are left unimplemented
+
+	id        uint64
+	net       *testNetwork
+	raft      *raftCore
+	blocks    []*pb.Block
+	curBatch  []*pb.Transaction
+	state     map[string][]byte
+	connected bool
+}
+
+type testMessage struct {
+	from uint64
+	to   uint64
+	msg  *pb.Message
+}
+
+type testCallback struct {
+	id    uint64
+	event events.Event
+}
+
+// testNetwork delivers messages and callbacks one at a time, in order,
+// so every run of a test sees the same interleaving
+type testNetwork struct {
+	replicas  []*testReplica
+	messages  []testMessage
+	callbacks []testCallback
+}
+
+func makeTestNetwork(N int, initFNs ...func(config *viper.Viper)) *testNetwork {
+	net := &testNetwork{}
+	for id := 0; id < N; id++ {
+		r := &testReplica{
+			id:        uint64(id),
+			net:       net,
+			blocks:    []*pb.Block{{}},
+			state:     make(map[string][]byte),
+			connected: true,
+		}
+		net.replicas = append(net.replicas, r)
+	}
+	for _, r := range net.replicas {
+		r.raft = newRaftCore(r.id, makeTestConfig(N, initFNs...), r, &inertTimerFactory{})
+	}
+	return net
+}
+
+func makeTestConfig(N int, initFNs ...func(config *viper.Viper)) *viper.Viper {
+	config := loadConfig()
+	config.Set("general.N", N)
+	config.Set("general.batchsize", 1)
+	for _, fn := range initFNs {
+		fn(config)
+	}
+	return config
+}
+
+// restart replaces the replica's raft instance by one restored from its stack
+func (net *testNetwork) restart(id uint64) *raftCore {
+	r := net.replicas[id]
+	r.raft = newRaftCore(id, makeTestConfig(len(net.replicas)), r, &inertTimerFactory{})
+	return r.raft
+}
+
+func (net *testNetwork) send(id uint64, event events.Event) {
+	events.SendEvent(net.replicas[id].raft, event)
+}
+
+func (net *testNetwork) submit(id uint64, uuid string) {
+	raw, _ := proto.Marshal(&pb.Transaction{Uuid: uuid})
+	net.send(id, messageEvent{
+		msg:    &pb.Message{Type: pb.Message_CHAIN_TRANSACTION, Payload: raw},
+		sender: getValidatorHandle(id),
+	})
+}
+
+// process delivers messages and callbacks until the network is idle,
+// messages from and to disconnected replicas are dropped
+func (net *testNetwork) process() {
+	for len(net.messages) > 0 || len(net.callbacks) > 0 {
+		if len(net.callbacks) > 0 {
+			cb := net.callbacks[0]
+			net.callbacks = net.callbacks[1:]
+			net.send(cb.id, cb.event)
+			continue
+		}
+
+		m := net.messages[0]
+		net.messages = net.messages[1:]
+		if !net.replicas[m.from].connected || !net.replicas[m.to].connected {
+			continue
+		}
+		net.send(m.to, messageEvent{msg: m.msg, sender: getValidatorHandle(m.from)})
+	}
+}
+
+func (r *testReplica) Unicast(msg *pb.Message, receiverHandle *pb.PeerID) error {
+	to, err := getValidatorID(receiverHandle)
+	if err != nil {
+		return err
+	}
+	r.net.messages = append(r.net.messages, testMessage{r.id, to, msg})
+	return nil
+}
+
+func (r *testReplica) Execute(tag interface{}, txs []*pb.Transaction) {
+	r.curBatch = txs
+	r.net.callbacks = append(r.net.callbacks, testCallback{r.id, executedEvent{tag}})
+}
+
+func (r *testReplica) Commit(tag interface{}, metadata []byte) {
+	r.blocks = append(r.blocks, &pb.Block{Transactions: r.curBatch, ConsensusMetadata: metadata})
+	r.curBatch = nil
+	r.net.callbacks = append(r.net.callbacks, testCallback{r.id, committedEvent{tag, r.GetBlockchainInfo()}})
+}
+
+func (r *testReplica) UpdateState(tag interface{}, target *pb.BlockchainInfo, peers []*pb.PeerID) {
+	r.curBatch = nil
+	for _, peer := range peers {
+		id, _ := getValidatorID(peer)
+		src := r.net.replicas[id]
+		if !src.connected || uint64(len(src.blocks)) < target.Height {
+			continue
+		}
+		r.blocks = append([]*pb.Block(nil), src.blocks[:target.Height]...)
+		r.net.callbacks = append(r.net.callbacks, testCallback{r.id, stateUpdatedEvent{tag.(*Snapshot), r.GetBlockchainInfo()}})
+		return
+	}
+	r.net.callbacks = append(r.net.callbacks, testCallback{r.id, stateUpdatedEvent{tag.(*Snapshot), nil}})
+}
+
+func (r *testReplica) InvalidateState() {}
+func (r *testReplica) ValidateState()   {}
+
+func (r *testReplica) GetBlockchainInfo() *pb.BlockchainInfo {
+	hash, _ := r.blocks[len(r.blocks)-1].GetHash()
+	return &pb.BlockchainInfo{
+		Height:           uint64(len(r.blocks)),
+		CurrentBlockHash: hash,
+	}
+}
+
+func (r *testReplica) GetBlockHeadMetadata() ([]byte, error) {
+	return r.blocks[len(r.blocks)-1].ConsensusMetadata, nil
+}
+
+func (r *testReplica) StoreState(key string, value []byte) error {
+	r.state[key] = value
+	return nil
+}
+
+func (r *testReplica) ReadState(key string) ([]byte, error) {
+	val, ok := r.state[key]
+	if !ok {
+		return nil, fmt.Errorf("No state for key %s", key)
+	}
+	return val, nil
+}
+
+func (r *testReplica) ReadStateSet(prefix string) (map[string][]byte, error) {
+	set := make(map[string][]byte)
+	for key, val := range r.state {
+		if strings.HasPrefix(key, prefix) {
+			set[key] = val
+		}
+	}
+	return set, nil
+}
+
+func (r *testReplica) DelState(key string) {
+	delete(r.state, key)
+}
+
+// uuids lists the transactions committed to the replica's ledger
+func (r *testReplica) uuids() []string {
+	var uuids []string
+	for _, block := range r.blocks {
+		for _, tx := range block.Transactions {
+			uuids = append(uuids, tx.Uuid)
+		}
+	}
+	return uuids
+}
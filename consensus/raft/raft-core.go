@@ -0,0 +1,894 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package raft
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/hyperledger/fabric/consensus"
+	"github.com/hyperledger/fabric/consensus/util/events"
+	_ "github.com/hyperledger/fabric/core" // Needed for logging format init
+	pb "github.com/hyperledger/fabric/protos"
+	"github.com/op/go-logging"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+)
+
+// =============================================================================
+// init
+// =============================================================================
+
+var logger *logging.Logger // package-level logger
+
+func init() {
+	logger = logging.MustGetLogger("consensus/raft")
+}
+
+// =============================================================================
+// custom interfaces and structure definitions
+// =============================================================================
+
+// Event Types
+
+// electionTimerEvent is sent when a follower did not hear from the leader in time
+type electionTimerEvent struct{}
+
+// heartbeatTimerEvent is sent when the leader should contact its followers
+type heartbeatTimerEvent struct{}
+
+// batchTimerEvent is sent when the batch timer expires
+type batchTimerEvent struct{}
+
+type raftState int
+
+const (
+	follower raftState = iota
+	candidate
+	leader
+)
+
+func (s raftState) String() string {
+	switch s {
+	case follower:
+		return "follower"
+	case candidate:
+		return "candidate"
+	case leader:
+		return "leader"
+	}
+	return fmt.Sprintf("raftState(%d)", int(s))
+}
+
+type raftCore struct {
+	// internal data
+	id    uint64          // replica ID; vpX
+	stack consensus.Stack // the stack the log is executed against
+
+	// Raft parameters
+	N                int    // number of replicas, vp0 to vpN-1
+	batchSize        int    // number of transactions per log entry
+	snapshotInterval uint64 // number of applied entries between log compactions
+
+	// persisted state
+	state    raftState // role of this replica in the current term
+	term     uint64    // latest term this replica has seen
+	voted    bool      // whether this replica voted in the current term
+	votedFor uint64    // candidate this replica voted for in the current term
+	snapshot *Snapshot // everything up to and including snapshot.Index was applied and discarded from the log
+	log      []*Entry  // entries following the snapshot
+
+	// volatile state
+	leaderID       uint64             // leader of the current term
+	leaderKnown    bool               // whether the leader of the current term is known
+	votes          map[uint64]bool    // votes received as a candidate
+	commitIndex    uint64             // highest log entry known to be committed
+	lastApplied    uint64             // highest log entry applied to the ledger
+	appliedInfo    *pb.BlockchainInfo // state of the ledger once lastApplied was applied
+	currentExec    *uint64            // index of the entry being executed, if any
+	skipInProgress bool               // whether state transfer to a snapshot is in progress
+	pending        [][]byte           // transactions received while no leader is known
+
+	// leader state
+	nextIndex        map[uint64]uint64 // next log entry to send to each replica
+	matchIndex       map[uint64]uint64 // highest log entry known to be replicated on each replica
+	batchStore       [][]byte          // transactions for the next log entry
+	batchTimerActive bool
+
+	electionTimer    events.Timer
+	electionTimeout  time.Duration
+	heartbeatTimer   events.Timer
+	heartbeatTimeout time.Duration
+	batchTimer       events.Timer
+	batchTimeout     time.Duration
+}
+
+// =============================================================================
+// constructors
+// =============================================================================
+
+func newRaftCore(id uint64, config *viper.Viper, stack consensus.Stack, etf events.TimerFactory) *raftCore {
+	var err error
+	rc := &raftCore{}
+	rc.id = id
+	rc.stack = stack
+
+	rc.N = config.GetInt("general.N")
+	rc.batchSize = config.GetInt("general.batchsize")
+	rc.snapshotInterval = uint64(config.GetInt("general.snapshotinterval"))
+	if rc.id >= uint64(rc.N) {
+		panic(fmt.Errorf("Replica %d is not part of a network of %d replicas", rc.id, rc.N))
+	}
+
+	rc.batchTimeout, err = time.ParseDuration(config.GetString("general.timeout.batch"))
+	if err != nil {
+		panic(fmt.Errorf("Cannot parse batch timeout: %s", err))
+	}
+	rc.electionTimeout, err = time.ParseDuration(config.GetString("general.timeout.election"))
+	if err != nil {
+		panic(fmt.Errorf("Cannot parse election timeout: %s", err))
+	}
+	rc.heartbeatTimeout, err = time.ParseDuration(config.GetString("general.timeout.heartbeat"))
+	if err != nil {
+		panic(fmt.Errorf("Cannot parse heartbeat timeout: %s", err))
+	}
+	if rc.heartbeatTimeout >= rc.electionTimeout {
+		rc.heartbeatTimeout = rc.electionTimeout / 2
+		logger.Warningf("Configured heartbeat timeout must be less than election timeout, setting to %v", rc.heartbeatTimeout)
+	}
+
+	rc.electionTimer = etf.CreateTimer()
+	rc.heartbeatTimer = etf.CreateTimer()
+	rc.batchTimer = etf.CreateTimer()
+
+	logger.Infof("Raft type = %T", rc)
+	logger.Infof("Raft Max number of validating peers (N) = %v", rc.N)
+	logger.Infof("Raft Batch size = %d", rc.batchSize)
+	logger.Infof("Raft Snapshot interval = %d", rc.snapshotInterval)
+	logger.Infof("Raft election timeout = %v", rc.electionTimeout)
+	logger.Infof("Raft heartbeat timeout = %v", rc.heartbeatTimeout)
+
+	rc.snapshot = &Snapshot{}
+	rc.restoreState()
+
+	return rc
+}
+
+// start makes the replica wait for a leader
+func (rc *raftCore) start() {
+	rc.resetElectionTimer()
+}
+
+// close tears down resources opened by newRaftCore
+func (rc *raftCore) close() {
+	rc.electionTimer.Halt()
+	rc.heartbeatTimer.Halt()
+	rc.batchTimer.Halt()
+}
+
+// =============================================================================
+// helper functions for Raft
+// =============================================================================
+
+// the number of replicas which make a majority
+func (rc *raftCore) quorum() int {
+	return rc.N/2 + 1
+}
+
+func (rc *raftCore) lastIndex() uint64 {
+	return rc.snapshot.Index + uint64(len(rc.log))
+}
+
+func (rc *raftCore) lastTerm() uint64 {
+	term, _ := rc.termAt(rc.lastIndex())
+	return term
+}
+
+// termAt returns the term of the log entry at index, if it is still known
+func (rc *raftCore) termAt(index uint64) (uint64, bool) {
+	if index == rc.snapshot.Index {
+		return rc.snapshot.Term, true
+	}
+	if index < rc.snapshot.Index || index > rc.lastIndex() {
+		return 0, false
+	}
+	return rc.entry(index).Term, true
+}
+
+// entry returns the log entry at index, which must follow the snapshot
+func (rc *raftCore) entry(index uint64) *Entry {
+	return rc.log[index-rc.snapshot.Index-1]
+}
+
+// entriesFrom returns the log entries from index onwards
+func (rc *raftCore) entriesFrom(index uint64) []*Entry {
+	return rc.log[index-rc.snapshot.Index-1:]
+}
+
+// Is the candidate's log at least as up-to-date as ours?
+func (rc *raftCore) upToDate(lastLogTerm, lastLogIndex uint64) bool {
+	if lastLogTerm != rc.lastTerm() {
+		return lastLogTerm > rc.lastTerm()
+	}
+	return lastLogIndex >= rc.lastIndex()
+}
+
+func (rc *raftCore) resetElectionTimer() {
+	timeout := rc.electionTimeout + time.Duration(rand.Int63n(int64(rc.electionTimeout)))
+	rc.electionTimer.Reset(timeout, electionTimerEvent{})
+}
+
+func (rc *raftCore) startBatchTimer() {
+	rc.batchTimer.Reset(rc.batchTimeout, batchTimerEvent{})
+	rc.batchTimerActive = true
+}
+
+func (rc *raftCore) stopBatchTimer() {
+	rc.batchTimer.Stop()
+	rc.batchTimerActive = false
+}
+
+// =============================================================================
+// receive methods
+// =============================================================================
+
+// ProcessEvent is the main event handling switch for the event thread
+func (rc *raftCore) ProcessEvent(e events.Event) events.Event {
+	logger.Debugf("Replica %d processing event", rc.id)
+	switch et := e.(type) {
+	case messageEvent:
+		rc.recvMsg(et.msg, et.sender)
+	case electionTimerEvent:
+		if rc.state != leader {
+			logger.Infof("Replica %d election timer expired in term %d", rc.id, rc.term)
+			rc.startElection()
+		}
+	case heartbeatTimerEvent:
+		if rc.state == leader {
+			rc.broadcastAppendEntries()
+			rc.heartbeatTimer.Reset(rc.heartbeatTimeout, heartbeatTimerEvent{})
+		}
+	case batchTimerEvent:
+		logger.Debugf("Replica %d batch timer expired", rc.id)
+		rc.batchTimerActive = false
+		if rc.state == leader && len(rc.batchStore) > 0 {
+			rc.sendBatch()
+		}
+	case executedEvent:
+		rc.executed(et.tag.(uint64))
+	case committedEvent:
+		rc.committed(et.tag.(uint64), et.target)
+	case stateUpdatedEvent:
+		rc.stateUpdated(et.snapshot, et.target)
+	case nil:
+		// Used by tests to wait for the event thread to become idle
+	default:
+		logger.Warningf("Replica %d received an unknown event type %T", rc.id, et)
+	}
+	return nil
+}
+
+func (rc *raftCore) recvMsg(ocMsg *pb.Message, senderHandle *pb.PeerID) {
+	if ocMsg.Type == pb.Message_CHAIN_TRANSACTION {
+		rc.recvRequest(ocMsg.Payload)
+		return
+	}
+
+	if ocMsg.Type != pb.Message_CONSENSUS {
+		logger.Errorf("Unexpected message type: %s", ocMsg.Type)
+		return
+	}
+
+	sender, err := getValidatorID(senderHandle)
+	if err != nil || sender >= uint64(rc.N) {
+		logger.Warningf("Replica %d received a message from %v which is not a replica", rc.id, senderHandle)
+		return
+	}
+
+	msg := &Message{}
+	if err = proto.Unmarshal(ocMsg.Payload, msg); err != nil {
+		logger.Errorf("Error unpacking payload from message: %s", err)
+		return
+	}
+
+	if req := msg.GetRequest(); req != nil {
+		rc.recvRequest(req.Payload)
+	} else if ae := msg.GetAppendEntries(); ae != nil {
+		rc.recvAppendEntries(ae, sender)
+	} else if aer := msg.GetAppendEntriesResponse(); aer != nil {
+		rc.recvAppendEntriesResponse(aer, sender)
+	} else if rv := msg.GetRequestVote(); rv != nil {
+		rc.recvRequestVote(rv, sender)
+	} else if rvr := msg.GetRequestVoteResponse(); rvr != nil {
+		rc.recvRequestVoteResponse(rvr, sender)
+	} else if is := msg.GetInstallSnapshot(); is != nil {
+		rc.recvInstallSnapshot(is, sender)
+	} else {
+		logger.Errorf("Replica %d received an invalid message from replica %d", rc.id, sender)
+	}
+}
+
+// =============================================================================
+// leader election
+// =============================================================================
+
+// becomeFollower moves to the given term, if it is newer, and follows
+// whichever leader is elected in it
+func (rc *raftCore) becomeFollower(term uint64) {
+	if term > rc.term {
+		rc.term = term
+		rc.voted = false
+		rc.leaderKnown = false
+		rc.persistHardState()
+	}
+	if rc.state == leader {
+		logger.Infof("Replica %d stepping down as leader in term %d", rc.id, rc.term)
+		rc.heartbeatTimer.Stop()
+		rc.stopBatchTimer()
+		// The transactions which were not appended yet are passed on to the next leader
+		rc.pending = append(rc.pending, rc.batchStore...)
+		rc.batchStore = nil
+	}
+	rc.state = follower
+	rc.resetElectionTimer()
+}
+
+func (rc *raftCore) startElection() {
+	rc.state = candidate
+	rc.term++
+	rc.voted = true
+	rc.votedFor = rc.id
+	rc.leaderKnown = false
+	rc.persistHardState()
+	rc.votes = map[uint64]bool{rc.id: true}
+	rc.resetElectionTimer()
+
+	logger.Infof("Replica %d starting election for term %d", rc.id, rc.term)
+	if len(rc.votes) >= rc.quorum() {
+		rc.becomeLeader()
+		return
+	}
+
+	rc.broadcast(&Message{Payload: &Message_RequestVote{RequestVote: &RequestVote{
+		Term:         rc.term,
+		CandidateId:  rc.id,
+		LastLogIndex: rc.lastIndex(),
+		LastLogTerm:  rc.lastTerm(),
+	}}})
+}
+
+func (rc *raftCore) recvRequestVote(rv *RequestVote, sender uint64) {
+	if rv.Term > rc.term {
+		rc.becomeFollower(rv.Term)
+	}
+
+	grant := rv.Term == rc.term && (!rc.voted || rc.votedFor == rv.CandidateId) &&
+		rc.upToDate(rv.LastLogTerm, rv.LastLogIndex)
+	if grant {
+		logger.Debugf("Replica %d voting for replica %d in term %d", rc.id, rv.CandidateId, rc.term)
+		rc.voted = true
+		rc.votedFor = rv.CandidateId
+		rc.persistHardState()
+		rc.resetElectionTimer()
+	}
+
+	rc.unicast(&Message{Payload: &Message_RequestVoteResponse{RequestVoteResponse: &RequestVoteResponse{
+		Term:        rc.term,
+		ReplicaId:   rc.id,
+		VoteGranted: grant,
+	}}}, sender)
+}
+
+func (rc *raftCore) recvRequestVoteResponse(rvr *RequestVoteResponse, sender uint64) {
+	if rvr.Term > rc.term {
+		rc.becomeFollower(rvr.Term)
+		return
+	}
+	if rc.state != candidate || rvr.Term != rc.term || !rvr.VoteGranted {
+		return
+	}
+
+	rc.votes[sender] = true
+	if len(rc.votes) >= rc.quorum() {
+		rc.becomeLeader()
+	}
+}
+
+func (rc *raftCore) becomeLeader() {
+	logger.Infof("Replica %d elected leader for term %d", rc.id, rc.term)
+	rc.state = leader
+	rc.leaderID = rc.id
+	rc.leaderKnown = true
+	rc.electionTimer.Stop()
+
+	rc.nextIndex = make(map[uint64]uint64)
+	rc.matchIndex = make(map[uint64]uint64)
+	for id := uint64(0); id < uint64(rc.N); id++ {
+		rc.nextIndex[id] = rc.lastIndex() + 1
+		rc.matchIndex[id] = 0
+	}
+
+	// Entries of previous terms are only known to be committed once an
+	// entry of the current term is, so start the term with an empty entry
+	rc.appendEntry(nil)
+	rc.heartbeatTimer.Reset(rc.heartbeatTimeout, heartbeatTimerEvent{})
+
+	pending := rc.pending
+	rc.pending = nil
+	for _, tx := range pending {
+		rc.leaderProcReq(tx)
+	}
+}
+
+// =============================================================================
+// requests
+// =============================================================================
+
+// recvRequest handles a transaction submitted to this replica, or forwarded
+// to it by another replica
+func (rc *raftCore) recvRequest(tx []byte) {
+	switch {
+	case rc.state == leader:
+		rc.leaderProcReq(tx)
+	case rc.leaderKnown:
+		rc.forwardRequest(tx)
+	default:
+		logger.Debugf("Replica %d holding request until a leader is elected", rc.id)
+		rc.pending = append(rc.pending, tx)
+	}
+}
+
+func (rc *raftCore) forwardRequest(tx []byte) {
+	logger.Debugf("Replica %d forwarding request to leader %d", rc.id, rc.leaderID)
+	rc.unicast(&Message{Payload: &Message_Request{Request: &Request{
+		Payload:   tx,
+		ReplicaId: rc.id,
+	}}}, rc.leaderID)
+}
+
+func (rc *raftCore) leaderProcReq(tx []byte) {
+	rc.batchStore = append(rc.batchStore, tx)
+	if len(rc.batchStore) >= rc.batchSize {
+		rc.sendBatch()
+		return
+	}
+	if !rc.batchTimerActive {
+		rc.startBatchTimer()
+	}
+}
+
+func (rc *raftCore) sendBatch() {
+	rc.stopBatchTimer()
+	logger.Infof("Replica %d creating log entry with %d transactions", rc.id, len(rc.batchStore))
+	rc.appendEntry(rc.batchStore)
+	rc.batchStore = nil
+}
+
+// =============================================================================
+// log replication
+// =============================================================================
+
+// appendEntry appends a new entry to the leader's log and replicates it
+func (rc *raftCore) appendEntry(txs [][]byte) {
+	e := &Entry{
+		Term:         rc.term,
+		Index:        rc.lastIndex() + 1,
+		Transactions: txs,
+	}
+	rc.log = append(rc.log, e)
+	rc.persistEntry(e)
+	rc.matchIndex[rc.id] = e.Index
+	rc.nextIndex[rc.id] = e.Index + 1
+
+	rc.broadcastAppendEntries()
+	rc.advanceCommitIndex()
+}
+
+func (rc *raftCore) broadcastAppendEntries() {
+	for id := uint64(0); id < uint64(rc.N); id++ {
+		if id != rc.id {
+			rc.sendAppendEntries(id)
+		}
+	}
+}
+
+// sendAppendEntries sends the replica the entries it is not known to
+// have, or the snapshot if they were discarded from the log already
+func (rc *raftCore) sendAppendEntries(id uint64) {
+	next := rc.nextIndex[id]
+	if next <= rc.snapshot.Index {
+		logger.Debugf("Replica %d sending snapshot at index %d to replica %d", rc.id, rc.snapshot.Index, id)
+		rc.unicast(&Message{Payload: &Message_InstallSnapshot{InstallSnapshot: &InstallSnapshot{
+			Term:     rc.term,
+			LeaderId: rc.id,
+			Snapshot: rc.snapshot,
+		}}}, id)
+		return
+	}
+
+	prevTerm, _ := rc.termAt(next - 1)
+	ae := &AppendEntries{
+		Term:         rc.term,
+		LeaderId:     rc.id,
+		PrevLogIndex: next - 1,
+		PrevLogTerm:  prevTerm,
+		Entries:      rc.entriesFrom(next),
+		LeaderCommit: rc.commitIndex,
+	}
+	// Optimistically assume the entries arrive, a failed response resets nextIndex
+	rc.nextIndex[id] = rc.lastIndex() + 1
+	rc.unicast(&Message{Payload: &Message_AppendEntries{AppendEntries: ae}}, id)
+}
+
+// followLeader accepts the sender of a valid append entries or snapshot
+// as the leader of the current term
+func (rc *raftCore) followLeader(term uint64, leaderID uint64) {
+	if term > rc.term || rc.state != follower {
+		rc.becomeFollower(term)
+	} else {
+		rc.resetElectionTimer()
+	}
+
+	if !rc.leaderKnown {
+		logger.Infof("Replica %d following leader %d in term %d", rc.id, leaderID, rc.term)
+		rc.leaderID = leaderID
+		rc.leaderKnown = true
+
+		pending := rc.pending
+		rc.pending = nil
+		for _, tx := range pending {
+			rc.forwardRequest(tx)
+		}
+	}
+}
+
+func (rc *raftCore) recvAppendEntries(ae *AppendEntries, sender uint64) {
+	if ae.Term < rc.term {
+		rc.sendAppendEntriesResponse(sender, false, rc.lastIndex())
+		return
+	}
+	rc.followLeader(ae.Term, ae.LeaderId)
+
+	// Entries up to our snapshot are committed, and therefore match the leader's
+	prevIndex, prevTerm, entries := ae.PrevLogIndex, ae.PrevLogTerm, ae.Entries
+	for len(entries) > 0 && prevIndex < rc.snapshot.Index {
+		prevIndex, prevTerm = entries[0].Index, entries[0].Term
+		entries = entries[1:]
+	}
+	if prevIndex < rc.snapshot.Index {
+		rc.sendAppendEntriesResponse(sender, true, rc.snapshot.Index)
+		return
+	}
+
+	if prevIndex > rc.lastIndex() {
+		rc.sendAppendEntriesResponse(sender, false, rc.lastIndex())
+		return
+	}
+	if term, _ := rc.termAt(prevIndex); term != prevTerm {
+		logger.Debugf("Replica %d log does not match leader %d at index %d", rc.id, sender, prevIndex)
+		rc.sendAppendEntriesResponse(sender, false, prevIndex-1)
+		return
+	}
+
+	for _, e := range entries {
+		if e.Index <= rc.lastIndex() {
+			if term, _ := rc.termAt(e.Index); term == e.Term {
+				continue
+			}
+			rc.truncateLog(e.Index)
+		}
+		rc.log = append(rc.log, e)
+		rc.persistEntry(e)
+	}
+
+	match := prevIndex + uint64(len(entries))
+	if ae.LeaderCommit > rc.commitIndex {
+		rc.commitIndex = ae.LeaderCommit
+		if rc.commitIndex > match {
+			rc.commitIndex = match
+		}
+	}
+	rc.sendAppendEntriesResponse(sender, true, match)
+	rc.executeOutstanding()
+}
+
+// truncateLog discards the conflicting entries from index onwards
+func (rc *raftCore) truncateLog(index uint64) {
+	if index <= rc.commitIndex {
+		panic(fmt.Errorf("Replica %d asked to discard committed entry %d, committed up to %d", rc.id, index, rc.commitIndex))
+	}
+	logger.Infof("Replica %d discarding log entries from index %d", rc.id, index)
+	for i := index; i <= rc.lastIndex(); i++ {
+		rc.persistDelEntry(i)
+	}
+	rc.log = rc.log[:index-rc.snapshot.Index-1]
+}
+
+func (rc *raftCore) sendAppendEntriesResponse(leaderID uint64, success bool, matchIndex uint64) {
+	rc.unicast(&Message{Payload: &Message_AppendEntriesResponse{AppendEntriesResponse: &AppendEntriesResponse{
+		Term:       rc.term,
+		ReplicaId:  rc.id,
+		Success:    success,
+		MatchIndex: matchIndex,
+	}}}, leaderID)
+}
+
+func (rc *raftCore) recvAppendEntriesResponse(aer *AppendEntriesResponse, sender uint64) {
+	if aer.Term > rc.term {
+		rc.becomeFollower(aer.Term)
+		return
+	}
+	if rc.state != leader || aer.Term != rc.term {
+		return
+	}
+
+	if !aer.Success {
+		// Back off to the follower's hint and try again
+		next := aer.MatchIndex + 1
+		if next <= rc.matchIndex[sender] {
+			next = rc.matchIndex[sender] + 1
+		}
+		if next > rc.lastIndex()+1 {
+			next = rc.lastIndex() + 1
+		}
+		rc.nextIndex[sender] = next
+		rc.sendAppendEntries(sender)
+		return
+	}
+
+	if aer.MatchIndex > rc.matchIndex[sender] {
+		rc.matchIndex[sender] = aer.MatchIndex
+	}
+	if rc.nextIndex[sender] <= rc.matchIndex[sender] {
+		rc.nextIndex[sender] = rc.matchIndex[sender] + 1
+		if rc.nextIndex[sender] <= rc.lastIndex() {
+			rc.sendAppendEntries(sender)
+		}
+	}
+	rc.advanceCommitIndex()
+}
+
+// advanceCommitIndex commits the highest entry of the current term
+// which a majority of the replicas have in their log
+func (rc *raftCore) advanceCommitIndex() {
+	for n := rc.lastIndex(); n > rc.commitIndex; n-- {
+		if term, _ := rc.termAt(n); term != rc.term {
+			// Terms only decrease from here
+			return
+		}
+		count := 0
+		for _, match := range rc.matchIndex {
+			if match >= n {
+				count++
+			}
+		}
+		if count >= rc.quorum() {
+			logger.Debugf("Replica %d committed log up to index %d", rc.id, n)
+			rc.commitIndex = n
+			// Let the followers know without waiting for the heartbeat
+			rc.broadcastAppendEntries()
+			rc.executeOutstanding()
+			return
+		}
+	}
+}
+
+// =============================================================================
+// execution
+// =============================================================================
+
+// executeOutstanding applies the committed entries to the ledger, one at a time
+func (rc *raftCore) executeOutstanding() {
+	for rc.currentExec == nil && !rc.skipInProgress && rc.lastApplied < rc.commitIndex {
+		e := rc.entry(rc.lastApplied + 1)
+
+		var txs []*pb.Transaction
+		for _, raw := range e.Transactions {
+			tx := &pb.Transaction{}
+			if err := proto.Unmarshal(raw, tx); err != nil {
+				logger.Warningf("Replica %d could not unmarshal transaction in entry %d: %s", rc.id, e.Index, err)
+				continue
+			}
+			txs = append(txs, tx)
+		}
+
+		if len(txs) == 0 {
+			// Nothing to commit, the ledger is unchanged
+			rc.lastApplied = e.Index
+			rc.maybeSnapshot()
+			continue
+		}
+
+		logger.Debugf("Replica %d executing entry %d containing %d transactions", rc.id, e.Index, len(txs))
+		index := e.Index
+		rc.currentExec = &index
+		rc.stack.Execute(index, txs) // This executes in the background, we will receive an executedEvent once it completes
+	}
+}
+
+func (rc *raftCore) executed(index uint64) {
+	if rc.currentExec == nil || *rc.currentExec != index {
+		logger.Warningf("Replica %d received executed for entry %d it is not executing", rc.id, index)
+		return
+	}
+	if rc.skipInProgress {
+		// State transfer rolls the execution back
+		rc.currentExec = nil
+		return
+	}
+
+	term, _ := rc.termAt(index)
+	meta, _ := proto.Marshal(&Metadata{Index: index, Term: term})
+	rc.stack.Commit(index, meta)
+}
+
+func (rc *raftCore) committed(index uint64, target *pb.BlockchainInfo) {
+	if rc.currentExec == nil || *rc.currentExec != index {
+		logger.Warningf("Replica %d received committed for entry %d it is not executing", rc.id, index)
+		return
+	}
+	rc.currentExec = nil
+	if rc.skipInProgress {
+		return
+	}
+
+	logger.Debugf("Replica %d applied entry %d", rc.id, index)
+	rc.lastApplied = index
+	rc.appliedInfo = target
+	rc.maybeSnapshot()
+	rc.executeOutstanding()
+}
+
+// =============================================================================
+// snapshots
+// =============================================================================
+
+// maybeSnapshot compacts the log once enough entries have been applied
+func (rc *raftCore) maybeSnapshot() {
+	if rc.snapshotInterval == 0 || rc.lastApplied-rc.snapshot.Index < rc.snapshotInterval {
+		return
+	}
+
+	info, err := proto.Marshal(rc.appliedInfo)
+	if err != nil {
+		logger.Errorf("Replica %d could not marshal blockchain info for snapshot: %s", rc.id, err)
+		return
+	}
+	term, _ := rc.termAt(rc.lastApplied)
+	snapshot := &Snapshot{
+		Index:          rc.lastApplied,
+		Term:           term,
+		BlockchainInfo: info,
+	}
+	logger.Debugf("Replica %d compacting log up to index %d", rc.id, snapshot.Index)
+
+	// Persist the snapshot first, entries it covers are ignored when restoring
+	rc.persistSnapshot(snapshot)
+	for i := rc.snapshot.Index + 1; i <= snapshot.Index; i++ {
+		rc.persistDelEntry(i)
+	}
+	rc.log = rc.entriesFrom(snapshot.Index + 1)
+	rc.snapshot = snapshot
+}
+
+func (rc *raftCore) recvInstallSnapshot(is *InstallSnapshot, sender uint64) {
+	if is.Term < rc.term {
+		rc.sendAppendEntriesResponse(sender, false, rc.lastIndex())
+		return
+	}
+	rc.followLeader(is.Term, is.LeaderId)
+
+	snapshot := is.Snapshot
+	if snapshot == nil {
+		logger.Warningf("Replica %d received an empty snapshot from replica %d", rc.id, sender)
+		return
+	}
+
+	if term, ok := rc.termAt(snapshot.Index); ok && term == snapshot.Term || snapshot.Index <= rc.snapshot.Index {
+		if rc.skipInProgress {
+			// Already transferring state to this snapshot
+			return
+		}
+		// The log already contains the entries of the snapshot
+		if snapshot.Index > rc.commitIndex {
+			rc.commitIndex = snapshot.Index
+		}
+		rc.sendAppendEntriesResponse(sender, true, snapshot.Index)
+		rc.executeOutstanding()
+		return
+	}
+
+	target := &pb.BlockchainInfo{}
+	if err := proto.Unmarshal(snapshot.BlockchainInfo, target); err != nil {
+		logger.Errorf("Replica %d could not unmarshal snapshot blockchain info: %s", rc.id, err)
+		return
+	}
+
+	logger.Infof("Replica %d missing entries up to %d, catching up to snapshot through state transfer", rc.id, snapshot.Index)
+	for i := rc.snapshot.Index + 1; i <= rc.lastIndex(); i++ {
+		rc.persistDelEntry(i)
+	}
+	rc.log = nil
+	rc.snapshot = snapshot
+	rc.commitIndex = snapshot.Index
+	rc.skipInProgress = true
+	rc.stack.InvalidateState()
+	rc.stack.UpdateState(snapshot, target, []*pb.PeerID{getValidatorHandle(sender)})
+}
+
+func (rc *raftCore) stateUpdated(snapshot *Snapshot, target *pb.BlockchainInfo) {
+	if !rc.skipInProgress || snapshot != rc.snapshot {
+		logger.Debugf("Replica %d ignoring state update to a stale snapshot", rc.id)
+		return
+	}
+
+	if target == nil {
+		logger.Warningf("Replica %d state transfer to snapshot at index %d failed, retrying", rc.id, snapshot.Index)
+		info := &pb.BlockchainInfo{}
+		proto.Unmarshal(snapshot.BlockchainInfo, info)
+		var peers []*pb.PeerID
+		for id := uint64(0); id < uint64(rc.N); id++ {
+			if id != rc.id {
+				peers = append(peers, getValidatorHandle(id))
+			}
+		}
+		rc.stack.UpdateState(snapshot, info, peers)
+		return
+	}
+
+	logger.Infof("Replica %d caught up to snapshot at index %d", rc.id, snapshot.Index)
+	rc.skipInProgress = false
+	rc.currentExec = nil
+	rc.lastApplied = snapshot.Index
+	rc.appliedInfo = target
+	rc.persistSnapshot(snapshot)
+	rc.stack.ValidateState()
+
+	if rc.leaderKnown && rc.state == follower {
+		rc.sendAppendEntriesResponse(rc.leaderID, true, snapshot.Index)
+	}
+	rc.executeOutstanding()
+}
+
+// =============================================================================
+// communication
+// =============================================================================
+
+func (rc *raftCore) broadcast(msg *Message) {
+	for id := uint64(0); id < uint64(rc.N); id++ {
+		if id != rc.id {
+			rc.unicast(msg, id)
+		}
+	}
+}
+
+func (rc *raftCore) unicast(msg *Message, receiverID uint64) {
+	msgPayload, err := proto.Marshal(msg)
+	if err != nil {
+		logger.Errorf("Replica %d could not marshal message: %s", rc.id, err)
+		return
+	}
+	ocMsg := &pb.Message{
+		Type:    pb.Message_CONSENSUS,
+		Payload: msgPayload,
+	}
+	if err = rc.stack.Unicast(ocMsg, getValidatorHandle(receiverID)); err != nil {
+		logger.Debugf("Replica %d could not send message to replica %d: %s", rc.id, receiverID, err)
+	}
+}
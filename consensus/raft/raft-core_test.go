@@ -0,0 +1,184 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package raft
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+)
+
+func TestElectionAndReplication(t *testing.T) {
+	net := makeTestNetwork(3)
+
+	net.send(0, electionTimerEvent{})
+	net.process()
+
+	for _, r := range net.replicas {
+		if r.raft.term != 1 || !r.raft.leaderKnown || r.raft.leaderID != 0 {
+			t.Errorf("Replica %d expected to follow leader 0 in term 1, in term %d following %d (known %v)",
+				r.id, r.raft.term, r.raft.leaderID, r.raft.leaderKnown)
+		}
+	}
+	if net.replicas[0].raft.state != leader {
+		t.Fatalf("Replica 0 expected to be leader, is %s", net.replicas[0].raft.state)
+	}
+
+	// Submitted to a follower, which forwards it to the leader
+	net.submit(2, "tx1")
+	net.process()
+
+	for _, r := range net.replicas {
+		if !reflect.DeepEqual(r.uuids(), []string{"tx1"}) {
+			t.Errorf("Replica %d expected to have committed tx1, has %v", r.id, r.uuids())
+		}
+		meta := &Metadata{}
+		raw, _ := r.GetBlockHeadMetadata()
+		proto.Unmarshal(raw, meta)
+		if meta.Index != 2 || meta.Term != 1 {
+			t.Errorf("Replica %d expected block metadata for entry 2 in term 1, got %+v", r.id, meta)
+		}
+	}
+}
+
+func TestLeaderFailover(t *testing.T) {
+	net := makeTestNetwork(3)
+	net.send(0, electionTimerEvent{})
+	net.process()
+	net.submit(0, "tx1")
+	net.process()
+
+	// The old leader appends an entry it cannot commit on its own
+	net.replicas[0].connected = false
+	net.submit(0, "lost")
+	net.process()
+
+	net.send(1, electionTimerEvent{})
+	net.process()
+	if net.replicas[1].raft.state != leader || net.replicas[1].raft.term != 2 {
+		t.Fatalf("Replica 1 expected to be leader of term 2, is %s of term %d", net.replicas[1].raft.state, net.replicas[1].raft.term)
+	}
+
+	net.submit(2, "tx2")
+	net.process()
+
+	net.replicas[0].connected = true
+	net.send(1, heartbeatTimerEvent{})
+	net.process()
+
+	if net.replicas[0].raft.state != follower || net.replicas[0].raft.term != 2 {
+		t.Errorf("Replica 0 expected to have stepped down in term 2, is %s of term %d", net.replicas[0].raft.state, net.replicas[0].raft.term)
+	}
+	for _, r := range net.replicas {
+		if !reflect.DeepEqual(r.uuids(), []string{"tx1", "tx2"}) {
+			t.Errorf("Replica %d expected to have committed tx1 and tx2, has %v", r.id, r.uuids())
+		}
+		if r.raft.lastIndex() != net.replicas[1].raft.lastIndex() {
+			t.Errorf("Replica %d expected the log of the leader up to %d, has up to %d", r.id, net.replicas[1].raft.lastIndex(), r.raft.lastIndex())
+		}
+	}
+}
+
+func TestVoteDeniedToStaleLog(t *testing.T) {
+	net := makeTestNetwork(3)
+	net.send(0, electionTimerEvent{})
+	net.process()
+
+	net.replicas[2].connected = false
+	net.submit(0, "tx1")
+	net.process()
+	net.replicas[2].connected = true
+
+	// Replica 2 missed an entry, replicas 0 and 1 must not vote for it
+	net.send(2, electionTimerEvent{})
+	net.process()
+
+	if net.replicas[2].raft.state == leader {
+		t.Fatalf("Replica 2 should not have been elected with a stale log")
+	}
+	if net.replicas[1].raft.voted && net.replicas[1].raft.votedFor == 2 {
+		t.Errorf("Replica 1 should not have voted for replica 2")
+	}
+}
+
+func TestRestoreFromPersistedState(t *testing.T) {
+	net := makeTestNetwork(3)
+	net.send(0, electionTimerEvent{})
+	net.process()
+	net.submit(0, "tx1")
+	net.process()
+
+	net.replicas[2].connected = false
+	net.submit(0, "tx2")
+	net.process()
+
+	old := net.replicas[1].raft
+	restored := net.restart(1)
+	if restored.term != old.term || !restored.voted || restored.votedFor != 0 {
+		t.Errorf("Expected term %d with vote for replica 0, got term %d, voted %v for %d", old.term, restored.term, restored.voted, restored.votedFor)
+	}
+	if restored.lastIndex() != old.lastIndex() || restored.lastTerm() != old.lastTerm() {
+		t.Errorf("Expected log up to %d in term %d, got log up to %d in term %d", old.lastIndex(), old.lastTerm(), restored.lastIndex(), restored.lastTerm())
+	}
+	if restored.lastApplied != old.lastApplied || restored.commitIndex != old.lastApplied {
+		t.Errorf("Expected to have applied up to %d, restored %d", old.lastApplied, restored.lastApplied)
+	}
+}
+
+func TestSnapshotCatchUp(t *testing.T) {
+	net := makeTestNetwork(3, func(config *viper.Viper) {
+		config.Set("general.snapshotinterval", 2)
+	})
+	net.send(0, electionTimerEvent{})
+	net.process()
+
+	net.replicas[2].connected = false
+	for _, uuid := range []string{"tx1", "tx2", "tx3", "tx4"} {
+		net.submit(0, uuid)
+		net.process()
+	}
+
+	leader := net.replicas[0].raft
+	if leader.snapshot.Index <= net.replicas[2].raft.lastIndex() {
+		t.Fatalf("Expected the leader to have discarded entries replica 2 is missing, snapshot at %d", leader.snapshot.Index)
+	}
+	if _, ok := net.replicas[0].state[entryKey(1)]; ok {
+		t.Errorf("Expected discarded entries to be removed from the persisted log")
+	}
+
+	net.replicas[2].connected = true
+	net.send(0, heartbeatTimerEvent{})
+	net.process()
+
+	r2 := net.replicas[2]
+	if r2.raft.skipInProgress {
+		t.Fatalf("Expected state transfer to have completed")
+	}
+	if !reflect.DeepEqual(r2.uuids(), net.replicas[0].uuids()) {
+		t.Errorf("Replica 2 expected to have caught up to %v, has %v", net.replicas[0].uuids(), r2.uuids())
+	}
+
+	net.submit(2, "tx5")
+	net.process()
+	for _, r := range net.replicas {
+		if !reflect.DeepEqual(r.uuids(), []string{"tx1", "tx2", "tx3", "tx4", "tx5"}) {
+			t.Errorf("Replica %d expected to have committed all transactions, has %v", r.id, r.uuids())
+		}
+	}
+}
@@ -0,0 +1,192 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package raft
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// --------------------------------------------------------------
+//
+// The term, vote and log of a replica are persisted through the
+// stack as they change, so a replica which crashes never votes
+// twice in a term nor forgets entries it acknowledged. Applied
+// entries are discarded once covered by a snapshot, whose index
+// and term are persisted in their place.
+//
+// --------------------------------------------------------------
+
+const entryPrefix = "raft.entry."
+
+func entryKey(index uint64) string {
+	return fmt.Sprintf("%s%d", entryPrefix, index)
+}
+
+func (rc *raftCore) persistHardState() {
+	raw, err := proto.Marshal(&HardState{
+		Term:     rc.term,
+		VotedFor: rc.votedFor,
+		Voted:    rc.voted,
+	})
+	if err != nil {
+		logger.Warningf("Replica %d could not persist hard state: %s", rc.id, err)
+		return
+	}
+	rc.stack.StoreState("raft.hardState", raw)
+}
+
+func (rc *raftCore) persistEntry(e *Entry) {
+	raw, err := proto.Marshal(e)
+	if err != nil {
+		logger.Warningf("Replica %d could not persist log entry %d: %s", rc.id, e.Index, err)
+		return
+	}
+	rc.stack.StoreState(entryKey(e.Index), raw)
+}
+
+func (rc *raftCore) persistDelEntry(index uint64) {
+	rc.stack.DelState(entryKey(index))
+}
+
+func (rc *raftCore) persistSnapshot(snapshot *Snapshot) {
+	raw, err := proto.Marshal(snapshot)
+	if err != nil {
+		logger.Warningf("Replica %d could not persist snapshot: %s", rc.id, err)
+		return
+	}
+	rc.stack.StoreState("raft.snapshot", raw)
+}
+
+func (rc *raftCore) restoreHardState() {
+	raw, err := rc.stack.ReadState("raft.hardState")
+	if err != nil {
+		logger.Debugf("Replica %d could not restore hard state: %s", rc.id, err)
+		return
+	}
+	hs := &HardState{}
+	if err = proto.Unmarshal(raw, hs); err != nil {
+		logger.Errorf("Replica %d could not unmarshal hard state - local state is damaged: %s", rc.id, err)
+		return
+	}
+	rc.term = hs.Term
+	rc.votedFor = hs.VotedFor
+	rc.voted = hs.Voted
+}
+
+func (rc *raftCore) restoreSnapshot() {
+	raw, err := rc.stack.ReadState("raft.snapshot")
+	if err != nil {
+		logger.Debugf("Replica %d could not restore snapshot: %s", rc.id, err)
+		return
+	}
+	snapshot := &Snapshot{}
+	if err = proto.Unmarshal(raw, snapshot); err != nil {
+		logger.Errorf("Replica %d could not unmarshal snapshot - local state is damaged: %s", rc.id, err)
+		return
+	}
+	rc.snapshot = snapshot
+}
+
+// restoreLog reads the entries following the snapshot, up to the first gap
+func (rc *raftCore) restoreLog() {
+	raw, err := rc.stack.ReadStateSet(entryPrefix)
+	if err != nil {
+		logger.Debugf("Replica %d could not restore log: %s", rc.id, err)
+		return
+	}
+
+	entries := make(map[uint64]*Entry)
+	var indices []int
+	for key, val := range raw {
+		index, err := strconv.ParseUint(strings.TrimPrefix(key, entryPrefix), 10, 64)
+		if err != nil {
+			logger.Warningf("Replica %d ignoring malformed log key %s", rc.id, key)
+			continue
+		}
+		if index <= rc.snapshot.Index {
+			rc.persistDelEntry(index)
+			continue
+		}
+		e := &Entry{}
+		if err = proto.Unmarshal(val, e); err != nil {
+			logger.Errorf("Replica %d could not unmarshal log entry %d - local state is damaged: %s", rc.id, index, err)
+			continue
+		}
+		entries[index] = e
+		indices = append(indices, int(index))
+	}
+	sort.Ints(indices)
+
+	for _, index := range indices {
+		if uint64(index) != rc.lastIndex()+1 {
+			logger.Warningf("Replica %d log has a gap before index %d, discarding the rest of the log", rc.id, index)
+			break
+		}
+		rc.log = append(rc.log, entries[uint64(index)])
+	}
+}
+
+// restoreLastApplied reads the last entry applied from the ledger
+func (rc *raftCore) restoreLastApplied() {
+	rc.appliedInfo = rc.stack.GetBlockchainInfo()
+
+	raw, err := rc.stack.GetBlockHeadMetadata()
+	if err != nil {
+		logger.Debugf("Replica %d could not read the metadata of the last block: %s", rc.id, err)
+		return
+	}
+	meta := &Metadata{}
+	proto.Unmarshal(raw, meta)
+	rc.lastApplied = meta.Index
+	info, _ := proto.Marshal(rc.appliedInfo)
+	if rc.lastApplied < rc.snapshot.Index && bytes.Equal(info, rc.snapshot.BlockchainInfo) {
+		// The entries following the last block were empty, and produced no block
+		rc.lastApplied = rc.snapshot.Index
+	}
+	rc.commitIndex = rc.lastApplied
+
+	if rc.lastApplied > rc.lastIndex() || rc.lastApplied < rc.snapshot.Index {
+		// The ledger does not match the log, start the log over from the ledger
+		logger.Warningf("Replica %d ledger applied entry %d which is not in the log, restarting log from it", rc.id, rc.lastApplied)
+		for i := rc.snapshot.Index + 1; i <= rc.lastIndex(); i++ {
+			rc.persistDelEntry(i)
+		}
+		rc.log = nil
+		rc.snapshot = &Snapshot{
+			Index:          meta.Index,
+			Term:           meta.Term,
+			BlockchainInfo: info,
+		}
+		rc.persistSnapshot(rc.snapshot)
+	}
+}
+
+func (rc *raftCore) restoreState() {
+	rc.restoreHardState()
+	rc.restoreSnapshot()
+	rc.restoreLog()
+	rc.restoreLastApplied()
+
+	logger.Infof("Replica %d restored term %d, log from index %d to %d, applied up to %d",
+		rc.id, rc.term, rc.snapshot.Index, rc.lastIndex(), rc.lastApplied)
+}
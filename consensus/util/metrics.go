@@ -0,0 +1,89 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"sync"
+	"time"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// LatencyBounds are the upper bounds of the buckets of the latency histograms
+// reported by the consensus plugins
+var LatencyBounds = []time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+}
+
+// Histogram counts durations in buckets, it is safe for concurrent use
+type Histogram struct {
+	lock   sync.Mutex
+	bounds []time.Duration
+	counts []uint64
+	count  uint64
+	sum    time.Duration
+}
+
+// NewHistogram creates a histogram whose buckets have the given increasing
+// upper bounds
+func NewHistogram(bounds []time.Duration) *Histogram {
+	return &Histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+}
+
+// Observe counts the duration in the first bucket whose upper bound is not
+// below it, durations above the last upper bound are only part of the total
+func (h *Histogram) Observe(d time.Duration) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for i, bound := range h.bounds {
+		if d <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += d
+}
+
+// ToProto returns the counts of the histogram, with durations in seconds
+func (h *Histogram) ToProto() *pb.Histogram {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	hist := &pb.Histogram{
+		UpperBounds: make([]float64, len(h.bounds)),
+		Counts:      make([]uint64, len(h.counts)),
+		Count:       h.count,
+		Sum:         h.sum.Seconds(),
+	}
+	for i, bound := range h.bounds {
+		hist.UpperBounds[i] = bound.Seconds()
+	}
+	copy(hist.Counts, h.counts)
+	return hist
+}
@@ -0,0 +1,42 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram([]time.Duration{time.Second, 2 * time.Second})
+	h.Observe(500 * time.Millisecond)
+	h.Observe(time.Second)
+	h.Observe(1500 * time.Millisecond)
+	h.Observe(3 * time.Second)
+
+	hist := h.ToProto()
+	if !reflect.DeepEqual(hist.UpperBounds, []float64{1, 2}) {
+		t.Errorf("Expected upper bounds of 1 and 2 seconds, got %v", hist.UpperBounds)
+	}
+	if !reflect.DeepEqual(hist.Counts, []uint64{2, 1}) {
+		t.Errorf("Expected bucket counts of 2 and 1, got %v", hist.Counts)
+	}
+	if hist.Count != 4 || hist.Sum != 6 {
+		t.Errorf("Expected 4 durations totalling 6 seconds, got %d totalling %f", hist.Count, hist.Sum)
+	}
+}
@@ -17,18 +17,29 @@ limitations under the License.
 package core
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"runtime"
+	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
 	"golang.org/x/net/context"
 
 	"google/protobuf"
 
+	"github.com/hyperledger/fabric/consensus"
+	"github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/crypto/primitives"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
+// reconfigurationWindow is how long a signed reconfiguration request is
+// accepted after it was created
+const reconfigurationWindow = 5 * time.Minute
+
 var log = logging.MustGetLogger("server")
 
 // NewAdminServer creates and returns a Admin service instance.
@@ -39,6 +50,27 @@ func NewAdminServer() *ServerAdmin {
 
 // ServerAdmin implementation of the Admin service for the Peer
 type ServerAdmin struct {
+	reconfigurer   consensus.Reconfigurer
+	statusReporter consensus.StatusReporter
+	secHelper      crypto.Peer
+}
+
+// SetReconfigurer sets the consensus plugin which changes the validator set
+// of the network, validating peers only
+func (s *ServerAdmin) SetReconfigurer(reconfigurer consensus.Reconfigurer) {
+	s.reconfigurer = reconfigurer
+}
+
+// SetSecHelper sets the security helper which authenticates the
+// administrators of the peer, when security is enabled
+func (s *ServerAdmin) SetSecHelper(secHelper crypto.Peer) {
+	s.secHelper = secHelper
+}
+
+// SetStatusReporter sets the consensus plugin which reports its state and
+// metrics, validating peers only
+func (s *ServerAdmin) SetStatusReporter(statusReporter consensus.StatusReporter) {
+	s.statusReporter = statusReporter
 }
 
 func worker(id int, die chan struct{}) {
@@ -79,3 +111,70 @@ func (*ServerAdmin) StopServer(context.Context, *google_protobuf.Empty) (*pb.Ser
 	defer os.Exit(0)
 	return status, nil
 }
+
+// ReconfigureValidators submits the replacement of the validator set of the
+// network to consensus
+func (s *ServerAdmin) ReconfigureValidators(ctx context.Context, validatorSet *pb.ValidatorSet) (*google_protobuf.Empty, error) {
+	if s.reconfigurer == nil {
+		return nil, errors.New("The validator set can only be changed through a validating peer whose consensus plugin supports it")
+	}
+	admin, err := s.authenticateAdmin(validatorSet)
+	if err != nil {
+		log.Warningf("Rejecting reconfiguration of the validators: %s", err)
+		return nil, err
+	}
+	log.Infof("Reconfiguring validators to %v as requested by %s", validatorSet.Validators, admin)
+	if err := s.reconfigurer.Reconfigure(validatorSet.Validators); err != nil {
+		return nil, err
+	}
+	return &google_protobuf.Empty{}, nil
+}
+
+// authenticateAdmin checks that the reconfiguration request is recent and
+// signed by the enrollment key of one of the administrators listed in
+// peer.validator.admins, and returns the enrollment ID of the administrator
+func (s *ServerAdmin) authenticateAdmin(validatorSet *pb.ValidatorSet) (string, error) {
+	if s.secHelper == nil {
+		return "", errors.New("The validator set can only be changed when security is enabled")
+	}
+
+	if validatorSet.Timestamp == nil {
+		return "", errors.New("The request has no timestamp")
+	}
+	created := time.Unix(validatorSet.Timestamp.Seconds, int64(validatorSet.Timestamp.Nanos))
+	if age := time.Since(created); age > reconfigurationWindow || age < -reconfigurationWindow {
+		return "", fmt.Errorf("The request was created at %s, requests are only accepted for %s", created, reconfigurationWindow)
+	}
+
+	// The certificate is retrieved from the ECA by its hash, so it must
+	// have been issued by the ECA and must not have been revoked
+	unsigned := *validatorSet
+	unsigned.Signature = nil
+	raw, err := proto.Marshal(&unsigned)
+	if err != nil {
+		return "", err
+	}
+	if err = s.secHelper.Verify(primitives.Hash(validatorSet.Cert), validatorSet.Signature, raw); err != nil {
+		return "", fmt.Errorf("The request is not signed by an enrolled user: %s", err)
+	}
+
+	cert, err := primitives.DERToX509Certificate(validatorSet.Cert)
+	if err != nil {
+		return "", err
+	}
+	admin := cert.Subject.CommonName
+	for _, id := range viper.GetStringSlice("peer.validator.admins") {
+		if id == admin {
+			return admin, nil
+		}
+	}
+	return "", fmt.Errorf("User %s is not an administrator of this peer", admin)
+}
+
+// GetConsensusStatus returns the state and metrics of the consensus plugin
+func (s *ServerAdmin) GetConsensusStatus(context.Context, *google_protobuf.Empty) (*pb.ConsensusStatus, error) {
+	if s.statusReporter == nil {
+		return nil, errors.New("The consensus status is only available from a validating peer")
+	}
+	return s.statusReporter.GetConsensusStatus()
+}
@@ -16,9 +16,127 @@ limitations under the License.
 
 package core
 
-import "testing"
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+
+	"google/protobuf"
+
+	"github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	pb "github.com/hyperledger/fabric/protos"
+)
 
 func TestServer_Status(t *testing.T) {
 	t.Skip("TBD")
 	//performHandshake(t, peerClientConn)
 }
+
+type mockReconfigurer struct {
+	validators []*pb.PeerID
+}
+
+func (r *mockReconfigurer) Reconfigure(validators []*pb.PeerID) error {
+	r.validators = validators
+	return nil
+}
+
+// mockAdminSecHelper accepts the signatures of the given certificate
+type mockAdminSecHelper struct {
+	crypto.Peer
+	cert []byte
+}
+
+func (h *mockAdminSecHelper) Verify(vkID, signature, message []byte) error {
+	if !bytes.Equal(vkID, primitives.Hash(h.cert)) || !bytes.Equal(signature, primitives.Hash(message)) {
+		return errors.New("Invalid signature")
+	}
+	return nil
+}
+
+func newAdminCertificate(t *testing.T, enrollID string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: enrollID},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func newSignedValidatorSet(cert []byte, created time.Time) *pb.ValidatorSet {
+	validatorSet := &pb.ValidatorSet{
+		Validators: []*pb.PeerID{{Name: "vp0"}, {Name: "vp1"}},
+		Timestamp:  &google_protobuf.Timestamp{Seconds: created.Unix()},
+		Cert:       cert,
+	}
+	raw, _ := proto.Marshal(validatorSet)
+	validatorSet.Signature = primitives.Hash(raw)
+	return validatorSet
+}
+
+func TestReconfigureValidatorsAuthorization(t *testing.T) {
+	primitives.SetSecurityLevel("SHA3", 256)
+	viper.Set("peer.validator.admins", []string{"admin"})
+	defer viper.Set("peer.validator.admins", nil)
+
+	adminCert := newAdminCertificate(t, "admin")
+	userCert := newAdminCertificate(t, "user")
+	reconfigurer := &mockReconfigurer{}
+	s := NewAdminServer()
+	s.SetReconfigurer(reconfigurer)
+
+	if _, err := s.ReconfigureValidators(context.Background(), newSignedValidatorSet(adminCert, time.Now())); err == nil {
+		t.Fatal("Reconfiguration should be rejected when security is disabled")
+	}
+
+	s.SetSecHelper(&mockAdminSecHelper{cert: adminCert})
+	tampered := newSignedValidatorSet(adminCert, time.Now())
+	tampered.Validators = tampered.Validators[:1]
+	rejected := map[string]*pb.ValidatorSet{
+		"tampered": tampered,
+		"stale":    newSignedValidatorSet(adminCert, time.Now().Add(-time.Hour)),
+		"unsigned": {Validators: tampered.Validators, Timestamp: tampered.Timestamp, Cert: adminCert},
+	}
+	for name, validatorSet := range rejected {
+		if _, err := s.ReconfigureValidators(context.Background(), validatorSet); err == nil {
+			t.Errorf("The %s request should be rejected", name)
+		}
+	}
+
+	s.SetSecHelper(&mockAdminSecHelper{cert: userCert})
+	if _, err := s.ReconfigureValidators(context.Background(), newSignedValidatorSet(userCert, time.Now())); err == nil {
+		t.Error("Reconfiguration should be rejected when requested by a user who is not an administrator")
+	}
+	if reconfigurer.validators != nil {
+		t.Fatalf("No reconfiguration should have been submitted, got %v", reconfigurer.validators)
+	}
+
+	s.SetSecHelper(&mockAdminSecHelper{cert: adminCert})
+	if _, err := s.ReconfigureValidators(context.Background(), newSignedValidatorSet(adminCert, time.Now())); err != nil {
+		t.Fatalf("Reconfiguration by the administrator failed: %s", err)
+	}
+	if len(reconfigurer.validators) != 2 {
+		t.Errorf("Expected the reconfiguration to be submitted, got %v", reconfigurer.validators)
+	}
+}
@@ -46,8 +46,11 @@ const (
 	// DevModeUserRunsChaincode property allows user to run chaincode in development environment
 	DevModeUserRunsChaincode       string = "dev"
 	chaincodeStartupTimeoutDefault int    = 5000
+	chaincodeExecuteTimeoutDefault int    = 30000
+	maxInvocationDepthDefault      int    = 8
 	chaincodeInstallPathDefault    string = "/opt/gopath/bin/"
 	peerAddressDefault             string = "0.0.0.0:30303"
+	processVMType                  string = "process"
 )
 
 // chains is a map between different blockchains and their ChaincodeSupport.
@@ -62,6 +65,8 @@ func init() {
 //This is where the VM that's running the chaincode would hook in
 type chaincodeRTEnv struct {
 	handler *Handler
+	//version of the code the container was launched from (see ccintf.CCID)
+	version string
 }
 
 // runningChaincodes contains maps of chaincodeIDs to their chaincodeRTEs
@@ -77,12 +82,12 @@ func GetChain(name ChainName) *ChaincodeSupport {
 }
 
 //call this under lock
-func (chaincodeSupport *ChaincodeSupport) preLaunchSetup(chaincode string) chan bool {
+func (chaincodeSupport *ChaincodeSupport) preLaunchSetup(chaincode string, version string) chan bool {
 	//register placeholder Handler. This will be transferred in registerHandler
 	//NOTE: from this point, existence of handler for this chaincode means the chaincode
 	//is in the process of getting started (or has been started)
 	notfy := make(chan bool, 1)
-	chaincodeSupport.runningChaincodes.chaincodeMap[chaincode] = &chaincodeRTEnv{handler: &Handler{readyNotify: notfy}}
+	chaincodeSupport.runningChaincodes.chaincodeMap[chaincode] = &chaincodeRTEnv{handler: &Handler{readyNotify: notfy}, version: version}
 	return notfy
 }
 
@@ -117,8 +122,24 @@ func NewChaincodeSupport(chainname ChainName, getPeerEndpoint func() (*pb.PeerEn
 
 	s.userRunsCC = userrunsCC
 
+	s.processVM = viper.GetString("vm.type") == processVMType
+
 	s.ccStartupTimeout = ccstartuptimeout
 
+	if to := viper.GetInt("chaincode.executetimeout"); to > 0 {
+		s.executeTimeout = time.Duration(to) * time.Millisecond
+	} else {
+		s.executeTimeout = time.Duration(chaincodeExecuteTimeoutDefault) * time.Millisecond
+	}
+
+	s.checkDeterminism = viper.GetBool("chaincode.checkdeterminism")
+
+	if depth := viper.GetInt("chaincode.maxinvocationdepth"); depth > 0 {
+		s.maxInvocationDepth = depth
+	} else {
+		s.maxInvocationDepth = maxInvocationDepthDefault
+	}
+
 	//TODO I'm not sure if this needs to be on a per chain basis... too lowel and just needs to be a global default ?
 	s.chaincodeInstallPath = viper.GetString("chaincode.installpath")
 	if s.chaincodeInstallPath == "" {
@@ -162,8 +183,12 @@ type ChaincodeSupport struct {
 	runningChaincodes    *runningChaincodes
 	peerAddress          string
 	ccStartupTimeout     time.Duration
+	executeTimeout       time.Duration
+	maxInvocationDepth   int
+	checkDeterminism     bool
 	chaincodeInstallPath string
 	userRunsCC           bool
+	processVM            bool
 	secHelper            crypto.Peer
 	peerNetworkID        string
 	peerID               string
@@ -277,8 +302,8 @@ func (chaincodeSupport *ChaincodeSupport) sendInitOrReady(context context.Contex
 	return err
 }
 
-//get args and env given chaincodeID
-func (chaincodeSupport *ChaincodeSupport) getArgsAndEnv(cID *pb.ChaincodeID, cLang pb.ChaincodeSpec_Type) (args []string, envs []string, err error) {
+//get args and env given chaincodeID and the version of its code
+func (chaincodeSupport *ChaincodeSupport) getArgsAndEnv(cID *pb.ChaincodeID, version string, cLang pb.ChaincodeSpec_Type) (args []string, envs []string, err error) {
 	envs = []string{"CORE_CHAINCODE_ID_NAME=" + cID.Name}
 	//if TLS is enabled, pass TLS material to chaincode
 	if chaincodeSupport.peerTLS {
@@ -292,8 +317,13 @@ func (chaincodeSupport *ChaincodeSupport) getArgsAndEnv(cID *pb.ChaincodeID, cLa
 	}
 	switch cLang {
 	case pb.ChaincodeSpec_GOLANG, pb.ChaincodeSpec_CAR:
-		//chaincode executable will be same as the name of the chaincode
-		args = []string{chaincodeSupport.chaincodeInstallPath + cID.Name, fmt.Sprintf("-peer.address=%s", chaincodeSupport.peerAddress)}
+		//chaincode executable will be same as the name of the chaincode, or
+		//the hash of its new code once upgraded
+		executable := cID.Name
+		if version != "" {
+			executable = version
+		}
+		args = []string{chaincodeSupport.chaincodeInstallPath + executable, fmt.Sprintf("-peer.address=%s", chaincodeSupport.peerAddress)}
 		chaincodeLogger.Debugf("Executable is %s", args[0])
 	case pb.ChaincodeSpec_JAVA:
 		//TODO add security args
@@ -309,7 +339,7 @@ func (chaincodeSupport *ChaincodeSupport) getArgsAndEnv(cID *pb.ChaincodeID, cLa
 }
 
 // launchAndWaitForRegister will launch container if not already running. Use the targz to create the image if not found
-func (chaincodeSupport *ChaincodeSupport) launchAndWaitForRegister(ctxt context.Context, cds *pb.ChaincodeDeploymentSpec, cID *pb.ChaincodeID, uuid string, cLang pb.ChaincodeSpec_Type, targz io.Reader) (bool, error) {
+func (chaincodeSupport *ChaincodeSupport) launchAndWaitForRegister(ctxt context.Context, cds *pb.ChaincodeDeploymentSpec, cID *pb.ChaincodeID, version string, uuid string, cLang pb.ChaincodeSpec_Type, targz io.Reader) (bool, error) {
 	chaincode := cID.Name
 	if chaincode == "" {
 		return false, fmt.Errorf("chaincode name not set")
@@ -325,12 +355,12 @@ func (chaincodeSupport *ChaincodeSupport) launchAndWaitForRegister(ctxt context.
 	}
 	alreadyRunning := false
 
-	notfy := chaincodeSupport.preLaunchSetup(chaincode)
+	notfy := chaincodeSupport.preLaunchSetup(chaincode, version)
 	chaincodeSupport.runningChaincodes.Unlock()
 
 	//launch the chaincode
 
-	args, env, err := chaincodeSupport.getArgsAndEnv(cID, cLang)
+	args, env, err := chaincodeSupport.getArgsAndEnv(cID, version, cLang)
 	if err != nil {
 		return alreadyRunning, err
 	}
@@ -339,7 +369,7 @@ func (chaincodeSupport *ChaincodeSupport) launchAndWaitForRegister(ctxt context.
 
 	vmtype, _ := chaincodeSupport.getVMType(cds)
 
-	sir := container.StartImageReq{CCID: ccintf.CCID{ChaincodeSpec: cds.ChaincodeSpec, NetworkID: chaincodeSupport.peerNetworkID, PeerID: chaincodeSupport.peerID}, Reader: targz, Args: args, Env: env}
+	sir := container.StartImageReq{CCID: ccintf.CCID{ChaincodeSpec: cds.ChaincodeSpec, NetworkID: chaincodeSupport.peerNetworkID, PeerID: chaincodeSupport.peerID, Version: version}, Reader: targz, Args: args, Env: env}
 
 	ipcCtxt := context.WithValue(ctxt, ccintf.GetCCHandlerKey(), chaincodeSupport)
 
@@ -381,8 +411,16 @@ func (chaincodeSupport *ChaincodeSupport) Stop(context context.Context, cds *pb.
 		return fmt.Errorf("chaincode name not set")
 	}
 
+	//the container is named after the version it was launched from
+	var version string
+	chaincodeSupport.runningChaincodes.Lock()
+	if chrte, ok := chaincodeSupport.chaincodeHasBeenLaunched(chaincode); ok {
+		version = chrte.version
+	}
+	chaincodeSupport.runningChaincodes.Unlock()
+
 	//stop the chaincode
-	sir := container.StopImageReq{CCID: ccintf.CCID{ChaincodeSpec: cds.ChaincodeSpec, NetworkID: chaincodeSupport.peerNetworkID, PeerID: chaincodeSupport.peerID}, Timeout: 0}
+	sir := container.StopImageReq{CCID: ccintf.CCID{ChaincodeSpec: cds.ChaincodeSpec, NetworkID: chaincodeSupport.peerNetworkID, PeerID: chaincodeSupport.peerID, Version: version}, Timeout: 0}
 
 	vmtype, _ := chaincodeSupport.getVMType(cds)
 
@@ -416,7 +454,7 @@ func (chaincodeSupport *ChaincodeSupport) Launch(context context.Context, t *pb.
 	var initargs []string
 
 	cds := &pb.ChaincodeDeploymentSpec{}
-	if t.Type == pb.Transaction_CHAINCODE_DEPLOY {
+	if t.Type == pb.Transaction_CHAINCODE_DEPLOY || t.Type == pb.Transaction_CHAINCODE_UPGRADE {
 		err := proto.Unmarshal(t.Payload, cds)
 		if err != nil {
 			return nil, nil, err
@@ -461,6 +499,7 @@ func (chaincodeSupport *ChaincodeSupport) Launch(context context.Context, t *pb.
 	chaincodeSupport.runningChaincodes.Unlock()
 
 	var depTx *pb.Transaction
+	version := getChaincodeVersion(t)
 
 	//extract depTx so we can initialize hander.deployTXSecContext
 	//we need it only after container is launched and only if this is not a deploy tx
@@ -474,7 +513,7 @@ func (chaincodeSupport *ChaincodeSupport) Launch(context context.Context, t *pb.
 	//         5) query successfully retrives committed tx and calls sendInitOrReady
 	// See issue #710
 
-	if t.Type != pb.Transaction_CHAINCODE_DEPLOY {
+	if t.Type != pb.Transaction_CHAINCODE_DEPLOY && t.Type != pb.Transaction_CHAINCODE_UPGRADE {
 		ledger, ledgerErr := ledger.GetLedger()
 
 		if chaincodeSupport.userRunsCC {
@@ -486,13 +525,11 @@ func (chaincodeSupport *ChaincodeSupport) Launch(context context.Context, t *pb.
 		}
 
 		//hopefully we are restarting from existing image and the deployed transaction exists
-		depTx, ledgerErr = ledger.GetTransactionByUUID(chaincode)
+		depTx, ledgerErr = getDeploymentTransaction(ledger, chaincode)
 		if ledgerErr != nil {
-			return cID, cMsg, fmt.Errorf("Could not get deployment transaction for %s - %s", chaincode, ledgerErr)
-		}
-		if depTx == nil {
-			return cID, cMsg, fmt.Errorf("deployment transaction does not exist for %s", chaincode)
+			return cID, cMsg, ledgerErr
 		}
+		version = getChaincodeVersion(depTx)
 		if nil != chaincodeSupport.secHelper {
 			var err error
 			depTx, err = chaincodeSupport.secHelper.TransactionPreExecution(depTx)
@@ -514,7 +551,7 @@ func (chaincodeSupport *ChaincodeSupport) Launch(context context.Context, t *pb.
 	//launch container if it is a System container or not in dev mode
 	if (!chaincodeSupport.userRunsCC || cds.ExecEnv == pb.ChaincodeDeploymentSpec_SYSTEM) && (chrte == nil || chrte.handler == nil) {
 		var targz io.Reader = bytes.NewBuffer(cds.CodePackage)
-		_, err = chaincodeSupport.launchAndWaitForRegister(context, cds, cID, t.Uuid, cLang, targz)
+		_, err = chaincodeSupport.launchAndWaitForRegister(context, cds, cID, version, t.Uuid, cLang, targz)
 		if err != nil {
 			chaincodeLogger.Errorf("launchAndWaitForRegister failed %s", err)
 			return cID, cMsg, err
@@ -546,11 +583,14 @@ func (chaincodeSupport *ChaincodeSupport) getSecHelper() crypto.Peer {
 }
 
 //getVMType - just returns a string for now. Another possibility is to use a factory method to
-//return a VM executor
+//return a VM executor. Only Go chaincodes can be run as a local process, others still need docker
 func (chaincodeSupport *ChaincodeSupport) getVMType(cds *pb.ChaincodeDeploymentSpec) (string, error) {
 	if cds.ExecEnv == pb.ChaincodeDeploymentSpec_SYSTEM {
 		return container.SYSTEM, nil
 	}
+	if chaincodeSupport.processVM && cds.ChaincodeSpec.Type == pb.ChaincodeSpec_GOLANG {
+		return container.PROCESS, nil
+	}
 	return container.DOCKER, nil
 }
 
@@ -583,13 +623,14 @@ func (chaincodeSupport *ChaincodeSupport) Deploy(context context.Context, t *pb.
 	}
 	chaincodeSupport.runningChaincodes.Unlock()
 
-	args, envs, err := chaincodeSupport.getArgsAndEnv(cID, cLang)
+	version := getChaincodeVersion(t)
+	args, envs, err := chaincodeSupport.getArgsAndEnv(cID, version, cLang)
 	if err != nil {
 		return cds, fmt.Errorf("error getting args for chaincode %s", err)
 	}
 
 	var targz io.Reader = bytes.NewBuffer(cds.CodePackage)
-	cir := &container.CreateImageReq{CCID: ccintf.CCID{ChaincodeSpec: cds.ChaincodeSpec, NetworkID: chaincodeSupport.peerNetworkID, PeerID: chaincodeSupport.peerID}, Args: args, Reader: targz, Env: envs}
+	cir := &container.CreateImageReq{CCID: ccintf.CCID{ChaincodeSpec: cds.ChaincodeSpec, NetworkID: chaincodeSupport.peerNetworkID, PeerID: chaincodeSupport.peerID, Version: version}, Args: args, Reader: targz, Env: envs}
 
 	vmtype, _ := chaincodeSupport.getVMType(cds)
 
@@ -604,6 +645,114 @@ func (chaincodeSupport *ChaincodeSupport) Deploy(context context.Context, t *pb.
 	return cds, err
 }
 
+// Upgrade replaces the code of a deployed chaincode with the code carried by the upgrade transaction.
+// The chaincode keeps its name and therefore its state. The running container is stopped, the new
+// code is deployed and launched, and Init is called on it so it can migrate the state it inherits.
+func (chaincodeSupport *ChaincodeSupport) Upgrade(context context.Context, t *pb.Transaction) (*pb.ChaincodeDeploymentSpec, error) {
+	cds := &pb.ChaincodeDeploymentSpec{}
+	err := proto.Unmarshal(t.Payload, cds)
+	if err != nil {
+		return nil, err
+	}
+	if cds.ChaincodeSpec == nil || cds.ChaincodeSpec.ChaincodeID == nil || cds.ChaincodeSpec.ChaincodeID.Name == "" {
+		return cds, fmt.Errorf("chaincode name not set")
+	}
+	chaincode := cds.ChaincodeSpec.ChaincodeID.Name
+	if cds.ExecEnv == pb.ChaincodeDeploymentSpec_SYSTEM {
+		return cds, fmt.Errorf("system chaincode %s cannot be upgraded", chaincode)
+	}
+
+	lgr, err := ledger.GetLedger()
+	if err != nil {
+		return cds, fmt.Errorf("Failed to get handle to ledger (%s)", err)
+	}
+
+	//only a running chaincode can be upgraded, by its deployer
+	depTx, err := getDeploymentTransaction(lgr, chaincode)
+	if err != nil {
+		return cds, err
+	}
+	if err = checkChaincodeDeployer(chaincode, depTx, t); err != nil {
+		return cds, err
+	}
+
+	//the upgrade transaction is named after the hash of the new code, which must not have been
+	//deployed before
+	if _, err = lgr.GetTransactionByUUID(t.Uuid); err != ledger.ErrResourceNotFound {
+		return cds, fmt.Errorf("code %s has already been deployed", t.Uuid)
+	}
+
+	//in development mode the user restarts the chaincode with the new code
+	if !chaincodeSupport.userRunsCC {
+		if err = chaincodeSupport.Stop(context, cds); err != nil {
+			chaincodeLogger.Debugf("error stopping %s for upgrade(%s)", chaincode, err)
+		}
+	}
+
+	if _, err = chaincodeSupport.Deploy(context, t); err != nil {
+		return cds, err
+	}
+
+	if err = setChaincodeLifecycle(lgr, chaincode, &pb.ChaincodeLifecycle{DeploymentUuid: t.Uuid}); err != nil {
+		return cds, err
+	}
+
+	_, _, err = chaincodeSupport.Launch(context, t)
+
+	return cds, err
+}
+
+// Terminate stops the chaincode named by the terminate transaction and marks it as terminated in the
+// ledger, after which it can no longer be invoked, queried or upgraded.
+func (chaincodeSupport *ChaincodeSupport) Terminate(context context.Context, t *pb.Transaction) error {
+	ci := &pb.ChaincodeInvocationSpec{}
+	err := proto.Unmarshal(t.Payload, ci)
+	if err != nil {
+		return err
+	}
+	if ci.ChaincodeSpec == nil || ci.ChaincodeSpec.ChaincodeID == nil || ci.ChaincodeSpec.ChaincodeID.Name == "" {
+		return fmt.Errorf("chaincode name not set")
+	}
+	chaincode := ci.ChaincodeSpec.ChaincodeID.Name
+
+	lgr, err := ledger.GetLedger()
+	if err != nil {
+		return fmt.Errorf("Failed to get handle to ledger (%s)", err)
+	}
+
+	depTx, err := getDeploymentTransaction(lgr, chaincode)
+	if err != nil {
+		return err
+	}
+	if err = checkChaincodeDeployer(chaincode, depTx, t); err != nil {
+		return err
+	}
+	if nil != chaincodeSupport.secHelper {
+		depTx, err = chaincodeSupport.secHelper.TransactionPreExecution(depTx)
+		if nil != err {
+			return fmt.Errorf("failed tx preexecution%s - %s", chaincode, err)
+		}
+	}
+	cds := &pb.ChaincodeDeploymentSpec{}
+	if err = proto.Unmarshal(depTx.Payload, cds); err != nil {
+		return fmt.Errorf("failed to unmarshal deployment transactions for %s - %s", chaincode, err)
+	}
+	if cds.ExecEnv == pb.ChaincodeDeploymentSpec_SYSTEM {
+		return fmt.Errorf("system chaincode %s cannot be terminated", chaincode)
+	}
+
+	if err = setChaincodeLifecycle(lgr, chaincode, &pb.ChaincodeLifecycle{DeploymentUuid: depTx.Uuid, Status: pb.ChaincodeLifecycle_TERMINATED}); err != nil {
+		return err
+	}
+
+	//the chaincode need not be running on this peer, so failing to stop it does not fail the transaction
+	if err = chaincodeSupport.Stop(context, cds); err != nil {
+		chaincodeLogger.Debugf("error stopping terminated chaincode %s(%s)", chaincode, err)
+	}
+
+	return nil
+}
+
 // HandleChaincodeStream implements ccintf.HandleChaincodeStream for all vms to call with appropriate stream
 func (chaincodeSupport *ChaincodeSupport) HandleChaincodeStream(ctxt context.Context, stream ccintf.ChaincodeStream) error {
 	return HandleChaincodeStream(chaincodeSupport, ctxt, stream)
@@ -633,7 +782,9 @@ func createQueryMessage(uuid string, cMsg *pb.ChaincodeInput) (*pb.ChaincodeMess
 	return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_QUERY, Payload: payload, Uuid: uuid}, nil
 }
 
-// Execute executes a transaction and waits for it to complete until a timeout value.
+// Execute executes a transaction and waits for it to complete until a timeout value. The timeout
+// declared when the chaincode was deployed, if any, can only shorten the given one, and a chaincode
+// invoked by another one completes within the time left to its caller.
 func (chaincodeSupport *ChaincodeSupport) Execute(ctxt context.Context, chaincode string, msg *pb.ChaincodeMessage, timeout time.Duration, tx *pb.Transaction) (*pb.ChaincodeMessage, error) {
 	chaincodeSupport.runningChaincodes.Lock()
 	//we expect the chaincode to be running... sanity check
@@ -645,9 +796,15 @@ func (chaincodeSupport *ChaincodeSupport) Execute(ctxt context.Context, chaincod
 	}
 	chaincodeSupport.runningChaincodes.Unlock()
 
+	if chrte.handler.executeTimeout > 0 && chrte.handler.executeTimeout < timeout {
+		timeout = chrte.handler.executeTimeout
+	}
+	invocation := getInvocationContext(ctxt, chaincode)
+	timeout = invocation.setDeadline(timeout)
+
 	var notfy chan *pb.ChaincodeMessage
 	var err error
-	if notfy, err = chrte.handler.sendExecuteMessage(msg, tx); err != nil {
+	if notfy, err = chrte.handler.sendExecuteMessage(msg, tx, invocation); err != nil {
 		return nil, fmt.Errorf("Error sending %s: %s", msg.Type.String(), err)
 	}
 	var ccresp *pb.ChaincodeMessage
@@ -656,7 +813,7 @@ func (chaincodeSupport *ChaincodeSupport) Execute(ctxt context.Context, chaincod
 		//response is sent to user or calling chaincode. ChaincodeMessage_ERROR and ChaincodeMessage_QUERY_ERROR
 		//are typically treated as error
 	case <-time.After(timeout):
-		err = fmt.Errorf("Timeout expired while executing transaction")
+		err = fmt.Errorf("Timeout expired while executing transaction: chaincode %s did not complete within %s", chaincode, timeout)
 	}
 
 	//our responsibility to delete transaction context if sendExecuteMessage succeeded
@@ -0,0 +1,334 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"golang.org/x/net/context"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/util"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// stateKey identifies a key in the state of a chaincode
+type stateKey struct {
+	chaincodeID string
+	key         string
+}
+
+// simulatedValue is the value written by a simulated transaction, nil if the key was deleted
+type simulatedValue struct {
+	value []byte
+}
+
+func hashValue(value []byte) []byte {
+	if value == nil {
+		return nil
+	}
+	return util.ComputeCryptoHash(value)
+}
+
+// read records a key read by a chaincode of the transaction
+func (recorder *invocationRecorder) read(chaincodeID string, key string, value []byte) {
+	recorder.Lock()
+	defer recorder.Unlock()
+	recorder.reads = append(recorder.reads, &pb.StateRead{ChaincodeID: chaincodeID, Key: key, ValueHash: hashValue(value)})
+}
+
+// write records a key put or deleted by a chaincode of the transaction. The value is kept when
+// the transaction is simulated, as it is not written to the ledger.
+func (recorder *invocationRecorder) write(chaincodeID string, key string, value []byte, isDelete bool) {
+	recorder.Lock()
+	defer recorder.Unlock()
+	recorder.writes = append(recorder.writes, &pb.StateWrite{ChaincodeID: chaincodeID, Key: key, ValueHash: hashValue(value), IsDelete: isDelete})
+	if recorder.simulation != nil {
+		recorder.simulation[stateKey{chaincodeID, key}] = &simulatedValue{value}
+	}
+}
+
+// simulating returns true if the state written by the transaction is kept from the ledger
+func (recorder *invocationRecorder) simulating() bool {
+	recorder.Lock()
+	defer recorder.Unlock()
+	return recorder.simulation != nil
+}
+
+// getSimulated returns the value the simulated transaction wrote to the key, if it did
+func (recorder *invocationRecorder) getSimulated(chaincodeID string, key string) ([]byte, bool) {
+	recorder.Lock()
+	defer recorder.Unlock()
+	written, ok := recorder.simulation[stateKey{chaincodeID, key}]
+	if !ok {
+		return nil, false
+	}
+	return written.value, true
+}
+
+// getReadWriteSet returns the state read and written by the transaction, nil if it did not
+// access the state
+func (recorder *invocationRecorder) getReadWriteSet() *pb.ReadWriteSet {
+	recorder.Lock()
+	defer recorder.Unlock()
+	if len(recorder.reads) == 0 && len(recorder.writes) == 0 {
+		return nil
+	}
+	return &pb.ReadWriteSet{Reads: recorder.reads, Writes: recorder.writes}
+}
+
+// Simulate executes an invoke or query transaction, and the chaincodes it invokes, without
+// changing the state. It returns the result of the transaction and the state it read and would
+// have written, which is also returned when the chaincode fails. Range queries do not see the
+// state written by the simulated transaction.
+func Simulate(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) ([]byte, *pb.ReadWriteSet, error) {
+	if t.Type != pb.Transaction_CHAINCODE_INVOKE && t.Type != pb.Transaction_CHAINCODE_QUERY {
+		return nil, nil, fmt.Errorf("Cannot simulate %s transactions", t.Type)
+	}
+
+	if secHelper := chain.getSecHelper(); nil != secHelper {
+		var err error
+		// Note that t is now decrypted and is a deep clone of the original input t
+		if t, err = secHelper.TransactionPreExecution(t); nil != err {
+			return nil, nil, err
+		}
+	}
+
+	recorder := &invocationRecorder{simulation: make(map[stateKey]*simulatedValue)}
+	ctxt = withInvocationRecorder(ctxt, recorder)
+
+	//will launch if necessary (and wait for ready)
+	cID, cMsg, err := chain.Launch(ctxt, t)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to launch chaincode spec(%s)", err)
+	}
+
+	var ccMsg *pb.ChaincodeMessage
+	if t.Type == pb.Transaction_CHAINCODE_INVOKE {
+		ccMsg, err = createTransactionMessage(t.Uuid, cMsg)
+	} else {
+		ccMsg, err = createQueryMessage(t.Uuid, cMsg)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to create message(%s)", err)
+	}
+
+	resp, err := chain.Execute(ctxt, cID.Name, ccMsg, chain.executeTimeout, t)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to simulate transaction or query(%s)", err)
+	} else if resp == nil {
+		return nil, nil, fmt.Errorf("Failed to receive a response for (%s)", t.Uuid)
+	}
+
+	switch resp.Type {
+	case pb.ChaincodeMessage_COMPLETED, pb.ChaincodeMessage_QUERY_COMPLETED:
+		return resp.Payload, recorder.getReadWriteSet(), nil
+	case pb.ChaincodeMessage_ERROR, pb.ChaincodeMessage_QUERY_ERROR:
+		return nil, recorder.getReadWriteSet(), fmt.Errorf("Transaction or query returned with failure: %s", string(resp.Payload))
+	}
+	return nil, nil, fmt.Errorf("receive a response for (%s) but in invalid state(%d)", t.Uuid, resp.Type)
+}
+
+// CheckDeterminism simulates the transaction twice against the current state and reports how
+// the two executions differ and, if original is given, how the first one differs from the
+// execution recorded in the ledger. The recorded execution read the state as of its block, the
+// keys it read that hold other values now are reported as state changes: differences from the
+// recorded execution are then expected and do not tell the transaction is not deterministic.
+func CheckDeterminism(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction, original *pb.TransactionResult) (*pb.DeterminismReport, error) {
+	report := &pb.DeterminismReport{Uuid: t.Uuid}
+	var results [2][]byte
+	var errs [2]error
+	for i := range results {
+		var rwset *pb.ReadWriteSet
+		results[i], rwset, errs[i] = Simulate(ctxt, chain, t)
+		if errs[i] != nil && rwset == nil {
+			return nil, errs[i]
+		}
+		report.Executions = append(report.Executions, rwset)
+	}
+
+	report.Differences = diffExecutions(results[0], errs[0], report.Executions[0], results[1], errs[1], report.Executions[1])
+	report.Deterministic = len(report.Differences) == 0
+	if original == nil {
+		return report, nil
+	}
+
+	stateChanges, err := getStateChanges(original.ReadWriteSet)
+	if err != nil {
+		return nil, err
+	}
+	report.Original = original.ReadWriteSet
+	report.OriginalDifferences = diffOriginalExecution(original, results[0], errs[0], report.Executions[0])
+	report.StateChanges = stateChanges
+	if len(report.StateChanges) == 0 && len(report.OriginalDifferences) > 0 {
+		report.Deterministic = false
+	}
+	return report, nil
+}
+
+// getStateChanges describes the keys read by a recorded execution that hold other values now.
+// Only the first read of each key is compared, the next ones may return what the transaction
+// wrote.
+func getStateChanges(original *pb.ReadWriteSet) ([]string, error) {
+	if len(original.GetReads()) == 0 {
+		return nil, nil
+	}
+	lgr, err := ledger.GetLedger()
+	if err != nil {
+		return nil, err
+	}
+	var changes []string
+	compared := make(map[stateKey]bool)
+	for _, r := range original.Reads {
+		k := stateKey{r.ChaincodeID, r.Key}
+		if compared[k] {
+			continue
+		}
+		compared[k] = true
+		value, err := lgr.GetState(r.ChaincodeID, r.Key, true)
+		if err != nil {
+			return nil, err
+		}
+		if current := hashValue(value); !bytes.Equal(current, r.ValueHash) {
+			changes = append(changes, fmt.Sprintf("%s key %q was read with %s by the recorded execution and holds %s now", r.ChaincodeID, r.Key, describeValue(r.ValueHash, false), describeValue(current, false)))
+		}
+	}
+	return changes, nil
+}
+
+// diffOriginalExecution describes how an execution differs from the one recorded in the ledger.
+// The errors are not compared, the messages of the recorded executions are worded differently.
+func diffOriginalExecution(original *pb.TransactionResult, result []byte, err error, rwset *pb.ReadWriteSet) []string {
+	var differences []string
+	if originalFailed := original.ErrorCode != 0; originalFailed != (err != nil) {
+		if originalFailed {
+			differences = append(differences, fmt.Sprintf("the recorded execution failed: %s, the first execution did not", original.Error))
+		} else {
+			differences = append(differences, fmt.Sprintf("the first execution failed: %s, the recorded execution did not", err))
+		}
+	} else if err == nil && !bytes.Equal(original.Result, result) {
+		differences = append(differences, fmt.Sprintf("the recorded execution returned %q and the first execution %q", original.Result, result))
+	}
+	return append(differences, diffReadWriteSets(original.ReadWriteSet, rwset, "recorded", "first")...)
+}
+
+// diffExecutions describes how two executions of a transaction differ
+func diffExecutions(firstResult []byte, firstErr error, first *pb.ReadWriteSet, secondResult []byte, secondErr error, second *pb.ReadWriteSet) []string {
+	var differences []string
+	if (firstErr == nil) != (secondErr == nil) {
+		differences = append(differences, fmt.Sprintf("the executions failed differently: %v, %v", firstErr, secondErr))
+	} else if firstErr != nil && firstErr.Error() != secondErr.Error() {
+		differences = append(differences, fmt.Sprintf("the executions failed differently: %s, %s", firstErr, secondErr))
+	}
+	if !bytes.Equal(firstResult, secondResult) {
+		differences = append(differences, fmt.Sprintf("the executions returned %q and %q", firstResult, secondResult))
+	}
+	return append(differences, diffReadWriteSets(first, second, "first", "second")...)
+}
+
+// describeValue names a value by the beginning of its hash
+func describeValue(valueHash []byte, isDelete bool) string {
+	if isDelete {
+		return "deleted"
+	}
+	if len(valueHash) == 0 {
+		return "no value"
+	}
+	if len(valueHash) > 8 {
+		valueHash = valueHash[:8]
+	}
+	return fmt.Sprintf("value %x", valueHash)
+}
+
+// diffReadWriteSets describes how the state read and written by two executions, named after
+// firstName and secondName, differ. The order of the operations does not matter, only the values
+// read and the values last written.
+func diffReadWriteSets(first *pb.ReadWriteSet, second *pb.ReadWriteSet, firstName string, secondName string) []string {
+	var differences []string
+
+	type stateRead struct {
+		stateKey
+		valueHash string
+	}
+	reads := func(rwset *pb.ReadWriteSet) map[stateRead]bool {
+		m := make(map[stateRead]bool)
+		for _, r := range rwset.GetReads() {
+			m[stateRead{stateKey{r.ChaincodeID, r.Key}, string(r.ValueHash)}] = true
+		}
+		return m
+	}
+	firstReads, secondReads := reads(first), reads(second)
+	for _, r := range first.GetReads() {
+		read := stateRead{stateKey{r.ChaincodeID, r.Key}, string(r.ValueHash)}
+		if !secondReads[read] {
+			differences = append(differences, fmt.Sprintf("%s key %q read with %s by the %s execution only", r.ChaincodeID, r.Key, describeValue(r.ValueHash, false), firstName))
+			secondReads[read] = true
+		}
+	}
+	for _, r := range second.GetReads() {
+		read := stateRead{stateKey{r.ChaincodeID, r.Key}, string(r.ValueHash)}
+		if !firstReads[read] {
+			differences = append(differences, fmt.Sprintf("%s key %q read with %s by the %s execution only", r.ChaincodeID, r.Key, describeValue(r.ValueHash, false), secondName))
+			firstReads[read] = true
+		}
+	}
+
+	writes := func(rwset *pb.ReadWriteSet) map[stateKey]*pb.StateWrite {
+		m := make(map[stateKey]*pb.StateWrite)
+		for _, w := range rwset.GetWrites() {
+			m[stateKey{w.ChaincodeID, w.Key}] = w
+		}
+		return m
+	}
+	firstWrites, secondWrites := writes(first), writes(second)
+	var keys []stateKey
+	for k := range firstWrites {
+		keys = append(keys, k)
+	}
+	for k := range secondWrites {
+		if _, ok := firstWrites[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Sort(stateKeys(keys))
+	for _, k := range keys {
+		w1, ok1 := firstWrites[k]
+		w2, ok2 := secondWrites[k]
+		switch {
+		case !ok2:
+			differences = append(differences, fmt.Sprintf("%s key %q written with %s by the %s execution only", k.chaincodeID, k.key, describeValue(w1.ValueHash, w1.IsDelete), firstName))
+		case !ok1:
+			differences = append(differences, fmt.Sprintf("%s key %q written with %s by the %s execution only", k.chaincodeID, k.key, describeValue(w2.ValueHash, w2.IsDelete), secondName))
+		case w1.IsDelete != w2.IsDelete || !bytes.Equal(w1.ValueHash, w2.ValueHash):
+			differences = append(differences, fmt.Sprintf("%s key %q written with %s by the %s execution and %s by the %s", k.chaincodeID, k.key, describeValue(w1.ValueHash, w1.IsDelete), firstName, describeValue(w2.ValueHash, w2.IsDelete), secondName))
+		}
+	}
+	return differences
+}
+
+type stateKeys []stateKey
+
+func (keys stateKeys) Len() int      { return len(keys) }
+func (keys stateKeys) Swap(i, j int) { keys[i], keys[j] = keys[j], keys[i] }
+func (keys stateKeys) Less(i, j int) bool {
+	if keys[i].chaincodeID != keys[j].chaincodeID {
+		return keys[i].chaincodeID < keys[j].chaincodeID
+	}
+	return keys[i].key < keys[j].key
+}
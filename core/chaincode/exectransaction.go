@@ -17,11 +17,9 @@ limitations under the License.
 package chaincode
 
 import (
-	"errors"
 	"fmt"
-	"time"
+	"strings"
 
-	"github.com/golang/protobuf/proto"
 	"golang.org/x/net/context"
 
 	"github.com/hyperledger/fabric/core/ledger"
@@ -30,7 +28,7 @@ import (
 )
 
 //Execute - execute transaction or a query
-func Execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) ([]byte, *pb.ChaincodeEvent, error) {
+func Execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) ([]byte, []*pb.ChaincodeEvent, error) {
 	var err error
 
 	// get a handle to ledger to mark the begin/finish of a tx
@@ -62,6 +60,23 @@ func Execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) (
 			return nil, nil, fmt.Errorf("%s", err)
 		}
 		markTxFinish(ledger, t, true)
+	} else if t.Type == pb.Transaction_CHAINCODE_UPGRADE {
+		//deploy the new code, launch and wait for ready
+		markTxBegin(ledger, t)
+		_, err = chain.Upgrade(ctxt, t)
+		if err != nil {
+			markTxFinish(ledger, t, false)
+			return nil, nil, fmt.Errorf("Failed to upgrade chaincode spec(%s)", err)
+		}
+		markTxFinish(ledger, t, true)
+	} else if t.Type == pb.Transaction_CHAINCODE_TERMINATE {
+		markTxBegin(ledger, t)
+		err = chain.Terminate(ctxt, t)
+		if err != nil {
+			markTxFinish(ledger, t, false)
+			return nil, nil, fmt.Errorf("Failed to terminate chaincode(%s)", err)
+		}
+		markTxFinish(ledger, t, true)
 	} else if t.Type == pb.Transaction_CHAINCODE_INVOKE || t.Type == pb.Transaction_CHAINCODE_QUERY {
 		//will launch if necessary (and wait for ready)
 		cID, cMsg, err := chain.Launch(ctxt, t)
@@ -76,14 +91,6 @@ func Execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) (
 			return nil, nil, fmt.Errorf("Failed to stablish stream to container %s", chaincode)
 		}
 
-		// TODO: Need to comment next line and uncomment call to getTimeout, when transaction blocks are being created
-		timeout := time.Duration(30000) * time.Millisecond
-		//timeout, err := getTimeout(cID)
-
-		if err != nil {
-			return nil, nil, fmt.Errorf("Failed to retrieve chaincode spec(%s)", err)
-		}
-
 		var ccMsg *pb.ChaincodeMessage
 		if t.Type == pb.Transaction_CHAINCODE_INVOKE {
 			ccMsg, err = createTransactionMessage(t.Uuid, cMsg)
@@ -98,7 +105,7 @@ func Execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) (
 		}
 
 		markTxBegin(ledger, t)
-		resp, err := chain.Execute(ctxt, chaincode, ccMsg, timeout, t)
+		resp, err := chain.Execute(ctxt, chaincode, ccMsg, chain.executeTimeout, t)
 		if err != nil {
 			// Rollback transaction
 			markTxFinish(ledger, t, false)
@@ -108,19 +115,19 @@ func Execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) (
 			markTxFinish(ledger, t, false)
 			return nil, nil, fmt.Errorf("Failed to receive a response for (%s)", t.Uuid)
 		} else {
-			if resp.ChaincodeEvent != nil {
-				resp.ChaincodeEvent.ChaincodeID = chaincode
-				resp.ChaincodeEvent.TxID = t.Uuid
+			for _, ccevent := range resp.ChaincodeEvents {
+				ccevent.ChaincodeID = chaincode
+				ccevent.TxID = t.Uuid
 			}
 
 			if resp.Type == pb.ChaincodeMessage_COMPLETED || resp.Type == pb.ChaincodeMessage_QUERY_COMPLETED {
 				// Success
 				markTxFinish(ledger, t, true)
-				return resp.Payload, resp.ChaincodeEvent, nil
+				return resp.Payload, resp.ChaincodeEvents, nil
 			} else if resp.Type == pb.ChaincodeMessage_ERROR || resp.Type == pb.ChaincodeMessage_QUERY_ERROR {
 				// Rollback transaction
 				markTxFinish(ledger, t, false)
-				return nil, resp.ChaincodeEvent, fmt.Errorf("Transaction or query returned with failure: %s", string(resp.Payload))
+				return nil, resp.ChaincodeEvents, fmt.Errorf("Transaction or query returned with failure: %s", string(resp.Payload))
 			}
 			markTxFinish(ledger, t, false)
 			return resp.Payload, nil, fmt.Errorf("receive a response for (%s) but in invalid state(%d)", t.Uuid, resp.Type)
@@ -133,25 +140,44 @@ func Execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) (
 }
 
 //ExecuteTransactions - will execute transactions on the array one by one
-//will return an array of errors one for each transaction. If the execution
-//succeeded, array element will be nil. returns []byte of state hash or
-//error
-func ExecuteTransactions(ctxt context.Context, cname ChainName, xacts []*pb.Transaction) (succeededTXs []*pb.Transaction, stateHash []byte, ccevents []*pb.ChaincodeEvent, txerrs []error, err error) {
+//will return an array of results one for each transaction, holding the
+//payload returned by the chaincode and the error if the execution failed.
+//returns []byte of state hash or error
+func ExecuteTransactions(ctxt context.Context, cname ChainName, xacts []*pb.Transaction) (succeededTXs []*pb.Transaction, stateHash []byte, txresults []*pb.TransactionResult, err error) {
 	var chain = GetChain(cname)
 	if chain == nil {
 		// TODO: We should never get here, but otherwise a good reminder to better handle
 		panic(fmt.Sprintf("[ExecuteTransactions]Chain %s not found\n", cname))
 	}
 
-	txerrs = make([]error, len(xacts))
-	ccevents = make([]*pb.ChaincodeEvent, len(xacts))
+	txresults = make([]*pb.TransactionResult, len(xacts))
 	var succeededTxs = make([]*pb.Transaction, 0)
 	for i, t := range xacts {
-		_, ccevents[i], txerrs[i] = Execute(ctxt, chain, t)
-		if txerrs[i] == nil {
+		// in debug mode the transaction is simulated first, to compare both executions
+		var simulatedResult []byte
+		var simulated *pb.ReadWriteSet
+		var simulateErr error
+		if chain.checkDeterminism && t.Type == pb.Transaction_CHAINCODE_INVOKE {
+			if simulatedResult, simulated, simulateErr = Simulate(ctxt, chain, t); simulated == nil && simulateErr != nil {
+				chaincodeLogger.Warningf("Failed to simulate transaction %s: %s", t.Uuid, simulateErr)
+			}
+		}
+
+		recorder := &invocationRecorder{}
+		result, ccevents, txerr := Execute(withInvocationRecorder(ctxt, recorder), chain, t)
+		txresults[i] = &pb.TransactionResult{Uuid: t.Uuid, Result: result, ChaincodeEvents: ccevents, Invocations: recorder.get(), ReadWriteSet: recorder.getReadWriteSet()}
+		if simulated != nil {
+			if differences := diffExecutions(simulatedResult, simulateErr, simulated, result, txerr, txresults[i].ReadWriteSet); len(differences) > 0 {
+				chaincodeLogger.Errorf("Transaction %s is not deterministic:\n%s", t.Uuid, strings.Join(differences, "\n"))
+			}
+		}
+		if txerr == nil {
 			succeededTxs = append(succeededTxs, t)
 		} else {
-			sendTxRejectedEvent(xacts[i], txerrs[i].Error())
+			//NOTE- it'll be nice if we can have error values. For now success == 0, error == 1
+			txresults[i].ErrorCode = 1
+			txresults[i].Error = txerr.Error()
+			sendTxRejectedEvent(xacts[i], txerr.Error())
 		}
 	}
 
@@ -161,7 +187,7 @@ func ExecuteTransactions(ctxt context.Context, cname ChainName, xacts []*pb.Tran
 		stateHash, err = lgr.GetTempStateHash()
 	}
 
-	return succeededTxs, stateHash, ccevents, txerrs, err
+	return succeededTxs, stateHash, txresults, err
 }
 
 // GetSecureContext returns the security context from the context object or error
@@ -178,28 +204,6 @@ func ExecuteTransactions(ctxt context.Context, cname ChainName, xacts []*pb.Tran
 // 	return nil, err
 // }
 
-var errFailedToGetChainCodeSpecForTransaction = errors.New("Failed to get ChainCodeSpec from Transaction")
-
-func getTimeout(cID *pb.ChaincodeID) (time.Duration, error) {
-	ledger, err := ledger.GetLedger()
-	if err == nil {
-		chaincodeID := cID.Name
-		txUUID, err := ledger.GetState(chaincodeID, "github.com_openblockchain_obc-peer_chaincode_id", true)
-		if err == nil {
-			tx, err := ledger.GetTransactionByUUID(string(txUUID))
-			if err == nil {
-				chaincodeDeploymentSpec := &pb.ChaincodeDeploymentSpec{}
-				proto.Unmarshal(tx.Payload, chaincodeDeploymentSpec)
-				chaincodeSpec := chaincodeDeploymentSpec.GetChaincodeSpec()
-				timeout := time.Duration(time.Duration(chaincodeSpec.Timeout) * time.Millisecond)
-				return timeout, nil
-			}
-		}
-	}
-
-	return -1, errFailedToGetChainCodeSpecForTransaction
-}
-
 func markTxBegin(ledger *ledger.Ledger, t *pb.Transaction) {
 	if t.Type == pb.Transaction_CHAINCODE_QUERY {
 		return
@@ -17,10 +17,13 @@ limitations under the License.
 package chaincode
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"os"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -44,7 +47,7 @@ import (
 )
 
 // attributes to request in the batch of tcerts while deploying, invoking or querying
-var attributes = []string{"company", "position"}
+var tcertAttributes = []string{"company", "position"}
 
 func getNowMillis() int64 {
 	nanos := time.Now().UnixNano()
@@ -162,7 +165,7 @@ func createDeployTransaction(dspec *pb.ChaincodeDeploymentSpec, uuid string) (*p
 			return nil, err
 		}
 
-		tx, err = sec.NewChaincodeDeployTransaction(dspec, uuid, attributes...)
+		tx, err = sec.NewChaincodeDeployTransaction(dspec, uuid, tcertAttributes...)
 		if nil != err {
 			return nil, err
 		}
@@ -186,9 +189,9 @@ func createTransaction(invokeTx bool, spec *pb.ChaincodeInvocationSpec, uuid str
 			return nil, err
 		}
 		if invokeTx {
-			tx, err = sec.NewChaincodeExecute(spec, uuid, attributes...)
+			tx, err = sec.NewChaincodeExecute(spec, uuid, tcertAttributes...)
 		} else {
-			tx, err = sec.NewChaincodeQuery(spec, uuid, attributes...)
+			tx, err = sec.NewChaincodeQuery(spec, uuid, tcertAttributes...)
 		}
 		if nil != err {
 			return nil, err
@@ -259,7 +262,7 @@ func deploy2(ctx context.Context, chaincodeDeploymentSpec *pb.ChaincodeDeploymen
 }
 
 // Invoke or query a chaincode.
-func invoke(ctx context.Context, spec *pb.ChaincodeSpec, typ pb.Transaction_Type) (*pb.ChaincodeEvent, string, []byte, error) {
+func invoke(ctx context.Context, spec *pb.ChaincodeSpec, typ pb.Transaction_Type) ([]*pb.ChaincodeEvent, string, []byte, error) {
 	chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
 
 	// Now create the Transactions message and send to Peer.
@@ -278,20 +281,20 @@ func invoke(ctx context.Context, spec *pb.ChaincodeSpec, typ pb.Transaction_Type
 
 	var retval []byte
 	var execErr error
-	var ccevt *pb.ChaincodeEvent
+	var ccevts []*pb.ChaincodeEvent
 	if typ == pb.Transaction_CHAINCODE_QUERY {
-		retval, ccevt, execErr = Execute(ctx, GetChain(DefaultChain), transaction)
+		retval, ccevts, execErr = Execute(ctx, GetChain(DefaultChain), transaction)
 	} else {
 		ledger, _ := ledger.GetLedger()
 		ledger.BeginTxBatch("1")
-		retval, ccevt, execErr = Execute(ctx, GetChain(DefaultChain), transaction)
+		retval, ccevts, execErr = Execute(ctx, GetChain(DefaultChain), transaction)
 		if err != nil {
 			return nil, uuid, nil, fmt.Errorf("Error invoking chaincode: %s ", err)
 		}
 		ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
 	}
 
-	return ccevt, uuid, retval, execErr
+	return ccevts, uuid, retval, execErr
 }
 
 func closeListenerAndSleep(l net.Listener) {
@@ -1351,6 +1354,268 @@ func TestRangeQuery(t *testing.T) {
 	closeListenerAndSleep(lis)
 }
 
+type testRangeScanIterator struct {
+	keys   []string
+	cursor int
+}
+
+func (itr *testRangeScanIterator) Next() bool {
+	itr.cursor++
+	return itr.cursor < len(itr.keys)
+}
+
+func (itr *testRangeScanIterator) GetKeyValue() (string, []byte) {
+	return itr.keys[itr.cursor], []byte(itr.keys[itr.cursor])
+}
+
+func (itr *testRangeScanIterator) Close() {
+}
+
+// Test that ordered range queries are sorted and limited, only hold the keys they return and
+// refuse ranges of too many keys.
+func TestSortedRangeScanIterator(t *testing.T) {
+	keys := []string{"key3", "key1", "key4", "key2"}
+
+	check := func(keys []string, limit uint32, reverse bool, expected []string) {
+		itr, err := newSortedRangeScanIterator(&testRangeScanIterator{keys: keys, cursor: -1}, limit, reverse)
+		if err != nil {
+			t.Fatalf("Unexpected error sorting %d keys: %s", len(keys), err)
+		}
+		defer itr.Close()
+		if limit > 0 && len(itr.kvs) > int(limit) {
+			t.Fatalf("Expected at most %d keys held but got %d", limit, len(itr.kvs))
+		}
+		var actual []string
+		for itr.Next() {
+			key, value := itr.GetKeyValue()
+			if string(value) != key {
+				t.Errorf("Expected value %s for key %s but got %s", key, key, string(value))
+			}
+			actual = append(actual, key)
+		}
+		if itr.Next() {
+			t.Errorf("Expected the iterator to stay at the end")
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Expected %v for limit %d, reverse %t but got %v", expected, limit, reverse, actual)
+		}
+	}
+
+	check(keys, 0, false, []string{"key1", "key2", "key3", "key4"})
+	check(keys, 0, true, []string{"key4", "key3", "key2", "key1"})
+	check(keys, 2, false, []string{"key1", "key2"})
+	check(keys, 3, true, []string{"key4", "key3", "key2"})
+	check(keys, 10, false, []string{"key1", "key2", "key3", "key4"})
+
+	var many, sorted []string
+	for i := 0; i < maxOrderedRangeQueryKeys; i++ {
+		key := fmt.Sprintf("key%05d", (i*7919)%maxOrderedRangeQueryKeys)
+		many = append(many, key)
+	}
+	sorted = append(sorted, many...)
+	sort.Strings(sorted)
+	check(many, 0, false, sorted)
+	check(many, 5, false, sorted[:5])
+	sort.Sort(sort.Reverse(sort.StringSlice(sorted)))
+	check(many, 0, true, sorted)
+
+	// A larger range is refused, even with a limit
+	many = append(many, "key99999")
+	if _, err := newSortedRangeScanIterator(&testRangeScanIterator{keys: many, cursor: -1}, 5, false); err == nil {
+		t.Fatalf("Expected a range of %d keys to be refused", len(many))
+	}
+}
+
+func TestChaincodeLimits(t *testing.T) {
+	handler := &Handler{ChaincodeID: &pb.ChaincodeID{Name: "mycc"}, txCtxs: make(map[string]*transactionContext)}
+	handler.setLimits(&pb.ChaincodeSpec{Timeout: 500, Limits: &pb.ChaincodeLimits{MaxStateOps: 3, MaxWriteSize: 10}})
+	if handler.executeTimeout != 500*time.Millisecond {
+		t.Fatalf("Expected an execution deadline of 500ms, got %s", handler.executeTimeout)
+	}
+
+	if _, err := handler.createTxContext("ops", nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := handler.checkLimits("ops", 0); err != nil {
+			t.Fatalf("Unexpected error for state operation %d: %s", i+1, err)
+		}
+	}
+	if err := handler.checkLimits("ops", 0); err == nil || !strings.Contains(err.Error(), "limit of 3 state operations") {
+		t.Fatalf("Expected the fourth state operation to exceed the limit, got %v", err)
+	}
+
+	if _, err := handler.createTxContext("size", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := handler.checkLimits("size", 10); err != nil {
+		t.Fatalf("Unexpected error writing 10 bytes: %s", err)
+	}
+	if err := handler.checkLimits("size", 1); err == nil || !strings.Contains(err.Error(), "limit of 10 bytes") {
+		t.Fatalf("Expected writing 11 bytes to exceed the limit, got %v", err)
+	}
+
+	//a chaincode ignoring the error still fails the transaction
+	handler.notify(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_COMPLETED, Uuid: "size"})
+	resp := <-handler.getTxContext("size").responseNotifier
+	if resp.Type != pb.ChaincodeMessage_ERROR || !strings.Contains(string(resp.Payload), "limit of 10 bytes") {
+		t.Fatalf("Expected the transaction to fail, got %v", resp)
+	}
+}
+
+func TestInvocationContext(t *testing.T) {
+	recorder := &invocationRecorder{}
+	top := getInvocationContext(withInvocationRecorder(context.Background(), recorder), "a")
+
+	b, err := top.invoke("b", 2, true)
+	if err != nil {
+		t.Fatalf("Unexpected error invoking b: %s", err)
+	}
+	b.modified("key1")
+	b.modified("key1")
+	b.completed("uuid", []*pb.ChaincodeEvent{&pb.ChaincodeEvent{EventName: "event"}})
+
+	//a query is checked but not recorded
+	c, err := b.invoke("c", 2, false)
+	if err != nil {
+		t.Fatalf("Unexpected error invoking c: %s", err)
+	}
+	c.modified("key2")
+	if _, err = c.invoke("d", 2, true); err == nil || !strings.Contains(err.Error(), "maximum invocation depth of 2") {
+		t.Fatalf("Expected the invocation of d to exceed the depth, got %v", err)
+	}
+	if _, err = c.invoke("a", 8, true); err == nil || !strings.Contains(err.Error(), "a -> b -> c -> a") {
+		t.Fatalf("Expected the invocation of a to be refused, got %v", err)
+	}
+
+	expected := []*pb.ChaincodeInvocation{&pb.ChaincodeInvocation{ChaincodeID: "b", CallerChaincodeID: "a", Depth: 1, ModifiedKeys: []string{"key1"},
+		ChaincodeEvents: []*pb.ChaincodeEvent{&pb.ChaincodeEvent{ChaincodeID: "b", TxID: "uuid", EventName: "event"}}}}
+	if invocations := recorder.get(); !reflect.DeepEqual(invocations, expected) {
+		t.Fatalf("Expected invocations %v, got %v", expected, invocations)
+	}
+}
+
+func TestInvocationDeadline(t *testing.T) {
+	top := getInvocationContext(withInvocationRecorder(context.Background(), &invocationRecorder{}), "a")
+	if timeout := top.setDeadline(time.Second); timeout != time.Second {
+		t.Fatalf("Expected the chaincode of the transaction to get 1s, got %s", timeout)
+	}
+
+	//an invoked chaincode completes within the time left to its caller
+	b, err := top.invoke("b", 2, true)
+	if err != nil {
+		t.Fatalf("Unexpected error invoking b: %s", err)
+	}
+	if timeout := b.setDeadline(time.Minute); timeout > time.Second {
+		t.Fatalf("Expected b to get at most the 1s of a, got %s", timeout)
+	}
+
+	//and down the chaincodes it invokes, unless they have less time
+	c, err := b.invoke("c", 2, false)
+	if err != nil {
+		t.Fatalf("Unexpected error invoking c: %s", err)
+	}
+	if timeout := c.setDeadline(100 * time.Millisecond); timeout != 100*time.Millisecond {
+		t.Fatalf("Expected c to get 100ms, got %s", timeout)
+	}
+	if !b.deadline.Equal(top.deadline) {
+		t.Fatal("The deadline of b should not be changed by c")
+	}
+}
+
+func TestReadWriteSet(t *testing.T) {
+	recorder := &invocationRecorder{simulation: make(map[stateKey]*simulatedValue)}
+	if recorder.getReadWriteSet() != nil {
+		t.Fatal("Expected no read/write set before the state is accessed")
+	}
+	recorder.read("mycc", "a", []byte("100"))
+	recorder.write("mycc", "a", []byte("90"), false)
+	recorder.write("mycc", "b", nil, true)
+
+	if value, ok := recorder.getSimulated("mycc", "a"); !ok || string(value) != "90" {
+		t.Fatalf("Expected the simulated value of a to be 90, got %q", value)
+	}
+	if value, ok := recorder.getSimulated("mycc", "b"); !ok || value != nil {
+		t.Fatalf("Expected b to be deleted by the simulation, got %q", value)
+	}
+	if _, ok := recorder.getSimulated("mycc", "c"); ok {
+		t.Fatal("Expected c not to be written by the simulation")
+	}
+
+	first := recorder.getReadWriteSet()
+	if len(first.Reads) != 1 || len(first.Writes) != 2 || !first.Writes[1].IsDelete {
+		t.Fatalf("Unexpected read/write set %v", first)
+	}
+	if differences := diffReadWriteSets(first, first, "first", "second"); len(differences) != 0 {
+		t.Fatalf("Expected no differences, got %v", differences)
+	}
+
+	//the order of the writes does not matter, only the last value of each key
+	second := &pb.ReadWriteSet{
+		Reads:  []*pb.StateRead{first.Reads[0]},
+		Writes: []*pb.StateWrite{first.Writes[1], &pb.StateWrite{ChaincodeID: "mycc", Key: "a", ValueHash: hashValue([]byte("80"))}, first.Writes[0]},
+	}
+	if differences := diffReadWriteSets(first, second, "first", "second"); len(differences) != 0 {
+		t.Fatalf("Expected no differences, got %v", differences)
+	}
+
+	second.Writes = []*pb.StateWrite{&pb.StateWrite{ChaincodeID: "mycc", Key: "a", ValueHash: hashValue([]byte("80"))}, first.Writes[1]}
+	second.Reads = append(second.Reads, &pb.StateRead{ChaincodeID: "mycc", Key: "time"})
+	differences := diffExecutions(nil, nil, first, nil, nil, second)
+	if len(differences) != 2 || !strings.Contains(differences[0], `"time" read with no value by the second execution only`) ||
+		!strings.Contains(differences[1], `"a" written with value`) {
+		t.Fatalf("Unexpected differences %v", differences)
+	}
+	if differences = diffExecutions([]byte("1"), nil, first, []byte("2"), nil, first); len(differences) != 1 {
+		t.Fatalf("Expected the results to differ, got %v", differences)
+	}
+
+	//the recorded execution is compared with the first one, whatever its error message
+	original := &pb.TransactionResult{Result: []byte("1"), ReadWriteSet: first}
+	if differences = diffOriginalExecution(original, []byte("1"), nil, first); len(differences) != 0 {
+		t.Fatalf("Expected no differences from the recorded execution, got %v", differences)
+	}
+	original = &pb.TransactionResult{ErrorCode: 1, Error: "Failed", ReadWriteSet: first}
+	if differences = diffOriginalExecution(original, nil, errors.New("Transaction or query returned with failure: Failed"), first); len(differences) != 0 {
+		t.Fatalf("Expected no differences from the failed recorded execution, got %v", differences)
+	}
+	differences = diffOriginalExecution(original, []byte("1"), nil, second)
+	if len(differences) != 3 || !strings.Contains(differences[0], "the recorded execution failed") ||
+		!strings.Contains(differences[1], `"time" read with no value by the first execution only`) {
+		t.Fatalf("Unexpected differences from the recorded execution %v", differences)
+	}
+}
+
+func TestStateChanges(t *testing.T) {
+	lgr := ledger.InitTestLedger(t)
+	lgr.BeginTxBatch(1)
+	lgr.TxBegin("txUuid")
+	lgr.SetState("mycc", "a", []byte("90"))
+	lgr.TxFinished("txUuid", true)
+	if err := lgr.CommitTxBatch(1, nil, nil, nil); err != nil {
+		t.Fatalf("Error committing state: %s", err)
+	}
+
+	//a was 100 when the transaction first read it, then it wrote and read 90
+	original := &pb.ReadWriteSet{Reads: []*pb.StateRead{
+		&pb.StateRead{ChaincodeID: "mycc", Key: "a", ValueHash: hashValue([]byte("100"))},
+		&pb.StateRead{ChaincodeID: "mycc", Key: "a", ValueHash: hashValue([]byte("90"))},
+		&pb.StateRead{ChaincodeID: "mycc", Key: "b"},
+	}}
+	changes, err := getStateChanges(original)
+	if err != nil {
+		t.Fatalf("Error getting state changes: %s", err)
+	}
+	if len(changes) != 1 || !strings.Contains(changes[0], `"a" was read with value`) {
+		t.Fatalf("Unexpected state changes %v", changes)
+	}
+
+	original.Reads = original.Reads[1:]
+	if changes, err = getStateChanges(original); err != nil || len(changes) != 0 {
+		t.Fatalf("Expected no state changes, got %v (%v)", changes, err)
+	}
+}
+
 func TestGetEvent(t *testing.T) {
 	var opts []grpc.ServerOption
 	if viper.GetBool("peer.tls.enabled") {
@@ -1406,18 +1671,18 @@ func TestGetEvent(t *testing.T) {
 
 	spec = &pb.ChaincodeSpec{Type: 1, ChaincodeID: cID, CtorMsg: &pb.ChaincodeInput{Function: "", Args: args}}
 
-	var ccevt *pb.ChaincodeEvent
-	ccevt, _, _, err = invoke(ctxt, spec, pb.Transaction_CHAINCODE_INVOKE)
+	var ccevts []*pb.ChaincodeEvent
+	ccevts, _, _, err = invoke(ctxt, spec, pb.Transaction_CHAINCODE_INVOKE)
 
 	if err != nil {
 		t.Logf("Error invoking chaincode %s(%s)", chaincodeID, err)
 		t.Fail()
 	}
 
-	if ccevt == nil {
-		t.Logf("Error ccevt is nil %s(%s)", chaincodeID, err)
-		t.Fail()
+	if len(ccevts) != 1 {
+		t.Fatalf("Error expected one event from %s, got %d (%v)", chaincodeID, len(ccevts), err)
 	}
+	ccevt := ccevts[0]
 
 	if ccevt.ChaincodeID != chaincodeID {
 		t.Logf("Error ccevt id(%s) != cid(%s)", ccevt.ChaincodeID, chaincodeID)
@@ -1433,6 +1698,122 @@ func TestGetEvent(t *testing.T) {
 	closeListenerAndSleep(lis)
 }
 
+// Test upgrading a chaincode to new code and then terminating it.
+func TestUpgradeAndTerminateChaincode(t *testing.T) {
+	var opts []grpc.ServerOption
+	if viper.GetBool("peer.tls.enabled") {
+		creds, err := credentials.NewServerTLSFromFile(viper.GetString("peer.tls.cert.file"), viper.GetString("peer.tls.key.file"))
+		if err != nil {
+			grpclog.Fatalf("Failed to generate credentials %v", err)
+		}
+		opts = []grpc.ServerOption{grpc.Creds(creds)}
+	}
+	grpcServer := grpc.NewServer(opts...)
+	viper.Set("peer.fileSystemPath", "/var/hyperledger/test/tmpdb")
+
+	//use a different address than what we usually use for "peer"
+	//we override the peerAddress set in chaincode_support.go
+	peerAddress := "0.0.0.0:21212"
+
+	lis, err := net.Listen("tcp", peerAddress)
+	if err != nil {
+		t.Fail()
+		t.Logf("Error starting peer listener %s", err)
+		return
+	}
+
+	getPeerEndpoint := func() (*pb.PeerEndpoint, error) {
+		return &pb.PeerEndpoint{ID: &pb.PeerID{Name: "testpeer"}, Address: peerAddress}, nil
+	}
+
+	ccStartupTimeout := time.Duration(chaincodeStartupTimeoutDefault) * time.Millisecond
+	pb.RegisterChaincodeSupportServer(grpcServer, NewChaincodeSupport(DefaultChain, getPeerEndpoint, false, ccStartupTimeout, nil))
+
+	go grpcServer.Serve(lis)
+
+	var ctxt = context.Background()
+
+	url := "github.com/hyperledger/fabric/examples/chaincode/go/chaincode_example02"
+
+	cID := &pb.ChaincodeID{Path: url}
+	spec := &pb.ChaincodeSpec{Type: 1, ChaincodeID: cID, CtorMsg: &pb.ChaincodeInput{Function: "init", Args: []string{"a", "100", "b", "200"}}}
+
+	_, err = deploy(ctxt, spec)
+	chaincodeID := spec.ChaincodeID.Name
+	if err != nil {
+		t.Fail()
+		t.Logf("Error initializing chaincode %s(%s)", chaincodeID, err)
+		GetChain(DefaultChain).Stop(ctxt, &pb.ChaincodeDeploymentSpec{ChaincodeSpec: spec})
+		closeListenerAndSleep(lis)
+		return
+	}
+
+	time.Sleep(time.Second)
+
+	//upgrade to the same code with a different constructor, which yields a new code hash
+	upgradeSpec := &pb.ChaincodeSpec{Type: 1, ChaincodeID: &pb.ChaincodeID{Path: url}, CtorMsg: &pb.ChaincodeInput{Function: "init", Args: []string{"a", "10", "b", "20"}}}
+	cds, err := getDeploymentSpec(ctxt, upgradeSpec)
+	if err != nil {
+		t.Fail()
+		t.Logf("Error getting upgrade spec for %s(%s)", chaincodeID, err)
+		GetChain(DefaultChain).Stop(ctxt, &pb.ChaincodeDeploymentSpec{ChaincodeSpec: spec})
+		closeListenerAndSleep(lis)
+		return
+	}
+	version := upgradeSpec.ChaincodeID.Name
+	upgradeSpec.ChaincodeID.Name = chaincodeID
+
+	transaction, err := pb.NewChaincodeUpgradeTransaction(cds, version)
+	if err != nil {
+		t.Fail()
+		t.Logf("Error creating upgrade transaction for %s(%s)", chaincodeID, err)
+		GetChain(DefaultChain).Stop(ctxt, &pb.ChaincodeDeploymentSpec{ChaincodeSpec: spec})
+		closeListenerAndSleep(lis)
+		return
+	}
+
+	ledgerObj, _ := ledger.GetLedger()
+	ledgerObj.BeginTxBatch("1")
+	_, _, err = Execute(ctxt, GetChain(DefaultChain), transaction)
+	ledgerObj.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
+	if err != nil {
+		t.Fail()
+		t.Logf("Error upgrading chaincode %s(%s)", chaincodeID, err)
+		GetChain(DefaultChain).Stop(ctxt, &pb.ChaincodeDeploymentSpec{ChaincodeSpec: spec})
+		closeListenerAndSleep(lis)
+		return
+	}
+
+	//the upgraded code was initialized under the same name
+	querySpec := &pb.ChaincodeSpec{Type: 1, ChaincodeID: &pb.ChaincodeID{Name: chaincodeID}, CtorMsg: &pb.ChaincodeInput{Function: "query", Args: []string{"a"}}}
+	_, _, retval, err := invoke(ctxt, querySpec, pb.Transaction_CHAINCODE_QUERY)
+	if err != nil || string(retval) != "10" {
+		t.Fail()
+		t.Logf("Expected 10 from upgraded chaincode %s but got %s(%v)", chaincodeID, string(retval), err)
+	}
+
+	//terminate the chaincode
+	terminateSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: &pb.ChaincodeSpec{Type: 1, ChaincodeID: &pb.ChaincodeID{Name: chaincodeID}}}
+	transaction, _ = pb.NewChaincodeExecute(terminateSpec, util.GenerateUUID(), pb.Transaction_CHAINCODE_TERMINATE)
+
+	ledgerObj.BeginTxBatch("1")
+	_, _, err = Execute(ctxt, GetChain(DefaultChain), transaction)
+	ledgerObj.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
+	if err != nil {
+		t.Fail()
+		t.Logf("Error terminating chaincode %s(%s)", chaincodeID, err)
+	}
+
+	//a terminated chaincode cannot be queried
+	_, _, _, err = invoke(ctxt, querySpec, pb.Transaction_CHAINCODE_QUERY)
+	if err == nil {
+		t.Fail()
+		t.Logf("Expected query of terminated chaincode %s to fail", chaincodeID)
+	}
+
+	closeListenerAndSleep(lis)
+}
+
 func TestMain(m *testing.M) {
 	SetupTestConfig()
 	os.Exit(m.Run())
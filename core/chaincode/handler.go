@@ -17,8 +17,10 @@ limitations under the License.
 package chaincode
 
 import (
+	"container/heap"
 	"fmt"
 	"io"
+	"strings"
 	"sync"
 	"time"
 
@@ -61,6 +63,137 @@ type transactionContext struct {
 
 	// tracks open iterators used for range queries
 	rangeQueryIteratorMap map[string]statemgmt.RangeScanIterator
+
+	// resources used by the transaction, checked against the limits of the chaincode
+	stateOps   uint32
+	writeSize  uint64
+	limitError error
+
+	// the chaincodes the transaction is executing, followed down the chaincodes they invoke
+	invocation *invocationContext
+}
+
+// invocationKey is the key of the invocationContext in the context given to Execute
+type invocationKey struct{}
+
+// invocationContext follows a transaction down the chaincodes invoked by its chaincode
+type invocationContext struct {
+	// the names of the chaincodes executing, the chaincode of the transaction first
+	callChain []string
+
+	// the invocation being executed, nil for the chaincode of the transaction
+	record *pb.ChaincodeInvocation
+
+	// the invocations of the transaction, shared with the nested invocations
+	recorder *invocationRecorder
+
+	// the time the caller of the chaincode expects it to complete by, zero for the chaincode of
+	// the transaction until it is executed
+	deadline time.Time
+}
+
+// invocationRecorder collects the invocations made and the state accessed during a transaction
+type invocationRecorder struct {
+	sync.Mutex
+	invocations []*pb.ChaincodeInvocation
+
+	// the state read and written, in order
+	reads  []*pb.StateRead
+	writes []*pb.StateWrite
+
+	// the state written by a simulated transaction, nil unless simulating
+	simulation map[stateKey]*simulatedValue
+}
+
+// withInvocationRecorder returns a context collecting the invocations of the transaction
+// executed with it
+func withInvocationRecorder(ctxt context.Context, recorder *invocationRecorder) context.Context {
+	return context.WithValue(ctxt, invocationKey{}, &invocationContext{recorder: recorder})
+}
+
+// getInvocationContext returns the invocation context for executing the chaincode, starting
+// a new call chain unless the context carries an invocation of the chaincode
+func getInvocationContext(ctxt context.Context, chaincode string) *invocationContext {
+	invocation, _ := ctxt.Value(invocationKey{}).(*invocationContext)
+	if invocation == nil {
+		invocation = &invocationContext{recorder: &invocationRecorder{}}
+	}
+	if len(invocation.callChain) == 0 {
+		return &invocationContext{callChain: []string{chaincode}, recorder: invocation.recorder}
+	}
+	return invocation
+}
+
+// invoke checks the chaincode may be invoked from this context and returns the context of the
+// invocation. An invocation is recorded when the chaincode is invoked in a transaction.
+func (invocation *invocationContext) invoke(chaincode string, maxDepth int, record bool) (*invocationContext, error) {
+	caller := invocation.callChain[len(invocation.callChain)-1]
+	for _, name := range invocation.callChain {
+		if name == chaincode {
+			return nil, fmt.Errorf("Chaincode %s cannot invoke %s, which is already executing (%s -> %s)", caller, chaincode, strings.Join(invocation.callChain, " -> "), chaincode)
+		}
+	}
+	depth := len(invocation.callChain)
+	if depth > maxDepth {
+		return nil, fmt.Errorf("Chaincode %s cannot invoke %s, the maximum invocation depth of %d is reached (%s)", caller, chaincode, maxDepth, strings.Join(invocation.callChain, " -> "))
+	}
+
+	callChain := make([]string, depth, depth+1)
+	copy(callChain, invocation.callChain)
+	nested := &invocationContext{callChain: append(callChain, chaincode), recorder: invocation.recorder, deadline: invocation.deadline}
+	if record {
+		nested.record = &pb.ChaincodeInvocation{ChaincodeID: chaincode, CallerChaincodeID: caller, Depth: uint32(depth)}
+		invocation.recorder.Lock()
+		invocation.recorder.invocations = append(invocation.recorder.invocations, nested.record)
+		invocation.recorder.Unlock()
+	}
+	return nested, nil
+}
+
+// setDeadline sets the time the chaincode must complete by to the given timeout from now, or to
+// the deadline of its caller if earlier, and returns the time left until then
+func (invocation *invocationContext) setDeadline(timeout time.Duration) time.Duration {
+	now := time.Now()
+	if deadline := now.Add(timeout); invocation.deadline.IsZero() || deadline.Before(invocation.deadline) {
+		invocation.deadline = deadline
+	}
+	return invocation.deadline.Sub(now)
+}
+
+// modified records a key put or deleted by the invoked chaincode
+func (invocation *invocationContext) modified(key string) {
+	if invocation.record == nil {
+		return
+	}
+	invocation.recorder.Lock()
+	defer invocation.recorder.Unlock()
+	for _, k := range invocation.record.ModifiedKeys {
+		if k == key {
+			return
+		}
+	}
+	invocation.record.ModifiedKeys = append(invocation.record.ModifiedKeys, key)
+}
+
+// completed records the events emitted by the invoked chaincode
+func (invocation *invocationContext) completed(uuid string, events []*pb.ChaincodeEvent) {
+	if invocation.record == nil || len(events) == 0 {
+		return
+	}
+	for _, event := range events {
+		event.ChaincodeID = invocation.record.ChaincodeID
+		event.TxID = uuid
+	}
+	invocation.recorder.Lock()
+	defer invocation.recorder.Unlock()
+	invocation.record.ChaincodeEvents = events
+}
+
+// get returns the invocations recorded so far
+func (recorder *invocationRecorder) get() []*pb.ChaincodeInvocation {
+	recorder.Lock()
+	defer recorder.Unlock()
+	return recorder.invocations
 }
 
 type nextStateInfo struct {
@@ -80,6 +213,10 @@ type Handler struct {
 	// A copy of decrypted deploy tx this handler manages, no code
 	deployTXSecContext *pb.Transaction
 
+	// limits declared when the chaincode was deployed
+	limits         *pb.ChaincodeLimits
+	executeTimeout time.Duration
+
 	chaincodeSupport *ChaincodeSupport
 	registered       bool
 	readyNotify      chan bool
@@ -114,6 +251,11 @@ func (handler *Handler) serialSend(msg *pb.ChaincodeMessage) error {
 }
 
 func (handler *Handler) createTxContext(uuid string, tx *pb.Transaction) (*transactionContext, error) {
+	return handler.createInvocationTxContext(uuid, tx, nil)
+}
+
+// createInvocationTxContext creates the context of a transaction executed in the invocation context
+func (handler *Handler) createInvocationTxContext(uuid string, tx *pb.Transaction, invocation *invocationContext) (*transactionContext, error) {
 	if handler.txCtxs == nil {
 		return nil, fmt.Errorf("cannot create notifier for Uuid:%s", uuid)
 	}
@@ -123,7 +265,7 @@ func (handler *Handler) createTxContext(uuid string, tx *pb.Transaction) (*trans
 		return nil, fmt.Errorf("Uuid:%s exists", uuid)
 	}
 	txctx := &transactionContext{transactionSecContext: tx, responseNotifier: make(chan *pb.ChaincodeMessage, 1),
-		rangeQueryIteratorMap: make(map[string]statemgmt.RangeScanIterator)}
+		rangeQueryIteratorMap: make(map[string]statemgmt.RangeScanIterator), invocation: invocation}
 	handler.txCtxs[uuid] = txctx
 	return txctx, nil
 }
@@ -161,6 +303,96 @@ func (handler *Handler) deleteRangeQueryIterator(txContext *transactionContext,
 	delete(txContext.rangeQueryIteratorMap, uuid)
 }
 
+// setLimits sets the limits declared by the chaincode spec of the deployment
+func (handler *Handler) setLimits(spec *pb.ChaincodeSpec) {
+	handler.Lock()
+	defer handler.Unlock()
+	handler.limits = spec.GetLimits()
+	handler.executeTimeout = 0
+	if spec != nil && spec.Timeout > 0 {
+		handler.executeTimeout = time.Duration(spec.Timeout) * time.Millisecond
+	}
+}
+
+// checkLimits counts a state operation of the transaction, writing size bytes to the state, and
+// returns an error once the transaction exceeds the limits of the chaincode. The error is kept so
+// the transaction fails even if the chaincode ignores it.
+func (handler *Handler) checkLimits(uuid string, size int) error {
+	handler.Lock()
+	defer handler.Unlock()
+	txContext := handler.txCtxs[uuid]
+	if txContext == nil || handler.limits == nil {
+		return nil
+	}
+	if txContext.limitError != nil {
+		return txContext.limitError
+	}
+	txContext.stateOps++
+	txContext.writeSize += uint64(size)
+	if max := handler.limits.MaxStateOps; max > 0 && txContext.stateOps > max {
+		txContext.limitError = fmt.Errorf("Transaction %s exceeded the limit of %d state operations of chaincode %s", uuid, max, handler.ChaincodeID.Name)
+	} else if max := handler.limits.MaxWriteSize; max > 0 && txContext.writeSize > max {
+		txContext.limitError = fmt.Errorf("Transaction %s exceeded the limit of %d bytes written to the state of chaincode %s", uuid, max, handler.ChaincodeID.Name)
+	}
+	if txContext.limitError != nil {
+		chaincodeLogger.Errorf("[%s]%s", shortuuid(uuid), txContext.limitError)
+	}
+	return txContext.limitError
+}
+
+// limitErrorMessage turns the response of a transaction that exceeded a limit into an error
+func limitErrorMessage(msg *pb.ChaincodeMessage, limitError error) *pb.ChaincodeMessage {
+	switch msg.Type {
+	case pb.ChaincodeMessage_COMPLETED, pb.ChaincodeMessage_ERROR:
+		return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(limitError.Error()), Uuid: msg.Uuid, ChaincodeEvents: msg.ChaincodeEvents}
+	case pb.ChaincodeMessage_QUERY_COMPLETED, pb.ChaincodeMessage_QUERY_ERROR:
+		return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_QUERY_ERROR, Payload: []byte(limitError.Error()), Uuid: msg.Uuid}
+	}
+	return msg
+}
+
+// getInvocation returns the invocation context of the transaction
+func (handler *Handler) getInvocation(uuid string) *invocationContext {
+	handler.Lock()
+	defer handler.Unlock()
+	if txctx := handler.txCtxs[uuid]; txctx != nil && txctx.invocation != nil {
+		return txctx.invocation
+	}
+	// the chaincode is not executing a transaction, e.g. it is initializing
+	return &invocationContext{callChain: []string{handler.ChaincodeID.Name}, recorder: &invocationRecorder{}}
+}
+
+// prepareInvocation returns the transaction invoking the chaincode of the spec on behalf of the
+// transaction, and the context to execute it with. The invocation carries the security context of
+// the transaction. It is refused when it exceeds chaincode.maxinvocationdepth or invokes a chaincode
+// that is already executing.
+func (handler *Handler) prepareInvocation(uuid string, spec *pb.ChaincodeSpec, txType pb.Transaction_Type) (context.Context, *invocationContext, *pb.Transaction, error) {
+	record := txType == pb.Transaction_CHAINCODE_INVOKE
+	invocation, err := handler.getInvocation(uuid).invoke(spec.ChaincodeID.Name, handler.chaincodeSupport.maxInvocationDepth, record)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
+	transaction, err := pb.NewChaincodeExecute(chaincodeInvocationSpec, uuid, txType)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// the invoked chaincode sees the caller, binding and timestamp of the transaction
+	if txctx := handler.getTxContext(uuid); txctx != nil && txctx.transactionSecContext != nil {
+		callerTx := txctx.transactionSecContext
+		transaction.Cert = callerTx.Cert
+		transaction.Signature = callerTx.Signature
+		transaction.Nonce = callerTx.Nonce
+		transaction.Metadata = callerTx.Metadata
+		transaction.Timestamp = callerTx.Timestamp
+		transaction.ConfidentialityLevel = callerTx.ConfidentialityLevel
+	}
+
+	return context.WithValue(context.Background(), invocationKey{}, invocation), invocation, transaction, nil
+}
+
 //THIS CAN BE REMOVED ONCE WE SUPPORT CONFIDENTIALITY WITH CC-CALLING-CC
 //we dissallow chaincode-chaincode interactions till confidentiality implications are understood
 func (handler *Handler) canCallChaincode(uuid string) *pb.ChaincodeMessage {
@@ -416,6 +648,11 @@ func newChaincodeSupportHandler(chaincodeSupport *ChaincodeSupport, peerChatStre
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE.String(), Src: []string{transactionstate}, Dst: transactionstate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE.String(), Src: []string{busyxactstate}, Dst: busyxactstate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY.String(), Src: []string{readystate}, Dst: readystate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY.String(), Src: []string{initstate}, Dst: initstate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY.String(), Src: []string{transactionstate}, Dst: transactionstate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY.String(), Src: []string{busyxactstate}, Dst: busyxactstate},
 			{Name: pb.ChaincodeMessage_ERROR.String(), Src: []string{initstate}, Dst: endstate},
 			{Name: pb.ChaincodeMessage_ERROR.String(), Src: []string{transactionstate}, Dst: readystate},
 			{Name: pb.ChaincodeMessage_ERROR.String(), Src: []string{busyinitstate}, Dst: initstate},
@@ -431,6 +668,7 @@ func newChaincodeSupportHandler(chaincodeSupport *ChaincodeSupport, peerChatStre
 			"after_" + pb.ChaincodeMessage_RANGE_QUERY_STATE.String():       func(e *fsm.Event) { v.afterRangeQueryState(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_RANGE_QUERY_STATE_NEXT.String():  func(e *fsm.Event) { v.afterRangeQueryStateNext(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE.String(): func(e *fsm.Event) { v.afterRangeQueryStateClose(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_GET_HISTORY_FOR_KEY.String():     func(e *fsm.Event) { v.afterGetHistoryForKey(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_PUT_STATE.String():               func(e *fsm.Event) { v.afterPutState(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_DEL_STATE.String():               func(e *fsm.Event) { v.afterDelState(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_INVOKE_CHAINCODE.String():        func(e *fsm.Event) { v.afterInvokeChaincode(e, v.FSM.Current()) },
@@ -547,6 +785,10 @@ func (handler *Handler) notify(msg *pb.ChaincodeMessage) {
 		chaincodeLogger.Debugf("notifier Uuid:%s does not exist", msg.Uuid)
 	} else {
 		chaincodeLogger.Debugf("notifying Uuid:%s", msg.Uuid)
+		//a transaction that exceeded a limit fails even if the chaincode ignored the error
+		if tctx.limitError != nil {
+			msg = limitErrorMessage(msg, tctx.limitError)
+		}
 		tctx.responseNotifier <- msg
 
 		// clean up rangeQueryIteratorMap
@@ -609,6 +851,11 @@ func (handler *Handler) handleGetState(msg *pb.ChaincodeMessage) {
 			handler.serialSend(serialSendMsg)
 		}()
 
+		if err := handler.checkLimits(msg.Uuid, 0); err != nil {
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Uuid: msg.Uuid}
+			return
+		}
+
 		key := string(msg.Payload)
 		ledgerObj, ledgerErr := ledger.GetLedger()
 		if ledgerErr != nil {
@@ -623,6 +870,15 @@ func (handler *Handler) handleGetState(msg *pb.ChaincodeMessage) {
 		// Invoke ledger to get state
 		chaincodeID := handler.ChaincodeID.Name
 
+		recorder := handler.getInvocation(msg.Uuid).recorder
+		if value, ok := recorder.getSimulated(chaincodeID, key); ok {
+			// the simulated transaction wrote the key, its value is not in the ledger
+			recorder.read(chaincodeID, key, value)
+			chaincodeLogger.Debugf("[%s]Got simulated state. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_RESPONSE)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: value, Uuid: msg.Uuid}
+			return
+		}
+
 		readCommittedState := !handler.getIsTransaction(msg.Uuid)
 		res, err := ledgerObj.GetState(chaincodeID, key, readCommittedState)
 		if err != nil {
@@ -632,11 +888,13 @@ func (handler *Handler) handleGetState(msg *pb.ChaincodeMessage) {
 			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
 		} else if res == nil {
 			//The state object being requested does not exist, so don't attempt to decrypt it
+			recorder.read(chaincodeID, key, nil)
 			chaincodeLogger.Debugf("[%s]No state associated with key: %s. Sending %s with an empty payload", shortuuid(msg.Uuid), key, pb.ChaincodeMessage_RESPONSE)
 			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: res, Uuid: msg.Uuid}
 		} else {
 			// Decrypt the data if the confidential is enabled
 			if res, err = handler.decrypt(msg.Uuid, res); err == nil {
+				recorder.read(chaincodeID, key, res)
 				// Send response msg back to chaincode. GetState will not trigger event
 				chaincodeLogger.Debugf("[%s]Got state. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_RESPONSE)
 				serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: res, Uuid: msg.Uuid}
@@ -652,8 +910,205 @@ func (handler *Handler) handleGetState(msg *pb.ChaincodeMessage) {
 	}()
 }
 
+// afterGetHistoryForKey handles a GET_HISTORY_FOR_KEY request from the chaincode.
+func (handler *Handler) afterGetHistoryForKey(e *fsm.Event, state string) {
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	chaincodeLogger.Debugf("[%s]Received %s, invoking get history from ledger", shortuuid(msg.Uuid), pb.ChaincodeMessage_GET_HISTORY_FOR_KEY)
+
+	// Query ledger for history
+	handler.handleGetHistoryForKey(msg)
+}
+
+// Handles query to ledger to get the history of a key
+func (handler *Handler) handleGetHistoryForKey(msg *pb.ChaincodeMessage) {
+	// The defer followed by triggering a go routine dance is needed to ensure that the previous state transition
+	// is completed before the next one is triggered. The previous state transition is deemed complete only when
+	// the afterGetHistoryForKey function is exited.
+	go func() {
+		// Check if this is the unique state request from this chaincode uuid
+		uniqueReq := handler.createUUIDEntry(msg.Uuid)
+		if !uniqueReq {
+			// Drop this request
+			chaincodeLogger.Error("Another state request pending for this Uuid. Cannot process.")
+			return
+		}
+
+		var serialSendMsg *pb.ChaincodeMessage
+
+		defer func() {
+			handler.deleteUUIDEntry(msg.Uuid)
+			chaincodeLogger.Debugf("[%s]handleGetHistoryForKey serial send %s", shortuuid(serialSendMsg.Uuid), serialSendMsg.Type)
+			handler.serialSend(serialSendMsg)
+		}()
+
+		// The history is indexed by each peer as it commits blocks, so it
+		// may differ between validators, transactions would not execute
+		// deterministically if they depended on it
+		if handler.getIsTransaction(msg.Uuid) {
+			payload := []byte(fmt.Sprintf("Cannot handle %s in transaction context", msg.Type.String()))
+			chaincodeLogger.Errorf("[%s]Cannot handle %s in transaction context. Sending %s", shortuuid(msg.Uuid), msg.Type.String(), pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+			return
+		}
+
+		if err := handler.checkLimits(msg.Uuid, 0); err != nil {
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Uuid: msg.Uuid}
+			return
+		}
+
+		key := string(msg.Payload)
+		ledgerObj, ledgerErr := ledger.GetLedger()
+		if ledgerErr != nil {
+			payload := []byte(ledgerErr.Error())
+			chaincodeLogger.Errorf("Failed to get ledger(%s). Sending %s", ledgerErr, pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+			return
+		}
+
+		chaincodeID := handler.ChaincodeID.Name
+		modifications, err := ledgerObj.GetHistoryForKey(chaincodeID, key)
+		if err != nil {
+			payload := []byte(err.Error())
+			chaincodeLogger.Errorf("[%s]Failed to get history of key %s(%s). Sending %s", shortuuid(msg.Uuid), key, err, pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+			return
+		}
+
+		// Decrypt the values if the confidential is enabled
+		for _, modification := range modifications {
+			if modification.IsDelete {
+				continue
+			}
+			if modification.Value, err = handler.decrypt(msg.Uuid, modification.Value); err != nil {
+				chaincodeLogger.Errorf("[%s]Got error (%s) while decrypting. Sending %s", shortuuid(msg.Uuid), err, pb.ChaincodeMessage_ERROR)
+				serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Uuid: msg.Uuid}
+				return
+			}
+		}
+
+		payloadBytes, err := proto.Marshal(&pb.KeyHistory{Modifications: modifications})
+		if err != nil {
+			payload := []byte(err.Error())
+			chaincodeLogger.Errorf("Failed to marshal history: %s", err)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+			return
+		}
+
+		chaincodeLogger.Debugf("[%s]Got history of key %s. Sending %s", shortuuid(msg.Uuid), key, pb.ChaincodeMessage_RESPONSE)
+		serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: payloadBytes, Uuid: msg.Uuid}
+	}()
+}
+
 const maxRangeQueryStateLimit = 100
 
+// maxOrderedRangeQueryKeys is the number of keys an ordered range query scans at most
+const maxOrderedRangeQueryKeys = 10000
+
+// sortedRangeScanIterator serves the key-values of a range query in lexical key order. The ledger
+// returns range scans in no specific order, so the range is read in a single scan which keeps the
+// keys to serve in a heap bounded by the limit of the query. A range holding more than
+// maxOrderedRangeQueryKeys keys is refused, as a query without a limit holds them all.
+type sortedRangeScanIterator struct {
+	kvs    []*rangeScanKeyValue
+	cursor int
+}
+
+type rangeScanKeyValue struct {
+	key   string
+	value []byte
+}
+
+// rangeScanHeap holds the key-values selected by the scan, the one served last on top so that it
+// is the first evicted by a key-value served before it
+type rangeScanHeap struct {
+	kvs     []*rangeScanKeyValue
+	reverse bool
+}
+
+func (h *rangeScanHeap) Len() int {
+	return len(h.kvs)
+}
+
+func (h *rangeScanHeap) Less(i, j int) bool {
+	return servedBefore(h.kvs[j].key, h.kvs[i].key, h.reverse)
+}
+
+func (h *rangeScanHeap) Swap(i, j int) {
+	h.kvs[i], h.kvs[j] = h.kvs[j], h.kvs[i]
+}
+
+func (h *rangeScanHeap) Push(x interface{}) {
+	h.kvs = append(h.kvs, x.(*rangeScanKeyValue))
+}
+
+func (h *rangeScanHeap) Pop() interface{} {
+	kv := h.kvs[len(h.kvs)-1]
+	h.kvs = h.kvs[:len(h.kvs)-1]
+	return kv
+}
+
+// servedBefore returns true if key a is served before key b
+func servedBefore(a, b string, reverse bool) bool {
+	if reverse {
+		return a > b
+	}
+	return a < b
+}
+
+// newSortedRangeScanIterator selects the first limit key-values of scan, or all of them if limit
+// is 0, and closes it
+func newSortedRangeScanIterator(scan statemgmt.RangeScanIterator, limit uint32, reverse bool) (*sortedRangeScanIterator, error) {
+	defer scan.Close()
+	size := maxOrderedRangeQueryKeys
+	if limit > 0 && limit < maxOrderedRangeQueryKeys {
+		size = int(limit)
+	}
+
+	h := &rangeScanHeap{reverse: reverse}
+	for scanned := 0; scan.Next(); scanned++ {
+		if scanned == maxOrderedRangeQueryKeys {
+			return nil, fmt.Errorf("Ordered range query over more than %d keys", maxOrderedRangeQueryKeys)
+		}
+		key, value := scan.GetKeyValue()
+		if h.Len() < size {
+			heap.Push(h, &rangeScanKeyValue{key, value})
+		} else if servedBefore(key, h.kvs[0].key, reverse) {
+			h.kvs[0] = &rangeScanKeyValue{key, value}
+			heap.Fix(h, 0)
+		}
+	}
+
+	kvs := make([]*rangeScanKeyValue, h.Len())
+	for i := len(kvs) - 1; i >= 0; i-- {
+		kvs[i] = heap.Pop(h).(*rangeScanKeyValue)
+	}
+	return &sortedRangeScanIterator{kvs: kvs, cursor: -1}, nil
+}
+
+// Next moves to the next key in order
+func (itr *sortedRangeScanIterator) Next() bool {
+	if itr.cursor < len(itr.kvs) {
+		itr.cursor++
+	}
+	return itr.cursor < len(itr.kvs)
+}
+
+// GetKeyValue returns the current key-value
+func (itr *sortedRangeScanIterator) GetKeyValue() (string, []byte) {
+	kv := itr.kvs[itr.cursor]
+	return kv.key, kv.value
+}
+
+// Close releases the key-values
+func (itr *sortedRangeScanIterator) Close() {
+	itr.kvs = nil
+	itr.cursor = 0
+}
+
 // afterRangeQueryState handles a RANGE_QUERY_STATE request from the chaincode.
 func (handler *Handler) afterRangeQueryState(e *fsm.Event, state string) {
 	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
@@ -690,6 +1145,11 @@ func (handler *Handler) handleRangeQueryState(msg *pb.ChaincodeMessage) {
 			handler.serialSend(serialSendMsg)
 		}()
 
+		if err := handler.checkLimits(msg.Uuid, 0); err != nil {
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Uuid: msg.Uuid}
+			return
+		}
+
 		rangeQueryState := &pb.RangeQueryState{}
 		unmarshalErr := proto.Unmarshal(msg.Payload, rangeQueryState)
 		if unmarshalErr != nil {
@@ -721,6 +1181,14 @@ func (handler *Handler) handleRangeQueryState(msg *pb.ChaincodeMessage) {
 			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
 			return
 		}
+		if rangeQueryState.Ordered {
+			if rangeIter, err = newSortedRangeScanIterator(rangeIter, rangeQueryState.Limit, rangeQueryState.Reverse); err != nil {
+				payload := []byte(err.Error())
+				chaincodeLogger.Errorf("Failed to sort range scan. Sending %s", pb.ChaincodeMessage_ERROR)
+				serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+				return
+			}
+		}
 
 		iterID := util.GenerateUUID()
 		txContext := handler.getTxContext(msg.Uuid)
@@ -728,6 +1196,7 @@ func (handler *Handler) handleRangeQueryState(msg *pb.ChaincodeMessage) {
 
 		hasNext = rangeIter.Next()
 
+		recorder := handler.getInvocation(msg.Uuid).recorder
 		var keysAndValues []*pb.RangeQueryStateKeyValue
 		var i = uint32(0)
 		for ; hasNext && i < maxRangeQueryStateLimit; i++ {
@@ -744,6 +1213,7 @@ func (handler *Handler) handleRangeQueryState(msg *pb.ChaincodeMessage) {
 
 				return
 			}
+			recorder.read(handler.ChaincodeID.Name, key, decryptedValue)
 			keyAndValue := pb.RangeQueryStateKeyValue{Key: key, Value: decryptedValue}
 			keysAndValues = append(keysAndValues, &keyAndValue)
 
@@ -810,6 +1280,11 @@ func (handler *Handler) handleRangeQueryStateNext(msg *pb.ChaincodeMessage) {
 			handler.serialSend(serialSendMsg)
 		}()
 
+		if err := handler.checkLimits(msg.Uuid, 0); err != nil {
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Uuid: msg.Uuid}
+			return
+		}
+
 		rangeQueryStateNext := &pb.RangeQueryStateNext{}
 		unmarshalErr := proto.Unmarshal(msg.Payload, rangeQueryStateNext)
 		if unmarshalErr != nil {
@@ -829,6 +1304,7 @@ func (handler *Handler) handleRangeQueryStateNext(msg *pb.ChaincodeMessage) {
 			return
 		}
 
+		recorder := handler.getInvocation(msg.Uuid).recorder
 		var keysAndValues []*pb.RangeQueryStateKeyValue
 		var i = uint32(0)
 		hasNext := true
@@ -846,6 +1322,7 @@ func (handler *Handler) handleRangeQueryStateNext(msg *pb.ChaincodeMessage) {
 
 				return
 			}
+			recorder.read(handler.ChaincodeID.Name, key, decryptedValue)
 			keyAndValue := pb.RangeQueryStateKeyValue{Key: key, Value: decryptedValue}
 			keysAndValues = append(keysAndValues, &keyAndValue)
 
@@ -1035,15 +1512,34 @@ func (handler *Handler) enterBusyState(e *fsm.Event, state string) {
 			}
 
 			var pVal []byte
-			// Encrypt the data if the confidential is enabled
-			if pVal, err = handler.encrypt(msg.Uuid, putStateInfo.Value); err == nil {
-				// Invoke ledger to put state
-				err = ledgerObj.SetState(chaincodeID, putStateInfo.Key, pVal)
+			invocation := handler.getInvocation(msg.Uuid)
+			if err = handler.checkLimits(msg.Uuid, len(putStateInfo.Value)); err == nil {
+				// the state written by a simulated transaction is kept from the ledger
+				if !invocation.recorder.simulating() {
+					// Encrypt the data if the confidential is enabled
+					if pVal, err = handler.encrypt(msg.Uuid, putStateInfo.Value); err == nil {
+						// Invoke ledger to put state
+						err = ledgerObj.SetState(chaincodeID, putStateInfo.Key, pVal)
+					}
+				}
+				if err == nil {
+					invocation.recorder.write(chaincodeID, putStateInfo.Key, putStateInfo.Value, false)
+					invocation.modified(putStateInfo.Key)
+				}
 			}
 		} else if msg.Type.String() == pb.ChaincodeMessage_DEL_STATE.String() {
-			// Invoke ledger to delete state
-			key := string(msg.Payload)
-			err = ledgerObj.DeleteState(chaincodeID, key)
+			invocation := handler.getInvocation(msg.Uuid)
+			if err = handler.checkLimits(msg.Uuid, 0); err == nil {
+				key := string(msg.Payload)
+				if !invocation.recorder.simulating() {
+					// Invoke ledger to delete state
+					err = ledgerObj.DeleteState(chaincodeID, key)
+				}
+				if err == nil {
+					invocation.recorder.write(chaincodeID, key, nil, true)
+					invocation.modified(key)
+				}
+			}
 		} else if msg.Type.String() == pb.ChaincodeMessage_INVOKE_CHAINCODE.String() {
 			//check and prohibit C-call-C for CONFIDENTIAL txs
 			if triggerNextStateMsg = handler.canCallChaincode(msg.Uuid); triggerNextStateMsg != nil {
@@ -1062,11 +1558,16 @@ func (handler *Handler) enterBusyState(e *fsm.Event, state string) {
 			newChaincodeID := chaincodeSpec.ChaincodeID.Name
 
 			// Create the transaction object
-			chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: chaincodeSpec}
-			transaction, _ := pb.NewChaincodeExecute(chaincodeInvocationSpec, msg.Uuid, pb.Transaction_CHAINCODE_INVOKE)
+			ctxt, invocation, transaction, invokeErr := handler.prepareInvocation(msg.Uuid, chaincodeSpec, pb.Transaction_CHAINCODE_INVOKE)
+			if invokeErr != nil {
+				payload := []byte(invokeErr.Error())
+				chaincodeLogger.Errorf("[%s]%s. Sending %s", shortuuid(msg.Uuid), invokeErr, pb.ChaincodeMessage_ERROR)
+				triggerNextStateMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+				return
+			}
 
 			// Launch the new chaincode if not already running
-			_, chaincodeInput, launchErr := handler.chaincodeSupport.Launch(context.Background(), transaction)
+			_, chaincodeInput, launchErr := handler.chaincodeSupport.Launch(ctxt, transaction)
 			if launchErr != nil {
 				payload := []byte(launchErr.Error())
 				chaincodeLogger.Errorf("[%s]Failed to launch invoked chaincode. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_ERROR)
@@ -1074,14 +1575,11 @@ func (handler *Handler) enterBusyState(e *fsm.Event, state string) {
 				return
 			}
 
-			// TODO: Need to handle timeout correctly
-			timeout := time.Duration(30000) * time.Millisecond
-
 			ccMsg, _ := createTransactionMessage(transaction.Uuid, chaincodeInput)
 
 			// Execute the chaincode
 			//NOTE: when confidential C-call-C is understood, transaction should have the correct sec context for enc/dec
-			response, execErr := handler.chaincodeSupport.Execute(context.Background(), newChaincodeID, ccMsg, timeout, transaction)
+			response, execErr := handler.chaincodeSupport.Execute(ctxt, newChaincodeID, ccMsg, handler.chaincodeSupport.executeTimeout, transaction)
 
 			//payload is marshalled and send to the calling chaincode's shim which unmarshals and
 			//sends it to chaincode
@@ -1089,6 +1587,11 @@ func (handler *Handler) enterBusyState(e *fsm.Event, state string) {
 			if execErr != nil {
 				err = execErr
 			} else {
+				// the events belong to the invocation, not to the caller
+				if response.Type == pb.ChaincodeMessage_COMPLETED {
+					invocation.completed(msg.Uuid, response.ChaincodeEvents)
+				}
+				response.ChaincodeEvents = nil
 				res, err = proto.Marshal(response)
 			}
 		}
@@ -1129,12 +1632,22 @@ func (handler *Handler) enterInitState(e *fsm.Event, state string) {
 	}
 }
 
+// hasEventPayload returns true if any of the events carries a payload
+func hasEventPayload(events []*pb.ChaincodeEvent) bool {
+	for _, event := range events {
+		if event != nil && event.Payload != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func (handler *Handler) enterReadyState(e *fsm.Event, state string) {
 	// Now notify
 	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
 	//we have to encrypt chaincode event payload. We cannot encrypt event type as
 	//it is needed by the event system to filter clients by
-	if ok && msg.ChaincodeEvent != nil && msg.ChaincodeEvent.Payload != nil {
+	if ok && hasEventPayload(msg.ChaincodeEvents) {
 		var err error
 		if msg.Payload, err = handler.encrypt(msg.Uuid, msg.Payload); nil != err {
 			chaincodeLogger.Errorf("[%s]Failed to encrypt chaincode event payload", msg.Uuid)
@@ -1196,6 +1709,13 @@ func (handler *Handler) initializeSecContext(tx, depTx *pb.Transaction) error {
 		}
 	}
 
+	//the limits are declared by the chaincode spec of the deploy or upgrade transaction
+	cds := &pb.ChaincodeDeploymentSpec{}
+	if err := proto.Unmarshal(handler.deployTXSecContext.Payload, cds); err != nil {
+		return fmt.Errorf("Failed to unmarshall : %s\n", err)
+	}
+	handler.setLimits(cds.ChaincodeSpec)
+
 	//don't need the payload which is not useful and rather large
 	handler.deployTXSecContext.Payload = nil
 
@@ -1340,11 +1860,16 @@ func (handler *Handler) handleQueryChaincode(msg *pb.ChaincodeMessage) {
 		newChaincodeID := chaincodeSpec.ChaincodeID.Name
 
 		// Create the transaction object
-		chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: chaincodeSpec}
-		transaction, _ := pb.NewChaincodeExecute(chaincodeInvocationSpec, msg.Uuid, pb.Transaction_CHAINCODE_QUERY)
+		ctxt, _, transaction, invokeErr := handler.prepareInvocation(msg.Uuid, chaincodeSpec, pb.Transaction_CHAINCODE_QUERY)
+		if invokeErr != nil {
+			payload := []byte(invokeErr.Error())
+			chaincodeLogger.Errorf("[%s]%s. Sending %s", shortuuid(msg.Uuid), invokeErr, pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+			return
+		}
 
 		// Launch the new chaincode if not already running
-		_, chaincodeInput, launchErr := handler.chaincodeSupport.Launch(context.Background(), transaction)
+		_, chaincodeInput, launchErr := handler.chaincodeSupport.Launch(ctxt, transaction)
 		if launchErr != nil {
 			payload := []byte(launchErr.Error())
 			chaincodeLogger.Errorf("[%s]Failed to launch invoked chaincode. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_ERROR)
@@ -1352,14 +1877,11 @@ func (handler *Handler) handleQueryChaincode(msg *pb.ChaincodeMessage) {
 			return
 		}
 
-		// TODO: Need to handle timeout correctly
-		timeout := time.Duration(30000) * time.Millisecond
-
 		ccMsg, _ := createQueryMessage(transaction.Uuid, chaincodeInput)
 
 		// Query the chaincode
 		//NOTE: when confidential C-call-C is understood, transaction should have the correct sec context for enc/dec
-		response, execErr := handler.chaincodeSupport.Execute(context.Background(), newChaincodeID, ccMsg, timeout, transaction)
+		response, execErr := handler.chaincodeSupport.Execute(ctxt, newChaincodeID, ccMsg, handler.chaincodeSupport.executeTimeout, transaction)
 
 		if execErr != nil {
 			// Send error msg back to chaincode and trigger event
@@ -1457,8 +1979,8 @@ func filterError(errFromFSMEvent error) error {
 	return nil
 }
 
-func (handler *Handler) sendExecuteMessage(msg *pb.ChaincodeMessage, tx *pb.Transaction) (chan *pb.ChaincodeMessage, error) {
-	txctx, err := handler.createTxContext(msg.Uuid, tx)
+func (handler *Handler) sendExecuteMessage(msg *pb.ChaincodeMessage, tx *pb.Transaction, invocation *invocationContext) (chan *pb.ChaincodeMessage, error) {
+	txctx, err := handler.createInvocationTxContext(msg.Uuid, tx, invocation)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,131 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/crypto/attributes"
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// mockChaincodeStream collects the messages sent to the chaincode
+type mockChaincodeStream struct {
+	sent chan *pb.ChaincodeMessage
+}
+
+func (s *mockChaincodeStream) Send(msg *pb.ChaincodeMessage) error {
+	s.sent <- msg
+	return nil
+}
+
+func (s *mockChaincodeStream) Recv() (*pb.ChaincodeMessage, error) {
+	select {}
+}
+
+func TestGetHistoryForKeyInTransaction(t *testing.T) {
+	stream := &mockChaincodeStream{sent: make(chan *pb.ChaincodeMessage, 1)}
+	handler := &Handler{
+		ChatStream:    stream,
+		ChaincodeID:   &pb.ChaincodeID{Name: "history"},
+		uuidMap:       make(map[string]bool),
+		isTransaction: make(map[string]bool),
+	}
+	handler.markIsTransaction("tx", true)
+
+	handler.handleGetHistoryForKey(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY, Payload: []byte("a"), Uuid: "tx"})
+	select {
+	case msg := <-stream.sent:
+		if msg.Type != pb.ChaincodeMessage_ERROR || msg.Uuid != "tx" {
+			t.Fatalf("Expected the history request of a transaction to be rejected, got %s", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the response to the history request")
+	}
+}
+
+// newTestCertificate returns a self-signed certificate issued to commonName
+func newTestCertificate(t *testing.T, commonName string, extensions ...pkix.Extension) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(time.Now().UnixNano()),
+		Subject:         pkix.Name{CommonName: commonName},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtraExtensions: extensions,
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// newTestTCertificate returns a certificate carrying enrollmentID encrypted under key, like a TCert
+func newTestTCertificate(t *testing.T, enrollmentID string, key []byte) []byte {
+	encEnrollmentID, err := attributes.EncryptAttributeValue(key, []byte(enrollmentID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestCertificate(t, "Transaction Certificate", pkix.Extension{Id: primitives.TCertEncEnrollmentID, Value: encEnrollmentID})
+}
+
+func TestCheckChaincodeDeployer(t *testing.T) {
+	key := make([]byte, 32)
+	otherKey := make([]byte, 32)
+	otherKey[0] = 1
+
+	depTx := &pb.Transaction{Type: pb.Transaction_CHAINCODE_DEPLOY, Cert: newTestTCertificate(t, "alice", key), EnrollmentIDKey: key}
+
+	// The deployer may sign with a new TCert or with its ECert
+	for _, upgradeTx := range []*pb.Transaction{
+		{Type: pb.Transaction_CHAINCODE_UPGRADE, Cert: newTestTCertificate(t, "alice", otherKey), EnrollmentIDKey: otherKey},
+		{Type: pb.Transaction_CHAINCODE_UPGRADE, Cert: newTestCertificate(t, "alice")},
+	} {
+		if err := checkChaincodeDeployer("mycc", depTx, upgradeTx); err != nil {
+			t.Fatalf("Upgrade by the deployer should be authorized: %s", err)
+		}
+	}
+
+	for _, terminateTx := range []*pb.Transaction{
+		{Type: pb.Transaction_CHAINCODE_TERMINATE, Cert: newTestTCertificate(t, "bob", otherKey), EnrollmentIDKey: otherKey},
+		{Type: pb.Transaction_CHAINCODE_TERMINATE, Cert: newTestTCertificate(t, "alice", key), EnrollmentIDKey: otherKey},
+		{Type: pb.Transaction_CHAINCODE_TERMINATE, Cert: newTestTCertificate(t, "alice", key)},
+		{Type: pb.Transaction_CHAINCODE_TERMINATE, Cert: newTestCertificate(t, "bob")},
+		{Type: pb.Transaction_CHAINCODE_TERMINATE},
+	} {
+		if err := checkChaincodeDeployer("mycc", depTx, terminateTx); err == nil {
+			t.Fatalf("Termination %v should not be authorized", terminateTx)
+		}
+	}
+
+	// Without security transactions carry no certificate
+	if err := checkChaincodeDeployer("mycc", &pb.Transaction{}, &pb.Transaction{}); err != nil {
+		t.Fatalf("Upgrade without security should be authorized: %s", err)
+	}
+}
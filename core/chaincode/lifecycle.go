@@ -0,0 +1,135 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/core/crypto/attributes"
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/ledger"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// lifecycleNamespace is the state namespace holding the ChaincodeLifecycle of upgraded and
+// terminated chaincodes, keyed by chaincode name
+const lifecycleNamespace = "__lifecycle"
+
+// getChaincodeLifecycle returns the lifecycle recorded for a chaincode. A chaincode that was never
+// upgraded or terminated has no record; its code is carried by the deploy transaction whose UUID
+// is the chaincode name.
+func getChaincodeLifecycle(ledger *ledger.Ledger, chaincode string) (*pb.ChaincodeLifecycle, error) {
+	data, err := ledger.GetState(lifecycleNamespace, chaincode, false)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get lifecycle of %s (%s)", chaincode, err)
+	}
+	lifecycle := &pb.ChaincodeLifecycle{DeploymentUuid: chaincode}
+	if data == nil {
+		return lifecycle, nil
+	}
+	if err = proto.Unmarshal(data, lifecycle); err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal lifecycle of %s (%s)", chaincode, err)
+	}
+	return lifecycle, nil
+}
+
+// setChaincodeLifecycle records the lifecycle of a chaincode as part of the current transaction
+func setChaincodeLifecycle(ledger *ledger.Ledger, chaincode string, lifecycle *pb.ChaincodeLifecycle) error {
+	data, err := proto.Marshal(lifecycle)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal lifecycle of %s (%s)", chaincode, err)
+	}
+	return ledger.SetState(lifecycleNamespace, chaincode, data)
+}
+
+// getDeploymentTransaction returns the deploy or upgrade transaction carrying the code a chaincode
+// currently runs. It fails if the chaincode has been terminated.
+func getDeploymentTransaction(ledger *ledger.Ledger, chaincode string) (*pb.Transaction, error) {
+	lifecycle, err := getChaincodeLifecycle(ledger, chaincode)
+	if err != nil {
+		return nil, err
+	}
+	if lifecycle.Status == pb.ChaincodeLifecycle_TERMINATED {
+		return nil, fmt.Errorf("chaincode %s has been terminated", chaincode)
+	}
+	depTx, err := ledger.GetTransactionByUUID(lifecycle.DeploymentUuid)
+	if err != nil {
+		return nil, fmt.Errorf("Could not get deployment transaction for %s - %s", chaincode, err)
+	}
+	if depTx == nil {
+		return nil, fmt.Errorf("deployment transaction does not exist for %s", chaincode)
+	}
+	return depTx, nil
+}
+
+// GetDeploymentTransaction returns the deploy or upgrade transaction carrying the code a chaincode
+// currently runs. It fails if the chaincode has been terminated.
+func GetDeploymentTransaction(chaincode string) (*pb.Transaction, error) {
+	lgr, err := ledger.GetLedger()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get handle to ledger (%s)", err)
+	}
+	return getDeploymentTransaction(lgr, chaincode)
+}
+
+// getEnrollmentID returns the enrollment ID of the user who signed a transaction. An ECert is
+// issued to the enrollment ID. A TCert carries the enrollment ID encrypted, and the transaction
+// carries the key to decrypt it.
+func getEnrollmentID(t *pb.Transaction) (string, error) {
+	cert, err := primitives.DERToX509Certificate(t.Cert)
+	if err != nil {
+		return "", err
+	}
+	if _, err = primitives.GetCriticalExtension(cert, primitives.TCertEncEnrollmentID); err != nil {
+		return cert.Subject.CommonName, nil
+	}
+	return attributes.ReadTCertEnrollmentID(cert, t.EnrollmentIDKey)
+}
+
+// checkChaincodeDeployer checks that an upgrade or terminate transaction is signed by the user who
+// deployed the chaincode. Users are told apart by their enrollment ID, so they may sign with any of
+// their certificates. Without security transactions carry no certificate and are not checked.
+func checkChaincodeDeployer(chaincode string, depTx *pb.Transaction, t *pb.Transaction) error {
+	if len(depTx.Cert) == 0 {
+		return nil
+	}
+	deployer, err := getEnrollmentID(depTx)
+	if err != nil {
+		return fmt.Errorf("Failed to get the deployer of chaincode %s (%s)", chaincode, err)
+	}
+	user, err := getEnrollmentID(t)
+	if err != nil {
+		return fmt.Errorf("Failed to get the signer of %s of chaincode %s (%s)", t.Type, chaincode, err)
+	}
+	if user != deployer {
+		return fmt.Errorf("%s of chaincode %s is not signed by its deployer", t.Type, chaincode)
+	}
+	return nil
+}
+
+// getChaincodeVersion returns the version of the code deployed by a deploy or upgrade transaction.
+// Upgrade transactions are named after the hash of the code they carry, which becomes the version
+// of the chaincode; chaincodes that were never upgraded are named after their code and have no
+// version.
+func getChaincodeVersion(depTx *pb.Transaction) string {
+	if depTx.Type == pb.Transaction_CHAINCODE_UPGRADE {
+		return depTx.Uuid
+	}
+	return ""
+}
@@ -0,0 +1,54 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package golang
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// Build compiles the chaincode with the local Go toolchain, outside of a container. gopath is
+// the directory the package written by WritePackage was extracted to, the executable is written
+// to output. The output of the compiler is returned whether the build fails or not.
+func (goPlatform *Platform) Build(spec *pb.ChaincodeSpec, gopath string, output string) ([]byte, error) {
+	urlLocation, _, err := getCodeLocation(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	//the package carries the whole GOPATH of the deployer, so the build
+	//must not see the one of the peer
+	env := []string{"GOPATH=" + gopath}
+	for _, v := range os.Environ() {
+		if !strings.HasPrefix(v, "GOPATH=") {
+			env = append(env, v)
+		}
+	}
+
+	cmd := exec.Command("go", "build", "-o", output, urlLocation)
+	cmd.Dir = gopath
+	cmd.Env = env
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return out, fmt.Errorf("Error building chaincode %s: %s", urlLocation, err)
+	}
+	return out, nil
+}
@@ -28,10 +28,9 @@ import (
 	pb "github.com/hyperledger/fabric/protos"
 )
 
-//tw is expected to have the chaincode in it from GenerateHashcode. This method
-//will just package rest of the bytes
-func writeChaincodePackage(spec *pb.ChaincodeSpec, tw *tar.Writer) error {
-
+//getCodeLocation returns the import path of the chaincode in the package and
+//the name of its executable
+func getCodeLocation(spec *pb.ChaincodeSpec) (string, string, error) {
 	var urlLocation string
 	if strings.HasPrefix(spec.ChaincodeID.Path, "http://") {
 		urlLocation = spec.ChaincodeID.Path[7:]
@@ -42,7 +41,7 @@ func writeChaincodePackage(spec *pb.ChaincodeSpec, tw *tar.Writer) error {
 	}
 
 	if urlLocation == "" {
-		return fmt.Errorf("empty url location")
+		return "", "", fmt.Errorf("empty url location")
 	}
 
 	if strings.LastIndex(urlLocation, "/") == len(urlLocation)-1 {
@@ -50,12 +49,23 @@ func writeChaincodePackage(spec *pb.ChaincodeSpec, tw *tar.Writer) error {
 	}
 	toks := strings.Split(urlLocation, "/")
 	if toks == nil || len(toks) == 0 {
-		return fmt.Errorf("cannot get path components from %s", urlLocation)
+		return "", "", fmt.Errorf("cannot get path components from %s", urlLocation)
 	}
 
 	chaincodeGoName := toks[len(toks)-1]
 	if chaincodeGoName == "" {
-		return fmt.Errorf("could not get chaincode name from path %s", urlLocation)
+		return "", "", fmt.Errorf("could not get chaincode name from path %s", urlLocation)
+	}
+	return urlLocation, chaincodeGoName, nil
+}
+
+//tw is expected to have the chaincode in it from GenerateHashcode. This method
+//will just package rest of the bytes
+func writeChaincodePackage(spec *pb.ChaincodeSpec, tw *tar.Writer) error {
+
+	urlLocation, chaincodeGoName, err := getCodeLocation(spec)
+	if err != nil {
+		return err
 	}
 
 	//let the executable's name be chaincode ID's name
@@ -76,7 +86,7 @@ func writeChaincodePackage(spec *pb.ChaincodeSpec, tw *tar.Writer) error {
 	var zeroTime time.Time
 	tw.WriteHeader(&tar.Header{Name: "Dockerfile", Size: dockerFileSize, ModTime: zeroTime, AccessTime: zeroTime, ChangeTime: zeroTime})
 	tw.Write([]byte(dockerFileContents))
-	err := cutil.WriteGopathSrc(tw, urlLocation)
+	err = cutil.WriteGopathSrc(tw, urlLocation)
 	if err != nil {
 		return fmt.Errorf("Error writing Chaincode package contents: %s", err)
 	}
@@ -27,6 +27,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	gp "google/protobuf"
 
@@ -68,7 +69,7 @@ type Chaincode interface {
 type ChaincodeStub struct {
 	UUID            string
 	securityContext *pb.ChaincodeSecurityContext
-	chaincodeEvent  *pb.ChaincodeEvent
+	chaincodeEvents []*pb.ChaincodeEvent
 }
 
 // Peer address derived from command line or env var
@@ -275,6 +276,14 @@ func (stub *ChaincodeStub) GetState(key string) ([]byte, error) {
 	return handler.handleGetState(key, stub.UUID)
 }
 
+// GetHistoryForKey returns the modifications made to the `key` by committed
+// transactions, oldest first, each with its block number and transaction UUID.
+// Changes made by the current transaction are not included. The history is
+// only available to queries, it is rejected when invoked by a transaction.
+func (stub *ChaincodeStub) GetHistoryForKey(key string) ([]*pb.KeyModification, error) {
+	return handler.handleGetHistoryForKey(key, stub.UUID)
+}
+
 // PutState writes the specified `value` and `key` into the ledger.
 func (stub *ChaincodeStub) PutState(key string, value []byte) error {
 	return handler.handlePutState(key, value, stub.UUID)
@@ -333,7 +342,19 @@ type StateRangeQueryIterator struct {
 // between the startKey and endKey, inclusive. The order in which keys are
 // returned by the iterator is random.
 func (stub *ChaincodeStub) RangeQueryState(startKey, endKey string) (*StateRangeQueryIterator, error) {
-	response, err := handler.handleRangeQueryState(startKey, endKey, stub.UUID)
+	return stub.rangeQueryState(&pb.RangeQueryState{StartKey: startKey, EndKey: endKey})
+}
+
+// OrderedRangeQueryState works like RangeQueryState, except that the keys are
+// returned in lexical order, or in reverse lexical order if reverse is true,
+// and at most limit keys are returned unless limit is 0. The peer scans the
+// whole range to select the keys, and refuses ranges of more than 10000 keys.
+func (stub *ChaincodeStub) OrderedRangeQueryState(startKey, endKey string, limit uint32, reverse bool) (*StateRangeQueryIterator, error) {
+	return stub.rangeQueryState(&pb.RangeQueryState{StartKey: startKey, EndKey: endKey, Ordered: true, Reverse: reverse, Limit: limit})
+}
+
+func (stub *ChaincodeStub) rangeQueryState(rangeQueryState *pb.RangeQueryState) (*StateRangeQueryIterator, error) {
+	response, err := handler.handleRangeQueryState(rangeQueryState, stub.UUID)
 	if err != nil {
 		return nil, err
 	}
@@ -380,6 +401,92 @@ func (iter *StateRangeQueryIterator) Close() error {
 	return err
 }
 
+// COMPOSITE KEY FUNCTIONALITY
+
+// Composite keys start with compositeKeyNamespace, so they cannot clash with
+// simple keys or table rows, and end each of their components with
+// compositeKeyDelimiter, so the keys sharing leading components form a range.
+const (
+	compositeKeyNamespace = "\x00"
+	compositeKeyDelimiter = "\x00"
+)
+
+// CreateCompositeKey combines the given objectType and attributes into a key
+// that can be used with GetState, PutState and DelState. Composite keys sort
+// by objectType and then by each attribute in turn, which allows chaincodes
+// to maintain secondary indexes. The objectType and attributes must be valid
+// UTF-8 and may not contain U+0000 or U+10FFFF.
+func (stub *ChaincodeStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	if err := validateCompositeKeyComponent(objectType); err != nil {
+		return "", err
+	}
+	var keyBuffer bytes.Buffer
+	keyBuffer.WriteString(compositeKeyNamespace)
+	keyBuffer.WriteString(objectType)
+	keyBuffer.WriteString(compositeKeyDelimiter)
+	for _, attribute := range attributes {
+		if err := validateCompositeKeyComponent(attribute); err != nil {
+			return "", err
+		}
+		keyBuffer.WriteString(attribute)
+		keyBuffer.WriteString(compositeKeyDelimiter)
+	}
+	return keyBuffer.String(), nil
+}
+
+// SplitCompositeKey splits a key created by CreateCompositeKey into its
+// objectType and attributes.
+func (stub *ChaincodeStub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	if len(compositeKey) < len(compositeKeyNamespace)+len(compositeKeyDelimiter) ||
+		!strings.HasPrefix(compositeKey, compositeKeyNamespace) || !strings.HasSuffix(compositeKey, compositeKeyDelimiter) {
+		return "", nil, fmt.Errorf("Key '%s' is not a composite key", compositeKey)
+	}
+	components := strings.Split(compositeKey[len(compositeKeyNamespace):len(compositeKey)-len(compositeKeyDelimiter)], compositeKeyDelimiter)
+	return components[0], components[1:], nil
+}
+
+// PartialCompositeKeyQuery returns an iterator over the composite keys, and
+// their values, of the given objectType whose leading attributes match the
+// given attributes. Like RangeQueryState, the keys are returned in no specific
+// order.
+func (stub *ChaincodeStub) PartialCompositeKeyQuery(objectType string, attributes []string) (*StateRangeQueryIterator, error) {
+	startKey, endKey, err := stub.partialCompositeKeyRange(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+	return stub.RangeQueryState(startKey, endKey)
+}
+
+// OrderedPartialCompositeKeyQuery works like PartialCompositeKeyQuery, except
+// that the keys are returned in order, as by OrderedRangeQueryState.
+func (stub *ChaincodeStub) OrderedPartialCompositeKeyQuery(objectType string, attributes []string, limit uint32, reverse bool) (*StateRangeQueryIterator, error) {
+	startKey, endKey, err := stub.partialCompositeKeyRange(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+	return stub.OrderedRangeQueryState(startKey, endKey, limit, reverse)
+}
+
+func (stub *ChaincodeStub) partialCompositeKeyRange(objectType string, attributes []string) (string, string, error) {
+	startKey, err := stub.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return "", "", err
+	}
+	// No valid component contains utf8.MaxRune, so every key starting with
+	// startKey sorts before endKey
+	return startKey, startKey + string(utf8.MaxRune), nil
+}
+
+func validateCompositeKeyComponent(component string) error {
+	if !utf8.ValidString(component) {
+		return fmt.Errorf("Composite key component '%x' is not valid UTF-8", component)
+	}
+	if strings.ContainsRune(component, 0) || strings.ContainsRune(component, utf8.MaxRune) {
+		return fmt.Errorf("Composite key component '%s' may not contain U+0000 or U+10FFFF", component)
+	}
+	return nil
+}
+
 // TABLE FUNCTIONALITY
 // TODO More comments here with documentation
 
@@ -839,9 +946,11 @@ func (stub *ChaincodeStub) insertRowInternal(tableName string, row Row, update b
 
 // ------------- ChaincodeEvent API ----------------------
 
-// SetEvent saves the event to be sent when a transaction is made part of a block
+// SetEvent saves the event to be sent when a transaction is made part of a block.
+// A transaction may set several events, including several of the same name, which
+// are sent in the order they were set.
 func (stub *ChaincodeStub) SetEvent(name string, payload []byte) error {
-	stub.chaincodeEvent = &pb.ChaincodeEvent{EventName: name, Payload: payload}
+	stub.chaincodeEvents = append(stub.chaincodeEvents, &pb.ChaincodeEvent{EventName: name, Payload: payload})
 	return nil
 }
 
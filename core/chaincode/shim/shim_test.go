@@ -134,3 +134,58 @@ func TestChaincodeLogging(t *testing.T) {
 		t.Errorf("'bar' should be enabled for LogCritical")
 	}
 }
+
+// TestCompositeKey tests that composite keys split back into their
+// components and that partial keys bound the keys they prefix.
+func TestCompositeKey(t *testing.T) {
+	stub := &ChaincodeStub{}
+
+	key, err := stub.CreateCompositeKey("color~name", []string{"blue", "marble1"})
+	if err != nil {
+		t.Fatalf("Error creating composite key: %s", err)
+	}
+	objectType, attributes, err := stub.SplitCompositeKey(key)
+	if err != nil {
+		t.Fatalf("Error splitting composite key: %s", err)
+	}
+	if objectType != "color~name" || len(attributes) != 2 || attributes[0] != "blue" || attributes[1] != "marble1" {
+		t.Errorf("Expected color~name [blue marble1] but got %s %v", objectType, attributes)
+	}
+
+	startKey, endKey, err := stub.partialCompositeKeyRange("color~name", []string{"blue"})
+	if err != nil {
+		t.Fatalf("Error getting partial composite key range: %s", err)
+	}
+	if key < startKey || key > endKey {
+		t.Errorf("Composite key %q is outside the range of its partial key [%q, %q]", key, startKey, endKey)
+	}
+	otherKey, _ := stub.CreateCompositeKey("color~name", []string{"bluegreen", "marble2"})
+	if otherKey >= startKey && otherKey <= endKey {
+		t.Errorf("Composite key %q is inside the range of a different partial key [%q, %q]", otherKey, startKey, endKey)
+	}
+
+	if _, err = stub.CreateCompositeKey("color~name", []string{"blue\x00"}); err == nil {
+		t.Errorf("Expected an error creating a composite key with U+0000")
+	}
+	if _, _, err = stub.SplitCompositeKey("marble1"); err == nil {
+		t.Errorf("Expected an error splitting a simple key")
+	}
+}
+
+func TestSetEvent(t *testing.T) {
+	stub := &ChaincodeStub{}
+
+	stub.SetEvent("transfer", []byte("a->b"))
+	stub.SetEvent("fee", []byte("1"))
+	stub.SetEvent("transfer", []byte("a->c"))
+
+	expected := []string{"transfer a->b", "fee 1", "transfer a->c"}
+	if len(stub.chaincodeEvents) != len(expected) {
+		t.Fatalf("Expected %d events, got %d", len(expected), len(stub.chaincodeEvents))
+	}
+	for i, event := range stub.chaincodeEvents {
+		if actual := event.EventName + " " + string(event.Payload); actual != expected[i] {
+			t.Errorf("Expected event %d to be %s, got %s", i, expected[i], actual)
+		}
+	}
+}
@@ -47,4 +47,7 @@ type CCID struct {
 	ChaincodeSpec *pb.ChaincodeSpec
 	NetworkID     string
 	PeerID        string
+	//Version distinguishes the code of an upgraded chaincode from the code
+	//it replaced. It is empty for chaincodes that were never upgraded
+	Version string
 }
@@ -26,6 +26,7 @@ import (
 	"github.com/hyperledger/fabric/core/container/ccintf"
 	"github.com/hyperledger/fabric/core/container/dockercontroller"
 	"github.com/hyperledger/fabric/core/container/inproccontroller"
+	"github.com/hyperledger/fabric/core/container/processcontroller"
 )
 
 //abstract virtual image for supporting arbitrary virual machines
@@ -57,8 +58,9 @@ var vmcontroller *VMController
 
 //constants for supported containers
 const (
-	DOCKER = "Docker"
-	SYSTEM = "System"
+	DOCKER  = "Docker"
+	SYSTEM  = "System"
+	PROCESS = "Process"
 )
 
 //NewVMController - creates/returns singleton
@@ -77,6 +79,8 @@ func (vmc *VMController) newVM(typ string) vm {
 		v = &dockercontroller.DockerVM{}
 	case SYSTEM:
 		v = &inproccontroller.InprocVM{}
+	case PROCESS:
+		v = &processcontroller.ProcessVM{}
 	default:
 		v = &dockercontroller.DockerVM{}
 	}
@@ -100,9 +100,29 @@ func getDockerHostConfig() *docker.HostConfig {
 	return hostConfig
 }
 
-func (vm *DockerVM) createContainer(ctxt context.Context, client *docker.Client, imageID string, containerID string, args []string, env []string, attachstdin bool, attachstdout bool) error {
+//getContainerHostConfig applies the limits declared by the chaincode to the host config of its container
+func getContainerHostConfig(ccid ccintf.CCID) *docker.HostConfig {
+	limits := ccid.ChaincodeSpec.GetLimits()
+	if limits == nil {
+		return getDockerHostConfig()
+	}
+	config := *getDockerHostConfig()
+	if limits.CpuShares > 0 {
+		config.CPUShares = limits.CpuShares
+	}
+	if limits.Memory > 0 {
+		config.Memory = limits.Memory
+		//the memory and swap limits must stay consistent, let swap follow the memory limit
+		if config.MemorySwap > 0 && config.MemorySwap < limits.Memory {
+			config.MemorySwap = limits.Memory
+		}
+	}
+	return &config
+}
+
+func (vm *DockerVM) createContainer(ctxt context.Context, client *docker.Client, imageID string, containerID string, args []string, env []string, attachstdin bool, attachstdout bool, hostConfig *docker.HostConfig) error {
 	config := docker.Config{Cmd: args, Image: imageID, Env: env, AttachStdin: attachstdin, AttachStdout: attachstdout}
-	copts := docker.CreateContainerOptions{Name: containerID, Config: &config, HostConfig: getDockerHostConfig()}
+	copts := docker.CreateContainerOptions{Name: containerID, Config: &config, HostConfig: hostConfig}
 	dockerLogger.Debugf("Create container: %s", containerID)
 	_, err := client.CreateContainer(copts)
 	if err != nil {
@@ -160,13 +180,14 @@ func (vm *DockerVM) Start(ctxt context.Context, ccid ccintf.CCID, args []string,
 	}
 
 	containerID := strings.Replace(imageID, ":", "_", -1)
+	hostConfig := getContainerHostConfig(ccid)
 
 	//stop,force remove if necessary
 	dockerLogger.Debugf("Cleanup container %s", containerID)
 	vm.stopInternal(ctxt, client, containerID, 0, false, false)
 
 	dockerLogger.Debugf("Start container %s", containerID)
-	err = vm.createContainer(ctxt, client, imageID, containerID, args, env, attachstdin, attachstdout)
+	err = vm.createContainer(ctxt, client, imageID, containerID, args, env, attachstdin, attachstdout, hostConfig)
 	if err != nil {
 		//if image not found try to create image and retry
 		if err == docker.ErrNoSuchImage {
@@ -177,7 +198,7 @@ func (vm *DockerVM) Start(ctxt context.Context, ccid ccintf.CCID, args []string,
 				}
 
 				dockerLogger.Debug("start-recreated image successfully")
-				if err = vm.createContainer(ctxt, client, imageID, containerID, args, env, attachstdin, attachstdout); err != nil {
+				if err = vm.createContainer(ctxt, client, imageID, containerID, args, env, attachstdin, attachstdout, hostConfig); err != nil {
 					dockerLogger.Errorf("start-could not recreate container post recreate image: %s", err)
 					return err
 				}
@@ -191,10 +212,10 @@ func (vm *DockerVM) Start(ctxt context.Context, ccid ccintf.CCID, args []string,
 		}
 	}
 
-	// Baohua: getDockerHostConfig() will be ignored when communicating with docker API 1.24+.
+	// Baohua: the host config will be ignored when communicating with docker API 1.24+.
 	// I keep it here for a short-term compatibility.
 	// See https://goo.gl/ZvtkKm for more details.
-	err = client.StartContainer(containerID, getDockerHostConfig())
+	err = client.StartContainer(containerID, hostConfig)
 	if err != nil {
 		dockerLogger.Errorf("start-could not start container %s", err)
 		return err
@@ -268,12 +289,17 @@ func (vm *DockerVM) Destroy(ctxt context.Context, ccid ccintf.CCID, force bool,
 
 //GetVMName generates the docker image from peer information given the hashcode. This is needed to
 //keep image name's unique in a single host, multi-peer environment (such as a development environment)
+//An upgraded chaincode is named by its version (the hash of the new code) so the new image does not clash with the old one
 func (vm *DockerVM) GetVMName(ccid ccintf.CCID) (string, error) {
+	name := ccid.ChaincodeSpec.ChaincodeID.Name
+	if ccid.Version != "" {
+		name = ccid.Version
+	}
 	if ccid.NetworkID != "" {
-		return fmt.Sprintf("%s-%s-%s", ccid.NetworkID, ccid.PeerID, ccid.ChaincodeSpec.ChaincodeID.Name), nil
+		return fmt.Sprintf("%s-%s-%s", ccid.NetworkID, ccid.PeerID, name), nil
 	} else if ccid.PeerID != "" {
-		return fmt.Sprintf("%s-%s", ccid.PeerID, ccid.ChaincodeSpec.ChaincodeID.Name), nil
+		return fmt.Sprintf("%s-%s", ccid.PeerID, name), nil
 	} else {
-		return ccid.ChaincodeSpec.ChaincodeID.Name, nil
+		return name, nil
 	}
 }
@@ -25,7 +25,9 @@ import (
 
 	"github.com/fsouza/go-dockerclient"
 	"github.com/hyperledger/fabric/core/config"
+	"github.com/hyperledger/fabric/core/container/ccintf"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
+	pb "github.com/hyperledger/fabric/protos"
 )
 
 func TestHostConfig(t *testing.T) {
@@ -54,3 +56,31 @@ func TestGetDockerHostConfig(t *testing.T) {
 	testutil.AssertEquals(t, hostConfig.Memory, int64(1024*1024*1024*2))
 	testutil.AssertEquals(t, hostConfig.CPUShares, int64(1024*1024*1024*2))
 }
+
+func TestGetContainerHostConfig(t *testing.T) {
+	config.SetupTestConfig("./../../../peer")
+	defaults := getDockerHostConfig()
+
+	spec := &pb.ChaincodeSpec{ChaincodeID: &pb.ChaincodeID{Name: "mycc"}}
+	testutil.AssertEquals(t, getContainerHostConfig(ccintf.CCID{ChaincodeSpec: spec}), defaults)
+
+	spec.Limits = &pb.ChaincodeLimits{CpuShares: 512, Memory: 1024 * 1024 * 256}
+	hostConfig := getContainerHostConfig(ccintf.CCID{ChaincodeSpec: spec})
+	testutil.AssertEquals(t, hostConfig.CPUShares, int64(512))
+	testutil.AssertEquals(t, hostConfig.Memory, int64(1024*1024*256))
+	testutil.AssertEquals(t, hostConfig.NetworkMode, defaults.NetworkMode)
+	testutil.AssertNotEquals(t, defaults.Memory, int64(1024*1024*256))
+}
+
+func TestGetVMName(t *testing.T) {
+	vm := DockerVM{}
+	spec := &pb.ChaincodeSpec{ChaincodeID: &pb.ChaincodeID{Name: "mycc"}}
+
+	name, err := vm.GetVMName(ccintf.CCID{ChaincodeSpec: spec, NetworkID: "dev", PeerID: "vp0"})
+	testutil.AssertNoError(t, err, "Error getting VM name")
+	testutil.AssertEquals(t, name, "dev-vp0-mycc")
+
+	name, err = vm.GetVMName(ccintf.CCID{ChaincodeSpec: spec, NetworkID: "dev", PeerID: "vp0", Version: "v2hash"})
+	testutil.AssertNoError(t, err, "Error getting VM name")
+	testutil.AssertEquals(t, name, "dev-vp0-v2hash")
+}
@@ -0,0 +1,185 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processcontroller
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+//chaincodeProcess supervises the process of a running chaincode
+type chaincodeProcess struct {
+	sync.Mutex
+	name     string
+	binary   string
+	args     []string
+	env      []string
+	logPath  string
+	cmd      *exec.Cmd
+	restarts int
+	stopChan chan struct{}
+	done     chan struct{}
+}
+
+var (
+	processesLock sync.Mutex
+	processes     = make(map[string]*chaincodeProcess)
+)
+
+func getProcess(name string) *chaincodeProcess {
+	processesLock.Lock()
+	defer processesLock.Unlock()
+	return processes[name]
+}
+
+func putProcess(p *chaincodeProcess) {
+	processesLock.Lock()
+	defer processesLock.Unlock()
+	processes[p.name] = p
+}
+
+func deleteProcess(p *chaincodeProcess) {
+	processesLock.Lock()
+	defer processesLock.Unlock()
+	if processes[p.name] == p {
+		delete(processes, p.name)
+	}
+}
+
+func newProcess(name string, binary string, args []string, env []string, logPath string) *chaincodeProcess {
+	return &chaincodeProcess{
+		name:     name,
+		binary:   binary,
+		args:     args,
+		env:      env,
+		logPath:  logPath,
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+//start runs the executable, appending its output to the log of the chaincode. The caller
+//must hold the lock once the process is supervised.
+func (p *chaincodeProcess) start() error {
+	logFile, err := os.OpenFile(p.logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("Error opening log of chaincode %s: %s", p.name, err)
+	}
+	fmt.Fprintf(logFile, "%s starting %s\n", time.Now().Format(time.RFC3339), p.name)
+
+	cmd := exec.Command(p.binary, p.args...)
+	cmd.Env = p.env
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err = cmd.Start(); err != nil {
+		logFile.Close()
+		return err
+	}
+	//the child has its own descriptor of the log
+	logFile.Close()
+
+	p.cmd = cmd
+	return nil
+}
+
+func (p *chaincodeProcess) isStopped() bool {
+	select {
+	case <-p.stopChan:
+		return true
+	default:
+		return false
+	}
+}
+
+//supervise waits for the process to exit, restarting it up to vm.process.maxRestarts times
+//unless it was stopped
+func (p *chaincodeProcess) supervise() {
+	defer close(p.done)
+	defer deleteProcess(p)
+
+	maxRestarts := viper.GetInt("vm.process.maxRestarts")
+	restartDelay := viper.GetDuration("vm.process.restartDelay")
+	for {
+		p.Lock()
+		cmd := p.cmd
+		p.Unlock()
+		err := cmd.Wait()
+		if p.isStopped() {
+			processLogger.Debugf("Stopped process %s", p.name)
+			return
+		}
+		if p.restarts >= maxRestarts {
+			processLogger.Errorf("Chaincode %s exited (%v), giving up after %d restarts, see %s", p.name, err, p.restarts, p.logPath)
+			return
+		}
+		p.restarts++
+		processLogger.Warningf("Chaincode %s exited (%v), restarting in %s (%d/%d)", p.name, err, restartDelay, p.restarts, maxRestarts)
+
+		select {
+		case <-p.stopChan:
+			return
+		case <-time.After(restartDelay):
+		}
+		//stop must either see the new process or prevent it from starting
+		p.Lock()
+		if p.isStopped() {
+			p.Unlock()
+			return
+		}
+		err = p.start()
+		p.Unlock()
+		if err != nil {
+			processLogger.Errorf("Error restarting chaincode %s: %s", p.name, err)
+			return
+		}
+	}
+}
+
+//stop asks the process to terminate and kills it once timeout seconds have passed, unless
+//dontkill is set. It returns once the supervisor has returned or given up waiting.
+func (p *chaincodeProcess) stop(timeout uint, dontkill bool) error {
+	p.Lock()
+	if !p.isStopped() {
+		close(p.stopChan)
+	}
+	cmd := p.cmd
+	p.Unlock()
+
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		processLogger.Debugf("Terminate process %s (%s)", p.name, err)
+	}
+
+	select {
+	case <-p.done:
+		return nil
+	case <-time.After(time.Duration(timeout) * time.Second):
+	}
+	if dontkill {
+		return fmt.Errorf("%s did not terminate within %d seconds", p.name, timeout)
+	}
+	if err := cmd.Process.Kill(); err != nil {
+		processLogger.Debugf("Kill process %s (%s)", p.name, err)
+	}
+	<-p.done
+	return nil
+}
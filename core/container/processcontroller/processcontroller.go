@@ -0,0 +1,247 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processcontroller
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+
+	"github.com/hyperledger/fabric/core/chaincode/platforms/golang"
+	"github.com/hyperledger/fabric/core/container/ccintf"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+var processLogger = logging.MustGetLogger("processcontroller")
+
+const (
+	//each chaincode is built and run in its own directory under the work directory
+	binaryName = "chaincode"
+	logName    = "chaincode.log"
+)
+
+//ProcessVM is a vm that runs Go chaincodes as processes of the host, for machines without
+//docker. It is identified by the same name a docker image would have.
+type ProcessVM struct {
+}
+
+//getWorkDir returns the directory holding the chaincode directories
+func getWorkDir() string {
+	dir := viper.GetString("vm.process.dir")
+	if dir == "" {
+		dir = filepath.Join(viper.GetString("peer.fileSystemPath"), "chaincodes")
+	}
+	return dir
+}
+
+func (vm *ProcessVM) getDirs(ccid ccintf.CCID) (string, string, error) {
+	name, err := vm.GetVMName(ccid)
+	if err != nil {
+		return "", "", err
+	}
+	dir := filepath.Join(getWorkDir(), name)
+	return dir, filepath.Join(dir, "bin", binaryName), nil
+}
+
+//extract writes the files of the gzipped tar to dir
+func extract(reader io.Reader, dir string) error {
+	gr, err := gzip.NewReader(reader)
+	if err != nil {
+		return fmt.Errorf("Error reading chaincode package: %s", err)
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Error reading chaincode package: %s", err)
+		}
+		//only the source tree is needed, the Dockerfile is ignored
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+		if !strings.HasPrefix(header.Name, "src/") {
+			continue
+		}
+		path := filepath.Join(dir, header.Name)
+		if !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path %s in chaincode package", header.Name)
+		}
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("Error extracting %s from chaincode package: %s", header.Name, err)
+		}
+	}
+}
+
+func (vm *ProcessVM) build(ccid ccintf.CCID, reader io.Reader) error {
+	if ccid.ChaincodeSpec.Type != pb.ChaincodeSpec_GOLANG {
+		return fmt.Errorf("%s chaincodes cannot be run as a process", ccid.ChaincodeSpec.Type)
+	}
+	dir, binary, err := vm.getDirs(ccid)
+	if err != nil {
+		return err
+	}
+
+	//start from a clean source tree, an upgrade is deployed to a directory of its own
+	if err = os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err = extract(reader, dir); err != nil {
+		return err
+	}
+
+	platform := &golang.Platform{}
+	out, err := platform.Build(ccid.ChaincodeSpec, dir, binary)
+	if err != nil {
+		processLogger.Errorf("Error building chaincode: %s", err)
+		processLogger.Errorf("Build Output:\n********************\n%s\n********************", out)
+		return err
+	}
+
+	processLogger.Debugf("Built chaincode: %s", binary)
+	return nil
+}
+
+//Deploy extracts the chaincode package and builds the chaincode executable
+func (vm *ProcessVM) Deploy(ctxt context.Context, ccid ccintf.CCID, args []string, env []string, attachstdin bool, attachstdout bool, reader io.Reader) error {
+	return vm.build(ccid, reader)
+}
+
+//Start runs the chaincode executable, building it first if it was not deployed on this peer.
+//The process is restarted if it exits before it is stopped. args[0] names the executable in a
+//container and is replaced by the one built by Deploy.
+func (vm *ProcessVM) Start(ctxt context.Context, ccid ccintf.CCID, args []string, env []string, attachstdin bool, attachstdout bool, reader io.Reader) error {
+	name, err := vm.GetVMName(ccid)
+	if err != nil {
+		return err
+	}
+	dir, binary, err := vm.getDirs(ccid)
+	if err != nil {
+		return err
+	}
+
+	if _, err = os.Stat(binary); os.IsNotExist(err) {
+		if reader == nil {
+			processLogger.Errorf("start-could not find executable %s", binary)
+			return err
+		}
+		processLogger.Debugf("start-could not find executable ...attempt to rebuild %s", binary)
+		if err = vm.build(ccid, reader); err != nil {
+			return err
+		}
+	}
+
+	//stop the process if necessary
+	if p := getProcess(name); p != nil {
+		processLogger.Debugf("Cleanup process %s", name)
+		p.stop(0, false)
+	}
+
+	if len(args) > 0 {
+		args = args[1:]
+	}
+	p := newProcess(name, binary, args, env, filepath.Join(dir, logName))
+	if err = p.start(); err != nil {
+		processLogger.Errorf("start-could not start process %s", err)
+		return err
+	}
+	putProcess(p)
+	go p.supervise()
+
+	processLogger.Debugf("Started process %s", name)
+	return nil
+}
+
+//Stop stops a running chaincode. The process is asked to terminate and killed once timeout
+//seconds have passed, unless dontkill is set.
+func (vm *ProcessVM) Stop(ctxt context.Context, ccid ccintf.CCID, timeout uint, dontkill bool, dontremove bool) error {
+	name, err := vm.GetVMName(ccid)
+	if err != nil {
+		return err
+	}
+	p := getProcess(name)
+	if p == nil {
+		return fmt.Errorf("%s not running", name)
+	}
+	return p.stop(timeout, dontkill)
+}
+
+//Destroy removes the source, executable and log of a chaincode. A running chaincode is only
+//stopped and removed if force is set.
+func (vm *ProcessVM) Destroy(ctxt context.Context, ccid ccintf.CCID, force bool, noprune bool) error {
+	name, err := vm.GetVMName(ccid)
+	if err != nil {
+		return err
+	}
+	dir, _, err := vm.getDirs(ccid)
+	if err != nil {
+		return err
+	}
+	if p := getProcess(name); p != nil {
+		if !force {
+			return fmt.Errorf("%s is running", name)
+		}
+		p.stop(0, false)
+	}
+
+	if err = os.RemoveAll(dir); err != nil {
+		processLogger.Errorf("error while destroying chaincode: %s", err)
+		return err
+	}
+
+	processLogger.Debugf("Destroyed chaincode %s", name)
+	return nil
+}
+
+//GetVMName names the chaincode after the peer and network, like the docker image it replaces,
+//so that peers sharing a host do not share chaincode directories
+func (vm *ProcessVM) GetVMName(ccid ccintf.CCID) (string, error) {
+	name := ccid.ChaincodeSpec.ChaincodeID.Name
+	if ccid.Version != "" {
+		name = ccid.Version
+	}
+	if ccid.NetworkID != "" {
+		return fmt.Sprintf("%s-%s-%s", ccid.NetworkID, ccid.PeerID, name), nil
+	} else if ccid.PeerID != "" {
+		return fmt.Sprintf("%s-%s", ccid.PeerID, name), nil
+	}
+	return name, nil
+}
@@ -0,0 +1,154 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processcontroller
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+
+	"github.com/hyperledger/fabric/core/container/ccintf"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func setupWorkDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "processcontroller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("vm.process.dir", dir)
+	viper.Set("vm.process.maxRestarts", 2)
+	viper.Set("vm.process.restartDelay", "10ms")
+	return dir
+}
+
+func newCCID(name string) ccintf.CCID {
+	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_GOLANG, ChaincodeID: &pb.ChaincodeID{Name: name}}
+	return ccintf.CCID{ChaincodeSpec: spec, NetworkID: "dev", PeerID: "jdoe"}
+}
+
+//installScript stands in for a built chaincode
+func installScript(t *testing.T, vm *ProcessVM, ccid ccintf.CCID, script string) string {
+	dir, binary, err := vm.getDirs(ccid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Dir(binary), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(binary, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestExtract(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for name, contents := range map[string]string{
+		"Dockerfile":           "from hyperledger/fabric-baseimage",
+		"src/example/cc/cc.go": "package main",
+	} {
+		tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(contents)), Mode: 0644})
+		tw.Write([]byte(contents))
+	}
+	tw.Close()
+	gw.Close()
+
+	dir := setupWorkDir(t)
+	defer os.RemoveAll(dir)
+	if err := extract(buf, dir); err != nil {
+		t.Fatalf("Error extracting package: %s", err)
+	}
+	if contents, err := ioutil.ReadFile(filepath.Join(dir, "src", "example", "cc", "cc.go")); err != nil || string(contents) != "package main" {
+		t.Fatalf("Expected the source to be extracted, got %q (%v)", contents, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Dockerfile")); !os.IsNotExist(err) {
+		t.Fatalf("Expected the Dockerfile not to be extracted")
+	}
+}
+
+func TestRestartOnCrash(t *testing.T) {
+	workDir := setupWorkDir(t)
+	defer os.RemoveAll(workDir)
+
+	vm := &ProcessVM{}
+	ccid := newCCID("crash")
+	dir := installScript(t, vm, ccid, "echo started $1; exit 1")
+
+	args := []string{"/opt/gopath/bin/crash", "-peer.address=0.0.0.0:30303"}
+	if err := vm.Start(context.Background(), ccid, args, nil, false, false, nil); err != nil {
+		t.Fatalf("Error starting chaincode: %s", err)
+	}
+
+	name, _ := vm.GetVMName(ccid)
+	deadline := time.Now().Add(5 * time.Second)
+	for getProcess(name) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the chaincode to be given up on")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	log, err := ioutil.ReadFile(filepath.Join(dir, logName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(log), "started -peer.address=0.0.0.0:30303"); n != 3 {
+		t.Fatalf("Expected the chaincode to be started and restarted twice, it ran %d times:\n%s", n, log)
+	}
+}
+
+func TestStopAndDestroy(t *testing.T) {
+	workDir := setupWorkDir(t)
+	defer os.RemoveAll(workDir)
+
+	vm := &ProcessVM{}
+	ccid := newCCID("sleep")
+	dir := installScript(t, vm, ccid, "exec sleep 60")
+
+	env := []string{"PATH=" + os.Getenv("PATH")}
+	if err := vm.Start(context.Background(), ccid, []string{"sleep"}, env, false, false, nil); err != nil {
+		t.Fatalf("Error starting chaincode: %s", err)
+	}
+	if err := vm.Destroy(context.Background(), ccid, false, false); err == nil {
+		t.Fatal("Expected a running chaincode not to be destroyed")
+	}
+	if err := vm.Stop(context.Background(), ccid, 5, false, false); err != nil {
+		t.Fatalf("Error stopping chaincode: %s", err)
+	}
+	name, _ := vm.GetVMName(ccid)
+	if getProcess(name) != nil {
+		t.Fatal("Expected the stopped chaincode not to be restarted")
+	}
+	if err := vm.Destroy(context.Background(), ccid, false, false); err != nil {
+		t.Fatalf("Error destroying chaincode: %s", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatal("Expected the chaincode directory to be removed")
+	}
+}
@@ -47,6 +47,9 @@ var (
 
 	//HeaderAttributeName is the name used to derivate the K used to encrypt/decrypt the header.
 	HeaderAttributeName = "attributeHeader"
+
+	//enrollmentIDName is the name used to derivate the K used to encrypt/decrypt the enrollment ID.
+	enrollmentIDName = "enrollmentID"
 )
 
 //ParseAttributesHeader parses a string and returns a map with the attributes.
@@ -202,6 +205,24 @@ func GetValueForAttribute(attributeName string, preK0 []byte, cert *x509.Certifi
 	return value, err
 }
 
+//GetEnrollmentIDKey derives from preK0 the key used to encrypt the enrollment ID in the TCert.
+func GetEnrollmentIDKey(preK0 []byte) []byte {
+	return getAttributeKey(preK0, enrollmentIDName)
+}
+
+//ReadTCertEnrollmentID decrypts the enrollment ID of the owner of the TCert using "enrollmentIDKey".
+func ReadTCertEnrollmentID(tcert *x509.Certificate, enrollmentIDKey []byte) (string, error) {
+	encryptedValue, err := primitives.GetCriticalExtension(tcert, primitives.TCertEncEnrollmentID)
+	if err != nil {
+		return "", err
+	}
+	value, err := DecryptAttributeValue(enrollmentIDKey, encryptedValue)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
 func createAttributesHeaderEntry(preK0 []byte) *pb.AttributesMetadataEntry {
 	attKey := getAttributeKey(preK0, HeaderAttributeName)
 	return &pb.AttributesMetadataEntry{AttributeName: HeaderAttributeName, AttributeKey: attKey}
@@ -85,6 +85,28 @@ func TestGetKAndValueForAttribute(t *testing.T) {
 	}
 }
 
+func TestReadTCertEnrollmentID(t *testing.T) {
+	expected := "diego\\institution_a\\00003"
+
+	tcert, prek0, err := loadTCertAndPreK0()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enrollmentID, err := ReadTCertEnrollmentID(tcert, GetEnrollmentIDKey(prek0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(enrollmentID)
+	if enrollmentID != expected {
+		t.Errorf("Failed reading enrollment ID from TCert. Expected: %v, Actual: %v", expected, enrollmentID)
+	}
+
+	if _, err = ReadTCertEnrollmentID(tcert, getAttributeKey(prek0, "position")); err == nil {
+		t.Error("Reading enrollment ID with the wrong key should fail.")
+	}
+}
+
 func TestGetKAndValueForAttribute_MissingAttribute(t *testing.T) {
 	tcert, prek0, err := loadTCertAndPreK0()
 	if err != nil {
@@ -93,7 +93,7 @@ func (client *clientImpl) encryptTxVersion1_2(tx *obc.Transaction) error {
 		}
 
 		break
-	case obc.Transaction_CHAINCODE_INVOKE:
+	case obc.Transaction_CHAINCODE_INVOKE, obc.Transaction_CHAINCODE_TERMINATE:
 		// Prepare chaincode stateKey and privateKey
 		stateKey = make([]byte, 0)
 
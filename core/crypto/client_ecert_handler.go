@@ -113,3 +113,13 @@ func (handler *eCertTransactionHandlerImpl) NewChaincodeExecute(chaincodeInvocat
 func (handler *eCertTransactionHandlerImpl) NewChaincodeQuery(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, attributeNames ...string) (*obc.Transaction, error) {
 	return handler.client.newChaincodeQueryUsingECert(chaincodeInvocation, uuid, handler.nonce)
 }
+
+// NewChaincodeUpgradeTransaction is used to upgrade a deployed chaincode.
+func (handler *eCertTransactionHandlerImpl) NewChaincodeUpgradeTransaction(chaincodeDeploymentSpec *obc.ChaincodeDeploymentSpec, uuid string, attributeNames ...string) (*obc.Transaction, error) {
+	return handler.client.newChaincodeUpgradeUsingECert(chaincodeDeploymentSpec, uuid, handler.nonce)
+}
+
+// NewChaincodeTerminateTransaction is used to terminate a deployed chaincode.
+func (handler *eCertTransactionHandlerImpl) NewChaincodeTerminateTransaction(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, attributeNames ...string) (*obc.Transaction, error) {
+	return handler.client.newChaincodeTerminateUsingECert(chaincodeInvocation, uuid, handler.nonce)
+}
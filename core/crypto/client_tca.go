@@ -96,6 +96,23 @@ func (client *clientImpl) loadTCertOwnerKDFKey() error {
 	return nil
 }
 
+// deriveTCertKey derives the key of a TCert from the enrollment key and the ExpansionValue
+// of the TCert: TCertPub_Key = EnrollPub_Key + ExpansionValue G
+func (client *clientImpl) deriveTCertKey(ExpansionValue []byte) (KeyHandle, error) {
+	enrollPK, err := checkECDSAPublicKey(client.enrollKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var k = new(big.Int).SetBytes(ExpansionValue)
+	var one = new(big.Int).SetInt64(1)
+	n := new(big.Int).Sub(enrollPK.Params().N, one)
+	k.Mod(k, n)
+	k.Add(k, one)
+
+	return client.provider.DeriveKey(client.enrollKey, k)
+}
+
 func (client *clientImpl) getTCertFromExternalDER(der []byte) (tCert, error) {
 	// DER to x509
 	x509Cert, err := primitives.DERToX509Certificate(der)
@@ -166,34 +183,18 @@ func (client *clientImpl) getTCertFromExternalDER(der []byte) (tCert, error) {
 		// using elliptic curve point addition per NIST FIPS PUB 186-4- specified P-384
 
 		// Compute temporary secret key
-		tempSK := &ecdsa.PrivateKey{
-			PublicKey: ecdsa.PublicKey{
-				Curve: client.enrollPrivKey.Curve,
-				X:     new(big.Int),
-				Y:     new(big.Int),
-			},
-			D: new(big.Int),
-		}
-
-		var k = new(big.Int).SetBytes(ExpansionValue)
-		var one = new(big.Int).SetInt64(1)
-		n := new(big.Int).Sub(client.enrollPrivKey.Params().N, one)
-		k.Mod(k, n)
-		k.Add(k, one)
+		tempSK, err := client.deriveTCertKey(ExpansionValue)
+		if err != nil {
+			client.Warningf("Failed deriving TCert key [%s]. This is an foreign certificate.", err.Error())
 
-		tempSK.D.Add(client.enrollPrivKey.D, k)
-		tempSK.D.Mod(tempSK.D, client.enrollPrivKey.PublicKey.Params().N)
+			return &tCertImpl{client, x509Cert, nil, []byte{}}, nil
+		}
 
 		// Compute temporary public key
-		tempX, tempY := client.enrollPrivKey.PublicKey.ScalarBaseMult(k.Bytes())
-		tempSK.PublicKey.X, tempSK.PublicKey.Y =
-			tempSK.PublicKey.Add(
-				client.enrollPrivKey.PublicKey.X, client.enrollPrivKey.PublicKey.Y,
-				tempX, tempY,
-			)
+		tempPK := tempSK.PublicKey().(*ecdsa.PublicKey)
 
 		// Verify temporary public key is a valid point on the reference curve
-		isOn := tempSK.Curve.IsOnCurve(tempSK.PublicKey.X, tempSK.PublicKey.Y)
+		isOn := tempPK.Curve.IsOnCurve(tempPK.X, tempPK.Y)
 		if !isOn {
 			client.Warning("Failed temporary public key IsOnCurve check. This is an foreign certificate.")
 
@@ -203,34 +204,19 @@ func (client *clientImpl) getTCertFromExternalDER(der []byte) (tCert, error) {
 		// Check that the derived public key is the same as the one in the certificate
 		certPK := x509Cert.PublicKey.(*ecdsa.PublicKey)
 
-		if certPK.X.Cmp(tempSK.PublicKey.X) != 0 {
+		if certPK.X.Cmp(tempPK.X) != 0 {
 			client.Warning("Derived public key is different on X. This is an foreign certificate.")
 
 			return &tCertImpl{client, x509Cert, nil, []byte{}}, nil
 		}
 
-		if certPK.Y.Cmp(tempSK.PublicKey.Y) != 0 {
+		if certPK.Y.Cmp(tempPK.Y) != 0 {
 			client.Warning("Derived public key is different on Y. This is an foreign certificate.")
 
 			return &tCertImpl{client, x509Cert, nil, []byte{}}, nil
 		}
 
-		// Verify the signing capability of tempSK
-		err = primitives.VerifySignCapability(tempSK, x509Cert.PublicKey)
-		if err != nil {
-			client.Warning("Failed verifing signing capability [%s]. This is an foreign certificate.", err.Error())
-
-			return &tCertImpl{client, x509Cert, nil, []byte{}}, nil
-		}
-
-		// Marshall certificate and secret key to be stored in the database
-		if err != nil {
-			client.Warningf("Failed marshalling private key [%s]. This is an foreign certificate.", err.Error())
-
-			return &tCertImpl{client, x509Cert, nil, []byte{}}, nil
-		}
-
-		if err = primitives.CheckCertPKAgainstSK(x509Cert, interface{}(tempSK)); err != nil {
+		if err = checkCertPKAgainstKey(x509Cert, tempSK); err != nil {
 			client.Warningf("Failed checking TCA cert PK against private key [%s]. This is an foreign certificate.", err.Error())
 
 			return &tCertImpl{client, x509Cert, nil, []byte{}}, nil
@@ -301,34 +287,18 @@ func (client *clientImpl) getTCertFromDER(certBlk *TCertDBBlock) (certBlock *TCe
 	// using elliptic curve point addition per NIST FIPS PUB 186-4- specified P-384
 
 	// Compute temporary secret key
-	tempSK := &ecdsa.PrivateKey{
-		PublicKey: ecdsa.PublicKey{
-			Curve: client.enrollPrivKey.Curve,
-			X:     new(big.Int),
-			Y:     new(big.Int),
-		},
-		D: new(big.Int),
-	}
-
-	var k = new(big.Int).SetBytes(ExpansionValue)
-	var one = new(big.Int).SetInt64(1)
-	n := new(big.Int).Sub(client.enrollPrivKey.Params().N, one)
-	k.Mod(k, n)
-	k.Add(k, one)
+	tempSK, err := client.deriveTCertKey(ExpansionValue)
+	if err != nil {
+		client.Errorf("Failed deriving TCert key [%s].", err.Error())
 
-	tempSK.D.Add(client.enrollPrivKey.D, k)
-	tempSK.D.Mod(tempSK.D, client.enrollPrivKey.PublicKey.Params().N)
+		return nil, err
+	}
 
 	// Compute temporary public key
-	tempX, tempY := client.enrollPrivKey.PublicKey.ScalarBaseMult(k.Bytes())
-	tempSK.PublicKey.X, tempSK.PublicKey.Y =
-		tempSK.PublicKey.Add(
-			client.enrollPrivKey.PublicKey.X, client.enrollPrivKey.PublicKey.Y,
-			tempX, tempY,
-		)
+	tempPK := tempSK.PublicKey().(*ecdsa.PublicKey)
 
 	// Verify temporary public key is a valid point on the reference curve
-	isOn := tempSK.Curve.IsOnCurve(tempSK.PublicKey.X, tempSK.PublicKey.Y)
+	isOn := tempPK.Curve.IsOnCurve(tempPK.X, tempPK.Y)
 	if !isOn {
 		client.Error("Failed temporary public key IsOnCurve check.")
 
@@ -338,34 +308,19 @@ func (client *clientImpl) getTCertFromDER(certBlk *TCertDBBlock) (certBlock *TCe
 	// Check that the derived public key is the same as the one in the certificate
 	certPK := x509Cert.PublicKey.(*ecdsa.PublicKey)
 
-	if certPK.X.Cmp(tempSK.PublicKey.X) != 0 {
+	if certPK.X.Cmp(tempPK.X) != 0 {
 		client.Error("Derived public key is different on X")
 
 		return nil, fmt.Errorf("Derived public key is different on X")
 	}
 
-	if certPK.Y.Cmp(tempSK.PublicKey.Y) != 0 {
+	if certPK.Y.Cmp(tempPK.Y) != 0 {
 		client.Error("Derived public key is different on Y")
 
 		return nil, fmt.Errorf("Derived public key is different on Y")
 	}
 
-	// Verify the signing capability of tempSK
-	err = primitives.VerifySignCapability(tempSK, x509Cert.PublicKey)
-	if err != nil {
-		client.Errorf("Failed verifing signing capability [%s].", err.Error())
-
-		return
-	}
-
-	// Marshall certificate and secret key to be stored in the database
-	if err != nil {
-		client.Errorf("Failed marshalling private key [%s].", err.Error())
-
-		return
-	}
-
-	if err = primitives.CheckCertPKAgainstSK(x509Cert, interface{}(tempSK)); err != nil {
+	if err = checkCertPKAgainstKey(x509Cert, tempSK); err != nil {
 		client.Errorf("Failed checking TCA cert PK against private key [%s].", err.Error())
 
 		return
@@ -466,34 +421,18 @@ func (client *clientImpl) getTCertsFromTCA(attrhash string, attributes []string,
 		// using elliptic curve point addition per NIST FIPS PUB 186-4- specified P-384
 
 		// Compute temporary secret key
-		tempSK := &ecdsa.PrivateKey{
-			PublicKey: ecdsa.PublicKey{
-				Curve: client.enrollPrivKey.Curve,
-				X:     new(big.Int),
-				Y:     new(big.Int),
-			},
-			D: new(big.Int),
-		}
-
-		var k = new(big.Int).SetBytes(ExpansionValue)
-		var one = new(big.Int).SetInt64(1)
-		n := new(big.Int).Sub(client.enrollPrivKey.Params().N, one)
-		k.Mod(k, n)
-		k.Add(k, one)
+		tempSK, err := client.deriveTCertKey(ExpansionValue)
+		if err != nil {
+			client.Errorf("Failed deriving TCert key [%s].", err.Error())
 
-		tempSK.D.Add(client.enrollPrivKey.D, k)
-		tempSK.D.Mod(tempSK.D, client.enrollPrivKey.PublicKey.Params().N)
+			continue
+		}
 
 		// Compute temporary public key
-		tempX, tempY := client.enrollPrivKey.PublicKey.ScalarBaseMult(k.Bytes())
-		tempSK.PublicKey.X, tempSK.PublicKey.Y =
-			tempSK.PublicKey.Add(
-				client.enrollPrivKey.PublicKey.X, client.enrollPrivKey.PublicKey.Y,
-				tempX, tempY,
-			)
+		tempPK := tempSK.PublicKey().(*ecdsa.PublicKey)
 
 		// Verify temporary public key is a valid point on the reference curve
-		isOn := tempSK.Curve.IsOnCurve(tempSK.PublicKey.X, tempSK.PublicKey.Y)
+		isOn := tempPK.Curve.IsOnCurve(tempPK.X, tempPK.Y)
 		if !isOn {
 			client.Error("Failed temporary public key IsOnCurve check.")
 
@@ -503,34 +442,19 @@ func (client *clientImpl) getTCertsFromTCA(attrhash string, attributes []string,
 		// Check that the derived public key is the same as the one in the certificate
 		certPK := x509Cert.PublicKey.(*ecdsa.PublicKey)
 
-		if certPK.X.Cmp(tempSK.PublicKey.X) != 0 {
+		if certPK.X.Cmp(tempPK.X) != 0 {
 			client.Error("Derived public key is different on X")
 
 			continue
 		}
 
-		if certPK.Y.Cmp(tempSK.PublicKey.Y) != 0 {
+		if certPK.Y.Cmp(tempPK.Y) != 0 {
 			client.Error("Derived public key is different on Y")
 
 			continue
 		}
 
-		// Verify the signing capability of tempSK
-		err = primitives.VerifySignCapability(tempSK, x509Cert.PublicKey)
-		if err != nil {
-			client.Errorf("Failed verifing signing capability [%s].", err.Error())
-
-			continue
-		}
-
-		// Marshall certificate and secret key to be stored in the database
-		if err != nil {
-			client.Errorf("Failed marshalling private key [%s].", err.Error())
-
-			continue
-		}
-
-		if err := primitives.CheckCertPKAgainstSK(x509Cert, interface{}(tempSK)); err != nil {
+		if err := checkCertPKAgainstKey(x509Cert, tempSK); err != nil {
 			client.Errorf("Failed checking TCA cert PK against private key [%s].", err.Error())
 
 			continue
@@ -563,6 +487,9 @@ func (client *clientImpl) getTCertsFromTCA(attrhash string, attributes []string,
 func (client *clientImpl) callTCACreateCertificateSet(num int, attributes []string) ([]byte, []*membersrvc.TCert, error) {
 	// Get a TCA Client
 	sock, tcaP, err := client.getTCAClient()
+	if err != nil {
+		return nil, nil, err
+	}
 	defer sock.Close()
 
 	var attributesList []*membersrvc.TCertAttribute
@@ -43,7 +43,7 @@ type tCert interface {
 type tCertImpl struct {
 	client *clientImpl
 	cert   *x509.Certificate
-	sk     interface{}
+	sk     KeyHandle
 	preK0  []byte
 }
 
@@ -63,12 +63,12 @@ func (tCert *tCertImpl) Sign(msg []byte) ([]byte, error) {
 		return nil, utils.ErrNilArgument
 	}
 
-	return tCert.client.sign(tCert.sk, msg)
+	return tCert.client.signWithKey(tCert.sk, msg)
 }
 
 //Verify verifies signature and message using the TCert public key.
 func (tCert *tCertImpl) Verify(signature, msg []byte) (err error) {
-	ok, err := tCert.client.verify(tCert.cert.PublicKey, msg, signature)
+	ok, err := tCert.client.verifyWithCert(tCert.cert, msg, signature)
 	if err != nil {
 		return
 	}
@@ -109,3 +109,13 @@ func (handler *tCertTransactionHandlerImpl) NewChaincodeExecute(chaincodeInvocat
 func (handler *tCertTransactionHandlerImpl) NewChaincodeQuery(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, attributeNames ...string) (*obc.Transaction, error) {
 	return handler.tCertHandler.client.newChaincodeQueryUsingTCert(chaincodeInvocation, uuid, attributeNames, handler.tCertHandler.tCert, handler.nonce)
 }
+
+// NewChaincodeUpgradeTransaction is used to upgrade a deployed chaincode.
+func (handler *tCertTransactionHandlerImpl) NewChaincodeUpgradeTransaction(chaincodeDeploymentSpec *obc.ChaincodeDeploymentSpec, uuid string, attributeNames ...string) (*obc.Transaction, error) {
+	return handler.tCertHandler.client.newChaincodeUpgradeUsingTCert(chaincodeDeploymentSpec, uuid, attributeNames, handler.tCertHandler.tCert, handler.nonce)
+}
+
+// NewChaincodeTerminateTransaction is used to terminate a deployed chaincode.
+func (handler *tCertTransactionHandlerImpl) NewChaincodeTerminateTransaction(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, attributeNames ...string) (*obc.Transaction, error) {
+	return handler.tCertHandler.client.newChaincodeTerminateUsingTCert(chaincodeInvocation, uuid, attributeNames, handler.tCertHandler.tCert, handler.nonce)
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/crypto/attributes"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/hyperledger/fabric/core/crypto/utils"
 	obc "github.com/hyperledger/fabric/protos"
@@ -98,9 +99,9 @@ func getMetadata(chaincodeSpec *obc.ChaincodeSpec, tCert tCert, attrs ...string)
 	return chaincodeSpec.Metadata, nil
 }
 
-func (client *clientImpl) createExecuteTx(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, nonce []byte, tCert tCert, attrs ...string) (*obc.Transaction, error) {
+func (client *clientImpl) createExecuteTx(txType obc.Transaction_Type, chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, nonce []byte, tCert tCert, attrs ...string) (*obc.Transaction, error) {
 	/// Create a new transaction
-	tx, err := obc.NewChaincodeExecute(chaincodeInvocation, uuid, obc.Transaction_CHAINCODE_INVOKE)
+	tx, err := obc.NewChaincodeExecute(chaincodeInvocation, uuid, txType)
 	if err != nil {
 		client.Errorf("Failed creating new transaction [%s].", err.Error())
 		return nil, err
@@ -202,6 +203,10 @@ func (client *clientImpl) newChaincodeDeployUsingTCert(chaincodeDeploymentSpec *
 	client.Debugf("Appending certificate [% x].", tCert.GetCertificate().Raw)
 	tx.Cert = tCert.GetCertificate().Raw
 
+	// Reveal the enrollment ID in the certificate. The validators let only
+	// the deployer upgrade or terminate the chaincode.
+	tx.EnrollmentIDKey = attributes.GetEnrollmentIDKey(tCert.GetPreK0())
+
 	// Sign the transaction and append the signature
 	// 1. Marshall tx to bytes
 	rawTx, err := proto.Marshal(tx)
@@ -227,7 +232,7 @@ func (client *clientImpl) newChaincodeDeployUsingTCert(chaincodeDeploymentSpec *
 
 func (client *clientImpl) newChaincodeExecuteUsingTCert(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, attributeKeys []string, tCert tCert, nonce []byte) (*obc.Transaction, error) {
 	/// Create a new transaction
-	tx, err := client.createExecuteTx(chaincodeInvocation, uuid, nonce, tCert, attributeKeys...)
+	tx, err := client.createExecuteTx(obc.Transaction_CHAINCODE_INVOKE, chaincodeInvocation, uuid, nonce, tCert, attributeKeys...)
 	if err != nil {
 		client.Errorf("Failed creating new execute transaction [%s].", err.Error())
 		return nil, err
@@ -299,6 +304,69 @@ func (client *clientImpl) newChaincodeQueryUsingTCert(chaincodeInvocation *obc.C
 	return tx, nil
 }
 
+func (client *clientImpl) newChaincodeUpgradeUsingTCert(chaincodeDeploymentSpec *obc.ChaincodeDeploymentSpec, uuid string, attributeNames []string, tCert tCert, nonce []byte) (*obc.Transaction, error) {
+	// The upgraded chaincode inherits the state of the chaincode it replaces, which
+	// is encrypted under keys bound to the deploy transaction when confidential
+	if chaincodeDeploymentSpec.ChaincodeSpec.ConfidentialityLevel == obc.ConfidentialityLevel_CONFIDENTIAL {
+		client.Error("Failed creating new upgrade transaction. Confidential chaincodes cannot be upgraded.")
+		return nil, utils.ErrInvalidConfidentialityLevel
+	}
+
+	// Create a new transaction
+	tx, err := client.createDeployTx(chaincodeDeploymentSpec, uuid, nonce, tCert, attributeNames...)
+	if err != nil {
+		client.Errorf("Failed creating new upgrade transaction [%s].", err.Error())
+		return nil, err
+	}
+	tx.Type = obc.Transaction_CHAINCODE_UPGRADE
+
+	return client.signTxUsingTCert(tx, tCert)
+}
+
+func (client *clientImpl) newChaincodeTerminateUsingTCert(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, attributeNames []string, tCert tCert, nonce []byte) (*obc.Transaction, error) {
+	// Create a new transaction
+	tx, err := client.createExecuteTx(obc.Transaction_CHAINCODE_TERMINATE, chaincodeInvocation, uuid, nonce, tCert, attributeNames...)
+	if err != nil {
+		client.Errorf("Failed creating new terminate transaction [%s].", err.Error())
+		return nil, err
+	}
+
+	return client.signTxUsingTCert(tx, tCert)
+}
+
+// signTxUsingTCert appends tCert to tx and signs tx with the corresponding signing key
+func (client *clientImpl) signTxUsingTCert(tx *obc.Transaction, tCert tCert) (*obc.Transaction, error) {
+	// Append the certificate to the transaction
+	client.Debugf("Appending certificate [% x].", tCert.GetCertificate().Raw)
+	tx.Cert = tCert.GetCertificate().Raw
+
+	// Reveal the enrollment ID in the certificate. The validators check it
+	// against the enrollment ID of the deployer.
+	tx.EnrollmentIDKey = attributes.GetEnrollmentIDKey(tCert.GetPreK0())
+
+	// Sign the transaction and append the signature
+	// 1. Marshall tx to bytes
+	rawTx, err := proto.Marshal(tx)
+	if err != nil {
+		client.Errorf("Failed marshaling tx [%s].", err.Error())
+		return nil, err
+	}
+
+	// 2. Sign rawTx and check signature
+	rawSignature, err := tCert.Sign(rawTx)
+	if err != nil {
+		client.Errorf("Failed creating signature [% x]: [%s].", rawTx, err.Error())
+		return nil, err
+	}
+
+	// 3. Append the signature
+	tx.Signature = rawSignature
+
+	client.Debugf("Appending signature [% x].", rawSignature)
+
+	return tx, nil
+}
+
 func (client *clientImpl) newChaincodeDeployUsingECert(chaincodeDeploymentSpec *obc.ChaincodeDeploymentSpec, uuid string, nonce []byte) (*obc.Transaction, error) {
 	// Create a new transaction
 	tx, err := client.createDeployTx(chaincodeDeploymentSpec, uuid, nonce, nil)
@@ -338,7 +406,7 @@ func (client *clientImpl) newChaincodeDeployUsingECert(chaincodeDeploymentSpec *
 
 func (client *clientImpl) newChaincodeExecuteUsingECert(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, nonce []byte) (*obc.Transaction, error) {
 	/// Create a new transaction
-	tx, err := client.createExecuteTx(chaincodeInvocation, uuid, nonce, nil)
+	tx, err := client.createExecuteTx(obc.Transaction_CHAINCODE_INVOKE, chaincodeInvocation, uuid, nonce, nil)
 	if err != nil {
 		client.Errorf("Failed creating new execute transaction [%s].", err.Error())
 		return nil, err
@@ -410,6 +478,65 @@ func (client *clientImpl) newChaincodeQueryUsingECert(chaincodeInvocation *obc.C
 	return tx, nil
 }
 
+func (client *clientImpl) newChaincodeUpgradeUsingECert(chaincodeDeploymentSpec *obc.ChaincodeDeploymentSpec, uuid string, nonce []byte) (*obc.Transaction, error) {
+	// The upgraded chaincode inherits the state of the chaincode it replaces, which
+	// is encrypted under keys bound to the deploy transaction when confidential
+	if chaincodeDeploymentSpec.ChaincodeSpec.ConfidentialityLevel == obc.ConfidentialityLevel_CONFIDENTIAL {
+		client.Error("Failed creating new upgrade transaction. Confidential chaincodes cannot be upgraded.")
+		return nil, utils.ErrInvalidConfidentialityLevel
+	}
+
+	// Create a new transaction
+	tx, err := client.createDeployTx(chaincodeDeploymentSpec, uuid, nonce, nil)
+	if err != nil {
+		client.Errorf("Failed creating new upgrade transaction [%s].", err.Error())
+		return nil, err
+	}
+	tx.Type = obc.Transaction_CHAINCODE_UPGRADE
+
+	return client.signTxUsingECert(tx)
+}
+
+func (client *clientImpl) newChaincodeTerminateUsingECert(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, nonce []byte) (*obc.Transaction, error) {
+	// Create a new transaction
+	tx, err := client.createExecuteTx(obc.Transaction_CHAINCODE_TERMINATE, chaincodeInvocation, uuid, nonce, nil)
+	if err != nil {
+		client.Errorf("Failed creating new terminate transaction [%s].", err.Error())
+		return nil, err
+	}
+
+	return client.signTxUsingECert(tx)
+}
+
+// signTxUsingECert appends the enrollment certificate to tx and signs tx with the enrollment key
+func (client *clientImpl) signTxUsingECert(tx *obc.Transaction) (*obc.Transaction, error) {
+	// Append the certificate to the transaction
+	client.Debugf("Appending certificate [% x].", client.enrollCert.Raw)
+	tx.Cert = client.enrollCert.Raw
+
+	// Sign the transaction and append the signature
+	// 1. Marshall tx to bytes
+	rawTx, err := proto.Marshal(tx)
+	if err != nil {
+		client.Errorf("Failed marshaling tx [%s].", err.Error())
+		return nil, err
+	}
+
+	// 2. Sign rawTx and check signature
+	rawSignature, err := client.signWithEnrollmentKey(rawTx)
+	if err != nil {
+		client.Errorf("Failed creating signature [% x]: [%s].", rawTx, err.Error())
+		return nil, err
+	}
+
+	// 3. Append the signature
+	tx.Signature = rawSignature
+
+	client.Debugf("Appending signature [% x].", rawSignature)
+
+	return tx, nil
+}
+
 // CheckTransaction is used to verify that a transaction
 // is well formed with the respect to the security layer
 // prescriptions. To be used for internal verifications.
@@ -455,7 +582,7 @@ func (client *clientImpl) checkTransaction(tx *obc.Transaction) error {
 		tx.Signature = signature
 
 		// 3. Verify signature
-		ver, err := client.verify(cert.PublicKey, rawTx, tx.Signature)
+		ver, err := client.verifyWithCert(cert, rawTx, tx.Signature)
 		if err != nil {
 			client.Errorf("Failed marshaling tx [%s].", err.Error())
 			return err
@@ -157,4 +157,12 @@ type TransactionHandler interface {
 
 	// NewChaincodeQuery is used to query chaincode's functions
 	NewChaincodeQuery(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, attributeNames ...string) (*obc.Transaction, error)
+
+	// NewChaincodeUpgradeTransaction is used to upgrade a deployed chaincode.
+	// Only the user who deployed the chaincode can upgrade it.
+	NewChaincodeUpgradeTransaction(chaincodeDeploymentSpec *obc.ChaincodeDeploymentSpec, uuid string, attributeNames ...string) (*obc.Transaction, error)
+
+	// NewChaincodeTerminateTransaction is used to terminate a deployed chaincode.
+	// Only the user who deployed the chaincode can terminate it.
+	NewChaincodeTerminateTransaction(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, attributeNames ...string) (*obc.Transaction, error)
 }
@@ -17,7 +17,10 @@ limitations under the License.
 package crypto
 
 import (
+	"crypto/x509"
+
 	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/crypto/utils"
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
 )
@@ -26,9 +29,28 @@ var (
 	log = logging.MustGetLogger("crypto")
 )
 
-// Init initializes the crypto layer. It load from viper the security level
-// and the logging setting.
+// Init initializes the crypto layer. It load from viper the crypto suite, or
+// the security level, and the logging setting.
 func Init() (err error) {
+	// Check the allowed crypto suites
+	for _, name := range viper.GetStringSlice("security.suites.allowed") {
+		if _, err = primitives.GetCryptoSuite(name); err != nil {
+			log.Errorf("Failed checking allowed crypto suites: [%s]", err)
+
+			return
+		}
+	}
+
+	// Init crypto suite
+	if suite := viper.GetString("security.suite"); suite != "" {
+		log.Debugf("Working with crypto suite [%s]", suite)
+		if err = primitives.InitCryptoSuite(suite); err != nil {
+			log.Errorf("Failed setting crypto suite: [%s]", err)
+		}
+
+		return
+	}
+
 	// Init security level
 	securityLevel := 256
 	if viper.IsSet("security.level") {
@@ -55,3 +77,71 @@ func Init() (err error) {
 
 	return
 }
+
+// GetCryptoSuiteName returns the name of the crypto suite the crypto layer works with.
+func GetCryptoSuiteName() string {
+	return primitives.GetDefaultCryptoSuite().Name
+}
+
+// GetAllowedCryptoSuites returns the names of the crypto suites the network allows, by
+// default the one the crypto layer works with.
+func GetAllowedCryptoSuites() []string {
+	allowed := viper.GetStringSlice("security.suites.allowed")
+	if len(allowed) == 0 {
+		return []string{GetCryptoSuiteName()}
+	}
+	return allowed
+}
+
+// IsCryptoSuiteAllowed returns true if the network allows the crypto suite.
+func IsCryptoSuiteAllowed(name string) bool {
+	for _, allowed := range GetAllowedCryptoSuites() {
+		if allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckCryptoSuites checks that an entity working with the crypto suite and allowing the
+// given ones can communicate with this one: each must allow the suite of the other.
+// Entities that do not advertise their suite are taken to work with the one of this entity.
+func CheckCryptoSuites(suite string, allowed []string) error {
+	if suite == "" {
+		return nil
+	}
+	if !IsCryptoSuiteAllowed(suite) {
+		log.Warningf("Crypto suite [%s] not allowed, allowed suites are %v", suite, GetAllowedCryptoSuites())
+
+		return utils.ErrCryptoSuiteNotAllowed
+	}
+
+	local := GetCryptoSuiteName()
+	for _, name := range allowed {
+		if name == local {
+			return nil
+		}
+	}
+	log.Warningf("Crypto suite [%s] not allowed by the remote entity, allowed suites are %v", local, allowed)
+
+	return utils.ErrCryptoSuiteNotAllowed
+}
+
+// getCertCryptoSuite returns the crypto suite advertised by the certificate if the network
+// allows it. Certificates that do not advertise their suite are taken to use the one the
+// crypto layer works with.
+func getCertCryptoSuite(cert *x509.Certificate) (*primitives.CryptoSuite, error) {
+	suite, err := primitives.GetCertificateCryptoSuite(cert)
+	if err != nil {
+		return nil, err
+	}
+	if suite == nil {
+		return primitives.GetDefaultCryptoSuite(), nil
+	}
+	if !IsCryptoSuiteAllowed(suite.Name) {
+		log.Warningf("Certificate [%v] uses crypto suite [%s], allowed suites are %v", cert.SerialNumber, suite.Name, GetAllowedCryptoSuites())
+
+		return nil, utils.ErrCryptoSuiteNotAllowed
+	}
+	return suite, nil
+}
@@ -107,6 +107,24 @@ func TestMain(m *testing.M) {
 		os.Exit(ret)
 	}
 
+	//Seventh scenario with the keys held by the soft HSM
+	properties["security.hashAlgorithm"] = "SHA3"
+	properties["security.level"] = "256"
+	properties["security.provider.name"] = "softhsm"
+	ret = runTestsOnScenario(m, properties, "Using the soft HSM")
+	if ret != 0 {
+		os.Exit(ret)
+	}
+
+	//Eighth scenario with a named crypto suite
+	properties["security.provider.name"] = "sw"
+	properties["security.suite"] = "P384-SHA3"
+	properties["security.suites.allowed"] = []string{"P256-SHA3", "P384-SHA3"}
+	ret = runTestsOnScenario(m, properties, "Using the P384-SHA3 crypto suite")
+	if ret != 0 {
+		os.Exit(ret)
+	}
+
 	os.Exit(ret)
 }
 
@@ -136,7 +154,11 @@ func runTestsOnScenario(m *testing.M, properties map[string]interface{}, scenari
 	if len(properties) > 0 {
 		currentValues = loadConfigScenario(properties)
 	}
-	primitives.SetSecurityLevel(viper.GetString("security.hashAlgorithm"), viper.GetInt("security.level"))
+	if suite := viper.GetString("security.suite"); suite != "" {
+		primitives.SetCryptoSuite(suite)
+	} else {
+		primitives.SetSecurityLevel(viper.GetString("security.hashAlgorithm"), viper.GetInt("security.level"))
+	}
 
 	before()
 	ret := m.Run()
@@ -431,6 +453,8 @@ func TestClientMultiExecuteTransaction(t *testing.T) {
 }
 
 func TestClientGetNextTCerts(t *testing.T) {
+	initNodes()
+	defer closeNodes()
 
 	// Some positive flow tests here
 	var nCerts int = 1
@@ -597,6 +621,64 @@ func TestClientGetTCertHandlerFromDER(t *testing.T) {
 	}
 }
 
+func TestClientUpgradeAndTerminateWithDeployerCert(t *testing.T) {
+	initNodes()
+	defer closeNodes()
+
+	cds := &obc.ChaincodeDeploymentSpec{
+		ChaincodeSpec: &obc.ChaincodeSpec{
+			Type:                 obc.ChaincodeSpec_GOLANG,
+			ChaincodeID:          &obc.ChaincodeID{Path: "Contract001", Name: "mycc"},
+			ConfidentialityLevel: obc.ConfidentialityLevel_PUBLIC,
+		},
+	}
+	cis := &obc.ChaincodeInvocationSpec{ChaincodeSpec: cds.ChaincodeSpec}
+
+	tCertHandler, err := deployer.GetTCertificateHandlerNext(attrs...)
+	if err != nil {
+		t.Fatalf("Failed getting handler: [%s]", err)
+	}
+	eCertHandler, err := deployer.GetEnrollmentCertificateHandler()
+	if err != nil {
+		t.Fatalf("Failed getting handler: [%s]", err)
+	}
+
+	for _, certHandler := range []CertificateHandler{tCertHandler, eCertHandler} {
+		// The deployer signs the upgrade and the termination with the certificate of the deployment
+		handler := certHandler
+		if certHandler == tCertHandler {
+			if handler, err = deployer.GetTCertificateHandlerFromDER(tCertHandler.GetCertificate()); err != nil {
+				t.Fatalf("Failed getting handler: [%s]", err)
+			}
+		}
+		txHandler, err := handler.GetTransactionHandler()
+		if err != nil {
+			t.Fatalf("Failed getting transaction handler: [%s]", err)
+		}
+
+		upgradeTx, err := txHandler.NewChaincodeUpgradeTransaction(cds, util.GenerateUUID())
+		if err != nil {
+			t.Fatalf("Failed creating upgrade transaction: [%s]", err)
+		}
+		terminateTx, err := txHandler.NewChaincodeTerminateTransaction(cis, util.GenerateUUID())
+		if err != nil {
+			t.Fatalf("Failed creating terminate transaction: [%s]", err)
+		}
+
+		for _, tx := range []*obc.Transaction{upgradeTx, terminateTx} {
+			if !reflect.DeepEqual(tx.Cert, certHandler.GetCertificate()) {
+				t.Fatalf("%s transaction must carry the certificate of the deployer", tx.Type)
+			}
+			if _, err = validator.TransactionPreValidation(tx); err != nil {
+				t.Fatalf("Failed pre-validating %s transaction: [%s]", tx.Type, err)
+			}
+		}
+		if upgradeTx.Type != obc.Transaction_CHAINCODE_UPGRADE || terminateTx.Type != obc.Transaction_CHAINCODE_TERMINATE {
+			t.Fatalf("Unexpected transaction types %s and %s", upgradeTx.Type, terminateTx.Type)
+		}
+	}
+}
+
 func TestClientTCertHandlerSign(t *testing.T) {
 	initNodes()
 	defer closeNodes()
@@ -987,6 +1069,39 @@ func TestValidatorID(t *testing.T) {
 	}
 }
 
+func TestValidatorCryptoSuiteNotAllowed(t *testing.T) {
+	initNodes()
+	defer closeNodes()
+
+	otx, tx, err := createPublicDeployTransaction(t)
+	if err != nil {
+		t.Fatalf("Failed creating deploy transaction [%s].", err)
+	}
+	if otx == nil || tx == nil {
+		t.Fatalf("Transaction must be different from nil")
+	}
+
+	// The network no longer allows the suite the certificates have been issued under
+	allowed := viper.Get("security.suites.allowed")
+	defer viper.Set("security.suites.allowed", allowed)
+	for _, name := range primitives.GetCryptoSuiteNames() {
+		if name != GetCryptoSuiteName() {
+			viper.Set("security.suites.allowed", []string{name})
+			break
+		}
+	}
+
+	if _, err := validator.TransactionPreValidation(tx); err != utils.ErrCryptoSuiteNotAllowed {
+		t.Fatalf("TransactionPreValidation should fail with ErrCryptoSuiteNotAllowed rather than [%v]", err)
+	}
+	if err := validator.Verify(validator.GetID(), []byte("signature"), []byte("message")); err != utils.ErrCryptoSuiteNotAllowed {
+		t.Fatalf("Verify should fail with ErrCryptoSuiteNotAllowed rather than [%v]", err)
+	}
+	if err := CheckCryptoSuites(GetCryptoSuiteName(), GetAllowedCryptoSuites()); err != utils.ErrCryptoSuiteNotAllowed {
+		t.Fatalf("CheckCryptoSuites should fail with ErrCryptoSuiteNotAllowed rather than [%v]", err)
+	}
+}
+
 func TestValidatorDeployTransaction(t *testing.T) {
 	initNodes()
 	defer closeNodes()
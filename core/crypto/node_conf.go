@@ -19,6 +19,7 @@ package crypto
 import (
 	"errors"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -63,6 +64,12 @@ type configuration struct {
 
 	multiThreading bool
 	tCertBatchSize int
+
+	crlVerification    bool
+	crlRefreshInterval time.Duration
+
+	cryptoProviderName string
+	cryptoProviderPath string
 }
 
 func (conf *configuration) init() error {
@@ -152,6 +159,38 @@ func (conf *configuration) init() error {
 		conf.multiThreading = viper.GetBool("security.multithreading.enabled")
 	}
 
+	// Set CRL verification
+	conf.crlVerification = true
+	if viper.IsSet("security.crl.verification") {
+		conf.crlVerification = viper.GetBool("security.crl.verification")
+	}
+
+	conf.crlRefreshInterval = time.Minute
+	if viper.IsSet("security.crl.refresh") {
+		ovveride := viper.GetDuration("security.crl.refresh")
+		if ovveride != 0 {
+			conf.crlRefreshInterval = ovveride
+		}
+	}
+
+	// Set crypto provider
+	conf.cryptoProviderName = defaultCryptoProvider
+	if viper.IsSet("security.provider.name") {
+		ovveride := viper.GetString("security.provider.name")
+		if ovveride != "" {
+			conf.cryptoProviderName = ovveride
+		}
+	}
+
+	// Providers shared by several nodes keep the keys of each node apart
+	conf.cryptoProviderPath = filepath.Join(conf.keystorePath, conf.cryptoProviderName)
+	if viper.IsSet("security.provider.path") {
+		ovveride := viper.GetString("security.provider.path")
+		if ovveride != "" {
+			conf.cryptoProviderPath = filepath.Join(ovveride, conf.prefix, conf.name)
+		}
+	}
+
 	return nil
 }
 
@@ -255,6 +294,22 @@ func (conf *configuration) IsMultithreadingEnabled() bool {
 	return conf.multiThreading
 }
 
+func (conf *configuration) isCRLVerificationEnabled() bool {
+	return conf.crlVerification
+}
+
+func (conf *configuration) getCRLRefreshInterval() time.Duration {
+	return conf.crlRefreshInterval
+}
+
+func (conf *configuration) getCryptoProviderName() string {
+	return conf.cryptoProviderName
+}
+
+func (conf *configuration) getCryptoProviderPath() string {
+	return conf.cryptoProviderPath
+}
+
 func (conf *configuration) getTCAServerName() string {
 	return conf.tlsServerName
 }
@@ -0,0 +1,168 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/x509"
+	"time"
+
+	membersrvc "github.com/hyperledger/fabric/membersrvc/protos"
+	"golang.org/x/net/context"
+)
+
+// isCertificateRevoked checks cert against the CRLs published by the ECA and
+// the TCA. The CRLs are fetched again when they are older than the configured
+// refresh interval.
+func (node *nodeImpl) isCertificateRevoked(cert *x509.Certificate) bool {
+	if !node.conf.isCRLVerificationEnabled() {
+		return false
+	}
+
+	node.refreshCRLs()
+
+	node.crlMutex.Lock()
+	revoked := node.revokedSerials[cert.SerialNumber.String()]
+	node.crlMutex.Unlock()
+
+	return revoked
+}
+
+// refreshCRLs fetches the CRLs again if they are stale. The membership service is
+// called without holding the lock, the verifications in the meantime use the last
+// known CRLs.
+func (node *nodeImpl) refreshCRLs() {
+	node.crlMutex.Lock()
+	if node.crlUpdating || time.Since(node.crlLastUpdate) <= node.conf.getCRLRefreshInterval() {
+		node.crlMutex.Unlock()
+		return
+	}
+	node.crlUpdating = true
+	node.crlMutex.Unlock()
+
+	revokedSerials, err := node.retrieveCRLs()
+
+	node.crlMutex.Lock()
+	if err != nil {
+		// Keep using the last known CRLs if the membership service cannot be reached
+		node.Warningf("Failed retrieving CRLs, using the last known ones [%s].", err)
+	} else {
+		node.revokedSerials = revokedSerials
+	}
+	node.crlLastUpdate = time.Now()
+	node.crlUpdating = false
+	node.crlMutex.Unlock()
+}
+
+func (node *nodeImpl) retrieveCRLs() (map[string]bool, error) {
+	node.Debug("Retrieving CRLs...")
+
+	ecaCRL, err := node.callECAReadCRL(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	tcaCRL, err := node.callTCAReadCRL(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	revokedSerials := make(map[string]bool)
+	if err := node.loadCRL(ecaCRL.Crl, node.conf.getECACertsChainFilename(), revokedSerials); err != nil {
+		return nil, err
+	}
+	if err := node.loadCRL(tcaCRL.Crl, node.conf.getTCACertsChainFilename(), revokedSerials); err != nil {
+		return nil, err
+	}
+
+	node.Debugf("Retrieving CRLs...done. [%d] revoked certificates.", len(revokedSerials))
+
+	return revokedSerials, nil
+}
+
+func (node *nodeImpl) loadCRL(raw []byte, issuerAlias string, revokedSerials map[string]bool) error {
+	if len(raw) == 0 {
+		// No CRL has been published yet
+		return nil
+	}
+
+	crl, err := x509.ParseCRL(raw)
+	if err != nil {
+		node.Errorf("Failed parsing CRL [%s].", err.Error())
+
+		return err
+	}
+
+	issuer, _, err := node.ks.loadCertX509AndDer(issuerAlias)
+	if err != nil {
+		return err
+	}
+	if err = issuer.CheckCRLSignature(crl); err != nil {
+		node.Errorf("Failed verifying CRL signature [%s].", err.Error())
+
+		return err
+	}
+	if crl.HasExpired(time.Now()) {
+		node.Warningf("CRL issued by [%s] has expired.", issuer.Subject.CommonName)
+	}
+
+	for _, revoked := range crl.TBSCertList.RevokedCertificates {
+		revokedSerials[revoked.SerialNumber.String()] = true
+	}
+
+	return nil
+}
+
+func (node *nodeImpl) callECAReadCRL(ctx context.Context) (*membersrvc.CRL, error) {
+	// Get an ECA Client
+	sock, ecaP, err := node.getECAClient()
+	if err != nil {
+		node.Errorf("Failed getting ECA client [%s].", err.Error())
+
+		return nil, err
+	}
+	defer sock.Close()
+
+	// Issue the request
+	crl, err := ecaP.ReadCRL(ctx, &membersrvc.Empty{})
+	if err != nil {
+		node.Errorf("Failed requesting ECA CRL [%s].", err.Error())
+
+		return nil, err
+	}
+
+	return crl, nil
+}
+
+func (node *nodeImpl) callTCAReadCRL(ctx context.Context) (*membersrvc.CRL, error) {
+	// Get a TCA Client
+	sock, tcaP, err := node.getTCAClient()
+	if err != nil {
+		node.Errorf("Failed getting TCA client [%s].", err.Error())
+
+		return nil, err
+	}
+	defer sock.Close()
+
+	// Issue the request
+	crl, err := tcaP.ReadCRL(ctx, &membersrvc.Empty{})
+	if err != nil {
+		node.Errorf("Failed requesting TCA CRL [%s].", err.Error())
+
+		return nil, err
+	}
+
+	return crl, nil
+}
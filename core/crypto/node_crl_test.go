@@ -0,0 +1,165 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	membersrvc "github.com/hyperledger/fabric/membersrvc/protos"
+	"golang.org/x/net/context"
+)
+
+func initCRLTestClient(t *testing.T, name string) *clientImpl {
+	conf := utils.NodeConfiguration{Type: "client", Name: name}
+	if err := RegisterClient(conf.Name, ksPwd, conf.GetEnrollmentID(), conf.GetEnrollmentPWD()); err != nil {
+		t.Fatalf("Failed registering client [%s]", err)
+	}
+	client, err := InitClient(conf.Name, ksPwd)
+	if err != nil {
+		t.Fatalf("Failed initializing client [%s]", err)
+	}
+	return client.(*clientImpl)
+}
+
+func signCRLTestRequest(t *testing.T, node *nodeImpl, req proto.Message) *membersrvc.Signature {
+	raw, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatalf("Failed marshaling request [%s]", err)
+	}
+	r, s, err := node.ecdsaSignWithEnrollmentKey(raw)
+	if err != nil {
+		t.Fatalf("Failed signing request [%s]", err)
+	}
+	R, _ := r.MarshalText()
+	S, _ := s.MarshalText()
+	return &membersrvc.Signature{Type: membersrvc.CryptoType_ECDSA, R: R, S: S}
+}
+
+// isRevokedNow checks cert against the CRLs fetched right away
+func isRevokedNow(node *nodeImpl, cert *x509.Certificate) bool {
+	node.crlMutex.Lock()
+	node.crlLastUpdate = time.Time{}
+	node.crlMutex.Unlock()
+
+	return node.isCertificateRevoked(cert)
+}
+
+func TestCRLRevocation(t *testing.T) {
+	initNodes()
+	defer closeNodes()
+
+	admin := initCRLTestClient(t, "crladmin")
+	defer CloseClient(admin)
+	user := initCRLTestClient(t, "userrevoked")
+	defer CloseClient(user)
+	verifier := validator.(*validatorImpl).nodeImpl
+
+	tcerts, err := user.GetNextTCerts(1)
+	if err != nil {
+		t.Fatalf("Failed getting TCerts [%s]", err)
+	}
+	tcert := tcerts[0].GetCertificate()
+
+	if isRevokedNow(verifier, user.enrollCert) || isRevokedNow(verifier, tcert) {
+		t.Fatal("Certificates should not be revoked yet")
+	}
+
+	sock, err := user.getClientConn(user.conf.getECAPAddr(), user.conf.getECAServerName())
+	if err != nil {
+		t.Fatalf("Failed connecting to the membership services [%s]", err)
+	}
+	defer sock.Close()
+
+	// The TCert is revoked first, the requests signed with a revoked enrollment
+	// certificate are rejected
+	tcertReq := &membersrvc.TCertRevokeReq{Id: &membersrvc.Identity{Id: user.enrollID}, Cert: &membersrvc.Cert{Cert: tcert.Raw}}
+	tcertReq.Sig = signCRLTestRequest(t, user.nodeImpl, tcertReq)
+	if _, err = membersrvc.NewTCAPClient(sock).RevokeCertificate(context.Background(), tcertReq); err != nil {
+		t.Fatalf("Failed revoking TCert [%s]", err)
+	}
+
+	ecertReq := &membersrvc.ECertRevokeReq{Id: &membersrvc.Identity{Id: user.enrollID}}
+	ecertReq.Sig = signCRLTestRequest(t, user.nodeImpl, ecertReq)
+	if _, err = membersrvc.NewECAPClient(sock).RevokeCertificatePair(context.Background(), ecertReq); err != nil {
+		t.Fatalf("Failed revoking enrollment certificates [%s]", err)
+	}
+
+	// Revocations are visible once the CRLs are published
+	if isRevokedNow(verifier, user.enrollCert) || isRevokedNow(verifier, tcert) {
+		t.Fatal("Revocations should not be visible before the CRLs are published")
+	}
+
+	ecaCRLReq := &membersrvc.ECertCRLReq{Id: &membersrvc.Identity{Id: admin.enrollID}}
+	ecaCRLReq.Sig = signCRLTestRequest(t, admin.nodeImpl, ecaCRLReq)
+	if _, err = membersrvc.NewECAAClient(sock).PublishCRL(context.Background(), ecaCRLReq); err != nil {
+		t.Fatalf("Failed publishing ECA CRL [%s]", err)
+	}
+	tcaCRLReq := &membersrvc.TCertCRLReq{Id: &membersrvc.Identity{Id: admin.enrollID}}
+	tcaCRLReq.Sig = signCRLTestRequest(t, admin.nodeImpl, tcaCRLReq)
+	if _, err = membersrvc.NewTCAAClient(sock).PublishCRL(context.Background(), tcaCRLReq); err != nil {
+		t.Fatalf("Failed publishing TCA CRL [%s]", err)
+	}
+
+	if !isRevokedNow(verifier, user.enrollCert) {
+		t.Fatal("Enrollment certificate should be revoked")
+	}
+	if !isRevokedNow(verifier, tcert) {
+		t.Fatal("TCert should be revoked")
+	}
+	if isRevokedNow(verifier, admin.enrollCert) {
+		t.Fatal("Enrollment certificate of the registrar should not be revoked")
+	}
+}
+
+func TestCRLRefreshInProgress(t *testing.T) {
+	initNodes()
+	defer closeNodes()
+
+	verifier := validator.(*validatorImpl).nodeImpl
+	cert := &x509.Certificate{SerialNumber: big.NewInt(7202)}
+
+	verifier.crlMutex.Lock()
+	revokedSerials := verifier.revokedSerials
+	verifier.revokedSerials = map[string]bool{cert.SerialNumber.String(): true}
+	verifier.crlLastUpdate = time.Time{}
+	verifier.crlUpdating = true
+	verifier.crlMutex.Unlock()
+
+	defer func() {
+		verifier.crlMutex.Lock()
+		verifier.revokedSerials = revokedSerials
+		verifier.crlUpdating = false
+		verifier.crlMutex.Unlock()
+	}()
+
+	// While another verification fetches the CRLs the last known ones are used
+	if !verifier.isCertificateRevoked(cert) {
+		t.Fatal("The last known CRLs should be used while they are being fetched")
+	}
+
+	verifier.crlMutex.Lock()
+	stale := verifier.crlLastUpdate.IsZero()
+	verifier.crlMutex.Unlock()
+	if !stale {
+		t.Fatal("The CRLs should be fetched only once at a time")
+	}
+}
@@ -17,8 +17,6 @@ limitations under the License.
 package crypto
 
 import (
-	"crypto/ecdsa"
-	"crypto/rand"
 	"crypto/x509"
 	"google/protobuf"
 	"time"
@@ -31,7 +29,7 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
-	"github.com/hyperledger/fabric/core/crypto/primitives/ecies"
+	"github.com/hyperledger/fabric/core/crypto/utils"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 )
@@ -64,6 +62,20 @@ func (node *nodeImpl) retrieveECACertsChain(userID string) error {
 		return err
 	}
 
+	// The membership services may work with any crypto suite the network allows,
+	// the certificates they issue to the node advertise the suite of the node
+	ecaSuite, err := primitives.GetCertificateCryptoSuite(x509ECACert)
+	if err != nil {
+		node.Errorf("Failed getting ECA crypto suite [%s].", err.Error())
+
+		return err
+	}
+	if ecaSuite != nil && !IsCryptoSuiteAllowed(ecaSuite.Name) {
+		node.Errorf("ECA works with crypto suite [%s], allowed suites are %v.", ecaSuite.Name, GetAllowedCryptoSuites())
+
+		return utils.ErrCryptoSuiteNotAllowed
+	}
+
 	// Prepare ecaCertPool
 	node.ecaCertPool = x509.NewCertPool()
 	node.ecaCertPool.AddCert(x509ECACert)
@@ -84,10 +96,22 @@ func (node *nodeImpl) retrieveEnrollmentData(enrollID, enrollPWD string) error {
 		return nil
 	}
 
-	key, enrollCertRaw, enrollChainKey, err := node.getEnrollmentCertificateFromECA(enrollID, enrollPWD)
+	// Generate enrollment key
+	key, err := node.provider.GenerateKey(node.conf.getEnrollmentKeyFilename())
+	if err != nil {
+		node.Errorf("Failed generating enrollment key [id=%s]: [%s]", enrollID, err)
+
+		return err
+	}
+
+	enrollCertRaw, enrollChainKey, err := node.getEnrollmentCertificateFromECA(enrollID, enrollPWD, key)
 	if err != nil {
 		node.Errorf("Failed getting enrollment certificate [id=%s]: [%s]", enrollID, err)
 
+		if err := node.provider.DeleteKey(key); err != nil {
+			node.Warningf("Failed deleting enrollment key [id=%s]: [%s]", enrollID, err)
+		}
+
 		return err
 	}
 	node.Debugf("Enrollment certificate [% x].", enrollCertRaw)
@@ -101,12 +125,6 @@ func (node *nodeImpl) retrieveEnrollmentData(enrollID, enrollPWD string) error {
 		return err
 	}
 
-	// Store enrollment key
-	if err := node.ks.storePrivateKey(node.conf.getEnrollmentKeyFilename(), key); err != nil {
-		node.Errorf("Failed storing enrollment key [id=%s]: [%s]", enrollID, err)
-		return err
-	}
-
 	// Store enrollment cert
 	if err := node.ks.storeCert(node.conf.getEnrollmentCertFilename(), enrollCertRaw); err != nil {
 		node.Errorf("Failed storing enrollment certificate [id=%s]: [%s]", enrollID, err)
@@ -127,7 +145,7 @@ func (node *nodeImpl) retrieveEnrollmentData(enrollID, enrollPWD string) error {
 			return err
 		}
 
-		if err := node.ks.storePrivateKey(node.conf.getEnrollmentChainKeyFilename(), key); err != nil {
+		if _, err := node.provider.ImportKey(node.conf.getEnrollmentChainKeyFilename(), key); err != nil {
 			node.Errorf("Failed storing enrollment chain key [id=%s]: [%s]", enrollID, err)
 			return err
 		}
@@ -154,14 +172,14 @@ func (node *nodeImpl) retrieveEnrollmentData(enrollID, enrollPWD string) error {
 func (node *nodeImpl) loadEnrollmentKey() error {
 	node.Debug("Loading enrollment key...")
 
-	enrollPrivKey, err := node.ks.loadPrivateKey(node.conf.getEnrollmentKeyFilename())
+	enrollKey, err := node.provider.GetKey(node.conf.getEnrollmentKeyFilename())
 	if err != nil {
 		node.Errorf("Failed loading enrollment private key [%s].", err.Error())
 
 		return err
 	}
 
-	node.enrollPrivKey = enrollPrivKey.(*ecdsa.PrivateKey)
+	node.enrollKey = enrollKey
 
 	return nil
 }
@@ -178,8 +196,7 @@ func (node *nodeImpl) loadEnrollmentCertificate() error {
 	node.enrollCert = cert
 
 	// TODO: move this to retrieve
-	pk := node.enrollCert.PublicKey.(*ecdsa.PublicKey)
-	err = primitives.VerifySignCapability(node.enrollPrivKey, pk)
+	err = checkCertPKAgainstKey(node.enrollCert, node.enrollKey)
 	if err != nil {
 		node.Errorf("Failed checking enrollment certificate against enrollment key [%s].", err.Error())
 
@@ -219,8 +236,8 @@ func (node *nodeImpl) loadEnrollmentChainKey() error {
 
 	// Code for confidentiality 1.2
 	if node.eType == NodeValidator {
-		// enrollChainKey is a secret key
-		enrollChainKey, err := node.ks.loadPrivateKey(node.conf.getEnrollmentChainKeyFilename())
+		// enrollChainKey is a secret key held by the crypto provider
+		enrollChainKey, err := node.provider.GetKey(node.conf.getEnrollmentChainKeyFilename())
 		if err != nil {
 			node.Errorf("Failed loading enrollment chain key: [%s]", err)
 			return err
@@ -265,6 +282,8 @@ func (node *nodeImpl) getECAClient() (*grpc.ClientConn, membersrvc.ECAPClient, e
 	conn, err := node.getClientConn(node.conf.getECAPAddr(), node.conf.getECAServerName())
 	if err != nil {
 		node.Errorf("Failed getting client connection: [%s]", err)
+
+		return nil, nil, err
 	}
 
 	client := membersrvc.NewECAPClient(conn)
@@ -277,6 +296,9 @@ func (node *nodeImpl) getECAClient() (*grpc.ClientConn, membersrvc.ECAPClient, e
 func (node *nodeImpl) callECAReadCACertificate(ctx context.Context, opts ...grpc.CallOption) (*membersrvc.Cert, error) {
 	// Get an ECA Client
 	sock, ecaP, err := node.getECAClient()
+	if err != nil {
+		return nil, err
+	}
 	defer sock.Close()
 
 	// Issue the request
@@ -293,6 +315,9 @@ func (node *nodeImpl) callECAReadCACertificate(ctx context.Context, opts ...grpc
 func (node *nodeImpl) callECAReadCertificate(ctx context.Context, in *membersrvc.ECertReadReq, opts ...grpc.CallOption) (*membersrvc.CertPair, error) {
 	// Get an ECA Client
 	sock, ecaP, err := node.getECAClient()
+	if err != nil {
+		return nil, err
+	}
 	defer sock.Close()
 
 	// Issue the request
@@ -309,6 +334,9 @@ func (node *nodeImpl) callECAReadCertificate(ctx context.Context, in *membersrvc
 func (node *nodeImpl) callECAReadCertificateByHash(ctx context.Context, in *membersrvc.Hash, opts ...grpc.CallOption) (*membersrvc.CertPair, error) {
 	// Get an ECA Client
 	sock, ecaP, err := node.getECAClient()
+	if err != nil {
+		return nil, err
+	}
 	defer sock.Close()
 
 	// Issue the request
@@ -322,37 +350,37 @@ func (node *nodeImpl) callECAReadCertificateByHash(ctx context.Context, in *memb
 	return &membersrvc.CertPair{Sign: resp.Cert, Enc: nil}, nil
 }
 
-func (node *nodeImpl) getEnrollmentCertificateFromECA(id, pw string) (interface{}, []byte, []byte, error) {
+func (node *nodeImpl) getEnrollmentCertificateFromECA(id, pw string, signKey KeyHandle) ([]byte, []byte, error) {
 	// Get a new ECA Client
 	sock, ecaP, err := node.getECAClient()
+	if err != nil {
+		return nil, nil, err
+	}
 	defer sock.Close()
 
 	// Run the protocol
 
-	signPriv, err := primitives.NewECDSAKey()
-	if err != nil {
-		node.Errorf("Failed generating ECDSA key [%s].", err.Error())
-
-		return nil, nil, nil, err
-	}
-	signPub, err := x509.MarshalPKIXPublicKey(&signPriv.PublicKey)
+	signPub, err := x509.MarshalPKIXPublicKey(signKey.PublicKey())
 	if err != nil {
 		node.Errorf("Failed mashalling ECDSA key [%s].", err.Error())
 
-		return nil, nil, nil, err
+		return nil, nil, err
 	}
 
-	encPriv, err := primitives.NewECDSAKey()
+	// The encryption key is only used to decrypt the challenge of the ECA
+	encKey, err := node.provider.GenerateKey("")
 	if err != nil {
 		node.Errorf("Failed generating Encryption key [%s].", err.Error())
 
-		return nil, nil, nil, err
+		return nil, nil, err
 	}
-	encPub, err := x509.MarshalPKIXPublicKey(&encPriv.PublicKey)
+	defer node.provider.DeleteKey(encKey)
+
+	encPub, err := x509.MarshalPKIXPublicKey(encKey.PublicKey())
 	if err != nil {
 		node.Errorf("Failed marshalling Encryption key [%s].", err.Error())
 
-		return nil, nil, nil, err
+		return nil, nil, err
 	}
 
 	req := &membersrvc.ECertCreateReq{
@@ -361,53 +389,43 @@ func (node *nodeImpl) getEnrollmentCertificateFromECA(id, pw string) (interface{
 		Tok:  &membersrvc.Token{Tok: []byte(pw)},
 		Sign: &membersrvc.PublicKey{Type: membersrvc.CryptoType_ECDSA, Key: signPub},
 		Enc:  &membersrvc.PublicKey{Type: membersrvc.CryptoType_ECDSA, Key: encPub},
-		Sig:  nil}
+		Sig:  nil,
+
+		CryptoSuite: GetCryptoSuiteName()}
 
 	resp, err := ecaP.CreateCertificatePair(context.Background(), req)
 	if err != nil {
 		node.Errorf("Failed invoking CreateCertficatePair [%s].", err.Error())
 
-		return nil, nil, nil, err
+		return nil, nil, err
 	}
 
 	if resp.FetchResult != nil && resp.FetchResult.Status != membersrvc.FetchAttrsResult_SUCCESS {
 		node.Warning(resp.FetchResult.Msg)
 	}
 	//out, err := rsa.DecryptPKCS1v15(rand.Reader, encPriv, resp.Tok.Tok)
-	spi := ecies.NewSPI()
-	eciesKey, err := spi.NewPrivateKey(nil, encPriv)
-	if err != nil {
-		node.Errorf("Failed parsing decrypting key [%s].", err.Error())
-
-		return nil, nil, nil, err
-	}
-
-	ecies, err := spi.NewAsymmetricCipherFromPublicKey(eciesKey)
-	if err != nil {
-		node.Errorf("Failed creating asymmetrinc cipher [%s].", err.Error())
-
-		return nil, nil, nil, err
-	}
-
-	out, err := ecies.Process(resp.Tok.Tok)
+	out, err := node.provider.Decrypt(encKey, resp.Tok.Tok)
 	if err != nil {
 		node.Errorf("Failed decrypting toke [%s].", err.Error())
 
-		return nil, nil, nil, err
+		return nil, nil, err
 	}
 
 	req.Tok.Tok = out
 	req.Sig = nil
 
-	hash := primitives.NewHash()
 	raw, _ := proto.Marshal(req)
-	hash.Write(raw)
+	signature, err := node.provider.Sign(signKey, raw)
+	if err != nil {
+		node.Errorf("Failed signing [%s].", err.Error())
 
-	r, s, err := ecdsa.Sign(rand.Reader, signPriv, hash.Sum(nil))
+		return nil, nil, err
+	}
+	r, s, err := ecdsaSignatureToRS(signature)
 	if err != nil {
 		node.Errorf("Failed signing [%s].", err.Error())
 
-		return nil, nil, nil, err
+		return nil, nil, err
 	}
 	R, _ := r.MarshalText()
 	S, _ := s.MarshalText()
@@ -417,7 +435,7 @@ func (node *nodeImpl) getEnrollmentCertificateFromECA(id, pw string) (interface{
 	if err != nil {
 		node.Errorf("Failed invoking CreateCertificatePair [%s].", err.Error())
 
-		return nil, nil, nil, err
+		return nil, nil, err
 	}
 
 	// Verify response
@@ -429,21 +447,33 @@ func (node *nodeImpl) getEnrollmentCertificateFromECA(id, pw string) (interface{
 	if err != nil {
 		node.Errorf("Failed parsing signing enrollment certificate for signing: [%s]", err)
 
-		return nil, nil, nil, err
+		return nil, nil, err
 	}
 
 	_, err = primitives.GetCriticalExtension(x509SignCert, ECertSubjectRole)
 	if err != nil {
 		node.Errorf("Failed parsing ECertSubjectRole in enrollment certificate for signing: [%s]", err)
 
-		return nil, nil, nil, err
+		return nil, nil, err
+	}
+
+	signSuite, err := primitives.GetCertificateCryptoSuite(x509SignCert)
+	if err != nil {
+		node.Errorf("Failed getting crypto suite of enrollment certificate for signing: [%s]", err)
+
+		return nil, nil, err
+	}
+	if signSuite != nil && signSuite.Name != GetCryptoSuiteName() {
+		node.Errorf("Enrollment certificate for signing advertises crypto suite [%s], the node works with [%s].", signSuite.Name, GetCryptoSuiteName())
+
+		return nil, nil, utils.ErrCryptoSuiteNotAllowed
 	}
 
-	err = primitives.CheckCertAgainstSKAndRoot(x509SignCert, signPriv, node.ecaCertPool)
+	err = checkCertAgainstKeyAndRoot(x509SignCert, signKey, node.ecaCertPool)
 	if err != nil {
 		node.Errorf("Failed checking signing enrollment certificate for signing: [%s]", err)
 
-		return nil, nil, nil, err
+		return nil, nil, err
 	}
 
 	// Verify cert for encrypting
@@ -453,24 +483,24 @@ func (node *nodeImpl) getEnrollmentCertificateFromECA(id, pw string) (interface{
 	if err != nil {
 		node.Errorf("Failed parsing signing enrollment certificate for encrypting: [%s]", err)
 
-		return nil, nil, nil, err
+		return nil, nil, err
 	}
 
 	_, err = primitives.GetCriticalExtension(x509EncCert, ECertSubjectRole)
 	if err != nil {
 		node.Errorf("Failed parsing ECertSubjectRole in enrollment certificate for encrypting: [%s]", err)
 
-		return nil, nil, nil, err
+		return nil, nil, err
 	}
 
-	err = primitives.CheckCertAgainstSKAndRoot(x509EncCert, encPriv, node.ecaCertPool)
+	err = checkCertAgainstKeyAndRoot(x509EncCert, encKey, node.ecaCertPool)
 	if err != nil {
 		node.Errorf("Failed checking signing enrollment certificate for encrypting: [%s]", err)
 
-		return nil, nil, nil, err
+		return nil, nil, err
 	}
 
-	return signPriv, resp.Certs.Sign, resp.Pkchain, nil
+	return resp.Certs.Sign, resp.Pkchain, nil
 }
 
 func (node *nodeImpl) getECACertificate() ([]byte, error) {
@@ -17,8 +17,9 @@ limitations under the License.
 package crypto
 
 import (
-	"crypto/ecdsa"
 	"crypto/x509"
+	"sync"
+	"time"
 
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/hyperledger/fabric/core/crypto/utils"
@@ -39,6 +40,9 @@ type nodeImpl struct {
 	// keyStore
 	ks *keyStore
 
+	// Crypto provider holding the private keys
+	provider CryptoProvider
+
 	// Certs Pool
 	rootsCertPool *x509.CertPool
 	tlsCertPool   *x509.CertPool
@@ -51,7 +55,7 @@ type nodeImpl struct {
 	// Enrollment Certificate and private key
 	enrollID       string
 	enrollCert     *x509.Certificate
-	enrollPrivKey  *ecdsa.PrivateKey
+	enrollKey      KeyHandle
 	enrollCertHash []byte
 
 	// Enrollment Chain
@@ -62,6 +66,12 @@ type nodeImpl struct {
 
 	// Crypto SPI
 	eciesSPI primitives.AsymmetricCipherSPI
+
+	// Certificate revocation lists
+	crlMutex       sync.Mutex
+	crlLastUpdate  time.Time
+	crlUpdating    bool
+	revokedSerials map[string]bool
 }
 
 type registerFunc func(eType NodeType, name string, pwd []byte, enrollID, enrollPWD string) error
@@ -112,6 +122,12 @@ func (node *nodeImpl) register(eType NodeType, name string, pwd []byte, enrollID
 		return err
 	}
 
+	// Initialize crypto provider
+	if err = node.initCryptoProvider(pwd); err != nil {
+		node.Errorf("Failed initiliazing crypto provider [%s].", err.Error())
+		return err
+	}
+
 	if node.IsRegistered() {
 		return utils.ErrAlreadyRegistered
 	}
@@ -169,6 +185,12 @@ func (node *nodeImpl) init(eType NodeType, name string, pwd []byte, initFunc ini
 		return err
 	}
 
+	// Initialize crypto provider
+	if err = node.initCryptoProvider(pwd); err != nil {
+		node.Errorf("Failed initiliazing crypto provider [%s].", err.Error())
+		return err
+	}
+
 	if node.IsInitialized() {
 		return utils.ErrAlreadyInitialized
 	}
@@ -202,11 +224,17 @@ func (node *nodeImpl) nodeInit(eType NodeType, name string, pwd []byte) error {
 }
 
 func (node *nodeImpl) close() error {
-	// Close keystore
+	// Close crypto provider and keystore
 	var err error
 
+	if node.provider != nil {
+		err = node.provider.Close()
+	}
+
 	if node.ks != nil {
-		err = node.ks.close()
+		if ksErr := node.ks.close(); err == nil {
+			err = ksErr
+		}
 	}
 
 	return err
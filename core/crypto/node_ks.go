@@ -153,6 +153,10 @@ func (ks *keyStore) deletePrivateKeyInClear(alias string) error {
 	return os.Remove(ks.node.conf.getPathForAlias(alias))
 }
 
+func (ks *keyStore) deletePrivateKey(alias string) error {
+	return os.Remove(ks.node.conf.getPathForAlias(alias))
+}
+
 func (ks *keyStore) loadPrivateKey(alias string) (interface{}, error) {
 	path := ks.node.conf.getPathForAlias(alias)
 	ks.node.Debugf("Loading private key [%s] at [%s]...", alias, path)
@@ -0,0 +1,231 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/crypto/primitives/ecies"
+	"github.com/hyperledger/fabric/core/crypto/utils"
+)
+
+// KeyHandle identifies a key held by a CryptoProvider. The private part of the key
+// never leaves the provider.
+type KeyHandle interface {
+	// Alias returns the name the key is stored under, empty if the key is not stored.
+	Alias() string
+
+	// PublicKey returns the public part of the key.
+	PublicKey() interface{}
+}
+
+// CryptoProvider generates, stores and uses the private keys of a node, in the manner of a
+// PKCS#11 token. Keys are ECDSA keys on the curve of the security level.
+type CryptoProvider interface {
+	// GenerateKey generates a key and stores it under alias. The key is not stored if alias
+	// is empty.
+	GenerateKey(alias string) (KeyHandle, error)
+
+	// ImportKey stores a private key under alias.
+	ImportKey(alias string, privateKey interface{}) (KeyHandle, error)
+
+	// GetKey returns the key stored under alias.
+	GetKey(alias string) (KeyHandle, error)
+
+	// DeriveKey returns the key whose private part is the one of the key plus k, as TCert
+	// keys are derived from the enrollment key. The derived key is not stored.
+	DeriveKey(key KeyHandle, k *big.Int) (KeyHandle, error)
+
+	// DeleteKey removes the key.
+	DeleteKey(key KeyHandle) error
+
+	// Sign signs msg with the key and returns the ASN.1 encoded ECDSA signature.
+	Sign(key KeyHandle, msg []byte) ([]byte, error)
+
+	// Verify verifies the signature of msg against the key.
+	Verify(key KeyHandle, msg, signature []byte) (bool, error)
+
+	// Encrypt encrypts msg to the key with ECIES.
+	Encrypt(key KeyHandle, msg []byte) ([]byte, error)
+
+	// Decrypt decrypts ct with the key.
+	Decrypt(key KeyHandle, ct []byte) ([]byte, error)
+
+	// Close releases the resources of the provider.
+	Close() error
+}
+
+// CryptoProviderFactory creates a provider keeping its keys at path, protected by pwd.
+type CryptoProviderFactory func(path string, pwd []byte) (CryptoProvider, error)
+
+// defaultCryptoProvider keeps the keys in the keystore of the node
+const defaultCryptoProvider = "sw"
+
+var (
+	cryptoProvidersMutex sync.Mutex
+	cryptoProviders      = make(map[string]CryptoProviderFactory)
+)
+
+// RegisterCryptoProvider makes a provider available under name to the security.provider.name
+// property.
+func RegisterCryptoProvider(name string, factory CryptoProviderFactory) error {
+	cryptoProvidersMutex.Lock()
+	defer cryptoProvidersMutex.Unlock()
+
+	if _, ok := cryptoProviders[name]; ok || name == defaultCryptoProvider {
+		return fmt.Errorf("Crypto provider [%s] already registered", name)
+	}
+	cryptoProviders[name] = factory
+
+	return nil
+}
+
+func getCryptoProviderFactory(name string) (CryptoProviderFactory, bool) {
+	cryptoProvidersMutex.Lock()
+	defer cryptoProvidersMutex.Unlock()
+
+	factory, ok := cryptoProviders[name]
+	return factory, ok
+}
+
+func (node *nodeImpl) initCryptoProvider(pwd []byte) error {
+	name := node.conf.getCryptoProviderName()
+	if name == defaultCryptoProvider {
+		node.provider = &swCryptoProvider{ks: node.ks}
+		return nil
+	}
+
+	factory, ok := getCryptoProviderFactory(name)
+	if !ok {
+		return fmt.Errorf("Unknown crypto provider [%s]", name)
+	}
+
+	node.Debugf("Initializing crypto provider [%s] at [%s]...", name, node.conf.getCryptoProviderPath())
+	provider, err := factory(node.conf.getCryptoProviderPath(), pwd)
+	if err != nil {
+		return err
+	}
+	node.provider = provider
+
+	return nil
+}
+
+func checkECDSAPublicKey(key KeyHandle) (*ecdsa.PublicKey, error) {
+	if key == nil {
+		return nil, utils.ErrNilArgument
+	}
+	pk, ok := key.PublicKey().(*ecdsa.PublicKey)
+	if !ok {
+		return nil, utils.ErrInvalidKey
+	}
+	return pk, nil
+}
+
+// checkCertPKAgainstKey checks that the certificate is the one of the key
+func checkCertPKAgainstKey(x509Cert *x509.Certificate, key KeyHandle) error {
+	pk, err := checkECDSAPublicKey(key)
+	if err != nil {
+		return err
+	}
+	certPK, ok := x509Cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return errors.New("Key type does not match public key type")
+	}
+	if certPK.X.Cmp(pk.X) != 0 || certPK.Y.Cmp(pk.Y) != 0 {
+		return errors.New("Key does not match public key")
+	}
+
+	return nil
+}
+
+// checkCertAgainstKeyAndRoot checks the certificate against the key and certPool
+func checkCertAgainstKeyAndRoot(x509Cert *x509.Certificate, key KeyHandle, certPool *x509.CertPool) error {
+	if err := checkCertPKAgainstKey(x509Cert, key); err != nil {
+		return err
+	}
+
+	if _, err := primitives.CheckCertAgainRoot(x509Cert, certPool); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// deriveECDSAKey returns the key whose secret is the one of sk plus k
+func deriveECDSAKey(sk *ecdsa.PrivateKey, k *big.Int) *ecdsa.PrivateKey {
+	derived := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: sk.Curve},
+		D:         new(big.Int).Add(sk.D, k),
+	}
+	derived.D.Mod(derived.D, sk.Params().N)
+
+	derived.PublicKey.X, derived.PublicKey.Y = deriveECDSAPublicKey(&sk.PublicKey, k)
+
+	return derived
+}
+
+// deriveECDSAPublicKey returns the point pk + kG
+func deriveECDSAPublicKey(pk *ecdsa.PublicKey, k *big.Int) (*big.Int, *big.Int) {
+	kX, kY := pk.ScalarBaseMult(k.Bytes())
+	return pk.Add(pk.X, pk.Y, kX, kY)
+}
+
+func eciesEncrypt(pk *ecdsa.PublicKey, msg []byte) ([]byte, error) {
+	spi := ecies.NewSPI()
+	eciesKey, err := spi.NewPublicKey(nil, pk)
+	if err != nil {
+		return nil, err
+	}
+
+	cipher, err := spi.NewAsymmetricCipherFromPublicKey(eciesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.Process(msg)
+}
+
+func eciesDecrypt(sk *ecdsa.PrivateKey, ct []byte) ([]byte, error) {
+	spi := ecies.NewSPI()
+	eciesKey, err := spi.NewPrivateKey(nil, sk)
+	if err != nil {
+		return nil, err
+	}
+
+	cipher, err := spi.NewAsymmetricCipherFromPrivateKey(eciesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.Process(ct)
+}
+
+// ecdsaSignatureToRS splits a signature returned by a provider
+func ecdsaSignatureToRS(signature []byte) (*big.Int, *big.Int, error) {
+	ecdsaSignature := new(primitives.ECDSASignature)
+	if _, err := asn1.Unmarshal(signature, ecdsaSignature); err != nil {
+		return nil, nil, err
+	}
+	return ecdsaSignature.R, ecdsaSignature.S, nil
+}
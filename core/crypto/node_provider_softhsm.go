@@ -0,0 +1,288 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/crypto/utils"
+)
+
+// softHSMProviderName selects the soft HSM in the security.provider.name property
+const softHSMProviderName = "softhsm"
+
+func init() {
+	RegisterCryptoProvider(softHSMProviderName, newSoftHSMProvider)
+}
+
+var errSoftHSMClosed = errors.New("Soft HSM closed.")
+
+// softHSMKey refers to a key of the soft HSM. It carries the public key only, derived keys
+// also carry the offset of their secret from the one of the key they are derived from.
+type softHSMKey struct {
+	hsm   *softHSMProvider
+	id    string
+	alias string
+	pk    *ecdsa.PublicKey
+	k     *big.Int
+}
+
+func (key *softHSMKey) Alias() string {
+	return key.alias
+}
+
+func (key *softHSMKey) PublicKey() interface{} {
+	return key.pk
+}
+
+// softHSMProvider stands in for a hardware token. Each key is a file of its directory,
+// encrypted with the password of the node, and is only used inside the provider: no
+// operation returns a private key.
+type softHSMProvider struct {
+	path string
+	pin  []byte
+
+	keys    map[string]*ecdsa.PrivateKey
+	counter int
+
+	m sync.Mutex
+}
+
+func newSoftHSMProvider(path string, pwd []byte) (CryptoProvider, error) {
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, err
+	}
+
+	return &softHSMProvider{
+		path: path,
+		pin:  utils.Clone(pwd),
+		keys: make(map[string]*ecdsa.PrivateKey),
+	}, nil
+}
+
+func (hsm *softHSMProvider) getPathForAlias(alias string) (string, error) {
+	if alias == "" || filepath.Base(alias) != alias {
+		return "", fmt.Errorf("Invalid key alias [%s]", alias)
+	}
+	return filepath.Join(hsm.path, alias+".key"), nil
+}
+
+// getPrivateKey returns the private key of a handle of this provider. The caller must hold
+// the lock.
+func (hsm *softHSMProvider) getPrivateKey(key KeyHandle) (*ecdsa.PrivateKey, error) {
+	if hsm.keys == nil {
+		return nil, errSoftHSMClosed
+	}
+	if key == nil {
+		return nil, utils.ErrNilArgument
+	}
+	hsmKey, ok := key.(*softHSMKey)
+	if !ok || hsmKey.hsm != hsm {
+		return nil, utils.ErrInvalidKey
+	}
+
+	sk, ok := hsm.keys[hsmKey.id]
+	if !ok {
+		return nil, utils.ErrInvalidKey
+	}
+	if hsmKey.k != nil {
+		return deriveECDSAKey(sk, hsmKey.k), nil
+	}
+	return sk, nil
+}
+
+func (hsm *softHSMProvider) GenerateKey(alias string) (KeyHandle, error) {
+	sk, err := primitives.NewECDSAKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return hsm.ImportKey(alias, sk)
+}
+
+func (hsm *softHSMProvider) ImportKey(alias string, privateKey interface{}) (KeyHandle, error) {
+	sk, ok := privateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, utils.ErrInvalidKey
+	}
+
+	hsm.m.Lock()
+	defer hsm.m.Unlock()
+
+	if hsm.keys == nil {
+		return nil, errSoftHSMClosed
+	}
+
+	id := alias
+	if alias == "" {
+		hsm.counter++
+		id = "#" + strconv.Itoa(hsm.counter)
+	} else {
+		path, err := hsm.getPathForAlias(alias)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := primitives.PrivateKeyToPEM(sk, hsm.pin)
+		if err != nil {
+			return nil, err
+		}
+		if err = ioutil.WriteFile(path, raw, 0600); err != nil {
+			return nil, err
+		}
+	}
+
+	// Keep a copy, later changes to the imported key must not affect the token
+	hsm.keys[id] = &ecdsa.PrivateKey{PublicKey: sk.PublicKey, D: new(big.Int).Set(sk.D)}
+
+	return &softHSMKey{hsm: hsm, id: id, alias: alias, pk: &hsm.keys[id].PublicKey}, nil
+}
+
+func (hsm *softHSMProvider) GetKey(alias string) (KeyHandle, error) {
+	hsm.m.Lock()
+	defer hsm.m.Unlock()
+
+	if hsm.keys == nil {
+		return nil, errSoftHSMClosed
+	}
+
+	sk, ok := hsm.keys[alias]
+	if !ok {
+		path, err := hsm.getPathForAlias(alias)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		privateKey, err := primitives.PEMtoPrivateKey(raw, hsm.pin)
+		if err != nil {
+			return nil, err
+		}
+		if sk, ok = privateKey.(*ecdsa.PrivateKey); !ok {
+			return nil, utils.ErrInvalidKey
+		}
+		hsm.keys[alias] = sk
+	}
+
+	return &softHSMKey{hsm: hsm, id: alias, alias: alias, pk: &sk.PublicKey}, nil
+}
+
+func (hsm *softHSMProvider) DeriveKey(key KeyHandle, k *big.Int) (KeyHandle, error) {
+	hsm.m.Lock()
+	defer hsm.m.Unlock()
+
+	if _, err := hsm.getPrivateKey(key); err != nil {
+		return nil, err
+	}
+
+	hsmKey := key.(*softHSMKey)
+	pk := &ecdsa.PublicKey{Curve: hsmKey.pk.Curve}
+	pk.X, pk.Y = deriveECDSAPublicKey(hsmKey.pk, k)
+
+	// The offsets of successive derivations add up
+	offset := new(big.Int).Set(k)
+	if hsmKey.k != nil {
+		offset.Add(offset, hsmKey.k)
+		offset.Mod(offset, pk.Params().N)
+	}
+
+	return &softHSMKey{hsm: hsm, id: hsmKey.id, pk: pk, k: offset}, nil
+}
+
+func (hsm *softHSMProvider) DeleteKey(key KeyHandle) error {
+	hsm.m.Lock()
+	defer hsm.m.Unlock()
+
+	if _, err := hsm.getPrivateKey(key); err != nil {
+		return err
+	}
+
+	hsmKey := key.(*softHSMKey)
+	if hsmKey.k != nil {
+		// Derived keys are not kept
+		return nil
+	}
+	delete(hsm.keys, hsmKey.id)
+	if hsmKey.alias == "" {
+		return nil
+	}
+
+	path, err := hsm.getPathForAlias(hsmKey.alias)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (hsm *softHSMProvider) Sign(key KeyHandle, msg []byte) ([]byte, error) {
+	hsm.m.Lock()
+	sk, err := hsm.getPrivateKey(key)
+	hsm.m.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return primitives.ECDSASign(sk, msg)
+}
+
+func (hsm *softHSMProvider) Verify(key KeyHandle, msg, signature []byte) (bool, error) {
+	pk, err := checkECDSAPublicKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	return primitives.ECDSAVerify(pk, msg, signature)
+}
+
+func (hsm *softHSMProvider) Encrypt(key KeyHandle, msg []byte) ([]byte, error) {
+	pk, err := checkECDSAPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return eciesEncrypt(pk, msg)
+}
+
+func (hsm *softHSMProvider) Decrypt(key KeyHandle, ct []byte) ([]byte, error) {
+	hsm.m.Lock()
+	sk, err := hsm.getPrivateKey(key)
+	hsm.m.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return eciesDecrypt(sk, ct)
+}
+
+// Close forgets the keys loaded from the token, the handles are no longer valid
+func (hsm *softHSMProvider) Close() error {
+	hsm.m.Lock()
+	defer hsm.m.Unlock()
+
+	hsm.keys = nil
+	return nil
+}
@@ -0,0 +1,155 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/crypto/utils"
+)
+
+// swKey is a key of the software provider, the private key is kept in memory
+type swKey struct {
+	alias string
+	sk    *ecdsa.PrivateKey
+}
+
+func (key *swKey) Alias() string {
+	return key.alias
+}
+
+func (key *swKey) PublicKey() interface{} {
+	return &key.sk.PublicKey
+}
+
+// swCryptoProvider is the default provider. Keys are stored in the keystore of the node
+// as PEM encrypted with the password of the node.
+type swCryptoProvider struct {
+	ks *keyStore
+}
+
+func (provider *swCryptoProvider) getPrivateKey(key KeyHandle) (*ecdsa.PrivateKey, error) {
+	if key == nil {
+		return nil, utils.ErrNilArgument
+	}
+	sw, ok := key.(*swKey)
+	if !ok {
+		return nil, utils.ErrInvalidKey
+	}
+	return sw.sk, nil
+}
+
+func (provider *swCryptoProvider) GenerateKey(alias string) (KeyHandle, error) {
+	sk, err := primitives.NewECDSAKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return provider.ImportKey(alias, sk)
+}
+
+func (provider *swCryptoProvider) ImportKey(alias string, privateKey interface{}) (KeyHandle, error) {
+	sk, ok := privateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, utils.ErrInvalidKey
+	}
+
+	if alias != "" {
+		if err := provider.ks.storePrivateKey(alias, sk); err != nil {
+			return nil, err
+		}
+	}
+
+	return &swKey{alias, sk}, nil
+}
+
+func (provider *swCryptoProvider) GetKey(alias string) (KeyHandle, error) {
+	privateKey, err := provider.ks.loadPrivateKey(alias)
+	if err != nil {
+		return nil, err
+	}
+
+	sk, ok := privateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, utils.ErrInvalidKey
+	}
+
+	return &swKey{alias, sk}, nil
+}
+
+func (provider *swCryptoProvider) DeriveKey(key KeyHandle, k *big.Int) (KeyHandle, error) {
+	sk, err := provider.getPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &swKey{"", deriveECDSAKey(sk, k)}, nil
+}
+
+func (provider *swCryptoProvider) DeleteKey(key KeyHandle) error {
+	if _, err := provider.getPrivateKey(key); err != nil {
+		return err
+	}
+	if key.Alias() == "" {
+		return nil
+	}
+
+	return provider.ks.deletePrivateKey(key.Alias())
+}
+
+func (provider *swCryptoProvider) Sign(key KeyHandle, msg []byte) ([]byte, error) {
+	sk, err := provider.getPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return primitives.ECDSASign(sk, msg)
+}
+
+func (provider *swCryptoProvider) Verify(key KeyHandle, msg, signature []byte) (bool, error) {
+	pk, err := checkECDSAPublicKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	return primitives.ECDSAVerify(pk, msg, signature)
+}
+
+func (provider *swCryptoProvider) Encrypt(key KeyHandle, msg []byte) ([]byte, error) {
+	pk, err := checkECDSAPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return eciesEncrypt(pk, msg)
+}
+
+func (provider *swCryptoProvider) Decrypt(key KeyHandle, ct []byte) ([]byte, error) {
+	sk, err := provider.getPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return eciesDecrypt(sk, ct)
+}
+
+// Close does nothing, the keystore is closed with the node
+func (provider *swCryptoProvider) Close() error {
+	return nil
+}
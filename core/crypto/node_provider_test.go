@@ -0,0 +1,209 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+)
+
+func newTestSoftHSM(t *testing.T, path string) CryptoProvider {
+	hsm, err := newSoftHSMProvider(path, ksPwd)
+	if err != nil {
+		t.Fatalf("Failed creating soft HSM [%s]", err)
+	}
+	return hsm
+}
+
+func TestSoftHSMSignEncrypt(t *testing.T) {
+	path, err := ioutil.TempDir("", "softhsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	hsm := newTestSoftHSM(t, path)
+	defer hsm.Close()
+
+	key, err := hsm.GenerateKey("enrollment.key")
+	if err != nil {
+		t.Fatalf("Failed generating key [%s]", err)
+	}
+	if _, ok := key.PublicKey().(*ecdsa.PublicKey); !ok {
+		t.Fatalf("Expected an ECDSA public key, got %T", key.PublicKey())
+	}
+	if _, ok := key.(interface {
+		PrivateKey() interface{}
+	}); ok {
+		t.Fatal("Soft HSM key handles must not expose the private key")
+	}
+
+	msg := []byte("Hello World")
+	signature, err := hsm.Sign(key, msg)
+	if err != nil {
+		t.Fatalf("Failed signing [%s]", err)
+	}
+	if ok, err := hsm.Verify(key, msg, signature); err != nil || !ok {
+		t.Fatalf("Failed verifying signature [%t][%v]", ok, err)
+	}
+	if ok, err := primitives.ECDSAVerify(key.PublicKey(), msg, signature); err != nil || !ok {
+		t.Fatalf("Failed verifying signature against the public key [%t][%v]", ok, err)
+	}
+
+	ct, err := hsm.Encrypt(key, msg)
+	if err != nil {
+		t.Fatalf("Failed encrypting [%s]", err)
+	}
+	pt, err := hsm.Decrypt(key, ct)
+	if err != nil {
+		t.Fatalf("Failed decrypting [%s]", err)
+	}
+	if !bytes.Equal(msg, pt) {
+		t.Fatalf("Decrypted [%s] instead of [%s]", pt, msg)
+	}
+
+	// The key is stored encrypted
+	raw, err := ioutil.ReadFile(filepath.Join(path, "enrollment.key.key"))
+	if err != nil {
+		t.Fatalf("Failed reading key file [%s]", err)
+	}
+	if _, err := primitives.PEMtoPrivateKey(raw, nil); err == nil {
+		t.Fatal("The key file must not be readable without the password")
+	}
+
+	// Keys of another provider are rejected
+	other := newTestSoftHSM(t, path)
+	defer other.Close()
+	if _, err := other.Sign(key, msg); err == nil {
+		t.Fatal("Signing with the key of another provider must fail")
+	}
+}
+
+func TestSoftHSMPersistence(t *testing.T) {
+	path, err := ioutil.TempDir("", "softhsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	hsm := newTestSoftHSM(t, path)
+	sk, err := primitives.NewECDSAKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := hsm.ImportKey("chain.key", sk)
+	if err != nil {
+		t.Fatalf("Failed importing key [%s]", err)
+	}
+	ephemeral, err := hsm.GenerateKey("")
+	if err != nil {
+		t.Fatalf("Failed generating key [%s]", err)
+	}
+	if _, err := hsm.ImportKey("../chain.key", sk); err == nil {
+		t.Fatal("Aliases must not name files out of the soft HSM")
+	}
+	hsm.Close()
+
+	if _, err := hsm.Sign(imported, []byte("Hello World")); err == nil {
+		t.Fatal("Handles must not be usable once the soft HSM is closed")
+	}
+
+	hsm = newTestSoftHSM(t, path)
+	defer hsm.Close()
+
+	key, err := hsm.GetKey("chain.key")
+	if err != nil {
+		t.Fatalf("Failed loading key [%s]", err)
+	}
+	pk := key.PublicKey().(*ecdsa.PublicKey)
+	if pk.X.Cmp(sk.X) != 0 || pk.Y.Cmp(sk.Y) != 0 {
+		t.Fatal("Loaded a different key")
+	}
+	if _, err := hsm.GetKey(ephemeral.Alias()); err == nil {
+		t.Fatal("Keys generated without alias must not be stored")
+	}
+
+	// A wrong password does not open the key
+	wrong, err := newSoftHSMProvider(path, []byte("wrong password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wrong.Close()
+	if _, err := wrong.GetKey("chain.key"); err == nil {
+		t.Fatal("Loading a key with the wrong password must fail")
+	}
+
+	if err := hsm.DeleteKey(key); err != nil {
+		t.Fatalf("Failed deleting key [%s]", err)
+	}
+	if _, err := hsm.GetKey("chain.key"); err == nil {
+		t.Fatal("Deleted keys must not be loaded")
+	}
+}
+
+func TestSoftHSMDeriveKey(t *testing.T) {
+	path, err := ioutil.TempDir("", "softhsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	hsm := newTestSoftHSM(t, path)
+	defer hsm.Close()
+
+	sk, err := primitives.NewECDSAKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := hsm.ImportKey("", sk)
+	if err != nil {
+		t.Fatalf("Failed importing key [%s]", err)
+	}
+
+	k1, k2 := big.NewInt(12345), big.NewInt(67890)
+	derived, err := hsm.DeriveKey(key, k1)
+	if err != nil {
+		t.Fatalf("Failed deriving key [%s]", err)
+	}
+	derived, err = hsm.DeriveKey(derived, k2)
+	if err != nil {
+		t.Fatalf("Failed deriving key [%s]", err)
+	}
+
+	// The key derived twice is the one derived once with the sum of the offsets
+	expected := deriveECDSAKey(sk, new(big.Int).Add(k1, k2))
+	pk := derived.PublicKey().(*ecdsa.PublicKey)
+	if pk.X.Cmp(expected.X) != 0 || pk.Y.Cmp(expected.Y) != 0 {
+		t.Fatal("Derived an unexpected public key")
+	}
+
+	msg := []byte("Hello World")
+	signature, err := hsm.Sign(derived, msg)
+	if err != nil {
+		t.Fatalf("Failed signing with derived key [%s]", err)
+	}
+	if ok, err := primitives.ECDSAVerify(&expected.PublicKey, msg, signature); err != nil || !ok {
+		t.Fatalf("Failed verifying signature of derived key [%t][%v]", ok, err)
+	}
+}
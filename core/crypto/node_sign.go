@@ -17,27 +17,44 @@ limitations under the License.
 package crypto
 
 import (
+	"crypto/x509"
 	"math/big"
 
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 )
 
-func (node *nodeImpl) sign(signKey interface{}, msg []byte) ([]byte, error) {
-	return primitives.ECDSASign(signKey, msg)
+func (node *nodeImpl) signWithKey(key KeyHandle, msg []byte) ([]byte, error) {
+	return node.provider.Sign(key, msg)
 }
 
 func (node *nodeImpl) signWithEnrollmentKey(msg []byte) ([]byte, error) {
-	return primitives.ECDSASign(node.enrollPrivKey, msg)
+	return node.provider.Sign(node.enrollKey, msg)
 }
 
 func (node *nodeImpl) ecdsaSignWithEnrollmentKey(msg []byte) (*big.Int, *big.Int, error) {
-	return primitives.ECDSASignDirect(node.enrollPrivKey, msg)
+	signature, err := node.provider.Sign(node.enrollKey, msg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ecdsaSignatureToRS(signature)
 }
 
 func (node *nodeImpl) verify(verKey interface{}, msg, signature []byte) (bool, error) {
 	return primitives.ECDSAVerify(verKey, msg, signature)
 }
 
+// verifyWithCert verifies a signature of the subject of the certificate, computed with the
+// crypto suite the certificate advertises
+func (node *nodeImpl) verifyWithCert(cert *x509.Certificate, msg, signature []byte) (bool, error) {
+	suite, err := getCertCryptoSuite(cert)
+	if err != nil {
+		return false, err
+	}
+
+	return primitives.ECDSAVerifyWithHash(suite.Hash, cert.PublicKey, msg, signature)
+}
+
 func (node *nodeImpl) verifyWithEnrollmentCert(msg, signature []byte) (bool, error) {
-	return primitives.ECDSAVerify(node.enrollCert.PublicKey, msg, signature)
+	return node.provider.Verify(node.enrollKey, msg, signature)
 }
@@ -87,6 +87,8 @@ func (node *nodeImpl) getTCAClient() (*grpc.ClientConn, membersrvc.TCAPClient, e
 	conn, err := node.getClientConn(node.conf.getTCAPAddr(), node.conf.getTCAServerName())
 	if err != nil {
 		node.Errorf("Failed getting client connection: [%s]", err)
+
+		return nil, nil, err
 	}
 
 	client := membersrvc.NewTCAPClient(conn)
@@ -99,6 +101,9 @@ func (node *nodeImpl) getTCAClient() (*grpc.ClientConn, membersrvc.TCAPClient, e
 func (node *nodeImpl) callTCAReadCACertificate(ctx context.Context, opts ...grpc.CallOption) (*membersrvc.Cert, error) {
 	// Get a TCA Client
 	sock, tcaP, err := node.getTCAClient()
+	if err != nil {
+		return nil, err
+	}
 	defer sock.Close()
 
 	// Issue the request
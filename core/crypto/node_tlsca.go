@@ -143,7 +143,8 @@ func (node *nodeImpl) getTLSCertificateFromTLSCA(id, affiliation string) (interf
 		Pub: &membersrvc.PublicKey{
 			Type: membersrvc.CryptoType_ECDSA,
 			Key:  pubraw,
-		}, Sig: nil}
+		}, Sig: nil,
+		CryptoSuite: GetCryptoSuiteName()}
 	rawreq, _ := proto.Marshal(req)
 	r, s, err := ecdsa.Sign(rand.Reader, priv, primitives.Hash(rawreq))
 	if err != nil {
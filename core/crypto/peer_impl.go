@@ -17,7 +17,6 @@ limitations under the License.
 package crypto
 
 import (
-	"crypto/ecdsa"
 	"crypto/x509"
 	"fmt"
 	"sync"
@@ -89,7 +88,14 @@ func (peer *peerImpl) TransactionPreValidation(tx *obc.Transaction) (*obc.Transa
 			}
 		}
 
-		// 3. Marshall tx without signature
+		// 4. Check the certificate has not been revoked
+		if peer.isCertificateRevoked(x509Cert) {
+			peer.Warningf("Certificate [%v] has been revoked.", x509Cert.SerialNumber)
+
+			return tx, utils.ErrCertificateRevoked
+		}
+
+		// 5. Marshall tx without signature
 		signature := tx.Signature
 		tx.Signature = nil
 		rawTx, err := proto.Marshal(tx)
@@ -99,8 +105,8 @@ func (peer *peerImpl) TransactionPreValidation(tx *obc.Transaction) (*obc.Transa
 		}
 		tx.Signature = signature
 
-		// 2. Verify signature
-		ok, err := peer.verify(cert.PublicKey, rawTx, tx.Signature)
+		// 6. Verify signature
+		ok, err := peer.verifyWithCert(cert, rawTx, tx.Signature)
 		if err != nil {
 			peer.Errorf("TransactionPreExecution: failed marshaling tx [%s].", err.Error())
 			return tx, err
@@ -157,9 +163,13 @@ func (peer *peerImpl) Verify(vkID, signature, message []byte) error {
 		return err
 	}
 
-	vk := cert.PublicKey.(*ecdsa.PublicKey)
+	if peer.isCertificateRevoked(cert) {
+		peer.Errorf("Enrollment cert for [% x] has been revoked", vkID)
+
+		return utils.ErrCertificateRevoked
+	}
 
-	ok, err := peer.verify(vk, message, signature)
+	ok, err := peer.verifyWithCert(cert, message, signature)
 	if err != nil {
 		peer.Errorf("Failed verifying signature for [% x]: [%s]", vkID, err)
 
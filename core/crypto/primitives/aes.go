@@ -119,8 +119,10 @@ func CBCDecrypt(key, src []byte) ([]byte, error) {
 
 	mode := cipher.NewCBCDecrypter(block, iv)
 
-	// CryptBlocks can work in-place if the two arguments are the same.
-	mode.CryptBlocks(src, src)
+	// Decrypt into a new buffer, src may alias data owned by the caller
+	// such as the extensions of a parsed certificate.
+	pt := make([]byte, len(src))
+	mode.CryptBlocks(pt, src)
 
 	// If the original plaintext lengths are not a multiple of the block
 	// size, padding would have to be added when encrypting, which would be
@@ -130,7 +132,7 @@ func CBCDecrypt(key, src []byte) ([]byte, error) {
 	// using crypto/hmac) before being decrypted in order to avoid creating
 	// a padding oracle.
 
-	return src, nil
+	return pt, nil
 }
 
 // CBCPKCS7Encrypt combines CBC encryption and PKCS7 padding
@@ -55,6 +55,27 @@ func TestCBCPKCS7EncryptCBCPKCS7Decrypt(t *testing.T) {
 
 }
 
+// TestCBCPKCS7DecryptKeepsCiphertext verifies that decrypting does not overwrite the ciphertext,
+// which may be part of a certificate.
+func TestCBCPKCS7DecryptKeepsCiphertext(t *testing.T) {
+	key := make([]byte, primitives.AESKeyLength)
+	rand.Reader.Read(key)
+
+	encrypted, err := primitives.CBCPKCS7Encrypt(key, []byte("a message with arbitrary length (42 bytes)"))
+	if err != nil {
+		t.Fatalf("Error encrypting: %s", err)
+	}
+	ctext := append([]byte(nil), encrypted...)
+
+	if _, err = primitives.CBCPKCS7Decrypt(key, encrypted); err != nil {
+		t.Fatalf("Error decrypting: %s", err)
+	}
+
+	if !bytes.Equal(ctext, encrypted) {
+		t.Fatal("Decrypt must not modify the ciphertext")
+	}
+}
+
 // TestPKCS7Padding verifies the PKCS#7 padding, using a human readable plaintext.
 func TestPKCS7Padding(t *testing.T) {
 
@@ -20,6 +20,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/rand"
 	"encoding/asn1"
+	"hash"
 	"math/big"
 )
 
@@ -69,6 +70,12 @@ func ECDSASign(signKey interface{}, msg []byte) ([]byte, error) {
 
 // ECDSAVerify verifies
 func ECDSAVerify(verKey interface{}, msg, signature []byte) (bool, error) {
+	return ECDSAVerifyWithHash(GetDefaultHash(), verKey, msg, signature)
+}
+
+// ECDSAVerifyWithHash verifies a signature computed over the given hash of msg, as the ones
+// of entities using another crypto suite
+func ECDSAVerifyWithHash(newHash func() hash.Hash, verKey interface{}, msg, signature []byte) (bool, error) {
 	ecdsaSignature := new(ECDSASignature)
 	_, err := asn1.Unmarshal(signature, ecdsaSignature)
 	if err != nil {
@@ -80,8 +87,9 @@ func ECDSAVerify(verKey interface{}, msg, signature []byte) (bool, error) {
 	//	fmt.Printf("r [%s], s [%s]\n", R, S)
 
 	temp := verKey.(*ecdsa.PublicKey)
-	h := Hash(msg)
-	return ecdsa.Verify(temp, h, ecdsaSignature.R, ecdsaSignature.S), nil
+	h := newHash()
+	h.Write(msg)
+	return ecdsa.Verify(temp, h.Sum(nil), ecdsaSignature.R, ecdsaSignature.S), nil
 }
 
 // VerifySignCapability tests signing capabilities
@@ -17,65 +17,21 @@ limitations under the License.
 package primitives
 
 import (
-	"crypto/elliptic"
-	"crypto/sha256"
-	"crypto/sha512"
-	"fmt"
 	"sync"
-
-	"golang.org/x/crypto/sha3"
 )
 
 var (
 	initOnce sync.Once
 )
 
-// Init SHA2
-func initSHA2(level int) (err error) {
-	switch level {
-	case 256:
-		defaultCurve = elliptic.P256()
-		defaultHash = sha256.New
-	case 384:
-		defaultCurve = elliptic.P384()
-		defaultHash = sha512.New384
-	default:
-		err = fmt.Errorf("Security level not supported [%d]", level)
-	}
-	return
-}
-
-// Init SHA3
-func initSHA3(level int) (err error) {
-	switch level {
-	case 256:
-		defaultCurve = elliptic.P256()
-		defaultHash = sha3.New256
-	case 384:
-		defaultCurve = elliptic.P384()
-		defaultHash = sha3.New384
-	default:
-		err = fmt.Errorf("Security level not supported [%d]", level)
-	}
-	return
-}
-
 // SetSecurityLevel sets the security configuration with the hash length and the algorithm
 func SetSecurityLevel(algorithm string, level int) (err error) {
-	switch algorithm {
-	case "SHA2":
-		err = initSHA2(level)
-	case "SHA3":
-		err = initSHA3(level)
-	default:
-		err = fmt.Errorf("Algorithm not supported [%s]", algorithm)
-	}
-	if err == nil {
-		// TODO: what's this
-		defaultHashAlgorithm = algorithm
-		//hashLength = level
+	suite, err := GetCryptoSuiteByLevel(algorithm, level)
+	if err != nil {
+		return err
 	}
-	return
+	setCryptoSuite(suite)
+	return nil
 }
 
 // InitSecurityLevel initialize the crypto layer at the given security level
@@ -85,3 +41,11 @@ func InitSecurityLevel(algorithm string, level int) (err error) {
 	})
 	return
 }
+
+// InitCryptoSuite initialize the crypto layer with the named suite
+func InitCryptoSuite(name string) (err error) {
+	initOnce.Do(func() {
+		err = SetCryptoSuite(name)
+	})
+	return
+}
@@ -0,0 +1,165 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package primitives
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"hash"
+	"sort"
+
+	"golang.org/x/crypto/sha3"
+)
+
+var (
+	// CryptoSuiteOID is the ASN1 object identifier of the extension naming the crypto suite
+	// of the subject of a certificate. It is out of the arc of the TCert attributes.
+	CryptoSuiteOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 7, 1}
+
+	defaultSuite *CryptoSuite
+)
+
+// CryptoSuite names the algorithms used together by an entity: the curve of its keys, the
+// hash of its signatures and key derivations, and the symmetric cipher.
+type CryptoSuite struct {
+	// Name identifies the suite in the configuration and in certificates
+	Name string
+
+	// SecurityLevel is the size in bits of the curve and of the hash
+	SecurityLevel int
+
+	Curve elliptic.Curve
+
+	// HashFamily is SHA2 or SHA3
+	HashFamily string
+	Hash       func() hash.Hash
+
+	// Cipher is the symmetric cipher
+	Cipher string
+
+	// KDF derives the keys of ECIES and of TCerts
+	KDF string
+}
+
+func newCryptoSuite(curve elliptic.Curve, family string, h func() hash.Hash) *CryptoSuite {
+	level := curve.Params().BitSize
+	return &CryptoSuite{
+		Name:          fmt.Sprintf("P%d-%s", level, family),
+		SecurityLevel: level,
+		Curve:         curve,
+		HashFamily:    family,
+		Hash:          h,
+		Cipher:        fmt.Sprintf("AES-%d", AESKeyLength*8),
+		KDF:           fmt.Sprintf("HKDF-%s-%d", family, level),
+	}
+}
+
+var cryptoSuites = map[string]*CryptoSuite{}
+
+func init() {
+	for _, suite := range []*CryptoSuite{
+		newCryptoSuite(elliptic.P256(), "SHA2", sha256.New),
+		newCryptoSuite(elliptic.P384(), "SHA2", sha512.New384),
+		newCryptoSuite(elliptic.P256(), "SHA3", sha3.New256),
+		newCryptoSuite(elliptic.P384(), "SHA3", sha3.New384),
+	} {
+		cryptoSuites[suite.Name] = suite
+	}
+}
+
+// GetCryptoSuite returns the suite with the given name
+func GetCryptoSuite(name string) (*CryptoSuite, error) {
+	suite, ok := cryptoSuites[name]
+	if !ok {
+		return nil, fmt.Errorf("Crypto suite not supported [%s]", name)
+	}
+	return suite, nil
+}
+
+// GetCryptoSuiteByLevel returns the suite using the given hash family at the given security level
+func GetCryptoSuiteByLevel(algorithm string, level int) (*CryptoSuite, error) {
+	if algorithm != "SHA2" && algorithm != "SHA3" {
+		return nil, fmt.Errorf("Algorithm not supported [%s]", algorithm)
+	}
+	suite, ok := cryptoSuites[fmt.Sprintf("P%d-%s", level, algorithm)]
+	if !ok {
+		return nil, fmt.Errorf("Security level not supported [%d]", level)
+	}
+	return suite, nil
+}
+
+// GetCryptoSuiteNames returns the names of the supported suites, sorted
+func GetCryptoSuiteNames() []string {
+	var names []string
+	for name := range cryptoSuites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetDefaultCryptoSuite returns the suite the crypto layer works with
+func GetDefaultCryptoSuite() *CryptoSuite {
+	return defaultSuite
+}
+
+// SetCryptoSuite sets the suite the crypto layer works with
+func SetCryptoSuite(name string) error {
+	suite, err := GetCryptoSuite(name)
+	if err != nil {
+		return err
+	}
+	setCryptoSuite(suite)
+	return nil
+}
+
+func setCryptoSuite(suite *CryptoSuite) {
+	defaultSuite = suite
+	defaultCurve = suite.Curve
+	defaultHash = suite.Hash
+	defaultHashAlgorithm = suite.HashFamily
+}
+
+// NewCryptoSuiteExtension returns the certificate extension advertising the suite
+func NewCryptoSuiteExtension(suite *CryptoSuite) (pkix.Extension, error) {
+	value, err := asn1.Marshal(suite.Name)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: CryptoSuiteOID, Critical: false, Value: value}, nil
+}
+
+// GetCertificateCryptoSuite returns the suite advertised by the certificate, nil if the
+// certificate does not advertise one
+func GetCertificateCryptoSuite(cert *x509.Certificate) (*CryptoSuite, error) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(CryptoSuiteOID) {
+			continue
+		}
+		var name string
+		if _, err := asn1.Unmarshal(ext.Value, &name); err != nil {
+			return nil, fmt.Errorf("Failed unmarshalling crypto suite [%s]", err)
+		}
+		return GetCryptoSuite(name)
+	}
+	return nil, nil
+}
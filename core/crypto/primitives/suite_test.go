@@ -0,0 +1,220 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package primitives_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/crypto/primitives/ecies"
+	"golang.org/x/crypto/sha3"
+)
+
+// newSuiteCert returns a self signed certificate advertising the crypto suite
+func newSuiteCert(t *testing.T, suite *primitives.CryptoSuite) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := primitives.NewECDSAKey()
+	if err != nil {
+		t.Fatalf("Failed generating ECDSA key [%s]", err)
+	}
+	ext, err := primitives.NewCryptoSuiteExtension(suite)
+	if err != nil {
+		t.Fatalf("Failed creating crypto suite extension [%s]", err)
+	}
+	tmpl := x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: suite.Name},
+		NotBefore:       time.Now().Add(-1 * time.Hour),
+		NotAfter:        time.Now().Add(1 * time.Hour),
+		ExtraExtensions: []pkix.Extension{ext},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed creating certificate [%s]", err)
+	}
+	cert, err := primitives.DERToX509Certificate(der)
+	if err != nil {
+		t.Fatalf("Failed parsing certificate [%s]", err)
+	}
+	return cert, key
+}
+
+// TestCryptoSuites signs, encrypts and issues certificates under every supported suite
+func TestCryptoSuites(t *testing.T) {
+	defaultSuite := primitives.GetDefaultCryptoSuite()
+	defer primitives.SetCryptoSuite(defaultSuite.Name)
+
+	names := primitives.GetCryptoSuiteNames()
+	if len(names) != 4 {
+		t.Fatalf("Expected 4 crypto suites, got %v", names)
+	}
+
+	msg := []byte("Hello World")
+	for _, name := range names {
+		if err := primitives.SetCryptoSuite(name); err != nil {
+			t.Fatalf("Failed setting crypto suite [%s]: [%s]", name, err)
+		}
+		suite := primitives.GetDefaultCryptoSuite()
+		if suite.Name != name {
+			t.Fatalf("Crypto suite [%s] set instead of [%s]", suite.Name, name)
+		}
+		if primitives.GetDefaultCurve().Params().BitSize != suite.SecurityLevel {
+			t.Fatalf("Invalid curve for [%s]", name)
+		}
+		if primitives.NewHash().Size()*8 != suite.SecurityLevel {
+			t.Fatalf("Invalid hash for [%s]", name)
+		}
+		if primitives.GetHashAlgorithm() != suite.HashFamily {
+			t.Fatalf("Invalid hash family for [%s]", name)
+		}
+
+		// Signatures
+		cert, key := newSuiteCert(t, suite)
+		sigma, err := primitives.ECDSASign(key, msg)
+		if err != nil {
+			t.Fatalf("Failed signing under [%s]: [%s]", name, err)
+		}
+		certSuite, err := primitives.GetCertificateCryptoSuite(cert)
+		if err != nil {
+			t.Fatalf("Failed getting crypto suite of certificate [%s]", err)
+		}
+		if certSuite != suite {
+			t.Fatalf("Certificate advertises [%v] instead of [%s]", certSuite, name)
+		}
+		ok, err := primitives.ECDSAVerifyWithHash(certSuite.Hash, cert.PublicKey, msg, sigma)
+		if err != nil || !ok {
+			t.Fatalf("Failed verifying under [%s]: [%t][%v]", name, ok, err)
+		}
+		for _, other := range names {
+			otherSuite, _ := primitives.GetCryptoSuite(other)
+			if otherSuite.SecurityLevel != suite.SecurityLevel || otherSuite.HashFamily == suite.HashFamily {
+				continue
+			}
+			if ok, _ := primitives.ECDSAVerifyWithHash(otherSuite.Hash, cert.PublicKey, msg, sigma); ok {
+				t.Fatalf("Signature under [%s] verified under [%s]", name, other)
+			}
+		}
+
+		// Encryption
+		spi := ecies.NewSPI()
+		sk, err := spi.NewPrivateKey(nil, key)
+		if err != nil {
+			t.Fatalf("Failed creating ECIES key [%s]", err)
+		}
+		cipher, err := spi.NewAsymmetricCipherFromPublicKey(sk.GetPublicKey())
+		if err != nil {
+			t.Fatalf("Failed creating ECIES cipher [%s]", err)
+		}
+		ct, err := cipher.Process(msg)
+		if err != nil {
+			t.Fatalf("Failed encrypting under [%s]: [%s]", name, err)
+		}
+		cipher, err = spi.NewAsymmetricCipherFromPrivateKey(sk)
+		if err != nil {
+			t.Fatalf("Failed creating ECIES cipher [%s]", err)
+		}
+		pt, err := cipher.Process(ct)
+		if err != nil {
+			t.Fatalf("Failed decrypting under [%s]: [%s]", name, err)
+		}
+		if !bytes.Equal(msg, pt) {
+			t.Fatalf("Decrypted [%s] instead of [%s] under [%s]", pt, msg, name)
+		}
+	}
+}
+
+func TestCryptoSuiteP384SHA3(t *testing.T) {
+	defaultSuite := primitives.GetDefaultCryptoSuite()
+	defer primitives.SetCryptoSuite(defaultSuite.Name)
+
+	if err := primitives.SetCryptoSuite("P384-SHA3"); err != nil {
+		t.Fatalf("Failed setting crypto suite [%s]", err)
+	}
+	suite := primitives.GetDefaultCryptoSuite()
+	if suite.Curve != elliptic.P384() || suite.Cipher != "AES-256" || suite.KDF != "HKDF-SHA3-384" {
+		t.Fatalf("Invalid crypto suite [%+v]", suite)
+	}
+
+	msg := []byte("Hello World")
+	expected := sha3.Sum384(msg)
+	if !bytes.Equal(primitives.Hash(msg), expected[:]) {
+		t.Fatal("Hash is not SHA3-384")
+	}
+
+	key, err := primitives.NewECDSAKey()
+	if err != nil {
+		t.Fatalf("Failed generating ECDSA key [%s]", err)
+	}
+	if key.Curve != elliptic.P384() {
+		t.Fatal("Key is not on P-384")
+	}
+
+	// Round trip through PEM
+	raw, err := primitives.PrivateKeyToPEM(key, nil)
+	if err != nil {
+		t.Fatalf("Failed marshalling key [%s]", err)
+	}
+	decoded, err := primitives.PEMtoPrivateKey(raw, nil)
+	if err != nil {
+		t.Fatalf("Failed unmarshalling key [%s]", err)
+	}
+	sigma, err := primitives.ECDSASign(decoded, msg)
+	if err != nil {
+		t.Fatalf("Failed signing [%s]", err)
+	}
+	if ok, err := primitives.ECDSAVerify(&key.PublicKey, msg, sigma); err != nil || !ok {
+		t.Fatalf("Failed verifying [%t][%v]", ok, err)
+	}
+}
+
+func TestCryptoSuiteErrors(t *testing.T) {
+	if _, err := primitives.GetCryptoSuite("P521-SHA3"); err == nil {
+		t.Fatal("Getting an unsupported crypto suite should fail")
+	}
+	if err := primitives.SetCryptoSuite("P521-SHA3"); err == nil {
+		t.Fatal("Setting an unsupported crypto suite should fail")
+	}
+	if _, err := primitives.GetCryptoSuiteByLevel("SHA3", 521); err == nil {
+		t.Fatal("Getting an unsupported security level should fail")
+	}
+
+	// Certificates without the extension do not advertise a suite
+	der, _, err := primitives.NewSelfSignedCert()
+	if err != nil {
+		t.Fatalf("Failed generating self signed cert [%s]", err)
+	}
+	cert, err := primitives.DERToX509Certificate(der)
+	if err != nil {
+		t.Fatalf("Failed parsing certificate [%s]", err)
+	}
+	if suite, err := primitives.GetCertificateCryptoSuite(cert); suite != nil || err != nil {
+		t.Fatalf("Expected no crypto suite, got [%v][%v]", suite, err)
+	}
+
+	// Certificates advertising an unknown suite are rejected
+	cert.Extensions = append(cert.Extensions, pkix.Extension{Id: primitives.CryptoSuiteOID, Value: []byte{0x13, 0x01, 0x58}})
+	if _, err := primitives.GetCertificateCryptoSuite(cert); err == nil {
+		t.Fatal("Getting an unknown crypto suite from a certificate should fail")
+	}
+}
@@ -43,6 +43,12 @@ var (
 	// ErrTransactionSignature Missing Transaction Signature
 	ErrTransactionSignature = errors.New("Missing Transaction Signature.")
 
+	// ErrCertificateRevoked Certificate has been revoked
+	ErrCertificateRevoked = errors.New("Certificate has been revoked.")
+
+	// ErrCryptoSuiteNotAllowed Crypto suite not allowed by the network
+	ErrCryptoSuiteNotAllowed = errors.New("Crypto suite not allowed.")
+
 	// ErrInvalidSignature Invalid Signature
 	ErrInvalidSignature = errors.New("Invalid Signature.")
 
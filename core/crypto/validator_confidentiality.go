@@ -72,13 +72,7 @@ func (validator *validatorImpl) deepCloneAndDecryptTx1_2(tx *obc.Transaction) (*
 	validator.Debug("Extract transaction key...")
 
 	// Derive transaction key
-	cipher, err := validator.eciesSPI.NewAsymmetricCipherFromPrivateKey(validator.chainPrivateKey)
-	if err != nil {
-		validator.Errorf("Failed init decryption engine [%s].", err.Error())
-		return nil, err
-	}
-
-	msgToValidatorsRaw, err := cipher.Process(tx.ToValidators)
+	msgToValidatorsRaw, err := validator.provider.Decrypt(validator.chainKey, tx.ToValidators)
 	if err != nil {
 		validator.Errorf("Failed decrypting message to validators [% x]: [%s].", tx.ToValidators, err.Error())
 		return nil, err
@@ -100,7 +94,7 @@ func (validator *validatorImpl) deepCloneAndDecryptTx1_2(tx *obc.Transaction) (*
 
 	validator.Debug("Extract transaction key...done")
 
-	cipher, err = validator.eciesSPI.NewAsymmetricCipherFromPrivateKey(ccPrivateKey)
+	cipher, err := validator.eciesSPI.NewAsymmetricCipherFromPrivateKey(ccPrivateKey)
 	if err != nil {
 		validator.Errorf("Failed init transaction decryption engine [%s].", err.Error())
 		return nil, err
@@ -17,11 +17,8 @@ limitations under the License.
 package crypto
 
 import (
-	"crypto/ecdsa"
-
 	"fmt"
 
-	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/hyperledger/fabric/core/crypto/utils"
 	obc "github.com/hyperledger/fabric/protos"
 )
@@ -31,8 +28,8 @@ import (
 type validatorImpl struct {
 	*peerImpl
 
-	// Chain
-	chainPrivateKey primitives.PrivateKey
+	// Chain key, held by the crypto provider
+	chainKey KeyHandle
 }
 
 // TransactionPreValidation verifies that the transaction is
@@ -107,9 +104,13 @@ func (validator *validatorImpl) Verify(vkID, signature, message []byte) error {
 		return err
 	}
 
-	vk := cert.PublicKey.(*ecdsa.PublicKey)
+	if validator.isCertificateRevoked(cert) {
+		validator.Errorf("Enrollment cert for [% x] has been revoked", vkID)
+
+		return utils.ErrCertificateRevoked
+	}
 
-	ok, err := validator.verify(vk, message, signature)
+	ok, err := validator.verifyWithCert(cert, message, signature)
 	if err != nil {
 		validator.Errorf("Failed verifying signature for [% x]: [%s]", vkID, err)
 
@@ -158,13 +159,12 @@ func (validator *validatorImpl) init(name string, pwd []byte, regFunc registerFu
 }
 
 func (validator *validatorImpl) initCryptoEngine() (err error) {
-	// Init chain publicKey
-	validator.chainPrivateKey, err = validator.eciesSPI.NewPrivateKey(
-		nil, validator.enrollChainKey.(*ecdsa.PrivateKey),
-	)
-	if err != nil {
-		return
+	// Init chain key
+	chainKey, ok := validator.enrollChainKey.(KeyHandle)
+	if !ok {
+		return utils.ErrInvalidKey
 	}
+	validator.chainKey = chainKey
 
 	return
 }
@@ -111,13 +111,7 @@ func (validator *validatorImpl) getStateEncryptor1_2(deployTx, executeTx *obc.Tr
 }
 
 func (validator *validatorImpl) getStateKeyFromTransaction(tx *obc.Transaction) ([]byte, error) {
-	cipher, err := validator.eciesSPI.NewAsymmetricCipherFromPrivateKey(validator.chainPrivateKey)
-	if err != nil {
-		validator.Errorf("Failed init decryption engine [%s].", err.Error())
-		return nil, err
-	}
-
-	msgToValidatorsRaw, err := cipher.Process(tx.ToValidators)
+	msgToValidatorsRaw, err := validator.provider.Decrypt(validator.chainKey, tx.ToValidators)
 	if err != nil {
 		validator.Errorf("Failed decrypting message to validators [% x]: [%s].", tx.ToValidators, err.Error())
 		return nil, err
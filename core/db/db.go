@@ -26,16 +26,17 @@ import (
 
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
-	"github.com/tecbot/gorocksdb"
 )
 
 var dbLogger = logging.MustGetLogger("db")
 
+const defaultCF = "default"
 const blockchainCF = "blockchainCF"
 const stateCF = "stateCF"
 const stateDeltaCF = "stateDeltaCF"
 const indexesCF = "indexesCF"
 const persistCF = "persistCF"
+const historyCF = "historyCF"
 
 var columnfamilies = []string{
 	blockchainCF, // blocks of the block chain
@@ -43,6 +44,7 @@ var columnfamilies = []string{
 	stateDeltaCF, // open transaction state
 	indexesCF,    // tx uuid -> blockno
 	persistCF,    // persistent per-peer state (consensus)
+	historyCF,    // chaincode key -> modifications per block
 }
 
 type dbState int32
@@ -52,14 +54,115 @@ const (
 	opened
 )
 
-// OpenchainDB encapsulates rocksdb's structures
+// ColumnFamilyHandle identifies a column family, a separate key space of the DB
+type ColumnFamilyHandle struct {
+	name string
+}
+
+// Name returns the name of the column family
+func (cf *ColumnFamilyHandle) Name() string {
+	return cf.name
+}
+
+// Iterator iterates over the key-values of a column family in lexical order
+// of the keys. The slices returned by Key and Value are only valid until the
+// iterator is moved, so they must be copied if they are retained. Remember to
+// call Close() when you are done.
+type Iterator interface {
+	Valid() bool
+	ValidForPrefix(prefix []byte) bool
+	Key() []byte
+	Value() []byte
+	Next()
+	Prev()
+	SeekToFirst()
+	SeekToLast()
+	Seek(key []byte)
+	Close()
+}
+
+// Snapshot is a point-in-time, read-only view of the DB. You MUST call
+// Release() when you are done with the snapshot.
+type Snapshot interface {
+	Release()
+	get(cfName string, key []byte) ([]byte, error)
+	newIterator(cfName string) Iterator
+}
+
+// SnapshotContent selects what can be read from a snapshot: the keys Keys of
+// the column family CF, or all of its keys if Keys is nil
+type SnapshotContent struct {
+	CF   *ColumnFamilyHandle
+	Keys [][]byte
+}
+
+// WriteBatch holds a list of updates that are written to the DB atomically
+// by OpenchainDB.Write
+type WriteBatch struct {
+	updates []*batchUpdate
+}
+
+type batchUpdate struct {
+	cfName string
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+// NewWriteBatch constructs an empty WriteBatch
+func NewWriteBatch() *WriteBatch {
+	return &WriteBatch{}
+}
+
+// Put adds to the batch a put of the key-value in the default column family
+func (writeBatch *WriteBatch) Put(key []byte, value []byte) {
+	writeBatch.updates = append(writeBatch.updates, &batchUpdate{defaultCF, makeCopy(key), makeCopy(value), false})
+}
+
+// PutCF adds to the batch a put of the key-value in the given column family
+func (writeBatch *WriteBatch) PutCF(cf *ColumnFamilyHandle, key []byte, value []byte) {
+	writeBatch.updates = append(writeBatch.updates, &batchUpdate{cf.name, makeCopy(key), makeCopy(value), false})
+}
+
+// DeleteCF adds to the batch a delete of the key in the given column family
+func (writeBatch *WriteBatch) DeleteCF(cf *ColumnFamilyHandle, key []byte) {
+	writeBatch.updates = append(writeBatch.updates, &batchUpdate{cf.name, makeCopy(key), nil, true})
+}
+
+// Count returns the number of updates in the batch
+func (writeBatch *WriteBatch) Count() int {
+	return len(writeBatch.updates)
+}
+
+// Destroy releases the updates held by the batch
+func (writeBatch *WriteBatch) Destroy() {
+	writeBatch.updates = nil
+}
+
+// dbImpl is implemented by the storage engines that can back the OpenchainDB.
+// Column families are addressed by their names.
+type dbImpl interface {
+	open(dbPath string, createIfMissing bool, cfNames []string) error
+	close()
+	get(cfName string, key []byte) ([]byte, error)
+	put(cfName string, key []byte, value []byte) error
+	delete(cfName string, key []byte) error
+	write(writeBatch *WriteBatch) error
+	newIterator(cfName string) Iterator
+	newSnapshot(contents []SnapshotContent) (Snapshot, error)
+	recreateColumnFamily(cfName string) error
+	getProperty(cfName string, property string) string
+}
+
+// OpenchainDB encapsulates the storage engine selected by 'peer.db.type'
 type OpenchainDB struct {
-	DB           *gorocksdb.DB
-	BlockchainCF *gorocksdb.ColumnFamilyHandle
-	StateCF      *gorocksdb.ColumnFamilyHandle
-	StateDeltaCF *gorocksdb.ColumnFamilyHandle
-	IndexesCF    *gorocksdb.ColumnFamilyHandle
-	PersistCF    *gorocksdb.ColumnFamilyHandle
+	BlockchainCF *ColumnFamilyHandle
+	StateCF      *ColumnFamilyHandle
+	StateDeltaCF *ColumnFamilyHandle
+	IndexesCF    *ColumnFamilyHandle
+	PersistCF    *ColumnFamilyHandle
+	HistoryCF    *ColumnFamilyHandle
+	dbImpl       dbImpl
 	dbState      dbState
 	mux          sync.Mutex
 }
@@ -68,7 +171,15 @@ var openchainDB = Create()
 
 // Create create an openchainDB instance
 func Create() *OpenchainDB {
-	return &OpenchainDB{dbState: closed}
+	return &OpenchainDB{
+		BlockchainCF: &ColumnFamilyHandle{blockchainCF},
+		StateCF:      &ColumnFamilyHandle{stateCF},
+		StateDeltaCF: &ColumnFamilyHandle{stateDeltaCF},
+		IndexesCF:    &ColumnFamilyHandle{indexesCF},
+		PersistCF:    &ColumnFamilyHandle{persistCF},
+		HistoryCF:    &ColumnFamilyHandle{historyCF},
+		dbState:      closed,
+	}
 }
 
 // GetDBHandle get an opened openchainDB singleton
@@ -83,7 +194,7 @@ func (openchainDB *OpenchainDB) GetFromBlockchainCF(key []byte) ([]byte, error)
 }
 
 // GetFromBlockchainCFSnapshot get value for given key from column family in a DB snapshot - blockchainCF
-func (openchainDB *OpenchainDB) GetFromBlockchainCFSnapshot(snapshot *gorocksdb.Snapshot, key []byte) ([]byte, error) {
+func (openchainDB *OpenchainDB) GetFromBlockchainCFSnapshot(snapshot Snapshot, key []byte) ([]byte, error) {
 	return openchainDB.getFromSnapshot(snapshot, openchainDB.BlockchainCF, key)
 }
 
@@ -102,32 +213,43 @@ func (openchainDB *OpenchainDB) GetFromIndexesCF(key []byte) ([]byte, error) {
 	return openchainDB.Get(openchainDB.IndexesCF, key)
 }
 
+// GetFromHistoryCF get value for given key from column family - historyCF
+func (openchainDB *OpenchainDB) GetFromHistoryCF(key []byte) ([]byte, error) {
+	return openchainDB.Get(openchainDB.HistoryCF, key)
+}
+
 // GetBlockchainCFIterator get iterator for column family - blockchainCF
-func (openchainDB *OpenchainDB) GetBlockchainCFIterator() *gorocksdb.Iterator {
+func (openchainDB *OpenchainDB) GetBlockchainCFIterator() Iterator {
 	return openchainDB.GetIterator(openchainDB.BlockchainCF)
 }
 
 // GetStateCFIterator get iterator for column family - stateCF
-func (openchainDB *OpenchainDB) GetStateCFIterator() *gorocksdb.Iterator {
+func (openchainDB *OpenchainDB) GetStateCFIterator() Iterator {
 	return openchainDB.GetIterator(openchainDB.StateCF)
 }
 
 // GetStateCFSnapshotIterator get iterator for column family - stateCF. This iterator
 // is based on a snapshot and should be used for long running scans, such as
 // reading the entire state. Remember to call iterator.Close() when you are done.
-func (openchainDB *OpenchainDB) GetStateCFSnapshotIterator(snapshot *gorocksdb.Snapshot) *gorocksdb.Iterator {
+func (openchainDB *OpenchainDB) GetStateCFSnapshotIterator(snapshot Snapshot) Iterator {
 	return openchainDB.getSnapshotIterator(snapshot, openchainDB.StateCF)
 }
 
 // GetStateDeltaCFIterator get iterator for column family - stateDeltaCF
-func (openchainDB *OpenchainDB) GetStateDeltaCFIterator() *gorocksdb.Iterator {
+func (openchainDB *OpenchainDB) GetStateDeltaCFIterator() Iterator {
 	return openchainDB.GetIterator(openchainDB.StateDeltaCF)
 }
 
-// GetSnapshot returns a point-in-time view of the DB. You MUST call snapshot.Release()
-// when you are done with the snapshot.
-func (openchainDB *OpenchainDB) GetSnapshot() *gorocksdb.Snapshot {
-	return openchainDB.DB.NewSnapshot()
+// GetHistoryCFIterator get iterator for column family - historyCF
+func (openchainDB *OpenchainDB) GetHistoryCFIterator() Iterator {
+	return openchainDB.GetIterator(openchainDB.HistoryCF)
+}
+
+// GetSnapshot returns a point-in-time view of the DB from which only the given contents can
+// be read. Depending on the storage engine, the contents may be copied into the snapshot.
+// You MUST call snapshot.Release() when you are done with the snapshot.
+func (openchainDB *OpenchainDB) GetSnapshot(contents ...SnapshotContent) (Snapshot, error) {
+	return openchainDB.dbImpl.newSnapshot(contents)
 }
 
 func getDBPath() string {
@@ -141,7 +263,23 @@ func getDBPath() string {
 	return dbPath + "db"
 }
 
-// Open open underlying rocksdb
+func newDBImpl() dbImpl {
+	dbType := viper.GetString("peer.db.type")
+	if dbType == "" {
+		dbType = defaultDBType
+	}
+	dbLogger.Infof("Initializing DB implementation [%s]", dbType)
+	switch dbType {
+	case "rocksdb":
+		return newRocksDBImpl()
+	case "boltdb":
+		return newBoltDBImpl()
+	default:
+		panic(fmt.Sprintf("Unknown DB type [%s]. Please check that property 'peer.db.type' is set to 'rocksdb' or 'boltdb'", dbType))
+	}
+}
+
+// Open open underlying DB
 func (openchainDB *OpenchainDB) Open() {
 	openchainDB.mux.Lock()
 	if openchainDB.dbState == opened {
@@ -165,35 +303,19 @@ func (openchainDB *OpenchainDB) Open() {
 		}
 	}
 
-	opts := gorocksdb.NewDefaultOptions()
-	defer opts.Destroy()
-
-	opts.SetCreateIfMissing(missing)
-	opts.SetCreateIfMissingColumnFamilies(true)
-
-	cfNames := []string{"default"}
+	cfNames := []string{defaultCF}
 	cfNames = append(cfNames, columnfamilies...)
-	var cfOpts []*gorocksdb.Options
-	for range cfNames {
-		cfOpts = append(cfOpts, opts)
-	}
-
-	db, cfHandlers, err := gorocksdb.OpenDbColumnFamilies(opts, dbPath, cfNames, cfOpts)
-
+	dbImpl := newDBImpl()
+	err = dbImpl.open(dbPath, missing, cfNames)
 	if err != nil {
 		panic(fmt.Sprintf("Error opening DB: %s", err))
 	}
 
-	openchainDB.DB = db
-	openchainDB.BlockchainCF = cfHandlers[1]
-	openchainDB.StateCF = cfHandlers[2]
-	openchainDB.StateDeltaCF = cfHandlers[3]
-	openchainDB.IndexesCF = cfHandlers[4]
-	openchainDB.PersistCF = cfHandlers[5]
+	openchainDB.dbImpl = dbImpl
 	openchainDB.dbState = opened
 }
 
-// Close releases all column family handles and closes rocksdb
+// Close releases all column family handles and closes the underlying DB
 func (openchainDB *OpenchainDB) Close() {
 	openchainDB.mux.Lock()
 	if openchainDB.dbState == closed {
@@ -202,12 +324,8 @@ func (openchainDB *OpenchainDB) Close() {
 	}
 
 	defer openchainDB.mux.Unlock()
-	openchainDB.BlockchainCF.Destroy()
-	openchainDB.StateCF.Destroy()
-	openchainDB.StateDeltaCF.Destroy()
-	openchainDB.IndexesCF.Destroy()
-	openchainDB.PersistCF.Destroy()
-	openchainDB.DB.Close()
+	openchainDB.dbImpl.close()
+	openchainDB.dbImpl = nil
 	openchainDB.dbState = closed
 }
 
@@ -215,53 +333,32 @@ func (openchainDB *OpenchainDB) Close() {
 // only used during state synchronization when creating a new state from
 // a snapshot.
 func (openchainDB *OpenchainDB) DeleteState() error {
-	err := openchainDB.DB.DropColumnFamily(openchainDB.StateCF)
-	if err != nil {
-		dbLogger.Errorf("Error dropping state CF: %s", err)
-		return err
-	}
-	err = openchainDB.DB.DropColumnFamily(openchainDB.StateDeltaCF)
+	err := openchainDB.dbImpl.recreateColumnFamily(stateCF)
 	if err != nil {
-		dbLogger.Errorf("Error dropping state delta CF: %s", err)
+		dbLogger.Errorf("Error recreating state CF: %s", err)
 		return err
 	}
-	opts := gorocksdb.NewDefaultOptions()
-	defer opts.Destroy()
-	openchainDB.StateCF, err = openchainDB.DB.CreateColumnFamily(opts, stateCF)
+	err = openchainDB.dbImpl.recreateColumnFamily(stateDeltaCF)
 	if err != nil {
-		dbLogger.Errorf("Error creating state CF: %s", err)
-		return err
-	}
-	openchainDB.StateDeltaCF, err = openchainDB.DB.CreateColumnFamily(opts, stateDeltaCF)
-	if err != nil {
-		dbLogger.Errorf("Error creating state delta CF: %s", err)
+		dbLogger.Errorf("Error recreating state delta CF: %s", err)
 		return err
 	}
 	return nil
 }
 
 // Get returns the valud for the given column family and key
-func (openchainDB *OpenchainDB) Get(cfHandler *gorocksdb.ColumnFamilyHandle, key []byte) ([]byte, error) {
-	opt := gorocksdb.NewDefaultReadOptions()
-	defer opt.Destroy()
-	slice, err := openchainDB.DB.GetCF(opt, cfHandler, key)
+func (openchainDB *OpenchainDB) Get(cfHandler *ColumnFamilyHandle, key []byte) ([]byte, error) {
+	value, err := openchainDB.dbImpl.get(cfHandler.name, key)
 	if err != nil {
 		fmt.Println("Error while trying to retrieve key:", key)
 		return nil, err
 	}
-	defer slice.Free()
-	if slice.Data() == nil {
-		return nil, nil
-	}
-	data := makeCopy(slice.Data())
-	return data, nil
+	return value, nil
 }
 
 // Put saves the key/value in the given column family
-func (openchainDB *OpenchainDB) Put(cfHandler *gorocksdb.ColumnFamilyHandle, key []byte, value []byte) error {
-	opt := gorocksdb.NewDefaultWriteOptions()
-	defer opt.Destroy()
-	err := openchainDB.DB.PutCF(opt, cfHandler, key, value)
+func (openchainDB *OpenchainDB) Put(cfHandler *ColumnFamilyHandle, key []byte, value []byte) error {
+	err := openchainDB.dbImpl.put(cfHandler.name, key, value)
 	if err != nil {
 		fmt.Println("Error while trying to write key:", key)
 		return err
@@ -270,10 +367,8 @@ func (openchainDB *OpenchainDB) Put(cfHandler *gorocksdb.ColumnFamilyHandle, key
 }
 
 // Delete delets the given key in the specified column family
-func (openchainDB *OpenchainDB) Delete(cfHandler *gorocksdb.ColumnFamilyHandle, key []byte) error {
-	opt := gorocksdb.NewDefaultWriteOptions()
-	defer opt.Destroy()
-	err := openchainDB.DB.DeleteCF(opt, cfHandler, key)
+func (openchainDB *OpenchainDB) Delete(cfHandler *ColumnFamilyHandle, key []byte) error {
+	err := openchainDB.dbImpl.delete(cfHandler.name, key)
 	if err != nil {
 		fmt.Println("Error while trying to delete key:", key)
 		return err
@@ -281,34 +376,39 @@ func (openchainDB *OpenchainDB) Delete(cfHandler *gorocksdb.ColumnFamilyHandle,
 	return nil
 }
 
-func (openchainDB *OpenchainDB) getFromSnapshot(snapshot *gorocksdb.Snapshot, cfHandler *gorocksdb.ColumnFamilyHandle, key []byte) ([]byte, error) {
-	opt := gorocksdb.NewDefaultReadOptions()
-	defer opt.Destroy()
-	opt.SetSnapshot(snapshot)
-	slice, err := openchainDB.DB.GetCF(opt, cfHandler, key)
+// Write applies all the updates of the batch atomically
+func (openchainDB *OpenchainDB) Write(writeBatch *WriteBatch) error {
+	return openchainDB.dbImpl.write(writeBatch)
+}
+
+func (openchainDB *OpenchainDB) getFromSnapshot(snapshot Snapshot, cfHandler *ColumnFamilyHandle, key []byte) ([]byte, error) {
+	value, err := snapshot.get(cfHandler.name, key)
 	if err != nil {
 		fmt.Println("Error while trying to retrieve key:", key)
 		return nil, err
 	}
-	defer slice.Free()
-	data := append([]byte(nil), slice.Data()...)
-	return data, nil
+	return value, nil
 }
 
 // GetIterator returns an iterator for the given column family
-func (openchainDB *OpenchainDB) GetIterator(cfHandler *gorocksdb.ColumnFamilyHandle) *gorocksdb.Iterator {
-	opt := gorocksdb.NewDefaultReadOptions()
-	opt.SetFillCache(true)
-	defer opt.Destroy()
-	return openchainDB.DB.NewIteratorCF(opt, cfHandler)
-}
-
-func (openchainDB *OpenchainDB) getSnapshotIterator(snapshot *gorocksdb.Snapshot, cfHandler *gorocksdb.ColumnFamilyHandle) *gorocksdb.Iterator {
-	opt := gorocksdb.NewDefaultReadOptions()
-	defer opt.Destroy()
-	opt.SetSnapshot(snapshot)
-	iter := openchainDB.DB.NewIteratorCF(opt, cfHandler)
-	return iter
+func (openchainDB *OpenchainDB) GetIterator(cfHandler *ColumnFamilyHandle) Iterator {
+	return openchainDB.dbImpl.newIterator(cfHandler.name)
+}
+
+func (openchainDB *OpenchainDB) getSnapshotIterator(snapshot Snapshot, cfHandler *ColumnFamilyHandle) Iterator {
+	return snapshot.newIterator(cfHandler.name)
+}
+
+// GetProperty returns a DB-wide property of the underlying storage engine,
+// such as "rocksdb.stats", or an empty string if the engine does not support it
+func (openchainDB *OpenchainDB) GetProperty(property string) string {
+	return openchainDB.dbImpl.getProperty("", property)
+}
+
+// GetPropertyCF returns a property of the given column family, or an empty
+// string if the underlying storage engine does not support it
+func (openchainDB *OpenchainDB) GetPropertyCF(property string, cfHandler *ColumnFamilyHandle) string {
+	return openchainDB.dbImpl.getProperty(cfHandler.name, property)
 }
 
 func dirMissingOrEmpty(path string) (bool, error) {
@@ -0,0 +1,453 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/spf13/viper"
+)
+
+const boltDBFileName = "openchain.db"
+const boltDBSnapshotsDir = "snapshots"
+const defaultBoltDBInitialMmapSize = 1024
+
+// boltDBIteratorBatchSize is the number of entries an iterator reads ahead
+// in each read transaction
+const boltDBIteratorBatchSize = 100
+
+// boltDBSnapshotBatchSize is the number of entries copied into a snapshot in
+// each write transaction
+const boltDBSnapshotBatchSize = 10000
+
+var boltDBKeyPrefix = []byte{0}
+
+func toBoltDBKey(key []byte) []byte {
+	return append(append([]byte{}, boltDBKeyPrefix...), key...)
+}
+
+func fromBoltDBKey(boltDBKey []byte) []byte {
+	if boltDBKey == nil {
+		return nil
+	}
+	return boltDBKey[len(boltDBKeyPrefix):]
+}
+
+// boltDBImpl stores each column family in a bucket of a single bolt file.
+// Bolt cannot grow its memory map while read transactions are open, so a
+// commit that needs a larger map waits for all of them to end. Snapshots and
+// iterators may be held for long, e.g. while the state is sent to a peer, so
+// they do not keep a read transaction open: a snapshot is a separate file with
+// a copy of the selected contents, taken in a single read transaction (e.g. the
+// state, without the blocks), and an iterator reads a batch of
+// entries ahead in each short read transaction. Iterators which are not on a
+// snapshot therefore see the writes committed while they are used.
+// 'peer.db.boltdb.initialMmapSize' should be large enough to hold the DB to
+// avoid remapping it.
+//
+// Bolt does not allow empty keys, which the ledger uses (e.g. the root of the
+// state trie), so every key is stored with a one byte prefix. This keeps the
+// order of the keys unchanged.
+type boltDBImpl struct {
+	db           *bolt.DB
+	cfNames      []string
+	snapshotsDir string
+}
+
+func newBoltDBImpl() dbImpl {
+	return &boltDBImpl{}
+}
+
+func (impl *boltDBImpl) open(dbPath string, createIfMissing bool, cfNames []string) error {
+	dbFile := filepath.Join(dbPath, boltDBFileName)
+	if createIfMissing {
+		if err := os.MkdirAll(dbPath, 0755); err != nil {
+			return err
+		}
+	} else if _, err := os.Stat(dbFile); err != nil {
+		return fmt.Errorf("Cannot open boltdb file [%s]: %s", dbFile, err)
+	}
+
+	initialMmapSize := viper.GetInt("peer.db.boltdb.initialMmapSize")
+	if initialMmapSize <= 0 {
+		initialMmapSize = defaultBoltDBInitialMmapSize
+	}
+	db, err := bolt.Open(dbFile, 0600, &bolt.Options{Timeout: time.Second, InitialMmapSize: initialMmapSize * 1024 * 1024})
+	if err != nil {
+		return err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, cfName := range cfNames {
+			if _, err := tx.CreateBucketIfNotExists([]byte(cfName)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	// Snapshots left over by a crash are useless
+	impl.snapshotsDir = filepath.Join(dbPath, boltDBSnapshotsDir)
+	if err := os.RemoveAll(impl.snapshotsDir); err != nil {
+		db.Close()
+		return err
+	}
+	impl.db = db
+	impl.cfNames = cfNames
+	return nil
+}
+
+func (impl *boltDBImpl) close() {
+	if err := impl.db.Close(); err != nil {
+		dbLogger.Errorf("Error closing boltdb: %s", err)
+	}
+}
+
+func (impl *boltDBImpl) get(cfName string, key []byte) ([]byte, error) {
+	var value []byte
+	err := impl.db.View(func(tx *bolt.Tx) error {
+		value = getFromBucket(tx, cfName, key)
+		return nil
+	})
+	return value, err
+}
+
+func (impl *boltDBImpl) put(cfName string, key []byte, value []byte) error {
+	return impl.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(cfName)).Put(toBoltDBKey(key), value)
+	})
+}
+
+func (impl *boltDBImpl) delete(cfName string, key []byte) error {
+	return impl.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(cfName)).Delete(toBoltDBKey(key))
+	})
+}
+
+func (impl *boltDBImpl) write(writeBatch *WriteBatch) error {
+	return impl.db.Update(func(tx *bolt.Tx) error {
+		for _, update := range writeBatch.updates {
+			bucket := tx.Bucket([]byte(update.cfName))
+			var err error
+			if update.delete {
+				err = bucket.Delete(toBoltDBKey(update.key))
+			} else {
+				err = bucket.Put(toBoltDBKey(update.key), update.value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (impl *boltDBImpl) newIterator(cfName string) Iterator {
+	return &boltDBIterator{db: impl.db, cfName: []byte(cfName)}
+}
+
+func (impl *boltDBImpl) newSnapshot(contents []SnapshotContent) (Snapshot, error) {
+	if err := os.MkdirAll(impl.snapshotsDir, 0755); err != nil {
+		return nil, fmt.Errorf("Error creating boltdb snapshots directory: %s", err)
+	}
+	file, err := ioutil.TempFile(impl.snapshotsDir, "snapshot")
+	if err != nil {
+		return nil, fmt.Errorf("Error creating boltdb snapshot file: %s", err)
+	}
+	path := file.Name()
+	file.Close()
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("Error opening boltdb snapshot: %s", err)
+	}
+	// The snapshot is removed on Release or when the DB is opened again
+	db.NoSync = true
+	snapshot := &boltDBSnapshot{db}
+	err = impl.db.View(func(tx *bolt.Tx) error {
+		return snapshot.copyFrom(tx, impl.cfNames, contents)
+	})
+	if err != nil {
+		snapshot.Release()
+		return nil, fmt.Errorf("Error copying boltdb snapshot: %s", err)
+	}
+	return snapshot, nil
+}
+
+func (impl *boltDBImpl) recreateColumnFamily(cfName string) error {
+	return impl.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket([]byte(cfName)); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket([]byte(cfName))
+		return err
+	})
+}
+
+// getProperty supports "boltdb.stats" for the DB and for each column family,
+// and "boltdb.num-keys" for each column family
+func (impl *boltDBImpl) getProperty(cfName string, property string) string {
+	if cfName == "" {
+		if property == "boltdb.stats" {
+			return fmt.Sprintf("%+v", impl.db.Stats())
+		}
+		return ""
+	}
+	var stats bolt.BucketStats
+	impl.db.View(func(tx *bolt.Tx) error {
+		stats = tx.Bucket([]byte(cfName)).Stats()
+		return nil
+	})
+	switch property {
+	case "boltdb.stats":
+		return fmt.Sprintf("%+v", stats)
+	case "boltdb.num-keys":
+		return fmt.Sprintf("%d", stats.KeyN)
+	default:
+		return ""
+	}
+}
+
+// getFromBucket returns a copy of the value, as bolt values are only valid
+// for the life of the transaction
+func getFromBucket(tx *bolt.Tx, cfName string, key []byte) []byte {
+	value := tx.Bucket([]byte(cfName)).Get(toBoltDBKey(key))
+	if value == nil {
+		return nil
+	}
+	return makeCopy(value)
+}
+
+// boltDBSnapshot is a file with a copy of part of the DB, removed on Release.
+// It has a bucket for every column family, those which are not part of the
+// snapshot are empty.
+type boltDBSnapshot struct {
+	db *bolt.DB
+}
+
+// copyFrom copies the contents from the read transaction tx of the DB
+func (snapshot *boltDBSnapshot) copyFrom(tx *bolt.Tx, cfNames []string, contents []SnapshotContent) error {
+	err := snapshot.db.Update(func(snapshotTx *bolt.Tx) error {
+		for _, cfName := range cfNames {
+			if _, err := snapshotTx.CreateBucket([]byte(cfName)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, content := range contents {
+		cfName := []byte(content.CF.name)
+		bucket := tx.Bucket(cfName)
+		if content.Keys != nil {
+			err = snapshot.db.Update(func(snapshotTx *bolt.Tx) error {
+				for _, key := range content.Keys {
+					boltDBKey := toBoltDBKey(key)
+					if value := bucket.Get(boltDBKey); value != nil {
+						if err := snapshotTx.Bucket(cfName).Put(boltDBKey, value); err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		// The keys and values of tx remain valid until the copy ends
+		cursor := bucket.Cursor()
+		key, value := cursor.First()
+		for key != nil {
+			err = snapshot.db.Update(func(snapshotTx *bolt.Tx) error {
+				snapshotBucket := snapshotTx.Bucket(cfName)
+				// The keys are appended in order
+				snapshotBucket.FillPercent = 1
+				for n := 0; key != nil && n < boltDBSnapshotBatchSize; n++ {
+					if err := snapshotBucket.Put(key, value); err != nil {
+						return err
+					}
+					key, value = cursor.Next()
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (snapshot *boltDBSnapshot) Release() {
+	path := snapshot.db.Path()
+	if err := snapshot.db.Close(); err != nil {
+		dbLogger.Errorf("Error closing boltdb snapshot: %s", err)
+	}
+	if err := os.Remove(path); err != nil {
+		dbLogger.Errorf("Error removing boltdb snapshot: %s", err)
+	}
+}
+
+func (snapshot *boltDBSnapshot) get(cfName string, key []byte) ([]byte, error) {
+	var value []byte
+	err := snapshot.db.View(func(tx *bolt.Tx) error {
+		value = getFromBucket(tx, cfName, key)
+		return nil
+	})
+	return value, err
+}
+
+func (snapshot *boltDBSnapshot) newIterator(cfName string) Iterator {
+	return &boltDBIterator{db: snapshot.db, cfName: []byte(cfName)}
+}
+
+// boltDBEntry is a key-value copied out of a read transaction, the key is
+// stored with its prefix
+type boltDBEntry struct {
+	key   []byte
+	value []byte
+}
+
+// boltDBIterator implements the Iterator interface without holding a read
+// transaction between calls. Each read transaction copies up to
+// boltDBIteratorBatchSize entries, from the position sought onwards in the
+// direction of the iteration. entries[0] is the current position.
+type boltDBIterator struct {
+	db      *bolt.DB
+	cfName  []byte
+	entries []boltDBEntry
+	reverse bool
+	more    bool // the bucket holds entries past the last one read
+}
+
+func (itr *boltDBIterator) Valid() bool {
+	return len(itr.entries) > 0
+}
+
+func (itr *boltDBIterator) ValidForPrefix(prefix []byte) bool {
+	return itr.Valid() && bytes.HasPrefix(itr.Key(), prefix)
+}
+
+func (itr *boltDBIterator) Key() []byte {
+	if !itr.Valid() {
+		return nil
+	}
+	return fromBoltDBKey(itr.entries[0].key)
+}
+
+func (itr *boltDBIterator) Value() []byte {
+	if !itr.Valid() {
+		return nil
+	}
+	return itr.entries[0].value
+}
+
+func (itr *boltDBIterator) Next() {
+	itr.move(false)
+}
+
+func (itr *boltDBIterator) Prev() {
+	itr.move(true)
+}
+
+func (itr *boltDBIterator) SeekToFirst() {
+	itr.read(false, func(cursor *bolt.Cursor) ([]byte, []byte) {
+		return cursor.First()
+	})
+}
+
+func (itr *boltDBIterator) SeekToLast() {
+	itr.read(true, func(cursor *bolt.Cursor) ([]byte, []byte) {
+		return cursor.Last()
+	})
+}
+
+func (itr *boltDBIterator) Seek(key []byte) {
+	itr.read(false, func(cursor *bolt.Cursor) ([]byte, []byte) {
+		return cursor.Seek(toBoltDBKey(key))
+	})
+}
+
+// move steps to the next entry in the given direction, reading the entries
+// following the current one if they have not been read ahead yet. The current
+// entry may have been deleted meanwhile, the cursor then lands past it.
+func (itr *boltDBIterator) move(reverse bool) {
+	if !itr.Valid() {
+		return
+	}
+	if itr.reverse == reverse && (len(itr.entries) > 1 || !itr.more) {
+		itr.entries = itr.entries[1:]
+		return
+	}
+	current := itr.entries[0].key
+	itr.read(reverse, func(cursor *bolt.Cursor) ([]byte, []byte) {
+		key, value := cursor.Seek(current)
+		switch {
+		case !reverse && bytes.Equal(key, current):
+			return cursor.Next()
+		case !reverse:
+			return key, value
+		case key == nil:
+			return cursor.Last()
+		default:
+			return cursor.Prev()
+		}
+	})
+}
+
+// read positions the iterator at the entry returned by seek, and reads ahead
+// from there in the given direction
+func (itr *boltDBIterator) read(reverse bool, seek func(*bolt.Cursor) ([]byte, []byte)) {
+	itr.entries, itr.reverse, itr.more = nil, reverse, false
+	err := itr.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(itr.cfName).Cursor()
+		key, value := seek(cursor)
+		for ; key != nil && len(itr.entries) < boltDBIteratorBatchSize; key, value = itr.step(cursor) {
+			itr.entries = append(itr.entries, boltDBEntry{makeCopy(key), makeCopy(value)})
+		}
+		itr.more = key != nil
+		return nil
+	})
+	if err != nil {
+		dbLogger.Errorf("Error reading boltdb bucket [%s]: %s", itr.cfName, err)
+	}
+}
+
+func (itr *boltDBIterator) step(cursor *bolt.Cursor) ([]byte, []byte) {
+	if itr.reverse {
+		return cursor.Prev()
+	}
+	return cursor.Next()
+}
+
+func (itr *boltDBIterator) Close() {
+	itr.entries = nil
+}
@@ -0,0 +1,28 @@
+// +build norocksdb
+
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+// defaultDBType is used when 'peer.db.type' is not set
+const defaultDBType = "boltdb"
+
+// newRocksDBImpl is not available when built with the 'norocksdb' tag, which
+// removes the dependency on cgo and the native rocksdb library
+func newRocksDBImpl() dbImpl {
+	panic("This peer was built with the 'norocksdb' tag and does not support 'peer.db.type' rocksdb")
+}
@@ -0,0 +1,217 @@
+// +build !norocksdb
+
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"github.com/tecbot/gorocksdb"
+)
+
+// defaultDBType is used when 'peer.db.type' is not set
+const defaultDBType = "rocksdb"
+
+// rocksDBImpl stores each column family in a rocksdb column family
+type rocksDBImpl struct {
+	db        *gorocksdb.DB
+	cfHandles map[string]*gorocksdb.ColumnFamilyHandle
+}
+
+func newRocksDBImpl() dbImpl {
+	return &rocksDBImpl{}
+}
+
+func (impl *rocksDBImpl) open(dbPath string, createIfMissing bool, cfNames []string) error {
+	opts := gorocksdb.NewDefaultOptions()
+	defer opts.Destroy()
+
+	opts.SetCreateIfMissing(createIfMissing)
+	opts.SetCreateIfMissingColumnFamilies(true)
+
+	var cfOpts []*gorocksdb.Options
+	for range cfNames {
+		cfOpts = append(cfOpts, opts)
+	}
+
+	db, cfHandlers, err := gorocksdb.OpenDbColumnFamilies(opts, dbPath, cfNames, cfOpts)
+	if err != nil {
+		return err
+	}
+
+	impl.db = db
+	impl.cfHandles = make(map[string]*gorocksdb.ColumnFamilyHandle)
+	for i, cfName := range cfNames {
+		impl.cfHandles[cfName] = cfHandlers[i]
+	}
+	return nil
+}
+
+func (impl *rocksDBImpl) close() {
+	for _, cfHandle := range impl.cfHandles {
+		cfHandle.Destroy()
+	}
+	impl.db.Close()
+}
+
+func (impl *rocksDBImpl) get(cfName string, key []byte) ([]byte, error) {
+	opt := gorocksdb.NewDefaultReadOptions()
+	defer opt.Destroy()
+	return impl.getWithOptions(opt, cfName, key)
+}
+
+func (impl *rocksDBImpl) getWithOptions(opt *gorocksdb.ReadOptions, cfName string, key []byte) ([]byte, error) {
+	slice, err := impl.db.GetCF(opt, impl.cfHandles[cfName], key)
+	if err != nil {
+		return nil, err
+	}
+	defer slice.Free()
+	if slice.Data() == nil {
+		return nil, nil
+	}
+	return makeCopy(slice.Data()), nil
+}
+
+func (impl *rocksDBImpl) put(cfName string, key []byte, value []byte) error {
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	return impl.db.PutCF(opt, impl.cfHandles[cfName], key, value)
+}
+
+func (impl *rocksDBImpl) delete(cfName string, key []byte) error {
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	return impl.db.DeleteCF(opt, impl.cfHandles[cfName], key)
+}
+
+func (impl *rocksDBImpl) write(writeBatch *WriteBatch) error {
+	rocksWriteBatch := gorocksdb.NewWriteBatch()
+	defer rocksWriteBatch.Destroy()
+	for _, update := range writeBatch.updates {
+		if update.delete {
+			rocksWriteBatch.DeleteCF(impl.cfHandles[update.cfName], update.key)
+		} else {
+			rocksWriteBatch.PutCF(impl.cfHandles[update.cfName], update.key, update.value)
+		}
+	}
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	return impl.db.Write(opt, rocksWriteBatch)
+}
+
+func (impl *rocksDBImpl) newIterator(cfName string) Iterator {
+	opt := gorocksdb.NewDefaultReadOptions()
+	opt.SetFillCache(true)
+	defer opt.Destroy()
+	return &rocksDBIterator{impl.db.NewIteratorCF(opt, impl.cfHandles[cfName])}
+}
+
+// newSnapshot ignores the contents, a rocksdb snapshot covers the whole DB
+// without copying it
+func (impl *rocksDBImpl) newSnapshot(contents []SnapshotContent) (Snapshot, error) {
+	return &rocksDBSnapshot{impl, impl.db.NewSnapshot()}, nil
+}
+
+func (impl *rocksDBImpl) recreateColumnFamily(cfName string) error {
+	err := impl.db.DropColumnFamily(impl.cfHandles[cfName])
+	if err != nil {
+		return err
+	}
+	opts := gorocksdb.NewDefaultOptions()
+	defer opts.Destroy()
+	cfHandle, err := impl.db.CreateColumnFamily(opts, cfName)
+	if err != nil {
+		return err
+	}
+	impl.cfHandles[cfName] = cfHandle
+	return nil
+}
+
+func (impl *rocksDBImpl) getProperty(cfName string, property string) string {
+	if cfName == "" {
+		return impl.db.GetProperty(property)
+	}
+	return impl.db.GetPropertyCF(property, impl.cfHandles[cfName])
+}
+
+type rocksDBSnapshot struct {
+	impl     *rocksDBImpl
+	snapshot *gorocksdb.Snapshot
+}
+
+func (snapshot *rocksDBSnapshot) Release() {
+	snapshot.snapshot.Release()
+}
+
+func (snapshot *rocksDBSnapshot) get(cfName string, key []byte) ([]byte, error) {
+	opt := gorocksdb.NewDefaultReadOptions()
+	defer opt.Destroy()
+	opt.SetSnapshot(snapshot.snapshot)
+	return snapshot.impl.getWithOptions(opt, cfName, key)
+}
+
+func (snapshot *rocksDBSnapshot) newIterator(cfName string) Iterator {
+	opt := gorocksdb.NewDefaultReadOptions()
+	defer opt.Destroy()
+	opt.SetSnapshot(snapshot.snapshot)
+	return &rocksDBIterator{snapshot.impl.db.NewIteratorCF(opt, snapshot.impl.cfHandles[cfName])}
+}
+
+// rocksDBIterator adapts a rocksdb iterator to the Iterator interface. The key
+// and value slices point into memory owned by rocksdb.
+type rocksDBIterator struct {
+	itr *gorocksdb.Iterator
+}
+
+func (itr *rocksDBIterator) Valid() bool {
+	return itr.itr.Valid()
+}
+
+func (itr *rocksDBIterator) ValidForPrefix(prefix []byte) bool {
+	return itr.itr.ValidForPrefix(prefix)
+}
+
+func (itr *rocksDBIterator) Key() []byte {
+	return itr.itr.Key().Data()
+}
+
+func (itr *rocksDBIterator) Value() []byte {
+	return itr.itr.Value().Data()
+}
+
+func (itr *rocksDBIterator) Next() {
+	itr.itr.Next()
+}
+
+func (itr *rocksDBIterator) Prev() {
+	itr.itr.Prev()
+}
+
+func (itr *rocksDBIterator) SeekToFirst() {
+	itr.itr.SeekToFirst()
+}
+
+func (itr *rocksDBIterator) SeekToLast() {
+	itr.itr.SeekToLast()
+}
+
+func (itr *rocksDBIterator) Seek(key []byte) {
+	itr.itr.Seek(key)
+}
+
+func (itr *rocksDBIterator) Close() {
+	itr.itr.Close()
+}
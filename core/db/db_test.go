@@ -18,12 +18,14 @@ package db
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
-	"github.com/tecbot/gorocksdb"
 )
 
 func TestMain(m *testing.M) {
@@ -145,7 +147,10 @@ func TestDBSnapshot(t *testing.T) {
 	openchainDB.Put(openchainDB.BlockchainCF, []byte("key2"), []byte("value2"))
 
 	// create a snapshot
-	snapshot := openchainDB.GetSnapshot()
+	snapshot, err := openchainDB.GetSnapshot(SnapshotContent{CF: openchainDB.BlockchainCF})
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
 
 	// add/delete/modify key-values
 	openchainDB.Delete(openchainDB.BlockchainCF, []byte("key1"))
@@ -169,7 +174,7 @@ func TestDBSnapshot(t *testing.T) {
 	// test key-values from snapshot
 	v1, _ = openchainDB.GetFromBlockchainCFSnapshot(snapshot, []byte("key1"))
 	v2, _ = openchainDB.GetFromBlockchainCFSnapshot(snapshot, []byte("key2"))
-	v3, err := openchainDB.GetFromBlockchainCFSnapshot(snapshot, []byte("key3"))
+	v3, err = openchainDB.GetFromBlockchainCFSnapshot(snapshot, []byte("key3"))
 	if err != nil {
 		t.Fatalf("Error: %s", err)
 	}
@@ -198,7 +203,10 @@ func TestDBIteratorAndSnapshotIterator(t *testing.T) {
 	openchainDB.Put(openchainDB.StateCF, []byte("key2"), []byte("value2"))
 
 	// create a snapshot
-	snapshot := openchainDB.GetSnapshot()
+	snapshot, err := openchainDB.GetSnapshot(SnapshotContent{CF: openchainDB.StateCF})
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
 
 	// add/delete/modify key-values
 	openchainDB.Delete(openchainDB.StateCF, []byte("key1"))
@@ -229,14 +237,226 @@ func TestDBIteratorAndSnapshotIterator(t *testing.T) {
 }
 
 // db helper functions
-func testIterator(t *testing.T, itr *gorocksdb.Iterator, expectedValues map[string][]byte) {
+func TestBoltDB(t *testing.T) {
+	originalSetting := viper.GetString("peer.db.type")
+	viper.Set("peer.db.type", "boltdb")
+	defer viper.Set("peer.db.type", originalSetting)
+
+	testDBWrapper := NewTestDBWrapper()
+	testDBWrapper.CleanDB(t)
+	openchainDB := GetDBHandle()
+	defer testDBWrapper.cleanup()
+
+	performBasicReadWrite(openchainDB, t)
+
+	// bolt does not allow empty keys, the ledger uses an empty key for the root of the state trie
+	openchainDB.Put(openchainDB.StateCF, []byte{}, []byte("rootValue"))
+	openchainDB.Put(openchainDB.StateCF, []byte("key1"), []byte("value1"))
+	snapshot, err := openchainDB.GetSnapshot(SnapshotContent{CF: openchainDB.StateCF})
+	if err != nil {
+		t.Fatalf("snapshot error = [%s]", err)
+	}
+	openchainDB.Delete(openchainDB.StateCF, []byte("key1"))
+
+	snapshotItr := openchainDB.GetStateCFSnapshotIterator(snapshot)
+	testIterator(t, snapshotItr, map[string][]byte{
+		"dummyKey1": []byte("dummyValue1"), "": []byte("rootValue"), "key1": []byte("value1")})
+	snapshotItr.Close()
+	itr := openchainDB.GetStateCFIterator()
+	testIterator(t, itr, map[string][]byte{
+		"dummyKey1": []byte("dummyValue1"), "": []byte("rootValue")})
+	itr.Close()
+
+	// data is retained across a close and open
+	snapshot.Release()
+	openchainDB.Close()
+	openchainDB.Open()
+	value, err := openchainDB.GetFromStateCF([]byte{})
+	if err != nil {
+		t.Fatalf("read error = [%s]", err)
+	}
+	if !bytes.Equal(value, []byte("rootValue")) {
+		t.Fatalf("read error. Bytes not equal. Expected [%s], found [%s]", "rootValue", value)
+	}
+}
+
+func TestBoltDBIteratorDuringWrite(t *testing.T) {
+	originalSetting := viper.GetString("peer.db.type")
+	viper.Set("peer.db.type", "boltdb")
+	defer viper.Set("peer.db.type", originalSetting)
+	originalMmapSize := viper.GetInt("peer.db.boltdb.initialMmapSize")
+	viper.Set("peer.db.boltdb.initialMmapSize", 1)
+	defer viper.Set("peer.db.boltdb.initialMmapSize", originalMmapSize)
+
+	testDBWrapper := NewTestDBWrapper()
+	testDBWrapper.CleanDB(t)
+	openchainDB := GetDBHandle()
+	defer testDBWrapper.cleanup()
+
+	numKeys := 3*boltDBIteratorBatchSize + 1
+	writeBatch := NewWriteBatch()
+	for i := 0; i < numKeys; i++ {
+		writeBatch.PutCF(openchainDB.StateCF, []byte(fmt.Sprintf("key%04d", i)), []byte(fmt.Sprintf("value%04d", i)))
+	}
+	if err := openchainDB.Write(writeBatch); err != nil {
+		t.Fatalf("write error = [%s]", err)
+	}
+
+	snapshot, err := openchainDB.GetSnapshot(SnapshotContent{CF: openchainDB.StateCF})
+	if err != nil {
+		t.Fatalf("snapshot error = [%s]", err)
+	}
+	defer snapshot.Release()
+	snapshotItr := openchainDB.GetStateCFSnapshotIterator(snapshot)
+	defer snapshotItr.Close()
+	snapshotItr.SeekToFirst()
+	itr := openchainDB.GetStateCFIterator()
+	defer itr.Close()
+	itr.Seek([]byte("key0150"))
+
+	// The write grows the DB past its memory map, it must not wait for the
+	// snapshot and the iterator
+	done := make(chan error, 1)
+	go func() {
+		writeBatch := NewWriteBatch()
+		writeBatch.DeleteCF(openchainDB.StateCF, []byte("key0151"))
+		writeBatch.PutCF(openchainDB.StateCF, []byte("key0150"), []byte("updated"))
+		writeBatch.PutCF(openchainDB.StateCF, []byte("large"), make([]byte, 2*1024*1024))
+		done <- openchainDB.Write(writeBatch)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("write error = [%s]", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Write blocked by an open snapshot or iterator")
+	}
+
+	// The snapshot does not see the write
+	for i := 0; i < numKeys; i++ {
+		expected := fmt.Sprintf("key%04d", i)
+		if !snapshotItr.Valid() || string(snapshotItr.Key()) != expected {
+			t.Fatalf("Expected snapshot iterator at [%s], found [%s]", expected, snapshotItr.Key())
+		}
+		snapshotItr.Next()
+	}
+	if snapshotItr.Valid() {
+		t.Fatalf("Expected snapshot iterator to be exhausted, found [%s]", snapshotItr.Key())
+	}
+
+	// The iterator reads ahead from where it stands, it sees the write past
+	// its read ahead
+	if string(itr.Value()) != "value0150" {
+		t.Fatalf("Expected the value read ahead, found [%s]", itr.Value())
+	}
+	for i := 0; i < boltDBIteratorBatchSize; i++ {
+		itr.Next()
+	}
+	if string(itr.Key()) != "key0250" {
+		t.Fatalf("Expected iterator at [key0250], found [%s]", itr.Key())
+	}
+	// key0151 has been deleted
+	for i := 0; i < boltDBIteratorBatchSize-1; i++ {
+		itr.Prev()
+	}
+	if string(itr.Key()) != "key0150" || string(itr.Value()) != "updated" {
+		t.Fatalf("Expected iterator at [key0150] with the updated value, found [%s] [%s]", itr.Key(), itr.Value())
+	}
+	itr.SeekToLast()
+	if string(itr.Key()) != "large" {
+		t.Fatalf("Expected iterator at [large], found [%s]", itr.Key())
+	}
+}
+
+func TestBoltDBSnapshotContents(t *testing.T) {
+	originalSetting := viper.GetString("peer.db.type")
+	viper.Set("peer.db.type", "boltdb")
+	defer viper.Set("peer.db.type", originalSetting)
+
+	testDBWrapper := NewTestDBWrapper()
+	testDBWrapper.CleanDB(t)
+	openchainDB := GetDBHandle()
+	defer testDBWrapper.cleanup()
+
+	numKeys := boltDBSnapshotBatchSize + 1
+	writeBatch := NewWriteBatch()
+	for i := 0; i < numKeys; i++ {
+		writeBatch.PutCF(openchainDB.StateCF, []byte(fmt.Sprintf("key%05d", i)), []byte(fmt.Sprintf("value%05d", i)))
+	}
+	writeBatch.PutCF(openchainDB.BlockchainCF, []byte("size"), []byte("1"))
+	writeBatch.PutCF(openchainDB.BlockchainCF, []byte("block"), []byte("block0"))
+	writeBatch.PutCF(openchainDB.IndexesCF, []byte("index"), []byte("index0"))
+	if err := openchainDB.Write(writeBatch); err != nil {
+		t.Fatalf("write error = [%s]", err)
+	}
+
+	snapshot, err := openchainDB.GetSnapshot(
+		SnapshotContent{CF: openchainDB.StateCF},
+		SnapshotContent{CF: openchainDB.BlockchainCF, Keys: [][]byte{[]byte("size"), []byte("missing")}})
+	if err != nil {
+		t.Fatalf("snapshot error = [%s]", err)
+	}
+	defer snapshot.Release()
+
+	snapshotItr := openchainDB.GetStateCFSnapshotIterator(snapshot)
+	n := 0
+	for snapshotItr.SeekToFirst(); snapshotItr.Valid(); snapshotItr.Next() {
+		if expected := fmt.Sprintf("key%05d", n); string(snapshotItr.Key()) != expected {
+			t.Fatalf("Expected snapshot iterator at [%s], found [%s]", expected, snapshotItr.Key())
+		}
+		n++
+	}
+	snapshotItr.Close()
+	if n != numKeys {
+		t.Fatalf("Expected [%d] keys in the snapshot, found [%d]", numKeys, n)
+	}
+
+	// Only the selected keys of the other column families are copied
+	expected := map[string]string{"size": "1", "block": "", "missing": ""}
+	for key, value := range expected {
+		v, err := openchainDB.GetFromBlockchainCFSnapshot(snapshot, []byte(key))
+		if err != nil {
+			t.Fatalf("read error = [%s]", err)
+		}
+		if string(v) != value {
+			t.Fatalf("Expected [%s] for [%s] in the snapshot, found [%s]", value, key, v)
+		}
+	}
+	v, err := openchainDB.getFromSnapshot(snapshot, openchainDB.IndexesCF, []byte("index"))
+	if err != nil || v != nil {
+		t.Fatalf("Expected no value for a column family out of the snapshot, found [%s] [%v]", v, err)
+	}
+}
+
+func TestBoltDBSnapshotError(t *testing.T) {
+	originalSetting := viper.GetString("peer.db.type")
+	viper.Set("peer.db.type", "boltdb")
+	defer viper.Set("peer.db.type", originalSetting)
+
+	testDBWrapper := NewTestDBWrapper()
+	testDBWrapper.CleanDB(t)
+	openchainDB := GetDBHandle()
+	defer testDBWrapper.cleanup()
+
+	// The snapshots cannot be written where a file stands in the way
+	snapshotsDir := filepath.Join(getDBPath(), boltDBSnapshotsDir)
+	if err := ioutil.WriteFile(snapshotsDir, nil, 0600); err != nil {
+		t.Fatalf("write error = [%s]", err)
+	}
+	defer os.Remove(snapshotsDir)
+
+	if _, err := openchainDB.GetSnapshot(SnapshotContent{CF: openchainDB.StateCF}); err == nil {
+		t.Fatal("Expected an error taking a snapshot")
+	}
+}
+
+func testIterator(t *testing.T, itr Iterator, expectedValues map[string][]byte) {
 	itrResults := make(map[string][]byte)
 	itr.SeekToFirst()
 	for ; itr.Valid(); itr.Next() {
-		key := itr.Key()
-		value := itr.Value()
-		k := makeCopy(key.Data())
-		v := makeCopy(value.Data())
+		k := makeCopy(itr.Key())
+		v := makeCopy(itr.Value())
 		itrResults[string(k)] = v
 	}
 	if len(itrResults) != len(expectedValues) {
@@ -269,15 +489,13 @@ func setupTestConfig() {
 }
 
 func performBasicReadWrite(openchainDB *OpenchainDB, t *testing.T) {
-	opt := gorocksdb.NewDefaultWriteOptions()
-	defer opt.Destroy()
-	writeBatch := gorocksdb.NewWriteBatch()
+	writeBatch := NewWriteBatch()
 	defer writeBatch.Destroy()
 	writeBatch.PutCF(openchainDB.BlockchainCF, []byte("dummyKey"), []byte("dummyValue"))
 	writeBatch.PutCF(openchainDB.StateCF, []byte("dummyKey1"), []byte("dummyValue1"))
 	writeBatch.PutCF(openchainDB.StateDeltaCF, []byte("dummyKey2"), []byte("dummyValue2"))
 	writeBatch.PutCF(openchainDB.IndexesCF, []byte("dummyKey3"), []byte("dummyValue3"))
-	err := openchainDB.DB.Write(opt, writeBatch)
+	err := openchainDB.Write(writeBatch)
 	if err != nil {
 		t.Fatalf("Error while writing to db: %s", err)
 	}
@@ -34,6 +34,7 @@ import (
 	"github.com/hyperledger/fabric/core/chaincode/platforms"
 	"github.com/hyperledger/fabric/core/container"
 	crypto "github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/core/peer"
 	"github.com/hyperledger/fabric/core/util"
 	pb "github.com/hyperledger/fabric/protos"
@@ -203,6 +204,199 @@ func (d *Devops) Deploy(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.Chainc
 	return chaincodeDeploymentSpec, err
 }
 
+// Upgrade replaces the code of the chaincode named in spec with the code at the spec's path through
+// an upgrade transaction. The chaincode keeps its name and state.
+func (d *Devops) Upgrade(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.ChaincodeDeploymentSpec, error) {
+	if spec.ChaincodeID == nil || spec.ChaincodeID.Name == "" {
+		return nil, fmt.Errorf("name not given for upgrade")
+	}
+	name := spec.ChaincodeID.Name
+
+	// get the deployment spec for the new code
+	chaincodeDeploymentSpec, err := d.getChaincodeBytes(ctx, spec)
+
+	if err != nil {
+		devopsLogger.Error(fmt.Sprintf("Error upgrading chaincode spec: %v\n\n error: %s", spec, err))
+		return nil, err
+	}
+
+	// Packaging the code renamed the chaincode after the hash of the new code. Like a deploy
+	// transaction, the upgrade transaction takes that name, while the chaincode keeps its own.
+	transID := chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name
+	if viper.GetString("chaincode.mode") == chaincode.DevModeUserRunsChaincode {
+		// no code is packaged in development mode
+		transID = util.GenerateUUID()
+	}
+	chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name = name
+
+	var tx *pb.Transaction
+	var sec crypto.Client
+
+	if peer.SecurityEnabled() {
+		if devopsLogger.IsEnabledFor(logging.DEBUG) {
+			devopsLogger.Debugf("Initializing secure devops using context %s", spec.SecureContext)
+		}
+		sec, err = crypto.InitClient(spec.SecureContext, nil)
+		defer crypto.CloseClient(sec)
+
+		// remove the security context since we are no longer need it down stream
+		spec.SecureContext = ""
+
+		if nil != err {
+			return nil, err
+		}
+
+		if devopsLogger.IsEnabledFor(logging.DEBUG) {
+			devopsLogger.Debugf("Creating secure upgrade transaction %s", transID)
+		}
+		var txHandler crypto.TransactionHandler
+		txHandler, err = getLifecycleTransactionHandler(sec, name, spec.Attributes...)
+		if nil != err {
+			return nil, err
+		}
+		tx, err = txHandler.NewChaincodeUpgradeTransaction(chaincodeDeploymentSpec, transID, spec.Attributes...)
+		if nil != err {
+			return nil, err
+		}
+	} else {
+		if devopsLogger.IsEnabledFor(logging.DEBUG) {
+			devopsLogger.Debugf("Creating upgrade transaction (%s)", transID)
+		}
+		tx, err = pb.NewChaincodeUpgradeTransaction(chaincodeDeploymentSpec, transID)
+		if err != nil {
+			return nil, fmt.Errorf("Error upgrading chaincode: %s ", err)
+		}
+	}
+
+	if devopsLogger.IsEnabledFor(logging.DEBUG) {
+		devopsLogger.Debugf("Sending upgrade transaction (%s) to validator", tx.Uuid)
+	}
+	resp := d.coord.ExecuteTransaction(tx)
+	if resp.Status == pb.Response_FAILURE {
+		err = errors.New(string(resp.Msg))
+	}
+
+	return chaincodeDeploymentSpec, err
+}
+
+// getLifecycleTransactionHandler returns the transaction handler of a new TCert to upgrade or
+// terminate a chaincode. Only the user who deployed the chaincode can do either.
+func getLifecycleTransactionHandler(sec crypto.Client, chaincodeName string, attributes ...string) (crypto.TransactionHandler, error) {
+	if _, err := chaincode.GetDeploymentTransaction(chaincodeName); err != nil {
+		return nil, err
+	}
+
+	certHandler, err := sec.GetTCertificateHandlerNext(attributes...)
+	if err != nil {
+		return nil, err
+	}
+	return certHandler.GetTransactionHandler()
+}
+
+// Terminate terminates the specified chaincode through a transaction
+func (d *Devops) Terminate(ctx context.Context, chaincodeInvocationSpec *pb.ChaincodeInvocationSpec) (*pb.Response, error) {
+	spec := chaincodeInvocationSpec.ChaincodeSpec
+	if spec == nil || spec.ChaincodeID == nil || spec.ChaincodeID.Name == "" {
+		return nil, fmt.Errorf("name not given for terminate")
+	}
+
+	id := util.GenerateUUID()
+	devopsLogger.Infof("Transaction ID: %v", id)
+
+	var tx *pb.Transaction
+	var err error
+	var sec crypto.Client
+
+	if peer.SecurityEnabled() {
+		if devopsLogger.IsEnabledFor(logging.DEBUG) {
+			devopsLogger.Debugf("Initializing secure devops using context %s", spec.SecureContext)
+		}
+		sec, err = crypto.InitClient(spec.SecureContext, nil)
+		defer crypto.CloseClient(sec)
+
+		// remove the security context since we are no longer need it down stream
+		spec.SecureContext = ""
+
+		if nil != err {
+			return nil, err
+		}
+
+		if devopsLogger.IsEnabledFor(logging.DEBUG) {
+			devopsLogger.Debugf("Creating secure terminate transaction %s", id)
+		}
+		var txHandler crypto.TransactionHandler
+		txHandler, err = getLifecycleTransactionHandler(sec, spec.ChaincodeID.Name, spec.Attributes...)
+		if nil != err {
+			return nil, err
+		}
+		tx, err = txHandler.NewChaincodeTerminateTransaction(chaincodeInvocationSpec, id, spec.Attributes...)
+		if nil != err {
+			return nil, err
+		}
+	} else {
+		if devopsLogger.IsEnabledFor(logging.DEBUG) {
+			devopsLogger.Debugf("Creating terminate transaction (%s)", id)
+		}
+		tx, err = pb.NewChaincodeExecute(chaincodeInvocationSpec, id, pb.Transaction_CHAINCODE_TERMINATE)
+		if err != nil {
+			return nil, fmt.Errorf("Error terminating chaincode: %s ", err)
+		}
+	}
+
+	if devopsLogger.IsEnabledFor(logging.DEBUG) {
+		devopsLogger.Debugf("Sending terminate transaction (%s) to validator", tx.Uuid)
+	}
+	resp := d.coord.ExecuteTransaction(tx)
+	if resp.Status == pb.Response_FAILURE {
+		err = errors.New(string(resp.Msg))
+	}
+
+	return resp, err
+}
+
+// GetTransactionStatus returns the status of the transaction, CONFIRMED once
+// enough validators report the same block for it
+func (d *Devops) GetTransactionStatus(ctx context.Context, txUUID *pb.TransactionUUID) (*pb.TransactionStatus, error) {
+	status, err := d.coord.GetConfirmedTransactionStatus(txUUID.Uuid)
+	if err != nil {
+		return nil, fmt.Errorf("Error retrieving transaction status: %s", err)
+	}
+	return status, nil
+}
+
+// CheckDeterminism executes the transaction of the ledger twice against the
+// current state, without changing it, and reports how the executions differ
+// from each other and from the execution recorded in the ledger
+func (d *Devops) CheckDeterminism(ctx context.Context, txUUID *pb.TransactionUUID) (*pb.DeterminismReport, error) {
+	lgr, err := ledger.GetLedger()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting ledger: %s", err)
+	}
+	tx, err := lgr.GetTransactionByUUID(txUUID.Uuid)
+	if err != nil {
+		return nil, fmt.Errorf("Error retrieving transaction %s: %s", txUUID.Uuid, err)
+	}
+	txResult, err := lgr.GetTransactionResult(txUUID.Uuid)
+	if err != nil {
+		return nil, fmt.Errorf("Error retrieving the result of transaction %s: %s", txUUID.Uuid, err)
+	}
+	chain := chaincode.GetChain(chaincode.DefaultChain)
+	if chain == nil {
+		return nil, fmt.Errorf("Chaincodes are not run by this peer")
+	}
+	report, err := chaincode.CheckDeterminism(ctx, chain, tx, txResult)
+	if err != nil {
+		return nil, fmt.Errorf("Error executing transaction %s: %s", txUUID.Uuid, err)
+	}
+	if len(report.StateChanges) > 0 {
+		devopsLogger.Infof("The state read by transaction %s changed since it was executed: %v", txUUID.Uuid, report.StateChanges)
+	}
+	if !report.Deterministic {
+		devopsLogger.Warningf("Transaction %s is not deterministic: %v", txUUID.Uuid, append(report.Differences, report.OriginalDifferences...))
+	}
+	return report, nil
+}
+
 func (d *Devops) invokeOrQuery(ctx context.Context, chaincodeInvocationSpec *pb.ChaincodeInvocationSpec, attributes []string, invoke bool) (*pb.Response, error) {
 
 	if chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name == "" {
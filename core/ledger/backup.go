@@ -0,0 +1,422 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/protos"
+)
+
+// A backup archive is a gzip stream of the following records
+//   header: magic, version, block number, state hash of the block
+//   one record per block, from block 0 up to the block number
+//   one record per state key-value as of the block number
+//   end: record type followed by the sha256 of everything before the checksum
+// Integers are uvarints and byte arrays are prefixed with their length.
+
+const backupMagic = "hyperledger-fabric-ledger-backup"
+const backupVersion = 1
+
+// state key-values are restored in chunks of this size
+const restoreStateChunkSize = 1000
+
+// records larger than this are considered a sign of a corrupted archive
+const maxBackupRecordSize = 1024 * 1024 * 1024
+
+const (
+	backupRecordBlock = iota + 1
+	backupRecordState
+	backupRecordEnd
+)
+
+// Backup writes to w an archive of the blocks from 0 up to and including
+// blockNumber and of the state as of blockNumber. If blockNumber is lower than
+// the last block, the state is rolled back using the state deltas of the
+// following blocks, which are only kept for the last 'ledger.state.deltaHistorySize'
+// blocks. The archive can be restored into an empty ledger with Restore.
+func (ledger *Ledger) Backup(w io.Writer, blockNumber uint64) error {
+	snapshot, err := ledger.GetStateSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snapshot.Release()
+
+	if blockNumber > snapshot.GetBlockNumber() {
+		return ErrOutOfBounds
+	}
+	rollbackDelta, err := ledger.getRollbackDelta(blockNumber, snapshot.GetBlockNumber())
+	if err != nil {
+		return err
+	}
+	lastBlock, err := ledger.GetBlockByNumber(blockNumber)
+	if err != nil {
+		return err
+	}
+
+	gzipWriter := gzip.NewWriter(w)
+	writer := &backupWriter{w: gzipWriter, checksum: sha256.New()}
+	writer.writeBytes([]byte(backupMagic))
+	writer.writeUvarint(backupVersion)
+	writer.writeUvarint(blockNumber)
+	writer.writeBytes(lastBlock.StateHash)
+
+	for i := uint64(0); i <= blockNumber; i++ {
+		block, err := ledger.GetBlockByNumber(i)
+		if err != nil {
+			return err
+		}
+		blockBytes, err := block.Bytes()
+		if err != nil {
+			return err
+		}
+		writer.writeUvarint(backupRecordBlock)
+		writer.writeBytes(blockBytes)
+	}
+
+	for snapshot.Next() {
+		compositeKey, value := snapshot.GetRawKeyValue()
+		chaincodeID, key := statemgmt.DecodeCompositeKey(compositeKey)
+		if rollbackDelta.IsUpdatedValueSet(chaincodeID, key) {
+			continue
+		}
+		writer.writeUvarint(backupRecordState)
+		writer.writeBytes(compositeKey)
+		writer.writeBytes(value)
+	}
+	for _, chaincodeID := range rollbackDelta.GetUpdatedChaincodeIds(true) {
+		updates := rollbackDelta.GetUpdates(chaincodeID)
+		var keys []string
+		for key := range updates {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if updates[key].IsDelete() {
+				continue
+			}
+			writer.writeUvarint(backupRecordState)
+			writer.writeBytes(statemgmt.ConstructCompositeKey(chaincodeID, key))
+			writer.writeBytes(updates[key].GetValue())
+		}
+	}
+
+	writer.writeUvarint(backupRecordEnd)
+	writer.writeRaw(writer.checksum.Sum(nil))
+	if writer.err != nil {
+		return writer.err
+	}
+	ledgerLogger.Infof("Backed up [%d] blocks and the state as of block [%d]", blockNumber+1, blockNumber)
+	return gzipWriter.Close()
+}
+
+// getRollbackDelta returns the changes that roll the state back from block
+// toBlockNumber to block blockNumber
+func (ledger *Ledger) getRollbackDelta(blockNumber uint64, toBlockNumber uint64) (*statemgmt.StateDelta, error) {
+	rollbackDelta := statemgmt.NewStateDelta()
+	for i := blockNumber + 1; i <= toBlockNumber; i++ {
+		delta, err := ledger.GetStateDelta(i)
+		if err != nil {
+			return nil, err
+		}
+		if delta == nil {
+			return nil, fmt.Errorf("The state delta for block [%d] is no longer available, cannot determine the state as of block [%d]", i, blockNumber)
+		}
+		for _, chaincodeID := range delta.GetUpdatedChaincodeIds(false) {
+			for key, updatedValue := range delta.GetUpdates(chaincodeID) {
+				// the oldest change of a key holds its value as of blockNumber
+				if rollbackDelta.IsUpdatedValueSet(chaincodeID, key) {
+					continue
+				}
+				if previousValue := updatedValue.GetPreviousValue(); previousValue != nil {
+					rollbackDelta.Set(chaincodeID, key, previousValue, nil)
+				} else {
+					rollbackDelta.Delete(chaincodeID, key, nil)
+				}
+			}
+		}
+	}
+	return rollbackDelta, nil
+}
+
+// Restore reads an archive written by Backup and puts its blocks and state
+// into the ledger, which must not have any blocks. The archive is spooled to
+// a temporary file and read in full, checksum included, before anything is put
+// into the ledger, so that a corrupted or truncated archive leaves the ledger
+// empty. The restored chain is checked with VerifyChain and the state hash
+// against the one recorded in the archive. State deltas and key history are not
+// part of the archive and are therefore not available for the restored blocks.
+// Restore returns the number of the last restored block.
+func (ledger *Ledger) Restore(r io.Reader) (uint64, error) {
+	if ledger.GetBlockchainSize() != 0 {
+		return 0, fmt.Errorf("Cannot restore into a ledger that already has [%d] blocks", ledger.GetBlockchainSize())
+	}
+
+	spool, err := ioutil.TempFile("", "ledger-restore")
+	if err != nil {
+		return 0, fmt.Errorf("Error creating the file to spool the backup archive: %s", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	if _, err = readBackup(io.TeeReader(r, spool), nil, nil); err != nil {
+		return 0, err
+	}
+	if _, err = spool.Seek(0, 0); err != nil {
+		return 0, err
+	}
+
+	delta := statemgmt.NewStateDelta()
+	numKeys := 0
+	putBlock := func(blockNumber uint64, block *protos.Block) error {
+		return ledger.PutRawBlock(block, blockNumber)
+	}
+	putState := func(compositeKey []byte, value []byte) error {
+		chaincodeID, key := statemgmt.DecodeCompositeKey(compositeKey)
+		delta.Set(chaincodeID, key, value, nil)
+		numKeys++
+		if numKeys%restoreStateChunkSize != 0 {
+			return nil
+		}
+		if err := ledger.commitRestoredState(delta); err != nil {
+			return err
+		}
+		delta = statemgmt.NewStateDelta()
+		return nil
+	}
+	archive, err := readBackup(spool, putBlock, putState)
+	if err != nil {
+		return 0, err
+	}
+	if !delta.IsEmpty() {
+		if err = ledger.commitRestoredState(delta); err != nil {
+			return 0, err
+		}
+	}
+
+	lowestValidBlock, err := ledger.VerifyChain(archive.blockNumber, 0)
+	if err != nil {
+		return 0, err
+	}
+	if lowestValidBlock != 0 {
+		return 0, fmt.Errorf("Restored chain failed verification at block [%d]", lowestValidBlock)
+	}
+	restoredStateHash, err := ledger.GetTempStateHash()
+	if err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(restoredStateHash, archive.stateHash) {
+		return 0, fmt.Errorf("Restored state hash [%x] does not match the state hash [%x] of block [%d]", restoredStateHash, archive.stateHash, archive.blockNumber)
+	}
+	ledgerLogger.Infof("Restored [%d] blocks and [%d] state keys", archive.blockNumber+1, archive.numKeys)
+	return archive.blockNumber, nil
+}
+
+// backupArchive describes an archive read by readBackup
+type backupArchive struct {
+	blockNumber uint64
+	stateHash   []byte
+	numKeys     int
+}
+
+// readBackup reads an archive written by Backup, passing its blocks to
+// putBlock and its state key-values to putState unless they are nil. The
+// archive is checked as it is read; the checksum can only be checked at the
+// end, after everything has been passed on.
+func readBackup(r io.Reader, putBlock func(uint64, *protos.Block) error, putState func([]byte, []byte) error) (*backupArchive, error) {
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading backup archive: %s", err)
+	}
+	reader := &backupReader{bufio.NewReader(gzipReader), sha256.New()}
+
+	magic, err := reader.readBytes()
+	if err != nil || string(magic) != backupMagic {
+		return nil, fmt.Errorf("Not a ledger backup archive")
+	}
+	version, err := reader.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if version != backupVersion {
+		return nil, fmt.Errorf("Unsupported backup archive version [%d]", version)
+	}
+	archive := &backupArchive{}
+	if archive.blockNumber, err = reader.readUvarint(); err != nil {
+		return nil, err
+	}
+	if archive.stateHash, err = reader.readBytes(); err != nil {
+		return nil, err
+	}
+
+	var numBlocks uint64
+	var lastBlock *protos.Block
+	for done := false; !done; {
+		recordType, err := reader.readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		switch recordType {
+		case backupRecordBlock:
+			blockBytes, err := reader.readBytes()
+			if err != nil {
+				return nil, err
+			}
+			if numBlocks > archive.blockNumber {
+				return nil, fmt.Errorf("Backup archive has more blocks than the [%d] expected", archive.blockNumber+1)
+			}
+			lastBlock, err = protos.UnmarshallBlock(blockBytes)
+			if err != nil {
+				return nil, err
+			}
+			if putBlock != nil {
+				if err = putBlock(numBlocks, lastBlock); err != nil {
+					return nil, err
+				}
+			}
+			numBlocks++
+		case backupRecordState:
+			compositeKey, err := reader.readBytes()
+			if err != nil {
+				return nil, err
+			}
+			value, err := reader.readBytes()
+			if err != nil {
+				return nil, err
+			}
+			if putState != nil {
+				if err = putState(compositeKey, value); err != nil {
+					return nil, err
+				}
+			}
+			archive.numKeys++
+		case backupRecordEnd:
+			checksum := reader.checksum.Sum(nil)
+			expectedChecksum := make([]byte, len(checksum))
+			if _, err := io.ReadFull(reader.r, expectedChecksum); err != nil {
+				return nil, fmt.Errorf("Error reading backup archive: %s", err)
+			}
+			if !bytes.Equal(checksum, expectedChecksum) {
+				return nil, fmt.Errorf("Backup archive checksum does not match, the archive is corrupted")
+			}
+			// reading up to the end of the gzip stream checks its trailer
+			if _, err := reader.r.ReadByte(); err == nil {
+				return nil, fmt.Errorf("Backup archive has data after the checksum")
+			} else if err != io.EOF {
+				return nil, fmt.Errorf("Error reading backup archive: %s", err)
+			}
+			done = true
+		default:
+			return nil, fmt.Errorf("Unknown record type [%d] in backup archive", recordType)
+		}
+	}
+
+	if numBlocks != archive.blockNumber+1 {
+		return nil, fmt.Errorf("Backup archive has [%d] blocks, expected [%d]", numBlocks, archive.blockNumber+1)
+	}
+	if !bytes.Equal(lastBlock.StateHash, archive.stateHash) {
+		return nil, fmt.Errorf("State hash of block [%d] does not match the one recorded in the backup archive", archive.blockNumber)
+	}
+	return archive, nil
+}
+
+func (ledger *Ledger) commitRestoredState(delta *statemgmt.StateDelta) error {
+	id := "restore"
+	if err := ledger.ApplyStateDelta(id, delta); err != nil {
+		return err
+	}
+	return ledger.CommitStateDelta(id)
+}
+
+// backupWriter writes the archive and computes its checksum. The first error
+// is kept and stops further writes.
+type backupWriter struct {
+	w        io.Writer
+	checksum hash.Hash
+	err      error
+}
+
+func (writer *backupWriter) writeUvarint(x uint64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	writer.write(buf[:binary.PutUvarint(buf, x)])
+}
+
+func (writer *backupWriter) writeBytes(b []byte) {
+	writer.writeUvarint(uint64(len(b)))
+	writer.write(b)
+}
+
+func (writer *backupWriter) write(b []byte) {
+	writer.checksum.Write(b)
+	writer.writeRaw(b)
+}
+
+// writeRaw writes b without adding it to the checksum
+func (writer *backupWriter) writeRaw(b []byte) {
+	if writer.err != nil {
+		return
+	}
+	_, writer.err = writer.w.Write(b)
+}
+
+// backupReader reads the archive and computes the checksum of what it read
+type backupReader struct {
+	r        *bufio.Reader
+	checksum hash.Hash
+}
+
+func (reader *backupReader) ReadByte() (byte, error) {
+	b, err := reader.r.ReadByte()
+	if err == nil {
+		reader.checksum.Write([]byte{b})
+	}
+	return b, err
+}
+
+func (reader *backupReader) readUvarint() (uint64, error) {
+	x, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return 0, fmt.Errorf("Error reading backup archive: %s", err)
+	}
+	return x, nil
+}
+
+func (reader *backupReader) readBytes() ([]byte, error) {
+	length, err := reader.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if length > maxBackupRecordSize {
+		return nil, fmt.Errorf("Backup archive has a record of size [%d], the archive is corrupted", length)
+	}
+	b := make([]byte, length)
+	if _, err = io.ReadFull(reader.r, b); err != nil {
+		return nil, fmt.Errorf("Error reading backup archive: %s", err)
+	}
+	reader.checksum.Write(b)
+	return b, nil
+}
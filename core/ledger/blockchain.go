@@ -24,7 +24,6 @@ import (
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/util"
 	"github.com/hyperledger/fabric/protos"
-	"github.com/tecbot/gorocksdb"
 	"golang.org/x/net/context"
 )
 
@@ -35,6 +34,7 @@ type blockchain struct {
 	previousBlockHash  []byte
 	indexer            blockchainIndexer
 	lastProcessedBlock *lastProcessedBlock
+	pruner             *blockPruner
 }
 
 type lastProcessedBlock struct {
@@ -50,14 +50,18 @@ func newBlockchain() (*blockchain, error) {
 	if err != nil {
 		return nil, err
 	}
-	blockchain := &blockchain{0, nil, nil, nil}
+	pruner, err := newBlockPruner()
+	if err != nil {
+		return nil, err
+	}
+	blockchain := &blockchain{0, nil, nil, nil, pruner}
 	blockchain.size = size
 	if size > 0 {
 		previousBlock, err := fetchBlockFromDB(size - 1)
 		if err != nil {
 			return nil, err
 		}
-		previousBlockHash, err := previousBlock.GetHash()
+		previousBlockHash, err := getBlockHash(previousBlock)
 		if err != nil {
 			return nil, err
 		}
@@ -94,9 +98,14 @@ func (blockchain *blockchain) getSize() uint64 {
 	return blockchain.size
 }
 
-// getBlock get block at arbitrary height in block chain
+// getBlock get block at arbitrary height in block chain. A pruned block is
+// fetched from the archive, ErrBlockPruned is returned if it was not archived
 func (blockchain *blockchain) getBlock(blockNumber uint64) (*protos.Block, error) {
-	return fetchBlockFromDB(blockNumber)
+	block, err := fetchBlockFromDB(blockNumber)
+	if err != nil || block == nil || !block.IsPruned() {
+		return block, err
+	}
+	return blockchain.pruner.fetchArchivedBlock(blockNumber, block)
 }
 
 // getBlockByHash get block by block hash
@@ -188,7 +197,7 @@ func (blockchain *blockchain) buildBlock(block *protos.Block, stateHash []byte)
 }
 
 func (blockchain *blockchain) addPersistenceChangesForNewBlock(ctx context.Context,
-	block *protos.Block, stateHash []byte, writeBatch *gorocksdb.WriteBatch) (uint64, error) {
+	block *protos.Block, stateHash []byte, writeBatch *db.WriteBatch) (uint64, error) {
 	block = blockchain.buildBlock(block, stateHash)
 	if block.NonHashData == nil {
 		block.NonHashData = &protos.NonHashData{LocalLedgerCommitTimestamp: util.CreateUtcTimestamp()}
@@ -214,6 +223,9 @@ func (blockchain *blockchain) addPersistenceChangesForNewBlock(ctx context.Conte
 }
 
 func (blockchain *blockchain) blockPersistenceStatus(success bool) {
+	if blockchain.pruner != nil {
+		blockchain.pruner.pruningStatus(success)
+	}
 	if success {
 		blockchain.size++
 		blockchain.previousBlockHash = blockchain.lastProcessedBlock.blockHash
@@ -230,7 +242,7 @@ func (blockchain *blockchain) persistRawBlock(block *protos.Block, blockNumber u
 	if blockBytesErr != nil {
 		return blockBytesErr
 	}
-	writeBatch := gorocksdb.NewWriteBatch()
+	writeBatch := db.NewWriteBatch()
 	defer writeBatch.Destroy()
 	writeBatch.PutCF(db.GetDBHandle().BlockchainCF, encodeBlockNumberDBKey(blockNumber), blockBytes)
 
@@ -252,15 +264,23 @@ func (blockchain *blockchain) persistRawBlock(block *protos.Block, blockNumber u
 		blockchain.indexer.createIndexesSync(block, blockNumber, blockHash, writeBatch)
 	}
 
-	opt := gorocksdb.NewDefaultWriteOptions()
-	defer opt.Destroy()
-	err = db.GetDBHandle().DB.Write(opt, writeBatch)
+	err = db.GetDBHandle().Write(writeBatch)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// getBlockHash returns the hash of the block, or the hash recorded when the
+// block was pruned
+func getBlockHash(block *protos.Block) ([]byte, error) {
+	if block.IsPruned() {
+		return block.NonHashData.PrunedBlockHash, nil
+	}
+	return block.GetHash()
+}
+
+// fetchBlockFromDB returns the block as stored, i.e. only its header if it has been pruned
 func fetchBlockFromDB(blockNumber uint64) (*protos.Block, error) {
 	blockBytes, err := db.GetDBHandle().GetFromBlockchainCF(encodeBlockNumberDBKey(blockNumber))
 	if err != nil {
@@ -283,7 +303,15 @@ func fetchBlockchainSizeFromDB() (uint64, error) {
 	return decodeToUint64(bytes), nil
 }
 
-func fetchBlockchainSizeFromSnapshot(snapshot *gorocksdb.Snapshot) (uint64, error) {
+// getStateDBSnapshot returns a DB snapshot of the state and of the size of the blockchain
+func getStateDBSnapshot() (db.Snapshot, error) {
+	openchainDB := db.GetDBHandle()
+	return openchainDB.GetSnapshot(
+		db.SnapshotContent{CF: openchainDB.StateCF},
+		db.SnapshotContent{CF: openchainDB.BlockchainCF, Keys: [][]byte{blockCountKey}})
+}
+
+func fetchBlockchainSizeFromSnapshot(snapshot db.Snapshot) (uint64, error) {
 	blockNumberBytes, err := db.GetDBHandle().GetFromBlockchainCFSnapshot(snapshot, blockCountKey)
 	if err != nil {
 		return 0, err
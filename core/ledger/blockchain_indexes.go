@@ -23,21 +23,22 @@ import (
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/protos"
 	"github.com/op/go-logging"
-	"github.com/tecbot/gorocksdb"
 )
 
 var indexLogger = logging.MustGetLogger("indexes")
 var prefixBlockHashKey = byte(1)
 var prefixTxUUIDKey = byte(2)
 var prefixAddressBlockNumCompositeKey = byte(3)
+var prefixTxResultKey = byte(4)
 
 type blockchainIndexer interface {
 	isSynchronous() bool
 	start(blockchain *blockchain) error
-	createIndexesSync(block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *gorocksdb.WriteBatch) error
+	createIndexesSync(block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *db.WriteBatch) error
 	createIndexesAsync(block *protos.Block, blockNumber uint64, blockHash []byte) error
 	fetchBlockNumberByBlockHash(blockHash []byte) (uint64, error)
 	fetchTransactionIndexByUUID(txUUID string) (uint64, uint64, error)
+	fetchTransactionResultIndexByUUID(txUUID string) (uint64, uint64, error)
 	stop()
 }
 
@@ -58,7 +59,7 @@ func (indexer *blockchainIndexerSync) start(blockchain *blockchain) error {
 }
 
 func (indexer *blockchainIndexerSync) createIndexesSync(
-	block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *gorocksdb.WriteBatch) error {
+	block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *db.WriteBatch) error {
 	return addIndexDataForPersistence(block, blockNumber, blockHash, writeBatch)
 }
 
@@ -74,12 +75,16 @@ func (indexer *blockchainIndexerSync) fetchTransactionIndexByUUID(txUUID string)
 	return fetchTransactionIndexByUUIDFromDB(txUUID)
 }
 
+func (indexer *blockchainIndexerSync) fetchTransactionResultIndexByUUID(txUUID string) (uint64, uint64, error) {
+	return fetchTransactionResultIndexByUUIDFromDB(txUUID)
+}
+
 func (indexer *blockchainIndexerSync) stop() {
 	return
 }
 
 // Functions for persisting and retrieving index data
-func addIndexDataForPersistence(block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *gorocksdb.WriteBatch) error {
+func addIndexDataForPersistence(block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *db.WriteBatch) error {
 	openchainDB := db.GetDBHandle()
 	cf := openchainDB.IndexesCF
 
@@ -106,6 +111,10 @@ func addIndexDataForPersistence(block *protos.Block, blockNumber uint64, blockHa
 			}
 		}
 	}
+	for resultIndex, txResult := range block.GetNonHashData().GetTransactionResults() {
+		// add TxUUID -> (blockNumber,indexWithinTransactionResults)
+		writeBatch.PutCF(cf, encodeTxResultKey(txResult.Uuid), encodeBlockNumTxIndex(blockNumber, uint64(resultIndex)))
+	}
 	for address, txsIndexes := range addressToTxIndexesMap {
 		writeBatch.PutCF(cf, encodeAddressBlockNumCompositeKey(address, blockNumber), encodeListTxIndexes(txsIndexes))
 	}
@@ -137,6 +146,17 @@ func fetchTransactionIndexByUUIDFromDB(txUUID string) (uint64, uint64, error) {
 	return decodeBlockNumTxIndex(blockNumTxIndexBytes)
 }
 
+func fetchTransactionResultIndexByUUIDFromDB(txUUID string) (uint64, uint64, error) {
+	blockNumResultIndexBytes, err := db.GetDBHandle().GetFromIndexesCF(encodeTxResultKey(txUUID))
+	if err != nil {
+		return 0, 0, err
+	}
+	if blockNumResultIndexBytes == nil {
+		return 0, 0, ErrResourceNotFound
+	}
+	return decodeBlockNumTxIndex(blockNumResultIndexBytes)
+}
+
 func getTxExecutingAddress(tx *protos.Transaction) string {
 	// TODO Fetch address form tx
 	return "address1"
@@ -196,6 +216,11 @@ func encodeTxUUIDKey(txUUID string) []byte {
 	return prependKeyPrefix(prefixTxUUIDKey, []byte(txUUID))
 }
 
+// encode TxResultKey
+func encodeTxResultKey(txUUID string) []byte {
+	return prependKeyPrefix(prefixTxResultKey, []byte(txUUID))
+}
+
 func encodeAddressBlockNumCompositeKey(address string, blockNumber uint64) []byte {
 	b := proto.NewBuffer([]byte{prefixAddressBlockNumCompositeKey})
 	b.EncodeRawBytes([]byte(address))
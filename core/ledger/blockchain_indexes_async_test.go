@@ -22,9 +22,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
 	"github.com/hyperledger/fabric/protos"
-	"github.com/tecbot/gorocksdb"
 )
 
 func TestIndexesAsync_GetBlockByBlockNumber(t *testing.T) {
@@ -155,7 +155,7 @@ func (noop *NoopIndexer) isSynchronous() bool {
 func (noop *NoopIndexer) start(blockchain *blockchain) error {
 	return nil
 }
-func (noop *NoopIndexer) createIndexesSync(block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *gorocksdb.WriteBatch) error {
+func (noop *NoopIndexer) createIndexesSync(block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *db.WriteBatch) error {
 	return nil
 }
 func (noop *NoopIndexer) createIndexesAsync(block *protos.Block, blockNumber uint64, blockHash []byte) error {
@@ -167,6 +167,9 @@ func (noop *NoopIndexer) fetchBlockNumberByBlockHash(blockHash []byte) (uint64,
 func (noop *NoopIndexer) fetchTransactionIndexByUUID(txUUID string) (uint64, uint64, error) {
 	return 0, 0, nil
 }
+func (noop *NoopIndexer) fetchTransactionResultIndexByUUID(txUUID string) (uint64, uint64, error) {
+	return 0, 0, nil
+}
 func (noop *NoopIndexer) stop() {
 }
 
@@ -0,0 +1,267 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+// Pruning replaces old blocks in the blockchainCF with their headers, i.e. the
+// blocks without their transactions. The hash of a pruned block is recorded in
+// its NonHashData so that the chain can still be verified. Blocks are pruned
+// in order, the number of pruned blocks is kept under prunedBlockCountKey.
+
+var prunedBlockCountKey = []byte("prunedBlockCount")
+
+// maxBlocksPrunedPerCommit bounds the work done by a single commit, e.g. when
+// pruning is enabled on an existing chain
+const maxBlocksPrunedPerCommit = 100
+
+// blocksPerArchiveFile is the number of consecutive blocks stored in one archive file
+const blocksPerArchiveFile = 1000
+
+type blockPruner struct {
+	keepBlocks       uint64
+	keepDuration     time.Duration
+	archive          *blockArchive
+	prunedBlockCount uint64
+	// prunedBlockCount once the pending changes are persisted
+	pendingPrunedBlockCount uint64
+}
+
+// newBlockPruner returns nil if pruning is not enabled
+func newBlockPruner() (*blockPruner, error) {
+	if !viper.GetBool("ledger.blockchain.pruning.enabled") {
+		return nil, nil
+	}
+	keepBlocks := viper.GetInt("ledger.blockchain.pruning.keepBlocks")
+	keepDuration := viper.GetDuration("ledger.blockchain.pruning.keepDuration")
+	if keepBlocks < 0 || keepDuration < 0 {
+		return nil, fmt.Errorf("Invalid pruning configuration: keepBlocks [%d] and keepDuration [%s] can not be negative", keepBlocks, keepDuration)
+	}
+	if keepBlocks == 0 && keepDuration == 0 {
+		return nil, fmt.Errorf("Invalid pruning configuration: at least one of keepBlocks and keepDuration must be set")
+	}
+	// the last block is always kept, its hash is needed for the next block
+	if keepBlocks == 0 {
+		keepBlocks = 1
+	}
+
+	pruner := &blockPruner{keepBlocks: uint64(keepBlocks), keepDuration: keepDuration}
+	if viper.GetBool("ledger.blockchain.pruning.archive.enabled") {
+		directory := viper.GetString("ledger.blockchain.pruning.archive.directory")
+		if directory == "" {
+			directory = filepath.Join(viper.GetString("peer.fileSystemPath"), "archive")
+		}
+		if err := os.MkdirAll(directory, 0755); err != nil {
+			return nil, err
+		}
+		pruner.archive = &blockArchive{directory}
+	}
+
+	prunedBlockCountBytes, err := db.GetDBHandle().GetFromBlockchainCF(prunedBlockCountKey)
+	if err != nil {
+		return nil, err
+	}
+	if prunedBlockCountBytes != nil {
+		pruner.prunedBlockCount = decodeToUint64(prunedBlockCountBytes)
+	}
+	pruner.pendingPrunedBlockCount = pruner.prunedBlockCount
+	ledgerLogger.Infof("Pruning blocks: keepBlocks=[%d], keepDuration=[%s], archive=[%t], blocks pruned so far=[%d]",
+		keepBlocks, keepDuration, pruner.archive != nil, pruner.prunedBlockCount)
+	return pruner, nil
+}
+
+// addPruningChangesForPersistence adds to writeBatch the headers replacing the
+// blocks that are to be pruned once the blockchain has blockchainSize blocks.
+// Blocks are archived, if enabled, before they are pruned. It returns the
+// numbers of the blocks that are pruned.
+func (pruner *blockPruner) addPruningChangesForPersistence(blockchainSize uint64, writeBatch *db.WriteBatch) ([]uint64, error) {
+	var prunedBlockNumbers []uint64
+	blockNumber := pruner.prunedBlockCount
+	for ; blockNumber+pruner.keepBlocks < blockchainSize && len(prunedBlockNumbers) < maxBlocksPrunedPerCommit; blockNumber++ {
+		block, err := fetchBlockFromDB(blockNumber)
+		if err != nil {
+			return nil, err
+		}
+		if block == nil {
+			// blocks received out of order during state transfer may still be missing
+			break
+		}
+		if block.IsPruned() {
+			continue
+		}
+		if pruner.keepDuration > 0 && time.Since(getBlockCommitTime(block)) < pruner.keepDuration {
+			break
+		}
+		blockHash, err := block.GetHash()
+		if err != nil {
+			return nil, err
+		}
+		if pruner.archive != nil {
+			if err = pruner.archive.archiveBlock(blockNumber, block); err != nil {
+				return nil, fmt.Errorf("Error archiving block [%d]: %s", blockNumber, err)
+			}
+		}
+		header := *block
+		header.Transactions = nil
+		// the results of the transactions are kept
+		header.NonHashData = &protos.NonHashData{
+			LocalLedgerCommitTimestamp: block.GetNonHashData().GetLocalLedgerCommitTimestamp(),
+			TransactionResults:         block.GetNonHashData().GetTransactionResults(),
+			PrunedBlockHash:            blockHash,
+		}
+		headerBytes, err := header.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		writeBatch.PutCF(db.GetDBHandle().BlockchainCF, encodeBlockNumberDBKey(blockNumber), headerBytes)
+		prunedBlockNumbers = append(prunedBlockNumbers, blockNumber)
+	}
+	if blockNumber != pruner.prunedBlockCount {
+		ledgerLogger.Debugf("Pruning blocks [%d] to [%d]", pruner.prunedBlockCount, blockNumber-1)
+		writeBatch.PutCF(db.GetDBHandle().BlockchainCF, prunedBlockCountKey, encodeUint64(blockNumber))
+	}
+	pruner.pendingPrunedBlockCount = blockNumber
+	return prunedBlockNumbers, nil
+}
+
+func (pruner *blockPruner) pruningStatus(success bool) {
+	if success {
+		pruner.prunedBlockCount = pruner.pendingPrunedBlockCount
+	} else {
+		pruner.pendingPrunedBlockCount = pruner.prunedBlockCount
+	}
+}
+
+// fetchArchivedBlock returns the full block for the given header of a pruned
+// block, or ErrBlockPruned if the block has not been archived
+func (pruner *blockPruner) fetchArchivedBlock(blockNumber uint64, header *protos.Block) (*protos.Block, error) {
+	if pruner == nil || pruner.archive == nil {
+		return nil, ErrBlockPruned
+	}
+	block, err := pruner.archive.fetchBlock(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, ErrBlockPruned
+	}
+	blockHash, err := block.GetHash()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(blockHash, header.NonHashData.PrunedBlockHash) {
+		return nil, fmt.Errorf("Archived block [%d] does not match the hash of the pruned block", blockNumber)
+	}
+	return block, nil
+}
+
+func getBlockCommitTime(block *protos.Block) time.Time {
+	timestamp := block.Timestamp
+	if block.NonHashData != nil && block.NonHashData.LocalLedgerCommitTimestamp != nil {
+		timestamp = block.NonHashData.LocalLedgerCommitTimestamp
+	}
+	if timestamp == nil {
+		return time.Time{}
+	}
+	return time.Unix(timestamp.Seconds, int64(timestamp.Nanos))
+}
+
+// blockArchive appends pruned blocks to flat files, each holding
+// blocksPerArchiveFile consecutive blocks. A file is a sequence of records of
+// the block number and the length of the block bytes, as uvarints, followed by
+// the block bytes. A block may appear more than once if a commit failed after
+// archiving it, all copies are identical.
+type blockArchive struct {
+	directory string
+}
+
+func (archive *blockArchive) fileName(blockNumber uint64) string {
+	first := blockNumber - blockNumber%blocksPerArchiveFile
+	return filepath.Join(archive.directory, fmt.Sprintf("blocks_%020d", first))
+}
+
+// archiveBlock appends the block to its archive file and syncs the file, so
+// that the block is safe before it is pruned
+func (archive *blockArchive) archiveBlock(blockNumber uint64, block *protos.Block) error {
+	blockBytes, err := block.Bytes()
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(archive.fileName(blockNumber), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	record := make([]byte, 2*binary.MaxVarintLen64, 2*binary.MaxVarintLen64+len(blockBytes))
+	n := binary.PutUvarint(record, blockNumber)
+	n += binary.PutUvarint(record[n:], uint64(len(blockBytes)))
+	record = append(record[:n], blockBytes...)
+	if _, err = file.Write(record); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// fetchBlock returns nil if the block is not in the archive
+func (archive *blockArchive) fetchBlock(blockNumber uint64) (*protos.Block, error) {
+	file, err := os.Open(archive.fileName(blockNumber))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	for {
+		number, err := binary.ReadUvarint(reader)
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		length, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return nil, err
+		}
+		if number != blockNumber {
+			if _, err = reader.Discard(int(length)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		blockBytes := make([]byte, length)
+		if _, err = io.ReadFull(reader, blockBytes); err != nil {
+			return nil, err
+		}
+		return protos.UnmarshallBlock(blockBytes)
+	}
+}
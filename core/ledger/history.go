@@ -0,0 +1,194 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/state"
+	"github.com/hyperledger/fabric/protos"
+)
+
+// The history of a key is kept in the historyCF, one entry per modification.
+// The db key is the length-prefixed chaincodeID and key followed by the
+// big-endian block number and index of the transaction within the block, so
+// that the modifications of a key are adjacent and sorted oldest first. The
+// length prefixes keep a key from being mistaken for the prefix of another.
+//
+// The history is complete from the block recorded under historyStartKey. When
+// it starts, the value every key holds as of the last committed block is
+// recorded as a baseline, ordered after the modifications of that block. The
+// history starts again once the state changes outside of committed blocks, by
+// state transfer or restore, or after it was disabled.
+
+// historyStartKey cannot be mistaken for the key of a modification, as
+// chaincode IDs are not empty
+var historyStartKey = []byte{0}
+
+// baselineTxIndex is the transaction index of the baseline values
+const baselineTxIndex = math.MaxUint32
+
+// historyBaselineBatchSize is the number of baseline values written per batch
+const historyBaselineBatchSize = 1000
+
+// startHistory records the value of every key as of the last committed block
+// and marks that block as the start of the history
+func (ledger *Ledger) startHistory() error {
+	var start uint64
+	if ledger.GetBlockchainSize() > 0 {
+		snapshot, err := ledger.GetStateSnapshot()
+		if err != nil {
+			return err
+		}
+		defer snapshot.Release()
+		start = snapshot.GetBlockNumber()
+		ledgerLogger.Infof("Starting the ledger history at block [%d]", start)
+
+		cf := db.GetDBHandle().HistoryCF
+		writeBatch := db.NewWriteBatch()
+		defer func() { writeBatch.Destroy() }()
+		for count := 1; snapshot.Next(); count++ {
+			compositeKey, value := snapshot.GetRawKeyValue()
+			chaincodeID, key := statemgmt.DecodeCompositeKey(compositeKey)
+			modificationBytes, err := proto.Marshal(&protos.KeyModification{BlockNumber: start, Value: value})
+			if err != nil {
+				return err
+			}
+			writeBatch.PutCF(cf, encodeHistoryKey(chaincodeID, key, start, baselineTxIndex), modificationBytes)
+			if count%historyBaselineBatchSize == 0 {
+				if err := db.GetDBHandle().Write(writeBatch); err != nil {
+					return err
+				}
+				writeBatch.Destroy()
+				writeBatch = db.NewWriteBatch()
+			}
+		}
+		if err := db.GetDBHandle().Write(writeBatch); err != nil {
+			return err
+		}
+	}
+
+	startBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(startBytes, start)
+	if err := db.GetDBHandle().Put(db.GetDBHandle().HistoryCF, historyStartKey, startBytes); err != nil {
+		return err
+	}
+	ledger.historyStarted = true
+	return nil
+}
+
+// stopHistory removes the start of the history, which starts again at the
+// next block committed while it is enabled
+func (ledger *Ledger) stopHistory() error {
+	ledger.historyStarted = false
+	return db.GetDBHandle().Delete(db.GetDBHandle().HistoryCF, historyStartKey)
+}
+
+// fetchHistoryStart returns the block the history is complete from, ok is false
+// if the history has not started
+func fetchHistoryStart() (start uint64, ok bool, err error) {
+	startBytes, err := db.GetDBHandle().GetFromHistoryCF(historyStartKey)
+	if err != nil || startBytes == nil {
+		return 0, false, err
+	}
+	return binary.BigEndian.Uint64(startBytes), true, nil
+}
+
+// getHistoryStart returns the block the history is complete from
+func (ledger *Ledger) getHistoryStart() (uint64, error) {
+	if !ledger.historyEnabled {
+		return 0, fmt.Errorf("Ledger history is not enabled")
+	}
+	start, ok, err := fetchHistoryStart()
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("Ledger history starts at the next block committed")
+	}
+	return start, nil
+}
+
+// addHistoryForPersistence adds to writeBatch one entry for every key changed
+// by the given transactions, which are expected in the order they were executed
+func addHistoryForPersistence(blockNumber uint64, txStateDeltas []*state.TxStateDelta, writeBatch *db.WriteBatch) error {
+	cf := db.GetDBHandle().HistoryCF
+	for txIndex, txStateDelta := range txStateDeltas {
+		delta := txStateDelta.StateDelta
+		for _, chaincodeID := range delta.GetUpdatedChaincodeIds(false) {
+			for key, updatedValue := range delta.GetUpdates(chaincodeID) {
+				modification := &protos.KeyModification{
+					BlockNumber: blockNumber,
+					TxUuid:      txStateDelta.TxUUID,
+					Value:       updatedValue.GetValue(),
+					IsDelete:    updatedValue.IsDelete(),
+				}
+				modificationBytes, err := proto.Marshal(modification)
+				if err != nil {
+					return err
+				}
+				ledgerLogger.Debugf("Adding history of key [%s:%s] for block [%d], tx [%s]",
+					chaincodeID, key, blockNumber, txStateDelta.TxUUID)
+				writeBatch.PutCF(cf, encodeHistoryKey(chaincodeID, key, blockNumber, uint32(txIndex)), modificationBytes)
+			}
+		}
+	}
+	return nil
+}
+
+// fetchHistoryFromDB returns the modifications of the key made in blocks from
+// minBlockNumber up to and including maxBlockNumber, oldest first
+func fetchHistoryFromDB(chaincodeID string, key string, minBlockNumber uint64, maxBlockNumber uint64) ([]*protos.KeyModification, error) {
+	itr := db.GetDBHandle().GetHistoryCFIterator()
+	defer itr.Close()
+
+	prefix := encodeHistoryKeyPrefix(chaincodeID, key)
+	var modifications []*protos.KeyModification
+	for itr.Seek(encodeHistoryKey(chaincodeID, key, minBlockNumber, 0)); itr.ValidForPrefix(prefix); itr.Next() {
+		// making a copy of the value bytes because, underlying bytes are reused by itr.
+		modificationBytes := statemgmt.Copy(itr.Value())
+		modification := &protos.KeyModification{}
+		if err := proto.Unmarshal(modificationBytes, modification); err != nil {
+			return nil, err
+		}
+		if modification.BlockNumber > maxBlockNumber {
+			break
+		}
+		modifications = append(modifications, modification)
+	}
+	return modifications, nil
+}
+
+func encodeHistoryKeyPrefix(chaincodeID string, key string) []byte {
+	b := proto.NewBuffer([]byte{})
+	b.EncodeRawBytes([]byte(chaincodeID))
+	b.EncodeRawBytes([]byte(key))
+	return b.Bytes()
+}
+
+func encodeHistoryKey(chaincodeID string, key string, blockNumber uint64, txIndex uint32) []byte {
+	historyKey := encodeHistoryKeyPrefix(chaincodeID, key)
+	suffix := make([]byte, 12)
+	binary.BigEndian.PutUint64(suffix, blockNumber)
+	binary.BigEndian.PutUint32(suffix[8:], txIndex)
+	return append(historyKey, suffix...)
+}
@@ -22,13 +22,12 @@ import (
 	"reflect"
 	"sync"
 
-	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt/state"
 	"github.com/hyperledger/fabric/events/producer"
 	"github.com/op/go-logging"
-	"github.com/tecbot/gorocksdb"
+	"github.com/spf13/viper"
 
 	"github.com/hyperledger/fabric/protos"
 	"golang.org/x/net/context"
@@ -48,6 +47,8 @@ const (
 	ErrorTypeResourceNotFound = ErrorType("ResourceNotFound")
 	//ErrorTypeBlockNotFound used to indicate if a block is not found when looked up by it's hash
 	ErrorTypeBlockNotFound = ErrorType("ErrorTypeBlockNotFound")
+	//ErrorTypeBlockPruned used to indicate that the transactions of a block have been pruned
+	ErrorTypeBlockPruned = ErrorType("BlockPruned")
 )
 
 //Error can be used for throwing an error from ledger code.
@@ -75,13 +76,19 @@ var (
 
 	// ErrResourceNotFound is returned if a resource is not found
 	ErrResourceNotFound = newLedgerError(ErrorTypeResourceNotFound, "ledger: resource not found")
+
+	// ErrBlockPruned is returned if a block has been pruned and is not available in the archive
+	ErrBlockPruned = newLedgerError(ErrorTypeBlockPruned, "ledger: block has been pruned from the local ledger and is not archived")
 )
 
 // Ledger - the struct for openchain ledger
 type Ledger struct {
-	blockchain *blockchain
-	state      *state.State
-	currentID  interface{}
+	blockchain     *blockchain
+	state          *state.State
+	currentID      interface{}
+	historyEnabled bool
+	historyStarted bool
+	pendingTxs     *pendingTransactions
 }
 
 var ledger *Ledger
@@ -104,7 +111,17 @@ func GetNewLedger() (*Ledger, error) {
 	}
 
 	state := state.NewState()
-	return &Ledger{blockchain, state, nil}, nil
+	ledger := &Ledger{blockchain, state, nil, viper.GetBool("ledger.history.enabled"), false, newPendingTransactions()}
+	if !ledger.historyEnabled {
+		// The blocks committed meanwhile are not recorded
+		err = ledger.stopHistory()
+	} else if _, ledger.historyStarted, err = fetchHistoryStart(); err == nil && !ledger.historyStarted {
+		err = ledger.startHistory()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ledger, nil
 }
 
 /////////////////// Transaction-batch related methods ///////////////////////////////
@@ -156,10 +173,13 @@ func (ledger *Ledger) CommitTxBatch(id interface{}, transactions []*protos.Trans
 		return err
 	}
 
-	writeBatch := gorocksdb.NewWriteBatch()
+	writeBatch := db.NewWriteBatch()
 	defer writeBatch.Destroy()
 	block := protos.NewBlock(transactions, metadata)
-	block.NonHashData = &protos.NonHashData{}
+	block.NonHashData = &protos.NonHashData{
+		ChaincodeEvents:    getChaincodeEvents(transactionResults),
+		TransactionResults: getStoredTransactionResults(transactionResults),
+	}
 	newBlockNumber, err := ledger.blockchain.addPersistenceChangesForNewBlock(context.TODO(), block, stateHash, writeBatch)
 	if err != nil {
 		ledger.resetForNextTxGroup(false)
@@ -167,9 +187,31 @@ func (ledger *Ledger) CommitTxBatch(id interface{}, transactions []*protos.Trans
 		return err
 	}
 	ledger.state.AddChangesForPersistence(newBlockNumber, writeBatch)
-	opt := gorocksdb.NewDefaultWriteOptions()
-	defer opt.Destroy()
-	dbErr := db.GetDBHandle().DB.Write(opt, writeBatch)
+	if ledger.blockchain.pruner != nil {
+		err = ledger.addPruningChangesForPersistence(newBlockNumber+1, writeBatch)
+		if err != nil {
+			ledger.resetForNextTxGroup(false)
+			ledger.blockchain.blockPersistenceStatus(false)
+			return err
+		}
+	}
+	if ledger.historyEnabled && !ledger.historyStarted {
+		err = ledger.startHistory()
+		if err != nil {
+			ledger.resetForNextTxGroup(false)
+			ledger.blockchain.blockPersistenceStatus(false)
+			return err
+		}
+	}
+	if ledger.historyEnabled {
+		err = addHistoryForPersistence(newBlockNumber, ledger.state.GetTxStateDeltas(), writeBatch)
+		if err != nil {
+			ledger.resetForNextTxGroup(false)
+			ledger.blockchain.blockPersistenceStatus(false)
+			return err
+		}
+	}
+	dbErr := db.GetDBHandle().Write(writeBatch)
 	if dbErr != nil {
 		ledger.resetForNextTxGroup(false)
 		ledger.blockchain.blockPersistenceStatus(false)
@@ -178,8 +220,9 @@ func (ledger *Ledger) CommitTxBatch(id interface{}, transactions []*protos.Trans
 
 	ledger.resetForNextTxGroup(true)
 	ledger.blockchain.blockPersistenceStatus(true)
+	ledger.pendingTxs.removeBlockTransactions(block)
 
-	sendProducerBlockEvent(block)
+	sendProducerBlockEvents(newBlockNumber, block)
 	if len(transactionResults) != 0 {
 		ledgerLogger.Debug("There were some erroneous transactions. We need to send a 'TX rejected' message here.")
 	}
@@ -276,7 +319,10 @@ func (ledger *Ledger) SetStateMultipleKeys(chaincodeID string, kvs map[string][]
 // should be used when transferring the state from one peer to another peer. You must call
 // stateSnapshot.Release() once you are done with the snapshot to free up resources.
 func (ledger *Ledger) GetStateSnapshot() (*state.StateSnapshot, error) {
-	dbSnapshot := db.GetDBHandle().GetSnapshot()
+	dbSnapshot, err := getStateDBSnapshot()
+	if err != nil {
+		return nil, err
+	}
 	blockHeight, err := fetchBlockchainSizeFromSnapshot(dbSnapshot)
 	if err != nil {
 		dbSnapshot.Release()
@@ -298,6 +344,54 @@ func (ledger *Ledger) GetStateDelta(blockNumber uint64) (*statemgmt.StateDelta,
 	return ledger.state.FetchStateDeltaFromDB(blockNumber)
 }
 
+// GetHistoryForKey returns the modifications made to the key by committed
+// transactions since the history started, oldest first. History is recorded
+// while ledger.history.enabled is set, and starts again after the state is
+// received through state transfer.
+func (ledger *Ledger) GetHistoryForKey(chaincodeID string, key string) ([]*protos.KeyModification, error) {
+	start, err := ledger.getHistoryStart()
+	if err != nil {
+		return nil, err
+	}
+	modifications, err := fetchHistoryFromDB(chaincodeID, key, start, ledger.GetBlockchainSize())
+	if err != nil {
+		return nil, err
+	}
+	// The baseline is the value held when the history started
+	var txModifications []*protos.KeyModification
+	for _, modification := range modifications {
+		if modification.TxUuid != "" {
+			txModifications = append(txModifications, modification)
+		}
+	}
+	return txModifications, nil
+}
+
+// GetStateAsOfBlock returns the value the key held once the block with the given
+// number was committed, or nil if the key did not exist at that point. An error
+// is returned for the blocks before the start of the history.
+func (ledger *Ledger) GetStateAsOfBlock(chaincodeID string, key string, blockNumber uint64) ([]byte, error) {
+	start, err := ledger.getHistoryStart()
+	if err != nil {
+		return nil, err
+	}
+	if blockNumber >= ledger.GetBlockchainSize() {
+		return nil, ErrOutOfBounds
+	}
+	if blockNumber < start {
+		return nil, fmt.Errorf("Ledger history starts at block %d", start)
+	}
+	modifications, err := fetchHistoryFromDB(chaincodeID, key, start, blockNumber)
+	if err != nil || len(modifications) == 0 {
+		return nil, err
+	}
+	lastModification := modifications[len(modifications)-1]
+	if lastModification.IsDelete {
+		return nil, nil
+	}
+	return lastModification.Value, nil
+}
+
 // ApplyStateDelta applies a state delta to the current state. This is an
 // in memory change only. You must call ledger.CommitStateDelta to persist
 // the change to the DB.
@@ -334,6 +428,10 @@ func (ledger *Ledger) CommitStateDelta(id interface{}) error {
 		return err
 	}
 	defer ledger.resetForNextTxGroup(true)
+	// The history does not know the blocks the delta comes from
+	if err = ledger.stopHistory(); err != nil {
+		return err
+	}
 	return ledger.state.CommitStateDelta()
 }
 
@@ -352,6 +450,9 @@ func (ledger *Ledger) RollbackStateDelta(id interface{}) error {
 // This is generally only used during state synchronization when creating a
 // new state from a snapshot.
 func (ledger *Ledger) DeleteALLStateKeysAndValues() error {
+	if err := ledger.stopHistory(); err != nil {
+		return err
+	}
 	return ledger.state.DeleteState()
 }
 
@@ -365,7 +466,8 @@ func (ledger *Ledger) GetBlockchainInfo() (*protos.BlockchainInfo, error) {
 }
 
 // GetBlockByNumber return block given the number of the block on blockchain.
-// Lowest block on chain is block number zero
+// Lowest block on chain is block number zero. ErrBlockPruned is returned for a
+// block that has been pruned and is not available in the archive
 func (ledger *Ledger) GetBlockByNumber(blockNumber uint64) (*protos.Block, error) {
 	if blockNumber >= ledger.GetBlockchainSize() {
 		return nil, ErrOutOfBounds
@@ -390,7 +492,8 @@ func (ledger *Ledger) PutRawBlock(block *protos.Block, blockNumber uint64) error
 	if err != nil {
 		return err
 	}
-	sendProducerBlockEvent(block)
+	ledger.pendingTxs.removeBlockTransactions(block)
+	sendProducerBlockEvents(blockNumber, block)
 	return nil
 }
 
@@ -408,6 +511,7 @@ func (ledger *Ledger) PutRawBlock(block *protos.Block, blockNumber uint64) error
 // wish to verify the entire chain, use ledger.GetBlockchainSize() - 1.
 // lowBlock is the low block in the chain to include in verification. If
 // you wish to verify the entire chain, use 0 for the genesis block.
+// The hash of a pruned block is the one recorded when it was pruned.
 func (ledger *Ledger) VerifyChain(highBlock, lowBlock uint64) (uint64, error) {
 	if highBlock >= ledger.GetBlockchainSize() {
 		return highBlock, ErrOutOfBounds
@@ -416,7 +520,7 @@ func (ledger *Ledger) VerifyChain(highBlock, lowBlock uint64) (uint64, error) {
 		return lowBlock, ErrOutOfBounds
 	}
 
-	currentBlock, err := ledger.GetBlockByNumber(highBlock)
+	currentBlock, err := fetchBlockFromDB(highBlock)
 	if err != nil {
 		return highBlock, fmt.Errorf("Error fetching block %d.", highBlock)
 	}
@@ -425,14 +529,14 @@ func (ledger *Ledger) VerifyChain(highBlock, lowBlock uint64) (uint64, error) {
 	}
 
 	for i := highBlock; i > lowBlock; i-- {
-		previousBlock, err := ledger.GetBlockByNumber(i - 1)
+		previousBlock, err := fetchBlockFromDB(i - 1)
 		if err != nil {
 			return i, nil
 		}
 		if previousBlock == nil {
 			return i, nil
 		}
-		previousBlockHash, err := previousBlock.GetHash()
+		previousBlockHash, err := getBlockHash(previousBlock)
 		if err != nil {
 			return i, nil
 		}
@@ -445,6 +549,19 @@ func (ledger *Ledger) VerifyChain(highBlock, lowBlock uint64) (uint64, error) {
 	return lowBlock, nil
 }
 
+// addPruningChangesForPersistence prunes the blocks that are no longer to be
+// kept once the blockchain has blockchainSize blocks, together with their state deltas
+func (ledger *Ledger) addPruningChangesForPersistence(blockchainSize uint64, writeBatch *db.WriteBatch) error {
+	prunedBlockNumbers, err := ledger.blockchain.pruner.addPruningChangesForPersistence(blockchainSize, writeBatch)
+	if err != nil {
+		return err
+	}
+	for _, blockNumber := range prunedBlockNumbers {
+		ledger.state.AddStateDeltaDeletionForPersistence(blockNumber, writeBatch)
+	}
+	return nil
+}
+
 func (ledger *Ledger) checkValidIDBegin() error {
 	if ledger.currentID != nil {
 		return fmt.Errorf("Another TxGroup [%s] already in-progress", ledger.currentID)
@@ -465,29 +582,24 @@ func (ledger *Ledger) resetForNextTxGroup(txCommited bool) {
 	ledger.state.ClearInMemoryChanges(txCommited)
 }
 
-func sendProducerBlockEvent(block *protos.Block) {
-
-	// Remove payload from deploy transactions. This is done to make block
-	// events more lightweight as the payload for these types of transactions
-	// can be very large.
-	blockTransactions := block.GetTransactions()
-	for _, transaction := range blockTransactions {
-		if transaction.Type == protos.Transaction_CHAINCODE_DEPLOY {
-			deploymentSpec := &protos.ChaincodeDeploymentSpec{}
-			err := proto.Unmarshal(transaction.Payload, deploymentSpec)
-			if err != nil {
-				ledgerLogger.Errorf("Error unmarshalling deployment transaction for block event: %s", err)
-				continue
-			}
-			deploymentSpec.CodePackage = nil
-			deploymentSpecBytes, err := proto.Marshal(deploymentSpec)
-			if err != nil {
-				ledgerLogger.Errorf("Error marshalling deployment transaction for block event: %s", err)
-				continue
-			}
-			transaction.Payload = deploymentSpecBytes
-		}
+func sendProducerBlockEvents(blockNumber uint64, block *protos.Block) {
+	for _, event := range producer.CreateBlockEvents(blockNumber, block) {
+		producer.Send(event)
 	}
+}
 
-	producer.Send(producer.CreateBlockEvent(block))
+// getChaincodeEvents returns the events emitted by the successful transactions, followed by the
+// events of the chaincodes they invoked
+func getChaincodeEvents(transactionResults []*protos.TransactionResult) []*protos.ChaincodeEvent {
+	var chaincodeEvents []*protos.ChaincodeEvent
+	for _, txResult := range transactionResults {
+		if txResult.ErrorCode != 0 {
+			continue
+		}
+		chaincodeEvents = append(chaincodeEvents, txResult.ChaincodeEvents...)
+		for _, invocation := range txResult.Invocations {
+			chaincodeEvents = append(chaincodeEvents, invocation.ChaincodeEvents...)
+		}
+	}
+	return chaincodeEvents
 }
@@ -24,6 +24,7 @@ import (
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
 	"github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
 )
 
 func TestLedgerCommit(t *testing.T) {
@@ -706,6 +707,89 @@ func TestGetTransactionByUUID(t *testing.T) {
 	testutil.AssertNil(t, ledgerTransaction)
 }
 
+func TestGetTransactionStatus(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	transaction, uuid := buildTestTx(t)
+	failedTransaction, failedUUID := buildTestTx(t)
+	ledger.AddPendingTransaction(uuid)
+	ledger.AddPendingTransaction(failedUUID)
+	ledger.AddPendingTransaction("pendingUUID")
+
+	status, err := ledger.GetTransactionStatus(uuid)
+	testutil.AssertNoError(t, err, "Error fetching transaction status.")
+	testutil.AssertEquals(t, status.Status, protos.TransactionStatus_PENDING)
+
+	// Block 0, only the first transaction succeeds
+	ledger.BeginTxBatch(0)
+	ledger.TxBegin("txUuid1")
+	ledger.SetState("chaincode1", "key1", []byte("value1A"))
+	ledger.TxFinished("txUuid1", true)
+	txResults := []*protos.TransactionResult{
+		&protos.TransactionResult{Uuid: uuid},
+		&protos.TransactionResult{Uuid: failedTransaction.Uuid, ErrorCode: 1, Error: "failed"},
+	}
+	ledger.CommitTxBatch(0, []*protos.Transaction{transaction}, txResults, []byte("proof"))
+
+	block, err := ledger.GetBlockByNumber(0)
+	testutil.AssertNoError(t, err, "Error fetching block.")
+	blockHash, err := block.GetHash()
+	testutil.AssertNoError(t, err, "Error hashing block.")
+
+	status, err = ledger.GetTransactionStatus(uuid)
+	testutil.AssertNoError(t, err, "Error fetching transaction status.")
+	testutil.AssertEquals(t, status, &protos.TransactionStatus{Uuid: uuid, Status: protos.TransactionStatus_COMMITTED, BlockHash: blockHash})
+
+	status, err = ledger.GetTransactionStatus(failedUUID)
+	testutil.AssertNoError(t, err, "Error fetching transaction status.")
+	testutil.AssertEquals(t, status, &protos.TransactionStatus{Uuid: failedUUID, Status: protos.TransactionStatus_ERROR, ErrorCode: 1, Error: "failed", BlockHash: blockHash})
+
+	status, err = ledger.GetTransactionStatus("pendingUUID")
+	testutil.AssertNoError(t, err, "Error fetching transaction status.")
+	testutil.AssertEquals(t, status.Status, protos.TransactionStatus_PENDING)
+
+	status, err = ledger.GetTransactionStatus("InvalidUUID")
+	testutil.AssertNoError(t, err, "Error fetching transaction status.")
+	testutil.AssertEquals(t, status.Status, protos.TransactionStatus_UNKNOWN)
+}
+
+func TestGetTransactionResult(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	transaction, uuid := buildTestTx(t)
+	_, failedUUID := buildTestTx(t)
+	ccEvent := &protos.ChaincodeEvent{ChaincodeID: "chaincode1", TxID: uuid, EventName: "event1"}
+	feeEvent := &protos.ChaincodeEvent{ChaincodeID: "chaincode1", TxID: uuid, EventName: "fee"}
+	invokedEvent := &protos.ChaincodeEvent{ChaincodeID: "chaincode2", TxID: uuid, EventName: "event2"}
+	invocation := &protos.ChaincodeInvocation{ChaincodeID: "chaincode2", CallerChaincodeID: "chaincode1", Depth: 1, ModifiedKeys: []string{"key2"}}
+	ledger.BeginTxBatch(0)
+	ledger.TxBegin("txUuid1")
+	ledger.SetState("chaincode1", "key1", []byte("value1A"))
+	ledger.TxFinished("txUuid1", true)
+	txResults := []*protos.TransactionResult{
+		&protos.TransactionResult{Uuid: uuid, Result: []byte("result"), ChaincodeEvents: []*protos.ChaincodeEvent{ccEvent, feeEvent},
+			Invocations: []*protos.ChaincodeInvocation{&protos.ChaincodeInvocation{ChaincodeID: "chaincode2", CallerChaincodeID: "chaincode1", Depth: 1, ModifiedKeys: []string{"key2"}, ChaincodeEvents: []*protos.ChaincodeEvent{invokedEvent}}}},
+		&protos.TransactionResult{Uuid: failedUUID, ErrorCode: 1, Error: "failed", ChaincodeEvents: []*protos.ChaincodeEvent{ccEvent}},
+	}
+	ledger.CommitTxBatch(0, []*protos.Transaction{transaction}, txResults, []byte("proof"))
+
+	// the chaincode events are stored apart from the results
+	result, err := ledger.GetTransactionResult(uuid)
+	testutil.AssertNoError(t, err, "Error fetching transaction result.")
+	testutil.AssertEquals(t, result, &protos.TransactionResult{Uuid: uuid, Result: []byte("result"), Invocations: []*protos.ChaincodeInvocation{invocation}})
+	block := ledgerTestWrapper.GetBlockByNumber(0)
+	testutil.AssertEquals(t, block.NonHashData.ChaincodeEvents, []*protos.ChaincodeEvent{ccEvent, feeEvent, invokedEvent})
+
+	result, err = ledger.GetTransactionResult(failedUUID)
+	testutil.AssertNoError(t, err, "Error fetching transaction result.")
+	testutil.AssertEquals(t, result, &protos.TransactionResult{Uuid: failedUUID, ErrorCode: 1, Error: "failed"})
+
+	_, err = ledger.GetTransactionResult("InvalidUUID")
+	testutil.AssertEquals(t, err, ErrResourceNotFound)
+}
+
 func TestRangeScanIterator(t *testing.T) {
 	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
 	ledger := ledgerTestWrapper.ledger
@@ -916,3 +1000,266 @@ func TestLedgerInvalidInput(t *testing.T) {
 	value, _ := l.GetState("chaincodeID1", "key1", true)
 	testutil.AssertEquals(t, value, []byte("value1"))
 }
+
+func TestLedgerHistory(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	l := ledgerTestWrapper.ledger
+
+	// Block 0: two successful txs update key1, a failed tx is not recorded
+	l.BeginTxBatch(1)
+	l.TxBegin("txUuid1")
+	l.SetState("chaincode1", "key1", []byte("value1"))
+	l.TxFinished("txUuid1", true)
+	l.TxBegin("txUuid2")
+	l.SetState("chaincode1", "key1", []byte("value2"))
+	l.TxFinished("txUuid2", true)
+	l.TxBegin("txUuid3")
+	l.SetState("chaincode1", "key1", []byte("value3"))
+	l.TxFinished("txUuid3", false)
+	tx, _ := buildTestTx(t)
+	l.CommitTxBatch(1, []*protos.Transaction{tx}, nil, nil)
+
+	// Block 1: key1 is deleted
+	l.BeginTxBatch(2)
+	l.TxBegin("txUuid4")
+	l.DeleteState("chaincode1", "key1")
+	l.SetState("chaincode1", "key2", []byte("value1"))
+	l.TxFinished("txUuid4", true)
+	tx, _ = buildTestTx(t)
+	l.CommitTxBatch(2, []*protos.Transaction{tx}, nil, nil)
+
+	// Block 2: key1 is set again
+	l.BeginTxBatch(3)
+	l.TxBegin("txUuid5")
+	l.SetState("chaincode1", "key1", []byte("value4"))
+	l.TxFinished("txUuid5", true)
+	tx, _ = buildTestTx(t)
+	l.CommitTxBatch(3, []*protos.Transaction{tx}, nil, nil)
+
+	history, err := l.GetHistoryForKey("chaincode1", "key1")
+	testutil.AssertNoError(t, err, "Error while getting history")
+	testutil.AssertEquals(t, len(history), 4)
+	testutil.AssertEquals(t, history[0], &protos.KeyModification{BlockNumber: 0, TxUuid: "txUuid1", Value: []byte("value1")})
+	testutil.AssertEquals(t, history[1], &protos.KeyModification{BlockNumber: 0, TxUuid: "txUuid2", Value: []byte("value2")})
+	testutil.AssertEquals(t, history[2], &protos.KeyModification{BlockNumber: 1, TxUuid: "txUuid4", IsDelete: true})
+	testutil.AssertEquals(t, history[3], &protos.KeyModification{BlockNumber: 2, TxUuid: "txUuid5", Value: []byte("value4")})
+
+	// A key that is a prefix of another key has its own history
+	history, err = l.GetHistoryForKey("chaincode1", "key")
+	testutil.AssertNoError(t, err, "Error while getting history")
+	testutil.AssertEquals(t, len(history), 0)
+
+	value, err := l.GetStateAsOfBlock("chaincode1", "key1", 0)
+	testutil.AssertNoError(t, err, "Error while getting state as of block 0")
+	testutil.AssertEquals(t, value, []byte("value2"))
+	value, err = l.GetStateAsOfBlock("chaincode1", "key1", 1)
+	testutil.AssertNoError(t, err, "Error while getting state as of block 1")
+	testutil.AssertNil(t, value)
+	value, err = l.GetStateAsOfBlock("chaincode1", "key1", 2)
+	testutil.AssertNoError(t, err, "Error while getting state as of block 2")
+	testutil.AssertEquals(t, value, []byte("value4"))
+	value, err = l.GetStateAsOfBlock("chaincode1", "key2", 0)
+	testutil.AssertNoError(t, err, "Error while getting state as of block 0")
+	testutil.AssertNil(t, value)
+	_, err = l.GetStateAsOfBlock("chaincode1", "key1", 3)
+	testutil.AssertEquals(t, err, ErrOutOfBounds)
+
+	l.historyEnabled = false
+	_, err = l.GetHistoryForKey("chaincode1", "key1")
+	testutil.AssertError(t, err, "Expected an error when history is not enabled")
+}
+
+func TestLedgerHistoryStart(t *testing.T) {
+	viper.Set("ledger.history.enabled", false)
+	defer viper.Set("ledger.history.enabled", true)
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	l := ledgerTestWrapper.ledger
+
+	// Blocks 0 and 1 are committed without history
+	l.BeginTxBatch(1)
+	l.TxBegin("txUuid1")
+	l.SetState("chaincode1", "key1", []byte("value1"))
+	l.SetState("chaincode1", "key2", []byte("value2"))
+	l.TxFinished("txUuid1", true)
+	tx, _ := buildTestTx(t)
+	l.CommitTxBatch(1, []*protos.Transaction{tx}, nil, nil)
+	l.BeginTxBatch(2)
+	l.TxBegin("txUuid2")
+	l.SetState("chaincode1", "key1", []byte("value1_new"))
+	l.DeleteState("chaincode1", "key2")
+	l.TxFinished("txUuid2", true)
+	tx, _ = buildTestTx(t)
+	l.CommitTxBatch(2, []*protos.Transaction{tx}, nil, nil)
+
+	// The history starts from the state of block 1
+	viper.Set("ledger.history.enabled", true)
+	l, err := GetNewLedger()
+	testutil.AssertNoError(t, err, "Error while constructing ledger")
+	l.BeginTxBatch(3)
+	l.TxBegin("txUuid3")
+	l.SetState("chaincode1", "key1", []byte("value1_newer"))
+	l.TxFinished("txUuid3", true)
+	tx, _ = buildTestTx(t)
+	l.CommitTxBatch(3, []*protos.Transaction{tx}, nil, nil)
+
+	_, err = l.GetStateAsOfBlock("chaincode1", "key1", 0)
+	testutil.AssertError(t, err, "Expected an error before the start of the history")
+	value, err := l.GetStateAsOfBlock("chaincode1", "key1", 1)
+	testutil.AssertNoError(t, err, "Error while getting state as of block 1")
+	testutil.AssertEquals(t, value, []byte("value1_new"))
+	value, err = l.GetStateAsOfBlock("chaincode1", "key2", 1)
+	testutil.AssertNoError(t, err, "Error while getting state as of block 1")
+	testutil.AssertNil(t, value)
+	value, err = l.GetStateAsOfBlock("chaincode1", "key1", 2)
+	testutil.AssertNoError(t, err, "Error while getting state as of block 2")
+	testutil.AssertEquals(t, value, []byte("value1_newer"))
+	history, err := l.GetHistoryForKey("chaincode1", "key1")
+	testutil.AssertNoError(t, err, "Error while getting history")
+	testutil.AssertEquals(t, history, []*protos.KeyModification{&protos.KeyModification{BlockNumber: 2, TxUuid: "txUuid3", Value: []byte("value1_newer")}})
+
+	// State transfer stops the history until the next block
+	delta := statemgmt.NewStateDelta()
+	delta.Set("chaincode1", "key2", []byte("value2_transferred"), nil)
+	testutil.AssertNoError(t, l.ApplyStateDelta(4, delta), "Error while applying state delta")
+	testutil.AssertNoError(t, l.CommitStateDelta(4), "Error while committing state delta")
+	_, err = l.GetStateAsOfBlock("chaincode1", "key1", 2)
+	testutil.AssertError(t, err, "Expected an error while the history is stopped")
+
+	l.BeginTxBatch(5)
+	l.TxBegin("txUuid5")
+	l.SetState("chaincode1", "key1", []byte("value1_newest"))
+	l.TxFinished("txUuid5", true)
+	tx, _ = buildTestTx(t)
+	l.CommitTxBatch(5, []*protos.Transaction{tx}, nil, nil)
+
+	_, err = l.GetStateAsOfBlock("chaincode1", "key1", 1)
+	testutil.AssertError(t, err, "Expected an error before the new start of the history")
+	value, err = l.GetStateAsOfBlock("chaincode1", "key2", 2)
+	testutil.AssertNoError(t, err, "Error while getting state as of block 2")
+	testutil.AssertEquals(t, value, []byte("value2_transferred"))
+	value, err = l.GetStateAsOfBlock("chaincode1", "key1", 3)
+	testutil.AssertNoError(t, err, "Error while getting state as of block 3")
+	testutil.AssertEquals(t, value, []byte("value1_newest"))
+}
+
+func TestLedgerBackupAndRestore(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	l := ledgerTestWrapper.ledger
+
+	// Block 0
+	l.BeginTxBatch(1)
+	l.TxBegin("txUuid1")
+	l.SetState("chaincode1", "key1", []byte("value1"))
+	l.SetState("chaincode1", "key2", []byte("value2"))
+	l.TxFinished("txUuid1", true)
+	tx, _ := buildTestTx(t)
+	l.CommitTxBatch(1, []*protos.Transaction{tx}, nil, nil)
+
+	// Block 1
+	l.BeginTxBatch(2)
+	l.TxBegin("txUuid2")
+	l.SetState("chaincode1", "key1", []byte("value1_new"))
+	l.DeleteState("chaincode1", "key2")
+	l.SetState("chaincode2", "key3", []byte("value3"))
+	l.TxFinished("txUuid2", true)
+	tx, _ = buildTestTx(t)
+	l.CommitTxBatch(2, []*protos.Transaction{tx}, nil, nil)
+
+	latestBackup := &bytes.Buffer{}
+	testutil.AssertNoError(t, l.Backup(latestBackup, 1), "Error while backing up block 1")
+	olderBackup := &bytes.Buffer{}
+	testutil.AssertNoError(t, l.Backup(olderBackup, 0), "Error while backing up block 0")
+	testutil.AssertEquals(t, l.Backup(&bytes.Buffer{}, 2), ErrOutOfBounds)
+	block0, _ := l.GetBlockByNumber(0)
+	block1, _ := l.GetBlockByNumber(1)
+
+	// a ledger that has blocks cannot be restored into
+	_, err := l.Restore(bytes.NewReader(latestBackup.Bytes()))
+	testutil.AssertError(t, err, "Expected an error restoring into a ledger with blocks")
+
+	ledgerTestWrapper = createFreshDBAndTestLedgerWrapper(t)
+	blockNumber, err := ledgerTestWrapper.ledger.Restore(bytes.NewReader(latestBackup.Bytes()))
+	testutil.AssertNoError(t, err, "Error while restoring block 1")
+	testutil.AssertEquals(t, blockNumber, uint64(1))
+	testutil.AssertEquals(t, ledgerTestWrapper.ledger.GetBlockchainSize(), uint64(2))
+	testutil.AssertEquals(t, ledgerTestWrapper.GetBlockByNumber(1), block1)
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode1", "key1", true), []byte("value1_new"))
+	testutil.AssertNil(t, ledgerTestWrapper.GetState("chaincode1", "key2", true))
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode2", "key3", true), []byte("value3"))
+
+	ledgerTestWrapper = createFreshDBAndTestLedgerWrapper(t)
+	blockNumber, err = ledgerTestWrapper.ledger.Restore(bytes.NewReader(olderBackup.Bytes()))
+	testutil.AssertNoError(t, err, "Error while restoring block 0")
+	testutil.AssertEquals(t, blockNumber, uint64(0))
+	testutil.AssertEquals(t, ledgerTestWrapper.ledger.GetBlockchainSize(), uint64(1))
+	testutil.AssertEquals(t, ledgerTestWrapper.GetBlockByNumber(0), block0)
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode1", "key1", true), []byte("value1"))
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode1", "key2", true), []byte("value2"))
+	testutil.AssertNil(t, ledgerTestWrapper.GetState("chaincode2", "key3", true))
+
+	// a truncated archive is rejected
+	ledgerTestWrapper = createFreshDBAndTestLedgerWrapper(t)
+	truncatedBackup := latestBackup.Bytes()[:latestBackup.Len()-10]
+	_, err = ledgerTestWrapper.ledger.Restore(bytes.NewReader(truncatedBackup))
+	testutil.AssertError(t, err, "Expected an error restoring a truncated archive")
+
+	// nothing of it was committed and the ledger can still be restored
+	testutil.AssertEquals(t, ledgerTestWrapper.ledger.GetBlockchainSize(), uint64(0))
+	testutil.AssertNil(t, ledgerTestWrapper.GetState("chaincode1", "key1", true))
+	blockNumber, err = ledgerTestWrapper.ledger.Restore(bytes.NewReader(latestBackup.Bytes()))
+	testutil.AssertNoError(t, err, "Error while restoring block 1 after a truncated archive")
+	testutil.AssertEquals(t, blockNumber, uint64(1))
+}
+
+func TestLedgerPruning(t *testing.T) {
+	viper.Set("ledger.blockchain.pruning.enabled", true)
+	viper.Set("ledger.blockchain.pruning.keepBlocks", 2)
+	viper.Set("ledger.blockchain.pruning.archive.enabled", true)
+	defer viper.Set("ledger.blockchain.pruning.enabled", false)
+	defer viper.Set("ledger.blockchain.pruning.archive.enabled", false)
+
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	l := ledgerTestWrapper.ledger
+	var blocks []*protos.Block
+	for i := 0; i < 5; i++ {
+		l.BeginTxBatch(i)
+		l.TxBegin("txUuid")
+		l.SetState("chaincode1", "key1", []byte("value"+strconv.Itoa(i)))
+		l.TxFinished("txUuid", true)
+		tx, _ := buildTestTx(t)
+		l.CommitTxBatch(i, []*protos.Transaction{tx}, nil, nil)
+		blocks = append(blocks, ledgerTestWrapper.GetBlockByNumber(uint64(i)))
+	}
+
+	// blocks 0 to 2 are replaced by their headers, together with their state deltas
+	for i := uint64(0); i < 5; i++ {
+		storedBlock, _ := fetchBlockFromDB(i)
+		testutil.AssertEquals(t, storedBlock.IsPruned(), i < 3)
+		testutil.AssertEquals(t, ledgerTestWrapper.GetStateDelta(i) == nil, i < 3)
+		if storedBlock.IsPruned() {
+			testutil.AssertNil(t, storedBlock.Transactions)
+			testutil.AssertEquals(t, storedBlock.PreviousBlockHash, blocks[i].PreviousBlockHash)
+		}
+	}
+	testutil.AssertEquals(t, ledgerTestWrapper.VerifyChain(4, 0), uint64(0))
+
+	// pruned blocks are fetched from the archive
+	for i := uint64(0); i < 5; i++ {
+		testutil.AssertEquals(t, ledgerTestWrapper.GetBlockByNumber(i), blocks[i])
+	}
+	_, err := l.GetTransactionByUUID(blocks[0].Transactions[0].Uuid)
+	testutil.AssertNoError(t, err, "Error while getting a transaction of a pruned block")
+
+	// without the archive, pruned blocks are no longer available
+	viper.Set("ledger.blockchain.pruning.archive.enabled", false)
+	l, err = GetNewLedger()
+	testutil.AssertNoError(t, err, "Error while constructing ledger")
+	_, err = l.GetBlockByNumber(0)
+	testutil.AssertEquals(t, err, ErrBlockPruned)
+	block, err := l.GetBlockByNumber(3)
+	testutil.AssertNoError(t, err, "Error while getting a block that is not pruned")
+	testutil.AssertEquals(t, block, blocks[3])
+	lowestValidBlock, err := l.VerifyChain(4, 0)
+	testutil.AssertNoError(t, err, "Error while verifying the chain")
+	testutil.AssertEquals(t, lowestValidBlock, uint64(0))
+}
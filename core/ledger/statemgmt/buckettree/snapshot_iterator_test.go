@@ -42,7 +42,8 @@ func TestStateSnapshotIterator(t *testing.T) {
 	testutil.AssertEquals(t, stateImplTestWrapper.get("chaincodeID5", "key5"), []byte("value5"))
 
 	// take db snapeshot
-	dbSnapshot := db.GetDBHandle().GetSnapshot()
+	dbSnapshot, err := db.GetDBHandle().GetSnapshot(db.SnapshotContent{CF: db.GetDBHandle().StateCF})
+	testutil.AssertNoError(t, err, "Error while taking db snapshot")
 
 	// delete keys
 	stateDelta.Delete("chaincodeID1", "key1", nil)
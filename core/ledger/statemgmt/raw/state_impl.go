@@ -19,7 +19,6 @@ package raw
 import (
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
-	"github.com/tecbot/gorocksdb"
 )
 
 // StateImpl implements raw state management. This implementation does not support computation of crypto-hash of the state.
@@ -62,7 +61,7 @@ func (impl *StateImpl) ComputeCryptoHash() ([]byte, error) {
 }
 
 // AddChangesForPersistence - method implementation for interface 'statemgmt.HashableState'
-func (impl *StateImpl) AddChangesForPersistence(writeBatch *gorocksdb.WriteBatch) error {
+func (impl *StateImpl) AddChangesForPersistence(writeBatch *db.WriteBatch) error {
 	delta := impl.stateDelta
 	if delta == nil {
 		return nil
@@ -88,7 +87,7 @@ func (impl *StateImpl) PerfHintKeyChanged(chaincodeID string, key string) {
 }
 
 // GetStateSnapshotIterator - method implementation for interface 'statemgmt.HashableState'
-func (impl *StateImpl) GetStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (statemgmt.StateSnapshotIterator, error) {
+func (impl *StateImpl) GetStateSnapshotIterator(snapshot db.Snapshot) (statemgmt.StateSnapshotIterator, error) {
 	panic("Not a full-fledged state implementation. Implemented only for measuring best-case performance benchmark")
 }
 
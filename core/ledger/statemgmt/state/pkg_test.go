@@ -23,7 +23,6 @@ import (
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
-	"github.com/tecbot/gorocksdb"
 )
 
 var testDBWrapper = db.NewTestDBWrapper()
@@ -55,14 +54,15 @@ func (testWrapper *stateTestWrapper) get(chaincodeID string, key string, committ
 }
 
 func (testWrapper *stateTestWrapper) getSnapshot() *StateSnapshot {
-	dbSnapshot := db.GetDBHandle().GetSnapshot()
+	dbSnapshot, err := db.GetDBHandle().GetSnapshot(db.SnapshotContent{CF: db.GetDBHandle().StateCF})
+	testutil.AssertNoError(testWrapper.t, err, "Error during creation of db snapshot")
 	stateSnapshot, err := testWrapper.state.GetSnapshot(0, dbSnapshot)
 	testutil.AssertNoError(testWrapper.t, err, "Error during creation of state snapshot")
 	return stateSnapshot
 }
 
 func (testWrapper *stateTestWrapper) persistAndClearInMemoryChanges(blockNumber uint64) {
-	writeBatch := gorocksdb.NewWriteBatch()
+	writeBatch := db.NewWriteBatch()
 	defer writeBatch.Destroy()
 	testWrapper.state.AddChangesForPersistence(blockNumber, writeBatch)
 	testDBWrapper.WriteToDB(testWrapper.t, writeBatch)
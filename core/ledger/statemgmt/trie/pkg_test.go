@@ -25,7 +25,6 @@ import (
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
 	"github.com/hyperledger/fabric/core/util"
-	"github.com/tecbot/gorocksdb"
 )
 
 var testDBWrapper = db.NewTestDBWrapper()
@@ -54,13 +53,13 @@ func (stateTrieTestWrapper *stateTrieTestWrapper) PrepareWorkingSetAndComputeCry
 	return cryptoHash
 }
 
-func (stateTrieTestWrapper *stateTrieTestWrapper) AddChangesForPersistence(writeBatch *gorocksdb.WriteBatch) {
+func (stateTrieTestWrapper *stateTrieTestWrapper) AddChangesForPersistence(writeBatch *db.WriteBatch) {
 	err := stateTrieTestWrapper.stateTrie.AddChangesForPersistence(writeBatch)
 	testutil.AssertNoError(stateTrieTestWrapper.t, err, "Error while adding changes to db write-batch")
 }
 
 func (stateTrieTestWrapper *stateTrieTestWrapper) PersistChangesAndResetInMemoryChanges() {
-	writeBatch := gorocksdb.NewWriteBatch()
+	writeBatch := db.NewWriteBatch()
 	defer writeBatch.Destroy()
 	stateTrieTestWrapper.AddChangesForPersistence(writeBatch)
 	testDBWrapper.WriteToDB(stateTrieTestWrapper.t, writeBatch)
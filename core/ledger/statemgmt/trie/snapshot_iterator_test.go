@@ -48,7 +48,8 @@ func TestStateSnapshotIterator(t *testing.T) {
 	testutil.AssertEquals(t, stateTrieTestWrapper.Get("chaincodeID6", "key6"), []byte("value6"))
 
 	// take db snapeshot
-	dbSnapshot := db.GetDBHandle().GetSnapshot()
+	dbSnapshot, err := db.GetDBHandle().GetSnapshot(db.SnapshotContent{CF: db.GetDBHandle().StateCF})
+	testutil.AssertNoError(t, err, "Error while taking db snapshot")
 
 	stateDelta1 := statemgmt.NewStateDelta()
 	// delete a few keys
@@ -22,7 +22,6 @@ import (
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/op/go-logging"
-	"github.com/tecbot/gorocksdb"
 )
 
 var stateTrieLogger = logging.MustGetLogger("stateTrie")
@@ -141,7 +140,7 @@ func (stateTrie *StateTrie) processChangedNode(changedNode *trieNode) error {
 }
 
 // AddChangesForPersistence commits current changes to the database
-func (stateTrie *StateTrie) AddChangesForPersistence(writeBatch *gorocksdb.WriteBatch) error {
+func (stateTrie *StateTrie) AddChangesForPersistence(writeBatch *db.WriteBatch) error {
 	if stateTrie.recomputeCryptoHash {
 		_, err := stateTrie.ComputeCryptoHash()
 		if err != nil {
@@ -180,7 +179,7 @@ func (stateTrie *StateTrie) PerfHintKeyChanged(chaincodeID string, key string) {
 }
 
 // GetStateSnapshotIterator - method implementation for interface 'statemgmt.HashableState'
-func (stateTrie *StateTrie) GetStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (statemgmt.StateSnapshotIterator, error) {
+func (stateTrie *StateTrie) GetStateSnapshotIterator(snapshot db.Snapshot) (statemgmt.StateSnapshotIterator, error) {
 	return newStateSnapshotIterator(snapshot)
 }
 
@@ -0,0 +1,165 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hyperledger/fabric/protos"
+)
+
+// pendingTransactions holds the UUIDs of the transactions submitted by this
+// peer that are neither committed nor failed yet. It is kept in memory only,
+// a transaction that never reaches a block stays pending until the peer restarts.
+type pendingTransactions struct {
+	sync.RWMutex
+	uuids map[string]bool
+}
+
+func newPendingTransactions() *pendingTransactions {
+	return &pendingTransactions{uuids: make(map[string]bool)}
+}
+
+func (pending *pendingTransactions) add(txUUID string) {
+	pending.Lock()
+	defer pending.Unlock()
+	pending.uuids[txUUID] = true
+}
+
+func (pending *pendingTransactions) contains(txUUID string) bool {
+	pending.RLock()
+	defer pending.RUnlock()
+	return pending.uuids[txUUID]
+}
+
+// removeBlockTransactions removes the transactions that are part of the block
+// or failed with it
+func (pending *pendingTransactions) removeBlockTransactions(block *protos.Block) {
+	pending.Lock()
+	defer pending.Unlock()
+	for _, tx := range block.GetTransactions() {
+		delete(pending.uuids, tx.Uuid)
+	}
+	for _, txResult := range block.GetNonHashData().GetTransactionResults() {
+		delete(pending.uuids, txResult.Uuid)
+	}
+}
+
+// AddPendingTransaction records that the transaction has been submitted, its
+// status is PENDING until it is committed or fails
+func (ledger *Ledger) AddPendingTransaction(txUUID string) {
+	ledger.pendingTxs.add(txUUID)
+}
+
+// GetTransactionStatus returns whether the transaction is pending, committed or
+// failed. The status is UNKNOWN if the peer does not know of the transaction.
+func (ledger *Ledger) GetTransactionStatus(txUUID string) (*protos.TransactionStatus, error) {
+	status := &protos.TransactionStatus{Uuid: txUUID}
+
+	blockNumber, _, err := ledger.blockchain.indexer.fetchTransactionIndexByUUID(txUUID)
+	if err == nil {
+		status.Status = protos.TransactionStatus_COMMITTED
+		status.BlockNumber = blockNumber
+		status.BlockHash, err = ledger.blockHash(blockNumber)
+		if err != nil {
+			return nil, err
+		}
+		return status, nil
+	}
+	if err != ErrResourceNotFound {
+		return nil, err
+	}
+
+	txResult, blockNumber, err := ledger.fetchTransactionResult(txUUID)
+	if err == nil {
+		status.Status = protos.TransactionStatus_ERROR
+		status.BlockNumber = blockNumber
+		status.ErrorCode = txResult.ErrorCode
+		status.Error = txResult.Error
+		status.BlockHash, err = ledger.blockHash(blockNumber)
+		if err != nil {
+			return nil, err
+		}
+		return status, nil
+	}
+	if err != ErrResourceNotFound {
+		return nil, err
+	}
+
+	if ledger.pendingTxs.contains(txUUID) {
+		status.Status = protos.TransactionStatus_PENDING
+	}
+	return status, nil
+}
+
+// blockHash returns the hash of the block, which validators report with the
+// status of a transaction so that their replies can be compared
+func (ledger *Ledger) blockHash(blockNumber uint64) ([]byte, error) {
+	block, err := fetchBlockFromDB(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, ErrResourceNotFound
+	}
+	return getBlockHash(block)
+}
+
+// GetTransactionResult returns the result of the transaction, i.e. the
+// payload returned by the chaincode or the error if the execution failed. The
+// chaincode event of the transaction is part of the block's NonHashData instead.
+func (ledger *Ledger) GetTransactionResult(txUUID string) (*protos.TransactionResult, error) {
+	txResult, _, err := ledger.fetchTransactionResult(txUUID)
+	return txResult, err
+}
+
+func (ledger *Ledger) fetchTransactionResult(txUUID string) (*protos.TransactionResult, uint64, error) {
+	blockNumber, resultIndex, err := ledger.blockchain.indexer.fetchTransactionResultIndexByUUID(txUUID)
+	if err != nil {
+		return nil, 0, err
+	}
+	// the results are kept in the NonHashData of pruned blocks too
+	block, err := fetchBlockFromDB(blockNumber)
+	if err != nil {
+		return nil, 0, err
+	}
+	txResults := block.GetNonHashData().GetTransactionResults()
+	if resultIndex >= uint64(len(txResults)) {
+		return nil, 0, fmt.Errorf("Result of transaction [%s] not found in block [%d]", txUUID, blockNumber)
+	}
+	return txResults[resultIndex], blockNumber, nil
+}
+
+// getStoredTransactionResults returns the results without the chaincode
+// events, including those of the invoked chaincodes, which are stored
+// separately
+func getStoredTransactionResults(transactionResults []*protos.TransactionResult) []*protos.TransactionResult {
+	storedResults := make([]*protos.TransactionResult, len(transactionResults))
+	for i, txResult := range transactionResults {
+		storedResult := *txResult
+		storedResult.ChaincodeEvents = nil
+		storedResult.Invocations = nil
+		for _, invocation := range txResult.Invocations {
+			storedInvocation := *invocation
+			storedInvocation.ChaincodeEvents = nil
+			storedResult.Invocations = append(storedResult.Invocations, &storedInvocation)
+		}
+		storedResults[i] = &storedResult
+	}
+	return storedResults
+}
@@ -0,0 +1,245 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+
+	"google/protobuf"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// A single validator may lie about the outcome of a transaction. Up to f of
+// the validators may be faulty, so an outcome reported by f+1 of them is
+// reported by at least one correct validator. f follows the current validator
+// set: a validating peer takes it from its consensus plugin, a non-validating
+// peer asks the validators for it along with the status of the transaction.
+
+// consensusStatusReporter is implemented by the engine of a validating peer
+type consensusStatusReporter interface {
+	GetConsensusStatus() (*pb.ConsensusStatus, error)
+}
+
+// transactionOutcome is the part of a transaction status that validators
+// must agree on
+type transactionOutcome struct {
+	status      pb.TransactionStatus_StatusCode
+	blockNumber uint64
+	blockHash   string
+	errorCode   uint32
+	err         string
+}
+
+func getTransactionOutcome(status *pb.TransactionStatus) transactionOutcome {
+	return transactionOutcome{
+		status:      status.Status,
+		blockNumber: status.BlockNumber,
+		blockHash:   string(status.BlockHash),
+		errorCode:   status.ErrorCode,
+		err:         status.Error,
+	}
+}
+
+// confirmTransactionStatus returns the status reported by at least f+1 of the
+// replies, with the number of matching replies. A transaction committed
+// according to f+1 replies is CONFIRMED. Only committed or failed transactions
+// are confirmed, nil is returned if no such outcome reaches the quorum.
+func confirmTransactionStatus(replies []*pb.TransactionStatus, f int) *pb.TransactionStatus {
+	counts := make(map[transactionOutcome]int)
+	for _, reply := range replies {
+		if reply == nil || (reply.Status != pb.TransactionStatus_COMMITTED && reply.Status != pb.TransactionStatus_ERROR) {
+			continue
+		}
+		outcome := getTransactionOutcome(reply)
+		counts[outcome]++
+		if counts[outcome] < f+1 {
+			continue
+		}
+		status := &pb.TransactionStatus{
+			Uuid:          reply.Uuid,
+			Status:        reply.Status,
+			BlockNumber:   reply.BlockNumber,
+			BlockHash:     reply.BlockHash,
+			ErrorCode:     reply.ErrorCode,
+			Error:         reply.Error,
+			Confirmations: uint32(counts[outcome]),
+		}
+		if status.Status == pb.TransactionStatus_COMMITTED {
+			status.Status = pb.TransactionStatus_CONFIRMED
+		}
+		return status
+	}
+	return nil
+}
+
+// getMaxFaults returns the number of faulty validators tolerated by the
+// validator set, as reported by the consensus plugins of the validators. A
+// faulty validator may report a lower f to have its own reply confirmed, the
+// highest f reported is used. ok is false if no validator reported it.
+func getMaxFaults(statuses []*pb.ConsensusStatus) (f int, ok bool) {
+	for _, status := range statuses {
+		if status == nil {
+			continue
+		}
+		if !ok || int(status.F) > f {
+			f = int(status.F)
+		}
+		ok = true
+	}
+	return f, ok
+}
+
+// getValidatorAddresses returns the addresses of the connected validators
+func (p *PeerImpl) getValidatorAddresses() ([]string, error) {
+	peersMsg, err := p.GetPeers()
+	if err != nil {
+		return nil, err
+	}
+	var addresses []string
+	for _, peerEndpoint := range peersMsg.Peers {
+		if peerEndpoint.Type == pb.PeerEndpoint_VALIDATOR {
+			addresses = append(addresses, peerEndpoint.Address)
+		}
+	}
+	return addresses, nil
+}
+
+// queryTransactionStatus asks the peer at peerAddress for the status of the
+// transaction
+func queryTransactionStatus(ctx context.Context, peerAddress string, txUUID string) (*pb.TransactionStatus, error) {
+	conn, err := NewPeerClientConnectionWithAddress(peerAddress)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating client to peer address=%s: %s", peerAddress, err)
+	}
+	defer conn.Close()
+	return pb.NewOpenchainClient(conn).GetTransactionStatus(ctx, &pb.TransactionUUID{Uuid: txUUID})
+}
+
+// queryConsensusStatus asks the validator at peerAddress for the status of its
+// consensus plugin
+func queryConsensusStatus(ctx context.Context, peerAddress string) (*pb.ConsensusStatus, error) {
+	conn, err := NewPeerClientConnectionWithAddress(peerAddress)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating client to peer address=%s: %s", peerAddress, err)
+	}
+	defer conn.Close()
+	return pb.NewAdminClient(conn).GetConsensusStatus(ctx, &google_protobuf.Empty{})
+}
+
+// GetConfirmedTransactionStatus returns the status of the transaction as
+// reported by the validators. It is CONFIRMED, or ERROR, once f+1 validators
+// report the same block for it, f being the number of faulty validators the
+// current validator set tolerates. Otherwise the status known to this peer is
+// returned.
+func (p *PeerImpl) GetConfirmedTransactionStatus(txUUID string) (*pb.TransactionStatus, error) {
+	p.ledgerWrapper.RLock()
+	localStatus, err := p.ledgerWrapper.ledger.GetTransactionStatus(txUUID)
+	p.ledgerWrapper.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	addresses, err := p.getValidatorAddresses()
+	if err != nil {
+		return nil, err
+	}
+
+	// A validating peer trusts its own consensus plugin for the validator set
+	var statuses []*pb.ConsensusStatus
+	if reporter, ok := p.engine.(consensusStatusReporter); ok {
+		status, err := reporter.GetConsensusStatus()
+		if err != nil {
+			peerLogger.Warningf("Error retrieving the local consensus status, asking the validators: %s", err)
+		} else {
+			statuses = append(statuses, status)
+		}
+	}
+	askValidators := len(statuses) == 0
+
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("peer.confirmation.timeout"))
+	defer cancel()
+	type validatorReply struct {
+		status    *pb.TransactionStatus
+		consensus *pb.ConsensusStatus
+	}
+	repliesChan := make(chan validatorReply, len(addresses))
+	for _, address := range addresses {
+		go func(address string) {
+			var reply validatorReply
+			var err error
+			if reply.status, err = queryTransactionStatus(ctx, address, txUUID); err != nil {
+				peerLogger.Warningf("Error retrieving status of transaction %s from validator %s: %s", txUUID, address, err)
+			}
+			if askValidators {
+				if reply.consensus, err = queryConsensusStatus(ctx, address); err != nil {
+					peerLogger.Warningf("Error retrieving consensus status from validator %s: %s", address, err)
+				}
+			}
+			repliesChan <- reply
+		}(address)
+	}
+
+	replies := []*pb.TransactionStatus{}
+	if p.isValidator {
+		replies = append(replies, localStatus)
+	}
+	for range addresses {
+		reply := <-repliesChan
+		replies = append(replies, reply.status)
+		statuses = append(statuses, reply.consensus)
+	}
+
+	f, ok := getMaxFaults(statuses)
+	if !ok {
+		peerLogger.Warningf("No validator reported the validator set, returning local status %s of transaction %s", localStatus.Status, txUUID)
+		return localStatus, nil
+	}
+	if status := confirmTransactionStatus(replies, f); status != nil {
+		return status, nil
+	}
+	peerLogger.Debugf("Transaction %s is not confirmed by %d validators, returning local status %s", txUUID, f+1, localStatus.Status)
+	return localStatus, nil
+}
+
+// forwardTransaction sends the transaction to a connected validator, trying
+// the others if it is not accepted
+func (p *PeerImpl) forwardTransaction(transaction *pb.Transaction) *pb.Response {
+	addresses, err := p.getValidatorAddresses()
+	if err != nil {
+		peerLogger.Warningf("Error getting the connected validators: %s", err)
+	}
+	if len(addresses) == 0 {
+		addresses = p.discHelper.GetRandomNodes(1)
+	}
+	if len(addresses) == 0 {
+		return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte("No validator to send the transaction to")}
+	}
+
+	var response *pb.Response
+	for _, address := range addresses {
+		response = p.SendTransactionsToPeer(address, transaction)
+		if response.Status == pb.Response_SUCCESS {
+			break
+		}
+		peerLogger.Warningf("Validator %s did not accept transaction %s: %s", address, transaction.Uuid, string(response.Msg))
+	}
+	return response
+}
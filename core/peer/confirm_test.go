@@ -0,0 +1,79 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"testing"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func committedStatus(blockNumber uint64, blockHash string) *pb.TransactionStatus {
+	return &pb.TransactionStatus{Uuid: "tx", Status: pb.TransactionStatus_COMMITTED, BlockNumber: blockNumber, BlockHash: []byte(blockHash)}
+}
+
+func TestConfirmTransactionStatusQuorum(t *testing.T) {
+	replies := []*pb.TransactionStatus{
+		committedStatus(3, "hash"),
+		nil,
+		committedStatus(3, "hash"),
+	}
+	status := confirmTransactionStatus(replies, 1)
+	if status == nil {
+		t.Fatal("Expected the transaction to be confirmed by 2 matching replies")
+	}
+	if status.Status != pb.TransactionStatus_CONFIRMED || status.BlockNumber != 3 || string(status.BlockHash) != "hash" || status.Confirmations != 2 {
+		t.Fatalf("Unexpected confirmed status: %v", status)
+	}
+}
+
+func TestConfirmTransactionStatusMismatch(t *testing.T) {
+	replies := []*pb.TransactionStatus{
+		committedStatus(3, "hash"),
+		committedStatus(3, "forged"),
+		committedStatus(4, "hash"),
+		{Uuid: "tx", Status: pb.TransactionStatus_PENDING},
+		{Uuid: "tx", Status: pb.TransactionStatus_PENDING},
+	}
+	if status := confirmTransactionStatus(replies, 1); status != nil {
+		t.Fatalf("Expected no status to be confirmed by mismatching replies, got %v", status)
+	}
+}
+
+func TestConfirmTransactionStatusError(t *testing.T) {
+	failed := &pb.TransactionStatus{Uuid: "tx", Status: pb.TransactionStatus_ERROR, BlockNumber: 3, BlockHash: []byte("hash"), ErrorCode: 1, Error: "failed"}
+	replies := []*pb.TransactionStatus{failed, committedStatus(3, "hash"), failed, failed}
+	status := confirmTransactionStatus(replies, 2)
+	if status == nil {
+		t.Fatal("Expected the failure to be confirmed by 3 matching replies")
+	}
+	if status.Status != pb.TransactionStatus_ERROR || status.Error != "failed" || status.Confirmations != 3 {
+		t.Fatalf("Unexpected confirmed status: %v", status)
+	}
+}
+
+func TestGetMaxFaults(t *testing.T) {
+	if _, ok := getMaxFaults([]*pb.ConsensusStatus{nil, nil}); ok {
+		t.Fatal("Expected f to be unknown when no validator reported its consensus status")
+	}
+
+	// A validator lying about the validator set cannot lower f
+	statuses := []*pb.ConsensusStatus{{F: 1}, nil, {F: 0}, {F: 2}, {F: 1}}
+	if f, ok := getMaxFaults(statuses); !ok || f != 2 {
+		t.Fatalf("Expected f to be the highest reported, 2, got %d", f)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/looplab/fsm"
 	"github.com/spf13/viper"
 
+	"github.com/hyperledger/fabric/core/crypto"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	pb "github.com/hyperledger/fabric/protos"
 )
@@ -162,6 +163,12 @@ func (d *Handler) beforeHello(e *fsm.Event) {
 			return
 		}
 		peerLogger.Debugf("Verified signature for %s", e.Event)
+
+		// Both peers must allow the crypto suite of the other
+		if err := crypto.CheckCryptoSuites(helloMessage.CryptoSuite, helloMessage.AllowedCryptoSuites); err != nil {
+			e.Cancel(fmt.Errorf("Error negotiating crypto suite with peer %s: %s", helloMessage.PeerEndpoint.ID, err))
+			return
+		}
 	}
 
 	if d.initiatedStream == false {
@@ -120,6 +120,7 @@ type MessageHandlerCoordinator interface {
 	GetRemoteLedger(receiver *pb.PeerID) (RemoteLedger, error)
 	PeersDiscovered(*pb.PeersMessage) error
 	ExecuteTransaction(transaction *pb.Transaction) *pb.Response
+	GetConfirmedTransactionStatus(txUUID string) (*pb.TransactionStatus, error)
 	Discoverer
 }
 
@@ -626,8 +627,12 @@ func (p *PeerImpl) ExecuteTransaction(transaction *pb.Transaction) (response *pb
 	if p.isValidator {
 		response = p.sendTransactionsToLocalEngine(transaction)
 	} else {
-		peerAddresses := p.discHelper.GetRandomNodes(1)
-		response = p.SendTransactionsToPeer(peerAddresses[0], transaction)
+		response = p.forwardTransaction(transaction)
+	}
+	if response.Status == pb.Response_SUCCESS && transaction.Type != pb.Transaction_CHAINCODE_QUERY {
+		p.ledgerWrapper.RLock()
+		p.ledgerWrapper.ledger.AddPendingTransaction(transaction.Uuid)
+		p.ledgerWrapper.RUnlock()
 	}
 	return response
 }
@@ -654,7 +659,12 @@ func (p *PeerImpl) newHelloMessage() (*pb.HelloMessage, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Error creating hello message, error getting block chain info: %s", err)
 	}
-	return &pb.HelloMessage{PeerEndpoint: endpoint, BlockchainInfo: blockChainInfo}, nil
+	helloMessage := &pb.HelloMessage{PeerEndpoint: endpoint, BlockchainInfo: blockChainInfo}
+	if SecurityEnabled() {
+		helloMessage.CryptoSuite = crypto.GetCryptoSuiteName()
+		helloMessage.AllowedCryptoSuites = crypto.GetAllowedCryptoSuites()
+	}
+	return helloMessage, nil
 }
 
 // GetBlockByNumber return a block by block number
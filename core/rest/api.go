@@ -91,6 +91,8 @@ func (s *ServerOpenchain) GetBlockByNumber(ctx context.Context, num *pb.BlockNum
 		switch err {
 		case ledger.ErrOutOfBounds:
 			return nil, ErrNotFound
+		case ledger.ErrBlockPruned:
+			return nil, err
 		default:
 			return nil, fmt.Errorf("Error retrieving block from blockchain: %s", err)
 		}
@@ -102,7 +104,7 @@ func (s *ServerOpenchain) GetBlockByNumber(ctx context.Context, num *pb.BlockNum
 	// individual transaction.
 	blockTransactions := block.GetTransactions()
 	for _, transaction := range blockTransactions {
-		if transaction.Type == pb.Transaction_CHAINCODE_DEPLOY {
+		if transaction.Type == pb.Transaction_CHAINCODE_DEPLOY || transaction.Type == pb.Transaction_CHAINCODE_UPGRADE {
 			deploymentSpec := &pb.ChaincodeDeploymentSpec{}
 			err := proto.Unmarshal(transaction.Payload, deploymentSpec)
 			if err != nil {
@@ -148,6 +150,26 @@ func (s *ServerOpenchain) GetState(ctx context.Context, chaincodeID, key string)
 	return s.ledger.GetState(chaincodeID, key, true)
 }
 
+// GetHistoryForKey returns the modifications of a particular chaincode ID and
+// key, oldest first
+func (s *ServerOpenchain) GetHistoryForKey(ctx context.Context, chaincodeID, key string) (*pb.KeyHistory, error) {
+	modifications, err := s.ledger.GetHistoryForKey(chaincodeID, key)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.KeyHistory{Modifications: modifications}, nil
+}
+
+// GetStateAsOfBlock returns the value for a particular chaincode ID and key as
+// of the given block
+func (s *ServerOpenchain) GetStateAsOfBlock(ctx context.Context, chaincodeID, key string, blockNumber uint64) ([]byte, error) {
+	value, err := s.ledger.GetStateAsOfBlock(chaincodeID, key, blockNumber)
+	if err == ledger.ErrOutOfBounds {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
 // GetTransactionByUUID returns a transaction matching the specified UUID
 func (s *ServerOpenchain) GetTransactionByUUID(ctx context.Context, txUUID string) (*pb.Transaction, error) {
 	transaction, err := s.ledger.GetTransactionByUUID(txUUID)
@@ -162,6 +184,31 @@ func (s *ServerOpenchain) GetTransactionByUUID(ctx context.Context, txUUID strin
 	return transaction, nil
 }
 
+// GetTransactionStatus returns whether the transaction is pending, committed or
+// failed
+func (s *ServerOpenchain) GetTransactionStatus(ctx context.Context, txUUID *pb.TransactionUUID) (*pb.TransactionStatus, error) {
+	status, err := s.ledger.GetTransactionStatus(txUUID.Uuid)
+	if err != nil {
+		return nil, fmt.Errorf("Error retrieving transaction status: %s", err)
+	}
+	return status, nil
+}
+
+// GetTransactionResult returns the result of the transaction, i.e. the
+// payload returned by the chaincode or the error if it failed
+func (s *ServerOpenchain) GetTransactionResult(ctx context.Context, txUUID *pb.TransactionUUID) (*pb.TransactionResult, error) {
+	result, err := s.ledger.GetTransactionResult(txUUID.Uuid)
+	if err != nil {
+		switch err {
+		case ledger.ErrResourceNotFound:
+			return nil, ErrNotFound
+		default:
+			return nil, fmt.Errorf("Error retrieving transaction result: %s", err)
+		}
+	}
+	return result, nil
+}
+
 // GetPeers returns a list of all peer nodes currently connected to the target peer.
 func (s *ServerOpenchain) GetPeers(ctx context.Context, e *google_protobuf.Empty) (*pb.PeersMessage, error) {
 	return s.peerInfo.GetPeers()
@@ -42,6 +42,7 @@ import (
 	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/crypto"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/ledger"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -49,17 +50,20 @@ var restLogger = logging.MustGetLogger("rest")
 
 // serverOpenchain is a variable that holds the pointer to the
 // underlying ServerOpenchain object. serverDevops is a variable that holds
-// the pointer to the underlying Devops object. This is necessary due to
-// how the gocraft/web package implements context initialization.
+// the pointer to the underlying Devops object. serverAdmin holds the pointer
+// to the underlying Admin object. This is necessary due to how the
+// gocraft/web package implements context initialization.
 var serverOpenchain *ServerOpenchain
 var serverDevops pb.DevopsServer
+var serverAdmin pb.AdminServer
 
 // ServerOpenchainREST defines the Openchain REST service object. It exposes
-// the methods available on the ServerOpenchain service and the Devops service
-// through a REST API.
+// the methods available on the ServerOpenchain service, the Devops service
+// and the Admin service through a REST API.
 type ServerOpenchainREST struct {
 	server *ServerOpenchain
 	devops pb.DevopsServer
+	admin  pb.AdminServer
 }
 
 // restResult defines the response payload for a general REST interface request.
@@ -68,6 +72,19 @@ type restResult struct {
 	Error string `json:",omitempty"`
 }
 
+// transactionResult defines the response payload for the GetTransactionByUUID
+// REST interface request, the transaction with its result if the peer has it.
+type transactionResult struct {
+	*pb.Transaction
+	Result *pb.TransactionResult `json:"result,omitempty"`
+}
+
+// stateResult defines the response payload for the GetStateAsOfBlock REST
+// interface request.
+type stateResult struct {
+	Value []byte `json:"value"`
+}
+
 // tcertsResult defines the response payload for the GetTransactionCert REST
 // interface request.
 type tcertsResult struct {
@@ -154,6 +171,8 @@ var (
 	ChaincodeDeployError     = &rpcError{Code: -32001, Message: "Deployment failure", Data: "Chaincode deployment has failed."}
 	ChaincodeInvokeError     = &rpcError{Code: -32002, Message: "Invocation failure", Data: "Chaincode invocation has failed."}
 	ChaincodeQueryError      = &rpcError{Code: -32003, Message: "Query failure", Data: "Chaincode query has failed."}
+	ChaincodeUpgradeError    = &rpcError{Code: -32004, Message: "Upgrade failure", Data: "Chaincode upgrade has failed."}
+	ChaincodeTerminateError  = &rpcError{Code: -32005, Message: "Termination failure", Data: "Chaincode termination has failed."}
 )
 
 // SetOpenchainServer is a middleware function that sets the pointer to the
@@ -161,6 +180,7 @@ var (
 func (s *ServerOpenchainREST) SetOpenchainServer(rw web.ResponseWriter, req *web.Request, next web.NextMiddlewareFunc) {
 	s.server = serverOpenchain
 	s.devops = serverDevops
+	s.admin = serverAdmin
 
 	next(rw, req)
 }
@@ -678,6 +698,12 @@ func (s *ServerOpenchainREST) GetBlockByNumber(rw web.ResponseWriter, req *web.R
 		return
 	}
 
+	if err == ledger.ErrBlockPruned {
+		rw.WriteHeader(http.StatusGone)
+		encoder.Encode(restResult{Error: err.Error()})
+		return
+	}
+
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		encoder.Encode(restResult{Error: err.Error()})
@@ -711,13 +737,129 @@ func (s *ServerOpenchainREST) GetTransactionByUUID(rw web.ResponseWriter, req *w
 			restLogger.Errorf("Error retrieving transaction %s: %s", txUUID, err)
 		}
 	} else {
+		// Add the result of the transaction, which blocks committed by older
+		// peers do not have
+		result, err := s.server.GetTransactionResult(context.Background(), &pb.TransactionUUID{Uuid: txUUID})
+		if err != nil && err != ErrNotFound {
+			restLogger.Errorf("Error retrieving result of transaction %s: %s", txUUID, err)
+		}
+
 		// Return existing transaction
 		rw.WriteHeader(http.StatusOK)
-		encoder.Encode(tx)
+		encoder.Encode(transactionResult{tx, result})
 		restLogger.Infof("Successfully retrieved transaction: %s", txUUID)
 	}
 }
 
+// GetTransactionStatus returns whether the transaction matching the specified
+// UUID is pending, committed or failed
+func (s *ServerOpenchainREST) GetTransactionStatus(rw web.ResponseWriter, req *web.Request) {
+	// Parse out the transaction UUID
+	txUUID := req.PathParams["uuid"]
+
+	// Retrieve the status of the transaction
+	status, err := s.server.GetTransactionStatus(context.Background(), &pb.TransactionUUID{Uuid: txUUID})
+
+	encodeTransactionStatus(rw, txUUID, status, err)
+}
+
+// GetConfirmedTransactionStatus returns the status of the transaction matching
+// the specified UUID as reported by the validators, CONFIRMED once enough of
+// them report the same block for it
+func (s *ServerOpenchainREST) GetConfirmedTransactionStatus(rw web.ResponseWriter, req *web.Request) {
+	// Parse out the transaction UUID
+	txUUID := req.PathParams["uuid"]
+
+	// Ask the validators for the status of the transaction
+	status, err := s.devops.GetTransactionStatus(context.Background(), &pb.TransactionUUID{Uuid: txUUID})
+
+	encodeTransactionStatus(rw, txUUID, status, err)
+}
+
+func encodeTransactionStatus(rw web.ResponseWriter, txUUID string, status *pb.TransactionStatus, err error) {
+	encoder := json.NewEncoder(rw)
+
+	// Check for Error
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		encoder.Encode(restResult{Error: fmt.Sprintf("Error retrieving status of transaction %s: %s.", txUUID, err)})
+		restLogger.Errorf("Error retrieving status of transaction %s: %s", txUUID, err)
+		return
+	}
+	if status.Status == pb.TransactionStatus_UNKNOWN {
+		rw.WriteHeader(http.StatusNotFound)
+		encoder.Encode(restResult{Error: fmt.Sprintf("Transaction %s is not found.", txUUID)})
+		return
+	}
+
+	// Success
+	rw.WriteHeader(http.StatusOK)
+	encoder.Encode(status)
+}
+
+// GetHistoryForKey returns the modifications of a key of a chaincode, oldest
+// first, each with the block number and UUID of the transaction that made it.
+func (s *ServerOpenchainREST) GetHistoryForKey(rw web.ResponseWriter, req *web.Request) {
+	chaincodeID := req.PathParams["chaincodeID"]
+	key := req.PathParams["key"]
+
+	// Retrieve the history of the key
+	history, err := s.server.GetHistoryForKey(context.Background(), chaincodeID, key)
+
+	encoder := json.NewEncoder(rw)
+
+	// Check for Error
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		encoder.Encode(restResult{Error: fmt.Sprintf("Error retrieving history of key %s: %s.", key, err)})
+		restLogger.Errorf("Error retrieving history of key %s: %s", key, err)
+		return
+	}
+
+	// Success
+	rw.WriteHeader(http.StatusOK)
+	encoder.Encode(history)
+}
+
+// GetStateAsOfBlock returns the value a key of a chaincode held once the given
+// block was committed.
+func (s *ServerOpenchainREST) GetStateAsOfBlock(rw web.ResponseWriter, req *web.Request) {
+	chaincodeID := req.PathParams["chaincodeID"]
+	key := req.PathParams["key"]
+
+	// Parse out the Block id
+	blockNumber, err := strconv.ParseUint(req.PathParams["block"], 10, 64)
+
+	encoder := json.NewEncoder(rw)
+
+	// Check for proper Block id syntax
+	if err != nil {
+		// Failure
+		rw.WriteHeader(http.StatusBadRequest)
+		encoder.Encode(restResult{Error: "Block id must be an integer (uint64)."})
+		return
+	}
+
+	// Retrieve the value of the key as of the block
+	value, err := s.server.GetStateAsOfBlock(context.Background(), chaincodeID, key, blockNumber)
+
+	if (err == ErrNotFound) || (err == nil && value == nil) {
+		rw.WriteHeader(http.StatusNotFound)
+		encoder.Encode(restResult{Error: ErrNotFound.Error()})
+		return
+	}
+
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		encoder.Encode(restResult{Error: err.Error()})
+		return
+	}
+
+	// Success
+	rw.WriteHeader(http.StatusOK)
+	encoder.Encode(stateResult{Value: value})
+}
+
 // Deploy first builds the chaincode package and subsequently deploys it to the
 // blockchain.
 //
@@ -1163,7 +1305,8 @@ func (s *ServerOpenchainREST) Query(rw web.ResponseWriter, req *web.Request) {
 	}
 }
 
-// ProcessChaincode implements JSON RPC 2.0 specification for chaincode deploy, invoke, and query.
+// ProcessChaincode implements JSON RPC 2.0 specification for chaincode deploy, upgrade, invoke, query,
+// and terminate.
 func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.Request) {
 	restLogger.Info("REST processing chaincode request...")
 
@@ -1244,7 +1387,7 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 		return
 	}
 
-	// Insure that the JSON method string is present and is either deploy, invoke or query
+	// Insure that the JSON method string is present and is one of deploy, upgrade, invoke, query or terminate
 	if requestPayload.Method == nil {
 		// If the request is not a notification, produce a response.
 		if !notification {
@@ -1256,7 +1399,8 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 		restLogger.Error("Missing JSON RPC 2.0 method string.")
 
 		return
-	} else if (*(requestPayload.Method) != "deploy") && (*(requestPayload.Method) != "invoke") && (*(requestPayload.Method) != "query") {
+	} else if (*(requestPayload.Method) != "deploy") && (*(requestPayload.Method) != "upgrade") && (*(requestPayload.Method) != "invoke") &&
+		(*(requestPayload.Method) != "query") && (*(requestPayload.Method) != "terminate") {
 		// If the request is not a notification, produce a response.
 		if !notification {
 			// Format the error appropriately and produce JSON RPC 2.0 response
@@ -1276,10 +1420,10 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 	// Variable that will hold the execution result
 	var result rpcResult
 
-	if *(requestPayload.Method) == "deploy" {
+	if (*(requestPayload.Method) == "deploy") || (*(requestPayload.Method) == "upgrade") {
 
 		//
-		// Chaincode deployment was requested
+		// Chaincode deployment/upgrade was requested
 		//
 
 		// Payload params field must contain a ChaincodeSpec message
@@ -1287,11 +1431,11 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 			// If the request is not a notification, produce a response.
 			if !notification {
 				// Format the error appropriately and produce JSON RPC 2.0 response
-				errObj := formatRPCError(InvalidParams.Code, InvalidParams.Message, "Client must supply ChaincodeSpec for chaincode deploy request.")
+				errObj := formatRPCError(InvalidParams.Code, InvalidParams.Message, fmt.Sprintf("Client must supply ChaincodeSpec for chaincode %s request.", *(requestPayload.Method)))
 				rw.WriteHeader(http.StatusBadRequest)
 				encoder.Encode(formatRPCResponse(errObj, requestPayload.ID))
 			}
-			restLogger.Error("Client must supply ChaincodeSpec for chaincode deploy request.")
+			restLogger.Errorf("Client must supply ChaincodeSpec for chaincode %s request.", *(requestPayload.Method))
 
 			return
 		}
@@ -1299,15 +1443,15 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 		// Extract the ChaincodeSpec from the params field
 		deploySpec := requestPayload.Params
 
-		// Process the chaincode deployment request and record the result
-		result = s.processChaincodeDeploy(deploySpec)
+		// Process the chaincode deployment/upgrade request and record the result
+		result = s.processChaincodeDeployOrUpgrade(*(requestPayload.Method), deploySpec)
 	} else {
 
 		//
-		// Chaincode invocation/query was reqested
+		// Chaincode invocation/query/termination was reqested
 		//
 
-		// Because chaincode invocation/query requests require a ChaincodeInvocationSpec
+		// Because chaincode invocation/query/termination requests require a ChaincodeInvocationSpec
 		// message instead of a ChaincodeSpec message, we must initialize it here
 		// before  proceeding.
 		invokequeryPayload := &pb.ChaincodeInvocationSpec{ChaincodeSpec: requestPayload.Params}
@@ -1321,12 +1465,12 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 				rw.WriteHeader(http.StatusBadRequest)
 				encoder.Encode(formatRPCResponse(errObj, requestPayload.ID))
 			}
-			restLogger.Error("Client must supply ChaincodeSpec for chaincode invoke or query request.")
+			restLogger.Error("Client must supply ChaincodeSpec for chaincode invoke, query or terminate request.")
 
 			return
 		}
 
-		// Process the chaincode invoke/query request and record the result
+		// Process the chaincode invoke/query/terminate request and record the result
 		result = s.processChaincodeInvokeOrQuery(*(requestPayload.Method), invokequeryPayload)
 	}
 
@@ -1353,9 +1497,9 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 	return
 }
 
-// processChaincodeDeploy triggers chaincode deploy and returns a result or an error
-func (s *ServerOpenchainREST) processChaincodeDeploy(spec *pb.ChaincodeSpec) rpcResult {
-	restLogger.Info("REST deploying chaincode...")
+// processChaincodeDeployOrUpgrade triggers chaincode deploy or upgrade and returns a result or an error
+func (s *ServerOpenchainREST) processChaincodeDeployOrUpgrade(method string, spec *pb.ChaincodeSpec) rpcResult {
+	restLogger.Infof("REST %s chaincode...", method)
 
 	// Check that the ChaincodeID is not nil.
 	if spec.ChaincodeID == nil {
@@ -1366,6 +1510,15 @@ func (s *ServerOpenchainREST) processChaincodeDeploy(spec *pb.ChaincodeSpec) rpc
 		return error
 	}
 
+	// An upgrade always targets an existing chaincode by name.
+	if (method == "upgrade") && (spec.ChaincodeID.Name == "") {
+		// Format the error appropriately for further processing
+		error := formatRPCError(InvalidParams.Code, InvalidParams.Message, "Chaincode name may not be blank.")
+		restLogger.Error("Chaincode name may not be blank.")
+
+		return error
+	}
+
 	// If the peer is running in development mode, confirm that the Chaincode name
 	// is not left blank. If the peer is running in production mode, confirm that
 	// the Chaincode path is not left blank. This is necessary as in development
@@ -1468,8 +1621,35 @@ func (s *ServerOpenchainREST) processChaincodeDeploy(spec *pb.ChaincodeSpec) rpc
 	}
 
 	//
-	// Trigger the chaincode deployment through the devops service
+	// Trigger the chaincode deployment or upgrade through the devops service
 	//
+
+	if method == "upgrade" {
+		chaincodeDeploymentSpec, err := s.devops.Upgrade(context.Background(), spec)
+
+		//
+		// Upgrade failed
+		//
+
+		if err != nil {
+			// Format the error appropriately for further processing
+			error := formatRPCError(ChaincodeUpgradeError.Code, ChaincodeUpgradeError.Message, fmt.Sprintf("Error when upgrading chaincode: %s", err))
+			restLogger.Errorf("Error when upgrading chaincode: %s", err)
+
+			return error
+		}
+
+		//
+		// Upgrade succeeded, the chaincode keeps its name
+		//
+
+		chainID := chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name
+		result := formatRPCOK(chainID)
+		restLogger.Infof("Successfully upgraded chainCode: %s", chainID)
+
+		return result
+	}
+
 	chaincodeDeploymentSpec, err := s.devops.Deploy(context.Background(), spec)
 
 	//
@@ -1501,7 +1681,7 @@ func (s *ServerOpenchainREST) processChaincodeDeploy(spec *pb.ChaincodeSpec) rpc
 	return result
 }
 
-// processChaincodeInvokeOrQuery triggers chaincode invoke or query and returns a result or an error
+// processChaincodeInvokeOrQuery triggers chaincode invoke, query or terminate and returns a result or an error
 func (s *ServerOpenchainREST) processChaincodeInvokeOrQuery(method string, spec *pb.ChaincodeInvocationSpec) rpcResult {
 	restLogger.Infof("REST %s chaincode...", method)
 
@@ -1523,8 +1703,8 @@ func (s *ServerOpenchainREST) processChaincodeInvokeOrQuery(method string, spec
 		return error
 	}
 
-	// Check that the CtorMsg is not left blank.
-	if (spec.ChaincodeSpec.CtorMsg == nil) || (spec.ChaincodeSpec.CtorMsg.Function == "") {
+	// Check that the CtorMsg is not left blank. Terminating a chaincode does not call it.
+	if (method != "terminate") && ((spec.ChaincodeSpec.CtorMsg == nil) || (spec.ChaincodeSpec.CtorMsg.Function == "")) {
 		// Format the error appropriately for further processing
 		error := formatRPCError(InvalidParams.Code, InvalidParams.Message, "Payload must contain a CtorMsg with a Chaincode function name.")
 		restLogger.Error("Payload must contain a CtorMsg with a Chaincode function name.")
@@ -1668,6 +1848,41 @@ func (s *ServerOpenchainREST) processChaincodeInvokeOrQuery(method string, spec
 		restLogger.Infof("Successfully queried chaincode: %s", val)
 	}
 
+	if method == "terminate" {
+
+		//
+		// Trigger the chaincode termination through the devops service
+		//
+
+		resp, err := s.devops.Terminate(context.Background(), spec)
+
+		//
+		// Termination failed
+		//
+
+		if err != nil {
+			// Format the error appropriately for further processing
+			error := formatRPCError(ChaincodeTerminateError.Code, ChaincodeTerminateError.Message, fmt.Sprintf("Error when terminating chaincode: %s", err))
+			restLogger.Errorf("Error when terminating chaincode: %s", err)
+
+			return error
+		}
+
+		//
+		// Termination succeeded
+		//
+
+		// Clients will need the txuuid in order to track the termination, record it
+		txuuid := string(resp.Msg)
+
+		//
+		// Output correctly formatted response
+		//
+
+		result = formatRPCOK(txuuid)
+		restLogger.Infof("Successfully submitted terminate transaction with txuuid (%s)", txuuid)
+	}
+
 	return result
 }
 
@@ -1709,6 +1924,27 @@ func (s *ServerOpenchainREST) GetPeers(rw web.ResponseWriter, req *web.Request)
 	}
 }
 
+// GetConsensusStatus returns the state and metrics of the consensus plugin of
+// the target validating peer.
+func (s *ServerOpenchainREST) GetConsensusStatus(rw web.ResponseWriter, req *web.Request) {
+	status, err := s.admin.GetConsensusStatus(context.Background(), &google_protobuf.Empty{})
+
+	encoder := json.NewEncoder(rw)
+
+	// Check for error
+	if err != nil {
+		// Failure
+		rw.WriteHeader(http.StatusBadRequest)
+		encoder.Encode(restResult{Error: err.Error()})
+		restLogger.Errorf("Error: Querying consensus status -- %s", err)
+		return
+	}
+
+	// Success
+	rw.WriteHeader(http.StatusOK)
+	encoder.Encode(status)
+}
+
 // NotFound returns a custom landing page when a given hyperledger end point
 // had not been defined.
 func (s *ServerOpenchainREST) NotFound(rw web.ResponseWriter, r *web.Request) {
@@ -1742,8 +1978,14 @@ func buildOpenchainRESTRouter() *web.Router {
 	router.Post("/chaincode", (*ServerOpenchainREST).ProcessChaincode)
 
 	router.Get("/transactions/:uuid", (*ServerOpenchainREST).GetTransactionByUUID)
+	router.Get("/transactions/:uuid/status", (*ServerOpenchainREST).GetTransactionStatus)
+	router.Get("/transactions/:uuid/confirmation", (*ServerOpenchainREST).GetConfirmedTransactionStatus)
+
+	router.Get("/history/:chaincodeID/:key", (*ServerOpenchainREST).GetHistoryForKey)
+	router.Get("/history/:chaincodeID/:key/blocks/:block", (*ServerOpenchainREST).GetStateAsOfBlock)
 
 	router.Get("/network/peers", (*ServerOpenchainREST).GetPeers)
+	router.Get("/network/consensus", (*ServerOpenchainREST).GetConsensusStatus)
 
 	// Add not found page
 	router.NotFound((*ServerOpenchainREST).NotFound)
@@ -1753,13 +1995,14 @@ func buildOpenchainRESTRouter() *web.Router {
 
 // StartOpenchainRESTServer initializes the REST service and adds the required
 // middleware and routes.
-func StartOpenchainRESTServer(server *ServerOpenchain, devops *core.Devops) {
+func StartOpenchainRESTServer(server *ServerOpenchain, devops *core.Devops, admin *core.ServerAdmin) {
 	// Initialize the REST service object
 	restLogger.Infof("Initializing the REST service on %s, TLS is %s.", viper.GetString("rest.address"), (map[bool]string{true: "enabled", false: "disabled"})[comm.TLSEnabled()])
 
-	// Record the pointer to the underlying ServerOpenchain and Devops objects.
+	// Record the pointer to the underlying ServerOpenchain, Devops and Admin objects.
 	serverOpenchain = server
 	serverDevops = devops
+	serverAdmin = admin
 
 	router := buildOpenchainRESTRouter()
 
@@ -29,6 +29,7 @@ import (
 
 	"golang.org/x/net/context"
 
+	"github.com/hyperledger/fabric/core"
 	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/protos"
 )
@@ -136,6 +137,31 @@ func (d *mockDevops) Query(c context.Context, cis *protos.ChaincodeInvocationSpe
 	return nil, fmt.Errorf("Unknown query function")
 }
 
+func (d *mockDevops) Upgrade(c context.Context, spec *protos.ChaincodeSpec) (*protos.ChaincodeDeploymentSpec, error) {
+	if spec.ChaincodeID.Name == "non-existing" {
+		return nil, fmt.Errorf("Upgrade failure on non-existing chaincode")
+	}
+	return &protos.ChaincodeDeploymentSpec{ChaincodeSpec: spec, CodePackage: []byte{}}, nil
+}
+
+func (d *mockDevops) Terminate(c context.Context, cis *protos.ChaincodeInvocationSpec) (*protos.Response, error) {
+	if cis.ChaincodeSpec.ChaincodeID.Name == "non-existing" {
+		return nil, fmt.Errorf("Terminate failure on non-existing chaincode")
+	}
+	return &protos.Response{Status: protos.Response_SUCCESS, Msg: []byte("terminate_txuuid")}, nil
+}
+
+func (d *mockDevops) GetTransactionStatus(c context.Context, txUUID *protos.TransactionUUID) (*protos.TransactionStatus, error) {
+	if txUUID.Uuid == "confirmed" {
+		return &protos.TransactionStatus{Uuid: txUUID.Uuid, Status: protos.TransactionStatus_CONFIRMED, BlockNumber: 1, Confirmations: 2}, nil
+	}
+	return &protos.TransactionStatus{Uuid: txUUID.Uuid}, nil
+}
+
+func (d *mockDevops) CheckDeterminism(c context.Context, txUUID *protos.TransactionUUID) (*protos.DeterminismReport, error) {
+	return nil, nil
+}
+
 func (d *mockDevops) EXP_GetApplicationTCert(ctx context.Context, secret *protos.Secret) (*protos.Response, error) {
 	return nil, nil
 }
@@ -159,6 +185,13 @@ func initGlobalServerOpenchain(t *testing.T) {
 		t.Fatalf("Error creating OpenchainServer: %s", err)
 	}
 	serverDevops = new(mockDevops)
+	serverAdmin = core.NewAdminServer()
+}
+
+type mockStatusReporter struct{}
+
+func (*mockStatusReporter) GetConsensusStatus() (*protos.ConsensusStatus, error) {
+	return &protos.ConsensusStatus{Plugin: "pbft", View: 2, LastExec: 42}, nil
 }
 
 func TestServerOpenchainREST_API_GetBlockchainInfo(t *testing.T) {
@@ -311,6 +344,87 @@ func TestServerOpenchainREST_API_GetTransactionByUUID(t *testing.T) {
 	}
 }
 
+func TestServerOpenchainREST_API_GetConfirmedTransactionStatus(t *testing.T) {
+	initGlobalServerOpenchain(t)
+
+	// Start the HTTP REST test server
+	httpServer := httptest.NewServer(buildOpenchainRESTRouter())
+	defer httpServer.Close()
+
+	body := performHTTPGet(t, httpServer.URL+"/transactions/confirmed/confirmation")
+	var status protos.TransactionStatus
+	err := json.Unmarshal(body, &status)
+	if err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+	if status.Status != protos.TransactionStatus_CONFIRMED || status.Confirmations != 2 {
+		t.Errorf("Expected a transaction confirmed by 2 validators, got %v", status)
+	}
+
+	body = performHTTPGet(t, httpServer.URL+"/transactions/unknown/confirmation")
+	res := parseRESTResult(t, body)
+	if res.Error == "" {
+		t.Errorf("Expected an error when retrieving the status of an unknown transaction, but got none")
+	}
+}
+
+func TestServerOpenchainREST_API_GetHistory(t *testing.T) {
+	// Construct a ledger with 3 blocks.
+	ledger := ledger.InitTestLedger(t)
+	buildTestLedger1(ledger, t)
+
+	initGlobalServerOpenchain(t)
+
+	// Start the HTTP REST test server
+	httpServer := httptest.NewServer(buildOpenchainRESTRouter())
+	defer httpServer.Close()
+
+	block1, err := ledger.GetBlockByNumber(1)
+	if err != nil {
+		t.Fatalf("Can't fetch first block from ledger: %v", err)
+	}
+	firstTx := block1.Transactions[0]
+
+	body := performHTTPGet(t, httpServer.URL+"/history/MyContract1/code")
+	var history protos.KeyHistory
+	err = json.Unmarshal(body, &history)
+	if err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+	if len(history.Modifications) != 1 {
+		t.Fatalf("Expected 1 modification of the key, but got %d", len(history.Modifications))
+	}
+	if history.Modifications[0].BlockNumber != 1 || history.Modifications[0].TxUuid != firstTx.Uuid {
+		t.Errorf("Expected the key to be modified by transaction %s in block 1, but got %v", firstTx.Uuid, history.Modifications[0])
+	}
+
+	body = performHTTPGet(t, httpServer.URL+"/history/MyContract1/code/blocks/2")
+	var state stateResult
+	err = json.Unmarshal(body, &state)
+	if err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+	if string(state.Value) != "code example" {
+		t.Errorf("Expected value 'code example' as of block 2, but got '%s'", state.Value)
+	}
+
+	// The key did not exist in block 0
+	res := parseRESTResult(t, performHTTPGet(t, httpServer.URL+"/history/MyContract1/code/blocks/0"))
+	if res.Error == "" {
+		t.Errorf("Expected an error when retrieving a key before it was set, but got none")
+	}
+
+	res = parseRESTResult(t, performHTTPGet(t, httpServer.URL+"/history/MyContract1/code/blocks/10"))
+	if res.Error == "" {
+		t.Errorf("Expected an error when retrieving a key as of a non-existing block, but got none")
+	}
+
+	res = parseRESTResult(t, performHTTPGet(t, httpServer.URL+"/history/MyContract1/code/blocks/invalid"))
+	if res.Error == "" {
+		t.Errorf("Expected an error when retrieving a key as of an invalid block, but got none")
+	}
+}
+
 func TestServerOpenchainREST_API_Register(t *testing.T) {
 	os.RemoveAll(getRESTFilePath())
 	initGlobalServerOpenchain(t)
@@ -464,6 +578,31 @@ func TestServerOpenchainREST_API_GetPeers(t *testing.T) {
 	}
 }
 
+func TestServerOpenchainREST_API_GetConsensusStatus(t *testing.T) {
+	initGlobalServerOpenchain(t)
+
+	// Start the HTTP REST test server
+	httpServer := httptest.NewServer(buildOpenchainRESTRouter())
+	defer httpServer.Close()
+
+	body := performHTTPGet(t, httpServer.URL+"/network/consensus")
+	res := parseRESTResult(t, body)
+	if res.Error == "" {
+		t.Errorf("Expected an error when the peer has no consensus plugin, but got none")
+	}
+
+	serverAdmin.(*core.ServerAdmin).SetStatusReporter(&mockStatusReporter{})
+	body = performHTTPGet(t, httpServer.URL+"/network/consensus")
+	var status protos.ConsensusStatus
+	err := json.Unmarshal(body, &status)
+	if err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+	if status.Plugin != "pbft" || status.View != 2 || status.LastExec != 42 {
+		t.Errorf("Expected the status of the consensus plugin, got %v", status)
+	}
+}
+
 func TestServerOpenchainREST_API_Chaincode_InvalidRequests(t *testing.T) {
 	// Construct a ledger with 3 blocks.
 	ledger := ledger.InitTestLedger(t)
@@ -766,6 +905,115 @@ func TestServerOpenchainREST_API_Chaincode_Query(t *testing.T) {
 	}
 }
 
+func TestServerOpenchainREST_API_Chaincode_Upgrade(t *testing.T) {
+	// Construct a ledger with 3 blocks.
+	ledger := ledger.InitTestLedger(t)
+	buildTestLedger1(ledger, t)
+
+	initGlobalServerOpenchain(t)
+
+	// Start the HTTP REST test server
+	httpServer := httptest.NewServer(buildOpenchainRESTRouter())
+	defer httpServer.Close()
+
+	// Test upgrade without params
+	httpResponse, body := performHTTPPost(t, httpServer.URL+"/chaincode", []byte(`{"jsonrpc":"2.0","ID":123,"method":"upgrade"}`))
+	if httpResponse.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected an HTTP status code %#v but got %#v", http.StatusBadRequest, httpResponse.StatusCode)
+	}
+	res := parseRPCResponse(t, body)
+	if res.Error == nil || res.Error.Code != InvalidParams.Code {
+		t.Errorf("Expected an error when sending missing params, but got %#v", res.Error)
+	}
+
+	// Login
+	performHTTPPost(t, httpServer.URL+"/registrar", []byte(`{"enrollId":"myuser","enrollSecret":"password"}`))
+
+	// Test upgrade without chaincode name
+	httpResponse, body = performHTTPPost(t, httpServer.URL+"/chaincode", []byte(`{"jsonrpc":"2.0","ID":123,"method":"upgrade","params":{"type":1,"chaincodeID":{"path":"github.com/hyperledger/fabric/core/rest/test_chaincode"},"ctorMsg":{"function":"Init","args":[]},"secureContext":"myuser"}}`))
+	res = parseRPCResponse(t, body)
+	if res.Error == nil || res.Error.Code != InvalidParams.Code {
+		t.Errorf("Expected an error when sending without chaincode name, but got %#v", res.Error)
+	}
+
+	// Test upgrade of non-existing chaincode
+	httpResponse, body = performHTTPPost(t, httpServer.URL+"/chaincode", []byte(`{"jsonrpc":"2.0","ID":123,"method":"upgrade","params":{"type":1,"chaincodeID":{"name":"non-existing","path":"github.com/hyperledger/fabric/core/rest/test_chaincode"},"ctorMsg":{"function":"Init","args":[]},"secureContext":"myuser"}}`))
+	if httpResponse.StatusCode != http.StatusOK {
+		t.Errorf("Expected an HTTP status code %#v but got %#v", http.StatusOK, httpResponse.StatusCode)
+	}
+	res = parseRPCResponse(t, body)
+	if res.Error == nil || res.Error.Code != ChaincodeUpgradeError.Code {
+		t.Errorf("Expected an error when upgrading non-existing chaincode, but got %#v", res.Error)
+	}
+
+	// Test upgrade of existing chaincode
+	httpResponse, body = performHTTPPost(t, httpServer.URL+"/chaincode", []byte(`{"jsonrpc":"2.0","ID":123,"method":"upgrade","params":{"type":1,"chaincodeID":{"name":"dummy","path":"github.com/hyperledger/fabric/core/rest/test_chaincode"},"ctorMsg":{"function":"Init","args":[]},"secureContext":"myuser"}}`))
+	if httpResponse.StatusCode != http.StatusOK {
+		t.Errorf("Expected an HTTP status code %#v but got %#v", http.StatusOK, httpResponse.StatusCode)
+	}
+	res = parseRPCResponse(t, body)
+	if res.Error != nil {
+		t.Errorf("Expected success but got %#v", res.Error)
+	}
+	if res.Result.Status != "OK" {
+		t.Errorf("Expected OK but got %#v", res.Result.Status)
+	}
+	if res.Result.Message != "dummy" {
+		t.Errorf("Expected 'dummy' but got '%v'", res.Result.Message)
+	}
+}
+
+func TestServerOpenchainREST_API_Chaincode_Terminate(t *testing.T) {
+	// Construct a ledger with 3 blocks.
+	ledger := ledger.InitTestLedger(t)
+	buildTestLedger1(ledger, t)
+
+	initGlobalServerOpenchain(t)
+
+	// Start the HTTP REST test server
+	httpServer := httptest.NewServer(buildOpenchainRESTRouter())
+	defer httpServer.Close()
+
+	// Test terminate without params
+	httpResponse, body := performHTTPPost(t, httpServer.URL+"/chaincode", []byte(`{"jsonrpc":"2.0","ID":123,"method":"terminate"}`))
+	if httpResponse.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected an HTTP status code %#v but got %#v", http.StatusBadRequest, httpResponse.StatusCode)
+	}
+	res := parseRPCResponse(t, body)
+	if res.Error == nil || res.Error.Code != InvalidParams.Code {
+		t.Errorf("Expected an error when sending missing params, but got %#v", res.Error)
+	}
+
+	// Login
+	performHTTPPost(t, httpServer.URL+"/registrar", []byte(`{"enrollId":"myuser","enrollSecret":"password"}`))
+
+	// Test terminate of non-existing chaincode
+	httpResponse, body = performHTTPPost(t, httpServer.URL+"/chaincode", []byte(`{"jsonrpc":"2.0","ID":123,"method":"terminate","params":{"type":1,"chaincodeID":{"name":"non-existing"},"secureContext":"myuser"}}`))
+	if httpResponse.StatusCode != http.StatusOK {
+		t.Errorf("Expected an HTTP status code %#v but got %#v", http.StatusOK, httpResponse.StatusCode)
+	}
+	res = parseRPCResponse(t, body)
+	if res.Error == nil || res.Error.Code != ChaincodeTerminateError.Code {
+		t.Errorf("Expected an error when terminating non-existing chaincode, but got %#v", res.Error)
+	}
+
+	// Test terminate of existing chaincode, no CtorMsg is needed
+	httpResponse, body = performHTTPPost(t, httpServer.URL+"/chaincode", []byte(`{"jsonrpc":"2.0","ID":123,"method":"terminate","params":{"type":1,"chaincodeID":{"name":"dummy"},"secureContext":"myuser"}}`))
+	if httpResponse.StatusCode != http.StatusOK {
+		t.Errorf("Expected an HTTP status code %#v but got %#v", http.StatusOK, httpResponse.StatusCode)
+	}
+	res = parseRPCResponse(t, body)
+	if res.Error != nil {
+		t.Errorf("Expected success but got %#v", res.Error)
+	}
+	if res.Result.Status != "OK" {
+		t.Errorf("Expected OK but got %#v", res.Result.Status)
+	}
+	if res.Result.Message != "terminate_txuuid" {
+		t.Errorf("Expected 'terminate_txuuid' but got '%v'", res.Result.Message)
+	}
+}
+
 func TestServerOpenchainREST_API_NotFound(t *testing.T) {
 	httpServer := httptest.NewServer(buildOpenchainRESTRouter())
 	defer httpServer.Close()
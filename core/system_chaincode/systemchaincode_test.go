@@ -35,7 +35,7 @@ import (
 )
 
 // Invoke or query a chaincode.
-func invoke(ctx context.Context, spec *pb.ChaincodeSpec, typ pb.Transaction_Type) (*pb.ChaincodeEvent, string, []byte, error) {
+func invoke(ctx context.Context, spec *pb.ChaincodeSpec, typ pb.Transaction_Type) ([]*pb.ChaincodeEvent, string, []byte, error) {
 	chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
 
 	// Now create the Transactions message and send to Peer.
@@ -50,20 +50,20 @@ func invoke(ctx context.Context, spec *pb.ChaincodeSpec, typ pb.Transaction_Type
 
 	var retval []byte
 	var execErr error
-	var ccevt *pb.ChaincodeEvent
+	var ccevts []*pb.ChaincodeEvent
 	if typ == pb.Transaction_CHAINCODE_QUERY {
-		retval, ccevt, execErr = chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
+		retval, ccevts, execErr = chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
 	} else {
 		ledger, _ := ledger.GetLedger()
 		ledger.BeginTxBatch("1")
-		retval, ccevt, execErr = chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
+		retval, ccevts, execErr = chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
 		if err != nil {
 			return nil, uuid, nil, fmt.Errorf("Error invoking chaincode: %s ", err)
 		}
 		ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
 	}
 
-	return ccevt, uuid, retval, execErr
+	return ccevts, uuid, retval, execErr
 }
 
 func closeListenerAndSleep(l net.Listener) {
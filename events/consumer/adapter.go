@@ -21,7 +21,8 @@ import (
 )
 
 //EventAdapter is the interface by which a openchain event client registers interested events and
-//receives messages from the openchain event Server
+//receives messages from the openchain event Server. Disconnected is called once the
+//client has stopped or could not reconnect to the event Server
 type EventAdapter interface {
 	GetInterestedEvents() ([]*ehpb.Interest, error)
 	Recv(msg *ehpb.Event) (bool, error)
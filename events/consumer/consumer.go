@@ -19,8 +19,10 @@ package consumer
 import (
 	"fmt"
 	"io"
+	"sync"
 	"time"
 
+	"github.com/op/go-logging"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 
@@ -28,16 +30,34 @@ import (
 	ehpb "github.com/hyperledger/fabric/protos"
 )
 
+const (
+	//interval between the attempts to reconnect, doubled after every
+	//failed attempt up to maxReconnectInterval
+	reconnectInterval    = time.Second
+	maxReconnectInterval = 30 * time.Second
+	maxReconnectAttempts = 10
+)
+
+var consumerLogger = logging.MustGetLogger("eventhub_consumer")
+
 //EventsClient holds the stream and adapter for consumer to work with
 type EventsClient struct {
+	sync.RWMutex
 	peerAddress string
+	conn        *grpc.ClientConn
 	stream      ehpb.Events_ChatClient
 	adapter     EventAdapter
+	interests   []*ehpb.Interest
+	stopped     bool
+
+	//the events are replayed from startBlock when registering
+	replay     bool
+	startBlock uint64
 }
 
 //NewEventsClient Returns a new grpc.ClientConn to the configured local PEER.
 func NewEventsClient(peerAddress string, adapter EventAdapter) *EventsClient {
-	return &EventsClient{peerAddress, nil, adapter}
+	return &EventsClient{peerAddress: peerAddress, adapter: adapter}
 }
 
 //newEventsClientConnectionWithAddress Returns a new grpc.ClientConn to the configured local PEER.
@@ -48,11 +68,27 @@ func newEventsClientConnectionWithAddress(peerAddress string) (*grpc.ClientConn,
 	return comm.NewClientConnectionWithAddress(peerAddress, true, false, nil)
 }
 
+//SetStartBlock makes the client register from the given block number, the
+//events of the blocks committed since then are replayed before the new
+//events. It has to be called before Start
+func (ec *EventsClient) SetStartBlock(blockNumber uint64) {
+	ec.Lock()
+	defer ec.Unlock()
+	ec.replay = true
+	ec.startBlock = blockNumber
+}
+
 func (ec *EventsClient) register(ies []*ehpb.Interest) error {
-	emsg := &ehpb.Event{Event: &ehpb.Event_Register{Register: &ehpb.Register{Events: ies}}}
+	ec.RLock()
+	regMsg := &ehpb.Register{Events: ies}
+	if ec.replay {
+		regMsg.StartBlock = &ehpb.BlockNumber{Number: ec.startBlock}
+	}
+	ec.RUnlock()
+	emsg := &ehpb.Event{Event: &ehpb.Event_Register{Register: regMsg}}
 	var err error
 	if err = ec.stream.Send(emsg); err != nil {
-		fmt.Printf("error on Register send %s\n", err)
+		consumerLogger.Errorf("error on Register send %s", err)
 		return err
 	}
 
@@ -66,6 +102,15 @@ func (ec *EventsClient) register(ies []*ehpb.Interest) error {
 		}
 		switch in.Event.(type) {
 		case *ehpb.Event_Register:
+			//the producer tells which block the events start from, the
+			//client resumes from there if it gets disconnected
+			startBlock := in.GetRegister().GetStartBlock()
+			ec.Lock()
+			ec.replay = startBlock != nil
+			if startBlock != nil {
+				ec.startBlock = startBlock.Number
+			}
+			ec.Unlock()
 		case nil:
 			err = fmt.Errorf("invalid nil object for register")
 		default:
@@ -80,39 +125,128 @@ func (ec *EventsClient) register(ies []*ehpb.Interest) error {
 	return err
 }
 
-func (ec *EventsClient) processEvents() error {
-	defer ec.stream.CloseSend()
+//updateStartBlock records the block the events have been received of, to
+//resume from it after a reconnect. The events of a block whose transaction
+//status or chaincode events were partially received are replayed again
+func (ec *EventsClient) updateStartBlock(e *ehpb.Event) {
+	var next uint64
+	switch e.Event.(type) {
+	case *ehpb.Event_Block:
+		next = e.BlockNumber + 1
+	case *ehpb.Event_ChaincodeEvent, *ehpb.Event_TransactionStatus:
+		next = e.BlockNumber
+	default:
+		return
+	}
+	ec.Lock()
+	if next > ec.startBlock {
+		ec.startBlock = next
+	}
+	ec.Unlock()
+}
+
+//recvEvents receives the events of the current stream until it breaks or the
+//adapter stops the processing, in which case done is true
+func (ec *EventsClient) recvEvents() (done bool, err error) {
 	for {
 		in, err := ec.stream.Recv()
 		if err == io.EOF {
 			// read done.
-			if ec.adapter != nil {
-				ec.adapter.Disconnected(nil)
-			}
-			return nil
+			return false, nil
 		}
 		if err != nil {
+			return false, err
+		}
+		ec.updateStartBlock(in)
+		if ec.adapter != nil {
+			cont, err := ec.adapter.Recv(in)
+			if !cont {
+				return true, err
+			}
+		}
+	}
+}
+
+func (ec *EventsClient) processEvents() error {
+	for {
+		done, err := ec.recvEvents()
+		ec.stream.CloseSend()
+		if done {
+			return err
+		}
+		if ec.isStopped() {
 			if ec.adapter != nil {
 				ec.adapter.Disconnected(err)
 			}
 			return err
 		}
-		if ec.adapter != nil {
-			cont, err := ec.adapter.Recv(in)
-			if !cont {
-				return err
+		consumerLogger.Warningf("Disconnected from %s (%v), reconnecting", ec.peerAddress, err)
+		if err = ec.reconnect(); err != nil {
+			if ec.adapter != nil {
+				ec.adapter.Disconnected(err)
 			}
+			return err
 		}
 	}
 }
 
-//Start establishes connection with Event hub and registers interested events with it
-func (ec *EventsClient) Start() error {
+//reconnect connects again and registers from the block the events were last
+//received of, if the producer supports replaying events
+func (ec *EventsClient) reconnect() error {
+	interval := reconnectInterval
+	var err error
+	for attempt := 1; attempt <= maxReconnectAttempts; attempt++ {
+		time.Sleep(interval)
+		if ec.isStopped() {
+			return fmt.Errorf("client stopped")
+		}
+		if err = ec.connect(); err == nil {
+			consumerLogger.Infof("Reconnected to %s", ec.peerAddress)
+			return nil
+		}
+		consumerLogger.Warningf("Reconnect attempt %d to %s failed: %s", attempt, ec.peerAddress, err)
+		if interval *= 2; interval > maxReconnectInterval {
+			interval = maxReconnectInterval
+		}
+	}
+	return fmt.Errorf("could not reconnect to %s: %s", ec.peerAddress, err)
+}
+
+func (ec *EventsClient) connect() error {
 	conn, err := newEventsClientConnectionWithAddress(ec.peerAddress)
 	if err != nil {
 		return fmt.Errorf("Could not create client conn to %s", ec.peerAddress)
 	}
 
+	serverClient := ehpb.NewEventsClient(conn)
+	stream, err := serverClient.Chat(context.Background())
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("Could not create client conn to %s", ec.peerAddress)
+	}
+
+	ec.Lock()
+	if ec.conn != nil {
+		ec.conn.Close()
+	}
+	ec.conn = conn
+	ec.stream = stream
+	ec.Unlock()
+
+	return ec.register(ec.interests)
+}
+
+func (ec *EventsClient) isStopped() bool {
+	ec.RLock()
+	defer ec.RUnlock()
+	return ec.stopped
+}
+
+//Start establishes connection with Event hub and registers interested events with it.
+//If the connection breaks later on, the client reconnects and resumes from the
+//block it last received events of. The adapter is only told it is disconnected
+//if the client cannot reconnect
+func (ec *EventsClient) Start() error {
 	ies, err := ec.adapter.GetInterestedEvents()
 	if err != nil {
 		return fmt.Errorf("error getting interested events:%s", err)
@@ -121,14 +255,9 @@ func (ec *EventsClient) Start() error {
 	if len(ies) == 0 {
 		return fmt.Errorf("must supply interested events")
 	}
+	ec.interests = ies
 
-	serverClient := ehpb.NewEventsClient(conn)
-	ec.stream, err = serverClient.Chat(context.Background())
-	if err != nil {
-		return fmt.Errorf("Could not create client conn to %s", ec.peerAddress)
-	}
-
-	if err = ec.register(ies); err != nil {
+	if err = ec.connect(); err != nil {
 		return err
 	}
 
@@ -139,6 +268,9 @@ func (ec *EventsClient) Start() error {
 
 //Stop terminates connection with event hub
 func (ec *EventsClient) Stop() error {
+	ec.Lock()
+	defer ec.Unlock()
+	ec.stopped = true
 	if ec.stream == nil {
 		// in case the steam/chat server has not been established earlier, we assume that it's closed, successfully
 		return nil
@@ -40,6 +40,10 @@ type Adapter struct {
 }
 
 var peerAddress string
+
+//number of events queued for a consumer during a replay before it is
+//disconnected
+const maxQueuedEvents = 5
 var adapter *Adapter
 var obcEHClient *consumer.EventsClient
 
@@ -163,6 +167,168 @@ func TestFailReceive(t *testing.T) {
 	}
 }
 
+type blockSource struct {
+	blocks []*ehpb.Block
+}
+
+func (s *blockSource) GetBlockchainSize() uint64 {
+	return uint64(len(s.blocks))
+}
+
+func (s *blockSource) GetBlockByNumber(blockNumber uint64) (*ehpb.Block, error) {
+	if blockNumber >= uint64(len(s.blocks)) {
+		return nil, fmt.Errorf("block %d does not exist", blockNumber)
+	}
+	return s.blocks[blockNumber], nil
+}
+
+var testBlockSource = &blockSource{blocks: []*ehpb.Block{
+	&ehpb.Block{
+		Transactions: []*ehpb.Transaction{&ehpb.Transaction{Uuid: "tx1"}},
+		NonHashData: &ehpb.NonHashData{ChaincodeEvents: []*ehpb.ChaincodeEvent{
+			&ehpb.ChaincodeEvent{ChaincodeID: "replaycc", TxID: "tx1", EventName: "evt-1"},
+			&ehpb.ChaincodeEvent{ChaincodeID: "replaycc", TxID: "tx1", EventName: "other"},
+		}},
+	},
+	&ehpb.Block{
+		Transactions: []*ehpb.Transaction{&ehpb.Transaction{Uuid: "tx2"}},
+		NonHashData: &ehpb.NonHashData{ChaincodeEvents: []*ehpb.ChaincodeEvent{
+			&ehpb.ChaincodeEvent{ChaincodeID: "othercc", TxID: "tx2", EventName: "evt-2"},
+		}},
+	},
+}}
+
+type replayAdapter struct {
+	events chan *ehpb.Event
+}
+
+func (a *replayAdapter) GetInterestedEvents() ([]*ehpb.Interest, error) {
+	return []*ehpb.Interest{
+		&ehpb.Interest{EventType: ehpb.EventType_BLOCK, TxID: "tx2"},
+		&ehpb.Interest{EventType: ehpb.EventType_CHAINCODE, RegInfo: &ehpb.Interest_ChaincodeRegInfo{ChaincodeRegInfo: &ehpb.ChaincodeReg{ChaincodeID: "replaycc", EventName: "evt-[0-9]+", IsRegex: true}}},
+	}, nil
+}
+
+func (a *replayAdapter) Recv(msg *ehpb.Event) (bool, error) {
+	a.events <- msg
+	return true, nil
+}
+
+func (a *replayAdapter) Disconnected(err error) {
+}
+
+func (a *replayAdapter) expectEvent(t *testing.T, blockNumber uint64, eventName string) {
+	select {
+	case e := <-a.events:
+		if e.BlockNumber != blockNumber {
+			t.Fatalf("Expected an event of block %d, got %d", blockNumber, e.BlockNumber)
+		}
+		if eventName == "" && e.GetBlock() == nil {
+			t.Fatalf("Expected a block event, got %v", e)
+		}
+		if eventName != "" && (e.GetChaincodeEvent() == nil || e.GetChaincodeEvent().EventName != eventName) {
+			t.Fatalf("Expected chaincode event %s, got %v", eventName, e)
+		}
+	case <-time.After(5 * time.Second):
+		time.Sleep(time.Minute)
+		t.Fatalf("Timed out waiting for an event of block %d", blockNumber)
+	}
+}
+
+func TestReplayFilteredEvents(t *testing.T) {
+	a := &replayAdapter{events: make(chan *ehpb.Event, 10)}
+	client := consumer.NewEventsClient(peerAddress, a)
+	client.SetStartBlock(0)
+	if err := client.Start(); err != nil {
+		t.Fatalf("Could not start events client: %s", err)
+	}
+	defer client.Stop()
+
+	//the past events are replayed
+	a.expectEvent(t, 0, "evt-1")
+	a.expectEvent(t, 1, "")
+
+	//followed by the new ones
+	emsg := createTestChaincodeEvent("replaycc", "nomatch")
+	emsg.BlockNumber = 2
+	if err := producer.Send(emsg); err != nil {
+		t.Fatalf("Error sending message %s", err)
+	}
+	emsg = createTestChaincodeEvent("replaycc", "evt-3")
+	emsg.BlockNumber = 2
+	if err := producer.Send(emsg); err != nil {
+		t.Fatalf("Error sending message %s", err)
+	}
+	a.expectEvent(t, 2, "evt-3")
+
+	select {
+	case e := <-a.events:
+		t.Fatalf("Unexpected event %v", e)
+	case <-time.After(time.Second):
+	}
+}
+
+//gatedBlockSource holds the replay until the gate is closed
+type gatedBlockSource struct {
+	*blockSource
+	reached chan struct{}
+	gate    chan struct{}
+}
+
+func (s *gatedBlockSource) GetBlockByNumber(blockNumber uint64) (*ehpb.Block, error) {
+	select {
+	case s.reached <- struct{}{}:
+	default:
+	}
+	<-s.gate
+	return s.blockSource.GetBlockByNumber(blockNumber)
+}
+
+func TestReplayDisconnectsSlowConsumer(t *testing.T) {
+	source := &gatedBlockSource{blockSource: testBlockSource, reached: make(chan struct{}, 1), gate: make(chan struct{})}
+	producer.SetBlockSource(source)
+	defer producer.SetBlockSource(testBlockSource)
+
+	a := &replayAdapter{events: make(chan *ehpb.Event, 10)}
+	client := consumer.NewEventsClient(peerAddress, a)
+	client.SetStartBlock(0)
+	if err := client.Start(); err != nil {
+		t.Fatalf("Could not start events client: %s", err)
+	}
+	defer client.Stop()
+
+	select {
+	case <-source.reached:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the replay")
+	}
+
+	//more new events than can be queued while the replay is held
+	for i := 0; i <= maxQueuedEvents; i++ {
+		emsg := createTestChaincodeEvent("replaycc", "evt-3")
+		emsg.BlockNumber = 2
+		if err := producer.Send(emsg); err != nil {
+			t.Fatalf("Error sending message %s", err)
+		}
+	}
+	time.Sleep(time.Second)
+	close(source.gate)
+
+	//the replay stops and the consumer is disconnected, the queued events
+	//are dropped rather than leaving a gap
+	a.expectEvent(t, 0, "evt-1")
+
+	//it registers again from the block it reached
+	a.expectEvent(t, 0, "evt-1")
+	a.expectEvent(t, 1, "")
+
+	select {
+	case e := <-a.events:
+		t.Fatalf("Unexpected event %v", e)
+	case <-time.After(2 * time.Second):
+	}
+}
+
 func BenchmarkMessages(b *testing.B) {
 	numMessages := 10000
 
@@ -214,8 +380,9 @@ func TestMain(m *testing.M) {
 
 	// Register EventHub server
 	// use a buffer of 100 and blocking timeout
-	ehServer := producer.NewEventsServer(100, 0)
+	ehServer := producer.NewEventsServer(100, 0, maxQueuedEvents)
 	ehpb.RegisterEventsServer(grpcServer, ehServer)
+	producer.SetBlockSource(testBlockSource)
 
 	fmt.Printf("Starting events server\n")
 	go grpcServer.Serve(lis)
@@ -17,6 +17,8 @@ limitations under the License.
 package producer
 
 import (
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/util"
 	ehpb "github.com/hyperledger/fabric/protos"
 )
 
@@ -32,5 +34,69 @@ func CreateChaincodeEvent(te *ehpb.ChaincodeEvent) *ehpb.Event {
 
 //CreateRejectionEvent creates an Event from TxResults
 func CreateRejectionEvent(tx *ehpb.Transaction, errorMsg string) *ehpb.Event {
-	return &ehpb.Event{Event: &ehpb.Event_Rejection{Rejection: &ehpb.Rejection{Tx: tx, ErrorMsg: errorMsg}}}
+	return &ehpb.Event{Event: &ehpb.Event_Rejection{Rejection: &ehpb.Rejection{Tx: tx, ErrorMsg: errorMsg}}, Timestamp: util.CreateUtcTimestamp()}
+}
+
+//CreateTransactionStatusEvent creates an Event from a TransactionStatus
+func CreateTransactionStatusEvent(status *ehpb.TransactionStatus) *ehpb.Event {
+	return &ehpb.Event{Event: &ehpb.Event_TransactionStatus{TransactionStatus: status}}
+}
+
+//CreateBlockEvents creates the events of a committed block: the block event,
+//the status events of the transactions of the block and of the transactions
+//that failed with it, then the chaincode events recorded in the block's NonHashData
+func CreateBlockEvents(blockNumber uint64, block *ehpb.Block) []*ehpb.Event {
+	events := []*ehpb.Event{CreateBlockEvent(removeCodePackages(block))}
+	for _, tx := range block.GetTransactions() {
+		status := &ehpb.TransactionStatus{Uuid: tx.Uuid, Status: ehpb.TransactionStatus_COMMITTED, BlockNumber: blockNumber}
+		events = append(events, CreateTransactionStatusEvent(status))
+	}
+	for _, txResult := range block.GetNonHashData().GetTransactionResults() {
+		if txResult.ErrorCode == 0 {
+			continue
+		}
+		status := &ehpb.TransactionStatus{Uuid: txResult.Uuid, Status: ehpb.TransactionStatus_ERROR, BlockNumber: blockNumber,
+			ErrorCode: txResult.ErrorCode, Error: txResult.Error}
+		events = append(events, CreateTransactionStatusEvent(status))
+	}
+	for _, ccEvent := range block.GetNonHashData().GetChaincodeEvents() {
+		events = append(events, CreateChaincodeEvent(ccEvent))
+	}
+
+	timestamp := block.GetNonHashData().GetLocalLedgerCommitTimestamp()
+	for _, e := range events {
+		e.Timestamp = timestamp
+		e.BlockNumber = blockNumber
+	}
+	return events
+}
+
+//removeCodePackages returns a copy of the block without the code packages of
+//its deploy transactions. This is done to make block events more lightweight
+//as the payload for these types of transactions can be very large.
+func removeCodePackages(block *ehpb.Block) *ehpb.Block {
+	blockCopy := *block
+	blockCopy.Transactions = make([]*ehpb.Transaction, len(block.Transactions))
+	for i, transaction := range block.Transactions {
+		blockCopy.Transactions[i] = transaction
+		if transaction.Type != ehpb.Transaction_CHAINCODE_DEPLOY && transaction.Type != ehpb.Transaction_CHAINCODE_UPGRADE {
+			continue
+		}
+		deploymentSpec := &ehpb.ChaincodeDeploymentSpec{}
+		err := proto.Unmarshal(transaction.Payload, deploymentSpec)
+		if err != nil {
+			producerLogger.Errorf("Error unmarshalling deployment transaction for block event: %s", err)
+			continue
+		}
+		deploymentSpec.CodePackage = nil
+		deploymentSpecBytes, err := proto.Marshal(deploymentSpec)
+		if err != nil {
+			producerLogger.Errorf("Error marshalling deployment transaction for block event: %s", err)
+			continue
+		}
+		transactionCopy := *transaction
+		transactionCopy.Payload = deploymentSpecBytes
+		blockCopy.Transactions[i] = &transactionCopy
+	}
+	return &blockCopy
 }
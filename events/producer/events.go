@@ -21,6 +21,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/golang/protobuf/proto"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -35,27 +36,29 @@ import (
 //
 type handlerList interface {
 	//find() *handler
-	add(ie *pb.Interest, h *handler) (bool, error)
-	del(ie *pb.Interest, h *handler) (bool, error)
+	add(f *eventFilter, h *handler) (bool, error)
+	del(f *eventFilter, h *handler) (bool, error)
 	foreach(ie *pb.Event, action func(h *handler))
 }
 
 type genericHandlerList struct {
 	sync.RWMutex
-	// this map used as a list - add/del/iterate
-	handlers map[*handler]bool
+	// the handlers and their filters, an event is sent once to a handler
+	// if it matches any of them
+	handlers map[*handler][]*eventFilter
 }
 
 type chaincodeHandlerList struct {
 	sync.RWMutex
-	// this map used as a list - add/del/iterate
-	handlers map[string]map[string]map[*handler]bool
+	// chaincode ID to the handlers and their filters for the chaincode's events
+	handlers map[string]map[*handler][]*eventFilter
 }
 
-func (hl *chaincodeHandlerList) add(ie *pb.Interest, h *handler) (bool, error) {
+func (hl *chaincodeHandlerList) add(f *eventFilter, h *handler) (bool, error) {
 	hl.Lock()
 	defer hl.Unlock()
 
+	ie := f.interest
 	//chaincode registration info must be non-nil
 	if ie.GetChaincodeRegInfo() == nil {
 		return false, fmt.Errorf("chaincode information not provided for registering")
@@ -64,31 +67,30 @@ func (hl *chaincodeHandlerList) add(ie *pb.Interest, h *handler) (bool, error) {
 	if ie.GetChaincodeRegInfo().ChaincodeID == "" {
 		return false, fmt.Errorf("chaincode ID not provided for registering")
 	}
-	//is there a event type map for the chaincode
-	emap, ok := hl.handlers[ie.GetChaincodeRegInfo().ChaincodeID]
+	//is there a handler map for the chaincode
+	hmap, ok := hl.handlers[ie.GetChaincodeRegInfo().ChaincodeID]
 	if !ok {
-		emap = make(map[string]map[*handler]bool)
-		hl.handlers[ie.GetChaincodeRegInfo().ChaincodeID] = emap
+		hmap = make(map[*handler][]*eventFilter)
+		hl.handlers[ie.GetChaincodeRegInfo().ChaincodeID] = hmap
 	}
 
-	//create handler map if this is the first handler for the type
-	var handlerMap map[*handler]bool
-	if handlerMap, _ = emap[ie.GetChaincodeRegInfo().EventName]; handlerMap == nil {
-		handlerMap = make(map[*handler]bool)
-		emap[ie.GetChaincodeRegInfo().EventName] = handlerMap
-	} else if _, ok = handlerMap[h]; ok {
-		return false, fmt.Errorf("handler exists for event type")
+	//the same interest can only be registered once by a handler
+	for _, other := range hmap[h] {
+		if proto.Equal(other.interest, ie) {
+			return false, fmt.Errorf("handler exists for event type")
+		}
 	}
 
-	//the handler is added to the map
-	handlerMap[h] = true
+	//the filter is added for the handler
+	hmap[h] = append(hmap[h], f)
 
 	return true, nil
 }
-func (hl *chaincodeHandlerList) del(ie *pb.Interest, h *handler) (bool, error) {
+func (hl *chaincodeHandlerList) del(f *eventFilter, h *handler) (bool, error) {
 	hl.Lock()
 	defer hl.Unlock()
 
+	ie := f.interest
 	//chaincode registration info must be non-nil
 	if ie.GetChaincodeRegInfo() == nil {
 		return false, fmt.Errorf("chaincode information not provided for de-registering")
@@ -99,36 +101,34 @@ func (hl *chaincodeHandlerList) del(ie *pb.Interest, h *handler) (bool, error) {
 		return false, fmt.Errorf("chaincode ID not provided for de-registering")
 	}
 
-	//if there's no event type map, nothing to do
-	emap, ok := hl.handlers[ie.GetChaincodeRegInfo().ChaincodeID]
+	//if there's no handler map, nothing to do
+	hmap, ok := hl.handlers[ie.GetChaincodeRegInfo().ChaincodeID]
 	if !ok {
 		return false, fmt.Errorf("chaincode ID not registered")
 	}
 
-	//if there are no handlers for the event type, nothing to do
-	var handlerMap map[*handler]bool
-	if handlerMap, _ = emap[ie.GetChaincodeRegInfo().EventName]; handlerMap == nil {
-		return false, fmt.Errorf("event name %s not registered for chaincode ID %s", ie.GetChaincodeRegInfo().EventName, ie.GetChaincodeRegInfo().ChaincodeID)
-	} else if _, ok = handlerMap[h]; !ok {
-		//the handler is not registered for the event type
-		return false, fmt.Errorf("handler not registered for event name %s for chaincode ID %s", ie.GetChaincodeRegInfo().EventName, ie.GetChaincodeRegInfo().ChaincodeID)
-	}
-
-	//remove the handler from the map
-	delete(handlerMap, h)
-
-	//if the last handler has been removed from handler map for a chaincode's event,
-	//remove the event map.
-	//if the last map of events have been removed for the chaincode UUID
-	//remove the chaincode UUID map
-	if len(handlerMap) == 0 {
-		delete(emap, ie.GetChaincodeRegInfo().EventName)
-		if len(emap) == 0 {
-			delete(hl.handlers, ie.GetChaincodeRegInfo().ChaincodeID)
+	//remove the filter of the handler
+	filters := hmap[h]
+	for i, other := range filters {
+		if other == f {
+			filters = append(filters[:i], filters[i+1:]...)
+			//if the last filter has been removed for the handler, remove the
+			//handler. If the last handler has been removed for the chaincode
+			//remove the chaincode ID map
+			if len(filters) == 0 {
+				delete(hmap, h)
+				if len(hmap) == 0 {
+					delete(hl.handlers, ie.GetChaincodeRegInfo().ChaincodeID)
+				}
+			} else {
+				hmap[h] = filters
+			}
+			return true, nil
 		}
 	}
 
-	return true, nil
+	//the handler is not registered for the event
+	return false, fmt.Errorf("handler not registered for event name %s for chaincode ID %s", ie.GetChaincodeRegInfo().EventName, ie.GetChaincodeRegInfo().ChaincodeID)
 }
 
 func (hl *chaincodeHandlerList) foreach(e *pb.Event, action func(h *handler)) {
@@ -140,54 +140,56 @@ func (hl *chaincodeHandlerList) foreach(e *pb.Event, action func(h *handler)) {
 		return
 	}
 
-	//get the event map for the chaincode
-	if emap := hl.handlers[e.GetChaincodeEvent().ChaincodeID]; emap != nil {
-		//get the handler map for the event
-		if handlerMap := emap[e.GetChaincodeEvent().EventName]; handlerMap != nil {
-			for h := range handlerMap {
+	//the event is sent to a handler once for every interest it matches
+	for h, filters := range hl.handlers[e.GetChaincodeEvent().ChaincodeID] {
+		for _, f := range filters {
+			if f.matches(e) {
 				action(h)
 			}
 		}
-		//send to handlers who want all events from the chaincode, but only if
-		//EventName is not already "" (chaincode should NOT send nameless events though)
-		if e.GetChaincodeEvent().EventName != "" {
-			if handlerMap := emap[""]; handlerMap != nil {
-				for h := range handlerMap {
-					action(h)
-				}
-			}
-		}
 	}
 }
 
-func (hl *genericHandlerList) add(ie *pb.Interest, h *handler) (bool, error) {
+func (hl *genericHandlerList) add(f *eventFilter, h *handler) (bool, error) {
 	hl.Lock()
-	if _, ok := hl.handlers[h]; ok {
-		hl.Unlock()
-		return false, fmt.Errorf("handler exists for event type")
+	defer hl.Unlock()
+	for _, other := range hl.handlers[h] {
+		if proto.Equal(other.interest, f.interest) {
+			return false, fmt.Errorf("handler exists for event type")
+		}
 	}
-	hl.handlers[h] = true
-	hl.Unlock()
+	hl.handlers[h] = append(hl.handlers[h], f)
 	return true, nil
 }
 
-func (hl *genericHandlerList) del(ie *pb.Interest, h *handler) (bool, error) {
+func (hl *genericHandlerList) del(f *eventFilter, h *handler) (bool, error) {
 	hl.Lock()
-	if _, ok := hl.handlers[h]; !ok {
-		hl.Unlock()
-		return false, fmt.Errorf("handler does not exist for event type")
+	defer hl.Unlock()
+	filters := hl.handlers[h]
+	for i, other := range filters {
+		if other == f {
+			if len(filters) == 1 {
+				delete(hl.handlers, h)
+			} else {
+				hl.handlers[h] = append(filters[:i], filters[i+1:]...)
+			}
+			return true, nil
+		}
 	}
-	delete(hl.handlers, h)
-	hl.Unlock()
-	return true, nil
+	return false, fmt.Errorf("handler does not exist for event type")
 }
 
 func (hl *genericHandlerList) foreach(e *pb.Event, action func(h *handler)) {
 	hl.Lock()
-	for h := range hl.handlers {
-		action(h)
+	defer hl.Unlock()
+	for h, filters := range hl.handlers {
+		for _, f := range filters {
+			if f.matches(e) {
+				action(h)
+				break
+			}
+		}
 	}
-	hl.Unlock()
 }
 
 //eventProcessor has a map of event type to handlers interested in that
@@ -207,6 +209,14 @@ type eventProcessor struct {
 	//if 0, if buffer full, will block and guarantee the event will be sent out
 	//if > 0, if buffer full, blocks till timeout
 	timeout int
+
+	//number of new events queued for a consumer while past events are
+	//replayed to it, a consumer falling further behind is disconnected.
+	//Unbounded if <= 0
+	maxQueuedEvents int
+
+	//blocks whose events are replayed to consumers registering from a block
+	blockSource BlockSource
 }
 
 //global eventProcessor singleton created by initializeEvents. Openchain producers
@@ -240,12 +250,12 @@ func (ep *eventProcessor) start() {
 }
 
 //initialize and start
-func initializeEvents(bufferSize uint, tout int) {
+func initializeEvents(bufferSize uint, tout int, maxQueued int) {
 	if gEventProcessor != nil {
 		panic("should not be called twice")
 	}
 
-	gEventProcessor = &eventProcessor{eventConsumers: make(map[pb.EventType]handlerList), eventChannel: make(chan *pb.Event, bufferSize), timeout: tout}
+	gEventProcessor = &eventProcessor{eventConsumers: make(map[pb.EventType]handlerList), eventChannel: make(chan *pb.Event, bufferSize), timeout: tout, maxQueuedEvents: maxQueued}
 
 	addInternalEventTypes()
 
@@ -263,48 +273,73 @@ func AddEventType(eventType pb.EventType) error {
 	}
 
 	switch eventType {
-	case pb.EventType_BLOCK:
-		gEventProcessor.eventConsumers[eventType] = &genericHandlerList{handlers: make(map[*handler]bool)}
+	case pb.EventType_BLOCK, pb.EventType_REJECTION, pb.EventType_TRANSACTION_STATUS:
+		gEventProcessor.eventConsumers[eventType] = &genericHandlerList{handlers: make(map[*handler][]*eventFilter)}
 	case pb.EventType_CHAINCODE:
-		gEventProcessor.eventConsumers[eventType] = &chaincodeHandlerList{handlers: make(map[string]map[string]map[*handler]bool)}
-	case pb.EventType_REJECTION:
-		gEventProcessor.eventConsumers[eventType] = &genericHandlerList{handlers: make(map[*handler]bool)}
+		gEventProcessor.eventConsumers[eventType] = &chaincodeHandlerList{handlers: make(map[string]map[*handler][]*eventFilter)}
 	}
 	gEventProcessor.Unlock()
 
 	return nil
 }
 
-func registerHandler(ie *pb.Interest, h *handler) error {
+func registerHandler(f *eventFilter, h *handler) error {
+	ie := f.interest
 	producerLogger.Debugf("registerHandler %s", ie.EventType)
 
 	gEventProcessor.Lock()
 	defer gEventProcessor.Unlock()
 	if hl, ok := gEventProcessor.eventConsumers[ie.EventType]; !ok {
 		return fmt.Errorf("event type %s does not exist", ie.EventType)
-	} else if _, err := hl.add(ie, h); err != nil {
+	} else if _, err := hl.add(f, h); err != nil {
 		return fmt.Errorf("error registering handler for  %s: %s", ie.EventType, err)
 	}
 
 	return nil
 }
 
-func deRegisterHandler(ie *pb.Interest, h *handler) error {
+func deRegisterHandler(f *eventFilter, h *handler) error {
+	ie := f.interest
 	producerLogger.Debugf("deRegisterHandler %s", ie.EventType)
 
 	gEventProcessor.Lock()
 	defer gEventProcessor.Unlock()
 	if hl, ok := gEventProcessor.eventConsumers[ie.EventType]; !ok {
 		return fmt.Errorf("event type %s does not exist", ie.EventType)
-	} else if _, err := hl.del(ie, h); err != nil {
+	} else if _, err := hl.del(f, h); err != nil {
 		return fmt.Errorf("error deregistering handler for %s: %s", ie.EventType, err)
 	}
 
 	return nil
 }
 
+func getBlockSource() BlockSource {
+	gEventProcessor.RLock()
+	defer gEventProcessor.RUnlock()
+	return gEventProcessor.blockSource
+}
+
+func getMaxQueuedEvents() int {
+	return gEventProcessor.maxQueuedEvents
+}
+
 //------------- producer API's -------------------------------
 
+//BlockSource gives access to the committed blocks, whose events are replayed
+//to consumers registering from a starting block
+type BlockSource interface {
+	GetBlockchainSize() uint64
+	GetBlockByNumber(blockNumber uint64) (*pb.Block, error)
+}
+
+//SetBlockSource sets the source of the blocks replayed to consumers. Without
+//a source, consumers only receive new events
+func SetBlockSource(source BlockSource) {
+	gEventProcessor.Lock()
+	gEventProcessor.blockSource = source
+	gEventProcessor.Unlock()
+}
+
 //Send sends the event to interested consumers
 func Send(e *pb.Event) error {
 	if e.Event == nil {
@@ -0,0 +1,85 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package producer
+
+import (
+	"fmt"
+	"regexp"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+//eventFilter matches events against an Interest registered by a consumer
+type eventFilter struct {
+	interest *pb.Interest
+	//set if the chaincode event name of the interest is a regular expression
+	eventNameRegex *regexp.Regexp
+}
+
+func newEventFilter(ie *pb.Interest) (*eventFilter, error) {
+	f := &eventFilter{interest: ie}
+	if ccReg := ie.GetChaincodeRegInfo(); ccReg != nil && ccReg.IsRegex {
+		//the whole event name has to match
+		regex, err := regexp.Compile("^(?:" + ccReg.EventName + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid event name regular expression %s: %s", ccReg.EventName, err)
+		}
+		f.eventNameRegex = regex
+	}
+	return f, nil
+}
+
+func (f *eventFilter) matches(e *pb.Event) bool {
+	if getMessageType(e) != f.interest.EventType {
+		return false
+	}
+	if f.interest.TxID != "" && !eventHasTransaction(e, f.interest.TxID) {
+		return false
+	}
+	ccEvent := e.GetChaincodeEvent()
+	if ccEvent == nil {
+		return true
+	}
+	ccReg := f.interest.GetChaincodeRegInfo()
+	if ccReg == nil || ccReg.ChaincodeID != ccEvent.ChaincodeID {
+		return false
+	}
+	if f.eventNameRegex != nil {
+		return f.eventNameRegex.MatchString(ccEvent.EventName)
+	}
+	return ccReg.EventName == "" || ccReg.EventName == ccEvent.EventName
+}
+
+func eventHasTransaction(e *pb.Event, txID string) bool {
+	switch x := e.Event.(type) {
+	case *pb.Event_Block:
+		for _, tx := range x.Block.GetTransactions() {
+			if tx.Uuid == txID {
+				return true
+			}
+		}
+		return false
+	case *pb.Event_ChaincodeEvent:
+		return x.ChaincodeEvent.TxID == txID
+	case *pb.Event_Rejection:
+		return x.Rejection.Tx != nil && x.Rejection.Tx.Uuid == txID
+	case *pb.Event_TransactionStatus:
+		return x.TransactionStatus.Uuid == txID
+	default:
+		return false
+	}
+}
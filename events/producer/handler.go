@@ -18,6 +18,7 @@ package producer
 
 import (
 	"fmt"
+	"sync"
 
 	pb "github.com/hyperledger/fabric/protos"
 )
@@ -27,28 +28,31 @@ type handler struct {
 	doneChan   chan bool
 	registered bool
 	// PM: this should be a list, add/del, iterate
-	interestedEvents []*pb.Interest
+	interestedEvents []*eventFilter
+
+	//serializes the sends on ChatStream and guards the fields below
+	sendLock sync.Mutex
+	//set while the handler answers a registration and replays past events,
+	//the events produced meanwhile are queued
+	queueing     bool
+	queuedEvents []*pb.Event
+	//set once more events were queued than allowed, the consumer is then
+	//disconnected
+	overflowed bool
 }
 
 func newEventHandler(stream pb.Events_ChatServer) (*handler, error) {
 	d := &handler{
 		ChatStream: stream,
 	}
-	d.doneChan = make(chan bool)
+	//buffered so that Stop does not block the Chat stream from ending,
+	//nobody waits on it
+	d.doneChan = make(chan bool, 1)
 	return d, nil
 }
 
-func (d *handler) addInterest(interest *pb.Interest) {
-	n := len(d.interestedEvents)
-	if n == cap(d.interestedEvents) {
-		// Slice is full; must grow.
-		// We double its size and add 1, so if the size is zero we still grow.
-		newSlice := make([]*pb.Interest, len(d.interestedEvents), 2*len(d.interestedEvents)+1)
-		copy(newSlice, d.interestedEvents)
-		d.interestedEvents = newSlice
-	}
-	d.interestedEvents = d.interestedEvents[0 : n+1]
-	d.interestedEvents[n] = interest
+func (d *handler) addInterest(f *eventFilter) {
+	d.interestedEvents = append(d.interestedEvents, f)
 }
 
 // Stop stops this handler
@@ -63,11 +67,16 @@ func (d *handler) register(iMsg []*pb.Interest) error {
 	//TODO add the handler to the map for the interested events
 	//if successfully done, continue....
 	for _, v := range iMsg {
-		if err := registerHandler(v, d); err != nil {
+		f, err := newEventFilter(v)
+		if err != nil {
+			producerLogger.Errorf("could not register %s: %s", v, err)
+			continue
+		}
+		if err := registerHandler(f, d); err != nil {
 			producerLogger.Errorf("could not register %s", v)
 			continue
 		}
-		d.addInterest(v)
+		d.addInterest(f)
 	}
 
 	return nil
@@ -76,10 +85,9 @@ func (d *handler) register(iMsg []*pb.Interest) error {
 func (d *handler) deregister() {
 	for _, v := range d.interestedEvents {
 		if err := deRegisterHandler(v, d); err != nil {
-			producerLogger.Errorf("could not deregister %s", v)
+			producerLogger.Errorf("could not deregister %s", v.interest)
 			continue
 		}
-		v = nil
 	}
 	// PM the following should release slice and its elements for GC?
 	d.interestedEvents = nil
@@ -93,25 +101,146 @@ func (d *handler) HandleMessage(msg *pb.Event) error {
 		return fmt.Errorf("Invalid object from consumer %v", msg.GetEvent())
 	}
 
+	//new events are queued until the response is sent and the past events
+	//are replayed
+	d.sendLock.Lock()
+	d.queueing = true
+	d.sendLock.Unlock()
+	defer d.sendQueuedEvents()
+
 	if err := d.register(eventsObj.Events); err != nil {
 		return fmt.Errorf("Could not register events %s", err)
 	}
 
-	//TODO return supported events.. for now just return the received msg
-	if err := d.ChatStream.Send(msg); err != nil {
+	//TODO return supported events.. for now just return the received msg,
+	//with the block the consumer receives events from
+	blockSource := getBlockSource()
+	startBlock := eventsObj.StartBlock
+	if blockSource == nil {
+		eventsObj.StartBlock = nil
+	} else if startBlock == nil {
+		eventsObj.StartBlock = &pb.BlockNumber{Number: blockSource.GetBlockchainSize()}
+	}
+	if err := d.send(msg); err != nil {
 		return fmt.Errorf("Error sending response to %v:  %s", msg, err)
 	}
 
 	d.registered = true
 
+	if blockSource != nil && startBlock != nil {
+		if err := d.replay(blockSource, startBlock.Number); err != nil {
+			return fmt.Errorf("Error replaying events: %s", err)
+		}
+	}
+
+	return nil
+}
+
+//replay sends the events of the committed blocks from startBlock on, until
+//the events of the last committed block have been sent
+func (d *handler) replay(blockSource BlockSource, startBlock uint64) error {
+	blockNumber := startBlock
+	for size := blockSource.GetBlockchainSize(); blockNumber < size; size = blockSource.GetBlockchainSize() {
+		for ; blockNumber < size; blockNumber++ {
+			if d.isOverflowed() {
+				return fmt.Errorf("More than %d events produced while replaying the events of blocks %d to %d, disconnecting the consumer", getMaxQueuedEvents(), startBlock, blockNumber)
+			}
+			block, err := blockSource.GetBlockByNumber(blockNumber)
+			if err != nil {
+				//e.g. the block has been pruned
+				producerLogger.Warningf("Could not replay the events of block %d: %s", blockNumber, err)
+				continue
+			}
+			for _, e := range CreateBlockEvents(blockNumber, block) {
+				if !d.isInterested(e) {
+					continue
+				}
+				if err = d.send(e); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	producerLogger.Debugf("Replayed the events of blocks %d to %d", startBlock, blockNumber)
+
+	//the events of the replayed blocks may have been queued as well
+	d.sendLock.Lock()
+	queuedEvents := d.queuedEvents[:0]
+	for _, e := range d.queuedEvents {
+		if !isBlockEvent(e) || e.BlockNumber >= blockNumber {
+			queuedEvents = append(queuedEvents, e)
+		}
+	}
+	d.queuedEvents = queuedEvents
+	d.sendLock.Unlock()
 	return nil
 }
 
+func (d *handler) isInterested(e *pb.Event) bool {
+	for _, f := range d.interestedEvents {
+		if f.matches(e) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *handler) isOverflowed() bool {
+	d.sendLock.Lock()
+	defer d.sendLock.Unlock()
+	return d.overflowed
+}
+
+func (d *handler) sendQueuedEvents() {
+	d.sendLock.Lock()
+	defer d.sendLock.Unlock()
+	for _, e := range d.queuedEvents {
+		if err := d.ChatStream.Send(e); err != nil {
+			producerLogger.Errorf("Error sending queued event: %s", err)
+			break
+		}
+	}
+	d.queuedEvents = nil
+	d.queueing = false
+}
+
+func (d *handler) send(msg *pb.Event) error {
+	d.sendLock.Lock()
+	defer d.sendLock.Unlock()
+	return d.ChatStream.Send(msg)
+}
+
 // SendMessage sends a message to the remote PEER through the stream
 func (d *handler) SendMessage(msg *pb.Event) error {
+	d.sendLock.Lock()
+	defer d.sendLock.Unlock()
+	if d.overflowed {
+		return fmt.Errorf("Consumer fell behind, dropping event")
+	}
+	if d.queueing {
+		if max := getMaxQueuedEvents(); max > 0 && len(d.queuedEvents) >= max {
+			//the consumer gets no gap in its events, it has to register again
+			producerLogger.Warningf("More than %d events queued for a consumer while replaying past events, disconnecting it", max)
+			d.overflowed = true
+			d.queuedEvents = nil
+			return fmt.Errorf("Consumer fell behind by more than %d events", max)
+		}
+		d.queuedEvents = append(d.queuedEvents, msg)
+		return nil
+	}
 	err := d.ChatStream.Send(msg)
 	if err != nil {
 		return fmt.Errorf("Error Sending message through ChatStream: %s", err)
 	}
 	return nil
 }
+
+//isBlockEvent returns true for the events that belong to a block
+func isBlockEvent(e *pb.Event) bool {
+	switch e.Event.(type) {
+	case *pb.Event_Block, *pb.Event_ChaincodeEvent, *pb.Event_TransactionStatus:
+		return true
+	default:
+		return false
+	}
+}
@@ -37,12 +37,12 @@ type EventsServer struct {
 var globalEventsServer *EventsServer
 
 // NewEventsServer returns a EventsServer
-func NewEventsServer(bufferSize uint, timeout int) *EventsServer {
+func NewEventsServer(bufferSize uint, timeout int, maxQueued int) *EventsServer {
 	if globalEventsServer != nil {
 		panic("Cannot create multiple event hub servers")
 	}
 	globalEventsServer = new(EventsServer)
-	initializeEvents(bufferSize, timeout)
+	initializeEvents(bufferSize, timeout, maxQueued)
 	//initializeCCEventProcessor(bufferSize, timeout)
 	return globalEventsServer
 }
@@ -68,7 +68,10 @@ func (p *EventsServer) Chat(stream pb.Events_ChatServer) error {
 		err = handler.HandleMessage(in)
 		if err != nil {
 			producerLogger.Errorf("Error handling message: %s", err)
-			//return err
+			//a consumer too far behind registers again from the block it reached
+			if handler.isOverflowed() {
+				return err
+			}
 		}
 
 	}
@@ -43,6 +43,7 @@ var (
 type ACA struct {
 	*CA
 	gRPCServer *grpc.Server
+	registry   UserRegistry
 }
 
 // ACAA serves the administrator GRPC interface of the ACA.
@@ -66,11 +67,17 @@ func IsAttributeOID(oid asn1.ObjectIdentifier) bool {
 	return ACAAttribute[l-1] < oid[l-1]
 }
 
-func initializeACATables(db *sql.DB) error {
-	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS Attributes (row INTEGER PRIMARY KEY, id VARCHAR(64), affiliation VARCHAR(64), attributeName VARCHAR(64), validFrom DATETIME, validTo DATETIME,  attributeValue BLOB)"); err != nil {
-		return err
-	}
-	return nil
+var acaMigrations = []migration{
+	{1, []string{
+		"CREATE TABLE IF NOT EXISTS Attributes (row INTEGER PRIMARY KEY, id VARCHAR(64), affiliation VARCHAR(64), attributeName VARCHAR(64), validFrom DATETIME, validTo DATETIME,  attributeValue BLOB)",
+	}},
+	{2, []string{
+		"CREATE UNIQUE INDEX AttributesByOwner ON Attributes (id, affiliation, attributeName)",
+	}},
+}
+
+func initializeACATables(db *sql.DB, dialect *sqlDialect) error {
+	return migrate(db, dialect, "aca", acaMigrations)
 }
 
 //AttributeOwner is the struct that contains the data related with the user who owns the attribute.
@@ -221,6 +228,12 @@ func (attrPair *AttributePair) ToACAAttribute() *pb.ACAAttribute {
 func NewACA() *ACA {
 	aca := &ACA{CA: NewCA("aca", initializeACATables)}
 
+	registry, err := newUserRegistry()
+	if err != nil {
+		Panic.Panicln(err)
+	}
+	aca.registry = registry
+
 	return aca
 }
 
@@ -241,7 +254,11 @@ func (aca *ACA) getTCACertificate() (*x509.Certificate, error) {
 }
 
 func (aca *ACA) fetchAttributes(id, affiliation string) ([]*AttributePair, error) {
-	// TODO this attributes should be readed from the outside world in place of configuration file.
+	if aca.registry != nil {
+		return aca.registry.ReadAttributes(id, affiliation)
+	}
+
+	// Without user registry, attributes are read from the configuration file.
 	var attributes = make([]*AttributePair, 0)
 	attrs := viper.GetStringMapString("aca.attributes")
 
@@ -299,7 +316,7 @@ func (aca *ACA) populateAttributes(attrs []*AttributePair) error {
 
 func (aca *ACA) populateAttribute(tx *sql.Tx, attr *AttributePair) error {
 	var count int
-	err := tx.QueryRow("SELECT count(row) AS cant FROM Attributes WHERE id=? AND affiliation =? AND attributeName =?",
+	err := tx.QueryRow(aca.rebind("SELECT count(row) AS cant FROM Attributes WHERE id=? AND affiliation =? AND attributeName =?"),
 		attr.GetID(), attr.GetAffiliation(), attr.GetAttributeName()).Scan(&count)
 
 	if err != nil {
@@ -307,13 +324,13 @@ func (aca *ACA) populateAttribute(tx *sql.Tx, attr *AttributePair) error {
 	}
 
 	if count > 0 {
-		_, err = tx.Exec("UPDATE Attributes SET validFrom = ?, validTo = ?,  attributeValue = ? WHERE  id=? AND affiliation =? AND attributeName =? AND validFrom < ?",
+		_, err = tx.Exec(aca.rebind("UPDATE Attributes SET validFrom = ?, validTo = ?,  attributeValue = ? WHERE  id=? AND affiliation =? AND attributeName =? AND validFrom < ?"),
 			attr.GetValidFrom(), attr.GetValidTo(), attr.GetAttributeValue(), attr.GetID(), attr.GetAffiliation(), attr.GetAttributeName(), attr.GetValidFrom())
 		if err != nil {
 			return err
 		}
 	} else {
-		_, err = tx.Exec("INSERT INTO Attributes (validFrom , validTo,  attributeValue, id, affiliation, attributeName) VALUES (?,?,?,?,?,?)",
+		_, err = tx.Exec(aca.rebind("INSERT INTO Attributes (validFrom , validTo,  attributeValue, id, affiliation, attributeName) VALUES (?,?,?,?,?,?)"),
 			attr.GetValidFrom(), attr.GetValidTo(), attr.GetAttributeValue(), attr.GetID(), attr.GetAffiliation(), attr.GetAttributeName())
 		if err != nil {
 			return err
@@ -341,7 +358,7 @@ func (aca *ACA) findAttribute(owner *AttributeOwner, attributeName string) (*Att
 	mutex.RLock()
 	defer mutex.RUnlock()
 
-	err := aca.db.QueryRow("SELECT count(row) AS cant FROM Attributes WHERE id=? AND affiliation =? AND attributeName =?",
+	err := aca.db.QueryRow(aca.rebind("SELECT count(row) AS cant FROM Attributes WHERE id=? AND affiliation =? AND attributeName =?"),
 		owner.GetID(), owner.GetAffiliation(), attributeName).Scan(&count)
 	if err != nil {
 		return nil, err
@@ -354,7 +371,7 @@ func (aca *ACA) findAttribute(owner *AttributeOwner, attributeName string) (*Att
 	var attName string
 	var attValue []byte
 	var validFrom, validTo time.Time
-	err = aca.db.QueryRow("SELECT attributeName, attributeValue, validFrom, validTo AS cant FROM Attributes WHERE id=? AND affiliation =? AND attributeName =?",
+	err = aca.db.QueryRow(aca.rebind("SELECT attributeName, attributeValue, validFrom, validTo AS cant FROM Attributes WHERE id=? AND affiliation =? AND attributeName =?"),
 		owner.GetID(), owner.GetAffiliation(), attributeName).Scan(&attName, &attValue, &validFrom, &validTo)
 	if err != nil {
 		return nil, err
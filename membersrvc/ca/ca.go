@@ -36,6 +36,7 @@ import (
 	"time"
 
 	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/util"
 	pb "github.com/hyperledger/fabric/membersrvc/protos"
 
 	_ "github.com/mattn/go-sqlite3" // This blank import is required to load sqlite3 driver
@@ -44,8 +45,10 @@ import (
 
 // CA is the base certificate authority.
 type CA struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect *sqlDialect
 
+	name string
 	path string
 
 	priv *ecdsa.PrivateKey
@@ -63,6 +66,7 @@ type CertificateSpec struct {
 	NotBefore    *time.Time
 	NotAfter     *time.Time
 	ext          *[]pkix.Extension
+	suite        *primitives.CryptoSuite
 }
 
 // AffiliationGroup struct
@@ -128,6 +132,8 @@ func CacheConfiguration() {
 	caCountry = viper.GetString("pki.ca.subject.country")
 	rootPath = viper.GetString("server.rootpath")
 	caDir = viper.GetString("server.cadir")
+	dbDriver = viper.GetString("server.db.driver")
+	dbDSN = viper.GetString("server.db.dsn")
 }
 
 // GetID returns the spec's ID field/value
@@ -202,25 +208,104 @@ func (spec *CertificateSpec) GetExtensions() *[]pkix.Extension {
 	return spec.ext
 }
 
-// TableInitializer is a function type for table initialization
-type TableInitializer func(*sql.DB) error
+// SetCryptoSuite sets the crypto suite the subject works with
+//
+func (spec *CertificateSpec) SetCryptoSuite(suite *primitives.CryptoSuite) {
+	spec.suite = suite
+}
 
-func initializeCommonTables(db *sql.DB) error {
-	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS Certificates (row INTEGER PRIMARY KEY, id VARCHAR(64), timestamp INTEGER, usage INTEGER, cert BLOB, hash BLOB, kdfkey BLOB)"); err != nil {
-		return err
+// GetCryptoSuite returns the crypto suite the subject works with, that of the CAs if not set
+//
+func (spec *CertificateSpec) GetCryptoSuite() *primitives.CryptoSuite {
+	if spec.suite == nil {
+		return primitives.GetDefaultCryptoSuite()
 	}
-	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS Users (row INTEGER PRIMARY KEY, id VARCHAR(64), enrollmentId VARCHAR(100), role INTEGER, metadata VARCHAR(256), token BLOB, state INTEGER, key BLOB)"); err != nil {
-		return err
+	return spec.suite
+}
+
+// getSubjectCryptoSuite returns the crypto suite a subject names in its request, that of the
+// CAs if it names none. The suite must be allowed by security.suites.allowed and use the curve
+// of the key of the subject.
+//
+func getSubjectCryptoSuite(name string, pub *ecdsa.PublicKey) (*primitives.CryptoSuite, error) {
+	if name == "" {
+		return primitives.GetDefaultCryptoSuite(), nil
 	}
-	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS AffiliationGroups (row INTEGER PRIMARY KEY, name VARCHAR(64), parent INTEGER, FOREIGN KEY(parent) REFERENCES AffiliationGroups(row))"); err != nil {
-		return err
+	if !isCryptoSuiteAllowed(name) {
+		return nil, fmt.Errorf("Crypto suite %s is not allowed.", name)
 	}
-	return nil
+	suite, err := primitives.GetCryptoSuite(name)
+	if err != nil {
+		return nil, err
+	}
+	if pub.Curve.Params().Name != suite.Curve.Params().Name {
+		return nil, fmt.Errorf("Key is not on the curve of crypto suite %s.", name)
+	}
+	return suite, nil
+}
+
+// isCryptoSuiteAllowed returns true if the CAs issue certificates for the crypto suite, by
+// default only for their own.
+//
+func isCryptoSuiteAllowed(name string) bool {
+	allowed := viper.GetStringSlice("security.suites.allowed")
+	if len(allowed) == 0 {
+		return name == primitives.GetDefaultCryptoSuite().Name
+	}
+	for _, suite := range allowed {
+		if suite == name {
+			return true
+		}
+	}
+	return false
+}
+
+// getCertCryptoSuite returns the crypto suite a certificate advertises, that of the CAs for
+// certificates advertising none.
+//
+func getCertCryptoSuite(cert *x509.Certificate) (*primitives.CryptoSuite, error) {
+	suite, err := primitives.GetCertificateCryptoSuite(cert)
+	if err != nil || suite != nil {
+		return suite, err
+	}
+	return primitives.GetDefaultCryptoSuite(), nil
+}
+
+// TableInitializer is a function type for table initialization
+type TableInitializer func(*sql.DB, *sqlDialect) error
+
+var commonMigrations = []migration{
+	{1, []string{
+		"CREATE TABLE IF NOT EXISTS Certificates (row INTEGER PRIMARY KEY, id VARCHAR(64), timestamp INTEGER, usage INTEGER, cert BLOB, hash BLOB, kdfkey BLOB)",
+		"CREATE TABLE IF NOT EXISTS Users (row INTEGER PRIMARY KEY, id VARCHAR(64), enrollmentId VARCHAR(100), role INTEGER, metadata VARCHAR(256), token BLOB, state INTEGER, key BLOB)",
+		"CREATE TABLE IF NOT EXISTS AffiliationGroups (row INTEGER PRIMARY KEY, name VARCHAR(64), parent INTEGER, FOREIGN KEY(parent) REFERENCES AffiliationGroups(row))",
+		"CREATE TABLE IF NOT EXISTS RevokedCertificates (row INTEGER PRIMARY KEY, id VARCHAR(64), serial VARCHAR(64), timestamp INTEGER, hash BLOB)",
+	}},
+	// Replicas sharing the database rely on the unique indexes to register users and
+	// groups once, top level groups have no parent rather than the parent 0
+	{2, []string{
+		"UPDATE AffiliationGroups SET parent=NULL WHERE parent=0",
+		"CREATE UNIQUE INDEX UsersByID ON Users (id)",
+		"CREATE UNIQUE INDEX AffiliationGroupsByName ON AffiliationGroups (name)",
+		"CREATE INDEX CertificatesByID ON Certificates (id)",
+	}},
+	{3, []string{
+		"ALTER TABLE Users ADD COLUMN disabled INTEGER DEFAULT 0",
+	}},
+	// Users enrolled with the password of the user registry
+	{4, []string{
+		"ALTER TABLE Users ADD COLUMN registry INTEGER DEFAULT 0",
+	}},
+}
+
+func initializeCommonTables(db *sql.DB, dialect *sqlDialect) error {
+	return migrate(db, dialect, "common", commonMigrations)
 }
 
 // NewCA sets up a new CA.
 func NewCA(name string, initTables TableInitializer) *CA {
 	ca := new(CA)
+	ca.name = name
 	ca.path = filepath.Join(rootPath, caDir)
 
 	if _, err := os.Stat(ca.path); err != nil {
@@ -232,19 +317,16 @@ func NewCA(name string, initTables TableInitializer) *CA {
 	}
 
 	// open or create certificate database
-	db, err := sql.Open("sqlite3", ca.path+"/"+name+".db")
+	db, dialect, err := openDB(name, ca.path)
 	if err != nil {
 		Panic.Panicln(err)
 	}
 
-	if err = db.Ping(); err != nil {
-		Panic.Panicln(err)
-	}
-
-	if err = initTables(db); err != nil {
+	if err = initTables(db, dialect); err != nil {
 		Panic.Panicln(err)
 	}
 	ca.db = db
+	ca.dialect = dialect
 
 	// read or create signing key pair
 	priv, err := ca.readCAPrivateKey(name)
@@ -269,6 +351,12 @@ func NewCA(name string, initTables TableInitializer) *CA {
 	return ca
 }
 
+// rebind rewrites a statement for the database of the CA.
+//
+func (ca *CA) rebind(query string) string {
+	return ca.dialect.rebind(query)
+}
+
 // Close closes down the CA.
 func (ca *CA) Stop() error {
 	err := ca.db.Close()
@@ -362,7 +450,7 @@ func (ca *CA) readCACertificate(name string) ([]byte, error) {
 }
 
 func (ca *CA) createCertificate(id string, pub interface{}, usage x509.KeyUsage, timestamp int64, kdfKey []byte, opt ...pkix.Extension) ([]byte, error) {
-	spec := NewDefaultCertificateSpec(id, pub, usage, opt...)
+	spec := NewDefaultPeriodCertificateSpec(id, util.GenerateIntUUID(), pub, usage, opt...)
 	return ca.createCertificateFromSpec(spec, timestamp, kdfKey, true)
 }
 
@@ -390,7 +478,7 @@ func (ca *CA) persistCertificate(id string, timestamp int64, usage x509.KeyUsage
 	hash.Write(certRaw)
 	var err error
 
-	if _, err = ca.db.Exec("INSERT INTO Certificates (id, timestamp, usage, cert, hash, kdfkey) VALUES (?, ?, ?, ?, ?, ?)", id, timestamp, usage, certRaw, hash.Sum(nil), kdfKey); err != nil {
+	if _, err = ca.db.Exec(ca.rebind("INSERT INTO Certificates (id, timestamp, usage, cert, hash, kdfkey) VALUES (?, ?, ?, ?, ?, ?)"), id, timestamp, usage, certRaw, hash.Sum(nil), kdfKey); err != nil {
 		Error.Println(err)
 	}
 	return err
@@ -430,6 +518,14 @@ func (ca *CA) newCertificateFromSpec(spec *CertificateSpec) ([]byte, error) {
 		tmpl.Extensions = *spec.GetExtensions()
 		tmpl.ExtraExtensions = *spec.GetExtensions()
 	}
+
+	// Advertise the crypto suite of the subject
+	suiteExt, err := primitives.NewCryptoSuiteExtension(spec.GetCryptoSuite())
+	if err != nil {
+		return nil, err
+	}
+	tmpl.ExtraExtensions = append(tmpl.ExtraExtensions, suiteExt)
+
 	if isCA {
 		parent = &tmpl
 	}
@@ -455,7 +551,7 @@ func (ca *CA) readCertificateByKeyUsage(id string, usage x509.KeyUsage) ([]byte,
 	defer mutex.RUnlock()
 
 	var raw []byte
-	err := ca.db.QueryRow("SELECT cert FROM Certificates WHERE id=? AND usage=?", id, usage).Scan(&raw)
+	err := ca.db.QueryRow(ca.rebind("SELECT cert FROM Certificates WHERE id=? AND usage=?"), id, usage).Scan(&raw)
 
 	if err != nil {
 		Trace.Printf("readCertificateByKeyUsage() Error: %v", err)
@@ -471,7 +567,7 @@ func (ca *CA) readCertificateByTimestamp(id string, ts int64) ([]byte, error) {
 	defer mutex.RUnlock()
 
 	var raw []byte
-	err := ca.db.QueryRow("SELECT cert FROM Certificates WHERE id=? AND timestamp=?", id, ts).Scan(&raw)
+	err := ca.db.QueryRow(ca.rebind("SELECT cert FROM Certificates WHERE id=? AND timestamp=?"), id, ts).Scan(&raw)
 
 	return raw, err
 }
@@ -483,10 +579,10 @@ func (ca *CA) readCertificates(id string, opt ...int64) (*sql.Rows, error) {
 	defer mutex.RUnlock()
 
 	if len(opt) > 0 && opt[0] != 0 {
-		return ca.db.Query("SELECT cert, kdfkey FROM Certificates WHERE id=? AND timestamp=? ORDER BY usage", id, opt[0])
+		return ca.db.Query(ca.rebind("SELECT cert, kdfkey FROM Certificates WHERE id=? AND timestamp=? ORDER BY usage"), id, opt[0])
 	}
 
-	return ca.db.Query("SELECT cert, kdfkey FROM Certificates WHERE id=?", id)
+	return ca.db.Query(ca.rebind("SELECT cert, kdfkey FROM Certificates WHERE id=? ORDER BY timestamp DESC, usage"), id)
 }
 
 func (ca *CA) readCertificateSets(id string, start, end int64) (*sql.Rows, error) {
@@ -495,7 +591,7 @@ func (ca *CA) readCertificateSets(id string, start, end int64) (*sql.Rows, error
 	mutex.RLock()
 	defer mutex.RUnlock()
 
-	return ca.db.Query("SELECT cert, kdfKey, timestamp FROM Certificates WHERE id=? AND timestamp BETWEEN ? AND ? ORDER BY timestamp", id, start, end)
+	return ca.db.Query(ca.rebind("SELECT cert, kdfKey, timestamp FROM Certificates WHERE id=? AND timestamp BETWEEN ? AND ? ORDER BY timestamp"), id, start, end)
 }
 
 func (ca *CA) readCertificateByHash(hash []byte) ([]byte, error) {
@@ -505,12 +601,203 @@ func (ca *CA) readCertificateByHash(hash []byte) ([]byte, error) {
 	defer mutex.RUnlock()
 
 	var raw []byte
-	row := ca.db.QueryRow("SELECT cert FROM Certificates WHERE hash=?", hash)
+	row := ca.db.QueryRow(ca.rebind("SELECT cert FROM Certificates WHERE hash=?"), hash)
 	err := row.Scan(&raw)
 
 	return raw, err
 }
 
+func (ca *CA) readCertificateOwner(raw []byte) (string, int64, error) {
+	Trace.Println("Reading owner of certificate.")
+
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	hash := primitives.NewHash()
+	hash.Write(raw)
+
+	var id string
+	var timestamp int64
+	err := ca.db.QueryRow(ca.rebind("SELECT id, timestamp FROM Certificates WHERE hash=?"), hash.Sum(nil)).Scan(&id, &timestamp)
+
+	return id, timestamp, err
+}
+
+// errCertificateRevoked is returned when revoking a certificate which has
+// already been revoked.
+var errCertificateRevoked = errors.New("Certificate is already revoked")
+
+// revokeCertificate records the revocation of a certificate issued to id.
+// The revocation becomes visible to peers with the next published CRL.
+//
+func (ca *CA) revokeCertificate(id string, cert *x509.Certificate) error {
+	Trace.Printf("Revoking certificate %v of %s.", cert.SerialNumber, id)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	tx, err := ca.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	var count int
+	if err = tx.QueryRow(ca.rebind("SELECT count(row) FROM RevokedCertificates WHERE serial=?"), cert.SerialNumber.String()).Scan(&count); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if count > 0 {
+		tx.Rollback()
+		return errCertificateRevoked
+	}
+
+	hash := primitives.NewHash()
+	hash.Write(cert.Raw)
+
+	if _, err = tx.Exec(ca.rebind("INSERT INTO RevokedCertificates (id, serial, timestamp, hash) VALUES (?, ?, ?, ?)"), id, cert.SerialNumber.String(), time.Now().Unix(), hash.Sum(nil)); err != nil {
+		Error.Println(err)
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// revokeSerialNumbers records the revocation of certificates issued to id
+// that are known only by their serial numbers (e.g. TCerts).
+//
+func (ca *CA) revokeSerialNumbers(id string, serials []*big.Int) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	tx, err := ca.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	for _, serial := range serials {
+		var count int
+		if err = tx.QueryRow(ca.rebind("SELECT count(row) FROM RevokedCertificates WHERE serial=?"), serial.String()).Scan(&count); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err = tx.Exec(ca.rebind("INSERT INTO RevokedCertificates (id, serial, timestamp) VALUES (?, ?, ?)"), id, serial.String(), now); err != nil {
+			Error.Println(err)
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (ca *CA) isRevoked(serial *big.Int) (bool, error) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	var count int
+	err := ca.db.QueryRow(ca.rebind("SELECT count(row) FROM RevokedCertificates WHERE serial=?"), serial.String()).Scan(&count)
+
+	return count > 0, err
+}
+
+func (ca *CA) readRevokedCertificates() ([]pkix.RevokedCertificate, error) {
+	Trace.Println("Reading revoked certificates.")
+
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	rows, err := ca.db.Query(ca.rebind("SELECT serial, timestamp FROM RevokedCertificates ORDER BY timestamp"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revoked []pkix.RevokedCertificate
+	for rows.Next() {
+		var serial string
+		var timestamp int64
+		if err = rows.Scan(&serial, &timestamp); err != nil {
+			return nil, err
+		}
+
+		serialNumber, ok := new(big.Int).SetString(serial, 10)
+		if !ok {
+			return nil, errors.New("Invalid serial number " + serial)
+		}
+		revoked = append(revoked, pkix.RevokedCertificate{SerialNumber: serialNumber, RevocationTime: time.Unix(timestamp, 0).UTC()})
+	}
+
+	return revoked, rows.Err()
+}
+
+// createCRL creates a new certificate revocation list signed by the CA and
+// stores it next to the CA certificate.
+//
+func (ca *CA) createCRL() ([]byte, error) {
+	Trace.Println("Creating CRL.")
+
+	revoked, err := ca.readRevokedCertificates()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	raw, err := ca.cert.CreateCRL(rand.Reader, ca.priv, revoked, now, now.Add(crlValidity()))
+	if err != nil {
+		Error.Println(err)
+		return nil, err
+	}
+
+	cooked := pem.EncodeToMemory(
+		&pem.Block{
+			Type:  "X509 CRL",
+			Bytes: raw,
+		})
+	if err = ioutil.WriteFile(ca.path+"/"+ca.name+".crl", cooked, 0644); err != nil {
+		Error.Println(err)
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// readCRL reads the last CRL published by the CA. A nil CRL is returned if
+// none has been published yet.
+//
+func (ca *CA) readCRL() ([]byte, error) {
+	Trace.Println("Reading CRL.")
+
+	cooked, err := ioutil.ReadFile(ca.path + "/" + ca.name + ".crl")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	block, _ := pem.Decode(cooked)
+	if block == nil {
+		return nil, errors.New("Invalid CRL file " + ca.name + ".crl")
+	}
+	return block.Bytes, nil
+}
+
+// crlValidity returns the period after which a published CRL should be
+// considered stale.
+//
+func crlValidity() time.Duration {
+	validity := viper.GetDuration("pki.ca.crl.validity")
+	if validity <= 0 {
+		validity = 24 * time.Hour
+	}
+	return validity
+}
+
 func (ca *CA) isValidAffiliation(affiliation string) (bool, error) {
 	Trace.Println("Validating affiliation: " + affiliation)
 
@@ -519,7 +806,7 @@ func (ca *CA) isValidAffiliation(affiliation string) (bool, error) {
 
 	var count int
 	var err error
-	err = ca.db.QueryRow("SELECT count(row) FROM AffiliationGroups WHERE name=?", affiliation).Scan(&count)
+	err = ca.db.QueryRow(ca.rebind("SELECT count(row) FROM AffiliationGroups WHERE name=?"), affiliation).Scan(&count)
 	if err != nil {
 		Trace.Println("Affiliation <" + affiliation + "> is INVALID.")
 
@@ -601,16 +888,18 @@ func (ca *CA) registerUser(id, affiliation string, role pb.Role, registrar, memb
 	if err != nil {
 		return "", err
 	}
-	tok, err = ca.registerUserWithEnrollID(id, enrollID, role, memberMetadata, opt...)
+	tok, err = ca.registerUserWithEnrollID(id, enrollID, role, memberMetadata, false, opt...)
 	if err != nil {
 		return "", err
 	}
 	return tok, nil
 }
 
-// registerUserWithEnrollID registers a new user and its enrollmentID, role and state
+// registerUserWithEnrollID registers a new user and its enrollmentID, role and state.
+// fromRegistry marks the users of the user registry, who enroll with their directory
+// password.
 //
-func (ca *CA) registerUserWithEnrollID(id string, enrollID string, role pb.Role, memberMetadata string, opt ...string) (string, error) {
+func (ca *CA) registerUserWithEnrollID(id string, enrollID string, role pb.Role, memberMetadata string, fromRegistry bool, opt ...string) (string, error) {
 	mutex.Lock()
 	defer mutex.Unlock()
 
@@ -625,12 +914,16 @@ func (ca *CA) registerUserWithEnrollID(id string, enrollID string, role pb.Role,
 	}
 
 	var row int
-	err := ca.db.QueryRow("SELECT row FROM Users WHERE id=?", id).Scan(&row)
+	err := ca.db.QueryRow(ca.rebind("SELECT row FROM Users WHERE id=?"), id).Scan(&row)
 	if err == nil {
 		return "", errors.New("User is already registered")
 	}
 
-	_, err = ca.db.Exec("INSERT INTO Users (id, enrollmentId, token, role, metadata, state) VALUES (?, ?, ?, ?, ?, ?)", id, enrollID, tok, role, memberMetadata, 0)
+	registry := 0
+	if fromRegistry {
+		registry = 1
+	}
+	_, err = ca.db.Exec(ca.rebind("INSERT INTO Users (id, enrollmentId, token, role, metadata, state, registry) VALUES (?, ?, ?, ?, ?, ?, ?)"), id, enrollID, tok, role, memberMetadata, 0, registry)
 
 	if err != nil {
 		Error.Println(err)
@@ -647,10 +940,10 @@ func (ca *CA) registerAffiliationGroup(name string, parentName string) error {
 
 	Trace.Println("Registering affiliation group " + name + " parent " + parentName + ".")
 
-	var parentID int
+	var parentID sql.NullInt64
 	var err error
 	var count int
-	err = ca.db.QueryRow("SELECT count(row) FROM AffiliationGroups WHERE name=?", name).Scan(&count)
+	err = ca.db.QueryRow(ca.rebind("SELECT count(row) FROM AffiliationGroups WHERE name=?"), name).Scan(&count)
 	if err != nil {
 		return err
 	}
@@ -659,13 +952,14 @@ func (ca *CA) registerAffiliationGroup(name string, parentName string) error {
 	}
 
 	if strings.Compare(parentName, "") != 0 {
-		err = ca.db.QueryRow("SELECT row FROM AffiliationGroups WHERE name=?", parentName).Scan(&parentID)
+		err = ca.db.QueryRow(ca.rebind("SELECT row FROM AffiliationGroups WHERE name=?"), parentName).Scan(&parentID)
 		if err != nil {
 			return err
 		}
 	}
 
-	_, err = ca.db.Exec("INSERT INTO AffiliationGroups (name, parent) VALUES (?, ?)", name, parentID)
+	// A replica registering the same group at the same time makes the insert fail
+	_, err = ca.db.Exec(ca.rebind("INSERT INTO AffiliationGroups (name, parent) VALUES (?, ?)"), name, parentID)
 
 	if err != nil {
 		Error.Println(err)
@@ -684,14 +978,14 @@ func (ca *CA) deleteUser(id string) error {
 	defer mutex.Unlock()
 
 	var row int
-	err := ca.db.QueryRow("SELECT row FROM Users WHERE id=?", id).Scan(&row)
+	err := ca.db.QueryRow(ca.rebind("SELECT row FROM Users WHERE id=?"), id).Scan(&row)
 	if err == nil {
-		_, err = ca.db.Exec("DELETE FROM Certificates Where id=?", id)
+		_, err = ca.db.Exec(ca.rebind("DELETE FROM Certificates Where id=?"), id)
 		if err != nil {
 			Error.Println(err)
 		}
 
-		_, err = ca.db.Exec("DELETE FROM Users WHERE row=?", row)
+		_, err = ca.db.Exec(ca.rebind("DELETE FROM Users WHERE row=?"), row)
 		if err != nil {
 			Error.Println(err)
 		}
@@ -700,6 +994,153 @@ func (ca *CA) deleteUser(id string) error {
 	return err
 }
 
+// updateUser changes the role and enrollment ID of a user
+//
+func (ca *CA) updateUser(id, enrollID string, role pb.Role) error {
+	Trace.Println("Updating user " + id + ".")
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	_, err := ca.db.Exec(ca.rebind("UPDATE Users SET role=?, enrollmentId=? WHERE id=?"), role, enrollID, id)
+	if err != nil {
+		Error.Println(err)
+	}
+
+	return err
+}
+
+// setUserDisabled disables or re-enables the enrollment of a user
+//
+func (ca *CA) setUserDisabled(id string, disabled bool) error {
+	Trace.Printf("Setting disabled of user %s to %t.\n", id, disabled)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	value := 0
+	if disabled {
+		value = 1
+	}
+	_, err := ca.db.Exec(ca.rebind("UPDATE Users SET disabled=? WHERE id=?"), value, id)
+	if err != nil {
+		Error.Println(err)
+	}
+
+	return err
+}
+
+// isUserDisabled returns whether the enrollment of a user is disabled
+//
+func (ca *CA) isUserDisabled(id string) (bool, error) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	var disabled int
+	err := ca.db.QueryRow(ca.rebind("SELECT disabled FROM Users WHERE id=?"), id).Scan(&disabled)
+
+	return disabled != 0, err
+}
+
+// isRegistryUser returns whether the user was registered from the user registry.
+//
+func (ca *CA) isRegistryUser(id string) (bool, error) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	var registry int
+	err := ca.db.QueryRow(ca.rebind("SELECT registry FROM Users WHERE id=?"), id).Scan(&registry)
+
+	return registry != 0, err
+}
+
+// resetUser deletes the certificates of a user and gives it a new token to enroll with
+//
+func (ca *CA) resetUser(id string) (string, error) {
+	Trace.Println("Resetting enrollment of user " + id + ".")
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	tx, err := ca.db.Begin()
+	if err != nil {
+		return "", err
+	}
+
+	tok := randomString(12)
+	if _, err = tx.Exec(ca.rebind("DELETE FROM Certificates WHERE id=?"), id); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+	if _, err = tx.Exec(ca.rebind("UPDATE Users SET token=?, state=?, key=NULL WHERE id=?"), tok, 0, id); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	return tok, tx.Commit()
+}
+
+// deleteAffiliationGroup deletes an affiliation group without subgroups or members
+//
+func (ca *CA) deleteAffiliationGroup(name string) error {
+	Trace.Println("Deleting affiliation group " + name + ".")
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	tx, err := ca.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var row int64
+	if err = tx.QueryRow(ca.rebind("SELECT row FROM AffiliationGroups WHERE name=?"), name).Scan(&row); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("Affiliation group is not registered")
+		}
+		return err
+	}
+
+	var count int
+	if err = tx.QueryRow(ca.rebind("SELECT count(row) FROM AffiliationGroups WHERE parent=?"), row).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("Affiliation group has subgroups")
+	}
+
+	rows, err := tx.Query(ca.rebind("SELECT enrollmentId FROM Users"))
+	if err != nil {
+		return err
+	}
+	members := 0
+	for rows.Next() {
+		var enrollID string
+		if err = rows.Scan(&enrollID); err != nil {
+			rows.Close()
+			return err
+		}
+		if strings.HasSuffix(enrollID, "\\"+name) {
+			members++
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if members > 0 {
+		return errors.New("Affiliation group has members")
+	}
+
+	if _, err = tx.Exec(ca.rebind("DELETE FROM AffiliationGroups WHERE row=?"), row); err != nil {
+		Error.Println(err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
 // readUser reads a token given an id
 //
 func (ca *CA) readUser(id string) *sql.Row {
@@ -708,7 +1149,7 @@ func (ca *CA) readUser(id string) *sql.Row {
 	mutex.RLock()
 	defer mutex.RUnlock()
 
-	return ca.db.QueryRow("SELECT role, token, state, key, enrollmentId FROM Users WHERE id=?", id)
+	return ca.db.QueryRow(ca.rebind("SELECT role, token, state, key, enrollmentId FROM Users WHERE id=?"), id)
 }
 
 // readUsers reads users of a given Role
@@ -716,7 +1157,15 @@ func (ca *CA) readUser(id string) *sql.Row {
 func (ca *CA) readUsers(role int) (*sql.Rows, error) {
 	Trace.Println("Reading users matching role " + strconv.FormatInt(int64(role), 2) + ".")
 
-	return ca.db.Query("SELECT id, role FROM Users WHERE role&?!=0", role)
+	return ca.db.Query(ca.rebind("SELECT id, role FROM Users WHERE role&?!=0"), role)
+}
+
+// readUserInfos reads users of a given Role with their enrollment ID and state
+//
+func (ca *CA) readUserInfos(role int) (*sql.Rows, error) {
+	Trace.Println("Reading user infos matching role " + strconv.FormatInt(int64(role), 2) + ".")
+
+	return ca.db.Query(ca.rebind("SELECT id, enrollmentId, role, state, disabled FROM Users WHERE role&?!=0"), role)
 }
 
 // readRole returns the user Role given a user id
@@ -728,7 +1177,7 @@ func (ca *CA) readRole(id string) int {
 	defer mutex.RUnlock()
 
 	var role int
-	ca.db.QueryRow("SELECT role FROM Users WHERE id=?", id).Scan(&role)
+	ca.db.QueryRow(ca.rebind("SELECT role FROM Users WHERE id=?"), id).Scan(&role)
 
 	return role
 }
@@ -736,7 +1185,7 @@ func (ca *CA) readRole(id string) int {
 func (ca *CA) readAffiliationGroups() ([]*AffiliationGroup, error) {
 	Trace.Println("Reading affilition groups.")
 
-	rows, err := ca.db.Query("SELECT row, name, parent FROM AffiliationGroups")
+	rows, err := ca.db.Query(ca.rebind("SELECT row, name, parent FROM AffiliationGroups"))
 	if err != nil {
 		return nil, err
 	}
@@ -746,9 +1195,11 @@ func (ca *CA) readAffiliationGroups() ([]*AffiliationGroup, error) {
 	for rows.Next() {
 		group := new(AffiliationGroup)
 		var id int64
-		if e := rows.Scan(&id, &group.name, &group.parentID); e != nil {
+		var parentID sql.NullInt64
+		if e := rows.Scan(&id, &group.name, &parentID); e != nil {
 			return nil, err
 		}
+		group.parentID = parentID.Int64
 		groups[id] = group
 	}
 
@@ -802,7 +1253,7 @@ func (ca *CA) canRegister(registrar string, newMemberRole string, newMemberMetad
 
 	// Read the user metadata associated with 'registrar'
 	var registrarMetadataStr string
-	err := ca.db.QueryRow("SELECT metadata FROM Users WHERE id=?", registrar).Scan(&registrarMetadataStr)
+	err := ca.db.QueryRow(ca.rebind("SELECT metadata FROM Users WHERE id=?"), registrar).Scan(&registrarMetadataStr)
 	if err != nil {
 		Trace.Printf("CA.canRegister: db error: %s\n", err.Error())
 		return err
@@ -830,6 +1281,64 @@ func (ca *CA) canRegister(registrar string, newMemberRole string, newMemberMetad
 	return registrarMetadata.canRegister(registrar, newMemberRole, newMemberMetadata)
 }
 
+// Check to see if member 'registrar' can revoke the certificates of member 'owner'.
+// A registrar can revoke the certificates of every member it is allowed to register.
+// Return nil if allowed, or an error if not allowed
+func (ca *CA) canRevoke(registrar string, owner string) error {
+	role := ca.readRole(owner)
+	if role == 0 {
+		return errors.New("member " + owner + " is not registered")
+	}
+	return ca.canRegister(registrar, role2String(role), "")
+}
+
+// Check to see if member 'registrar' can manage member 'id' and give it the role 'newRole'.
+// A registrar can manage the members it is allowed to register with their current role
+// and registrar privileges; a role of 0 keeps the current role.
+// Return nil if allowed, or an error if not allowed
+func (ca *CA) canManage(registrar string, id string, newRole int) error {
+	var role int
+	var metadata string
+	mutex.RLock()
+	err := ca.db.QueryRow(ca.rebind("SELECT role, metadata FROM Users WHERE id=?"), id).Scan(&role, &metadata)
+	mutex.RUnlock()
+	if err != nil {
+		Trace.Printf("CA.canManage: db error: %s\n", err.Error())
+		return errors.New("member " + id + " is not registered")
+	}
+
+	if err = ca.canRegister(registrar, role2String(role), metadata); err != nil {
+		return err
+	}
+	if newRole != 0 && newRole != role {
+		return ca.canRegister(registrar, role2String(newRole), metadata)
+	}
+	return nil
+}
+
+// Check to see if member 'registrar' is a registrar at all.
+// Return nil if it is, or an error if it is not
+func (ca *CA) isRegistrar(registrar string) error {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	var registrarMetadataStr string
+	err := ca.db.QueryRow(ca.rebind("SELECT metadata FROM Users WHERE id=?"), registrar).Scan(&registrarMetadataStr)
+	if err != nil {
+		Trace.Printf("CA.isRegistrar: db error: %s\n", err.Error())
+		return err
+	}
+	registrarMetadata, err := newMemberMetadata(registrarMetadataStr)
+	if err != nil {
+		return err
+	}
+	if registrarMetadata == nil || len(registrarMetadata.Registrar.Roles) == 0 {
+		Trace.Println("isRegistrar: member " + registrar + " is not a registrar")
+		return errors.New("member " + registrar + " is not a registrar")
+	}
+	return nil
+}
+
 // Convert a string to a MemberMetadata
 func newMemberMetadata(metadata string) (*MemberMetadata, error) {
 	if metadata == "" {
@@ -93,6 +93,6 @@ func TestNewCA(t *testing.T) {
 }
 
 // Empty initializer for CA
-func initializeTables(db *sql.DB) error {
+func initializeTables(db *sql.DB, dialect *sqlDialect) error {
 	return nil
 }
@@ -0,0 +1,335 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// The statements of the CAs are written for SQLite and rewritten for the configured
+// database by its dialect. Several membersrvc replicas can share a PostgreSQL or MySQL
+// database: the schema is migrated under a database lock and the unique indexes and
+// guarded updates keep concurrent registrations and enrollments consistent. The mutex
+// only serializes the accesses of a single process.
+
+const defaultDBDriver = "sqlite3"
+
+// sqlDialect adapts the statements of the CAs to a database.
+type sqlDialect struct {
+	// numbered placeholders, $1, $2..., instead of ?
+	numbered bool
+
+	// quote encloses the column names reserved by the database
+	quote string
+
+	// types translates the column types of the schema
+	types []sqlType
+
+	// lock and unlock serialize the schema migrations of the replicas, the lock is
+	// released at the end of the transaction if unlock is empty, otherwise it is held
+	// by the connection until unlock
+	lock, unlock string
+}
+
+type sqlType struct {
+	pattern *regexp.Regexp
+	name    string
+}
+
+func newSQLTypes(types ...string) []sqlType {
+	var out []sqlType
+	for i := 0; i < len(types); i += 2 {
+		out = append(out, sqlType{regexp.MustCompile(`\b` + types[i] + `\b`), types[i+1]})
+	}
+	return out
+}
+
+var (
+	sqlDialects = map[string]*sqlDialect{
+		"sqlite3": {},
+		"postgres": {
+			numbered: true,
+			quote:    `"`,
+			types:    newSQLTypes("INTEGER PRIMARY KEY", "BIGSERIAL PRIMARY KEY", "INTEGER", "BIGINT", "BLOB", "BYTEA", "DATETIME", "TIMESTAMP"),
+			lock:     "SELECT pg_advisory_xact_lock(7202)",
+		},
+		"mysql": {
+			quote:  "`",
+			types:  newSQLTypes("INTEGER PRIMARY KEY", "BIGINT AUTO_INCREMENT PRIMARY KEY", "INTEGER", "BIGINT", "BLOB", "LONGBLOB"),
+			lock:   "SELECT GET_LOCK('membersrvc', -1)",
+			unlock: "SELECT RELEASE_LOCK('membersrvc')",
+		},
+	}
+
+	// reservedColumns are the column names of the schema that are reserved words in
+	// some databases
+	reservedColumns = map[string]bool{"row": true, "key": true, "usage": true, "timestamp": true}
+
+	// uniqueIndex matches the statements creating a unique index
+	uniqueIndex = regexp.MustCompile(`^CREATE UNIQUE INDEX (\w+) ON (\w+) \(([\w, ]+)\)$`)
+
+	dbDriver string
+	dbDSN    string
+)
+
+func init() {
+	sqlDialects["pgx"] = sqlDialects["postgres"]
+}
+
+// rebind rewrites a statement for the database of the dialect.
+//
+func (d *sqlDialect) rebind(query string) string {
+	if !d.numbered && d.quote == "" {
+		return query
+	}
+
+	var buf bytes.Buffer
+	n := 0
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'':
+			end := strings.IndexByte(query[i+1:], '\'')
+			if end < 0 {
+				buf.WriteString(query[i:])
+				return buf.String()
+			}
+			buf.WriteString(query[i : i+end+2])
+			i += end + 2
+		case c == '?':
+			n++
+			if d.numbered {
+				fmt.Fprintf(&buf, "$%d", n)
+			} else {
+				buf.WriteByte(c)
+			}
+			i++
+		case c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+			j := i + 1
+			for j < len(query) && (query[j] == '_' || 'a' <= query[j] && query[j] <= 'z' || 'A' <= query[j] && query[j] <= 'Z' || '0' <= query[j] && query[j] <= '9') {
+				j++
+			}
+			if word := query[i:j]; reservedColumns[word] {
+				buf.WriteString(d.quote + word + d.quote)
+			} else {
+				buf.WriteString(word)
+			}
+			i = j
+		default:
+			buf.WriteByte(c)
+			i++
+		}
+	}
+	return buf.String()
+}
+
+// schema rewrites a statement of the schema for the configured database.
+//
+func (d *sqlDialect) schema(stmt string) string {
+	for _, t := range d.types {
+		stmt = t.pattern.ReplaceAllString(stmt, t.name)
+	}
+	return d.rebind(stmt)
+}
+
+// openDB opens the database of the CA name and returns its dialect, the {name} variable
+// of the DSN is replaced with the name of the CA.
+//
+func openDB(name, path string) (*sql.DB, *sqlDialect, error) {
+	driver, dsn := dbDriver, dbDSN
+	if driver == "" {
+		driver = defaultDBDriver
+	}
+	dialect, ok := sqlDialects[driver]
+	if !ok {
+		return nil, nil, fmt.Errorf("Unsupported database driver [%s]", driver)
+	}
+	if dsn == "" {
+		if driver != defaultDBDriver {
+			return nil, nil, fmt.Errorf("Missing DSN for database driver [%s]", driver)
+		}
+		dsn = filepath.Join(path, "{name}.db")
+	}
+	if !strings.Contains(dsn, "{name}") {
+		// The CAs have tables of the same name
+		return nil, nil, fmt.Errorf("DSN [%s] does not tell apart the databases of the CAs with {name}", dsn)
+	}
+
+	db, err := sql.Open(driver, strings.Replace(dsn, "{name}", name, -1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return db, dialect, nil
+}
+
+// checkRowUpdated fails if an update guarded by the state of a row did not apply, as a
+// replica updated the row first.
+//
+func checkRowUpdated(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("Concurrent update of the row.")
+	}
+	return nil
+}
+
+// migration is a version of a schema, the statements of which bring the schema from the
+// previous version.
+//
+type migration struct {
+	version    int
+	statements []string
+}
+
+// migrate brings the schema name of the database to its last version. The migrations are
+// recorded in the SchemaVersions table; the tables created before the schema was
+// versioned are kept as long as the statements of the first version use IF NOT EXISTS.
+//
+// The pool is limited to a single connection while migrating. MySQL commits the statements
+// of the schema implicitly and ends the transaction, so its lock is held by the connection
+// and released when the migrations are done or failed.
+//
+func migrate(db *sql.DB, dialect *sqlDialect, name string, migrations []migration) (err error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	db.SetMaxOpenConns(1)
+	defer func() {
+		db.SetMaxOpenConns(0)
+		db.SetMaxIdleConns(2)
+	}()
+
+	if dialect.unlock != "" {
+		if _, err = db.Exec(dialect.lock); err != nil {
+			return err
+		}
+		defer func() {
+			if _, unlockErr := db.Exec(dialect.unlock); unlockErr != nil && err == nil {
+				err = unlockErr
+			}
+		}()
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if dialect.lock != "" && dialect.unlock == "" {
+		if _, err = tx.Exec(dialect.lock); err != nil {
+			return err
+		}
+	}
+	if _, err = tx.Exec(dialect.schema("CREATE TABLE IF NOT EXISTS SchemaVersions (name VARCHAR(64) PRIMARY KEY, version INTEGER)")); err != nil {
+		return err
+	}
+
+	var version int
+	err = tx.QueryRow(dialect.rebind("SELECT version FROM SchemaVersions WHERE name=?"), name).Scan(&version)
+	if err == sql.ErrNoRows {
+		_, err = tx.Exec(dialect.rebind("INSERT INTO SchemaVersions (name, version) VALUES (?, ?)"), name, 0)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		Info.Printf("Migrating schema [%s] to version %d\n", name, m.version)
+
+		for _, stmt := range m.statements {
+			if err = checkUniqueIndex(tx, dialect, stmt); err != nil {
+				return fmt.Errorf("Failed migrating schema [%s] to version %d: %s", name, m.version, err)
+			}
+			if _, err = tx.Exec(dialect.schema(stmt)); err != nil {
+				return fmt.Errorf("Failed migrating schema [%s] to version %d: %s", name, m.version, err)
+			}
+		}
+		if _, err = tx.Exec(dialect.rebind("UPDATE SchemaVersions SET version=? WHERE name=?"), m.version, name); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// checkUniqueIndex fails if the columns of a unique index to create hold duplicates, such
+// as the users registered twice by replicas before the index existed. The duplicates are
+// left to the administrator to resolve.
+//
+func checkUniqueIndex(tx *sql.Tx, dialect *sqlDialect, stmt string) error {
+	m := uniqueIndex.FindStringSubmatch(stmt)
+	if m == nil {
+		return nil
+	}
+	index, table, columns := m[1], m[2], strings.Split(m[3], ",")
+	for i := range columns {
+		columns[i] = strings.TrimSpace(columns[i])
+	}
+
+	list := strings.Join(columns, ", ")
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s IS NOT NULL GROUP BY %s HAVING count(*) > 1",
+		list, table, strings.Join(columns, " IS NOT NULL AND "), list)
+	rows, err := tx.Query(dialect.rebind(query))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var duplicates []string
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return err
+		}
+		var duplicate []string
+		for _, value := range values {
+			duplicate = append(duplicate, value.String)
+		}
+		duplicates = append(duplicates, strings.Join(duplicate, "/"))
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("Cannot create the unique index %s, %s of %s has duplicates %v which must be removed first", index, list, table, duplicates)
+	}
+	return nil
+}
@@ -0,0 +1,412 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	for _, name := range []string{"postgres", "mysql"} {
+		sql.Register("embedded_"+name, &embeddedServer{})
+		sqlDialects["embedded_"+name] = sqlDialects[name]
+	}
+}
+
+func TestRebind(t *testing.T) {
+	for _, test := range []struct {
+		dialect  string
+		query    string
+		expected string
+	}{
+		{"sqlite3", "SELECT count(row) FROM Users WHERE id=? AND key=?", "SELECT count(row) FROM Users WHERE id=? AND key=?"},
+		{"postgres", "SELECT count(row) FROM Users WHERE id=? AND key=?", `SELECT count("row") FROM Users WHERE id=$1 AND "key"=$2`},
+		{"mysql", "SELECT count(row) FROM Users WHERE id=? AND key=?", "SELECT count(`row`) FROM Users WHERE id=? AND `key`=?"},
+		{"postgres", "SELECT id, role FROM Users WHERE role&?!=0", "SELECT id, role FROM Users WHERE role&$1!=0"},
+		{"postgres", "SELECT cert, kdfKey, timestamp FROM Certificates WHERE id=? AND timestamp BETWEEN ? AND ? ORDER BY timestamp",
+			`SELECT cert, kdfKey, "timestamp" FROM Certificates WHERE id=$1 AND "timestamp" BETWEEN $2 AND $3 ORDER BY "timestamp"`},
+		// Literals and longer identifiers are kept
+		{"postgres", "SELECT 'row?' FROM keys WHERE rows=? AND usage_=?", "SELECT 'row?' FROM keys WHERE rows=$1 AND usage_=$2"},
+	} {
+		if rebound := sqlDialects[test.dialect].rebind(test.query); rebound != test.expected {
+			t.Fatalf("Rebound [%s] for %s instead of [%s]", rebound, test.dialect, test.expected)
+		}
+	}
+
+	// Each CA rewrites the statements for its own database
+	sqlite, postgres := &CA{dialect: sqlDialects["sqlite3"]}, &CA{dialect: sqlDialects["postgres"]}
+	if rebound := sqlite.rebind("SELECT row FROM Users WHERE id=?"); rebound != "SELECT row FROM Users WHERE id=?" {
+		t.Fatalf("Rebound [%s] for the sqlite3 CA", rebound)
+	}
+	if rebound := postgres.rebind("SELECT row FROM Users WHERE id=?"); rebound != `SELECT "row" FROM Users WHERE id=$1` {
+		t.Fatalf("Rebound [%s] for the postgres CA", rebound)
+	}
+}
+
+func TestSchemaStatements(t *testing.T) {
+	stmt := "CREATE TABLE IF NOT EXISTS Attributes (row INTEGER PRIMARY KEY, id VARCHAR(64), validFrom DATETIME, timestamp INTEGER, attributeValue BLOB)"
+	for dialect, expected := range map[string]string{
+		"sqlite3":  stmt,
+		"postgres": `CREATE TABLE IF NOT EXISTS Attributes ("row" BIGSERIAL PRIMARY KEY, id VARCHAR(64), validFrom TIMESTAMP, "timestamp" BIGINT, attributeValue BYTEA)`,
+		"mysql":    "CREATE TABLE IF NOT EXISTS Attributes (`row` BIGINT AUTO_INCREMENT PRIMARY KEY, id VARCHAR(64), validFrom DATETIME, `timestamp` BIGINT, attributeValue LONGBLOB)",
+	} {
+		if schema := sqlDialects[dialect].schema(stmt); schema != expected {
+			t.Fatalf("Translated [%s] for %s instead of [%s]", schema, dialect, expected)
+		}
+	}
+}
+
+func TestOpenDBErrors(t *testing.T) {
+	defer func(driver, dsn string) {
+		dbDriver, dbDSN = driver, dsn
+	}(dbDriver, dbDSN)
+
+	for _, test := range []struct {
+		driver string
+		dsn    string
+		err    string
+	}{
+		{"oracle", "", "Unsupported database driver [oracle]"},
+		{"postgres", "", "Missing DSN for database driver [postgres]"},
+		{"postgres", "postgres://membersrvc@localhost/membersrvc", "does not tell apart"},
+	} {
+		dbDriver, dbDSN = test.driver, test.dsn
+		if _, _, err := openDB("eca", "."); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("Opening [%s][%s] should fail with [%s], got [%v]", test.driver, test.dsn, test.err, err)
+		}
+	}
+}
+
+func TestMigrateSQLite(t *testing.T) {
+	defer func(driver, dsn string) {
+		dbDriver, dbDSN = driver, dsn
+	}(dbDriver, dbDSN)
+
+	path, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatalf("Failed creating directory [%s]", err)
+	}
+	defer os.RemoveAll(path)
+
+	dbDriver, dbDSN = "", ""
+	db, dialect, err := openDB("legacy", path)
+	if err != nil {
+		t.Fatalf("Failed opening database [%s]", err)
+	}
+	defer db.Close()
+	if _, err := os.Stat(filepath.Join(path, "legacy.db")); err != nil {
+		t.Fatalf("Database file not created [%s]", err)
+	}
+
+	// Tables created before the schema was versioned
+	for _, stmt := range commonMigrations[0].statements {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Failed creating table [%s]", err)
+		}
+	}
+	if _, err := db.Exec("INSERT INTO AffiliationGroups (name, parent) VALUES (?, ?)", "bank_a", 0); err != nil {
+		t.Fatalf("Failed inserting group [%s]", err)
+	}
+	if _, err := db.Exec("INSERT INTO Users (id, role, state) VALUES (?, ?, ?)", "alice", 1, 0); err != nil {
+		t.Fatalf("Failed inserting user [%s]", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := initializeTCATables(db, dialect); err != nil {
+			t.Fatalf("Failed migrating [%s]", err)
+		}
+	}
+	for name, expected := range map[string]int{"common": 4, "tca": 2} {
+		var version int
+		if err := db.QueryRow("SELECT version FROM SchemaVersions WHERE name=?", name).Scan(&version); err != nil || version != expected {
+			t.Fatalf("Schema [%s] at version %d instead of %d [%v]", name, version, expected, err)
+		}
+	}
+
+	var parent sql.NullInt64
+	if err := db.QueryRow("SELECT parent FROM AffiliationGroups WHERE name=?", "bank_a").Scan(&parent); err != nil || parent.Valid {
+		t.Fatalf("Top level group has parent [%v] [%v]", parent, err)
+	}
+	if _, err := db.Exec("INSERT INTO Users (id, role, state) VALUES (?, ?, ?)", "alice", 1, 0); err == nil {
+		t.Fatal("Registering a user twice should fail")
+	}
+}
+
+func TestMigrateDuplicateUsers(t *testing.T) {
+	defer func(driver, dsn string) {
+		dbDriver, dbDSN = driver, dsn
+	}(dbDriver, dbDSN)
+
+	path, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatalf("Failed creating directory [%s]", err)
+	}
+	defer os.RemoveAll(path)
+
+	dbDriver, dbDSN = "", ""
+	db, dialect, err := openDB("legacy", path)
+	if err != nil {
+		t.Fatalf("Failed opening database [%s]", err)
+	}
+	defer db.Close()
+
+	// Replicas registered alice twice before the schema was versioned
+	for _, stmt := range commonMigrations[0].statements {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Failed creating table [%s]", err)
+		}
+	}
+	for _, id := range []string{"alice", "bob", "alice"} {
+		if _, err := db.Exec("INSERT INTO Users (id, role, state) VALUES (?, ?, ?)", id, 1, 0); err != nil {
+			t.Fatalf("Failed inserting user [%s]", err)
+		}
+	}
+
+	err = initializeCommonTables(db, dialect)
+	if err == nil || !strings.Contains(err.Error(), "UsersByID") || !strings.Contains(err.Error(), "[alice]") {
+		t.Fatalf("Migrating duplicate users should fail naming them, got [%v]", err)
+	}
+	var count int
+	if err := db.QueryRow("SELECT count(row) FROM Users WHERE id=?", "alice").Scan(&count); err != nil || count != 2 {
+		t.Fatalf("Failed migration changed the users, %d alice [%v]", count, err)
+	}
+
+	// The migration completes once the duplicate is removed
+	if _, err := db.Exec("DELETE FROM Users WHERE row=(SELECT max(row) FROM Users WHERE id=?)", "alice"); err != nil {
+		t.Fatalf("Failed removing user [%s]", err)
+	}
+	if err := initializeCommonTables(db, dialect); err != nil {
+		t.Fatalf("Failed migrating [%s]", err)
+	}
+}
+
+// TestMigrateServer migrates the schemas of replicas sharing a PostgreSQL or MySQL
+// server, the DSNs of which are given by the CATEST_POSTGRES_DSN and CATEST_MYSQL_DSN
+// variables, e.g. "postgres://membersrvc@localhost/membersrvc_{name}?sslmode=disable"
+// or "membersrvc@tcp(localhost:3306)/membersrvc_{name}?parseTime=true". The databases
+// are dropped and created again.
+func TestMigrateServer(t *testing.T) {
+	for _, server := range []struct {
+		driver string
+		env    string
+	}{
+		{"postgres", "CATEST_POSTGRES_DSN"},
+		{"mysql", "CATEST_MYSQL_DSN"},
+	} {
+		dsn := os.Getenv(server.env)
+		if dsn == "" {
+			t.Logf("Skipping %s, %s is not set", server.driver, server.env)
+			continue
+		}
+		testMigrateServer(t, server.driver, dsn)
+	}
+}
+
+func testMigrateServer(t *testing.T, driver, dsn string) {
+	defer func(driver, dsn string) {
+		dbDriver, dbDSN = driver, dsn
+	}(dbDriver, dbDSN)
+	dbDriver, dbDSN = driver, dsn
+
+	resetServerTables(t, "tca")
+
+	const replicas = 4
+	var wg sync.WaitGroup
+	errs := make(chan error, replicas)
+	for i := 0; i < replicas; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db, dialect, err := openDB("tca", ".")
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer db.Close()
+			errs <- initializeTCATables(db, dialect)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Failed migrating %s [%s]", driver, err)
+		}
+	}
+
+	db, dialect, err := openDB("tca", ".")
+	if err != nil {
+		t.Fatalf("Failed opening %s [%s]", driver, err)
+	}
+	defer db.Close()
+	for name, expected := range map[string]int{"common": len(commonMigrations), "tca": len(tcaMigrations)} {
+		var version int
+		if err := db.QueryRow(dialect.rebind("SELECT version FROM SchemaVersions WHERE name=?"), name).Scan(&version); err != nil || version != expected {
+			t.Fatalf("Schema [%s] of %s at version %d instead of %d [%v]", name, driver, version, expected, err)
+		}
+	}
+
+	ca := &CA{db: db, dialect: dialect}
+	if _, err := ca.registerUserWithEnrollID("alice", "alice", pb.Role_CLIENT, "", false); err != nil {
+		t.Fatalf("Failed registering user on %s [%s]", driver, err)
+	}
+	if _, err := db.Exec(ca.rebind("INSERT INTO Users (id, role, state) VALUES (?, ?, ?)"), "alice", 1, 0); err == nil {
+		t.Fatalf("Registering a user twice on %s should fail", driver)
+	}
+}
+
+// TestMigrateEmbeddedServer migrates the schemas of replicas sharing an embedded server
+// with the dialects of PostgreSQL and MySQL, it runs without a server.
+func TestMigrateEmbeddedServer(t *testing.T) {
+	path, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatalf("Failed creating directory [%s]", err)
+	}
+	defer os.RemoveAll(path)
+
+	for _, driver := range []string{"postgres", "mysql"} {
+		testMigrateServer(t, "embedded_"+driver, filepath.Join(path, driver+"_{name}.db"))
+	}
+}
+
+// embeddedServer runs the statements of the PostgreSQL and MySQL dialects on SQLite,
+// which accepts their placeholders, quotes and column types, and emulates the locks
+// serializing the migrations: the lock of PostgreSQL is released at the end of the
+// transaction, the one of MySQL is held by the connection until released.
+type embeddedServer struct {
+	sqlite3.SQLiteDriver
+	lock sync.Mutex
+}
+
+func (s *embeddedServer) Open(dsn string) (driver.Conn, error) {
+	conn, err := s.SQLiteDriver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &embeddedConn{conn: conn.(*sqlite3.SQLiteConn), server: s}, nil
+}
+
+type embeddedConn struct {
+	conn   *sqlite3.SQLiteConn
+	server *embeddedServer
+
+	// locked is set while the connection holds the lock, which is released at the
+	// end of the transaction if xact is set
+	locked, xact bool
+}
+
+func (c *embeddedConn) acquire(xact bool) {
+	if !c.locked {
+		c.server.lock.Lock()
+		c.locked = true
+	}
+	c.xact = xact
+}
+
+func (c *embeddedConn) release() {
+	if c.locked {
+		c.locked = false
+		c.server.lock.Unlock()
+	}
+}
+
+func (c *embeddedConn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	switch {
+	case strings.HasPrefix(query, "SELECT pg_advisory_xact_lock("):
+		c.acquire(true)
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(query, "SELECT GET_LOCK("):
+		c.acquire(false)
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(query, "SELECT RELEASE_LOCK("):
+		c.release()
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(query, "CREATE ") && !c.locked:
+		// The schema only changes in migrations, which hold the lock
+		return nil, errors.New("schema changed without holding the lock")
+	}
+	return c.conn.Exec(query, args)
+}
+
+func (c *embeddedConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	return c.conn.Query(query, args)
+}
+
+func (c *embeddedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.conn.Prepare(query)
+}
+
+func (c *embeddedConn) Begin() (driver.Tx, error) {
+	tx, err := c.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &embeddedTx{tx: tx, conn: c}, nil
+}
+
+func (c *embeddedConn) Close() error {
+	c.release()
+	return c.conn.Close()
+}
+
+type embeddedTx struct {
+	tx   driver.Tx
+	conn *embeddedConn
+}
+
+func (tx *embeddedTx) Commit() error {
+	defer tx.end()
+	return tx.tx.Commit()
+}
+
+func (tx *embeddedTx) Rollback() error {
+	defer tx.end()
+	return tx.tx.Rollback()
+}
+
+func (tx *embeddedTx) end() {
+	if tx.conn.xact {
+		tx.conn.release()
+	}
+}
+
+// resetServerTables drops the tables of the database of the CA name.
+func resetServerTables(t *testing.T, name string) {
+	db, dialect, err := openDB(name, ".")
+	if err != nil {
+		t.Fatalf("Failed opening %s [%s]", dbDriver, err)
+	}
+	defer db.Close()
+
+	for _, table := range []string{"TCertificates", "TCertificateSets", "RevokedCertificates", "Certificates", "Users", "AffiliationGroups", "SchemaVersions"} {
+		if _, err := db.Exec(dialect.rebind(fmt.Sprintf("DROP TABLE IF EXISTS %s", table))); err != nil {
+			t.Fatalf("Failed dropping %s on %s [%s]", table, dbDriver, err)
+		}
+	}
+}
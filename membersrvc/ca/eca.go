@@ -23,11 +23,16 @@ import (
 	"database/sql"
 	"encoding/asn1"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/pem"
+	"errors"
 	"io/ioutil"
+	"math/big"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	pb "github.com/hyperledger/fabric/membersrvc/protos"
 	"github.com/spf13/viper"
@@ -47,10 +52,23 @@ type ECA struct {
 	obcKey          []byte
 	obcPriv, obcPub []byte
 	gRPCServer      *grpc.Server
+	registry        UserRegistry
+	tca             *TCA
 }
 
-func initializeECATables(db *sql.DB) error {
-	return initializeCommonTables(db)
+var ecaMigrations = []migration{
+	// Nonces of the management requests served within the request window
+	{1, []string{
+		"CREATE TABLE IF NOT EXISTS RequestNonces (row INTEGER PRIMARY KEY, nonce VARCHAR(128), timestamp INTEGER)",
+		"CREATE UNIQUE INDEX RequestNoncesByNonce ON RequestNonces (nonce)",
+	}},
+}
+
+func initializeECATables(db *sql.DB, dialect *sqlDialect) error {
+	if err := initializeCommonTables(db, dialect); err != nil {
+		return err
+	}
+	return migrate(db, dialect, "eca", ecaMigrations)
 }
 
 // NewECA sets up a new ECA.
@@ -120,11 +138,52 @@ func NewECA() *ECA {
 			})
 	}
 
+	registry, err := newUserRegistry()
+	if err != nil {
+		Panic.Panicln(err)
+	}
+	eca.registry = registry
+
 	eca.populateAffiliationGroupsTable()
 	eca.populateUsersTable()
 	return eca
 }
 
+// authenticateRegistryUser checks the password of a user against the user registry. The
+// user is registered with the ECA on its first enrollment, to keep track of its state.
+// The users registered with the ECA otherwise never enroll with a directory password.
+//
+func (eca *ECA) authenticateRegistryUser(id string, pwd []byte) error {
+	fromRegistry, err := eca.isRegistryUser(id)
+	if err == nil && !fromRegistry {
+		return errors.New("User is not a user of the registry.")
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	user, err := eca.registry.Authenticate(id, pwd)
+	if err != nil {
+		return err
+	}
+	if fromRegistry {
+		return nil
+	}
+
+	enrollID, err := eca.validateAndGenerateEnrollID(id, user.Affiliation, user.Role)
+	if err != nil {
+		return err
+	}
+	if _, err = eca.registerUserWithEnrollID(id, enrollID, user.Role, user.Metadata, true); err != nil {
+		// The user may have been registered by a concurrent enrollment
+		if fromRegistry, _ = eca.isRegistryUser(id); fromRegistry {
+			return nil
+		}
+	}
+
+	return err
+}
+
 // populateUsersTable populates the users table.
 //
 func (eca *ECA) populateUsersTable() {
@@ -214,3 +273,185 @@ func (eca *ECA) startECAA(srv *grpc.Server) {
 	pb.RegisterECAAServer(srv, &ECAA{eca})
 	Info.Println("ECA ADMIN gRPC API server started")
 }
+
+// verifySignature checks that sig is a valid signature of the request in
+// (with its signature field cleared) under the enrollment certificate of id.
+// Requests signed with a revoked enrollment certificate or by a member whose
+// enrollment is disabled are rejected.
+//
+func (eca *ECA) verifySignature(id string, sig *pb.Signature, in proto.Message) error {
+	if sig == nil {
+		return errors.New("Missing signature.")
+	}
+
+	if disabled, err := eca.isUserDisabled(id); err == nil && disabled {
+		return errors.New("Enrollment of " + id + " has been disabled.")
+	}
+
+	raw, err := eca.readCertificateByKeyUsage(id, x509.KeyUsageDigitalSignature)
+	if err != nil {
+		return err
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := eca.isRevoked(cert.SerialNumber)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return errors.New("Enrollment certificate of " + id + " has been revoked.")
+	}
+
+	r, s := big.NewInt(0), big.NewInt(0)
+	r.UnmarshalText(sig.R)
+	s.UnmarshalText(sig.S)
+
+	suite, err := getCertCryptoSuite(cert)
+	if err != nil {
+		return err
+	}
+	hash := suite.Hash()
+	raw, _ = proto.Marshal(in)
+	hash.Write(raw)
+	if ecdsa.Verify(cert.PublicKey.(*ecdsa.PublicKey), hash.Sum(nil), r, s) == false {
+		return errors.New("Signature verification failed.")
+	}
+
+	return nil
+}
+
+// revokeCertificates revokes the enrollment certificates of owner not revoked yet.
+//
+func (eca *ECA) revokeCertificates(owner string) error {
+	rows, err := eca.readCertificates(owner)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var certs []*x509.Certificate
+	for rows.Next() {
+		var raw, kdfKey []byte
+		if err = rows.Scan(&raw, &kdfKey); err != nil {
+			return err
+		}
+		x509Cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, x509Cert)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, x509Cert := range certs {
+		if err = eca.revokeCertificate(owner, x509Cert); err != nil && err != errCertificateRevoked {
+			return err
+		}
+	}
+
+	return eca.revokeTCerts(owner)
+}
+
+// revokeCertificatePair revokes the enrollment certificate pair cert belongs to.
+// If cert is nil, the current certificate pair of owner is revoked.
+//
+func (eca *ECA) revokeCertificatePair(owner string, cert *pb.Cert) error {
+	var ts int64
+	if cert != nil && len(cert.Cert) > 0 {
+		id, timestamp, err := eca.readCertificateOwner(cert.Cert)
+		if err != nil {
+			return errors.New("Certificate was not issued by this ECA.")
+		}
+		if id != owner {
+			return errors.New("Certificate does not belong to " + owner + ".")
+		}
+		ts = timestamp
+	} else {
+		raw, err := eca.readCertificateByKeyUsage(owner, x509.KeyUsageDigitalSignature)
+		if err != nil {
+			return err
+		}
+		if _, ts, err = eca.readCertificateOwner(raw); err != nil {
+			return err
+		}
+	}
+
+	rows, err := eca.readCertificates(owner, ts)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var certs []*x509.Certificate
+	for rows.Next() {
+		var raw, kdfKey []byte
+		if err = rows.Scan(&raw, &kdfKey); err != nil {
+			return err
+		}
+		x509Cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, x509Cert)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, x509Cert := range certs {
+		if err = eca.revokeCertificate(owner, x509Cert); err != nil {
+			return err
+		}
+	}
+
+	return eca.revokeTCerts(owner)
+}
+
+// revokeTCerts revokes the TCerts issued to owner. Their keys are derived from
+// the enrollment key of owner, and the TCA does not record which enrollment
+// certificate they were issued under, so all of them are revoked. The
+// revocation becomes visible to peers with the next CRL published by the TCA.
+//
+func (eca *ECA) revokeTCerts(owner string) error {
+	if eca.tca == nil {
+		return nil
+	}
+	return eca.tca.revokeCertificates(owner)
+}
+
+// registerRequestNonce records the nonce of a management request sent at timestamp, a
+// request is served once. The nonces of the requests older than window are dropped, such
+// requests are rejected anyway.
+//
+func (eca *ECA) registerRequestNonce(nonce []byte, timestamp int64, window time.Duration) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, err := eca.db.Exec(eca.rebind("DELETE FROM RequestNonces WHERE timestamp<?"), time.Now().Add(-window).Unix()-1); err != nil {
+		return err
+	}
+
+	var count int
+	cooked := hex.EncodeToString(nonce)
+	if err := eca.db.QueryRow(eca.rebind("SELECT count(row) FROM RequestNonces WHERE nonce=?"), cooked).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("The request has already been served.")
+	}
+
+	// A replica serving the same request at the same time makes the insert fail
+	if _, err := eca.db.Exec(eca.rebind("INSERT INTO RequestNonces (nonce, timestamp) VALUES (?, ?)"), cooked, timestamp); err != nil {
+		Error.Println(err)
+		return errors.New("The request has already been served.")
+	}
+
+	return nil
+}
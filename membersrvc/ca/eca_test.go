@@ -22,6 +22,7 @@ import (
 	"crypto/x509"
 	"errors"
 	"google/protobuf"
+	"math/big"
 	"os"
 	"testing"
 	"time"
@@ -30,6 +31,7 @@ import (
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/hyperledger/fabric/core/crypto/primitives/ecies"
 	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/viper"
 	"golang.org/x/net/context"
 )
 
@@ -41,6 +43,7 @@ type User struct {
 	affiliation            string
 	registrarRoles         []string
 	registrarDelegateRoles []string
+	suite                  *primitives.CryptoSuite
 }
 
 var (
@@ -57,15 +60,27 @@ var (
 		registrarRoles: []string{"client"}}
 	testPeer = User{enrollID: "testPeer", role: 2, affiliation: "institution_a",
 		registrarRoles: []string{"peer"}}
+	testRevokedUser  = User{enrollID: "testRevokedUser", role: 1, affiliation: "institution_a"}
+	testRevokedUser2 = User{enrollID: "testRevokedUser2", role: 1, affiliation: "institution_a"}
+	testSuiteUser    = User{enrollID: "testSuiteUser", role: 1, affiliation: "institution_a"}
 )
 
+// getSuite returns the crypto suite the user works with, that of the CAs if not set
+func (user *User) getSuite() *primitives.CryptoSuite {
+	if user.suite == nil {
+		return primitives.GetDefaultCryptoSuite()
+	}
+	return user.suite
+}
+
 //helper function for multiple tests
 func enrollUser(user *User) error {
 
 	ecap := &ECAP{eca}
 
 	// Phase 1 of the protocol: Generate crypto material
-	signPriv, err := primitives.NewECDSAKey()
+	suite := user.getSuite()
+	signPriv, err := ecdsa.GenerateKey(suite.Curve, rand.Reader)
 	user.enrollPrivKey = signPriv
 	if err != nil {
 		return err
@@ -75,7 +90,7 @@ func enrollUser(user *User) error {
 		return err
 	}
 
-	encPriv, err := primitives.NewECDSAKey()
+	encPriv, err := ecdsa.GenerateKey(suite.Curve, rand.Reader)
 	if err != nil {
 		return err
 	}
@@ -91,6 +106,9 @@ func enrollUser(user *User) error {
 		Sign: &pb.PublicKey{Type: pb.CryptoType_ECDSA, Key: signPub},
 		Enc:  &pb.PublicKey{Type: pb.CryptoType_ECDSA, Key: encPub},
 		Sig:  nil}
+	if user.suite != nil {
+		req.CryptoSuite = user.suite.Name
+	}
 
 	resp, err := ecap.CreateCertificatePair(context.Background(), req)
 	if err != nil {
@@ -117,7 +135,7 @@ func enrollUser(user *User) error {
 	req.Tok.Tok = out
 	req.Sig = nil
 
-	hash := primitives.NewHash()
+	hash := suite.Hash()
 	raw, _ := proto.Marshal(req)
 	hash.Write(raw)
 
@@ -155,6 +173,17 @@ func enrollUser(user *User) error {
 		return err
 	}
 
+	// The certificates advertise the crypto suite of the user
+	for _, cert := range []*x509.Certificate{x509SignCert, x509EncCert} {
+		certSuite, err := primitives.GetCertificateCryptoSuite(cert)
+		if err != nil {
+			return err
+		}
+		if certSuite != suite {
+			return errors.New("Certificate does not advertise the crypto suite of the user")
+		}
+	}
+
 	return nil
 }
 
@@ -545,27 +574,223 @@ func TestRevokeCertificatePair(t *testing.T) {
 
 	ecap := &ECAP{eca}
 
-	_, err := ecap.RevokeCertificatePair(context.Background(), &pb.ECertRevokeReq{})
-	if err.Error() != "ECAP:RevokeCertificate method not (yet) implemented" {
-		t.Fatalf("Expected error was not returned: [%s]", err.Error())
+	if err := registerUser(testAdmin, &testRevokedUser); err != nil {
+		t.Fatalf("Failed to register user: [%s]", err.Error())
+	}
+	if err := enrollUser(&testRevokedUser); err != nil {
+		t.Fatalf("Failed to enroll user: [%s]", err.Error())
+	}
+
+	req := &pb.ECertRevokeReq{Id: &pb.Identity{Id: testRevokedUser.enrollID}}
+	req.Sig = signRequest(t, &testRevokedUser, req)
+
+	if _, err := ecap.RevokeCertificatePair(context.Background(), req); err != nil {
+		t.Fatalf("Failed to revoke certificate pair: [%s]", err.Error())
+	}
+
+	pair, err := ecap.ReadCertificatePair(context.Background(), &pb.ECertReadReq{Id: &pb.Identity{Id: testRevokedUser.enrollID}})
+	if err != nil {
+		t.Fatalf("Failed to read certificate pair: [%s]", err.Error())
+	}
+	for _, raw := range [][]byte{pair.Sign, pair.Enc} {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			t.Fatalf("Failed to parse certificate: [%s]", err.Error())
+		}
+		if revoked, _ := eca.isRevoked(cert.SerialNumber); !revoked {
+			t.Fatal("Certificate should have been revoked")
+		}
+	}
+
+	// Requests signed with a revoked certificate must be rejected
+	req = &pb.ECertRevokeReq{Id: &pb.Identity{Id: testRevokedUser.enrollID}}
+	req.Sig = signRequest(t, &testRevokedUser, req)
+
+	if _, err = ecap.RevokeCertificatePair(context.Background(), req); err == nil {
+		t.Fatal("Requests signed with a revoked certificate should be rejected")
+	}
+}
+
+func TestCreateCertificatePairOtherCryptoSuite(t *testing.T) {
+
+	ecap := &ECAP{eca}
+
+	// A suite on the curve of the CAs with the other hash family
+	var other *primitives.CryptoSuite
+	for _, name := range primitives.GetCryptoSuiteNames() {
+		suite, _ := primitives.GetCryptoSuite(name)
+		if suite != primitives.GetDefaultCryptoSuite() && suite.SecurityLevel == primitives.GetDefaultCryptoSuite().SecurityLevel {
+			other = suite
+		}
+	}
+	if other == nil {
+		t.Fatal("No other crypto suite on the curve of the CAs")
+	}
+
+	if err := registerUser(testAdmin, &testSuiteUser); err != nil {
+		t.Fatalf("Failed to register user: [%s]", err.Error())
+	}
+	pwd := testSuiteUser.enrollPwd
+	testSuiteUser.suite = other
+
+	// The CAs only issue certificates for the suites allowed
+	if err := enrollUser(&testSuiteUser); err == nil {
+		t.Fatal("Enrollment with a crypto suite not allowed should fail")
+	}
+
+	allowed := viper.Get("security.suites.allowed")
+	defer viper.Set("security.suites.allowed", allowed)
+	viper.Set("security.suites.allowed", []string{primitives.GetDefaultCryptoSuite().Name, other.Name})
+
+	testSuiteUser.enrollPwd = pwd
+	if err := enrollUser(&testSuiteUser); err != nil {
+		t.Fatalf("Failed to enroll user with crypto suite %s: [%s]", other.Name, err.Error())
+	}
+
+	// Requests signed under the suite of the user are accepted
+	req := &pb.ECertRevokeReq{Id: &pb.Identity{Id: testSuiteUser.enrollID}}
+	req.Sig = signRequest(t, &testSuiteUser, req)
+
+	if _, err := ecap.RevokeCertificatePair(context.Background(), req); err != nil {
+		t.Fatalf("Failed to revoke certificate pair: [%s]", err.Error())
 	}
 }
 
 func TestRevokeCertificate(t *testing.T) {
 
+	ecap := &ECAP{eca}
 	ecaa := &ECAA{eca}
 
-	_, err := ecaa.RevokeCertificate(context.Background(), &pb.ECertRevokeReq{})
-	if err.Error() != "ECAA:RevokeCertificate method not (yet) implemented" {
-		t.Fatalf("Expected error was not returned: [%s]", err.Error())
+	if err := registerUser(testAdmin, &testRevokedUser2); err != nil {
+		t.Fatalf("Failed to register user: [%s]", err.Error())
+	}
+	if err := enrollUser(&testRevokedUser2); err != nil {
+		t.Fatalf("Failed to enroll user: [%s]", err.Error())
+	}
+
+	pair, err := ecap.ReadCertificatePair(context.Background(), &pb.ECertReadReq{Id: &pb.Identity{Id: testRevokedUser2.enrollID}})
+	if err != nil {
+		t.Fatalf("Failed to read certificate pair: [%s]", err.Error())
+	}
+
+	// A member who is not a registrar cannot revoke certificates of other members
+	req := &pb.ECertRevokeReq{Id: &pb.Identity{Id: testUser.enrollID}, Cert: &pb.Cert{Cert: pair.Sign}}
+	req.Sig = signRequest(t, &testUser, req)
+
+	if _, err = ecaa.RevokeCertificate(context.Background(), req); err == nil {
+		t.Fatal("Only registrars should be able to revoke certificates of other members")
+	}
+
+	req = &pb.ECertRevokeReq{Id: &pb.Identity{Id: testAdmin.enrollID}, Cert: &pb.Cert{Cert: pair.Sign}}
+	req.Sig = signRequest(t, &testAdmin, req)
+
+	if _, err = ecaa.RevokeCertificate(context.Background(), req); err != nil {
+		t.Fatalf("Failed to revoke certificate: [%s]", err.Error())
+	}
+
+	cert, err := x509.ParseCertificate(pair.Sign)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: [%s]", err.Error())
+	}
+	if revoked, _ := eca.isRevoked(cert.SerialNumber); !revoked {
+		t.Fatal("Certificate should have been revoked")
+	}
+}
+
+func TestRevokeCertificateConcurrently(t *testing.T) {
+	cert, err := x509.ParseCertificate(eca.raw)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: [%s]", err.Error())
+	}
+	cert.SerialNumber = big.NewInt(time.Now().UnixNano())
+
+	// The certificate is revoked once, the other attempts find it revoked
+	start := make(chan struct{})
+	errs := make(chan error)
+	for i := 0; i < 50; i++ {
+		go func() {
+			<-start
+			errs <- eca.revokeCertificate("eca", cert)
+		}()
+	}
+	close(start)
+	revoked := 0
+	for i := 0; i < 50; i++ {
+		switch err := <-errs; err {
+		case nil:
+			revoked++
+		case errCertificateRevoked:
+		default:
+			t.Fatalf("Failed to revoke certificate: [%s]", err.Error())
+		}
+	}
+	if revoked != 1 {
+		t.Fatalf("Certificate should have been revoked once, it was revoked %d times", revoked)
 	}
 }
 
 func TestPublishCRL(t *testing.T) {
+	ecap := &ECAP{eca}
 	ecaa := &ECAA{eca}
 
-	_, err := ecaa.PublishCRL(context.Background(), &pb.ECertCRLReq{})
-	if err.Error() != "ECAA:PublishCRL method not (yet) implemented" {
-		t.Fatalf("Expected error was not returned: [%s]", err.Error())
+	req := &pb.ECertCRLReq{Id: &pb.Identity{Id: testUser.enrollID}}
+	req.Sig = signRequest(t, &testUser, req)
+
+	if _, err := ecaa.PublishCRL(context.Background(), req); err == nil {
+		t.Fatal("Only registrars should be able to publish CRLs")
+	}
+
+	req = &pb.ECertCRLReq{Id: &pb.Identity{Id: testAdmin.enrollID}}
+	req.Sig = signRequest(t, &testAdmin, req)
+
+	if _, err := ecaa.PublishCRL(context.Background(), req); err != nil {
+		t.Fatalf("Failed to publish CRL: [%s]", err.Error())
+	}
+
+	resp, err := ecap.ReadCRL(context.Background(), &pb.Empty{})
+	if err != nil {
+		t.Fatalf("Failed to read CRL: [%s]", err.Error())
 	}
+
+	crl, err := x509.ParseCRL(resp.Crl)
+	if err != nil {
+		t.Fatalf("Failed to parse CRL: [%s]", err.Error())
+	}
+	if err = eca.cert.CheckCRLSignature(crl); err != nil {
+		t.Fatalf("Failed to verify CRL signature: [%s]", err.Error())
+	}
+
+	pair, err := ecap.ReadCertificatePair(context.Background(), &pb.ECertReadReq{Id: &pb.Identity{Id: testRevokedUser2.enrollID}})
+	if err != nil {
+		t.Fatalf("Failed to read certificate pair: [%s]", err.Error())
+	}
+	cert, err := x509.ParseCertificate(pair.Sign)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: [%s]", err.Error())
+	}
+
+	found := false
+	for _, revoked := range crl.TBSCertList.RevokedCertificates {
+		if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("Revoked certificate is missing from the CRL")
+	}
+}
+
+//helper function to sign a request with the enrollment key of user
+func signRequest(t *testing.T, user *User, req proto.Message) *pb.Signature {
+	hash := user.getSuite().Hash()
+	raw, _ := proto.Marshal(req)
+	hash.Write(raw)
+
+	r, s, err := ecdsa.Sign(rand.Reader, user.enrollPrivKey, hash.Sum(nil))
+	if err != nil {
+		t.Fatalf("Failed (ECDSA) signing [%s]", err.Error())
+	}
+	R, _ := r.MarshalText()
+	S, _ := s.MarshalText()
+	return &pb.Signature{Type: pb.CryptoType_ECDSA, R: R, S: S}
 }
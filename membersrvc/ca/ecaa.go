@@ -21,14 +21,27 @@ import (
 	"crypto/x509"
 	"encoding/json"
 	"errors"
+	"google/protobuf"
 	"math/big"
+	"strings"
+	"time"
 
 	"github.com/golang/protobuf/proto"
-	"github.com/hyperledger/fabric/core/crypto/primitives"
 	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/viper"
 	"golang.org/x/net/context"
 )
 
+// defaultRequestWindow is the time a management request is accepted within, before and
+// after its timestamp, if eca.requestWindow is not set.
+const defaultRequestWindow = 5 * time.Minute
+
+// Nonces of the management requests
+const (
+	minRequestNonceLength = 16
+	maxRequestNonceLength = 64
+)
+
 // ECAA serves the administrator GRPC interface of the ECA.
 //
 type ECAA struct {
@@ -95,7 +108,11 @@ func (ecaa *ECAA) checkRegistrarSignature(in *pb.RegisterUserReq) error {
 	r.UnmarshalText(sig.R)
 	s.UnmarshalText(sig.S)
 
-	hash := primitives.NewHash()
+	suite, err := getCertCryptoSuite(cert)
+	if err != nil {
+		return err
+	}
+	hash := suite.Hash()
 	raw, _ = proto.Marshal(in)
 	hash.Write(raw)
 
@@ -137,7 +154,11 @@ func (ecaa *ECAA) ReadUserSet(ctx context.Context, in *pb.ReadUserSetReq) (*pb.U
 	r.UnmarshalText(sig.R)
 	s.UnmarshalText(sig.S)
 
-	hash := primitives.NewHash()
+	suite, err := getCertCryptoSuite(cert)
+	if err != nil {
+		return nil, err
+	}
+	hash := suite.Hash()
 	raw, _ = proto.Marshal(in)
 	hash.Write(raw)
 	if ecdsa.Verify(cert.PublicKey.(*ecdsa.PublicKey), hash.Sum(nil), r, s) == false {
@@ -165,18 +186,337 @@ func (ecaa *ECAA) ReadUserSet(ctx context.Context, in *pb.ReadUserSetReq) (*pb.U
 	return &pb.UserSet{Users: users}, err
 }
 
-// RevokeCertificate revokes a certificate from the ECA.  Not yet implemented.
+// RevokeCertificate revokes a certificate pair from the ECA.  The requester must be
+// a registrar allowed to register members with the role of the certificate owner.
 //
-func (ecaa *ECAA) RevokeCertificate(context.Context, *pb.ECertRevokeReq) (*pb.CAStatus, error) {
+func (ecaa *ECAA) RevokeCertificate(ctx context.Context, in *pb.ECertRevokeReq) (*pb.CAStatus, error) {
 	Trace.Println("gRPC ECAA:RevokeCertificate")
 
-	return nil, errors.New("ECAA:RevokeCertificate method not (yet) implemented")
+	if in.Id == nil || in.Id.Id == "" {
+		return nil, errors.New("No identity was specified.")
+	}
+	if in.Cert == nil || len(in.Cert.Cert) == 0 {
+		return nil, errors.New("No certificate was specified.")
+	}
+	admin := in.Id.Id
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := ecaa.eca.verifySignature(admin, sig, in); err != nil {
+		return nil, err
+	}
+
+	owner, _, err := ecaa.eca.readCertificateOwner(in.Cert.Cert)
+	if err != nil {
+		return nil, errors.New("Certificate was not issued by this ECA.")
+	}
+	if err = ecaa.eca.canRevoke(admin, owner); err != nil {
+		return nil, err
+	}
+
+	if err = ecaa.eca.revokeCertificatePair(owner, in.Cert); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
 }
 
-// PublishCRL requests the creation of a certificate revocation list from the ECA.  Not yet implemented.
+// PublishCRL requests the creation of a certificate revocation list from the ECA. The TCA
+// publishes its list as well. It holds the TCerts revoked along with enrollment certificates.
 //
-func (ecaa *ECAA) PublishCRL(context.Context, *pb.ECertCRLReq) (*pb.CAStatus, error) {
+func (ecaa *ECAA) PublishCRL(ctx context.Context, in *pb.ECertCRLReq) (*pb.CAStatus, error) {
 	Trace.Println("gRPC ECAA:CreateCRL")
 
-	return nil, errors.New("ECAA:PublishCRL method not (yet) implemented")
+	if in.Id == nil || in.Id.Id == "" {
+		return nil, errors.New("No identity was specified.")
+	}
+	admin := in.Id.Id
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := ecaa.eca.verifySignature(admin, sig, in); err != nil {
+		return nil, err
+	}
+	if err := ecaa.eca.isRegistrar(admin); err != nil {
+		return nil, err
+	}
+
+	if _, err := ecaa.eca.createCRL(); err != nil {
+		return nil, err
+	}
+	if ecaa.eca.tca != nil {
+		if _, err := ecaa.eca.tca.createCRL(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
+}
+
+// checkRegistrar checks the signature sig of the management request in, the signature
+// field of which is cleared, and that its signer is a registrar. The request must be sent
+// within the request window and not have been served before.
+//
+func (ecaa *ECAA) checkRegistrar(registrar *pb.Identity, ts *google_protobuf.Timestamp, nonce []byte, sig *pb.Signature, in proto.Message) (string, error) {
+	if registrar == nil || registrar.Id == "" {
+		return "", errors.New("No registrar was specified.")
+	}
+	if err := ecaa.eca.verifySignature(registrar.Id, sig, in); err != nil {
+		return "", err
+	}
+	if err := ecaa.eca.isRegistrar(registrar.Id); err != nil {
+		return "", err
+	}
+	if err := ecaa.checkReplay(ts, nonce); err != nil {
+		Trace.Printf("ECAA: rejected request of %s: %s\n", registrar.Id, err)
+		return "", err
+	}
+	return registrar.Id, nil
+}
+
+// checkReplay rejects the management requests sent outside of the request window and
+// those served before.
+//
+func (ecaa *ECAA) checkReplay(ts *google_protobuf.Timestamp, nonce []byte) error {
+	if ts == nil {
+		return errors.New("No timestamp was specified.")
+	}
+	if len(nonce) < minRequestNonceLength || len(nonce) > maxRequestNonceLength {
+		return errors.New("Invalid nonce.")
+	}
+
+	window := viper.GetDuration("eca.requestWindow")
+	if window <= 0 {
+		window = defaultRequestWindow
+	}
+	if age := time.Since(time.Unix(ts.Seconds, int64(ts.Nanos))); age > window || age < -window {
+		return errors.New("The request is outside of the request window.")
+	}
+
+	return ecaa.eca.registerRequestNonce(nonce, ts.Seconds, window)
+}
+
+// ReadUsers returns the users matching the request that the registrar can register.
+//
+func (ecaa *ECAA) ReadUsers(ctx context.Context, in *pb.ReadUsersReq) (*pb.UserInfoSet, error) {
+	Trace.Println("gRPC ECAA:ReadUsers")
+
+	sig := in.Sig
+	in.Sig = nil
+	registrar, err := ecaa.checkRegistrar(in.Registrar, in.Ts, in.Nonce, sig, in)
+	if err != nil {
+		return nil, err
+	}
+
+	role := int(in.Role)
+	if role == 0 {
+		role = int(pb.Role_ALL)
+	}
+	rows, err := ecaa.eca.readUserInfos(role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*pb.UserInfo
+	for rows.Next() {
+		var id, enrollID string
+		var role, state, disabled int
+		if err = rows.Scan(&id, &enrollID, &role, &state, &disabled); err != nil {
+			return nil, err
+		}
+
+		var affiliation string
+		if enrollID != "" {
+			_, affiliation, _ = ecaa.eca.parseEnrollID(enrollID)
+		}
+		if !strings.Contains(id, in.Id) || (in.Affiliation != "" && affiliation != in.Affiliation) {
+			continue
+		}
+		users = append(users, &pb.UserInfo{Id: &pb.Identity{Id: id}, Role: pb.Role(role), Affiliation: affiliation, Enrolled: state == 2, Disabled: disabled != 0})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	var managed []*pb.UserInfo
+	for _, user := range users {
+		if ecaa.eca.canManage(registrar, user.Id.Id, 0) == nil {
+			managed = append(managed, user)
+		}
+	}
+
+	return &pb.UserInfoSet{Users: managed}, nil
+}
+
+// UpdateUser changes the role or affiliation of a user. The registrar must be allowed to
+// register the user with both its current and new role. The certificates already issued
+// keep the previous role and affiliation until the user enrolls anew.
+//
+func (ecaa *ECAA) UpdateUser(ctx context.Context, in *pb.UpdateUserReq) (*pb.CAStatus, error) {
+	Trace.Println("gRPC ECAA:UpdateUser")
+
+	if in.Id == nil || in.Id.Id == "" {
+		return nil, errors.New("No identity was specified.")
+	}
+	id := in.Id.Id
+
+	sig := in.Sig
+	in.Sig = nil
+	registrar, err := ecaa.checkRegistrar(in.Registrar, in.Ts, in.Nonce, sig, in)
+	if err != nil {
+		return nil, err
+	}
+	if err = ecaa.eca.canManage(registrar, id, int(in.Role)); err != nil {
+		return nil, err
+	}
+
+	var tok, prev []byte
+	var role, state int
+	var enrollID string
+	if err = ecaa.eca.readUser(id).Scan(&role, &tok, &state, &prev, &enrollID); err != nil {
+		return nil, err
+	}
+
+	newRole := pb.Role(role)
+	if in.Role != pb.Role_NONE {
+		newRole = in.Role
+	}
+	affiliation := in.Affiliation
+	if affiliation == "" && enrollID != "" {
+		if _, affiliation, err = ecaa.eca.parseEnrollID(enrollID); err != nil {
+			return nil, err
+		}
+	}
+
+	if enrollID, err = ecaa.eca.validateAndGenerateEnrollID(id, affiliation, newRole); err != nil {
+		return nil, err
+	}
+	if err = ecaa.eca.updateUser(id, enrollID, newRole); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
+}
+
+// EnableUser disables or re-enables the enrollment of a user. A user whose enrollment is
+// disabled can neither enroll, request transaction certificates nor sign requests to the
+// ECA.
+//
+func (ecaa *ECAA) EnableUser(ctx context.Context, in *pb.EnableUserReq) (*pb.CAStatus, error) {
+	Trace.Println("gRPC ECAA:EnableUser")
+
+	if in.Id == nil || in.Id.Id == "" {
+		return nil, errors.New("No identity was specified.")
+	}
+	id := in.Id.Id
+
+	sig := in.Sig
+	in.Sig = nil
+	registrar, err := ecaa.checkRegistrar(in.Registrar, in.Ts, in.Nonce, sig, in)
+	if err != nil {
+		return nil, err
+	}
+	if err = ecaa.eca.canManage(registrar, id, 0); err != nil {
+		return nil, err
+	}
+
+	if err = ecaa.eca.setUserDisabled(id, !in.Enabled); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
+}
+
+// ResetEnrollmentSecret revokes the certificates of a user and returns the new one-time
+// password it enrolls with.
+//
+func (ecaa *ECAA) ResetEnrollmentSecret(ctx context.Context, in *pb.ResetEnrollmentSecretReq) (*pb.Token, error) {
+	Trace.Println("gRPC ECAA:ResetEnrollmentSecret")
+
+	if in.Id == nil || in.Id.Id == "" {
+		return nil, errors.New("No identity was specified.")
+	}
+	id := in.Id.Id
+
+	sig := in.Sig
+	in.Sig = nil
+	registrar, err := ecaa.checkRegistrar(in.Registrar, in.Ts, in.Nonce, sig, in)
+	if err != nil {
+		return nil, err
+	}
+	if err = ecaa.eca.canManage(registrar, id, 0); err != nil {
+		return nil, err
+	}
+
+	if err = ecaa.eca.revokeCertificates(id); err != nil {
+		return nil, err
+	}
+	tok, err := ecaa.eca.resetUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.Token{Tok: []byte(tok)}, nil
+}
+
+// checkAffiliationGroupRegistrar checks the request on an affiliation group. Groups are the
+// affiliations of clients and peers, the registrar must be allowed to register either.
+//
+func (ecaa *ECAA) checkAffiliationGroupRegistrar(in *pb.AffiliationGroupReq) error {
+	if in.Name == "" {
+		return errors.New("No affiliation group was specified.")
+	}
+	if strings.Contains(in.Name, "\\") {
+		return errors.New("Do not include the escape character \\ as part of the values")
+	}
+
+	sig := in.Sig
+	in.Sig = nil
+	registrar, err := ecaa.checkRegistrar(in.Registrar, in.Ts, in.Nonce, sig, in)
+	if err != nil {
+		return err
+	}
+	if err = ecaa.eca.canRegister(registrar, role2String(int(pb.Role_CLIENT)), ""); err != nil {
+		return ecaa.eca.canRegister(registrar, role2String(int(pb.Role_PEER)), "")
+	}
+	return nil
+}
+
+// RegisterAffiliationGroup registers a new affiliation group.
+//
+func (ecaa *ECAA) RegisterAffiliationGroup(ctx context.Context, in *pb.AffiliationGroupReq) (*pb.CAStatus, error) {
+	Trace.Println("gRPC ECAA:RegisterAffiliationGroup")
+
+	if err := ecaa.checkAffiliationGroupRegistrar(in); err != nil {
+		return nil, err
+	}
+	if in.Parent != "" {
+		if valid, err := ecaa.eca.isValidAffiliation(in.Parent); err != nil || !valid {
+			return nil, errors.New("Invalid affiliation group " + in.Parent)
+		}
+	}
+
+	if err := ecaa.eca.registerAffiliationGroup(in.Name, in.Parent); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
+}
+
+// DeleteAffiliationGroup deletes an affiliation group without members or subgroups.
+//
+func (ecaa *ECAA) DeleteAffiliationGroup(ctx context.Context, in *pb.AffiliationGroupReq) (*pb.CAStatus, error) {
+	Trace.Println("gRPC ECAA:DeleteAffiliationGroup")
+
+	if err := ecaa.checkAffiliationGroupRegistrar(in); err != nil {
+		return nil, err
+	}
+
+	if err := ecaa.eca.deleteAffiliationGroup(in.Name); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
 }
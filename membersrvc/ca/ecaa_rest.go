@@ -0,0 +1,236 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gocraft/web"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+)
+
+// ecaaServer holds the ECAA served by the REST API. This is necessary due to how the
+// gocraft/web package implements context initialization.
+var ecaaServer *ECAA
+
+// ECAAREST serves the administrator interface of the ECA over HTTP. The bodies of the
+// requests are the JSON encoding of the ECAA requests, signed by the registrar as over
+// gRPC; the responses are the JSON encoding of the ECAA responses.
+//
+type ECAAREST struct {
+	ecaa *ECAA
+}
+
+// ecaaRESTResult is the response of a failed request.
+type ecaaRESTResult struct {
+	Error string `json:",omitempty"`
+}
+
+// SetECAA is a middleware function that sets the ECAA the requests are served by.
+//
+func (s *ECAAREST) SetECAA(rw web.ResponseWriter, req *web.Request, next web.NextMiddlewareFunc) {
+	s.ecaa = ecaaServer
+
+	next(rw, req)
+}
+
+// SetResponseType is a middleware function that sets the content type of the responses.
+//
+func (s *ECAAREST) SetResponseType(rw web.ResponseWriter, req *web.Request, next web.NextMiddlewareFunc) {
+	rw.Header().Set("Content-Type", "application/json")
+
+	next(rw, req)
+}
+
+func writeECAAError(rw web.ResponseWriter, status int, err error) {
+	Trace.Printf("ECAA REST: %s\n", err)
+
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(ecaaRESTResult{Error: err.Error()})
+}
+
+// writeECAAResponse writes the response of an ECAA call
+func writeECAAResponse(rw web.ResponseWriter, resp proto.Message, err error) {
+	if err != nil {
+		writeECAAError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	if err = (&jsonpb.Marshaler{}).Marshal(rw, resp); err != nil {
+		Error.Println(err)
+	}
+}
+
+// readECAARequest decodes the body of the request into in
+func readECAARequest(rw web.ResponseWriter, req *web.Request, in proto.Message) bool {
+	if err := jsonpb.Unmarshal(req.Body, in); err != nil {
+		writeECAAError(rw, http.StatusBadRequest, errors.New("Invalid request body: "+err.Error()))
+		return false
+	}
+	return true
+}
+
+// checkECAAPath checks that the path parameter name of the request is value
+func checkECAAPath(rw web.ResponseWriter, req *web.Request, name string, value string) bool {
+	if req.PathParams[name] != value {
+		writeECAAError(rw, http.StatusBadRequest, errors.New("The request does not match the path."))
+		return false
+	}
+	return true
+}
+
+// identityOf returns the id of an identity of a request, empty if missing
+func identityOf(id *pb.Identity) string {
+	if id == nil {
+		return ""
+	}
+	return id.Id
+}
+
+// RegisterUser registers a new user, see ECAA.RegisterUser.
+//
+func (s *ECAAREST) RegisterUser(rw web.ResponseWriter, req *web.Request) {
+	in := &pb.RegisterUserReq{}
+	if !readECAARequest(rw, req, in) {
+		return
+	}
+
+	resp, err := s.ecaa.RegisterUser(context.Background(), in)
+	writeECAAResponse(rw, resp, err)
+}
+
+// ReadUsers lists and searches users, see ECAA.ReadUsers.
+//
+func (s *ECAAREST) ReadUsers(rw web.ResponseWriter, req *web.Request) {
+	in := &pb.ReadUsersReq{}
+	if !readECAARequest(rw, req, in) {
+		return
+	}
+
+	resp, err := s.ecaa.ReadUsers(context.Background(), in)
+	writeECAAResponse(rw, resp, err)
+}
+
+// UpdateUser changes the role or affiliation of a user, see ECAA.UpdateUser.
+//
+func (s *ECAAREST) UpdateUser(rw web.ResponseWriter, req *web.Request) {
+	in := &pb.UpdateUserReq{}
+	if !readECAARequest(rw, req, in) || !checkECAAPath(rw, req, "id", identityOf(in.Id)) {
+		return
+	}
+
+	resp, err := s.ecaa.UpdateUser(context.Background(), in)
+	writeECAAResponse(rw, resp, err)
+}
+
+// EnableUser disables or re-enables the enrollment of a user, see ECAA.EnableUser.
+//
+func (s *ECAAREST) EnableUser(rw web.ResponseWriter, req *web.Request) {
+	in := &pb.EnableUserReq{}
+	if !readECAARequest(rw, req, in) || !checkECAAPath(rw, req, "id", identityOf(in.Id)) {
+		return
+	}
+
+	resp, err := s.ecaa.EnableUser(context.Background(), in)
+	writeECAAResponse(rw, resp, err)
+}
+
+// ResetEnrollmentSecret gives a user a new enrollment secret, see
+// ECAA.ResetEnrollmentSecret.
+//
+func (s *ECAAREST) ResetEnrollmentSecret(rw web.ResponseWriter, req *web.Request) {
+	in := &pb.ResetEnrollmentSecretReq{}
+	if !readECAARequest(rw, req, in) || !checkECAAPath(rw, req, "id", identityOf(in.Id)) {
+		return
+	}
+
+	resp, err := s.ecaa.ResetEnrollmentSecret(context.Background(), in)
+	writeECAAResponse(rw, resp, err)
+}
+
+// RegisterAffiliationGroup registers a new affiliation group, see
+// ECAA.RegisterAffiliationGroup.
+//
+func (s *ECAAREST) RegisterAffiliationGroup(rw web.ResponseWriter, req *web.Request) {
+	in := &pb.AffiliationGroupReq{}
+	if !readECAARequest(rw, req, in) {
+		return
+	}
+
+	resp, err := s.ecaa.RegisterAffiliationGroup(context.Background(), in)
+	writeECAAResponse(rw, resp, err)
+}
+
+// DeleteAffiliationGroup deletes an affiliation group, see ECAA.DeleteAffiliationGroup.
+//
+func (s *ECAAREST) DeleteAffiliationGroup(rw web.ResponseWriter, req *web.Request) {
+	in := &pb.AffiliationGroupReq{}
+	if !readECAARequest(rw, req, in) || !checkECAAPath(rw, req, "name", in.Name) {
+		return
+	}
+
+	resp, err := s.ecaa.DeleteAffiliationGroup(context.Background(), in)
+	writeECAAResponse(rw, resp, err)
+}
+
+// NotFound is the response to the requests of undefined endpoints.
+//
+func (s *ECAAREST) NotFound(rw web.ResponseWriter, req *web.Request) {
+	writeECAAError(rw, http.StatusNotFound, errors.New("ECAA endpoint not found."))
+}
+
+func buildECAARESTRouter() *web.Router {
+	router := web.New(ECAAREST{})
+
+	router.Middleware((*ECAAREST).SetECAA)
+	router.Middleware((*ECAAREST).SetResponseType)
+
+	router.Post("/ecaa/users", (*ECAAREST).RegisterUser)
+	router.Post("/ecaa/users/search", (*ECAAREST).ReadUsers)
+	router.Put("/ecaa/users/:id", (*ECAAREST).UpdateUser)
+	router.Put("/ecaa/users/:id/enabled", (*ECAAREST).EnableUser)
+	router.Post("/ecaa/users/:id/secret", (*ECAAREST).ResetEnrollmentSecret)
+	router.Post("/ecaa/affiliations", (*ECAAREST).RegisterAffiliationGroup)
+	router.Delete("/ecaa/affiliations/:name", (*ECAAREST).DeleteAffiliationGroup)
+
+	router.NotFound((*ECAAREST).NotFound)
+
+	return router
+}
+
+// ServeREST serves the REST API of the ECAA on address over TLS, the server must have a
+// TLS certificate. It returns when the server fails.
+//
+func (eca *ECA) ServeREST(address string) error {
+	cert := viper.GetString("server.tls.cert.file")
+	if cert == "" {
+		return errors.New("The ECA ADMIN REST API is served over TLS only, no TLS certificate is configured.")
+	}
+
+	ecaaServer = &ECAA{eca}
+	router := buildECAARESTRouter()
+
+	Info.Printf("ECA ADMIN REST API server listening on %s\n", address)
+	return http.ListenAndServeTLS(address, cert, viper.GetString("server.tls.key.file"), router)
+}
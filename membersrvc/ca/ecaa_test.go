@@ -0,0 +1,354 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"google/protobuf"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+)
+
+var testManagedUser = User{enrollID: "testManagedUser", role: 1, affiliation: "institution_a"}
+
+// requestTimestamp returns the timestamp of a management request sent now
+func requestTimestamp() *google_protobuf.Timestamp {
+	return &google_protobuf.Timestamp{Seconds: time.Now().Unix(), Nanos: 0}
+}
+
+// requestNonce returns a new nonce of a management request
+func requestNonce() []byte {
+	nonce := make([]byte, minRequestNonceLength)
+	rand.Reader.Read(nonce)
+	return nonce
+}
+
+// readUserInfo returns the user id as listed to registrar, nil if not listed
+func readUserInfo(t *testing.T, registrar *User, id string) *pb.UserInfo {
+	ecaa := &ECAA{eca}
+
+	req := &pb.ReadUsersReq{Registrar: &pb.Identity{Id: registrar.enrollID}, Id: id, Ts: requestTimestamp(), Nonce: requestNonce()}
+	req.Sig = signRequest(t, registrar, req)
+
+	resp, err := ecaa.ReadUsers(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Failed to read users: [%s]", err.Error())
+	}
+	for _, user := range resp.Users {
+		if user.Id.Id == id {
+			return user
+		}
+	}
+	return nil
+}
+
+func TestReadUsers(t *testing.T) {
+	ecaa := &ECAA{eca}
+
+	if err := registerUser(testAdmin, &testManagedUser); err != nil {
+		t.Fatalf("Failed to register user: [%s]", err.Error())
+	}
+
+	user := readUserInfo(t, &testAdmin, testManagedUser.enrollID)
+	if user == nil {
+		t.Fatalf("User %s not listed", testManagedUser.enrollID)
+	}
+	if user.Role != pb.Role_CLIENT || user.Affiliation != "institution_a" || user.Enrolled || user.Disabled {
+		t.Fatalf("Unexpected user info [%v]", user)
+	}
+
+	// Users are searched by role
+	req := &pb.ReadUsersReq{Registrar: &pb.Identity{Id: testAdmin.enrollID}, Id: testManagedUser.enrollID, Role: pb.Role_PEER, Ts: requestTimestamp(), Nonce: requestNonce()}
+	req.Sig = signRequest(t, &testAdmin, req)
+
+	resp, err := ecaa.ReadUsers(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Failed to read users: [%s]", err.Error())
+	}
+	if len(resp.Users) != 0 {
+		t.Fatalf("Unexpected users of role peer [%v]", resp.Users)
+	}
+
+	// A member who is not a registrar cannot list users
+	req = &pb.ReadUsersReq{Registrar: &pb.Identity{Id: testUser.enrollID}, Ts: requestTimestamp(), Nonce: requestNonce()}
+	req.Sig = signRequest(t, &testUser, req)
+
+	if _, err = ecaa.ReadUsers(context.Background(), req); err == nil {
+		t.Fatal("Only registrars should be able to read users")
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	ecaa := &ECAA{eca}
+
+	req := &pb.UpdateUserReq{Registrar: &pb.Identity{Id: testAdmin.enrollID}, Id: &pb.Identity{Id: testManagedUser.enrollID}, Affiliation: "bank_a", Ts: requestTimestamp(), Nonce: requestNonce()}
+	req.Sig = signRequest(t, &testAdmin, req)
+
+	if _, err := ecaa.UpdateUser(context.Background(), req); err != nil {
+		t.Fatalf("Failed to update user: [%s]", err.Error())
+	}
+	if user := readUserInfo(t, &testAdmin, testManagedUser.enrollID); user == nil || user.Affiliation != "bank_a" || user.Role != pb.Role_CLIENT {
+		t.Fatalf("User not updated [%v]", user)
+	}
+
+	// Affiliations must be registered
+	req = &pb.UpdateUserReq{Registrar: &pb.Identity{Id: testAdmin.enrollID}, Id: &pb.Identity{Id: testManagedUser.enrollID}, Affiliation: "bank_z", Ts: requestTimestamp(), Nonce: requestNonce()}
+	req.Sig = signRequest(t, &testAdmin, req)
+
+	if _, err := ecaa.UpdateUser(context.Background(), req); err == nil {
+		t.Fatal("Users should not be moved to an unknown affiliation")
+	}
+
+	// A member who is not a registrar cannot update users
+	req = &pb.UpdateUserReq{Registrar: &pb.Identity{Id: testUser.enrollID}, Id: &pb.Identity{Id: testManagedUser.enrollID}, Affiliation: "institution_a", Ts: requestTimestamp(), Nonce: requestNonce()}
+	req.Sig = signRequest(t, &testUser, req)
+
+	if _, err := ecaa.UpdateUser(context.Background(), req); err == nil {
+		t.Fatal("Only registrars should be able to update users")
+	}
+}
+
+func enableUser(t *testing.T, user *User, enabled bool) {
+	ecaa := &ECAA{eca}
+
+	req := &pb.EnableUserReq{Registrar: &pb.Identity{Id: testAdmin.enrollID}, Id: &pb.Identity{Id: user.enrollID}, Enabled: enabled, Ts: requestTimestamp(), Nonce: requestNonce()}
+	req.Sig = signRequest(t, &testAdmin, req)
+
+	if _, err := ecaa.EnableUser(context.Background(), req); err != nil {
+		t.Fatalf("Failed to enable user: [%s]", err.Error())
+	}
+}
+
+func TestEnableUser(t *testing.T) {
+	ecap := &ECAP{eca}
+	ecaa := &ECAA{eca}
+
+	enableUser(t, &testManagedUser, false)
+	if user := readUserInfo(t, &testAdmin, testManagedUser.enrollID); user == nil || !user.Disabled {
+		t.Fatalf("User not disabled [%v]", user)
+	}
+	if err := enrollUser(&testManagedUser); err == nil {
+		t.Fatal("Disabled users should not be able to enroll")
+	}
+
+	enableUser(t, &testManagedUser, true)
+	if err := enrollUser(&testManagedUser); err != nil {
+		t.Fatalf("Failed to enroll user: [%s]", err.Error())
+	}
+
+	// Requests signed by a disabled user must be rejected
+	enableUser(t, &testManagedUser, false)
+	req := &pb.ECertRevokeReq{Id: &pb.Identity{Id: testManagedUser.enrollID}}
+	req.Sig = signRequest(t, &testManagedUser, req)
+
+	if _, err := ecap.RevokeCertificatePair(context.Background(), req); err == nil {
+		t.Fatal("Requests signed by a disabled user should be rejected")
+	}
+	enableUser(t, &testManagedUser, true)
+
+	// A member who is not a registrar cannot disable users
+	enable := &pb.EnableUserReq{Registrar: &pb.Identity{Id: testUser.enrollID}, Id: &pb.Identity{Id: testManagedUser.enrollID}, Ts: requestTimestamp(), Nonce: requestNonce()}
+	enable.Sig = signRequest(t, &testUser, enable)
+
+	if _, err := ecaa.EnableUser(context.Background(), enable); err == nil {
+		t.Fatal("Only registrars should be able to disable users")
+	}
+}
+
+func TestResetEnrollmentSecret(t *testing.T) {
+	ecap := &ECAP{eca}
+	ecaa := &ECAA{eca}
+
+	pair, err := ecap.ReadCertificatePair(context.Background(), &pb.ECertReadReq{Id: &pb.Identity{Id: testManagedUser.enrollID}})
+	if err != nil {
+		t.Fatalf("Failed to read certificate pair: [%s]", err.Error())
+	}
+
+	req := &pb.ResetEnrollmentSecretReq{Registrar: &pb.Identity{Id: testAdmin.enrollID}, Id: &pb.Identity{Id: testManagedUser.enrollID}, Ts: requestTimestamp(), Nonce: requestNonce()}
+	req.Sig = signRequest(t, &testAdmin, req)
+
+	tok, err := ecaa.ResetEnrollmentSecret(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Failed to reset enrollment secret: [%s]", err.Error())
+	}
+
+	cert, err := x509.ParseCertificate(pair.Sign)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: [%s]", err.Error())
+	}
+	if revoked, _ := eca.isRevoked(cert.SerialNumber); !revoked {
+		t.Fatal("Certificate should have been revoked")
+	}
+
+	// The previous secret is no longer valid
+	if err = enrollUser(&testManagedUser); err == nil {
+		t.Fatal("Users should not enroll with a reset secret")
+	}
+	testManagedUser.enrollPwd = tok.Tok
+	if err = enrollUser(&testManagedUser); err != nil {
+		t.Fatalf("Failed to enroll user: [%s]", err.Error())
+	}
+
+	// A member who is not a registrar cannot reset secrets
+	req = &pb.ResetEnrollmentSecretReq{Registrar: &pb.Identity{Id: testUser.enrollID}, Id: &pb.Identity{Id: testManagedUser.enrollID}, Ts: requestTimestamp(), Nonce: requestNonce()}
+	req.Sig = signRequest(t, &testUser, req)
+
+	if _, err = ecaa.ResetEnrollmentSecret(context.Background(), req); err == nil {
+		t.Fatal("Only registrars should be able to reset enrollment secrets")
+	}
+}
+
+func TestAffiliationGroups(t *testing.T) {
+	ecaa := &ECAA{eca}
+
+	for _, test := range []struct {
+		registrar *User
+		name      string
+		parent    string
+		delete    bool
+		success   bool
+	}{
+		{&testAdmin, "bank_z", "banks", false, true},
+		{&testAdmin, "bank_z", "banks", false, false},
+		{&testAdmin, "bank_y", "unknown", false, false},
+		{&testUser, "bank_y", "banks", false, false},
+		{&testAdmin, "banks", "", true, false},
+		{&testAdmin, "bank_a", "", true, false},
+		{&testUser, "bank_z", "", true, false},
+		{&testAdmin, "bank_z", "", true, true},
+		{&testAdmin, "bank_z", "", true, false},
+	} {
+		req := &pb.AffiliationGroupReq{Registrar: &pb.Identity{Id: test.registrar.enrollID}, Name: test.name, Parent: test.parent, Ts: requestTimestamp(), Nonce: requestNonce()}
+		req.Sig = signRequest(t, test.registrar, req)
+
+		var err error
+		if test.delete {
+			_, err = ecaa.DeleteAffiliationGroup(context.Background(), req)
+		} else {
+			_, err = ecaa.RegisterAffiliationGroup(context.Background(), req)
+		}
+		if (err == nil) != test.success {
+			t.Fatalf("Unexpected result [%v] of %s deleting %t group %s", err, test.registrar.enrollID, test.delete, test.name)
+		}
+	}
+}
+
+func TestReplayedRequests(t *testing.T) {
+	ecaa := &ECAA{eca}
+
+	req := &pb.EnableUserReq{Registrar: &pb.Identity{Id: testAdmin.enrollID}, Id: &pb.Identity{Id: testManagedUser.enrollID}, Enabled: true, Ts: requestTimestamp(), Nonce: requestNonce()}
+	req.Sig = signRequest(t, &testAdmin, req)
+	sig := req.Sig
+
+	if _, err := ecaa.EnableUser(context.Background(), req); err != nil {
+		t.Fatalf("Failed to enable user: [%s]", err.Error())
+	}
+
+	// The same request is served once
+	req.Sig = sig
+	if _, err := ecaa.EnableUser(context.Background(), req); err == nil {
+		t.Fatal("Replayed requests should be rejected")
+	}
+
+	for _, ts := range []*google_protobuf.Timestamp{
+		nil,
+		{Seconds: time.Now().Add(-defaultRequestWindow - time.Minute).Unix()},
+		{Seconds: time.Now().Add(defaultRequestWindow + time.Minute).Unix()},
+	} {
+		req = &pb.EnableUserReq{Registrar: &pb.Identity{Id: testAdmin.enrollID}, Id: &pb.Identity{Id: testManagedUser.enrollID}, Enabled: true, Ts: ts, Nonce: requestNonce()}
+		req.Sig = signRequest(t, &testAdmin, req)
+
+		if _, err := ecaa.EnableUser(context.Background(), req); err == nil {
+			t.Fatalf("Requests sent at %v should be rejected", ts)
+		}
+	}
+
+	// Nonces must be long enough not to repeat
+	req = &pb.EnableUserReq{Registrar: &pb.Identity{Id: testAdmin.enrollID}, Id: &pb.Identity{Id: testManagedUser.enrollID}, Enabled: true, Ts: requestTimestamp(), Nonce: []byte{1}}
+	req.Sig = signRequest(t, &testAdmin, req)
+
+	if _, err := ecaa.EnableUser(context.Background(), req); err == nil {
+		t.Fatal("Requests with a short nonce should be rejected")
+	}
+}
+
+func TestServeRESTWithoutTLS(t *testing.T) {
+	defer viper.Set("server.tls.cert.file", viper.Get("server.tls.cert.file"))
+	viper.Set("server.tls.cert.file", "")
+
+	if err := eca.ServeREST("localhost:0"); err == nil {
+		t.Fatal("The REST API should not be served without TLS")
+	}
+}
+
+func TestECAAREST(t *testing.T) {
+	ecaaServer = &ECAA{eca}
+	httpServer := httptest.NewTLSServer(buildECAARESTRouter())
+	defer httpServer.Close()
+
+	// The certificate of the test server is self-signed
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+
+	send := func(method, path string, in *pb.EnableUserReq) *http.Response {
+		body, err := (&jsonpb.Marshaler{}).MarshalToString(in)
+		if err != nil {
+			t.Fatalf("Failed to encode request: [%s]", err.Error())
+		}
+		req, err := http.NewRequest(method, httpServer.URL+path, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: [%s]", err.Error())
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: [%s]", err.Error())
+		}
+		return resp
+	}
+
+	req := &pb.EnableUserReq{Registrar: &pb.Identity{Id: testAdmin.enrollID}, Id: &pb.Identity{Id: testManagedUser.enrollID}, Enabled: true, Ts: requestTimestamp(), Nonce: requestNonce()}
+	req.Sig = signRequest(t, &testAdmin, req)
+
+	resp := send("PUT", "/ecaa/users/"+testManagedUser.enrollID+"/enabled", req)
+	defer resp.Body.Close()
+	status := &pb.CAStatus{}
+	if err := jsonpb.Unmarshal(resp.Body, status); err != nil || resp.StatusCode != http.StatusOK || status.Status != pb.CAStatus_OK {
+		t.Fatalf("Unexpected response %d [%v] [%v]", resp.StatusCode, status, err)
+	}
+
+	// The path must match the request
+	resp = send("PUT", "/ecaa/users/"+testUser.enrollID+"/enabled", req)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	resp = send("GET", "/ecaa/unknown", req)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected status %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"crypto/subtle"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"database/sql"
 	"errors"
 	"google/protobuf"
 	"io/ioutil"
@@ -33,6 +34,7 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/util"
 	pb "github.com/hyperledger/fabric/membersrvc/protos"
 	"github.com/spf13/viper"
 	"golang.org/x/net/context"
@@ -107,32 +109,62 @@ func (ecap *ECAP) CreateCertificatePair(ctx context.Context, in *pb.ECertCreateR
 
 	id := in.Id.Id
 	err := ecap.eca.readUser(id).Scan(&role, &tok, &state, &prev, &enrollID)
+	authenticated := err == nil && bytes.Equal(tok, in.Tok.Tok)
+
+	// Users of the registry start their enrollment with their directory password, the
+	// registry is checked for these users only
+	if !authenticated && ecap.eca.registry != nil && (err == sql.ErrNoRows || (err == nil && state == 0)) {
+		if err = ecap.eca.authenticateRegistryUser(id, in.Tok.Tok); err != nil {
+			Trace.Printf("User registry authentication failed: id=%s: %s\n", id, err)
+			return nil, errors.New("Identity or token does not match.")
+		}
+		err = ecap.eca.readUser(id).Scan(&role, &tok, &state, &prev, &enrollID)
+		authenticated = true
+	}
 
 	if err != nil {
 		errMsg := "Identity lookup error: " + err.Error()
 		Trace.Println(errMsg)
 		return nil, errors.New(errMsg)
 	}
-	if !bytes.Equal(tok, in.Tok.Tok) {
+	if !authenticated {
 		Trace.Printf("id or token mismatch: id=%s\n", id)
 		return nil, errors.New("Identity or token does not match.")
 	}
+	disabled, err := ecap.eca.isUserDisabled(id)
+	if err != nil {
+		return nil, err
+	}
+	if disabled {
+		Trace.Printf("enrollment disabled: id=%s\n", id)
+		return nil, errors.New("Enrollment has been disabled.")
+	}
 
 	ekey, err := x509.ParsePKIXPublicKey(in.Enc.Key)
 	if err != nil {
 		return nil, err
 	}
 
+	// The subject works with its own crypto suite, which its ECerts advertise
+	suite, err := getSubjectCryptoSuite(in.CryptoSuite, ekey.(*ecdsa.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+
 	fetchResult := pb.FetchAttrsResult{Status: pb.FetchAttrsResult_SUCCESS, Msg: ""}
 	switch {
 	case state == 0:
 		// initial request, create encryption challenge
 		tok = []byte(randomString(12))
 
+		// The state guards against a replica serving a concurrent request of the user
 		mutex.Lock()
-		_, err = ecap.eca.db.Exec("UPDATE Users SET token=?, state=?, key=? WHERE id=?", tok, 1, in.Enc.Key, id)
+		res, err := ecap.eca.db.Exec(ecap.eca.rebind("UPDATE Users SET token=?, state=?, key=? WHERE id=? AND state=?"), tok, 1, in.Enc.Key, id, 0)
 		mutex.Unlock()
 
+		if err == nil {
+			err = checkRowUpdated(res)
+		}
 		if err != nil {
 			Error.Println(err)
 			return nil, err
@@ -175,7 +207,11 @@ func (ecap *ECAP) CreateCertificatePair(ctx context.Context, in *pb.ECertCreateR
 			return nil, err
 		}
 
-		hash := primitives.NewHash()
+		if skey.(*ecdsa.PublicKey).Curve != suite.Curve {
+			return nil, errors.New("Signing key is not on the curve of the crypto suite.")
+		}
+
+		hash := suite.Hash()
 		raw, _ := proto.Marshal(in)
 		hash.Write(raw)
 		if ecdsa.Verify(skey.(*ecdsa.PublicKey), hash.Sum(nil), r, s) == false {
@@ -185,7 +221,8 @@ func (ecap *ECAP) CreateCertificatePair(ctx context.Context, in *pb.ECertCreateR
 		// create new certificate pair
 		ts := time.Now().Add(-1 * time.Minute).UnixNano()
 
-		spec := NewDefaultCertificateSpecWithCommonName(id, enrollID, skey.(*ecdsa.PublicKey), x509.KeyUsageDigitalSignature, pkix.Extension{Id: ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(ecap.eca.readRole(id)))})
+		spec := NewDefaultPeriodCertificateSpecWithCommonName(id, enrollID, util.GenerateIntUUID(), skey.(*ecdsa.PublicKey), x509.KeyUsageDigitalSignature, pkix.Extension{Id: ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(ecap.eca.readRole(id)))})
+		spec.SetCryptoSuite(suite)
 		sraw, err := ecap.eca.createCertificateFromSpec(spec, ts, nil, true)
 		if err != nil {
 			Error.Println(err)
@@ -194,22 +231,28 @@ func (ecap *ECAP) CreateCertificatePair(ctx context.Context, in *pb.ECertCreateR
 
 		_ = ioutil.WriteFile("/tmp/ecert_"+id, sraw, 0644)
 
-		spec = NewDefaultCertificateSpecWithCommonName(id, enrollID, ekey.(*ecdsa.PublicKey), x509.KeyUsageDataEncipherment, pkix.Extension{Id: ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(ecap.eca.readRole(id)))})
+		spec = NewDefaultPeriodCertificateSpecWithCommonName(id, enrollID, util.GenerateIntUUID(), ekey.(*ecdsa.PublicKey), x509.KeyUsageDataEncipherment, pkix.Extension{Id: ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(ecap.eca.readRole(id)))})
+		spec.SetCryptoSuite(suite)
 		eraw, err := ecap.eca.createCertificateFromSpec(spec, ts, nil, true)
 		if err != nil {
 			mutex.Lock()
-			ecap.eca.db.Exec("DELETE FROM Certificates Where id=?", id)
+			ecap.eca.db.Exec(ecap.eca.rebind("DELETE FROM Certificates Where id=?"), id)
 			mutex.Unlock()
 			Error.Println(err)
 			return nil, err
 		}
 
 		mutex.Lock()
-		_, err = ecap.eca.db.Exec("UPDATE Users SET state=? WHERE id=?", 2, id)
+		res, err := ecap.eca.db.Exec(ecap.eca.rebind("UPDATE Users SET state=? WHERE id=? AND state=?"), 2, id, 1)
 		mutex.Unlock()
+		if err == nil {
+			err = checkRowUpdated(res)
+		}
 		if err != nil {
+			// Only the certificates of this request, those of a concurrent request
+			// which completed the enrollment are kept
 			mutex.Lock()
-			ecap.eca.db.Exec("DELETE FROM Certificates Where id=?", id)
+			ecap.eca.db.Exec(ecap.eca.rebind("DELETE FROM Certificates Where id=? AND timestamp=?"), id, ts)
 			mutex.Unlock()
 			Error.Println(err)
 			return nil, err
@@ -244,24 +287,27 @@ func (ecap *ECAP) ReadCertificatePair(ctx context.Context, in *pb.ECertReadReq)
 	Trace.Println("gRPC ECAP:ReadCertificate")
 
 	rows, err := ecap.eca.readCertificates(in.Id.Id)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
-	hasResults := false
 	var certs [][]byte
-	if err == nil {
-		for rows.Next() {
-			hasResults = true
-			var raw []byte
-			err = rows.Scan(&raw)
-			certs = append(certs, raw)
+	for rows.Next() {
+		var raw, kdfKey []byte
+		if err = rows.Scan(&raw, &kdfKey); err != nil {
+			return nil, err
 		}
-		err = rows.Err()
+		certs = append(certs, raw)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
 
-	if !hasResults {
+	if len(certs) < 2 {
 		return nil, errors.New("No certificates for the given identity were found.")
 	}
-	return &pb.CertPair{Sign: certs[0], Enc: certs[1]}, err
+	return &pb.CertPair{Sign: certs[0], Enc: certs[1]}, nil
 }
 
 // ReadCertificateByHash reads a single enrollment certificate by hash from the ECA.
@@ -273,10 +319,39 @@ func (ecap *ECAP) ReadCertificateByHash(ctx context.Context, hash *pb.Hash) (*pb
 	return &pb.Cert{Cert: raw}, err
 }
 
-// RevokeCertificatePair revokes a certificate pair from the ECA.  Not yet implemented.
+// RevokeCertificatePair revokes a certificate pair from the ECA.  A user can only revoke
+// his/her own certificate pair.
 //
-func (ecap *ECAP) RevokeCertificatePair(context.Context, *pb.ECertRevokeReq) (*pb.CAStatus, error) {
+func (ecap *ECAP) RevokeCertificatePair(ctx context.Context, in *pb.ECertRevokeReq) (*pb.CAStatus, error) {
 	Trace.Println("gRPC ECAP:RevokeCertificate")
 
-	return nil, errors.New("ECAP:RevokeCertificate method not (yet) implemented")
+	if in.Id == nil || in.Id.Id == "" {
+		return nil, errors.New("No identity was specified.")
+	}
+	id := in.Id.Id
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := ecap.eca.verifySignature(id, sig, in); err != nil {
+		return nil, err
+	}
+
+	if err := ecap.eca.revokeCertificatePair(id, in.Cert); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
+}
+
+// ReadCRL reads the last certificate revocation list published by the ECA.
+//
+func (ecap *ECAP) ReadCRL(ctx context.Context, in *pb.Empty) (*pb.CRL, error) {
+	Trace.Println("gRPC ECAP:ReadCRL")
+
+	raw, err := ecap.eca.readCRL()
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.CRL{Crl: raw}, nil
 }
@@ -0,0 +1,255 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/viper"
+	"gopkg.in/ldap.v2"
+)
+
+// ldapUserRegistryName selects the LDAP registry in the eca.registry.type property
+const ldapUserRegistryName = "ldap"
+
+func init() {
+	RegisterUserRegistry(ldapUserRegistryName, newLDAPUserRegistryFromConfig)
+}
+
+// ldapUserRegistry looks the users up in a directory with the credentials of the ECA, and
+// authenticates them by binding with their entry and password.
+type ldapUserRegistry struct {
+	address string
+	timeout time.Duration
+
+	// tls is set unless the configuration disables TLS, passwords are sent in cleartext
+	// otherwise. rootCAs, if set, replaces the system roots to verify the directory.
+	tls     bool
+	rootCAs *x509.CertPool
+
+	bindDN       string
+	bindPassword string
+	baseDN       string
+
+	uidAttribute         string
+	affiliationAttribute string
+	roleAttribute        string
+	metadataAttribute    string
+
+	// attributes maps the names of the attributes issued by the ACA to the attributes of
+	// the directory
+	attributes map[string]string
+}
+
+func newLDAPUserRegistryFromConfig() (UserRegistry, error) {
+	key := "eca.registry.ldap."
+
+	registry := &ldapUserRegistry{
+		address:              viper.GetString(key + "address"),
+		tls:                  true,
+		timeout:              10 * time.Second,
+		bindDN:               viper.GetString(key + "bindDN"),
+		bindPassword:         viper.GetString(key + "bindPassword"),
+		baseDN:               viper.GetString(key + "baseDN"),
+		uidAttribute:         "uid",
+		affiliationAttribute: "ou",
+		roleAttribute:        viper.GetString(key + "attributes.role"),
+		metadataAttribute:    viper.GetString(key + "attributes.metadata"),
+		attributes:           viper.GetStringMapString(key + "aca"),
+	}
+	if registry.address == "" {
+		return nil, errors.New("Address of the LDAP directory missing")
+	}
+	if viper.IsSet(key + "tls.enabled") {
+		registry.tls = viper.GetBool(key + "tls.enabled")
+	}
+	if file := viper.GetString(key + "tls.rootcert.file"); registry.tls && file != "" {
+		pem, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		registry.rootCAs = x509.NewCertPool()
+		if !registry.rootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificate in the LDAP root certificate file %s", file)
+		}
+	}
+	if timeout := viper.GetDuration(key + "timeout"); timeout != 0 {
+		registry.timeout = timeout
+	}
+	if uid := viper.GetString(key + "attributes.uid"); uid != "" {
+		registry.uidAttribute = uid
+	}
+	if affiliation := viper.GetString(key + "attributes.affiliation"); affiliation != "" {
+		registry.affiliationAttribute = affiliation
+	}
+
+	return registry, nil
+}
+
+// connect opens a connection bound with the credentials of the ECA
+func (registry *ldapUserRegistry) connect() (*ldap.Conn, error) {
+	dialer := &net.Dialer{Timeout: registry.timeout}
+
+	var conn net.Conn
+	var err error
+	if registry.tls {
+		var host string
+		if host, _, err = net.SplitHostPort(registry.address); err != nil {
+			return nil, err
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", registry.address, &tls.Config{ServerName: host, RootCAs: registry.rootCAs})
+	} else {
+		conn, err = dialer.Dial("tcp", registry.address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ldapConn := ldap.NewConn(conn, registry.tls)
+	ldapConn.Start()
+	ldapConn.SetTimeout(registry.timeout)
+
+	if registry.bindDN != "" {
+		if err := ldapConn.Bind(registry.bindDN, registry.bindPassword); err != nil {
+			ldapConn.Close()
+			return nil, err
+		}
+	}
+	return ldapConn, nil
+}
+
+func (registry *ldapUserRegistry) findUser(conn *ldap.Conn, id string) (*ldap.Entry, error) {
+	attributes := []string{registry.affiliationAttribute}
+	for _, name := range []string{registry.roleAttribute, registry.metadataAttribute} {
+		if name != "" {
+			attributes = append(attributes, name)
+		}
+	}
+	for _, name := range registry.attributes {
+		attributes = append(attributes, name)
+	}
+
+	// Referrals to other directories are not followed
+	result, err := conn.Search(ldap.NewSearchRequest(
+		registry.baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, int(registry.timeout/time.Second), false,
+		fmt.Sprintf("(%s=%s)", registry.uidAttribute, ldap.EscapeFilter(id)), attributes, nil))
+	if err != nil {
+		return nil, err
+	}
+	switch len(result.Entries) {
+	case 0:
+		return nil, fmt.Errorf("User %s not found in the directory", id)
+	case 1:
+		return result.Entries[0], nil
+	default:
+		return nil, fmt.Errorf("Several entries for user %s in the directory", id)
+	}
+}
+
+// ldapAttribute returns the first value of the attribute of the entry, attribute names are
+// case insensitive
+func ldapAttribute(entry *ldap.Entry, name string) string {
+	for _, attribute := range entry.Attributes {
+		if strings.EqualFold(attribute.Name, name) && len(attribute.Values) > 0 {
+			return attribute.Values[0]
+		}
+	}
+	return ""
+}
+
+func (registry *ldapUserRegistry) Authenticate(id string, pwd []byte) (*RegistryUser, error) {
+	// Directories accept binds without password as unauthenticated
+	if len(pwd) == 0 {
+		return nil, errors.New("Empty password")
+	}
+
+	conn, err := registry.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entry, err := registry.findUser(conn, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Bind(entry.DN, string(pwd)); err != nil {
+		return nil, err
+	}
+
+	user := &RegistryUser{ID: id, Affiliation: ldapAttribute(entry, registry.affiliationAttribute), Role: pb.Role_CLIENT}
+	if registry.roleAttribute != "" {
+		if value := ldapAttribute(entry, registry.roleAttribute); value != "" {
+			role, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid role [%s] for user %s", value, id)
+			}
+			if _, err := MemberRoleToString(pb.Role(role)); err != nil {
+				return nil, err
+			}
+			user.Role = pb.Role(role)
+		}
+	}
+	if registry.metadataAttribute != "" {
+		user.Metadata = ldapAttribute(entry, registry.metadataAttribute)
+	}
+
+	return user, nil
+}
+
+func (registry *ldapUserRegistry) ReadAttributes(id, affiliation string) ([]*AttributePair, error) {
+	conn, err := registry.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entry, err := registry.findUser(conn, id)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := make([]*AttributePair, 0)
+	if ldapAttribute(entry, registry.affiliationAttribute) != affiliation {
+		return attributes, nil
+	}
+
+	var names []string
+	for name := range registry.attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	owner := &AttributeOwner{id, affiliation}
+	for _, name := range names {
+		if value := ldapAttribute(entry, registry.attributes[name]); value != "" {
+			attributes = append(attributes, &AttributePair{owner: owner, attributeName: name, attributeValue: []byte(value)})
+		}
+	}
+
+	return attributes, nil
+}
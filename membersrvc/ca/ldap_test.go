@@ -0,0 +1,376 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/viper"
+	"gopkg.in/asn1-ber.v1"
+	"gopkg.in/ldap.v2"
+)
+
+const (
+	ldapTestAdminDN  = "cn=admin,dc=example,dc=com"
+	ldapTestAdminPwd = "adminpw"
+	ldapTestBaseDN   = "ou=users,dc=example,dc=com"
+)
+
+type ldapTestEntry struct {
+	dn         string
+	pwd        string
+	attributes map[string]string
+}
+
+// ldapTestDirectory is an in-process stand-in for an LDAP server. It serves simple binds,
+// and equality searches to the admin.
+type ldapTestDirectory struct {
+	listener net.Listener
+	entries  []*ldapTestEntry
+}
+
+func newLDAPTestDirectory(t *testing.T) *ldapTestDirectory {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed listening [%s]", err)
+	}
+
+	directory := &ldapTestDirectory{
+		listener: listener,
+		entries: []*ldapTestEntry{
+			{dn: ldapTestAdminDN, pwd: ldapTestAdminPwd, attributes: map[string]string{"cn": "admin"}},
+			{dn: "uid=ldapUser," + ldapTestBaseDN, pwd: "ldapUserPwd", attributes: map[string]string{
+				"uid": "ldapUser", "ou": "institution_a", "o": "ACompany", "title": "Software Engineer"}},
+			{dn: "uid=ldapPeer," + ldapTestBaseDN, pwd: "ldapPeerPwd", attributes: map[string]string{
+				"uid": "ldapPeer", "ou": "institution_a", "fabricRole": "2", "fabricMetadata": `{"registrar":{"roles":["client"]}}`}},
+			{dn: "uid=ldapTwin,ou=a," + ldapTestBaseDN, pwd: "ldapTwinPwd", attributes: map[string]string{"uid": "ldapTwin"}},
+			{dn: "uid=ldapTwin,ou=b," + ldapTestBaseDN, pwd: "ldapTwinPwd", attributes: map[string]string{"uid": "ldapTwin"}},
+			{dn: "uid=ldapLocalValidator," + ldapTestBaseDN, pwd: "ldapLocalValidatorPwd", attributes: map[string]string{
+				"uid": "ldapLocalValidator", "ou": "institution_a"}},
+		},
+	}
+	go directory.serve()
+
+	return directory
+}
+
+func (directory *ldapTestDirectory) registry() *ldapUserRegistry {
+	return &ldapUserRegistry{
+		address:              directory.listener.Addr().String(),
+		timeout:              5 * time.Second,
+		bindDN:               ldapTestAdminDN,
+		bindPassword:         ldapTestAdminPwd,
+		baseDN:               ldapTestBaseDN,
+		uidAttribute:         "uid",
+		affiliationAttribute: "ou",
+		roleAttribute:        "fabricRole",
+		metadataAttribute:    "fabricMetadata",
+		attributes:           map[string]string{"company": "o", "position": "title"},
+	}
+}
+
+func (directory *ldapTestDirectory) close() {
+	directory.listener.Close()
+}
+
+func (directory *ldapTestDirectory) serve() {
+	for {
+		conn, err := directory.listener.Accept()
+		if err != nil {
+			return
+		}
+		go directory.handle(conn)
+	}
+}
+
+func (directory *ldapTestDirectory) handle(conn net.Conn) {
+	defer conn.Close()
+
+	bound := ""
+	for {
+		msg, err := ber.ReadPacket(conn)
+		if err != nil || len(msg.Children) < 2 {
+			return
+		}
+		op := msg.Children[1]
+		reply := func(response *ber.Packet) {
+			packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+			packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, msg.Children[0].Value, ""))
+			packet.AppendChild(response)
+			conn.Write(packet.Bytes())
+		}
+		result := func(tag ber.Tag, code int64) *ber.Packet {
+			response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+			response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, ""))
+			response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+			response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+			return response
+		}
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			if len(op.Children) != 3 {
+				return
+			}
+			dn, pwd := op.Children[1].Value.(string), op.Children[2].Data.String()
+
+			// As real directories, accept unauthenticated binds
+			bound = ""
+			code := int64(ldap.LDAPResultInvalidCredentials)
+			if pwd == "" {
+				code = ldap.LDAPResultSuccess
+			} else {
+				for _, entry := range directory.entries {
+					if entry.dn == dn && entry.pwd == pwd {
+						bound, code = dn, ldap.LDAPResultSuccess
+					}
+				}
+			}
+			reply(result(ldap.ApplicationBindResponse, code))
+
+		case ldap.ApplicationSearchRequest:
+			if len(op.Children) != 8 {
+				return
+			}
+			if bound != ldapTestAdminDN {
+				reply(result(ldap.ApplicationSearchResultDone, ldap.LDAPResultInsufficientAccessRights))
+				continue
+			}
+			filter := op.Children[6]
+			if filter.Tag != ldap.FilterEqualityMatch || len(filter.Children) != 2 {
+				return
+			}
+			baseDN := op.Children[0].Value.(string)
+			name, value := filter.Children[0].Value.(string), filter.Children[1].Value.(string)
+
+			for _, entry := range directory.entries {
+				if !strings.HasSuffix(entry.dn, baseDN) || entry.attributes[name] != value {
+					continue
+				}
+				response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+				response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn, ""))
+				attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+				for _, selected := range op.Children[7].Children {
+					for key, value := range entry.attributes {
+						if !strings.EqualFold(key, selected.Value.(string)) {
+							continue
+						}
+						attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+						attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, key, ""))
+						values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+						values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, ""))
+						attribute.AppendChild(values)
+						attributes.AppendChild(attribute)
+					}
+				}
+				response.AppendChild(attributes)
+				reply(response)
+			}
+			reply(result(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+
+		default:
+			return
+		}
+	}
+}
+
+func TestLDAPUserRegistryAuthenticate(t *testing.T) {
+	directory := newLDAPTestDirectory(t)
+	defer directory.close()
+	registry := directory.registry()
+
+	user, err := registry.Authenticate("ldapUser", []byte("ldapUserPwd"))
+	if err != nil {
+		t.Fatalf("Failed authenticating [%s]", err)
+	}
+	if user.ID != "ldapUser" || user.Affiliation != "institution_a" || user.Role != pb.Role_CLIENT || user.Metadata != "" {
+		t.Fatalf("Unexpected user [%+v]", user)
+	}
+
+	user, err = registry.Authenticate("ldapPeer", []byte("ldapPeerPwd"))
+	if err != nil {
+		t.Fatalf("Failed authenticating [%s]", err)
+	}
+	if user.Role != pb.Role_PEER || user.Metadata != `{"registrar":{"roles":["client"]}}` {
+		t.Fatalf("Unexpected user [%+v]", user)
+	}
+
+	if _, err := registry.Authenticate("ldapUser", []byte("ldapPeerPwd")); err == nil {
+		t.Fatal("Authenticating with a wrong password should fail")
+	}
+	if _, err := registry.Authenticate("ldapUser", nil); err == nil {
+		t.Fatal("Authenticating without password should fail")
+	}
+	if _, err := registry.Authenticate("unknownUser", []byte("ldapUserPwd")); err == nil {
+		t.Fatal("Authenticating an unknown user should fail")
+	}
+	if _, err := registry.Authenticate("ldapTwin", []byte("ldapTwinPwd")); err == nil {
+		t.Fatal("Authenticating a user with several entries should fail")
+	}
+
+	registry.bindPassword = "wrong"
+	if _, err := registry.Authenticate("ldapUser", []byte("ldapUserPwd")); err == nil {
+		t.Fatal("Authenticating with wrong credentials of the ECA should fail")
+	}
+}
+
+func TestLDAPUserRegistryReadAttributes(t *testing.T) {
+	directory := newLDAPTestDirectory(t)
+	defer directory.close()
+	registry := directory.registry()
+
+	attributes, err := registry.ReadAttributes("ldapUser", "institution_a")
+	if err != nil {
+		t.Fatalf("Failed reading attributes [%s]", err)
+	}
+	if len(attributes) != 2 {
+		t.Fatalf("Expected 2 attributes, got %d", len(attributes))
+	}
+	expected := map[string]string{"company": "ACompany", "position": "Software Engineer"}
+	for _, attribute := range attributes {
+		if attribute.GetID() != "ldapUser" || attribute.GetAffiliation() != "institution_a" {
+			t.Fatalf("Unexpected owner [%s][%s]", attribute.GetID(), attribute.GetAffiliation())
+		}
+		if string(attribute.GetAttributeValue()) != expected[attribute.GetAttributeName()] {
+			t.Fatalf("Unexpected value [%s] for attribute [%s]", attribute.GetAttributeValue(), attribute.GetAttributeName())
+		}
+		if !attribute.IsValidFor(time.Now()) {
+			t.Fatalf("Attribute [%s] should be valid", attribute.GetAttributeName())
+		}
+	}
+
+	// Attributes are issued within the affiliation of the user only
+	attributes, err = registry.ReadAttributes("ldapUser", "bank_a")
+	if err != nil {
+		t.Fatalf("Failed reading attributes [%s]", err)
+	}
+	if len(attributes) != 0 {
+		t.Fatalf("Expected no attributes, got %d", len(attributes))
+	}
+}
+
+func TestNewUserRegistry(t *testing.T) {
+	defer viper.Set("eca.registry.type", viper.Get("eca.registry.type"))
+	defer viper.Set("eca.registry.ldap.address", viper.Get("eca.registry.ldap.address"))
+	defer viper.Set("eca.registry.ldap.tls.enabled", viper.Get("eca.registry.ldap.tls.enabled"))
+
+	viper.Set("eca.registry.type", "local")
+	if registry, err := newUserRegistry(); registry != nil || err != nil {
+		t.Fatalf("Expected no registry, got [%v][%v]", registry, err)
+	}
+
+	viper.Set("eca.registry.type", "unknown")
+	if _, err := newUserRegistry(); err == nil {
+		t.Fatal("Creating an unknown registry should fail")
+	}
+
+	viper.Set("eca.registry.type", "ldap")
+	viper.Set("eca.registry.ldap.address", "")
+	if _, err := newUserRegistry(); err == nil {
+		t.Fatal("Creating an LDAP registry without address should fail")
+	}
+	viper.Set("eca.registry.ldap.address", "localhost:389")
+	registry, err := newUserRegistry()
+	if err != nil {
+		t.Fatalf("Failed creating LDAP registry [%s]", err)
+	}
+	if registry.(*ldapUserRegistry).uidAttribute != "uid" {
+		t.Fatal("The uid attribute should default to uid")
+	}
+	if !registry.(*ldapUserRegistry).tls {
+		t.Fatal("TLS should be enabled unless disabled by the configuration")
+	}
+	viper.Set("eca.registry.ldap.tls.enabled", false)
+	if registry, err := newUserRegistry(); err != nil || registry.(*ldapUserRegistry).tls {
+		t.Fatalf("TLS should be disabled by the configuration [%v]", err)
+	}
+
+	if err := RegisterUserRegistry("ldap", newLDAPUserRegistryFromConfig); err == nil {
+		t.Fatal("Registering a registry twice should fail")
+	}
+}
+
+func TestCreateCertificatePairRegistryUser(t *testing.T) {
+	directory := newLDAPTestDirectory(t)
+	defer directory.close()
+
+	eca.registry = directory.registry()
+	defer func() { eca.registry = nil }()
+
+	// Unknown to the ECA, the user enrolls with its directory password
+	if err := enrollUser(&User{enrollID: "ldapUser", enrollPwd: []byte("ldapUserPwd")}); err != nil {
+		t.Fatalf("Failed enrolling registry user [%s]", err)
+	}
+	var role int
+	var enrollID string
+	if err := eca.db.QueryRow("SELECT role, enrollmentId FROM Users WHERE id=?", "ldapUser").Scan(&role, &enrollID); err != nil {
+		t.Fatalf("Registry user not registered with the ECA [%s]", err)
+	}
+	if role != int(pb.Role_CLIENT) || enrollID != "ldapUser\\institution_a" {
+		t.Fatalf("Unexpected registration [%d][%s]", role, enrollID)
+	}
+
+	// Wrong passwords and users unknown to the registry are rejected
+	if err := enrollUser(&User{enrollID: "ldapUser", enrollPwd: []byte("wrongPwd")}); err == nil {
+		t.Fatal("Enrolling with a wrong password should fail")
+	}
+	if err := enrollUser(&User{enrollID: "unknownUser", enrollPwd: []byte("ldapUserPwd")}); err == nil {
+		t.Fatal("Enrolling a user unknown to the registry should fail")
+	}
+
+	// The ACA issues the attributes of the directory
+	aca.registry = eca.registry
+	defer func() { aca.registry = nil }()
+
+	attributes, err := aca.fetchAttributes("ldapUser", "institution_a")
+	if err != nil {
+		t.Fatalf("Failed fetching attributes [%s]", err)
+	}
+	if len(attributes) != 2 {
+		t.Fatalf("Expected 2 attributes, got %d", len(attributes))
+	}
+}
+
+func TestCreateCertificatePairLocalUserInRegistry(t *testing.T) {
+	directory := newLDAPTestDirectory(t)
+	defer directory.close()
+
+	// The validator is registered with the ECA, the directory holds an entry of the same id
+	validator := User{enrollID: "ldapLocalValidator", role: int(pb.Role_VALIDATOR), affiliation: "institution_a"}
+	if err := registerUser(testAdmin, &validator); err != nil {
+		t.Fatalf("Failed registering user [%s]", err)
+	}
+
+	eca.registry = directory.registry()
+	defer func() { eca.registry = nil }()
+
+	// Local users never enroll with a directory password
+	if err := enrollUser(&User{enrollID: validator.enrollID, enrollPwd: []byte("ldapLocalValidatorPwd")}); err == nil {
+		t.Fatal("Local users should not enroll with the password of the registry")
+	}
+	if fromRegistry, err := eca.isRegistryUser(validator.enrollID); err != nil || fromRegistry {
+		t.Fatalf("Local user marked as registry user [%v][%v]", fromRegistry, err)
+	}
+
+	if err := enrollUser(&validator); err != nil {
+		t.Fatalf("Failed enrolling local user [%s]", err)
+	}
+}
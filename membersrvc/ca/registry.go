@@ -0,0 +1,93 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"fmt"
+	"sync"
+
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/viper"
+)
+
+// RegistryUser is the entry of a user in a user registry.
+//
+type RegistryUser struct {
+	ID          string
+	Affiliation string
+	Role        pb.Role
+	Metadata    string
+}
+
+// UserRegistry is an external directory of users. The ECA enrolls the users of the
+// registry with their directory password, and the ACA issues the attributes the registry
+// holds for them.
+//
+type UserRegistry interface {
+	// Authenticate checks the password of the user and returns its entry.
+	Authenticate(id string, pwd []byte) (*RegistryUser, error)
+
+	// ReadAttributes returns the attributes of the user within the affiliation.
+	ReadAttributes(id, affiliation string) ([]*AttributePair, error)
+}
+
+// UserRegistryFactory creates a registry from the eca.registry properties.
+//
+type UserRegistryFactory func() (UserRegistry, error)
+
+// localUserRegistry selects the users registered with the ECA only
+const localUserRegistry = "local"
+
+var (
+	userRegistriesMutex sync.Mutex
+	userRegistries      = make(map[string]UserRegistryFactory)
+)
+
+// RegisterUserRegistry makes a registry available under name to the eca.registry.type
+// property.
+//
+func RegisterUserRegistry(name string, factory UserRegistryFactory) error {
+	userRegistriesMutex.Lock()
+	defer userRegistriesMutex.Unlock()
+
+	if _, ok := userRegistries[name]; ok || name == localUserRegistry {
+		return fmt.Errorf("User registry [%s] already registered", name)
+	}
+	userRegistries[name] = factory
+
+	return nil
+}
+
+// newUserRegistry returns the registry selected by the eca.registry.type property, nil if
+// the users are the ones registered with the ECA only.
+//
+func newUserRegistry() (UserRegistry, error) {
+	name := viper.GetString("eca.registry.type")
+	if name == "" || name == localUserRegistry {
+		return nil, nil
+	}
+
+	userRegistriesMutex.Lock()
+	factory, ok := userRegistries[name]
+	userRegistriesMutex.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("Unknown user registry [%s]", name)
+	}
+
+	Info.Printf("Using user registry [%s]\n", name)
+	return factory()
+}
@@ -25,10 +25,13 @@ import (
 	"encoding/base64"
 	"errors"
 	"io/ioutil"
+	"math/big"
 
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	pb "github.com/hyperledger/fabric/membersrvc/protos"
 	"google.golang.org/grpc"
+
+	"google/protobuf"
 )
 
 var (
@@ -66,24 +69,33 @@ type TCertSet struct {
 	Key          []byte
 }
 
-func initializeTCATables(db *sql.DB) error {
-	var err error
+var tcaMigrations = []migration{
+	{1, []string{
+		"CREATE TABLE IF NOT EXISTS TCertificateSets (row INTEGER PRIMARY KEY, enrollmentID VARCHAR(64), timestamp INTEGER, nonce BLOB, kdfkey BLOB)",
+		"CREATE TABLE IF NOT EXISTS TCertificates (row INTEGER PRIMARY KEY, enrollmentID VARCHAR(64), timestamp INTEGER, serial VARCHAR(64))",
+	}},
+	{2, []string{
+		"CREATE INDEX TCertificatesByEnrollmentID ON TCertificates (enrollmentID, timestamp)",
+		"CREATE INDEX TCertificatesBySerial ON TCertificates (serial)",
+	}},
+}
 
-	err = initializeCommonTables(db)
+func initializeTCATables(db *sql.DB, dialect *sqlDialect) error {
+	err := initializeCommonTables(db, dialect)
 	if err != nil {
 		return err
 	}
 
-	if _, err = db.Exec("CREATE TABLE IF NOT EXISTS TCertificateSets (row INTEGER PRIMARY KEY, enrollmentID VARCHAR(64), timestamp INTEGER, nonce BLOB, kdfkey BLOB)"); err != nil {
-		return err
-	}
-
-	return err
+	return migrate(db, dialect, "tca", tcaMigrations)
 }
 
 // NewTCA sets up a new TCA.
 func NewTCA(eca *ECA) *TCA {
 	tca := &TCA{NewCA("tca", initializeTCATables), eca, nil, nil, nil, nil}
+	// The ECA revokes the TCerts of the members whose enrollment certificates it revokes
+	if eca != nil {
+		eca.tca = tca
+	}
 
 	err := tca.readHmacKey()
 	if err != nil {
@@ -271,18 +283,154 @@ func (tca *TCA) getCertificateSets(enrollmentID string) ([]*TCertSet, error) {
 	return sets, nil
 }
 
-func (tca *TCA) persistCertificateSet(enrollmentID string, timestamp int64, nonce []byte, kdfKey []byte) error {
+func (tca *TCA) persistCertificateSet(enrollmentID string, timestamp int64, nonce []byte, kdfKey []byte, serials []*big.Int) error {
 	mutex.Lock()
 	defer mutex.Unlock()
 
-	var err error
+	tx, err := tca.db.Begin()
+	if err != nil {
+		return err
+	}
 
-	if _, err = tca.db.Exec("INSERT INTO TCertificateSets (enrollmentID, timestamp, nonce, kdfkey) VALUES (?, ?, ?, ?)", enrollmentID, timestamp, nonce, kdfKey); err != nil {
+	if _, err = tx.Exec(tca.rebind("INSERT INTO TCertificateSets (enrollmentID, timestamp, nonce, kdfkey) VALUES (?, ?, ?, ?)"), enrollmentID, timestamp, nonce, kdfKey); err != nil {
 		Error.Println(err)
+		tx.Rollback()
+		return err
 	}
-	return err
+
+	// The serial numbers of the TCerts are kept so that the set can be revoked later on
+	for _, serial := range serials {
+		if _, err = tx.Exec(tca.rebind("INSERT INTO TCertificates (enrollmentID, timestamp, serial) VALUES (?, ?, ?)"), enrollmentID, timestamp, serial.String()); err != nil {
+			Error.Println(err)
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// readTCertOwner returns the enrollment ID a TCert has been issued to.
+func (tca *TCA) readTCertOwner(serial *big.Int) (string, error) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	var enrollmentID string
+	err := tca.db.QueryRow(tca.rebind("SELECT enrollmentID FROM TCertificates WHERE serial=?"), serial.String()).Scan(&enrollmentID)
+
+	return enrollmentID, err
+}
+
+// readTCertSerials returns the serial numbers of the TCerts in the set issued to enrollmentID at timestamp.
+// If timestamp is 0 the latest set is used.
+func (tca *TCA) readTCertSerials(enrollmentID string, timestamp int64) ([]*big.Int, error) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if timestamp == 0 {
+		if err := tca.db.QueryRow(tca.rebind("SELECT max(timestamp) FROM TCertificates WHERE enrollmentID=?"), enrollmentID).Scan(&timestamp); err != nil {
+			return nil, errors.New("No certificate sets for " + enrollmentID + " were found.")
+		}
+	}
+
+	rows, err := tca.db.Query(tca.rebind("SELECT serial FROM TCertificates WHERE enrollmentID=? AND timestamp=?"), enrollmentID, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	serials, err := scanTCertSerials(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(serials) == 0 {
+		return nil, errors.New("No certificate sets for " + enrollmentID + " were found.")
+	}
+
+	return serials, nil
+}
+
+// readAllTCertSerials returns the serial numbers of all the TCerts issued to enrollmentID.
+func (tca *TCA) readAllTCertSerials(enrollmentID string) ([]*big.Int, error) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	rows, err := tca.db.Query(tca.rebind("SELECT serial FROM TCertificates WHERE enrollmentID=?"), enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+	return scanTCertSerials(rows)
+}
+
+// scanTCertSerials reads the serial numbers from rows and closes them.
+func scanTCertSerials(rows *sql.Rows) ([]*big.Int, error) {
+	defer rows.Close()
+
+	var serials []*big.Int
+	for rows.Next() {
+		var serial string
+		if err := rows.Scan(&serial); err != nil {
+			return nil, err
+		}
+		serialNumber, ok := new(big.Int).SetString(serial, 10)
+		if !ok {
+			return nil, errors.New("Invalid serial number " + serial)
+		}
+		serials = append(serials, serialNumber)
+	}
+
+	return serials, rows.Err()
+}
+
+// readTCert parses a TCert issued by the TCA and returns it along with its owner.
+func (tca *TCA) readTCert(in *pb.Cert) (*x509.Certificate, string, error) {
+	if in == nil || len(in.Cert) == 0 {
+		return nil, "", errors.New("no certificate was specified")
+	}
+
+	cert, err := x509.ParseCertificate(in.Cert)
+	if err != nil {
+		return nil, "", err
+	}
+	if err = cert.CheckSignatureFrom(tca.cert); err != nil {
+		return nil, "", errors.New("certificate was not issued by this TCA")
+	}
+
+	owner, err := tca.readTCertOwner(cert.SerialNumber)
+	if err != nil {
+		return nil, "", errors.New("unknown certificate")
+	}
+
+	return cert, owner, nil
+}
+
+// revokeCertificates revokes all the TCerts issued to enrollmentID.
+func (tca *TCA) revokeCertificates(enrollmentID string) error {
+	serials, err := tca.readAllTCertSerials(enrollmentID)
+	if err != nil {
+		return err
+	}
+	if len(serials) == 0 {
+		return nil
+	}
+
+	return tca.revokeSerialNumbers(enrollmentID, serials)
+}
+
+// revokeCertificateSet revokes all the TCerts in the set issued to enrollmentID at ts.
+// If ts is not specified the latest set is revoked.
+func (tca *TCA) revokeCertificateSet(enrollmentID string, ts *google_protobuf.Timestamp) error {
+	var timestamp int64
+	if ts != nil {
+		timestamp = ts.Seconds
+	}
+
+	serials, err := tca.readTCertSerials(enrollmentID, timestamp)
+	if err != nil {
+		return err
+	}
+
+	return tca.revokeSerialNumbers(enrollmentID, serials)
 }
 
 func (tca *TCA) retrieveCertificateSets(enrollmentID string) (*sql.Rows, error) {
-	return tca.db.Query("SELECT enrollmentID, timestamp, nonce, kdfkey FROM TCertificateSets WHERE enrollmentID=?", enrollmentID)
+	return tca.db.Query(tca.rebind("SELECT enrollmentID, timestamp, nonce, kdfkey FROM TCertificateSets WHERE enrollmentID=?"), enrollmentID)
 }
@@ -120,6 +120,141 @@ func TestCreateCertificateSet(t *testing.T) {
 	}
 }
 
+var testTCertUser = User{enrollID: "testTCertUser", role: 1, affiliation: "institution_a"}
+
+func TestRevokeTCert(t *testing.T) {
+	tcap := &TCAP{tca}
+	tcaa := &TCAA{tca}
+
+	if err := registerUser(testAdmin, &testTCertUser); err != nil {
+		t.Fatalf("Failed to register user: [%s]", err.Error())
+	}
+	if err := enrollUser(&testTCertUser); err != nil {
+		t.Fatalf("Failed to enroll user: [%s]", err.Error())
+	}
+
+	certificateSetRequest, err := buildCertificateSetRequest(testTCertUser.enrollID, testTCertUser.enrollPrivKey, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := tcap.CreateCertificateSet(context.Background(), certificateSetRequest)
+	if err != nil {
+		t.Fatalf("Failed to create certificate set: [%s]", err.Error())
+	}
+	tcerts := resp.Certs.Certs
+
+	// A member cannot revoke TCerts of other members
+	req := &protos.TCertRevokeReq{Id: &protos.Identity{Id: testUser.enrollID}, Cert: &protos.Cert{Cert: tcerts[0].Cert}}
+	req.Sig = signRequest(t, &testUser, req)
+
+	if _, err = tcap.RevokeCertificate(context.Background(), req); err == nil {
+		t.Fatal("Only the owner should be able to revoke a TCert")
+	}
+
+	req = &protos.TCertRevokeReq{Id: &protos.Identity{Id: testTCertUser.enrollID}, Cert: &protos.Cert{Cert: tcerts[0].Cert}}
+	req.Sig = signRequest(t, &testTCertUser, req)
+
+	if _, err = tcap.RevokeCertificate(context.Background(), req); err != nil {
+		t.Fatalf("Failed to revoke TCert: [%s]", err.Error())
+	}
+
+	// A member who is not a registrar cannot revoke TCerts through the TCAA
+	req = &protos.TCertRevokeReq{Id: &protos.Identity{Id: testUser.enrollID}, Cert: &protos.Cert{Cert: tcerts[1].Cert}}
+	req.Sig = signRequest(t, &testUser, req)
+
+	if _, err = tcaa.RevokeCertificate(context.Background(), req); err == nil {
+		t.Fatal("Only registrars should be able to revoke TCerts of other members")
+	}
+
+	req = &protos.TCertRevokeReq{Id: &protos.Identity{Id: testAdmin.enrollID}, Cert: &protos.Cert{Cert: tcerts[1].Cert}}
+	req.Sig = signRequest(t, &testAdmin, req)
+
+	if _, err = tcaa.RevokeCertificate(context.Background(), req); err != nil {
+		t.Fatalf("Failed to revoke TCert: [%s]", err.Error())
+	}
+
+	crlReq := &protos.TCertCRLReq{Id: &protos.Identity{Id: testAdmin.enrollID}}
+	crlReq.Sig = signRequest(t, &testAdmin, crlReq)
+
+	if _, err = tcaa.PublishCRL(context.Background(), crlReq); err != nil {
+		t.Fatalf("Failed to publish CRL: [%s]", err.Error())
+	}
+
+	crlResp, err := tcap.ReadCRL(context.Background(), &protos.Empty{})
+	if err != nil {
+		t.Fatalf("Failed to read CRL: [%s]", err.Error())
+	}
+	crl, err := x509.ParseCRL(crlResp.Crl)
+	if err != nil {
+		t.Fatalf("Failed to parse CRL: [%s]", err.Error())
+	}
+	if err = tca.cert.CheckCRLSignature(crl); err != nil {
+		t.Fatalf("Failed to verify CRL signature: [%s]", err.Error())
+	}
+
+	for _, tcert := range tcerts {
+		cert, err := x509.ParseCertificate(tcert.Cert)
+		if err != nil {
+			t.Fatalf("Failed to parse certificate: [%s]", err.Error())
+		}
+
+		found := false
+		for _, revoked := range crl.TBSCertList.RevokedCertificates {
+			if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("Revoked TCert %v is missing from the CRL", cert.SerialNumber)
+		}
+	}
+}
+
+var testTCertRevokedUser = User{enrollID: "testTCertRevokedUser", role: 1, affiliation: "institution_a"}
+
+func TestRevokeECertRevokesTCerts(t *testing.T) {
+	ecaa := &ECAA{eca}
+	tcap := &TCAP{tca}
+
+	if err := registerUser(testAdmin, &testTCertRevokedUser); err != nil {
+		t.Fatalf("Failed to register user: [%s]", err.Error())
+	}
+	if err := enrollUser(&testTCertRevokedUser); err != nil {
+		t.Fatalf("Failed to enroll user: [%s]", err.Error())
+	}
+
+	certificateSetRequest, err := buildCertificateSetRequest(testTCertRevokedUser.enrollID, testTCertRevokedUser.enrollPrivKey, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := tcap.CreateCertificateSet(context.Background(), certificateSetRequest)
+	if err != nil {
+		t.Fatalf("Failed to create certificate set: [%s]", err.Error())
+	}
+
+	raw, err := eca.readCertificateByKeyUsage(testTCertRevokedUser.enrollID, x509.KeyUsageDigitalSignature)
+	if err != nil {
+		t.Fatalf("Failed to read certificate: [%s]", err.Error())
+	}
+	req := &protos.ECertRevokeReq{Id: &protos.Identity{Id: testAdmin.enrollID}, Cert: &protos.Cert{Cert: raw}}
+	req.Sig = signRequest(t, &testAdmin, req)
+
+	if _, err = ecaa.RevokeCertificate(context.Background(), req); err != nil {
+		t.Fatalf("Failed to revoke certificate: [%s]", err.Error())
+	}
+
+	// The TCerts derived from the revoked enrollment key are revoked too
+	for _, tcert := range resp.Certs.Certs {
+		cert, err := x509.ParseCertificate(tcert.Cert)
+		if err != nil {
+			t.Fatalf("Failed to parse certificate: [%s]", err.Error())
+		}
+		if revoked, _ := tca.isRevoked(cert.SerialNumber); !revoked {
+			t.Fatalf("TCert %v should have been revoked with the enrollment certificate", cert.SerialNumber)
+		}
+	}
+}
+
 func loadECertAndEnrollmentPrivateKey(enrollmentID string, password string) ([]byte, *ecdsa.PrivateKey, error) {
 	cooked, err := ioutil.ReadFile("./test_resources/key_" + enrollmentID + ".dump")
 	if err != nil {
@@ -28,23 +28,88 @@ type TCAA struct {
 	tca *TCA
 }
 
-// RevokeCertificate revokes a certificate from the TCA.  Not yet implemented.
-func (tcaa *TCAA) RevokeCertificate(context.Context, *pb.TCertRevokeReq) (*pb.CAStatus, error) {
+// RevokeCertificate revokes a certificate from the TCA.  The requester must be a registrar
+// allowed to register members with the role of the certificate owner.
+func (tcaa *TCAA) RevokeCertificate(ctx context.Context, in *pb.TCertRevokeReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc TCAA:RevokeCertificate")
 
-	return nil, errors.New("not yet implemented")
+	if in.Id == nil || in.Id.Id == "" {
+		return nil, errors.New("no identity was specified")
+	}
+	admin := in.Id.Id
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := tcaa.tca.eca.verifySignature(admin, sig, in); err != nil {
+		return nil, err
+	}
+
+	cert, owner, err := tcaa.tca.readTCert(in.Cert)
+	if err != nil {
+		return nil, err
+	}
+	if err = tcaa.tca.eca.canRevoke(admin, owner); err != nil {
+		return nil, err
+	}
+
+	if err = tcaa.tca.revokeCertificate(owner, cert); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
 }
 
-// RevokeCertificateSet revokes a certificate set from the TCA.  Not yet implemented.
-func (tcaa *TCAA) RevokeCertificateSet(context.Context, *pb.TCertRevokeSetReq) (*pb.CAStatus, error) {
+// RevokeCertificateSet revokes a certificate set from the TCA.  The requester must be a registrar
+// allowed to register members with the role of the owner of the set.
+func (tcaa *TCAA) RevokeCertificateSet(ctx context.Context, in *pb.TCertRevokeSetReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc TCAA:RevokeCertificateSet")
 
-	return nil, errors.New("not yet implemented")
+	if in.Id == nil || in.Id.Id == "" {
+		return nil, errors.New("no identity was specified")
+	}
+	if in.Owner == nil || in.Owner.Id == "" {
+		return nil, errors.New("no owner was specified")
+	}
+	admin := in.Id.Id
+	owner := in.Owner.Id
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := tcaa.tca.eca.verifySignature(admin, sig, in); err != nil {
+		return nil, err
+	}
+	if err := tcaa.tca.eca.canRevoke(admin, owner); err != nil {
+		return nil, err
+	}
+
+	if err := tcaa.tca.revokeCertificateSet(owner, in.Ts); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
 }
 
-// PublishCRL requests the creation of a certificate revocation list from the TCA.  Not yet implemented.
-func (tcaa *TCAA) PublishCRL(context.Context, *pb.TCertCRLReq) (*pb.CAStatus, error) {
+// PublishCRL requests the creation of a certificate revocation list from the TCA.
+func (tcaa *TCAA) PublishCRL(ctx context.Context, in *pb.TCertCRLReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc TCAA:CreateCRL")
 
-	return nil, errors.New("not yet implemented")
+	if in.Id == nil || in.Id.Id == "" {
+		return nil, errors.New("no identity was specified")
+	}
+	admin := in.Id.Id
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := tcaa.tca.eca.verifySignature(admin, sig, in); err != nil {
+		return nil, err
+	}
+	if err := tcaa.tca.eca.isRegistrar(admin); err != nil {
+		return nil, err
+	}
+
+	if _, err := tcaa.tca.createCRL(); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
 }
@@ -177,8 +177,28 @@ func (tcap *TCAP) createCertificateSet(ctx context.Context, raw []byte, in *pb.T
 		return nil, err
 	}
 
+	// TCerts are not issued against a revoked enrollment certificate
+	revoked, err := tcap.tca.eca.isRevoked(cert.SerialNumber)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errors.New("enrollment certificate has been revoked")
+	}
+
+	// Nor against a disabled enrollment
+	if disabled, err := tcap.tca.eca.isUserDisabled(id); err == nil && disabled {
+		return nil, errors.New("enrollment has been disabled")
+	}
+
 	pub := cert.PublicKey.(*ecdsa.PublicKey)
 
+	// The owner signs and derives the keys of its TCerts with the crypto suite of its ECert
+	suite, err := getCertCryptoSuite(cert)
+	if err != nil {
+		return nil, err
+	}
+
 	r, s := big.NewInt(0), big.NewInt(0)
 	r.UnmarshalText(in.Sig.R)
 	s.UnmarshalText(in.Sig.S)
@@ -186,7 +206,7 @@ func (tcap *TCAP) createCertificateSet(ctx context.Context, raw []byte, in *pb.T
 	//sig := in.Sig
 	in.Sig = nil
 
-	hash := primitives.NewHash()
+	hash := suite.Hash()
 	raw, _ = proto.Marshal(in)
 	hash.Write(raw)
 	if ecdsa.Verify(pub, hash.Sum(nil), r, s) == false {
@@ -198,7 +218,7 @@ func (tcap *TCAP) createCertificateSet(ctx context.Context, raw []byte, in *pb.T
 	rand.Reader.Read(nonce[:8])
 	binary.LittleEndian.PutUint64(nonce[8:], uint64(in.Ts.Seconds))
 
-	mac := hmac.New(primitives.GetDefaultHash(), tcap.tca.hmacKey)
+	mac := hmac.New(suite.Hash, tcap.tca.hmacKey)
 	raw, _ = x509.MarshalPKIXPublicKey(pub)
 	mac.Write(raw)
 	kdfKey := mac.Sum(nil)
@@ -210,6 +230,7 @@ func (tcap *TCAP) createCertificateSet(ctx context.Context, raw []byte, in *pb.T
 
 	// the batch of TCerts
 	var set []*pb.TCert
+	var serials []*big.Int
 
 	for i := 0; i < num; i++ {
 		tcertid := util.GenerateIntUUID()
@@ -219,13 +240,13 @@ func (tcap *TCAP) createCertificateSet(ctx context.Context, raw []byte, in *pb.T
 		tidx = append(tidx[:], nonce[:]...)
 		tidx = append(tidx[:], Padding...)
 
-		mac := hmac.New(primitives.GetDefaultHash(), kdfKey)
+		mac := hmac.New(suite.Hash, kdfKey)
 		mac.Write([]byte{1})
 		extKey := mac.Sum(nil)[:32]
 
-		mac = hmac.New(primitives.GetDefaultHash(), kdfKey)
+		mac = hmac.New(suite.Hash, kdfKey)
 		mac.Write([]byte{2})
-		mac = hmac.New(primitives.GetDefaultHash(), mac.Sum(nil))
+		mac = hmac.New(suite.Hash, mac.Sum(nil))
 		mac.Write(tidx)
 
 		one := new(big.Int).SetInt64(1)
@@ -250,15 +271,19 @@ func (tcap *TCAP) createCertificateSet(ctx context.Context, raw []byte, in *pb.T
 		}
 
 		spec := NewDefaultPeriodCertificateSpecWithCommonName(id, TCERT_SUBJECT_COMMON_NAME_VALUE, tcertid, &txPub, x509.KeyUsageDigitalSignature, extensions...)
+		spec.SetCryptoSuite(suite)
 		if raw, err = tcap.tca.createCertificateFromSpec(spec, timestamp, kdfKey, false); err != nil {
 			Error.Println(err)
 			return nil, err
 		}
 
 		set = append(set, &pb.TCert{Cert: raw, Prek0: preK0})
+		serials = append(serials, tcertid)
 	}
 
-	tcap.tca.persistCertificateSet(id, timestamp, nonce, kdfKey)
+	if err = tcap.tca.persistCertificateSet(id, timestamp, nonce, kdfKey, serials); err != nil {
+		return nil, err
+	}
 
 	return &pb.TCertCreateSetResp{Certs: &pb.CertSet{Ts: in.Ts, Id: in.Id, Key: kdfKey, Certs: set}}, nil
 }
@@ -341,18 +366,72 @@ func (tcap *TCAP) generateExtensions(tcertid *big.Int, tidx []byte, enrollmentCe
 	return extensions, preK0, nil
 }
 
-// RevokeCertificate revokes a certificate from the TCA.  Not yet implemented.
-func (tcap *TCAP) RevokeCertificate(context.Context, *pb.TCertRevokeReq) (*pb.CAStatus, error) {
+// RevokeCertificate revokes a certificate from the TCA.  A user can only revoke his/her own TCerts.
+func (tcap *TCAP) RevokeCertificate(ctx context.Context, in *pb.TCertRevokeReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc TCAP:RevokeCertificate")
 
-	return nil, errors.New("not yet implemented")
+	if in.Id == nil || in.Id.Id == "" {
+		return nil, errors.New("no identity was specified")
+	}
+	id := in.Id.Id
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := tcap.tca.eca.verifySignature(id, sig, in); err != nil {
+		return nil, err
+	}
+
+	cert, owner, err := tcap.tca.readTCert(in.Cert)
+	if err != nil {
+		return nil, err
+	}
+	if owner != id {
+		return nil, errors.New("certificate does not belong to " + id)
+	}
+
+	if err = tcap.tca.revokeCertificate(owner, cert); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
 }
 
-// RevokeCertificateSet revokes a certificate set from the TCA.  Not yet implemented.
-func (tcap *TCAP) RevokeCertificateSet(context.Context, *pb.TCertRevokeSetReq) (*pb.CAStatus, error) {
+// RevokeCertificateSet revokes a certificate set from the TCA.  A user can only revoke his/her own TCerts.
+func (tcap *TCAP) RevokeCertificateSet(ctx context.Context, in *pb.TCertRevokeSetReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc TCAP:RevokeCertificateSet")
 
-	return nil, errors.New("not yet implemented")
+	if in.Id == nil || in.Id.Id == "" {
+		return nil, errors.New("no identity was specified")
+	}
+	id := in.Id.Id
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := tcap.tca.eca.verifySignature(id, sig, in); err != nil {
+		return nil, err
+	}
+
+	if in.Owner != nil && in.Owner.Id != "" && in.Owner.Id != id {
+		return nil, errors.New("certificates do not belong to " + id)
+	}
+
+	if err := tcap.tca.revokeCertificateSet(id, in.Ts); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
+}
+
+// ReadCRL reads the last certificate revocation list published by the TCA.
+func (tcap *TCAP) ReadCRL(ctx context.Context, in *pb.Empty) (*pb.CRL, error) {
+	Trace.Println("grpc TCAP:ReadCRL")
+
+	raw, err := tcap.tca.readCRL()
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.CRL{Crl: raw}, nil
 }
 
 func isEnabledAttributesEncryption() bool {
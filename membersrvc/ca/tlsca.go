@@ -24,7 +24,7 @@ import (
 	"math/big"
 
 	"github.com/golang/protobuf/proto"
-	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/util"
 	pb "github.com/hyperledger/fabric/membersrvc/protos"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -50,8 +50,8 @@ type TLSCAA struct {
 	tlsca *TLSCA
 }
 
-func initializeTLSCATables(db *sql.DB) error {
-	return initializeCommonTables(db)
+func initializeTLSCATables(db *sql.DB, dialect *sqlDialect) error {
+	return initializeCommonTables(db, dialect)
 }
 
 // NewTLSCA sets up a new TLSCA.
@@ -125,14 +125,21 @@ func (tlscap *TLSCAP) CreateCertificate(ctx context.Context, in *pb.TLSCertCreat
 		return nil, err
 	}
 
-	hash := primitives.NewHash()
+	suite, err := getSubjectCryptoSuite(in.CryptoSuite, pub.(*ecdsa.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+
+	hash := suite.Hash()
 	raw, _ = proto.Marshal(in)
 	hash.Write(raw)
 	if ecdsa.Verify(pub.(*ecdsa.PublicKey), hash.Sum(nil), r, s) == false {
 		return nil, errors.New("signature does not verify")
 	}
 
-	if raw, err = tlscap.tlsca.createCertificate(id, pub.(*ecdsa.PublicKey), x509.KeyUsageDigitalSignature, in.Ts.Seconds, nil); err != nil {
+	spec := NewDefaultPeriodCertificateSpec(id, util.GenerateIntUUID(), pub.(*ecdsa.PublicKey), x509.KeyUsageDigitalSignature)
+	spec.SetCryptoSuite(suite)
+	if raw, err = tlscap.tlsca.createCertificateFromSpec(spec, in.Ts.Seconds, nil, true); err != nil {
 		Error.Println(err)
 		return nil, err
 	}
@@ -153,18 +160,111 @@ func (tlscap *TLSCAP) ReadCertificate(ctx context.Context, in *pb.TLSCertReadReq
 	return &pb.Cert{Cert: raw}, nil
 }
 
-// RevokeCertificate revokes a certificate from the TLSCA.  Not yet implemented.
+// RevokeCertificate revokes a certificate from the TLSCA.  A user can only revoke his/her own
+// certificate.
 //
-func (tlscap *TLSCAP) RevokeCertificate(context.Context, *pb.TLSCertRevokeReq) (*pb.CAStatus, error) {
+func (tlscap *TLSCAP) RevokeCertificate(ctx context.Context, in *pb.TLSCertRevokeReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc TLSCAP:RevokeCertificate")
 
-	return nil, errors.New("not yet implemented")
+	if in.Id == nil || in.Id.Id == "" {
+		return nil, errors.New("no identity was specified")
+	}
+	id := in.Id.Id
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := tlscap.tlsca.eca.verifySignature(id, sig, in); err != nil {
+		return nil, err
+	}
+
+	cert, owner, err := tlscap.tlsca.readTLSCert(in.Cert)
+	if err != nil {
+		return nil, err
+	}
+	if owner != id {
+		return nil, errors.New("certificate does not belong to " + id)
+	}
+
+	if err = tlscap.tlsca.revokeTLSCertificate(owner, cert); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
 }
 
-// RevokeCertificate revokes a certificate from the TLSCA.  Not yet implemented.
+// ReadCRL reads the certificate revocation list of the TLSCA.
 //
-func (tlscaa *TLSCAA) RevokeCertificate(context.Context, *pb.TLSCertRevokeReq) (*pb.CAStatus, error) {
+func (tlscap *TLSCAP) ReadCRL(ctx context.Context, in *pb.Empty) (*pb.CRL, error) {
+	Trace.Println("grpc TLSCAP:ReadCRL")
+
+	raw, err := tlscap.tlsca.readCRL()
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.CRL{Crl: raw}, nil
+}
+
+// RevokeCertificate revokes a certificate from the TLSCA.  The requester must be a registrar
+// allowed to register members with the role of the certificate owner.
+//
+func (tlscaa *TLSCAA) RevokeCertificate(ctx context.Context, in *pb.TLSCertRevokeReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc TLSCAA:RevokeCertificate")
 
-	return nil, errors.New("not yet implemented")
+	if in.Id == nil || in.Id.Id == "" {
+		return nil, errors.New("no identity was specified")
+	}
+	admin := in.Id.Id
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := tlscaa.tlsca.eca.verifySignature(admin, sig, in); err != nil {
+		return nil, err
+	}
+
+	cert, owner, err := tlscaa.tlsca.readTLSCert(in.Cert)
+	if err != nil {
+		return nil, err
+	}
+	if err = tlscaa.tlsca.eca.canRevoke(admin, owner); err != nil {
+		return nil, err
+	}
+
+	if err = tlscaa.tlsca.revokeTLSCertificate(owner, cert); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
+}
+
+// readTLSCert parses a certificate issued by the TLSCA and returns it along with its owner.
+//
+func (tlsca *TLSCA) readTLSCert(in *pb.Cert) (*x509.Certificate, string, error) {
+	if in == nil || len(in.Cert) == 0 {
+		return nil, "", errors.New("no certificate was specified")
+	}
+
+	owner, _, err := tlsca.readCertificateOwner(in.Cert)
+	if err != nil {
+		return nil, "", errors.New("certificate was not issued by this TLSCA")
+	}
+
+	cert, err := x509.ParseCertificate(in.Cert)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return cert, owner, nil
+}
+
+// revokeTLSCertificate revokes a TLS certificate.  The TLSCA has no separate
+// publication step, so a new CRL is created right away.
+//
+func (tlsca *TLSCA) revokeTLSCertificate(owner string, cert *x509.Certificate) error {
+	if err := tlsca.revokeCertificate(owner, cert); err != nil {
+		return err
+	}
+
+	_, err := tlsca.createCRL()
+	return err
 }
@@ -62,6 +62,115 @@ func TestTLS(t *testing.T) {
 	stopTLSCA(t)
 }
 
+var testTLSUser = User{enrollID: "testTLSUser", role: 1, affiliation: "institution_a"}
+
+func createTLSCertificate(t *testing.T, tlscap *TLSCAP, user *User) []byte {
+	priv, err := primitives.NewECDSAKey()
+	if err != nil {
+		t.Fatalf("Failed generating key [%s]", err.Error())
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed marshaling key [%s]", err.Error())
+	}
+
+	req := &membersrvc.TLSCertCreateReq{
+		Ts:  &google_protobuf.Timestamp{Seconds: time.Now().Unix(), Nanos: 0},
+		Id:  &membersrvc.Identity{Id: user.enrollID},
+		Pub: &membersrvc.PublicKey{Type: membersrvc.CryptoType_ECDSA, Key: pub},
+	}
+	raw, _ := proto.Marshal(req)
+	r, s, err := primitives.ECDSASignDirect(priv, raw)
+	if err != nil {
+		t.Fatalf("Failed signing [%s]", err.Error())
+	}
+	R, _ := r.MarshalText()
+	S, _ := s.MarshalText()
+	req.Sig = &membersrvc.Signature{Type: membersrvc.CryptoType_ECDSA, R: R, S: S}
+
+	resp, err := tlscap.CreateCertificate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Failed creating TLS certificate [%s]", err.Error())
+	}
+	return resp.Cert.Cert
+}
+
+func TestRevokeTLSCertificate(t *testing.T) {
+	tlsca := NewTLSCA(eca)
+	tlscap := &TLSCAP{tlsca}
+	tlscaa := &TLSCAA{tlsca}
+
+	if err := registerUser(testAdmin, &testTLSUser); err != nil {
+		t.Fatalf("Failed to register user: [%s]", err.Error())
+	}
+	if err := enrollUser(&testTLSUser); err != nil {
+		t.Fatalf("Failed to enroll user: [%s]", err.Error())
+	}
+
+	own := createTLSCertificate(t, tlscap, &testTLSUser)
+	other := createTLSCertificate(t, tlscap, &testTLSUser)
+
+	// A member cannot revoke certificates of other members
+	req := &membersrvc.TLSCertRevokeReq{Id: &membersrvc.Identity{Id: testUser.enrollID}, Cert: &membersrvc.Cert{Cert: own}}
+	req.Sig = signRequest(t, &testUser, req)
+
+	if _, err := tlscap.RevokeCertificate(context.Background(), req); err == nil {
+		t.Fatal("Only the owner should be able to revoke a TLS certificate")
+	}
+
+	req = &membersrvc.TLSCertRevokeReq{Id: &membersrvc.Identity{Id: testTLSUser.enrollID}, Cert: &membersrvc.Cert{Cert: own}}
+	req.Sig = signRequest(t, &testTLSUser, req)
+
+	if _, err := tlscap.RevokeCertificate(context.Background(), req); err != nil {
+		t.Fatalf("Failed to revoke TLS certificate: [%s]", err.Error())
+	}
+
+	// A member who is not a registrar cannot revoke certificates through the TLSCAA
+	req = &membersrvc.TLSCertRevokeReq{Id: &membersrvc.Identity{Id: testUser.enrollID}, Cert: &membersrvc.Cert{Cert: other}}
+	req.Sig = signRequest(t, &testUser, req)
+
+	if _, err := tlscaa.RevokeCertificate(context.Background(), req); err == nil {
+		t.Fatal("Only registrars should be able to revoke TLS certificates of other members")
+	}
+
+	req = &membersrvc.TLSCertRevokeReq{Id: &membersrvc.Identity{Id: testAdmin.enrollID}, Cert: &membersrvc.Cert{Cert: other}}
+	req.Sig = signRequest(t, &testAdmin, req)
+
+	if _, err := tlscaa.RevokeCertificate(context.Background(), req); err != nil {
+		t.Fatalf("Failed to revoke TLS certificate: [%s]", err.Error())
+	}
+
+	// The CRL of the TLSCA is updated right away
+	resp, err := tlscap.ReadCRL(context.Background(), &membersrvc.Empty{})
+	if err != nil {
+		t.Fatalf("Failed to read CRL: [%s]", err.Error())
+	}
+	crl, err := x509.ParseCRL(resp.Crl)
+	if err != nil {
+		t.Fatalf("Failed to parse CRL: [%s]", err.Error())
+	}
+	if err = tlsca.cert.CheckCRLSignature(crl); err != nil {
+		t.Fatalf("Failed to verify CRL signature: [%s]", err.Error())
+	}
+
+	for _, raw := range [][]byte{own, other} {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			t.Fatalf("Failed to parse certificate: [%s]", err.Error())
+		}
+
+		found := false
+		for _, revoked := range crl.TBSCertList.RevokedCertificates {
+			if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("Revoked TLS certificate %v is missing from the CRL", cert.SerialNumber)
+		}
+	}
+}
+
 func startTLSCA(t *testing.T) {
 	LogInit(ioutil.Discard, os.Stdout, os.Stdout, os.Stderr, os.Stdout)
 	CacheConfiguration() // Cache configuration
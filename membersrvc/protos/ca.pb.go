@@ -22,6 +22,13 @@ It has these top-level messages:
 	ReadUserSetReq
 	User
 	UserSet
+	ReadUsersReq
+	UserInfo
+	UserInfoSet
+	UpdateUserReq
+	EnableUserReq
+	ResetEnrollmentSecretReq
+	AffiliationGroupReq
 	ECertCreateReq
 	ECertCreateResp
 	ECertReadReq
@@ -41,6 +48,7 @@ It has these top-level messages:
 	TLSCertReadReq
 	TLSCertRevokeReq
 	Cert
+	CRL
 	TCert
 	CertSet
 	CertSets
@@ -423,15 +431,245 @@ func (m *UserSet) GetUsers() []*User {
 	return nil
 }
 
+// User management. The requests are signed by a registrar, which can manage the users
+// it can register.
+//
+type ReadUsersReq struct {
+	Registrar   *Identity                  `protobuf:"bytes,1,opt,name=registrar" json:"registrar,omitempty"`
+	Id          string                     `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	Affiliation string                     `protobuf:"bytes,3,opt,name=affiliation" json:"affiliation,omitempty"`
+	Role        Role                       `protobuf:"varint,4,opt,name=role,enum=protos.Role" json:"role,omitempty"`
+	Sig         *Signature                 `protobuf:"bytes,5,opt,name=sig" json:"sig,omitempty"`
+	Ts          *google_protobuf.Timestamp `protobuf:"bytes,6,opt,name=ts" json:"ts,omitempty"`
+	Nonce       []byte                     `protobuf:"bytes,7,opt,name=nonce,proto3" json:"nonce,omitempty"`
+}
+
+func (m *ReadUsersReq) Reset()         { *m = ReadUsersReq{} }
+func (m *ReadUsersReq) String() string { return proto.CompactTextString(m) }
+func (*ReadUsersReq) ProtoMessage()    {}
+
+func (m *ReadUsersReq) GetRegistrar() *Identity {
+	if m != nil {
+		return m.Registrar
+	}
+	return nil
+}
+
+func (m *ReadUsersReq) GetSig() *Signature {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
+func (m *ReadUsersReq) GetTs() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Ts
+	}
+	return nil
+}
+
+type UserInfo struct {
+	Id          *Identity `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Role        Role      `protobuf:"varint,2,opt,name=role,enum=protos.Role" json:"role,omitempty"`
+	Affiliation string    `protobuf:"bytes,3,opt,name=affiliation" json:"affiliation,omitempty"`
+	Enrolled    bool      `protobuf:"varint,4,opt,name=enrolled" json:"enrolled,omitempty"`
+	Disabled    bool      `protobuf:"varint,5,opt,name=disabled" json:"disabled,omitempty"`
+}
+
+func (m *UserInfo) Reset()         { *m = UserInfo{} }
+func (m *UserInfo) String() string { return proto.CompactTextString(m) }
+func (*UserInfo) ProtoMessage()    {}
+
+func (m *UserInfo) GetId() *Identity {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+type UserInfoSet struct {
+	Users []*UserInfo `protobuf:"bytes,1,rep,name=users" json:"users,omitempty"`
+}
+
+func (m *UserInfoSet) Reset()         { *m = UserInfoSet{} }
+func (m *UserInfoSet) String() string { return proto.CompactTextString(m) }
+func (*UserInfoSet) ProtoMessage()    {}
+
+func (m *UserInfoSet) GetUsers() []*UserInfo {
+	if m != nil {
+		return m.Users
+	}
+	return nil
+}
+
+type UpdateUserReq struct {
+	Registrar   *Identity                  `protobuf:"bytes,1,opt,name=registrar" json:"registrar,omitempty"`
+	Id          *Identity                  `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	Role        Role                       `protobuf:"varint,3,opt,name=role,enum=protos.Role" json:"role,omitempty"`
+	Affiliation string                     `protobuf:"bytes,4,opt,name=affiliation" json:"affiliation,omitempty"`
+	Sig         *Signature                 `protobuf:"bytes,5,opt,name=sig" json:"sig,omitempty"`
+	Ts          *google_protobuf.Timestamp `protobuf:"bytes,6,opt,name=ts" json:"ts,omitempty"`
+	Nonce       []byte                     `protobuf:"bytes,7,opt,name=nonce,proto3" json:"nonce,omitempty"`
+}
+
+func (m *UpdateUserReq) Reset()         { *m = UpdateUserReq{} }
+func (m *UpdateUserReq) String() string { return proto.CompactTextString(m) }
+func (*UpdateUserReq) ProtoMessage()    {}
+
+func (m *UpdateUserReq) GetRegistrar() *Identity {
+	if m != nil {
+		return m.Registrar
+	}
+	return nil
+}
+
+func (m *UpdateUserReq) GetId() *Identity {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+func (m *UpdateUserReq) GetSig() *Signature {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
+func (m *UpdateUserReq) GetTs() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Ts
+	}
+	return nil
+}
+
+type EnableUserReq struct {
+	Registrar *Identity                  `protobuf:"bytes,1,opt,name=registrar" json:"registrar,omitempty"`
+	Id        *Identity                  `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	Enabled   bool                       `protobuf:"varint,3,opt,name=enabled" json:"enabled,omitempty"`
+	Sig       *Signature                 `protobuf:"bytes,4,opt,name=sig" json:"sig,omitempty"`
+	Ts        *google_protobuf.Timestamp `protobuf:"bytes,5,opt,name=ts" json:"ts,omitempty"`
+	Nonce     []byte                     `protobuf:"bytes,6,opt,name=nonce,proto3" json:"nonce,omitempty"`
+}
+
+func (m *EnableUserReq) Reset()         { *m = EnableUserReq{} }
+func (m *EnableUserReq) String() string { return proto.CompactTextString(m) }
+func (*EnableUserReq) ProtoMessage()    {}
+
+func (m *EnableUserReq) GetRegistrar() *Identity {
+	if m != nil {
+		return m.Registrar
+	}
+	return nil
+}
+
+func (m *EnableUserReq) GetId() *Identity {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+func (m *EnableUserReq) GetSig() *Signature {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
+func (m *EnableUserReq) GetTs() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Ts
+	}
+	return nil
+}
+
+type ResetEnrollmentSecretReq struct {
+	Registrar *Identity                  `protobuf:"bytes,1,opt,name=registrar" json:"registrar,omitempty"`
+	Id        *Identity                  `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	Sig       *Signature                 `protobuf:"bytes,3,opt,name=sig" json:"sig,omitempty"`
+	Ts        *google_protobuf.Timestamp `protobuf:"bytes,4,opt,name=ts" json:"ts,omitempty"`
+	Nonce     []byte                     `protobuf:"bytes,5,opt,name=nonce,proto3" json:"nonce,omitempty"`
+}
+
+func (m *ResetEnrollmentSecretReq) Reset()         { *m = ResetEnrollmentSecretReq{} }
+func (m *ResetEnrollmentSecretReq) String() string { return proto.CompactTextString(m) }
+func (*ResetEnrollmentSecretReq) ProtoMessage()    {}
+
+func (m *ResetEnrollmentSecretReq) GetRegistrar() *Identity {
+	if m != nil {
+		return m.Registrar
+	}
+	return nil
+}
+
+func (m *ResetEnrollmentSecretReq) GetId() *Identity {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+func (m *ResetEnrollmentSecretReq) GetSig() *Signature {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
+func (m *ResetEnrollmentSecretReq) GetTs() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Ts
+	}
+	return nil
+}
+
+type AffiliationGroupReq struct {
+	Registrar *Identity                  `protobuf:"bytes,1,opt,name=registrar" json:"registrar,omitempty"`
+	Name      string                     `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Parent    string                     `protobuf:"bytes,3,opt,name=parent" json:"parent,omitempty"`
+	Sig       *Signature                 `protobuf:"bytes,4,opt,name=sig" json:"sig,omitempty"`
+	Ts        *google_protobuf.Timestamp `protobuf:"bytes,5,opt,name=ts" json:"ts,omitempty"`
+	Nonce     []byte                     `protobuf:"bytes,6,opt,name=nonce,proto3" json:"nonce,omitempty"`
+}
+
+func (m *AffiliationGroupReq) Reset()         { *m = AffiliationGroupReq{} }
+func (m *AffiliationGroupReq) String() string { return proto.CompactTextString(m) }
+func (*AffiliationGroupReq) ProtoMessage()    {}
+
+func (m *AffiliationGroupReq) GetRegistrar() *Identity {
+	if m != nil {
+		return m.Registrar
+	}
+	return nil
+}
+
+func (m *AffiliationGroupReq) GetSig() *Signature {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
+func (m *AffiliationGroupReq) GetTs() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Ts
+	}
+	return nil
+}
+
 // Certificate requests.
 //
 type ECertCreateReq struct {
-	Ts   *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=ts" json:"ts,omitempty"`
-	Id   *Identity                  `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
-	Tok  *Token                     `protobuf:"bytes,3,opt,name=tok" json:"tok,omitempty"`
-	Sign *PublicKey                 `protobuf:"bytes,4,opt,name=sign" json:"sign,omitempty"`
-	Enc  *PublicKey                 `protobuf:"bytes,5,opt,name=enc" json:"enc,omitempty"`
-	Sig  *Signature                 `protobuf:"bytes,6,opt,name=sig" json:"sig,omitempty"`
+	Ts          *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=ts" json:"ts,omitempty"`
+	Id          *Identity                  `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	Tok         *Token                     `protobuf:"bytes,3,opt,name=tok" json:"tok,omitempty"`
+	Sign        *PublicKey                 `protobuf:"bytes,4,opt,name=sign" json:"sign,omitempty"`
+	Enc         *PublicKey                 `protobuf:"bytes,5,opt,name=enc" json:"enc,omitempty"`
+	Sig         *Signature                 `protobuf:"bytes,6,opt,name=sig" json:"sig,omitempty"`
+	CryptoSuite string                     `protobuf:"bytes,7,opt,name=cryptoSuite" json:"cryptoSuite,omitempty"`
 }
 
 func (m *ECertCreateReq) Reset()         { *m = ECertCreateReq{} }
@@ -778,9 +1016,10 @@ func (m *TCertRevokeReq) GetSig() *Signature {
 }
 
 type TCertRevokeSetReq struct {
-	Id  *Identity                  `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
-	Ts  *google_protobuf.Timestamp `protobuf:"bytes,2,opt,name=ts" json:"ts,omitempty"`
-	Sig *Signature                 `protobuf:"bytes,3,opt,name=sig" json:"sig,omitempty"`
+	Id    *Identity                  `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Ts    *google_protobuf.Timestamp `protobuf:"bytes,2,opt,name=ts" json:"ts,omitempty"`
+	Sig   *Signature                 `protobuf:"bytes,3,opt,name=sig" json:"sig,omitempty"`
+	Owner *Identity                  `protobuf:"bytes,4,opt,name=owner" json:"owner,omitempty"`
 }
 
 func (m *TCertRevokeSetReq) Reset()         { *m = TCertRevokeSetReq{} }
@@ -808,6 +1047,13 @@ func (m *TCertRevokeSetReq) GetSig() *Signature {
 	return nil
 }
 
+func (m *TCertRevokeSetReq) GetOwner() *Identity {
+	if m != nil {
+		return m.Owner
+	}
+	return nil
+}
+
 type TCertCRLReq struct {
 	Id  *Identity  `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
 	Sig *Signature `protobuf:"bytes,2,opt,name=sig" json:"sig,omitempty"`
@@ -832,10 +1078,11 @@ func (m *TCertCRLReq) GetSig() *Signature {
 }
 
 type TLSCertCreateReq struct {
-	Ts  *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=ts" json:"ts,omitempty"`
-	Id  *Identity                  `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
-	Pub *PublicKey                 `protobuf:"bytes,3,opt,name=pub" json:"pub,omitempty"`
-	Sig *Signature                 `protobuf:"bytes,4,opt,name=sig" json:"sig,omitempty"`
+	Ts          *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=ts" json:"ts,omitempty"`
+	Id          *Identity                  `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	Pub         *PublicKey                 `protobuf:"bytes,3,opt,name=pub" json:"pub,omitempty"`
+	Sig         *Signature                 `protobuf:"bytes,4,opt,name=sig" json:"sig,omitempty"`
+	CryptoSuite string                     `protobuf:"bytes,5,opt,name=cryptoSuite" json:"cryptoSuite,omitempty"`
 }
 
 func (m *TLSCertCreateReq) Reset()         { *m = TLSCertCreateReq{} }
@@ -949,6 +1196,16 @@ func (m *Cert) Reset()         { *m = Cert{} }
 func (m *Cert) String() string { return proto.CompactTextString(m) }
 func (*Cert) ProtoMessage()    {}
 
+// Certificate revocation list issued by either the ECA, TCA or TLSCA.
+//
+type CRL struct {
+	Crl []byte `protobuf:"bytes,1,opt,name=crl,proto3" json:"crl,omitempty"`
+}
+
+func (m *CRL) Reset()         { *m = CRL{} }
+func (m *CRL) String() string { return proto.CompactTextString(m) }
+func (*CRL) ProtoMessage()    {}
+
 // TCert
 //
 type TCert struct {
@@ -1207,6 +1464,7 @@ type ECAPClient interface {
 	ReadCertificatePair(ctx context.Context, in *ECertReadReq, opts ...grpc.CallOption) (*CertPair, error)
 	ReadCertificateByHash(ctx context.Context, in *Hash, opts ...grpc.CallOption) (*Cert, error)
 	RevokeCertificatePair(ctx context.Context, in *ECertRevokeReq, opts ...grpc.CallOption) (*CAStatus, error)
+	ReadCRL(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CRL, error)
 }
 
 type eCAPClient struct {
@@ -1262,6 +1520,15 @@ func (c *eCAPClient) RevokeCertificatePair(ctx context.Context, in *ECertRevokeR
 	return out, nil
 }
 
+func (c *eCAPClient) ReadCRL(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CRL, error) {
+	out := new(CRL)
+	err := grpc.Invoke(ctx, "/protos.ECAP/ReadCRL", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for ECAP service
 
 type ECAPServer interface {
@@ -1270,6 +1537,7 @@ type ECAPServer interface {
 	ReadCertificatePair(context.Context, *ECertReadReq) (*CertPair, error)
 	ReadCertificateByHash(context.Context, *Hash) (*Cert, error)
 	RevokeCertificatePair(context.Context, *ECertRevokeReq) (*CAStatus, error)
+	ReadCRL(context.Context, *Empty) (*CRL, error)
 }
 
 func RegisterECAPServer(s *grpc.Server, srv ECAPServer) {
@@ -1336,6 +1604,18 @@ func _ECAP_RevokeCertificatePair_Handler(srv interface{}, ctx context.Context, d
 	return out, nil
 }
 
+func _ECAP_ReadCRL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ECAPServer).ReadCRL(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _ECAP_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.ECAP",
 	HandlerType: (*ECAPServer)(nil),
@@ -1360,6 +1640,10 @@ var _ECAP_serviceDesc = grpc.ServiceDesc{
 			MethodName: "RevokeCertificatePair",
 			Handler:    _ECAP_RevokeCertificatePair_Handler,
 		},
+		{
+			MethodName: "ReadCRL",
+			Handler:    _ECAP_ReadCRL_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{},
 }
@@ -1371,6 +1655,12 @@ type ECAAClient interface {
 	ReadUserSet(ctx context.Context, in *ReadUserSetReq, opts ...grpc.CallOption) (*UserSet, error)
 	RevokeCertificate(ctx context.Context, in *ECertRevokeReq, opts ...grpc.CallOption) (*CAStatus, error)
 	PublishCRL(ctx context.Context, in *ECertCRLReq, opts ...grpc.CallOption) (*CAStatus, error)
+	ReadUsers(ctx context.Context, in *ReadUsersReq, opts ...grpc.CallOption) (*UserInfoSet, error)
+	UpdateUser(ctx context.Context, in *UpdateUserReq, opts ...grpc.CallOption) (*CAStatus, error)
+	EnableUser(ctx context.Context, in *EnableUserReq, opts ...grpc.CallOption) (*CAStatus, error)
+	ResetEnrollmentSecret(ctx context.Context, in *ResetEnrollmentSecretReq, opts ...grpc.CallOption) (*Token, error)
+	RegisterAffiliationGroup(ctx context.Context, in *AffiliationGroupReq, opts ...grpc.CallOption) (*CAStatus, error)
+	DeleteAffiliationGroup(ctx context.Context, in *AffiliationGroupReq, opts ...grpc.CallOption) (*CAStatus, error)
 }
 
 type eCAAClient struct {
@@ -1417,6 +1707,60 @@ func (c *eCAAClient) PublishCRL(ctx context.Context, in *ECertCRLReq, opts ...gr
 	return out, nil
 }
 
+func (c *eCAAClient) ReadUsers(ctx context.Context, in *ReadUsersReq, opts ...grpc.CallOption) (*UserInfoSet, error) {
+	out := new(UserInfoSet)
+	err := grpc.Invoke(ctx, "/protos.ECAA/ReadUsers", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eCAAClient) UpdateUser(ctx context.Context, in *UpdateUserReq, opts ...grpc.CallOption) (*CAStatus, error) {
+	out := new(CAStatus)
+	err := grpc.Invoke(ctx, "/protos.ECAA/UpdateUser", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eCAAClient) EnableUser(ctx context.Context, in *EnableUserReq, opts ...grpc.CallOption) (*CAStatus, error) {
+	out := new(CAStatus)
+	err := grpc.Invoke(ctx, "/protos.ECAA/EnableUser", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eCAAClient) ResetEnrollmentSecret(ctx context.Context, in *ResetEnrollmentSecretReq, opts ...grpc.CallOption) (*Token, error) {
+	out := new(Token)
+	err := grpc.Invoke(ctx, "/protos.ECAA/ResetEnrollmentSecret", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eCAAClient) RegisterAffiliationGroup(ctx context.Context, in *AffiliationGroupReq, opts ...grpc.CallOption) (*CAStatus, error) {
+	out := new(CAStatus)
+	err := grpc.Invoke(ctx, "/protos.ECAA/RegisterAffiliationGroup", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eCAAClient) DeleteAffiliationGroup(ctx context.Context, in *AffiliationGroupReq, opts ...grpc.CallOption) (*CAStatus, error) {
+	out := new(CAStatus)
+	err := grpc.Invoke(ctx, "/protos.ECAA/DeleteAffiliationGroup", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for ECAA service
 
 type ECAAServer interface {
@@ -1424,6 +1768,12 @@ type ECAAServer interface {
 	ReadUserSet(context.Context, *ReadUserSetReq) (*UserSet, error)
 	RevokeCertificate(context.Context, *ECertRevokeReq) (*CAStatus, error)
 	PublishCRL(context.Context, *ECertCRLReq) (*CAStatus, error)
+	ReadUsers(context.Context, *ReadUsersReq) (*UserInfoSet, error)
+	UpdateUser(context.Context, *UpdateUserReq) (*CAStatus, error)
+	EnableUser(context.Context, *EnableUserReq) (*CAStatus, error)
+	ResetEnrollmentSecret(context.Context, *ResetEnrollmentSecretReq) (*Token, error)
+	RegisterAffiliationGroup(context.Context, *AffiliationGroupReq) (*CAStatus, error)
+	DeleteAffiliationGroup(context.Context, *AffiliationGroupReq) (*CAStatus, error)
 }
 
 func RegisterECAAServer(s *grpc.Server, srv ECAAServer) {
@@ -1478,6 +1828,78 @@ func _ECAA_PublishCRL_Handler(srv interface{}, ctx context.Context, dec func(int
 	return out, nil
 }
 
+func _ECAA_ReadUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ReadUsersReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ECAAServer).ReadUsers(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _ECAA_UpdateUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(UpdateUserReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ECAAServer).UpdateUser(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _ECAA_EnableUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(EnableUserReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ECAAServer).EnableUser(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _ECAA_ResetEnrollmentSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ResetEnrollmentSecretReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ECAAServer).ResetEnrollmentSecret(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _ECAA_RegisterAffiliationGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(AffiliationGroupReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ECAAServer).RegisterAffiliationGroup(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _ECAA_DeleteAffiliationGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(AffiliationGroupReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ECAAServer).DeleteAffiliationGroup(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _ECAA_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.ECAA",
 	HandlerType: (*ECAAServer)(nil),
@@ -1498,6 +1920,30 @@ var _ECAA_serviceDesc = grpc.ServiceDesc{
 			MethodName: "PublishCRL",
 			Handler:    _ECAA_PublishCRL_Handler,
 		},
+		{
+			MethodName: "ReadUsers",
+			Handler:    _ECAA_ReadUsers_Handler,
+		},
+		{
+			MethodName: "UpdateUser",
+			Handler:    _ECAA_UpdateUser_Handler,
+		},
+		{
+			MethodName: "EnableUser",
+			Handler:    _ECAA_EnableUser_Handler,
+		},
+		{
+			MethodName: "ResetEnrollmentSecret",
+			Handler:    _ECAA_ResetEnrollmentSecret_Handler,
+		},
+		{
+			MethodName: "RegisterAffiliationGroup",
+			Handler:    _ECAA_RegisterAffiliationGroup_Handler,
+		},
+		{
+			MethodName: "DeleteAffiliationGroup",
+			Handler:    _ECAA_DeleteAffiliationGroup_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{},
 }
@@ -1509,6 +1955,7 @@ type TCAPClient interface {
 	CreateCertificateSet(ctx context.Context, in *TCertCreateSetReq, opts ...grpc.CallOption) (*TCertCreateSetResp, error)
 	RevokeCertificate(ctx context.Context, in *TCertRevokeReq, opts ...grpc.CallOption) (*CAStatus, error)
 	RevokeCertificateSet(ctx context.Context, in *TCertRevokeSetReq, opts ...grpc.CallOption) (*CAStatus, error)
+	ReadCRL(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CRL, error)
 }
 
 type tCAPClient struct {
@@ -1555,6 +2002,15 @@ func (c *tCAPClient) RevokeCertificateSet(ctx context.Context, in *TCertRevokeSe
 	return out, nil
 }
 
+func (c *tCAPClient) ReadCRL(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CRL, error) {
+	out := new(CRL)
+	err := grpc.Invoke(ctx, "/protos.TCAP/ReadCRL", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for TCAP service
 
 type TCAPServer interface {
@@ -1562,6 +2018,7 @@ type TCAPServer interface {
 	CreateCertificateSet(context.Context, *TCertCreateSetReq) (*TCertCreateSetResp, error)
 	RevokeCertificate(context.Context, *TCertRevokeReq) (*CAStatus, error)
 	RevokeCertificateSet(context.Context, *TCertRevokeSetReq) (*CAStatus, error)
+	ReadCRL(context.Context, *Empty) (*CRL, error)
 }
 
 func RegisterTCAPServer(s *grpc.Server, srv TCAPServer) {
@@ -1616,6 +2073,18 @@ func _TCAP_RevokeCertificateSet_Handler(srv interface{}, ctx context.Context, de
 	return out, nil
 }
 
+func _TCAP_ReadCRL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TCAPServer).ReadCRL(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _TCAP_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.TCAP",
 	HandlerType: (*TCAPServer)(nil),
@@ -1636,6 +2105,10 @@ var _TCAP_serviceDesc = grpc.ServiceDesc{
 			MethodName: "RevokeCertificateSet",
 			Handler:    _TCAP_RevokeCertificateSet_Handler,
 		},
+		{
+			MethodName: "ReadCRL",
+			Handler:    _TCAP_ReadCRL_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{},
 }
@@ -1758,6 +2231,7 @@ type TLSCAPClient interface {
 	CreateCertificate(ctx context.Context, in *TLSCertCreateReq, opts ...grpc.CallOption) (*TLSCertCreateResp, error)
 	ReadCertificate(ctx context.Context, in *TLSCertReadReq, opts ...grpc.CallOption) (*Cert, error)
 	RevokeCertificate(ctx context.Context, in *TLSCertRevokeReq, opts ...grpc.CallOption) (*CAStatus, error)
+	ReadCRL(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CRL, error)
 }
 
 type tLSCAPClient struct {
@@ -1804,6 +2278,15 @@ func (c *tLSCAPClient) RevokeCertificate(ctx context.Context, in *TLSCertRevokeR
 	return out, nil
 }
 
+func (c *tLSCAPClient) ReadCRL(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CRL, error) {
+	out := new(CRL)
+	err := grpc.Invoke(ctx, "/protos.TLSCAP/ReadCRL", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for TLSCAP service
 
 type TLSCAPServer interface {
@@ -1811,6 +2294,7 @@ type TLSCAPServer interface {
 	CreateCertificate(context.Context, *TLSCertCreateReq) (*TLSCertCreateResp, error)
 	ReadCertificate(context.Context, *TLSCertReadReq) (*Cert, error)
 	RevokeCertificate(context.Context, *TLSCertRevokeReq) (*CAStatus, error)
+	ReadCRL(context.Context, *Empty) (*CRL, error)
 }
 
 func RegisterTLSCAPServer(s *grpc.Server, srv TLSCAPServer) {
@@ -1865,6 +2349,18 @@ func _TLSCAP_RevokeCertificate_Handler(srv interface{}, ctx context.Context, dec
 	return out, nil
 }
 
+func _TLSCAP_ReadCRL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TLSCAPServer).ReadCRL(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _TLSCAP_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.TLSCAP",
 	HandlerType: (*TLSCAPServer)(nil),
@@ -1885,6 +2381,10 @@ var _TLSCAP_serviceDesc = grpc.ServiceDesc{
 			MethodName: "RevokeCertificate",
 			Handler:    _TLSCAP_RevokeCertificate_Handler,
 		},
+		{
+			MethodName: "ReadCRL",
+			Handler:    _TLSCAP_ReadCRL_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{},
 }
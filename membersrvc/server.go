@@ -122,6 +122,14 @@ func main() {
 	tca.Start(srv)
 	tlsca.Start(srv)
 
+	if address := viper.GetString("server.rest.address"); address != "" {
+		go func() {
+			if err := eca.ServeREST(address); err != nil {
+				ca.Error.Println("Fail to start CA REST Server: ", err)
+			}
+		}()
+	}
+
 	if sock, err := net.Listen("tcp", viper.GetString("server.port")); err != nil {
 		ca.Error.Println("Fail to start CA Server: ", err)
 		os.Exit(1)
@@ -36,6 +36,7 @@ import (
 
 	"golang.org/x/net/context"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/howeyc/gopass"
 	"github.com/op/go-logging"
 	"github.com/spf13/cobra"
@@ -52,6 +53,8 @@ import (
 	"github.com/hyperledger/fabric/core/chaincode"
 	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/core/ledger/genesis"
 	"github.com/hyperledger/fabric/core/peer"
 	"github.com/hyperledger/fabric/core/rest"
@@ -131,6 +134,15 @@ var nodeStatusCmd = &cobra.Command{
 	},
 }
 
+var nodeConsensusStatusCmd = &cobra.Command{
+	Use:   "consensus-status",
+	Short: "Returns the consensus status of the node.",
+	Long:  `Returns the state and metrics of the consensus plugin of the running validating node.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return consensusStatus()
+	},
+}
+
 var (
 	stopPidFile string
 )
@@ -144,6 +156,29 @@ var nodeStopCmd = &cobra.Command{
 	},
 }
 
+var (
+	backupFile        string
+	backupBlockNumber int64
+)
+
+var nodeBackupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Backs up the ledger of the node.",
+	Long:  `Writes the blocks and the state of the ledger of the stopped node to a checksummed archive.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return backup()
+	},
+}
+
+var nodeRestoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restores the ledger of the node.",
+	Long:  `Restores the ledger of the stopped node, which must not have a database yet, from an archive written by backup.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return restore()
+	},
+}
+
 var networkCmd = &cobra.Command{
 	Use:   networkFuncName,
 	Short: fmt.Sprintf("%s specific commands.", networkFuncName),
@@ -190,11 +225,25 @@ var networkListCmd = &cobra.Command{
 	},
 }
 
+var networkReconfigureCmd = &cobra.Command{
+	Use:   "reconfigure",
+	Short: "Replaces the validating peers of the network.",
+	Long:  `Replaces the validating peers of the network by the peers whose IDs are supplied as parameters. The request is signed by the logged in administrator supplied with --username. The change is ordered through consensus once a quorum of the validating peers requested it.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return networkReconfigure(args)
+	},
+}
+
 // login related variables.
 var (
 	loginPW string
 )
 
+// reconfigure related variables.
+var (
+	networkUsr string
+)
+
 // Chaincode-related variables.
 var (
 	chaincodeLang           string
@@ -206,6 +255,8 @@ var (
 	chaincodeQueryRaw       bool
 	chaincodeQueryHex       bool
 	chaincodeAttributesJSON string
+	chaincodeLimitsJSON     string
+	chaincodeTimeout        int32
 	customIDGenAlg          string
 )
 
@@ -233,6 +284,16 @@ var chaincodeDeployCmd = &cobra.Command{
 	},
 }
 
+var chaincodeUpgradeCmd = &cobra.Command{
+	Use:       "upgrade",
+	Short:     fmt.Sprintf("Upgrade the specified %s to new code, keeping its name and state.", chainFuncName),
+	Long:      fmt.Sprintf(`Upgrade the specified %s to new code, keeping its name and state.`, chainFuncName),
+	ValidArgs: []string{"1"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeUpgrade(cmd, args)
+	},
+}
+
 var chaincodeInvokeCmd = &cobra.Command{
 	Use:       "invoke",
 	Short:     fmt.Sprintf("Invoke the specified %s.", chainFuncName),
@@ -253,6 +314,26 @@ var chaincodeQueryCmd = &cobra.Command{
 	},
 }
 
+var chaincodeCheckDeterminismCmd = &cobra.Command{
+	Use:   "check-determinism <transaction UUID>",
+	Short: "Check a transaction executes the same way twice.",
+	Long: `Execute a transaction of the ledger twice against the current state of the peer, without changing it, and report
+the differences between the results and the state read and written by the two executions.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeCheckDeterminism(cmd, args)
+	},
+}
+
+var chaincodeTerminateCmd = &cobra.Command{
+	Use:       "terminate",
+	Short:     fmt.Sprintf("Terminate the specified %s.", chainFuncName),
+	Long:      fmt.Sprintf(`Terminate the specified %s, stopping it on every peer.`, chainFuncName),
+	ValidArgs: []string{"1"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeTerminate(cmd, args)
+	},
+}
+
 func main() {
 	// For environment variables.
 	viper.SetEnvPrefix(cmdRoot)
@@ -298,10 +379,17 @@ func main() {
 
 	nodeCmd.AddCommand(nodeStartCmd)
 	nodeCmd.AddCommand(nodeStatusCmd)
+	nodeCmd.AddCommand(nodeConsensusStatusCmd)
 
 	nodeStopCmd.Flags().StringVar(&stopPidFile, "stop-peer-pid-file", viper.GetString("peer.fileSystemPath"), "Location of peer pid local file, for forces kill")
 	nodeCmd.AddCommand(nodeStopCmd)
 
+	nodeBackupCmd.Flags().StringVarP(&backupFile, "file", "f", undefinedParamValue, "Path of the backup archive to write")
+	nodeBackupCmd.Flags().Int64VarP(&backupBlockNumber, "block", "b", -1, "Number of the last block to back up, defaults to the last block of the chain")
+	nodeCmd.AddCommand(nodeBackupCmd)
+	nodeRestoreCmd.Flags().StringVarP(&backupFile, "file", "f", undefinedParamValue, "Path of the backup archive to restore")
+	nodeCmd.AddCommand(nodeRestoreCmd)
+
 	mainCmd.AddCommand(versionCmd)
 	mainCmd.AddCommand(nodeCmd)
 	// Set the flags on the login command.
@@ -313,6 +401,8 @@ func main() {
 	// mainCmd.AddCommand(vmCmd)
 
 	networkCmd.AddCommand(networkListCmd)
+	networkReconfigureCmd.Flags().StringVarP(&networkUsr, "username", "u", undefinedParamValue, "Username of the administrator of the peer who signs the reconfiguration")
+	networkCmd.AddCommand(networkReconfigureCmd)
 
 	mainCmd.AddCommand(networkCmd)
 
@@ -324,12 +414,20 @@ func main() {
 	chaincodeCmd.PersistentFlags().StringVarP(&chaincodeUsr, "username", "u", undefinedParamValue, fmt.Sprintf("Username for chaincode operations when security is enabled"))
 	chaincodeCmd.PersistentFlags().StringVarP(&customIDGenAlg, "tid", "t", undefinedParamValue, fmt.Sprintf("Name of a custom ID generation algorithm (hashing and decoding) e.g. sha256base64"))
 
+	for _, cmd := range []*cobra.Command{chaincodeDeployCmd, chaincodeUpgradeCmd} {
+		cmd.Flags().StringVar(&chaincodeLimitsJSON, "limits", "{}", fmt.Sprintf("Resource limits of the %s in JSON format, e.g. {\"cpuShares\":512,\"memory\":268435456,\"maxWriteSize\":1048576,\"maxStateOps\":1000}", chainFuncName))
+		cmd.Flags().Int32Var(&chaincodeTimeout, "timeout", 0, fmt.Sprintf("Timeout in milliseconds for executing a transaction or query of the %s, 0 for the timeout of the peer", chainFuncName))
+	}
+
 	chaincodeQueryCmd.Flags().BoolVarP(&chaincodeQueryRaw, "raw", "r", false, "If true, output the query value as raw bytes, otherwise format as a printable string")
 	chaincodeQueryCmd.Flags().BoolVarP(&chaincodeQueryHex, "hex", "x", false, "If true, output the query value byte array in hexadecimal. Incompatible with --raw")
 
 	chaincodeCmd.AddCommand(chaincodeDeployCmd)
+	chaincodeCmd.AddCommand(chaincodeUpgradeCmd)
 	chaincodeCmd.AddCommand(chaincodeInvokeCmd)
 	chaincodeCmd.AddCommand(chaincodeQueryCmd)
+	chaincodeCmd.AddCommand(chaincodeTerminateCmd)
+	chaincodeCmd.AddCommand(chaincodeCheckDeterminismCmd)
 
 	mainCmd.AddCommand(chaincodeCmd)
 
@@ -369,8 +467,15 @@ func createEventHubServer() (net.Listener, *grpc.Server, error) {
 		}
 
 		grpcServer = grpc.NewServer(opts...)
-		ehServer := producer.NewEventsServer(uint(viper.GetInt("peer.validator.events.buffersize")), viper.GetInt("peer.validator.events.timeout"))
+		ehServer := producer.NewEventsServer(uint(viper.GetInt("peer.validator.events.buffersize")), viper.GetInt("peer.validator.events.timeout"), viper.GetInt("peer.validator.events.maxqueued"))
 		pb.RegisterEventsServer(grpcServer, ehServer)
+
+		//consumers can have the events of past blocks replayed from the ledger
+		ledgerPtr, err := ledger.GetLedger()
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to get the ledger: %v", err)
+		}
+		producer.SetBlockSource(ledgerPtr)
 	}
 	return lis, grpcServer, err
 }
@@ -518,7 +623,13 @@ func serve(args []string) error {
 	pb.RegisterPeerServer(grpcServer, peerServer)
 
 	// Register the Admin server
-	pb.RegisterAdminServer(grpcServer, core.NewAdminServer())
+	serverAdmin := core.NewAdminServer()
+	if peer.ValidatorEnabled() {
+		serverAdmin.SetReconfigurer(helper.GetReconfigurer())
+		serverAdmin.SetStatusReporter(helper.GetStatusReporter())
+		serverAdmin.SetSecHelper(secHelper)
+	}
+	pb.RegisterAdminServer(grpcServer, serverAdmin)
 
 	// Register Devops server
 	serverDevops := core.NewDevopsServer(peerServer)
@@ -535,7 +646,7 @@ func serve(args []string) error {
 
 	// Create and register the REST service if configured
 	if viper.GetBool("rest.enabled") {
-		go rest.StartOpenchainRESTServer(serverOpenchain, serverDevops)
+		go rest.StartOpenchainRESTServer(serverOpenchain, serverDevops, serverAdmin)
 	}
 
 	logger.Infof("Starting peer with ID=%s, network ID=%s, address=%s, rootnodes=%v, validator=%v",
@@ -609,6 +720,22 @@ func status() (err error) {
 	return nil
 }
 
+func consensusStatus() (err error) {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error trying to connect to local peer: %s", err)
+	}
+	defer clientConn.Close()
+
+	status, err := pb.NewAdminClient(clientConn).GetConsensusStatus(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		return fmt.Errorf("Error trying to get consensus status from local peer: %s", err)
+	}
+	jsonOutput, _ := json.MarshalIndent(status, "", "  ")
+	fmt.Println(string(jsonOutput))
+	return nil
+}
+
 func stop() (err error) {
 	clientConn, err := peer.NewPeerClientConnection()
 	if err != nil {
@@ -642,6 +769,90 @@ func stop() (err error) {
 	return err
 }
 
+// checkPeerStopped returns an error if the local peer is running, as the
+// ledger database can only be opened by one process
+func checkPeerStopped() error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return nil
+	}
+	defer clientConn.Close()
+	if _, err = pb.NewAdminClient(clientConn).GetStatus(context.Background(), &google_protobuf.Empty{}); err != nil {
+		return nil
+	}
+	return errors.New("The local peer is running, stop it before backing up or restoring its ledger")
+}
+
+func backup() (err error) {
+	if backupFile == undefinedParamValue {
+		return errors.New("Must supply the path of the backup archive with --file")
+	}
+	if err = checkPeerStopped(); err != nil {
+		return err
+	}
+	ledgerPtr, err := ledger.GetLedger()
+	if err != nil {
+		return fmt.Errorf("Error opening the ledger: %s", err)
+	}
+	defer db.GetDBHandle().Close()
+
+	blockNumber := uint64(backupBlockNumber)
+	if backupBlockNumber < 0 {
+		if ledgerPtr.GetBlockchainSize() == 0 {
+			return errors.New("The ledger has no blocks to back up")
+		}
+		blockNumber = ledgerPtr.GetBlockchainSize() - 1
+	}
+
+	file, err := os.Create(backupFile)
+	if err != nil {
+		return err
+	}
+	if err = ledgerPtr.Backup(file, blockNumber); err != nil {
+		file.Close()
+		os.Remove(backupFile)
+		return fmt.Errorf("Error backing up the ledger: %s", err)
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Backed up the ledger up to block %d to %s\n", blockNumber, backupFile)
+	return nil
+}
+
+func restore() (err error) {
+	if backupFile == undefinedParamValue {
+		return errors.New("Must supply the path of the backup archive with --file")
+	}
+	if err = checkPeerStopped(); err != nil {
+		return err
+	}
+	// restoring into a new database allows removing it if the restore fails
+	dbPath := filepath.Join(viper.GetString("peer.fileSystemPath"), "db")
+	if files, _ := ioutil.ReadDir(dbPath); len(files) != 0 {
+		return fmt.Errorf("The ledger database %s already exists, move it away before restoring", dbPath)
+	}
+
+	file, err := os.Open(backupFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	ledgerPtr, err := ledger.GetLedger()
+	if err != nil {
+		return fmt.Errorf("Error opening the ledger: %s", err)
+	}
+	blockNumber, err := ledgerPtr.Restore(file)
+	db.GetDBHandle().Close()
+	if err != nil {
+		os.RemoveAll(dbPath)
+		return fmt.Errorf("Error restoring the ledger: %s", err)
+	}
+	fmt.Printf("Restored the ledger up to block %d from %s\n", blockNumber, backupFile)
+	return nil
+}
+
 // login confirms the enrollmentID and secret password of the client with the
 // CA and stores the enrollment certificate and key in the Devops server.
 func networkLogin(args []string) (err error) {
@@ -823,38 +1034,10 @@ func getDevopsClient(cmd *cobra.Command) (pb.DevopsClient, error) {
 	return devopsClient, nil
 }
 
-// chaincodeDeploy deploys the chaincode. On success, the chaincode name
-// (hash) is printed to STDOUT for use by subsequent chaincode-related CLI
-// commands.
-func chaincodeDeploy(cmd *cobra.Command, args []string) (err error) {
-	if err = checkChaincodeCmdParams(cmd); err != nil {
-		return
-	}
-	devopsClient, err := getDevopsClient(cmd)
-	if err != nil {
-		err = fmt.Errorf("Error building %s: %s", chainFuncName, err)
-		return
-	}
-	// Build the spec
-	input := &pb.ChaincodeInput{}
-	if err = json.Unmarshal([]byte(chaincodeCtorJSON), &input); err != nil {
-		err = fmt.Errorf("Chaincode argument error: %s", err)
-		return
-	}
-
-	var attributes []string
-	if err = json.Unmarshal([]byte(chaincodeAttributesJSON), &attributes); err != nil {
-		err = fmt.Errorf("Chaincode argument error: %s", err)
-		return
-	}
-
-	chaincodeLang = strings.ToUpper(chaincodeLang)
-	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_Type(pb.ChaincodeSpec_Type_value[chaincodeLang]),
-		ChaincodeID: &pb.ChaincodeID{Path: chaincodePath, Name: chaincodeName}, CtorMsg: input, Attributes: attributes}
-
-	// If security is enabled, add client login token
+// setChaincodeSecureContext adds the login token of the CLI user to the spec
+// when security is enabled.
+func setChaincodeSecureContext(spec *pb.ChaincodeSpec) (err error) {
 	if core.SecurityEnabled() {
-		logger.Debug("Security is enabled. Include security context in deploy spec")
 		if chaincodeUsr == undefinedParamValue {
 			err = errors.New("Must supply username for chaincode when security is enabled")
 			return
@@ -900,12 +1083,79 @@ func chaincodeDeploy(cmd *cobra.Command, args []string) (err error) {
 		}
 	}
 
-	chaincodeDeploymentSpec, err := devopsClient.Deploy(context.Background(), spec)
+	return nil
+}
+
+func chaincodeDeploy(cmd *cobra.Command, args []string) error {
+	return chaincodeDeployOrUpgrade(cmd, args, false)
+}
+
+func chaincodeUpgrade(cmd *cobra.Command, args []string) error {
+	return chaincodeDeployOrUpgrade(cmd, args, true)
+}
+
+// chaincodeDeployOrUpgrade deploys or upgrades the chaincode. On a successful
+// deploy, the chaincode name (hash) is printed to STDOUT for use by subsequent
+// chaincode-related CLI commands. An upgrade replaces the code of the chaincode
+// given by --name with the code at --path and prints the unchanged name.
+func chaincodeDeployOrUpgrade(cmd *cobra.Command, args []string, upgrade bool) (err error) {
+	if err = checkChaincodeCmdParams(cmd); err != nil {
+		return
+	}
+
+	action := "deploy"
+	if upgrade {
+		action = "upgrade"
+		if chaincodeName == "" {
+			err = errors.New("Name not given for upgrade")
+			return
+		}
+	}
+
+	devopsClient, err := getDevopsClient(cmd)
+	if err != nil {
+		err = fmt.Errorf("Error building %s: %s", chainFuncName, err)
+		return
+	}
+	// Build the spec
+	input := &pb.ChaincodeInput{}
+	if err = json.Unmarshal([]byte(chaincodeCtorJSON), &input); err != nil {
+		err = fmt.Errorf("Chaincode argument error: %s", err)
+		return
+	}
+
+	var attributes []string
+	if err = json.Unmarshal([]byte(chaincodeAttributesJSON), &attributes); err != nil {
+		err = fmt.Errorf("Chaincode argument error: %s", err)
+		return
+	}
+
+	limits := &pb.ChaincodeLimits{}
+	if err = json.Unmarshal([]byte(chaincodeLimitsJSON), limits); err != nil {
+		err = fmt.Errorf("Chaincode limits error: %s", err)
+		return
+	}
+
+	chaincodeLang = strings.ToUpper(chaincodeLang)
+	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_Type(pb.ChaincodeSpec_Type_value[chaincodeLang]),
+		ChaincodeID: &pb.ChaincodeID{Path: chaincodePath, Name: chaincodeName}, CtorMsg: input, Attributes: attributes,
+		Timeout: chaincodeTimeout, Limits: limits}
+
+	if err = setChaincodeSecureContext(spec); err != nil {
+		return
+	}
+
+	var chaincodeDeploymentSpec *pb.ChaincodeDeploymentSpec
+	if upgrade {
+		chaincodeDeploymentSpec, err = devopsClient.Upgrade(context.Background(), spec)
+	} else {
+		chaincodeDeploymentSpec, err = devopsClient.Deploy(context.Background(), spec)
+	}
 	if err != nil {
 		err = fmt.Errorf("Error building %s: %s\n", chainFuncName, err)
 		return
 	}
-	logger.Infof("Deploy result: %s", chaincodeDeploymentSpec.ChaincodeSpec)
+	logger.Infof("%s result: %s", strings.Title(action), chaincodeDeploymentSpec.ChaincodeSpec)
 	fmt.Println(chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name)
 	return nil
 }
@@ -957,51 +1207,8 @@ func chaincodeInvokeOrQuery(cmd *cobra.Command, args []string, invoke bool) (err
 	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_Type(pb.ChaincodeSpec_Type_value[chaincodeLang]),
 		ChaincodeID: &pb.ChaincodeID{Name: chaincodeName}, CtorMsg: input, Attributes: attributes}
 
-	// If security is enabled, add client login token
-	if core.SecurityEnabled() {
-		if chaincodeUsr == undefinedParamValue {
-			err = errors.New("Must supply username for chaincode when security is enabled")
-			return
-		}
-
-		// Retrieve the CLI data storage path
-		// Returns /var/openchain/production/client/
-		localStore := getCliFilePath()
-
-		// Check if the user is logged in before sending transaction
-		if _, err = os.Stat(localStore + "loginToken_" + chaincodeUsr); err == nil {
-			logger.Infof("Local user '%s' is already logged in. Retrieving login token.\n", chaincodeUsr)
-
-			// Read in the login token
-			token, err := ioutil.ReadFile(localStore + "loginToken_" + chaincodeUsr)
-			if err != nil {
-				panic(fmt.Errorf("Fatal error when reading client login token: %s\n", err))
-			}
-
-			// Add the login token to the chaincodeSpec
-			spec.SecureContext = string(token)
-
-			// If privacy is enabled, mark chaincode as confidential
-			if viper.GetBool("security.privacy") {
-				logger.Info("Set confidentiality level to CONFIDENTIAL.\n")
-				spec.ConfidentialityLevel = pb.ConfidentialityLevel_CONFIDENTIAL
-			}
-		} else {
-			// Check if the token is not there and fail
-			if os.IsNotExist(err) {
-				err = fmt.Errorf("User '%s' not logged in. Use the 'login' command to obtain a security token.", chaincodeUsr)
-				return
-			}
-			// Unexpected error
-			panic(fmt.Errorf("Fatal error when checking for client login token: %s\n", err))
-		}
-	} else {
-		if chaincodeUsr != undefinedParamValue {
-			logger.Warning("Username supplied but security is disabled.")
-		}
-		if viper.GetBool("security.privacy") {
-			panic(errors.New("Privacy cannot be enabled as requested because security is disabled"))
-		}
+	if err = setChaincodeSecureContext(spec); err != nil {
+		return
 	}
 
 	// Build the ChaincodeInvocationSpec message
@@ -1050,6 +1257,73 @@ func chaincodeInvokeOrQuery(cmd *cobra.Command, args []string, invoke bool) (err
 	return nil
 }
 
+// chaincodeTerminate terminates the chaincode given by --name. If successful,
+// the transaction ID is printed on STDOUT.
+func chaincodeTerminate(cmd *cobra.Command, args []string) (err error) {
+	if chaincodeName == "" {
+		err = errors.New("Name not given for terminate")
+		return
+	}
+
+	devopsClient, err := getDevopsClient(cmd)
+	if err != nil {
+		err = fmt.Errorf("Error building %s: %s", chainFuncName, err)
+		return
+	}
+
+	var attributes []string
+	if err = json.Unmarshal([]byte(chaincodeAttributesJSON), &attributes); err != nil {
+		err = fmt.Errorf("Chaincode argument error: %s", err)
+		return
+	}
+
+	chaincodeLang = strings.ToUpper(chaincodeLang)
+	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_Type(pb.ChaincodeSpec_Type_value[chaincodeLang]),
+		ChaincodeID: &pb.ChaincodeID{Name: chaincodeName}, Attributes: attributes}
+
+	if err = setChaincodeSecureContext(spec); err != nil {
+		return
+	}
+
+	invocation := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
+	resp, err := devopsClient.Terminate(context.Background(), invocation)
+	if err != nil {
+		err = fmt.Errorf("Error terminating %s: %s\n", chainFuncName, err)
+		return
+	}
+	transactionID := string(resp.Msg)
+	logger.Infof("Successfully terminated %s: %s(%s)", chainFuncName, chaincodeName, transactionID)
+	fmt.Println(transactionID)
+	return nil
+}
+
+// chaincodeCheckDeterminism prints the report of the two executions of the
+// transaction given as argument
+func chaincodeCheckDeterminism(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("Transaction UUID not given for check-determinism")
+	}
+
+	devopsClient, err := getDevopsClient(cmd)
+	if err != nil {
+		return fmt.Errorf("Error building %s: %s", chainFuncName, err)
+	}
+
+	report, err := devopsClient.CheckDeterminism(context.Background(), &pb.TransactionUUID{Uuid: args[0]})
+	if err != nil {
+		return fmt.Errorf("Error checking determinism of transaction %s: %s", args[0], err)
+	}
+	jsonOutput, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(jsonOutput))
+	if len(report.StateChanges) > 0 {
+		logger.Warningf("The state read by transaction %s changed since it was committed, it may not match the recorded execution", args[0])
+	}
+	if !report.Deterministic {
+		logger.Warningf("Transaction %s is not deterministic", args[0])
+	}
+	return nil
+}
+
 // Show a list of all existing network connections for the target peer node,
 // includes both validating and non-validating peers
 func networkList() (err error) {
@@ -1071,6 +1345,64 @@ func networkList() (err error) {
 	return nil
 }
 
+func networkReconfigure(args []string) (err error) {
+	if len(args) == 0 {
+		return errors.New("Must supply the IDs of the validating peers as parameters")
+	}
+	validatorSet := &pb.ValidatorSet{}
+	for _, id := range args {
+		validatorSet.Validators = append(validatorSet.Validators, &pb.PeerID{Name: id})
+	}
+	if err = signValidatorSet(validatorSet); err != nil {
+		return err
+	}
+
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error trying to connect to local peer: %s", err)
+	}
+	defer clientConn.Close()
+	if _, err = pb.NewAdminClient(clientConn).ReconfigureValidators(context.Background(), validatorSet); err != nil {
+		return fmt.Errorf("Error trying to reconfigure validators: %s", err)
+	}
+	fmt.Printf("Submitted reconfiguration of the validators to %v\n", args)
+	return nil
+}
+
+// signValidatorSet signs the reconfiguration request with the enrollment key
+// of the CLI user, who must be logged in
+func signValidatorSet(validatorSet *pb.ValidatorSet) error {
+	if !core.SecurityEnabled() {
+		return errors.New("The validators can only be reconfigured when security is enabled")
+	}
+	if networkUsr == undefinedParamValue {
+		return errors.New("Must supply the username of an administrator of the peer")
+	}
+	if _, err := os.Stat(getCliFilePath() + "loginToken_" + networkUsr); err != nil {
+		return fmt.Errorf("User '%s' not logged in. Use the 'login' command to obtain a security token.", networkUsr)
+	}
+
+	sec, err := crypto.InitClient(networkUsr, nil)
+	if err != nil {
+		return fmt.Errorf("Error initializing the security of user '%s': %s", networkUsr, err)
+	}
+	defer crypto.CloseClient(sec)
+	handler, err := sec.GetEnrollmentCertificateHandler()
+	if err != nil {
+		return fmt.Errorf("Error getting the enrollment certificate of user '%s': %s", networkUsr, err)
+	}
+
+	now := time.Now()
+	validatorSet.Timestamp = &google_protobuf.Timestamp{Seconds: now.Unix(), Nanos: int32(now.Nanosecond())}
+	validatorSet.Cert = handler.GetCertificate()
+	raw, err := proto.Marshal(validatorSet)
+	if err != nil {
+		return err
+	}
+	validatorSet.Signature, err = handler.Sign(raw)
+	return err
+}
+
 func writePid(fileName string, pid int) error {
 	err := os.MkdirAll(filepath.Dir(fileName), 0755)
 	if err != nil {
@@ -16,12 +16,15 @@ It is generated from these files:
 
 It has these top-level messages:
 	BlockNumber
+	TransactionUUID
 	BlockCount
 	ChaincodeEvent
 	ChaincodeID
 	ChaincodeInput
 	ChaincodeSpec
+	ChaincodeLimits
 	ChaincodeDeploymentSpec
+	ChaincodeLifecycle
 	ChaincodeInvocationSpec
 	ChaincodeSecurityContext
 	ChaincodeMessage
@@ -31,11 +34,14 @@ It has these top-level messages:
 	RangeQueryStateClose
 	RangeQueryStateKeyValue
 	RangeQueryStateResponse
+	KeyModification
+	KeyHistory
 	Secret
 	SigmaInput
 	ExecuteWithBinding
 	SigmaOutput
 	BuildResult
+	DeterminismReport
 	TransactionRequest
 	ChaincodeReg
 	Interest
@@ -45,6 +51,11 @@ It has these top-level messages:
 	Transaction
 	TransactionBlock
 	TransactionResult
+	ReadWriteSet
+	StateRead
+	StateWrite
+	ChaincodeInvocation
+	TransactionStatus
 	Block
 	BlockchainInfo
 	NonHashData
@@ -63,6 +74,12 @@ It has these top-level messages:
 	SyncStateDeltasRequest
 	SyncStateDeltas
 	ServerStatus
+	ValidatorSet
+	ConsensusStatus
+	ConsensusCheckpoint
+	ReplicaStatus
+	ConsensusMetrics
+	Histogram
 */
 package protos
 
@@ -90,6 +107,15 @@ func (m *BlockNumber) Reset()         { *m = BlockNumber{} }
 func (m *BlockNumber) String() string { return proto.CompactTextString(m) }
 func (*BlockNumber) ProtoMessage()    {}
 
+// Specifies the UUID of a transaction.
+type TransactionUUID struct {
+	Uuid string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+}
+
+func (m *TransactionUUID) Reset()         { *m = TransactionUUID{} }
+func (m *TransactionUUID) String() string { return proto.CompactTextString(m) }
+func (*TransactionUUID) ProtoMessage()    {}
+
 // Specifies the current number of blocks in the blockchain.
 type BlockCount struct {
 	Count uint64 `protobuf:"varint,1,opt,name=count" json:"count,omitempty"`
@@ -118,6 +144,12 @@ type OpenchainClient interface {
 	// GetPeers returns a list of all peer nodes currently connected to the target
 	// peer.
 	GetPeers(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*PeersMessage, error)
+	// GetTransactionStatus returns whether a transaction is pending, committed
+	// or failed.
+	GetTransactionStatus(ctx context.Context, in *TransactionUUID, opts ...grpc.CallOption) (*TransactionStatus, error)
+	// GetTransactionResult returns the result of a transaction, i.e. the
+	// payload returned by the chaincode or the error if it failed.
+	GetTransactionResult(ctx context.Context, in *TransactionUUID, opts ...grpc.CallOption) (*TransactionResult, error)
 }
 
 type openchainClient struct {
@@ -164,6 +196,24 @@ func (c *openchainClient) GetPeers(ctx context.Context, in *google_protobuf1.Emp
 	return out, nil
 }
 
+func (c *openchainClient) GetTransactionStatus(ctx context.Context, in *TransactionUUID, opts ...grpc.CallOption) (*TransactionStatus, error) {
+	out := new(TransactionStatus)
+	err := grpc.Invoke(ctx, "/protos.Openchain/GetTransactionStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *openchainClient) GetTransactionResult(ctx context.Context, in *TransactionUUID, opts ...grpc.CallOption) (*TransactionResult, error) {
+	out := new(TransactionResult)
+	err := grpc.Invoke(ctx, "/protos.Openchain/GetTransactionResult", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Openchain service
 
 type OpenchainServer interface {
@@ -179,6 +229,12 @@ type OpenchainServer interface {
 	// GetPeers returns a list of all peer nodes currently connected to the target
 	// peer.
 	GetPeers(context.Context, *google_protobuf1.Empty) (*PeersMessage, error)
+	// GetTransactionStatus returns whether a transaction is pending, committed
+	// or failed.
+	GetTransactionStatus(context.Context, *TransactionUUID) (*TransactionStatus, error)
+	// GetTransactionResult returns the result of a transaction, i.e. the
+	// payload returned by the chaincode or the error if it failed.
+	GetTransactionResult(context.Context, *TransactionUUID) (*TransactionResult, error)
 }
 
 func RegisterOpenchainServer(s *grpc.Server, srv OpenchainServer) {
@@ -233,6 +289,30 @@ func _Openchain_GetPeers_Handler(srv interface{}, ctx context.Context, dec func(
 	return out, nil
 }
 
+func _Openchain_GetTransactionStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(TransactionUUID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(OpenchainServer).GetTransactionStatus(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Openchain_GetTransactionResult_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(TransactionUUID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(OpenchainServer).GetTransactionResult(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _Openchain_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.Openchain",
 	HandlerType: (*OpenchainServer)(nil),
@@ -253,6 +333,14 @@ var _Openchain_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetPeers",
 			Handler:    _Openchain_GetPeers_Handler,
 		},
+		{
+			MethodName: "GetTransactionStatus",
+			Handler:    _Openchain_GetTransactionStatus_Handler,
+		},
+		{
+			MethodName: "GetTransactionResult",
+			Handler:    _Openchain_GetTransactionResult_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{},
 }
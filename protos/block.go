@@ -88,6 +88,13 @@ func (block *Block) GetStateHash() []byte {
 	return block.StateHash
 }
 
+// IsPruned returns true if the transactions of this block have been pruned
+// from the local ledger. The hash of a pruned block can not be computed and is
+// recorded in NonHashData.PrunedBlockHash instead.
+func (block *Block) IsPruned() bool {
+	return block.NonHashData != nil && len(block.NonHashData.PrunedBlockHash) != 0
+}
+
 // SetPreviousBlockHash sets the hash of the previous block. This will be
 // called by blockchain.AddBlock when then the block is added.
 func (block *Block) SetPreviousBlockHash(previousBlockHash []byte) {
@@ -89,6 +89,26 @@ func (x ChaincodeDeploymentSpec_ExecutionEnvironment) String() string {
 	return proto.EnumName(ChaincodeDeploymentSpec_ExecutionEnvironment_name, int32(x))
 }
 
+type ChaincodeLifecycle_Status int32
+
+const (
+	ChaincodeLifecycle_ACTIVE     ChaincodeLifecycle_Status = 0
+	ChaincodeLifecycle_TERMINATED ChaincodeLifecycle_Status = 1
+)
+
+var ChaincodeLifecycle_Status_name = map[int32]string{
+	0: "ACTIVE",
+	1: "TERMINATED",
+}
+var ChaincodeLifecycle_Status_value = map[string]int32{
+	"ACTIVE":     0,
+	"TERMINATED": 1,
+}
+
+func (x ChaincodeLifecycle_Status) String() string {
+	return proto.EnumName(ChaincodeLifecycle_Status_name, int32(x))
+}
+
 type ChaincodeMessage_Type int32
 
 const (
@@ -113,6 +133,7 @@ const (
 	ChaincodeMessage_RANGE_QUERY_STATE_NEXT  ChaincodeMessage_Type = 18
 	ChaincodeMessage_RANGE_QUERY_STATE_CLOSE ChaincodeMessage_Type = 19
 	ChaincodeMessage_KEEPALIVE               ChaincodeMessage_Type = 20
+	ChaincodeMessage_GET_HISTORY_FOR_KEY     ChaincodeMessage_Type = 21
 )
 
 var ChaincodeMessage_Type_name = map[int32]string{
@@ -137,6 +158,7 @@ var ChaincodeMessage_Type_name = map[int32]string{
 	18: "RANGE_QUERY_STATE_NEXT",
 	19: "RANGE_QUERY_STATE_CLOSE",
 	20: "KEEPALIVE",
+	21: "GET_HISTORY_FOR_KEY",
 }
 var ChaincodeMessage_Type_value = map[string]int32{
 	"UNDEFINED":               0,
@@ -160,6 +182,7 @@ var ChaincodeMessage_Type_value = map[string]int32{
 	"RANGE_QUERY_STATE_NEXT":  18,
 	"RANGE_QUERY_STATE_CLOSE": 19,
 	"KEEPALIVE":               20,
+	"GET_HISTORY_FOR_KEY":     21,
 }
 
 func (x ChaincodeMessage_Type) String() string {
@@ -198,14 +221,17 @@ func (*ChaincodeInput) ProtoMessage()    {}
 // Carries the chaincode specification. This is the actual metadata required for
 // defining a chaincode.
 type ChaincodeSpec struct {
-	Type                 ChaincodeSpec_Type   `protobuf:"varint,1,opt,name=type,enum=protos.ChaincodeSpec_Type" json:"type,omitempty"`
-	ChaincodeID          *ChaincodeID         `protobuf:"bytes,2,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
-	CtorMsg              *ChaincodeInput      `protobuf:"bytes,3,opt,name=ctorMsg" json:"ctorMsg,omitempty"`
+	Type        ChaincodeSpec_Type `protobuf:"varint,1,opt,name=type,enum=protos.ChaincodeSpec_Type" json:"type,omitempty"`
+	ChaincodeID *ChaincodeID       `protobuf:"bytes,2,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	CtorMsg     *ChaincodeInput    `protobuf:"bytes,3,opt,name=ctorMsg" json:"ctorMsg,omitempty"`
+	// Deadline in milliseconds for executing a transaction or query of the
+	// deployed chaincode, 0 for chaincode.executetimeout of the peer
 	Timeout              int32                `protobuf:"varint,4,opt,name=timeout" json:"timeout,omitempty"`
 	SecureContext        string               `protobuf:"bytes,5,opt,name=secureContext" json:"secureContext,omitempty"`
 	ConfidentialityLevel ConfidentialityLevel `protobuf:"varint,6,opt,name=confidentialityLevel,enum=protos.ConfidentialityLevel" json:"confidentialityLevel,omitempty"`
 	Metadata             []byte               `protobuf:"bytes,7,opt,name=metadata,proto3" json:"metadata,omitempty"`
 	Attributes           []string             `protobuf:"bytes,8,rep,name=attributes" json:"attributes,omitempty"`
+	Limits               *ChaincodeLimits     `protobuf:"bytes,9,opt,name=limits" json:"limits,omitempty"`
 }
 
 func (m *ChaincodeSpec) Reset()         { *m = ChaincodeSpec{} }
@@ -226,6 +252,32 @@ func (m *ChaincodeSpec) GetCtorMsg() *ChaincodeInput {
 	return nil
 }
 
+func (m *ChaincodeSpec) GetLimits() *ChaincodeLimits {
+	if m != nil {
+		return m.Limits
+	}
+	return nil
+}
+
+// Limits on the resources used by a chaincode, declared when it is deployed.
+// A zero value leaves the resource to the defaults of the peer.
+type ChaincodeLimits struct {
+	// Relative CPU weight of the chaincode container
+	CpuShares int64 `protobuf:"varint,1,opt,name=cpuShares" json:"cpuShares,omitempty"`
+	// Memory limit of the chaincode container, in bytes
+	Memory int64 `protobuf:"varint,2,opt,name=memory" json:"memory,omitempty"`
+	// Maximum total size, in bytes, of the values written to the state by a
+	// transaction
+	MaxWriteSize uint64 `protobuf:"varint,3,opt,name=maxWriteSize" json:"maxWriteSize,omitempty"`
+	// Maximum number of state operations (get, put, delete, range query and
+	// history requests) of a transaction
+	MaxStateOps uint32 `protobuf:"varint,4,opt,name=maxStateOps" json:"maxStateOps,omitempty"`
+}
+
+func (m *ChaincodeLimits) Reset()         { *m = ChaincodeLimits{} }
+func (m *ChaincodeLimits) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeLimits) ProtoMessage()    {}
+
 // Specify the deployment of a chaincode.
 // TODO: Define `codePackage`.
 type ChaincodeDeploymentSpec struct {
@@ -254,6 +306,18 @@ func (m *ChaincodeDeploymentSpec) GetEffectiveDate() *google_protobuf.Timestamp
 	return nil
 }
 
+// ChaincodeLifecycle is recorded in the ledger for chaincodes that have been
+// upgraded or terminated. deploymentUuid is the UUID of the deploy or upgrade
+// transaction carrying the code currently in use.
+type ChaincodeLifecycle struct {
+	DeploymentUuid string                    `protobuf:"bytes,1,opt,name=deploymentUuid" json:"deploymentUuid,omitempty"`
+	Status         ChaincodeLifecycle_Status `protobuf:"varint,2,opt,name=status,enum=protos.ChaincodeLifecycle_Status" json:"status,omitempty"`
+}
+
+func (m *ChaincodeLifecycle) Reset()         { *m = ChaincodeLifecycle{} }
+func (m *ChaincodeLifecycle) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeLifecycle) ProtoMessage()    {}
+
 // Carries the chaincode function and its arguments.
 type ChaincodeInvocationSpec struct {
 	ChaincodeSpec *ChaincodeSpec `protobuf:"bytes,1,opt,name=chaincodeSpec" json:"chaincodeSpec,omitempty"`
@@ -309,10 +373,11 @@ type ChaincodeMessage struct {
 	Payload         []byte                     `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
 	Uuid            string                     `protobuf:"bytes,4,opt,name=uuid" json:"uuid,omitempty"`
 	SecurityContext *ChaincodeSecurityContext  `protobuf:"bytes,5,opt,name=securityContext" json:"securityContext,omitempty"`
-	// event emmited by chaincode. Used only with Init or Invoke.
-	// This event is then stored (currently)
-	// with Block.NonHashData.TransactionResult
-	ChaincodeEvent *ChaincodeEvent `protobuf:"bytes,6,opt,name=chaincodeEvent" json:"chaincodeEvent,omitempty"`
+	// events emmited by chaincode, in order. Used only with Init or Invoke.
+	// These events are then stored (currently)
+	// with Block.NonHashData.ChaincodeEvents. The field held a single event
+	// in earlier versions, which is read as a list of one event
+	ChaincodeEvents []*ChaincodeEvent `protobuf:"bytes,6,rep,name=chaincodeEvents" json:"chaincodeEvents,omitempty"`
 }
 
 func (m *ChaincodeMessage) Reset()         { *m = ChaincodeMessage{} }
@@ -333,9 +398,9 @@ func (m *ChaincodeMessage) GetSecurityContext() *ChaincodeSecurityContext {
 	return nil
 }
 
-func (m *ChaincodeMessage) GetChaincodeEvent() *ChaincodeEvent {
+func (m *ChaincodeMessage) GetChaincodeEvents() []*ChaincodeEvent {
 	if m != nil {
-		return m.ChaincodeEvent
+		return m.ChaincodeEvents
 	}
 	return nil
 }
@@ -352,6 +417,11 @@ func (*PutStateInfo) ProtoMessage()    {}
 type RangeQueryState struct {
 	StartKey string `protobuf:"bytes,1,opt,name=startKey" json:"startKey,omitempty"`
 	EndKey   string `protobuf:"bytes,2,opt,name=endKey" json:"endKey,omitempty"`
+	// When ordered is set, the keys are returned in lexical order, descending
+	// if reverse is set, and at most limit keys are returned unless limit is 0.
+	Ordered bool   `protobuf:"varint,3,opt,name=ordered" json:"ordered,omitempty"`
+	Reverse bool   `protobuf:"varint,4,opt,name=reverse" json:"reverse,omitempty"`
+	Limit   uint32 `protobuf:"varint,5,opt,name=limit" json:"limit,omitempty"`
 }
 
 func (m *RangeQueryState) Reset()         { *m = RangeQueryState{} }
@@ -400,10 +470,41 @@ func (m *RangeQueryStateResponse) GetKeysAndValues() []*RangeQueryStateKeyValue
 	return nil
 }
 
+// KeyModification records a single change made to a key by a committed
+// transaction. isDelete is set when the transaction deleted the key, in which
+// case value is empty.
+type KeyModification struct {
+	BlockNumber uint64 `protobuf:"varint,1,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	TxUuid      string `protobuf:"bytes,2,opt,name=txUuid" json:"txUuid,omitempty"`
+	Value       []byte `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	IsDelete    bool   `protobuf:"varint,4,opt,name=isDelete" json:"isDelete,omitempty"`
+}
+
+func (m *KeyModification) Reset()         { *m = KeyModification{} }
+func (m *KeyModification) String() string { return proto.CompactTextString(m) }
+func (*KeyModification) ProtoMessage()    {}
+
+// KeyHistory lists the modifications of a key, oldest first.
+type KeyHistory struct {
+	Modifications []*KeyModification `protobuf:"bytes,1,rep,name=modifications" json:"modifications,omitempty"`
+}
+
+func (m *KeyHistory) Reset()         { *m = KeyHistory{} }
+func (m *KeyHistory) String() string { return proto.CompactTextString(m) }
+func (*KeyHistory) ProtoMessage()    {}
+
+func (m *KeyHistory) GetModifications() []*KeyModification {
+	if m != nil {
+		return m.Modifications
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("protos.ConfidentialityLevel", ConfidentialityLevel_name, ConfidentialityLevel_value)
 	proto.RegisterEnum("protos.ChaincodeSpec_Type", ChaincodeSpec_Type_name, ChaincodeSpec_Type_value)
 	proto.RegisterEnum("protos.ChaincodeDeploymentSpec_ExecutionEnvironment", ChaincodeDeploymentSpec_ExecutionEnvironment_name, ChaincodeDeploymentSpec_ExecutionEnvironment_value)
+	proto.RegisterEnum("protos.ChaincodeLifecycle_Status", ChaincodeLifecycle_Status_name, ChaincodeLifecycle_Status_value)
 	proto.RegisterEnum("protos.ChaincodeMessage_Type", ChaincodeMessage_Type_name, ChaincodeMessage_Type_value)
 }
 
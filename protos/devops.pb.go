@@ -112,6 +112,47 @@ func (m *BuildResult) GetDeploymentSpec() *ChaincodeDeploymentSpec {
 	return nil
 }
 
+// DeterminismReport compares two executions of a transaction against the
+// current state, and the first one with the execution recorded in the ledger.
+// deterministic - true if the executions returned the same result, read the
+// same values and wrote the same values, and so did the first execution and
+// the recorded one unless the state changed since
+// differences - a description of each difference between the executions
+// executions - the state read and written by each execution
+// original - the state read and written by the recorded execution
+// originalDifferences - a description of each difference between the first
+// execution and the recorded one
+// stateChanges - the keys read by the recorded execution that hold other
+// values now, the first execution cannot be expected to match the recorded one
+// if there are any
+type DeterminismReport struct {
+	Uuid                string          `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Deterministic       bool            `protobuf:"varint,2,opt,name=deterministic" json:"deterministic,omitempty"`
+	Differences         []string        `protobuf:"bytes,3,rep,name=differences" json:"differences,omitempty"`
+	Executions          []*ReadWriteSet `protobuf:"bytes,4,rep,name=executions" json:"executions,omitempty"`
+	Original            *ReadWriteSet   `protobuf:"bytes,5,opt,name=original" json:"original,omitempty"`
+	OriginalDifferences []string        `protobuf:"bytes,6,rep,name=originalDifferences" json:"originalDifferences,omitempty"`
+	StateChanges        []string        `protobuf:"bytes,7,rep,name=stateChanges" json:"stateChanges,omitempty"`
+}
+
+func (m *DeterminismReport) Reset()         { *m = DeterminismReport{} }
+func (m *DeterminismReport) String() string { return proto.CompactTextString(m) }
+func (*DeterminismReport) ProtoMessage()    {}
+
+func (m *DeterminismReport) GetExecutions() []*ReadWriteSet {
+	if m != nil {
+		return m.Executions
+	}
+	return nil
+}
+
+func (m *DeterminismReport) GetOriginal() *ReadWriteSet {
+	if m != nil {
+		return m.Original
+	}
+	return nil
+}
+
 type TransactionRequest struct {
 	TransactionUuid string `protobuf:"bytes,1,opt,name=transactionUuid" json:"transactionUuid,omitempty"`
 }
@@ -142,6 +183,16 @@ type DevopsClient interface {
 	Invoke(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*Response, error)
 	// Invoke chaincode.
 	Query(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*Response, error)
+	// Upgrade a deployed chaincode to new code, keeping its name and state.
+	Upgrade(ctx context.Context, in *ChaincodeSpec, opts ...grpc.CallOption) (*ChaincodeDeploymentSpec, error)
+	// Terminate a deployed chaincode.
+	Terminate(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*Response, error)
+	// Get the status of a transaction, CONFIRMED once f+1 validators report
+	// the same block for it.
+	GetTransactionStatus(ctx context.Context, in *TransactionUUID, opts ...grpc.CallOption) (*TransactionStatus, error)
+	// Execute a transaction of the ledger twice against the current state,
+	// without changing it, and compare the two executions.
+	CheckDeterminism(ctx context.Context, in *TransactionUUID, opts ...grpc.CallOption) (*DeterminismReport, error)
 	// Retrieve a TCert.
 	EXP_GetApplicationTCert(ctx context.Context, in *Secret, opts ...grpc.CallOption) (*Response, error)
 	// Prepare for performing a TX, which will return a binding that can later be used to sign and then execute a transaction.
@@ -205,6 +256,42 @@ func (c *devopsClient) Query(ctx context.Context, in *ChaincodeInvocationSpec, o
 	return out, nil
 }
 
+func (c *devopsClient) Upgrade(ctx context.Context, in *ChaincodeSpec, opts ...grpc.CallOption) (*ChaincodeDeploymentSpec, error) {
+	out := new(ChaincodeDeploymentSpec)
+	err := grpc.Invoke(ctx, "/protos.Devops/Upgrade", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) Terminate(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := grpc.Invoke(ctx, "/protos.Devops/Terminate", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) GetTransactionStatus(ctx context.Context, in *TransactionUUID, opts ...grpc.CallOption) (*TransactionStatus, error) {
+	out := new(TransactionStatus)
+	err := grpc.Invoke(ctx, "/protos.Devops/GetTransactionStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) CheckDeterminism(ctx context.Context, in *TransactionUUID, opts ...grpc.CallOption) (*DeterminismReport, error) {
+	out := new(DeterminismReport)
+	err := grpc.Invoke(ctx, "/protos.Devops/CheckDeterminism", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *devopsClient) EXP_GetApplicationTCert(ctx context.Context, in *Secret, opts ...grpc.CallOption) (*Response, error) {
 	out := new(Response)
 	err := grpc.Invoke(ctx, "/protos.Devops/EXP_GetApplicationTCert", in, out, c.cc, opts...)
@@ -255,6 +342,16 @@ type DevopsServer interface {
 	Invoke(context.Context, *ChaincodeInvocationSpec) (*Response, error)
 	// Invoke chaincode.
 	Query(context.Context, *ChaincodeInvocationSpec) (*Response, error)
+	// Upgrade a deployed chaincode to new code, keeping its name and state.
+	Upgrade(context.Context, *ChaincodeSpec) (*ChaincodeDeploymentSpec, error)
+	// Terminate a deployed chaincode.
+	Terminate(context.Context, *ChaincodeInvocationSpec) (*Response, error)
+	// Get the status of a transaction, CONFIRMED once f+1 validators report
+	// the same block for it.
+	GetTransactionStatus(context.Context, *TransactionUUID) (*TransactionStatus, error)
+	// Execute a transaction of the ledger twice against the current state,
+	// without changing it, and compare the two executions.
+	CheckDeterminism(context.Context, *TransactionUUID) (*DeterminismReport, error)
 	// Retrieve a TCert.
 	EXP_GetApplicationTCert(context.Context, *Secret) (*Response, error)
 	// Prepare for performing a TX, which will return a binding that can later be used to sign and then execute a transaction.
@@ -329,6 +426,54 @@ func _Devops_Query_Handler(srv interface{}, ctx context.Context, dec func(interf
 	return out, nil
 }
 
+func _Devops_Upgrade_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ChaincodeSpec)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).Upgrade(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_Terminate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ChaincodeInvocationSpec)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).Terminate(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_GetTransactionStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(TransactionUUID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).GetTransactionStatus(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_CheckDeterminism_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(TransactionUUID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).CheckDeterminism(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func _Devops_EXP_GetApplicationTCert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
 	in := new(Secret)
 	if err := dec(in); err != nil {
@@ -401,6 +546,22 @@ var _Devops_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Query",
 			Handler:    _Devops_Query_Handler,
 		},
+		{
+			MethodName: "Upgrade",
+			Handler:    _Devops_Upgrade_Handler,
+		},
+		{
+			MethodName: "Terminate",
+			Handler:    _Devops_Terminate_Handler,
+		},
+		{
+			MethodName: "GetTransactionStatus",
+			Handler:    _Devops_GetTransactionStatus_Handler,
+		},
+		{
+			MethodName: "CheckDeterminism",
+			Handler:    _Devops_CheckDeterminism_Handler,
+		},
 		{
 			MethodName: "EXP_GetApplicationTCert",
 			Handler:    _Devops_EXP_GetApplicationTCert_Handler,
@@ -7,6 +7,7 @@ package protos
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
+import google_protobuf "google/protobuf"
 
 import (
 	context "golang.org/x/net/context"
@@ -21,10 +22,11 @@ var _ = math.Inf
 type EventType int32
 
 const (
-	EventType_REGISTER  EventType = 0
-	EventType_BLOCK     EventType = 1
-	EventType_CHAINCODE EventType = 2
-	EventType_REJECTION EventType = 3
+	EventType_REGISTER           EventType = 0
+	EventType_BLOCK              EventType = 1
+	EventType_CHAINCODE          EventType = 2
+	EventType_REJECTION          EventType = 3
+	EventType_TRANSACTION_STATUS EventType = 4
 )
 
 var EventType_name = map[int32]string{
@@ -32,12 +34,14 @@ var EventType_name = map[int32]string{
 	1: "BLOCK",
 	2: "CHAINCODE",
 	3: "REJECTION",
+	4: "TRANSACTION_STATUS",
 }
 var EventType_value = map[string]int32{
-	"REGISTER":  0,
-	"BLOCK":     1,
-	"CHAINCODE": 2,
-	"REJECTION": 3,
+	"REGISTER":           0,
+	"BLOCK":              1,
+	"CHAINCODE":          2,
+	"REJECTION":          3,
+	"TRANSACTION_STATUS": 4,
 }
 
 func (x EventType) String() string {
@@ -46,9 +50,12 @@ func (x EventType) String() string {
 
 // ChaincodeReg is used for registering chaincode Interests
 // when EventType is CHAINCODE
+// eventName - name of the events, all the events of the chaincode if empty
+// isRegex - eventName is a regular expression the whole event name must match
 type ChaincodeReg struct {
 	ChaincodeID string `protobuf:"bytes,1,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
 	EventName   string `protobuf:"bytes,2,opt,name=eventName" json:"eventName,omitempty"`
+	IsRegex     bool   `protobuf:"varint,3,opt,name=isRegex" json:"isRegex,omitempty"`
 }
 
 func (m *ChaincodeReg) Reset()         { *m = ChaincodeReg{} }
@@ -65,6 +72,9 @@ type Interest struct {
 	// Types that are valid to be assigned to RegInfo:
 	//	*Interest_ChaincodeRegInfo
 	RegInfo isInterest_RegInfo `protobuf_oneof:"RegInfo"`
+	// if set, only the events of the transaction with this UUID are sent.
+	// For BLOCK, the block containing the transaction is sent
+	TxID string `protobuf:"bytes,3,opt,name=txID" json:"txID,omitempty"`
 }
 
 func (m *Interest) Reset()         { *m = Interest{} }
@@ -137,8 +147,14 @@ func _Interest_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffe
 // ---------- consumer events ---------
 // Register is sent by consumers for registering events
 // string type - "register"
+// startBlock - if set, the events of the blocks committed from this block on
+// are replayed from the ledger before new events are sent. The producer
+// answers with the Register it received, startBlock set to the first block
+// whose events the consumer will receive. It is not set if the producer
+// cannot replay events
 type Register struct {
-	Events []*Interest `protobuf:"bytes,1,rep,name=events" json:"events,omitempty"`
+	Events     []*Interest  `protobuf:"bytes,1,rep,name=events" json:"events,omitempty"`
+	StartBlock *BlockNumber `protobuf:"bytes,2,opt,name=startBlock" json:"startBlock,omitempty"`
 }
 
 func (m *Register) Reset()         { *m = Register{} }
@@ -152,6 +168,13 @@ func (m *Register) GetEvents() []*Interest {
 	return nil
 }
 
+func (m *Register) GetStartBlock() *BlockNumber {
+	if m != nil {
+		return m.StartBlock
+	}
+	return nil
+}
+
 // Rejection is sent by consumers for erroneous transaction rejection events
 // string type - "rejection"
 type Rejection struct {
@@ -174,13 +197,19 @@ func (m *Rejection) GetTx() *Transaction {
 // Event is used by
 //  - consumers (adapters) to send Register
 //  - producer to advertise supported types and events
+// timestamp - for BLOCK, CHAINCODE and TRANSACTION_STATUS the time at which the block was committed
+// to the ledger, otherwise the time at which the event was created
+// blockNumber - for BLOCK, CHAINCODE and TRANSACTION_STATUS the number of the block
 type Event struct {
 	// Types that are valid to be assigned to Event:
 	//	*Event_Register
 	//	*Event_Block
 	//	*Event_ChaincodeEvent
 	//	*Event_Rejection
-	Event isEvent_Event `protobuf_oneof:"Event"`
+	//	*Event_TransactionStatus
+	Event       isEvent_Event              `protobuf_oneof:"Event"`
+	Timestamp   *google_protobuf.Timestamp `protobuf:"bytes,5,opt,name=timestamp" json:"timestamp,omitempty"`
+	BlockNumber uint64                     `protobuf:"varint,6,opt,name=blockNumber" json:"blockNumber,omitempty"`
 }
 
 func (m *Event) Reset()         { *m = Event{} }
@@ -203,11 +232,15 @@ type Event_ChaincodeEvent struct {
 type Event_Rejection struct {
 	Rejection *Rejection `protobuf:"bytes,4,opt,name=rejection,oneof"`
 }
+type Event_TransactionStatus struct {
+	TransactionStatus *TransactionStatus `protobuf:"bytes,7,opt,name=transactionStatus,oneof"`
+}
 
-func (*Event_Register) isEvent_Event()       {}
-func (*Event_Block) isEvent_Event()          {}
-func (*Event_ChaincodeEvent) isEvent_Event() {}
-func (*Event_Rejection) isEvent_Event()      {}
+func (*Event_Register) isEvent_Event()          {}
+func (*Event_Block) isEvent_Event()             {}
+func (*Event_ChaincodeEvent) isEvent_Event()    {}
+func (*Event_Rejection) isEvent_Event()         {}
+func (*Event_TransactionStatus) isEvent_Event() {}
 
 func (m *Event) GetEvent() isEvent_Event {
 	if m != nil {
@@ -244,6 +277,20 @@ func (m *Event) GetRejection() *Rejection {
 	return nil
 }
 
+func (m *Event) GetTransactionStatus() *TransactionStatus {
+	if x, ok := m.GetEvent().(*Event_TransactionStatus); ok {
+		return x.TransactionStatus
+	}
+	return nil
+}
+
+func (m *Event) GetTimestamp() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Timestamp
+	}
+	return nil
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*Event) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), []interface{}) {
 	return _Event_OneofMarshaler, _Event_OneofUnmarshaler, []interface{}{
@@ -251,6 +298,7 @@ func (*Event) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error,
 		(*Event_Block)(nil),
 		(*Event_ChaincodeEvent)(nil),
 		(*Event_Rejection)(nil),
+		(*Event_TransactionStatus)(nil),
 	}
 }
 
@@ -278,6 +326,11 @@ func _Event_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
 		if err := b.EncodeMessage(x.Rejection); err != nil {
 			return err
 		}
+	case *Event_TransactionStatus:
+		b.EncodeVarint(7<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.TransactionStatus); err != nil {
+			return err
+		}
 	case nil:
 	default:
 		return fmt.Errorf("Event.Event has unexpected type %T", x)
@@ -320,6 +373,14 @@ func _Event_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer)
 		err := b.DecodeMessage(msg)
 		m.Event = &Event_Rejection{msg}
 		return true, err
+	case 7: // Event.transactionStatus
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(TransactionStatus)
+		err := b.DecodeMessage(msg)
+		m.Event = &Event_TransactionStatus{msg}
+		return true, err
 	default:
 		return false, nil
 	}
@@ -29,8 +29,11 @@ const (
 	Transaction_CHAINCODE_INVOKE Transaction_Type = 2
 	// call a chaincode `query` function
 	Transaction_CHAINCODE_QUERY Transaction_Type = 3
-	// terminate a chaincode; not implemented yet
+	// terminate a chaincode, stopping its container and marking it as
+	// terminated in the ledger
 	Transaction_CHAINCODE_TERMINATE Transaction_Type = 4
+	// replace the code of a deployed chaincode, keeping its name and state
+	Transaction_CHAINCODE_UPGRADE Transaction_Type = 5
 )
 
 var Transaction_Type_name = map[int32]string{
@@ -39,6 +42,7 @@ var Transaction_Type_name = map[int32]string{
 	2: "CHAINCODE_INVOKE",
 	3: "CHAINCODE_QUERY",
 	4: "CHAINCODE_TERMINATE",
+	5: "CHAINCODE_UPGRADE",
 }
 var Transaction_Type_value = map[string]int32{
 	"UNDEFINED":           0,
@@ -46,12 +50,42 @@ var Transaction_Type_value = map[string]int32{
 	"CHAINCODE_INVOKE":    2,
 	"CHAINCODE_QUERY":     3,
 	"CHAINCODE_TERMINATE": 4,
+	"CHAINCODE_UPGRADE":   5,
 }
 
 func (x Transaction_Type) String() string {
 	return proto.EnumName(Transaction_Type_name, int32(x))
 }
 
+type TransactionStatus_StatusCode int32
+
+const (
+	TransactionStatus_UNKNOWN   TransactionStatus_StatusCode = 0
+	TransactionStatus_PENDING   TransactionStatus_StatusCode = 1
+	TransactionStatus_COMMITTED TransactionStatus_StatusCode = 2
+	TransactionStatus_ERROR     TransactionStatus_StatusCode = 3
+	TransactionStatus_CONFIRMED TransactionStatus_StatusCode = 4
+)
+
+var TransactionStatus_StatusCode_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "PENDING",
+	2: "COMMITTED",
+	3: "ERROR",
+	4: "CONFIRMED",
+}
+var TransactionStatus_StatusCode_value = map[string]int32{
+	"UNKNOWN":   0,
+	"PENDING":   1,
+	"COMMITTED": 2,
+	"ERROR":     3,
+	"CONFIRMED": 4,
+}
+
+func (x TransactionStatus_StatusCode) String() string {
+	return proto.EnumName(TransactionStatus_StatusCode_name, int32(x))
+}
+
 type PeerEndpoint_Type int32
 
 const (
@@ -179,6 +213,10 @@ type Transaction struct {
 	ToValidators                   []byte                     `protobuf:"bytes,10,opt,name=toValidators,proto3" json:"toValidators,omitempty"`
 	Cert                           []byte                     `protobuf:"bytes,11,opt,name=cert,proto3" json:"cert,omitempty"`
 	Signature                      []byte                     `protobuf:"bytes,12,opt,name=signature,proto3" json:"signature,omitempty"`
+	// key decrypting the enrollment ID carried by the TCert in cert, set on
+	// deploy, upgrade and terminate transactions so that validators can tell
+	// who deployed a chaincode
+	EnrollmentIDKey []byte `protobuf:"bytes,13,opt,name=enrollmentIDKey,proto3" json:"enrollmentIDKey,omitempty"`
 }
 
 func (m *Transaction) Reset()         { *m = Transaction{} }
@@ -214,26 +252,147 @@ func (m *TransactionBlock) GetTransactions() []*Transaction {
 // result - The return value of the transaction.
 // errorCode - An error code. 5xx will be logged as a failure in the dashboard.
 // error - An error string for logging an issue.
-// chaincodeEvent - any event emitted by a transaction
+// chaincodeEvents - the events emitted by the transaction, in order
+// invocations - the chaincodes invoked by the chaincode of the transaction,
+// in the order they were invoked
+// readWriteSet - the state read and written by the transaction
 type TransactionResult struct {
-	Uuid           string          `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
-	Result         []byte          `protobuf:"bytes,2,opt,name=result,proto3" json:"result,omitempty"`
-	ErrorCode      uint32          `protobuf:"varint,3,opt,name=errorCode" json:"errorCode,omitempty"`
-	Error          string          `protobuf:"bytes,4,opt,name=error" json:"error,omitempty"`
-	ChaincodeEvent *ChaincodeEvent `protobuf:"bytes,5,opt,name=chaincodeEvent" json:"chaincodeEvent,omitempty"`
+	Uuid            string                 `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Result          []byte                 `protobuf:"bytes,2,opt,name=result,proto3" json:"result,omitempty"`
+	ErrorCode       uint32                 `protobuf:"varint,3,opt,name=errorCode" json:"errorCode,omitempty"`
+	Error           string                 `protobuf:"bytes,4,opt,name=error" json:"error,omitempty"`
+	ChaincodeEvents []*ChaincodeEvent      `protobuf:"bytes,5,rep,name=chaincodeEvents" json:"chaincodeEvents,omitempty"`
+	Invocations     []*ChaincodeInvocation `protobuf:"bytes,6,rep,name=invocations" json:"invocations,omitempty"`
+	ReadWriteSet    *ReadWriteSet          `protobuf:"bytes,7,opt,name=readWriteSet" json:"readWriteSet,omitempty"`
 }
 
 func (m *TransactionResult) Reset()         { *m = TransactionResult{} }
 func (m *TransactionResult) String() string { return proto.CompactTextString(m) }
 func (*TransactionResult) ProtoMessage()    {}
 
-func (m *TransactionResult) GetChaincodeEvent() *ChaincodeEvent {
+func (m *TransactionResult) GetChaincodeEvents() []*ChaincodeEvent {
+	if m != nil {
+		return m.ChaincodeEvents
+	}
+	return nil
+}
+
+func (m *TransactionResult) GetInvocations() []*ChaincodeInvocation {
+	if m != nil {
+		return m.Invocations
+	}
+	return nil
+}
+
+func (m *TransactionResult) GetReadWriteSet() *ReadWriteSet {
+	if m != nil {
+		return m.ReadWriteSet
+	}
+	return nil
+}
+
+// ReadWriteSet records the state read and written by the chaincodes of a
+// transaction, in the order of the operations. Values are recorded by their
+// hash.
+type ReadWriteSet struct {
+	Reads  []*StateRead  `protobuf:"bytes,1,rep,name=reads" json:"reads,omitempty"`
+	Writes []*StateWrite `protobuf:"bytes,2,rep,name=writes" json:"writes,omitempty"`
+}
+
+func (m *ReadWriteSet) Reset()         { *m = ReadWriteSet{} }
+func (m *ReadWriteSet) String() string { return proto.CompactTextString(m) }
+func (*ReadWriteSet) ProtoMessage()    {}
+
+func (m *ReadWriteSet) GetReads() []*StateRead {
+	if m != nil {
+		return m.Reads
+	}
+	return nil
+}
+
+func (m *ReadWriteSet) GetWrites() []*StateWrite {
+	if m != nil {
+		return m.Writes
+	}
+	return nil
+}
+
+// StateRead is a key read by a chaincode. valueHash is empty when the key
+// had no value.
+type StateRead struct {
+	ChaincodeID string `protobuf:"bytes,1,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	Key         string `protobuf:"bytes,2,opt,name=key" json:"key,omitempty"`
+	ValueHash   []byte `protobuf:"bytes,3,opt,name=valueHash,proto3" json:"valueHash,omitempty"`
+}
+
+func (m *StateRead) Reset()         { *m = StateRead{} }
+func (m *StateRead) String() string { return proto.CompactTextString(m) }
+func (*StateRead) ProtoMessage()    {}
+
+// StateWrite is a key put or deleted by a chaincode. valueHash is empty
+// when the key is deleted.
+type StateWrite struct {
+	ChaincodeID string `protobuf:"bytes,1,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	Key         string `protobuf:"bytes,2,opt,name=key" json:"key,omitempty"`
+	ValueHash   []byte `protobuf:"bytes,3,opt,name=valueHash,proto3" json:"valueHash,omitempty"`
+	IsDelete    bool   `protobuf:"varint,4,opt,name=isDelete" json:"isDelete,omitempty"`
+}
+
+func (m *StateWrite) Reset()         { *m = StateWrite{} }
+func (m *StateWrite) String() string { return proto.CompactTextString(m) }
+func (*StateWrite) ProtoMessage()    {}
+
+// ChaincodeInvocation records a chaincode invoked by another chaincode
+// during a transaction.
+// chaincodeID - The name of the invoked chaincode.
+// callerChaincodeID - The name of the invoking chaincode.
+// depth - 1 when invoked by the chaincode of the transaction, 2 when invoked
+// by a chaincode it invoked, and so on.
+// modifiedKeys - The keys of the invoked chaincode it put or deleted.
+// chaincodeEvents - The events emitted by the invoked chaincode, if it
+// completed.
+type ChaincodeInvocation struct {
+	ChaincodeID       string            `protobuf:"bytes,1,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	CallerChaincodeID string            `protobuf:"bytes,2,opt,name=callerChaincodeID" json:"callerChaincodeID,omitempty"`
+	Depth             uint32            `protobuf:"varint,3,opt,name=depth" json:"depth,omitempty"`
+	ModifiedKeys      []string          `protobuf:"bytes,4,rep,name=modifiedKeys" json:"modifiedKeys,omitempty"`
+	ChaincodeEvents   []*ChaincodeEvent `protobuf:"bytes,5,rep,name=chaincodeEvents" json:"chaincodeEvents,omitempty"`
+}
+
+func (m *ChaincodeInvocation) Reset()         { *m = ChaincodeInvocation{} }
+func (m *ChaincodeInvocation) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeInvocation) ProtoMessage()    {}
+
+func (m *ChaincodeInvocation) GetChaincodeEvents() []*ChaincodeEvent {
 	if m != nil {
-		return m.ChaincodeEvent
+		return m.ChaincodeEvents
 	}
 	return nil
 }
 
+// TransactionStatus is the status of a transaction known to the peer.
+// status - PENDING once the peer has submitted the transaction, COMMITTED
+// once it is part of a block, ERROR if its execution failed. CONFIRMED once
+// f+1 validators report the transaction committed in the same block.
+// blockNumber - The block the transaction is part of, or was executed with if
+// it failed.
+// errorCode, error - The error of a failed transaction.
+// blockHash - The hash of the block at blockNumber.
+// confirmations - The number of validators reporting this status.
+type TransactionStatus struct {
+	Uuid          string                       `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Status        TransactionStatus_StatusCode `protobuf:"varint,2,opt,name=status,enum=protos.TransactionStatus_StatusCode" json:"status,omitempty"`
+	BlockNumber   uint64                       `protobuf:"varint,3,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	ErrorCode     uint32                       `protobuf:"varint,4,opt,name=errorCode" json:"errorCode,omitempty"`
+	Error         string                       `protobuf:"bytes,5,opt,name=error" json:"error,omitempty"`
+	BlockHash     []byte                       `protobuf:"bytes,6,opt,name=blockHash,proto3" json:"blockHash,omitempty"`
+	Confirmations uint32                       `protobuf:"varint,7,opt,name=confirmations" json:"confirmations,omitempty"`
+}
+
+func (m *TransactionStatus) Reset()         { *m = TransactionStatus{} }
+func (m *TransactionStatus) String() string { return proto.CompactTextString(m) }
+func (*TransactionStatus) ProtoMessage()    {}
+
 // Block carries The data that describes a block in the blockchain.
 // version - Version used to track any protocol changes.
 // timestamp - The time at which the block or transaction order
@@ -297,8 +456,18 @@ func (*BlockchainInfo) ProtoMessage()    {}
 // the block hash when verifying the blockchain.
 // localLedgerCommitTimestamp - The time at which the block was added
 // to the ledger on the local peer.
+// prunedBlockHash - Set when the transactions of the block have been pruned
+// from the local ledger, to the hash of the block before pruning.
+// chaincodeEvents - The events emitted by the transactions of the block, in
+// the order of the transactions.
+// transactionResults - The results of the transactions executed with the
+// block, including the ones that failed and are therefore not part of it.
+// Their chaincode events are in chaincodeEvents instead.
 type NonHashData struct {
 	LocalLedgerCommitTimestamp *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=localLedgerCommitTimestamp" json:"localLedgerCommitTimestamp,omitempty"`
+	TransactionResults         []*TransactionResult       `protobuf:"bytes,2,rep,name=transactionResults" json:"transactionResults,omitempty"`
+	PrunedBlockHash            []byte                     `protobuf:"bytes,3,opt,name=prunedBlockHash,proto3" json:"prunedBlockHash,omitempty"`
+	ChaincodeEvents            []*ChaincodeEvent          `protobuf:"bytes,4,rep,name=chaincodeEvents" json:"chaincodeEvents,omitempty"`
 }
 
 func (m *NonHashData) Reset()         { *m = NonHashData{} }
@@ -312,6 +481,20 @@ func (m *NonHashData) GetLocalLedgerCommitTimestamp() *google_protobuf.Timestamp
 	return nil
 }
 
+func (m *NonHashData) GetTransactionResults() []*TransactionResult {
+	if m != nil {
+		return m.TransactionResults
+	}
+	return nil
+}
+
+func (m *NonHashData) GetChaincodeEvents() []*ChaincodeEvent {
+	if m != nil {
+		return m.ChaincodeEvents
+	}
+	return nil
+}
+
 type PeerAddress struct {
 	Host string `protobuf:"bytes,1,opt,name=host" json:"host,omitempty"`
 	Port int32  `protobuf:"varint,2,opt,name=port" json:"port,omitempty"`
@@ -373,6 +556,9 @@ func (*PeersAddresses) ProtoMessage()    {}
 type HelloMessage struct {
 	PeerEndpoint   *PeerEndpoint   `protobuf:"bytes,1,opt,name=peerEndpoint" json:"peerEndpoint,omitempty"`
 	BlockchainInfo *BlockchainInfo `protobuf:"bytes,2,opt,name=blockchainInfo" json:"blockchainInfo,omitempty"`
+	// Crypto suite of the peer and crypto suites it accepts, set if security is enabled
+	CryptoSuite         string   `protobuf:"bytes,3,opt,name=cryptoSuite" json:"cryptoSuite,omitempty"`
+	AllowedCryptoSuites []string `protobuf:"bytes,4,rep,name=allowedCryptoSuites" json:"allowedCryptoSuites,omitempty"`
 }
 
 func (m *HelloMessage) Reset()         { *m = HelloMessage{} }
@@ -551,6 +737,7 @@ func (m *SyncStateDeltas) GetRange() *SyncBlockRange {
 
 func init() {
 	proto.RegisterEnum("protos.Transaction_Type", Transaction_Type_name, Transaction_Type_value)
+	proto.RegisterEnum("protos.TransactionStatus_StatusCode", TransactionStatus_StatusCode_name, TransactionStatus_StatusCode_value)
 	proto.RegisterEnum("protos.PeerEndpoint_Type", PeerEndpoint_Type_name, PeerEndpoint_Type_value)
 	proto.RegisterEnum("protos.Message_Type", Message_Type_name, Message_Type_value)
 	proto.RegisterEnum("protos.Response_StatusCode", Response_StatusCode_name, Response_StatusCode_value)
@@ -8,6 +8,7 @@ import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
 import google_protobuf1 "google/protobuf"
+import google_protobuf "google/protobuf"
 
 import (
 	context "golang.org/x/net/context"
@@ -59,6 +60,176 @@ func (m *ServerStatus) Reset()         { *m = ServerStatus{} }
 func (m *ServerStatus) String() string { return proto.CompactTextString(m) }
 func (*ServerStatus) ProtoMessage()    {}
 
+// ValidatorSet is a request to reconfigure the validators, it must be signed
+// by an administrator of the validating peer.
+// timestamp - When the request was created, requests are only accepted for
+// a few minutes.
+// cert - Enrollment certificate of the administrator.
+// signature - Signature of the request, without the signature, by the
+// enrollment key of the administrator.
+type ValidatorSet struct {
+	Validators []*PeerID                  `protobuf:"bytes,1,rep,name=validators" json:"validators,omitempty"`
+	Timestamp  *google_protobuf.Timestamp `protobuf:"bytes,2,opt,name=timestamp" json:"timestamp,omitempty"`
+	Cert       []byte                     `protobuf:"bytes,3,opt,name=cert,proto3" json:"cert,omitempty"`
+	Signature  []byte                     `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *ValidatorSet) Reset()         { *m = ValidatorSet{} }
+func (m *ValidatorSet) String() string { return proto.CompactTextString(m) }
+func (*ValidatorSet) ProtoMessage()    {}
+
+func (m *ValidatorSet) GetValidators() []*PeerID {
+	if m != nil {
+		return m.Validators
+	}
+	return nil
+}
+
+func (m *ValidatorSet) GetTimestamp() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Timestamp
+	}
+	return nil
+}
+
+// ConsensusStatus is the state of the consensus plugin of a validating peer.
+// Fields which do not apply to the plugin are left unset.
+// plugin - The name of the consensus plugin.
+// view - The current view, and whether a view change is in progress.
+// lowWatermark, highWatermark - The sequence numbers the replica accepts
+// requests for, PBFT h and H.
+// lastExec - The sequence number of the last request batch executed.
+// outstandingRequests - The requests received and not executed yet.
+// pendingRequests - The outstanding requests which are part of a request
+// batch being ordered.
+// checkpoints - The checkpoints of the replica.
+// replicas - What the replica knows of the other replicas.
+// f - The number of faulty validators the current validator set tolerates,
+// PBFT f.
+type ConsensusStatus struct {
+	Plugin                  string                 `protobuf:"bytes,1,opt,name=plugin" json:"plugin,omitempty"`
+	ReplicaId               uint64                 `protobuf:"varint,2,opt,name=replicaId" json:"replicaId,omitempty"`
+	View                    uint64                 `protobuf:"varint,3,opt,name=view" json:"view,omitempty"`
+	ViewChangeInProgress    bool                   `protobuf:"varint,4,opt,name=viewChangeInProgress" json:"viewChangeInProgress,omitempty"`
+	LowWatermark            uint64                 `protobuf:"varint,5,opt,name=lowWatermark" json:"lowWatermark,omitempty"`
+	HighWatermark           uint64                 `protobuf:"varint,6,opt,name=highWatermark" json:"highWatermark,omitempty"`
+	LastExec                uint64                 `protobuf:"varint,7,opt,name=lastExec" json:"lastExec,omitempty"`
+	OutstandingRequests     uint64                 `protobuf:"varint,8,opt,name=outstandingRequests" json:"outstandingRequests,omitempty"`
+	PendingRequests         uint64                 `protobuf:"varint,9,opt,name=pendingRequests" json:"pendingRequests,omitempty"`
+	StateTransferInProgress bool                   `protobuf:"varint,10,opt,name=stateTransferInProgress" json:"stateTransferInProgress,omitempty"`
+	Checkpoints             []*ConsensusCheckpoint `protobuf:"bytes,11,rep,name=checkpoints" json:"checkpoints,omitempty"`
+	Replicas                []*ReplicaStatus       `protobuf:"bytes,12,rep,name=replicas" json:"replicas,omitempty"`
+	Metrics                 *ConsensusMetrics      `protobuf:"bytes,13,opt,name=metrics" json:"metrics,omitempty"`
+	F                       uint32                 `protobuf:"varint,14,opt,name=f" json:"f,omitempty"`
+}
+
+func (m *ConsensusStatus) Reset()         { *m = ConsensusStatus{} }
+func (m *ConsensusStatus) String() string { return proto.CompactTextString(m) }
+func (*ConsensusStatus) ProtoMessage()    {}
+
+func (m *ConsensusStatus) GetCheckpoints() []*ConsensusCheckpoint {
+	if m != nil {
+		return m.Checkpoints
+	}
+	return nil
+}
+
+func (m *ConsensusStatus) GetReplicas() []*ReplicaStatus {
+	if m != nil {
+		return m.Replicas
+	}
+	return nil
+}
+
+func (m *ConsensusStatus) GetMetrics() *ConsensusMetrics {
+	if m != nil {
+		return m.Metrics
+	}
+	return nil
+}
+
+// ConsensusCheckpoint is a checkpoint taken by a replica.
+// votes - The number of replicas which reported the same checkpoint.
+// stable - Whether the checkpoint is the low watermark.
+type ConsensusCheckpoint struct {
+	SequenceNumber uint64 `protobuf:"varint,1,opt,name=sequenceNumber" json:"sequenceNumber,omitempty"`
+	Id             string `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	Votes          uint32 `protobuf:"varint,3,opt,name=votes" json:"votes,omitempty"`
+	Stable         bool   `protobuf:"varint,4,opt,name=stable" json:"stable,omitempty"`
+}
+
+func (m *ConsensusCheckpoint) Reset()         { *m = ConsensusCheckpoint{} }
+func (m *ConsensusCheckpoint) String() string { return proto.CompactTextString(m) }
+func (*ConsensusCheckpoint) ProtoMessage()    {}
+
+// ReplicaStatus is what a replica knows of another replica.
+// lastSeen - When the last consensus message of the replica was received.
+// lastCheckpoint - The sequence number of the last checkpoint received from
+// the replica.
+type ReplicaStatus struct {
+	ReplicaId      uint64                     `protobuf:"varint,1,opt,name=replicaId" json:"replicaId,omitempty"`
+	LastSeen       *google_protobuf.Timestamp `protobuf:"bytes,2,opt,name=lastSeen" json:"lastSeen,omitempty"`
+	LastCheckpoint uint64                     `protobuf:"varint,3,opt,name=lastCheckpoint" json:"lastCheckpoint,omitempty"`
+}
+
+func (m *ReplicaStatus) Reset()         { *m = ReplicaStatus{} }
+func (m *ReplicaStatus) String() string { return proto.CompactTextString(m) }
+func (*ReplicaStatus) ProtoMessage()    {}
+
+func (m *ReplicaStatus) GetLastSeen() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.LastSeen
+	}
+	return nil
+}
+
+// ConsensusMetrics counts the work done by the consensus plugin since the
+// peer started.
+// batchLatency - The time from the ordering of a batch to its execution.
+// requestLatency - The time from the creation of a request to its execution.
+type ConsensusMetrics struct {
+	ViewChanges    uint64     `protobuf:"varint,1,opt,name=viewChanges" json:"viewChanges,omitempty"`
+	Batches        uint64     `protobuf:"varint,2,opt,name=batches" json:"batches,omitempty"`
+	NullRequests   uint64     `protobuf:"varint,3,opt,name=nullRequests" json:"nullRequests,omitempty"`
+	Requests       uint64     `protobuf:"varint,4,opt,name=requests" json:"requests,omitempty"`
+	StateTransfers uint64     `protobuf:"varint,5,opt,name=stateTransfers" json:"stateTransfers,omitempty"`
+	BatchLatency   *Histogram `protobuf:"bytes,6,opt,name=batchLatency" json:"batchLatency,omitempty"`
+	RequestLatency *Histogram `protobuf:"bytes,7,opt,name=requestLatency" json:"requestLatency,omitempty"`
+}
+
+func (m *ConsensusMetrics) Reset()         { *m = ConsensusMetrics{} }
+func (m *ConsensusMetrics) String() string { return proto.CompactTextString(m) }
+func (*ConsensusMetrics) ProtoMessage()    {}
+
+func (m *ConsensusMetrics) GetBatchLatency() *Histogram {
+	if m != nil {
+		return m.BatchLatency
+	}
+	return nil
+}
+
+func (m *ConsensusMetrics) GetRequestLatency() *Histogram {
+	if m != nil {
+		return m.RequestLatency
+	}
+	return nil
+}
+
+// Histogram counts durations in seconds. counts[i] is the number of
+// durations up to upperBounds[i] and above upperBounds[i-1]. count is the
+// number of all durations, including those above the last upper bound, and
+// sum is their total.
+type Histogram struct {
+	UpperBounds []float64 `protobuf:"fixed64,1,rep,name=upperBounds" json:"upperBounds,omitempty"`
+	Counts      []uint64  `protobuf:"varint,2,rep,name=counts" json:"counts,omitempty"`
+	Count       uint64    `protobuf:"varint,3,opt,name=count" json:"count,omitempty"`
+	Sum         float64   `protobuf:"fixed64,4,opt,name=sum" json:"sum,omitempty"`
+}
+
+func (m *Histogram) Reset()         { *m = Histogram{} }
+func (m *Histogram) String() string { return proto.CompactTextString(m) }
+func (*Histogram) ProtoMessage()    {}
+
 func init() {
 	proto.RegisterEnum("protos.ServerStatus_StatusCode", ServerStatus_StatusCode_name, ServerStatus_StatusCode_value)
 }
@@ -74,6 +245,12 @@ type AdminClient interface {
 	GetStatus(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStatus, error)
 	StartServer(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStatus, error)
 	StopServer(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStatus, error)
+	// Replace the validating peers of the network, the change is ordered
+	// through consensus and takes effect once committed.
+	ReconfigureValidators(ctx context.Context, in *ValidatorSet, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// Return the state and metrics of the consensus plugin of a validating
+	// peer.
+	GetConsensusStatus(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ConsensusStatus, error)
 }
 
 type adminClient struct {
@@ -111,6 +288,24 @@ func (c *adminClient) StopServer(ctx context.Context, in *google_protobuf1.Empty
 	return out, nil
 }
 
+func (c *adminClient) ReconfigureValidators(ctx context.Context, in *ValidatorSet, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/protos.Admin/ReconfigureValidators", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetConsensusStatus(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ConsensusStatus, error) {
+	out := new(ConsensusStatus)
+	err := grpc.Invoke(ctx, "/protos.Admin/GetConsensusStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Admin service
 
 type AdminServer interface {
@@ -118,6 +313,12 @@ type AdminServer interface {
 	GetStatus(context.Context, *google_protobuf1.Empty) (*ServerStatus, error)
 	StartServer(context.Context, *google_protobuf1.Empty) (*ServerStatus, error)
 	StopServer(context.Context, *google_protobuf1.Empty) (*ServerStatus, error)
+	// Replace the validating peers of the network, the change is ordered
+	// through consensus and takes effect once committed.
+	ReconfigureValidators(context.Context, *ValidatorSet) (*google_protobuf1.Empty, error)
+	// Return the state and metrics of the consensus plugin of a validating
+	// peer.
+	GetConsensusStatus(context.Context, *google_protobuf1.Empty) (*ConsensusStatus, error)
 }
 
 func RegisterAdminServer(s *grpc.Server, srv AdminServer) {
@@ -160,6 +361,30 @@ func _Admin_StopServer_Handler(srv interface{}, ctx context.Context, dec func(in
 	return out, nil
 }
 
+func _Admin_ReconfigureValidators_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ValidatorSet)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).ReconfigureValidators(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_GetConsensusStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).GetConsensusStatus(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _Admin_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.Admin",
 	HandlerType: (*AdminServer)(nil),
@@ -176,6 +401,14 @@ var _Admin_serviceDesc = grpc.ServiceDesc{
 			MethodName: "StopServer",
 			Handler:    _Admin_StopServer_Handler,
 		},
+		{
+			MethodName: "ReconfigureValidators",
+			Handler:    _Admin_ReconfigureValidators_Handler,
+		},
+		{
+			MethodName: "GetConsensusStatus",
+			Handler:    _Admin_GetConsensusStatus_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{},
 }
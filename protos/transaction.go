@@ -90,6 +90,16 @@ func NewChaincodeDeployTransaction(chaincodeDeploymentSpec *ChaincodeDeploymentS
 	return transaction, nil
 }
 
+// NewChaincodeUpgradeTransaction is used to upgrade a deployed chaincode.
+func NewChaincodeUpgradeTransaction(chaincodeDeploymentSpec *ChaincodeDeploymentSpec, uuid string) (*Transaction, error) {
+	transaction, err := NewChaincodeDeployTransaction(chaincodeDeploymentSpec, uuid)
+	if err != nil {
+		return nil, err
+	}
+	transaction.Type = Transaction_CHAINCODE_UPGRADE
+	return transaction, nil
+}
+
 // NewChaincodeExecute is used to deploy chaincode.
 func NewChaincodeExecute(chaincodeInvocationSpec *ChaincodeInvocationSpec, uuid string, typ Transaction_Type) (*Transaction, error) {
 	transaction := new(Transaction)